@@ -0,0 +1,231 @@
+// Package bom builds bills of materials from the symbols of a schematic or
+// the footprints of a board, and writes them as CSV, TSV, JSON or Markdown.
+//
+// Parts are grouped into lines that share a value, footprint and any other
+// fields chosen by Options, and ordered by reference designator. Symbols and
+// footprints that KiCad excludes from bills of materials are always left
+// out, as are power symbols. Options can also exclude parts marked "do not
+// populate", which are otherwise kept on lines of their own.
+package bom
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/apparentlymart/go-kicad"
+)
+
+// Options customizes the fields of a bill of materials and how its parts
+// are grouped. A nil *Options is equivalent to a pointer to the zero value,
+// which groups parts by value and footprint only and has no extra fields.
+type Options struct {
+	// Fields are the extra columns of the bill of materials, after the
+	// references, quantity, value and footprint of each line.
+	Fields []Field
+
+	// GroupBy names fields from Fields whose values must also match for
+	// parts to share a line.
+	GroupBy []string
+
+	// ExcludeDNP leaves out parts marked "do not populate".
+	ExcludeDNP bool
+}
+
+// Field is a column of a bill of materials, whose value for each part is
+// taken from the first of the named properties that the part has a
+// non-empty value for. If Properties is empty then the property with the
+// same name as the field is used.
+//
+// Designs often name the same information differently, such as "MPN" or
+// "Manufacturer Part Number", so a field can list all of the names used.
+type Field struct {
+	Name       string
+	Properties []string
+}
+
+// ManufacturerFields are fields for the manufacturer and manufacturer part
+// number of each part, under the property names most commonly used for
+// them.
+var ManufacturerFields = []Field{
+	{Name: "Manufacturer", Properties: []string{"Manufacturer", "MANUFACTURER", "Mfr", "MFR"}},
+	{Name: "MPN", Properties: []string{"MPN", "Manufacturer Part Number", "Mfr Part Number", "PartNumber", "MFN"}},
+}
+
+// Line is a line of a bill of materials, describing a group of parts.
+type Line struct {
+	// References are the reference designators of the parts, ordered
+	// naturally so that "R2" comes before "R10".
+	References []string
+
+	Value     string
+	Footprint string
+	DNP       bool
+
+	// Fields maps the name of each field from Options to its value. If
+	// the parts of a line have different values for a field that isn't
+	// used for grouping, the distinct values are joined by commas.
+	Fields map[string]string
+}
+
+// Quantity returns the number of parts on the line.
+func (l *Line) Quantity() int {
+	return len(l.References)
+}
+
+// part is a single symbol or footprint, before grouping.
+type part struct {
+	ref              string
+	value, footprint string
+	dnp              bool
+	property         func(name string) string
+}
+
+// FromHierarchy returns the bill of materials for the symbols of all of
+// the sheets of the given schematic.
+//
+// Reference designators are those of each sheet instance, so a sheet file
+// used by several sheets contributes its parts once for each. The units of
+// a multi-unit symbol count as one part.
+func FromHierarchy(h *kicad.SchematicHierarchy, opts *Options) []Line {
+	var parts []part
+	seen := make(map[string]bool)
+	for _, hs := range h.Sheets {
+		for i := range hs.Schematic.Symbols {
+			sym := &hs.Schematic.Symbols[i]
+			ref, _ := h.SymbolReference(hs, sym)
+			lib := hs.Schematic.LibSymbol(sym)
+			if !sym.IncludeInBOM() || strings.HasPrefix(ref, "#") || (lib != nil && lib.Power) {
+				continue
+			}
+			if lib != nil && lib.UnitCount() > 1 {
+				if seen[ref] {
+					continue
+				}
+				seen[ref] = true
+			}
+			parts = append(parts, part{
+				ref:       ref,
+				value:     sym.Property("Value"),
+				footprint: sym.Property("Footprint"),
+				dnp:       sym.DNP,
+				property:  sym.Property,
+			})
+		}
+	}
+	return group(parts, opts)
+}
+
+// FromSchematic returns the bill of materials for the symbols of a single
+// schematic sheet, using the reference designators recorded in their
+// properties.
+func FromSchematic(sch *kicad.Schematic, opts *Options) []Line {
+	h := &kicad.SchematicHierarchy{
+		Root:   sch,
+		Sheets: []*kicad.HierarchySheet{{Path: "/", Schematic: sch}},
+	}
+	return FromHierarchy(h, opts)
+}
+
+// FromBoard returns the bill of materials for the footprints of the given
+// board, leaving out those that are marked as excluded from bills of
+// materials or that have no physical part.
+func FromBoard(pcb *kicad.PCB, opts *Options) []Line {
+	var parts []part
+	for i := range pcb.Footprints {
+		fp := &pcb.Footprints[i]
+		attr := &fp.Attr
+		if attr.ExcludeFromBOM || attr.BoardOnly || attr.Type == "virtual" {
+			continue
+		}
+		parts = append(parts, part{
+			ref:       fp.Reference(),
+			value:     fp.Value(),
+			footprint: fp.LibID,
+			dnp:       attr.DNP,
+			property:  fp.Property,
+		})
+	}
+	return group(parts, opts)
+}
+
+// group collects the given parts into lines of a bill of materials.
+func group(parts []part, opts *Options) []Line {
+	if opts == nil {
+		opts = &Options{}
+	}
+	sort.SliceStable(parts, func(i, j int) bool {
+		return kicad.ReferenceLess(parts[i].ref, parts[j].ref)
+	})
+
+	var lines []*Line
+	byKey := make(map[string]*Line)
+	// distinct records the field values already on each line, as the name
+	// and value separated by a NUL.
+	distinct := make(map[*Line]map[string]bool)
+	for _, p := range parts {
+		if p.dnp && opts.ExcludeDNP {
+			continue
+		}
+		fields := make(map[string]string, len(opts.Fields))
+		for _, f := range opts.Fields {
+			fields[f.Name] = fieldValue(f, p.property)
+		}
+
+		key := []string{p.value, p.footprint}
+		if p.dnp {
+			key = append(key, "dnp")
+		}
+		for _, name := range opts.GroupBy {
+			key = append(key, fields[name])
+		}
+		k := strings.Join(key, "\x00")
+
+		l, ok := byKey[k]
+		if !ok {
+			l = &Line{
+				Value:     p.value,
+				Footprint: p.footprint,
+				DNP:       p.dnp,
+				Fields:    fields,
+			}
+			byKey[k] = l
+			lines = append(lines, l)
+			distinct[l] = make(map[string]bool)
+		} else {
+			for name, v := range fields {
+				if v == "" || distinct[l][name+"\x00"+v] {
+					continue
+				}
+				if l.Fields[name] != "" {
+					v = l.Fields[name] + ", " + v
+				}
+				l.Fields[name] = v
+			}
+		}
+		for name, v := range fields {
+			distinct[l][name+"\x00"+v] = true
+		}
+		l.References = append(l.References, p.ref)
+	}
+
+	// The parts were sorted, so each line's first reference is its lowest
+	// and the lines are already in order of it.
+	ret := make([]Line, len(lines))
+	for i, l := range lines {
+		ret[i] = *l
+	}
+	return ret
+}
+
+func fieldValue(f Field, property func(name string) string) string {
+	names := f.Properties
+	if len(names) == 0 {
+		names = []string{f.Name}
+	}
+	for _, name := range names {
+		if v := property(name); v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,318 @@
+package bom
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/apparentlymart/go-kicad"
+	"github.com/davecgh/go-spew/spew"
+)
+
+const testSchematicSrc = `(kicad_sch (version 20231120) (generator "eeschema") (uuid "root")
+	(lib_symbols
+		(symbol "Device:R" (in_bom yes) (on_board yes)
+			(property "Reference" "R" (at 0 0 0))
+			(symbol "R_0_1")
+		)
+		(symbol "Amplifier_Operational:LM358" (in_bom yes) (on_board yes)
+			(property "Reference" "U" (at 0 0 0))
+			(symbol "LM358_1_1")
+			(symbol "LM358_2_1")
+		)
+		(symbol "power:GND" (power) (in_bom yes) (on_board yes)
+			(property "Reference" "#PWR" (at 0 0 0))
+			(symbol "GND_0_1")
+		)
+	)
+	(symbol (lib_id "Device:R") (at 0 0 0) (unit 1) (uuid "r10")
+		(property "Reference" "R10" (at 0 0 0))
+		(property "Value" "10k" (at 0 0 0))
+		(property "Footprint" "Resistor_SMD:R_0603_1608Metric" (at 0 0 0))
+		(property "MPN" "RC0603FR-0710KL" (at 0 0 0))
+	)
+	(symbol (lib_id "Device:R") (at 0 0 0) (unit 1) (uuid "r2")
+		(property "Reference" "R2" (at 0 0 0))
+		(property "Value" "10k" (at 0 0 0))
+		(property "Footprint" "Resistor_SMD:R_0603_1608Metric" (at 0 0 0))
+		(property "Manufacturer Part Number" "ERJ-3EKF1002V" (at 0 0 0))
+	)
+	(symbol (lib_id "Device:R") (at 0 0 0) (unit 1) (uuid "r1")
+		(property "Reference" "R1" (at 0 0 0))
+		(property "Value" "10k" (at 0 0 0))
+		(property "Footprint" "Resistor_SMD:R_0603_1608Metric" (at 0 0 0))
+		(property "MPN" "RC0603FR-0710KL" (at 0 0 0))
+	)
+	(symbol (lib_id "Device:R") (at 0 0 0) (unit 1) (dnp yes) (uuid "r3")
+		(property "Reference" "R3" (at 0 0 0))
+		(property "Value" "10k" (at 0 0 0))
+		(property "Footprint" "Resistor_SMD:R_0603_1608Metric" (at 0 0 0))
+	)
+	(symbol (lib_id "Device:R") (at 0 0 0) (unit 1) (in_bom no) (uuid "r4")
+		(property "Reference" "R4" (at 0 0 0))
+		(property "Value" "0" (at 0 0 0))
+	)
+	(symbol (lib_id "Amplifier_Operational:LM358") (at 0 0 0) (unit 1) (uuid "u1a")
+		(property "Reference" "U1" (at 0 0 0))
+		(property "Value" "LM358" (at 0 0 0))
+		(property "Footprint" "Package_SO:SOIC-8_3.9x4.9mm_P1.27mm" (at 0 0 0))
+	)
+	(symbol (lib_id "Amplifier_Operational:LM358") (at 0 0 0) (unit 2) (uuid "u1b")
+		(property "Reference" "U1" (at 0 0 0))
+		(property "Value" "LM358" (at 0 0 0))
+		(property "Footprint" "Package_SO:SOIC-8_3.9x4.9mm_P1.27mm" (at 0 0 0))
+	)
+	(symbol (lib_id "power:GND") (at 0 0 0) (unit 1) (uuid "gnd")
+		(property "Reference" "#PWR01" (at 0 0 0))
+		(property "Value" "GND" (at 0 0 0))
+	)
+)
+`
+
+func testSchematic(t *testing.T) *kicad.Schematic {
+	t.Helper()
+	sch, err := kicad.ReadSchematic(strings.NewReader(testSchematicSrc))
+	if err != nil {
+		t.Fatalf("reading schematic: %s", err)
+	}
+	return sch
+}
+
+func TestFromSchematic(t *testing.T) {
+	opts := &Options{Fields: []Field{{Name: "MPN", Properties: []string{"MPN", "Manufacturer Part Number"}}}}
+	got := FromSchematic(testSchematic(t), opts)
+	want := []Line{
+		{
+			References: []string{"R1", "R2", "R10"},
+			Value:      "10k",
+			Footprint:  "Resistor_SMD:R_0603_1608Metric",
+			Fields:     map[string]string{"MPN": "RC0603FR-0710KL, ERJ-3EKF1002V"},
+		},
+		{
+			References: []string{"R3"},
+			Value:      "10k",
+			Footprint:  "Resistor_SMD:R_0603_1608Metric",
+			DNP:        true,
+			Fields:     map[string]string{"MPN": ""},
+		},
+		{
+			References: []string{"U1"},
+			Value:      "LM358",
+			Footprint:  "Package_SO:SOIC-8_3.9x4.9mm_P1.27mm",
+			Fields:     map[string]string{"MPN": ""},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("incorrect result\ngot:\n%s\nwant:\n%s", spew.Sdump(got), spew.Sdump(want))
+	}
+}
+
+func TestFromSchematic_groupBy(t *testing.T) {
+	opts := &Options{
+		Fields:     ManufacturerFields,
+		GroupBy:    []string{"MPN"},
+		ExcludeDNP: true,
+	}
+	got := FromSchematic(testSchematic(t), opts)
+	var refs [][]string
+	for _, l := range got {
+		refs = append(refs, l.References)
+	}
+	want := [][]string{{"R1", "R10"}, {"R2"}, {"U1"}}
+	if !reflect.DeepEqual(refs, want) {
+		t.Errorf("wrong lines %v; want %v", refs, want)
+	}
+	if got, want := got[1].Fields["MPN"], "ERJ-3EKF1002V"; got != want {
+		t.Errorf("wrong MPN %q; want %q", got, want)
+	}
+}
+
+func TestFromHierarchy(t *testing.T) {
+	files := map[string]string{
+		"root.kicad_sch": `(kicad_sch (version 20231120) (uuid "root")
+	(sheet (at 0 0) (size 10 10) (uuid "a")
+		(property "Sheetname" "Left" (at 0 0 0))
+		(property "Sheetfile" "channel.kicad_sch" (at 0 0 0))
+	)
+	(sheet (at 20 0) (size 10 10) (uuid "b")
+		(property "Sheetname" "Right" (at 0 0 0))
+		(property "Sheetfile" "channel.kicad_sch" (at 0 0 0))
+	)
+)`,
+		"channel.kicad_sch": `(kicad_sch (version 20231120) (uuid "chan")
+	(symbol (lib_id "Device:R") (at 0 0 0) (unit 1) (uuid "r")
+		(property "Reference" "R?" (at 0 0 0))
+		(property "Value" "1k" (at 0 0 0))
+		(instances (project "p"
+			(path "/root/b" (reference "R2") (unit 1))
+			(path "/root/a" (reference "R11") (unit 1))
+		))
+	)
+)`,
+	}
+	h, err := kicad.LoadSchematicHierarchyFunc("root.kicad_sch", func(filename string) (*kicad.Schematic, error) {
+		src, ok := files[filename]
+		if !ok {
+			return nil, fmt.Errorf("no file %s", filename)
+		}
+		return kicad.ReadSchematic(strings.NewReader(src))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := FromHierarchy(h, nil)
+	want := []Line{
+		{References: []string{"R2", "R11"}, Value: "1k", Fields: map[string]string{}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("incorrect result\ngot:\n%s\nwant:\n%s", spew.Sdump(got), spew.Sdump(want))
+	}
+}
+
+func TestFromBoard(t *testing.T) {
+	pcb, err := kicad.ReadPCB(strings.NewReader(`(kicad_pcb (version 20240108) (generator pcbnew)
+  (footprint "Resistor_SMD:R_0603_1608Metric" (layer "F.Cu")
+    (property "Reference" "R10")
+    (property "Value" "10k")
+    (attr smd)
+  )
+  (footprint "Resistor_SMD:R_0603_1608Metric" (layer "F.Cu")
+    (property "Reference" "R2")
+    (property "Value" "10k")
+    (attr smd)
+  )
+  (footprint "TestPoint:TestPoint_Pad_D1.0mm" (layer "F.Cu")
+    (property "Reference" "TP1")
+    (property "Value" "TestPoint")
+    (attr smd exclude_from_bom)
+  )
+  (footprint "Symbol:Logo" (layer "F.Cu")
+    (property "Reference" "G1")
+    (property "Value" "Logo")
+    (attr board_only)
+  )
+  (footprint "Capacitor_SMD:C_0603_1608Metric" (layer "B.Cu")
+    (property "Reference" "C1")
+    (property "Value" "100n")
+    (attr smd dnp)
+  )
+)
+`))
+	if err != nil {
+		t.Fatalf("reading board: %s", err)
+	}
+
+	got := FromBoard(pcb, nil)
+	want := []Line{
+		{References: []string{"C1"}, Value: "100n", Footprint: "Capacitor_SMD:C_0603_1608Metric", DNP: true, Fields: map[string]string{}},
+		{References: []string{"R2", "R10"}, Value: "10k", Footprint: "Resistor_SMD:R_0603_1608Metric", Fields: map[string]string{}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("incorrect result\ngot:\n%s\nwant:\n%s", spew.Sdump(got), spew.Sdump(want))
+	}
+}
+
+var testLines = []Line{
+	{
+		References: []string{"R1", "R2"},
+		Value:      "10k",
+		Footprint:  "R_0603",
+		Fields:     map[string]string{"MPN": `RC0603 "FR"`},
+	},
+	{
+		References: []string{"J1"},
+		Value:      "Conn|2",
+		Footprint:  "Conn\tTH",
+		DNP:        true,
+		Fields:     map[string]string{},
+	},
+}
+
+var testOptions = &Options{Fields: []Field{{Name: "MPN"}}}
+
+func TestWriteCSV(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteCSV(&buf, testLines, testOptions); err != nil {
+		t.Fatal(err)
+	}
+	got := buf.String()
+	want := `"Reference","Quantity","Value","Footprint","MPN","DNP"
+"R1, R2","2","10k","R_0603","RC0603 ""FR""",""
+"J1","1","Conn|2","Conn	TH","","DNP"
+`
+	if got != want {
+		t.Errorf("incorrect result\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestWriteTSV(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteTSV(&buf, testLines, testOptions); err != nil {
+		t.Fatal(err)
+	}
+	got := buf.String()
+	want := "Reference\tQuantity\tValue\tFootprint\tMPN\tDNP\n" +
+		"R1, R2\t2\t10k\tR_0603\tRC0603 \"FR\"\t\n" +
+		"J1\t1\tConn|2\tConn TH\t\tDNP\n"
+	if got != want {
+		t.Errorf("incorrect result\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestWriteMarkdown(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteMarkdown(&buf, testLines, testOptions); err != nil {
+		t.Fatal(err)
+	}
+	got := buf.String()
+	want := `| Reference | Quantity | Value | Footprint | MPN | DNP |
+| --- | --: | --- | --- | --- | --- |
+| R1, R2 | 2 | 10k | R_0603 | RC0603 "FR" |  |
+| J1 | 1 | Conn\|2 | Conn	TH |  | DNP |
+`
+	if got != want {
+		t.Errorf("incorrect result\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteJSON(&buf, testLines, testOptions); err != nil {
+		t.Fatal(err)
+	}
+	got := buf.String()
+	want := `[
+  {
+    "references": [
+      "R1",
+      "R2"
+    ],
+    "quantity": 2,
+    "value": "10k",
+    "footprint": "R_0603",
+    "dnp": false,
+    "fields": {
+      "MPN": "RC0603 \"FR\""
+    }
+  },
+  {
+    "references": [
+      "J1"
+    ],
+    "quantity": 1,
+    "value": "Conn|2",
+    "footprint": "Conn\tTH",
+    "dnp": true,
+    "fields": {
+      "MPN": ""
+    }
+  }
+]
+`
+	if got != want {
+		t.Errorf("incorrect result\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
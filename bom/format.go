@@ -0,0 +1,139 @@
+package bom
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// columns returns the header of each column of a bill of materials with the
+// fields from the given options.
+func columns(opts *Options) []string {
+	ret := []string{"Reference", "Quantity", "Value", "Footprint"}
+	for _, f := range opts.Fields {
+		ret = append(ret, f.Name)
+	}
+	return append(ret, "DNP")
+}
+
+// row returns the value of each column of a line, in the order of columns.
+func row(l *Line, opts *Options) []string {
+	ret := []string{strings.Join(l.References, ", "), strconv.Itoa(l.Quantity()), l.Value, l.Footprint}
+	for _, f := range opts.Fields {
+		ret = append(ret, l.Fields[f.Name])
+	}
+	dnp := ""
+	if l.DNP {
+		dnp = "DNP"
+	}
+	return append(ret, dnp)
+}
+
+// WriteCSV writes the given lines to the given writer as comma-separated
+// values, with a header row naming the columns.
+func WriteCSV(w io.Writer, lines []Line, opts *Options) error {
+	return writeTable(w, lines, opts, func(cells []string) string {
+		for i, c := range cells {
+			cells[i] = `"` + strings.ReplaceAll(c, `"`, `""`) + `"`
+		}
+		return strings.Join(cells, ",")
+	})
+}
+
+// WriteTSV writes the given lines to the given writer as tab-separated
+// values, with a header row naming the columns. Tabs and line breaks within
+// values are replaced by spaces, because the format has no way to escape
+// them.
+func WriteTSV(w io.Writer, lines []Line, opts *Options) error {
+	r := strings.NewReplacer("\t", " ", "\r\n", " ", "\n", " ", "\r", " ")
+	return writeTable(w, lines, opts, func(cells []string) string {
+		for i, c := range cells {
+			cells[i] = r.Replace(c)
+		}
+		return strings.Join(cells, "\t")
+	})
+}
+
+// WriteMarkdown writes the given lines to the given writer as a Markdown
+// table.
+func WriteMarkdown(w io.Writer, lines []Line, opts *Options) error {
+	r := strings.NewReplacer(`\`, `\\`, "|", `\|`, "\r\n", " ", "\n", " ", "\r", " ")
+	header := true
+	return writeTable(w, lines, opts, func(cells []string) string {
+		for i, c := range cells {
+			cells[i] = r.Replace(c)
+		}
+		ret := "| " + strings.Join(cells, " | ") + " |"
+		if header {
+			header = false
+			rule := make([]string, len(cells))
+			for i := range rule {
+				rule[i] = "---"
+			}
+			// The quantity is a number, so is aligned to the right.
+			rule[1] = "--:"
+			ret += "\n| " + strings.Join(rule, " | ") + " |"
+		}
+		return ret
+	})
+}
+
+// writeTable writes the header and lines of a bill of materials, formatting
+// the cells of each row with the given function.
+func writeTable(w io.Writer, lines []Line, opts *Options, format func(cells []string) string) error {
+	if opts == nil {
+		opts = &Options{}
+	}
+	var buf bytes.Buffer
+	buf.WriteString(format(columns(opts)) + "\n")
+	for i := range lines {
+		buf.WriteString(format(row(&lines[i], opts)) + "\n")
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// jsonLine is the form of a Line in JSON output.
+type jsonLine struct {
+	References []string          `json:"references"`
+	Quantity   int               `json:"quantity"`
+	Value      string            `json:"value"`
+	Footprint  string            `json:"footprint"`
+	DNP        bool              `json:"dnp"`
+	Fields     map[string]string `json:"fields,omitempty"`
+}
+
+// WriteJSON writes the given lines to the given writer as a JSON array with
+// an object for each line.
+func WriteJSON(w io.Writer, lines []Line, opts *Options) error {
+	if opts == nil {
+		opts = &Options{}
+	}
+	out := make([]jsonLine, len(lines))
+	for i, l := range lines {
+		jl := jsonLine{
+			References: l.References,
+			Quantity:   l.Quantity(),
+			Value:      l.Value,
+			Footprint:  l.Footprint,
+			DNP:        l.DNP,
+		}
+		if len(opts.Fields) > 0 {
+			jl.Fields = make(map[string]string, len(opts.Fields))
+			for _, f := range opts.Fields {
+				jl.Fields[f.Name] = l.Fields[f.Name]
+			}
+		}
+		out[i] = jl
+	}
+	buf, err := json.MarshalIndent(out, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding JSON: %w", err)
+	}
+	buf = append(buf, '\n')
+	_, err = w.Write(buf)
+	return err
+}
@@ -0,0 +1,181 @@
+package kicad
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/apparentlymart/go-kicad/sexp"
+)
+
+// ReadDesignRules reads a stream containing custom design rules, as found
+// in a .kicad_dru file, and returns a DesignRules structure describing
+// them.
+//
+// The conditions of the rules are not parsed here. See the drc package
+// for evaluating them.
+func ReadDesignRules(r io.Reader) (*DesignRules, error) {
+	rules := &DesignRules{}
+	err := sexp.DecodeSequence(r, rules)
+	return rules, err
+}
+
+// ReadDesignRulesFile is a convenience wrapper around ReadDesignRules that
+// takes a filename and opens the given file for reading before calling
+// ReadDesignRules.
+func ReadDesignRulesFile(filename string) (*DesignRules, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ReadDesignRules(f)
+}
+
+// WriteDesignRules writes the given custom design rules to the given
+// writer, in the form of a .kicad_dru file.
+func WriteDesignRules(w io.Writer, rules *DesignRules) error {
+	return sexp.EncodeSequence(w, rules)
+}
+
+// WriteDesignRulesFile is a convenience wrapper around WriteDesignRules that
+// creates or replaces the given file before calling WriteDesignRules.
+func WriteDesignRulesFile(filename string, rules *DesignRules) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+
+	err = WriteDesignRules(f, rules)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// DesignRules represents a custom design rules file, which is a sequence of
+// rules rather than a single top-level tuple like other KiCad documents.
+//
+// Where several rules give the same type of constraint for an item, the
+// one that appears latest takes precedence.
+type DesignRules struct {
+	Version int          `kicad:"version"`
+	Rules   []DesignRule `kicad:"rule,multi,flat"`
+}
+
+// DesignRule is a custom design rule, which applies its constraints to the
+// items for which Condition is true, or to all items if it is empty.
+//
+// Layer restricts the rule to a single layer, or is "outer" or "inner" for
+// the outer or inner copper layers, or is empty for all layers. Severity
+// is "error", "warning" or "ignore", or empty for the default severity of
+// each check.
+type DesignRule struct {
+	Name        string           `kicad:""`
+	Layer       string           `kicad:"layer"`
+	Severity    string           `kicad:"severity"`
+	Condition   string           `kicad:"condition"`
+	Constraints []RuleConstraint `kicad:"constraint,multi,flat"`
+}
+
+// Constraint returns the constraint of the given type, such as
+// "clearance", or nil if the rule has no such constraint.
+func (r *DesignRule) Constraint(typ string) *RuleConstraint {
+	for i := range r.Constraints {
+		if r.Constraints[i].Type == typ {
+			return &r.Constraints[i]
+		}
+	}
+	return nil
+}
+
+// RuleConstraint is a constraint within a DesignRule.
+//
+// Most constraint types, like "clearance" or "track_width", give some of
+// Min, Opt and Max. Others give Args instead, such as the item types of a
+// "disallow" constraint or the expression of an "assertion" constraint.
+// WithinDiffPairs applies to "skew" constraints only.
+type RuleConstraint struct {
+	Type            string    `kicad:""`
+	Args            []string  `kicad:",flat,optional"`
+	Min             RuleValue `kicad:"min,raw"`
+	Opt             RuleValue `kicad:"opt,raw"`
+	Max             RuleValue `kicad:"max,raw"`
+	WithinDiffPairs bool      `kicad:"within_diff_pairs,empty"`
+}
+
+// RuleValue is a value of a rule constraint, which is a number with an
+// optional unit suffix such as "0.2mm", "8mil" or "45deg". It is empty if
+// the constraint does not give the value.
+type RuleValue string
+
+// Millimeters returns the value as a distance in millimeters. Values with
+// no unit are taken to be in millimeters.
+func (v RuleValue) Millimeters() (float64, error) {
+	n, unit, err := v.parse()
+	if err != nil {
+		return 0, err
+	}
+	switch unit {
+	case "", "mm":
+		return n, nil
+	case "um":
+		return n / 1000, nil
+	case "mil", "mils", "th", "thou":
+		return n * 0.0254, nil
+	case "in", `"`:
+		return n * 25.4, nil
+	default:
+		return 0, fmt.Errorf("invalid distance %q", string(v))
+	}
+}
+
+// Degrees returns the value as an angle in degrees. Values with no unit are
+// taken to be in degrees.
+func (v RuleValue) Degrees() (float64, error) {
+	n, unit, err := v.parse()
+	if err != nil {
+		return 0, err
+	}
+	switch unit {
+	case "", "deg", "°":
+		return n, nil
+	default:
+		return 0, fmt.Errorf("invalid angle %q", string(v))
+	}
+}
+
+// Number returns the value as a plain number, such as the count of a
+// "via_count" constraint. The value must not have a unit.
+func (v RuleValue) Number() (float64, error) {
+	n, unit, err := v.parse()
+	if err != nil {
+		return 0, err
+	}
+	if unit != "" {
+		return 0, fmt.Errorf("invalid number %q", string(v))
+	}
+	return n, nil
+}
+
+func (v RuleValue) parse() (float64, string, error) {
+	s := strings.TrimSpace(string(v))
+	end := 0
+	for end < len(s) && strings.IndexByte("0123456789.+-eE", s[end]) >= 0 {
+		// An "e" is part of the number only if an exponent follows, so
+		// that we don't take it from a unit.
+		if (s[end] == 'e' || s[end] == 'E') && (end+1 >= len(s) || strings.IndexByte("0123456789+-", s[end+1]) < 0) {
+			break
+		}
+		end++
+	}
+	n, err := strconv.ParseFloat(s[:end], 64)
+	if err != nil {
+		return 0, "", fmt.Errorf("invalid value %q", string(v))
+	}
+	return n, strings.ToLower(strings.TrimSpace(s[end:])), nil
+}
@@ -0,0 +1,133 @@
+package kicad
+
+import (
+	"bytes"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/davecgh/go-spew/spew"
+)
+
+func TestReadDesignRules(t *testing.T) {
+	src := `(version 1)
+
+# High voltage nets need extra room
+(rule "HV clearance"
+	(constraint clearance (min 1.5mm))
+	(condition "A.NetClass == 'HV' && B.NetClass != 'HV'"))
+
+(rule "Outer track width"
+	(layer outer)
+	(constraint track_width (min 8mil) (opt 0.25mm) (max 0.5mm)))
+
+(rule "No vias under U1"
+	(severity warning)
+	(constraint disallow via micro_via)
+	(condition "A.insideArea('U1 keepout')"))
+
+(rule "DDR skew"
+	(constraint skew (max 0.1mm) (within_diff_pairs))
+	(condition "A.inDiffPair('/DDR_DQS*')"))
+`
+	got, err := ReadDesignRules(strings.NewReader(src))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	want := &DesignRules{
+		Version: 1,
+		Rules: []DesignRule{
+			{
+				Name:        "HV clearance",
+				Condition:   "A.NetClass == 'HV' && B.NetClass != 'HV'",
+				Constraints: []RuleConstraint{{Type: "clearance", Min: "1.5mm"}},
+			},
+			{
+				Name:        "Outer track width",
+				Layer:       "outer",
+				Constraints: []RuleConstraint{{Type: "track_width", Min: "8mil", Opt: "0.25mm", Max: "0.5mm"}},
+			},
+			{
+				Name:        "No vias under U1",
+				Severity:    "warning",
+				Condition:   "A.insideArea('U1 keepout')",
+				Constraints: []RuleConstraint{{Type: "disallow", Args: []string{"via", "micro_via"}}},
+			},
+			{
+				Name:        "DDR skew",
+				Condition:   "A.inDiffPair('/DDR_DQS*')",
+				Constraints: []RuleConstraint{{Type: "skew", Max: "0.1mm", WithinDiffPairs: true}},
+			},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("incorrect result\ngot:  %swant: %s", spew.Sdump(got), spew.Sdump(want))
+	}
+
+	if c := got.Rules[1].Constraint("track_width"); c == nil {
+		t.Errorf("no track_width constraint")
+	} else if min, err := c.Min.Millimeters(); err != nil || math.Abs(min-0.2032) > 1e-9 {
+		t.Errorf("wrong minimum track width %v (%v)", min, err)
+	}
+	if c := got.Rules[1].Constraint("clearance"); c != nil {
+		t.Errorf("found nonexistent clearance constraint")
+	}
+
+	var buf bytes.Buffer
+	if err := WriteDesignRules(&buf, got); err != nil {
+		t.Fatalf("unexpected error writing: %s", err)
+	}
+	if !strings.Contains(buf.String(), "(min 8mil)") {
+		t.Errorf("values not written raw\n%s", buf.String())
+	}
+	again, err := ReadDesignRules(&buf)
+	if err != nil {
+		t.Fatalf("unexpected error reading result: %s", err)
+	}
+	if !reflect.DeepEqual(again, want) {
+		t.Errorf("incorrect round-trip\ngot:  %swant: %s", spew.Sdump(again), spew.Sdump(want))
+	}
+}
+
+func TestRuleValue(t *testing.T) {
+	tests := []struct {
+		v       RuleValue
+		mm      float64
+		wantErr bool
+	}{
+		{"0.2mm", 0.2, false},
+		{"0.2", 0.2, false},
+		{"10mil", 0.254, false},
+		{"10 mils", 0.254, false},
+		{"1in", 25.4, false},
+		{"150um", 0.15, false},
+		{"1e-1mm", 0.1, false},
+		{"45deg", 0, true},
+		{"mm", 0, true},
+	}
+	for _, test := range tests {
+		got, err := test.v.Millimeters()
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("%q: no error", test.v)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: unexpected error: %s", test.v, err)
+			continue
+		}
+		if math.Abs(got-test.mm) > 1e-9 {
+			t.Errorf("%q: got %v; want %v", test.v, got, test.mm)
+		}
+	}
+
+	if got, err := RuleValue("45deg").Degrees(); err != nil || got != 45 {
+		t.Errorf("wrong angle %v (%v)", got, err)
+	}
+	if got, err := RuleValue("3").Number(); err != nil || got != 3 {
+		t.Errorf("wrong number %v (%v)", got, err)
+	}
+}
@@ -0,0 +1,60 @@
+package kicad
+
+// Dimension is a dimension annotation on a board.
+//
+// Type is one of "aligned", "orthogonal", "leader", "center" or "radial".
+// Points gives the points being measured, or for leader, center and radial
+// dimensions the point being annotated and the end of the leader line.
+//
+// Documents from KiCad 5 instead give the measured value in Value and the
+// line width in Width, with the shape of the dimension given by tuples that
+// this type does not model. Their Type is empty.
+type Dimension struct {
+	Value        float64         `kicad:",optional"`
+	Locked       bool            `kicad:"locked,bare"`
+	Type         string          `kicad:"type"`
+	Layer        string          `kicad:"layer"`
+	UUID         string          `kicad:"uuid"`
+	TStamp       string          `kicad:"tstamp"`
+	Points       Points          `kicad:"pts,flat"`
+	Height       float64         `kicad:"height"`
+	Orientation  int             `kicad:"orientation"`
+	LeaderLength float64         `kicad:"leader_length"`
+	Width        float64         `kicad:"width"`
+	Text         BoardText       `kicad:"gr_text,flat"`
+	Format       DimensionFormat `kicad:"format,flat"`
+	Style        DimensionStyle  `kicad:"style,flat"`
+}
+
+// DimensionFormat describes how the measured value of a dimension is shown.
+//
+// Units is 0 for inches, 1 for mils, 2 for millimeters and 3 to follow the
+// editor's current units. UnitsFormat is 0 to show no units, 1 for units
+// without parentheses and 2 for units in parentheses.
+type DimensionFormat struct {
+	Prefix         string `kicad:"prefix"`
+	Suffix         string `kicad:"suffix"`
+	Units          int    `kicad:"units"`
+	UnitsFormat    int    `kicad:"units_format"`
+	Precision      int    `kicad:"precision"`
+	OverrideValue  string `kicad:"override_value"`
+	SuppressZeroes bool   `kicad:"suppress_zeroes,bare"`
+}
+
+// DimensionStyle describes how the lines and arrows of a dimension are
+// drawn.
+//
+// TextPositionMode is 0 for text outside the dimension line, 1 for text
+// inline with it and 2 for text positioned manually. TextFrame, used by
+// leader dimensions, is 0 for no frame, 1 for a rectangle and 2 for a
+// circle.
+type DimensionStyle struct {
+	Thickness        float64 `kicad:"thickness"`
+	ArrowLength      float64 `kicad:"arrow_length"`
+	ArrowDirection   string  `kicad:"arrow_direction"`
+	TextPositionMode int     `kicad:"text_position_mode"`
+	ExtensionHeight  float64 `kicad:"extension_height"`
+	ExtensionOffset  float64 `kicad:"extension_offset"`
+	KeepTextAligned  bool    `kicad:"keep_text_aligned,bare"`
+	TextFrame        int     `kicad:"text_frame"`
+}
@@ -0,0 +1,271 @@
+package kicad
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/apparentlymart/go-kicad/sexp"
+)
+
+// ReadDrawingSheet reads a stream containing a drawing sheet, as found in a
+// .kicad_wks file, and returns a DrawingSheet structure describing it.
+// Drawing sheets from KiCad 5, whose top-level keyword is "page_layout",
+// are also accepted.
+func ReadDrawingSheet(r io.Reader) (*DrawingSheet, error) {
+	ds := &DrawingSheet{}
+	_, err := sexp.DecodeAny(r, []string{"kicad_wks", "page_layout"}, ds)
+	return ds, err
+}
+
+// ReadDrawingSheetFile is a convenience wrapper around ReadDrawingSheet that
+// takes a filename and opens the given file for reading before calling
+// ReadDrawingSheet.
+func ReadDrawingSheetFile(filename string) (*DrawingSheet, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ReadDrawingSheet(f)
+}
+
+// WriteDrawingSheet writes the given drawing sheet to the given writer, in
+// the form of a .kicad_wks file.
+//
+// Items are written grouped by their type, so the relative order of items
+// of different types in a drawing sheet previously returned by
+// ReadDrawingSheet is not preserved. That order affects only which items
+// are drawn over others.
+func WriteDrawingSheet(w io.Writer, ds *DrawingSheet) error {
+	return sexp.Encode(w, "kicad_wks", ds)
+}
+
+// WriteDrawingSheetFile is a convenience wrapper around WriteDrawingSheet
+// that creates or replaces the given file before calling WriteDrawingSheet.
+func WriteDrawingSheetFile(filename string, ds *DrawingSheet) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+
+	err = WriteDrawingSheet(f, ds)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// DrawingSheet represents a drawing sheet, which describes the frame and
+// title block drawn around the pages of a schematic or the plots of a
+// board. Use Render to find the geometry of the sheet for a particular
+// page.
+//
+// Setup is nil if the sheet doesn't give one, in which case KiCad's
+// defaults apply.
+type DrawingSheet struct {
+	Version          int                   `kicad:"version"`
+	Generator        string                `kicad:"generator"`
+	GeneratorVersion string                `kicad:"generator_version"`
+	Setup            *DrawingSheetSetup    `kicad:"setup,flat"`
+	Lines            []DrawingSheetLine    `kicad:"line,multi,flat"`
+	Rects            []DrawingSheetRect    `kicad:"rect,multi,flat"`
+	Texts            []DrawingSheetText    `kicad:"tbtext,multi,flat"`
+	Polygons         []DrawingSheetPolygon `kicad:"polygon,multi,flat"`
+	Bitmaps          []DrawingSheetBitmap  `kicad:"bitmap,multi,flat"`
+}
+
+// DrawingSheetSetup gives the defaults for the items of a drawing sheet,
+// and the margins between the edges of the page and the corners that item
+// positions are relative to.
+type DrawingSheetSetup struct {
+	TextSize      Size    `kicad:"textsize,flat"`
+	LineWidth     float64 `kicad:"linewidth"`
+	TextLineWidth float64 `kicad:"textlinewidth"`
+	LeftMargin    float64 `kicad:"left_margin,always"`
+	RightMargin   float64 `kicad:"right_margin,always"`
+	TopMargin     float64 `kicad:"top_margin,always"`
+	BottomMargin  float64 `kicad:"bottom_margin,always"`
+}
+
+// DrawingSheetPoint is a position in a drawing sheet, which is relative to
+// one of the corners of the page inside its margins.
+//
+// Corner is one of "ltcorner", "lbcorner", "rbcorner" or "rtcorner" for the
+// left top, left bottom, right bottom and right top corners, or is empty
+// for the default of the right bottom corner. X and Y increase away from
+// the corner, towards the middle of the page.
+type DrawingSheetPoint struct {
+	X      float64 `kicad:""`
+	Y      float64 `kicad:""`
+	Corner string  `kicad:",optional"`
+}
+
+// Option values for the items of a drawing sheet, which restrict them to
+// the first page or to the other pages of a document.
+const (
+	DrawingSheetPage1Only  = "page1only"
+	DrawingSheetNotOnPage1 = "notonpage1"
+)
+
+// DrawingSheetLine is a line segment in a drawing sheet.
+//
+// Each item of a drawing sheet may be drawn Repeat times, with each copy
+// offset by IncrX and IncrY from the previous, in the direction away from
+// its corner. Copies other than the first are drawn only if they are
+// within the margins of the page.
+type DrawingSheetLine struct {
+	Name      string            `kicad:"name,always"`
+	Start     DrawingSheetPoint `kicad:"start,flat"`
+	End       DrawingSheetPoint `kicad:"end,flat"`
+	Option    string            `kicad:"option"`
+	LineWidth float64           `kicad:"linewidth"`
+	Repeat    int               `kicad:"repeat"`
+	IncrX     float64           `kicad:"incrx"`
+	IncrY     float64           `kicad:"incry"`
+	Comment   string            `kicad:"comment"`
+}
+
+// DrawingSheetRect is a rectangle in a drawing sheet, given by two of its
+// opposite corners.
+type DrawingSheetRect struct {
+	Name      string            `kicad:"name,always"`
+	Start     DrawingSheetPoint `kicad:"start,flat"`
+	End       DrawingSheetPoint `kicad:"end,flat"`
+	Option    string            `kicad:"option"`
+	LineWidth float64           `kicad:"linewidth"`
+	Repeat    int               `kicad:"repeat"`
+	IncrX     float64           `kicad:"incrx"`
+	IncrY     float64           `kicad:"incry"`
+	Comment   string            `kicad:"comment"`
+}
+
+// DrawingSheetText is a text item in a drawing sheet. Text may refer to
+// text variables such as ${TITLE} or ${#}.
+//
+// When the item is repeated, IncrLabel, or 1 if it is zero, is added to the
+// last character of the text of each copy in turn, so that a border can be
+// labelled 1, 2, 3 or A, B, C. MaxLen and MaxHeight, if set, give the largest size that the text may
+// be drawn at, and the text is shrunk to fit if necessary.
+type DrawingSheetText struct {
+	Text      string              `kicad:""`
+	Name      string              `kicad:"name,always"`
+	Pos       DrawingSheetPoint   `kicad:"pos,flat"`
+	Option    string              `kicad:"option"`
+	Font      DrawingSheetFont    `kicad:"font,flat"`
+	Justify   DrawingSheetJustify `kicad:"justify,flat"`
+	Rotate    float64             `kicad:"rotate"`
+	MaxLen    float64             `kicad:"maxlen"`
+	MaxHeight float64             `kicad:"maxheight"`
+	Repeat    int                 `kicad:"repeat"`
+	IncrX     float64             `kicad:"incrx"`
+	IncrY     float64             `kicad:"incry"`
+	IncrLabel int                 `kicad:"incrlabel"`
+	Comment   string              `kicad:"comment"`
+}
+
+// DrawingSheetFont describes the appearance of a text item of a drawing
+// sheet. Zero sizes and widths are replaced with the defaults from the
+// sheet's setup.
+type DrawingSheetFont struct {
+	Face      string  `kicad:"face"`
+	LineWidth float64 `kicad:"linewidth"`
+	Size      Size    `kicad:"size,flat"`
+	Bold      bool    `kicad:"bold,bare"`
+	Italic    bool    `kicad:"italic,bare"`
+	Color     *Color  `kicad:"color,flat"`
+}
+
+// DrawingSheetJustify describes the alignment of a text item of a drawing
+// sheet relative to its position. By default text is aligned on its left
+// side and vertically centered. Center centers the text on both axes,
+// although Left, Right, Top or Bottom take precedence over it.
+type DrawingSheetJustify struct {
+	Left   bool `kicad:"left,bare"`
+	Center bool `kicad:"center,bare"`
+	Right  bool `kicad:"right,bare"`
+	Top    bool `kicad:"top,bare"`
+	Bottom bool `kicad:"bottom,bare"`
+}
+
+// DrawingSheetPolygon is a set of filled polygons in a drawing sheet. The
+// points of the outlines are relative to Pos, and are rotated by Rotate
+// degrees around it.
+type DrawingSheetPolygon struct {
+	Name      string            `kicad:"name,always"`
+	Pos       DrawingSheetPoint `kicad:"pos,flat"`
+	Option    string            `kicad:"option"`
+	Rotate    float64           `kicad:"rotate"`
+	LineWidth float64           `kicad:"linewidth"`
+	Repeat    int               `kicad:"repeat"`
+	IncrX     float64           `kicad:"incrx"`
+	IncrY     float64           `kicad:"incry"`
+	Comment   string            `kicad:"comment"`
+	Outlines  []Points          `kicad:"pts,multi,flat"`
+}
+
+// DrawingSheetBitmap is an image in a drawing sheet, which is centered on
+// Pos.
+type DrawingSheetBitmap struct {
+	Name    string              `kicad:"name,always"`
+	Pos     DrawingSheetPoint   `kicad:"pos,flat"`
+	Option  string              `kicad:"option"`
+	Scale   float64             `kicad:"scale"`
+	Repeat  int                 `kicad:"repeat"`
+	IncrX   float64             `kicad:"incrx"`
+	IncrY   float64             `kicad:"incry"`
+	Comment string              `kicad:"comment"`
+	PNGData DrawingSheetPNGData `kicad:"pngdata,flat"`
+}
+
+// DrawingSheetPNGData is the image of a bitmap in a drawing sheet, as a PNG
+// file written as hexadecimal bytes across many data tuples.
+type DrawingSheetPNGData struct {
+	Data []DrawingSheetPNGRow `kicad:"data,multi,flat"`
+}
+
+// DrawingSheetPNGRow is one of the data tuples of a DrawingSheetPNGData.
+// KiCad writes each byte as a separate value, but the bytes may also be
+// written together in a string separated by spaces.
+type DrawingSheetPNGRow struct {
+	Bytes []string `kicad:",flat,optional,raw"`
+}
+
+// PNG returns the bytes of the PNG image.
+func (d *DrawingSheetPNGData) PNG() ([]byte, error) {
+	var ret []byte
+	for _, row := range d.Data {
+		for _, s := range row.Bytes {
+			for _, tok := range strings.Fields(s) {
+				b, err := hex.DecodeString(tok)
+				if err != nil || len(b) != 1 {
+					return nil, fmt.Errorf("invalid PNG data byte %q", tok)
+				}
+				ret = append(ret, b[0])
+			}
+		}
+	}
+	return ret, nil
+}
+
+// SetPNG replaces the image with the given PNG image.
+func (d *DrawingSheetPNGData) SetPNG(png []byte) {
+	const rowLen = 32
+	d.Data = nil
+	for len(png) > 0 {
+		n := rowLen
+		if n > len(png) {
+			n = len(png)
+		}
+		row := DrawingSheetPNGRow{Bytes: make([]string, n)}
+		for i, b := range png[:n] {
+			row.Bytes[i] = fmt.Sprintf("%02X", b)
+		}
+		d.Data = append(d.Data, row)
+		png = png[n:]
+	}
+}
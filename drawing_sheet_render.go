@@ -0,0 +1,478 @@
+package kicad
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// DrawingSheetPage describes a page that a drawing sheet is drawn on, which
+// gives the values of the text variables in the sheet's text items.
+//
+// SheetNumber and SheetCount are the number of the page, counting from 1,
+// and the number of pages in the document. SheetName and SheetPath are the
+// name of the schematic sheet and its path in the hierarchy, as in
+// "/Power/". Layer is the name of the board layer being plotted, if any.
+// Vars are the project's text variables, which are used for variables that
+// the drawing sheet itself doesn't define.
+type DrawingSheetPage struct {
+	Paper        PaperSize
+	TitleBlock   TitleBlock
+	SheetNumber  int
+	SheetCount   int
+	SheetName    string
+	SheetPath    string
+	Filename     string
+	Layer        string
+	KiCadVersion string
+	Vars         map[string]string
+}
+
+// DrawingSheetFrame is the geometry of a drawing sheet as drawn on a
+// particular page. All positions are in millimeters from the top left
+// corner of the page.
+type DrawingSheetFrame struct {
+	Size     Size
+	Lines    []FrameLine
+	Rects    []FrameRect
+	Texts    []FrameText
+	Polygons []FramePolygon
+	Bitmaps  []FrameBitmap
+}
+
+// FrameLine is a line segment of a DrawingSheetFrame.
+type FrameLine struct {
+	Start Position
+	End   Position
+	Width float64
+}
+
+// FrameRect is an unfilled rectangle of a DrawingSheetFrame, given by two
+// of its opposite corners.
+type FrameRect struct {
+	Start Position
+	End   Position
+	Width float64
+}
+
+// FrameText is a text item of a DrawingSheetFrame, whose text variables
+// have been replaced with their values.
+//
+// MaxLen and MaxHeight are the largest width and height of the text, or
+// zero if it is unconstrained. Text that would be larger when drawn with
+// the given Size must be shrunk to fit them, which requires measuring the
+// text in its font.
+type FrameText struct {
+	Text      string
+	Pos       Position
+	Angle     float64
+	Size      Size
+	Width     float64
+	Face      string
+	Bold      bool
+	Italic    bool
+	Color     *Color
+	Justify   TextJustify
+	MaxLen    float64
+	MaxHeight float64
+}
+
+// FramePolygon is a set of filled polygons of a DrawingSheetFrame.
+type FramePolygon struct {
+	Outlines [][]Position
+	Width    float64
+}
+
+// FrameBitmap is a PNG image of a DrawingSheetFrame, which is centered on
+// Pos and drawn at the given scale.
+type FrameBitmap struct {
+	Pos   Position
+	Scale float64
+	PNG   []byte
+}
+
+// defaultDrawingSheetSetup is the setup of drawing sheets that don't give
+// one, which is also where the defaults of items come from.
+var defaultDrawingSheetSetup = DrawingSheetSetup{
+	TextSize:      Size{Width: 1.5, Height: 1.5},
+	LineWidth:     0.15,
+	TextLineWidth: 0.15,
+	LeftMargin:    10,
+	RightMargin:   10,
+	TopMargin:     10,
+	BottomMargin:  10,
+}
+
+// Render returns the geometry of the drawing sheet as drawn on the given
+// page, including only the items that appear on that page and replacing
+// text variables with their values.
+//
+// It returns an error if the page's paper size is not known, or if a
+// bitmap of the drawing sheet is invalid.
+func (ds *DrawingSheet) Render(page *DrawingSheetPage) (*DrawingSheetFrame, error) {
+	size, ok := page.Paper.Dimensions()
+	if !ok {
+		return nil, fmt.Errorf("unknown paper size %q", page.Paper.Name)
+	}
+
+	setup := defaultDrawingSheetSetup
+	if ds.Setup != nil {
+		setup = *ds.Setup
+		if setup.TextSize.Width == 0 || setup.TextSize.Height == 0 {
+			setup.TextSize = defaultDrawingSheetSetup.TextSize
+		}
+	}
+
+	r := &sheetRenderer{
+		page:  page,
+		setup: &setup,
+		lt:    Position{X: setup.LeftMargin, Y: setup.TopMargin},
+		rb:    Position{X: size.Width - setup.RightMargin, Y: size.Height - setup.BottomMargin},
+	}
+	frame := &DrawingSheetFrame{Size: size}
+
+	for _, item := range ds.Lines {
+		if !r.onPage(item.Option) {
+			continue
+		}
+		width := r.lineWidth(item.LineWidth)
+		for i := 0; i < repeatCount(item.Repeat); i++ {
+			start := r.point(item.Start, i, item.IncrX, item.IncrY)
+			end := r.point(item.End, i, item.IncrX, item.IncrY)
+			if i > 0 && !(r.inside(start) && r.inside(end)) {
+				continue
+			}
+			frame.Lines = append(frame.Lines, FrameLine{Start: start, End: end, Width: width})
+		}
+	}
+
+	for _, item := range ds.Rects {
+		if !r.onPage(item.Option) {
+			continue
+		}
+		width := r.lineWidth(item.LineWidth)
+		for i := 0; i < repeatCount(item.Repeat); i++ {
+			start := r.point(item.Start, i, item.IncrX, item.IncrY)
+			end := r.point(item.End, i, item.IncrX, item.IncrY)
+			if i > 0 && !(r.inside(start) && r.inside(end)) {
+				continue
+			}
+			frame.Rects = append(frame.Rects, FrameRect{Start: start, End: end, Width: width})
+		}
+	}
+
+	for _, item := range ds.Texts {
+		if !r.onPage(item.Option) {
+			continue
+		}
+		text := r.expand(item.Text)
+		incr := item.IncrLabel
+		if incr == 0 {
+			incr = 1
+		}
+		ft := FrameText{
+			Angle:     item.Rotate,
+			Size:      item.Font.Size,
+			Width:     item.Font.LineWidth,
+			Face:      item.Font.Face,
+			Bold:      item.Font.Bold,
+			Italic:    item.Font.Italic,
+			Color:     item.Font.Color,
+			Justify:   textJustify(item.Justify),
+			MaxLen:    item.MaxLen,
+			MaxHeight: item.MaxHeight,
+		}
+		if ft.Size.Width == 0 || ft.Size.Height == 0 {
+			ft.Size = setup.TextSize
+		}
+		if ft.Width == 0 {
+			ft.Width = setup.TextLineWidth
+		}
+		for i := 0; i < repeatCount(item.Repeat); i++ {
+			pos := r.point(item.Pos, i, item.IncrX, item.IncrY)
+			if i > 0 && !r.inside(pos) {
+				continue
+			}
+			ft.Pos = pos
+			ft.Text = incrementLabel(text, i*incr)
+			frame.Texts = append(frame.Texts, ft)
+		}
+	}
+
+	for _, item := range ds.Polygons {
+		if !r.onPage(item.Option) {
+			continue
+		}
+		width := r.lineWidth(item.LineWidth)
+		sin, cos := math.Sincos(item.Rotate * math.Pi / 180)
+	Repeats:
+		for i := 0; i < repeatCount(item.Repeat); i++ {
+			pos := r.point(item.Pos, i, item.IncrX, item.IncrY)
+			fp := FramePolygon{Width: width}
+			for _, outline := range item.Outlines {
+				xy := outline.Polyline()
+				points := make([]Position, len(xy))
+				for j, p := range xy {
+					points[j] = Position{
+						X: pos.X + p.X*cos - p.Y*sin,
+						Y: pos.Y + p.Y*cos + p.X*sin,
+					}
+					if i > 0 && !r.inside(points[j]) {
+						continue Repeats
+					}
+				}
+				fp.Outlines = append(fp.Outlines, points)
+			}
+			frame.Polygons = append(frame.Polygons, fp)
+		}
+	}
+
+	for _, item := range ds.Bitmaps {
+		if !r.onPage(item.Option) {
+			continue
+		}
+		png, err := item.PNGData.PNG()
+		if err != nil {
+			return nil, fmt.Errorf("invalid bitmap %q: %w", item.Name, err)
+		}
+		scale := item.Scale
+		if scale == 0 {
+			scale = 1
+		}
+		for i := 0; i < repeatCount(item.Repeat); i++ {
+			pos := r.point(item.Pos, i, item.IncrX, item.IncrY)
+			if i > 0 && !r.inside(pos) {
+				continue
+			}
+			frame.Bitmaps = append(frame.Bitmaps, FrameBitmap{Pos: pos, Scale: scale, PNG: png})
+		}
+	}
+
+	return frame, nil
+}
+
+type sheetRenderer struct {
+	page   *DrawingSheetPage
+	setup  *DrawingSheetSetup
+	lt, rb Position
+}
+
+func (r *sheetRenderer) onPage(option string) bool {
+	first := r.page.SheetNumber <= 1
+	switch option {
+	case DrawingSheetPage1Only:
+		return first
+	case DrawingSheetNotOnPage1:
+		return !first
+	default:
+		return true
+	}
+}
+
+func (r *sheetRenderer) lineWidth(w float64) float64 {
+	if w == 0 {
+		return r.setup.LineWidth
+	}
+	return w
+}
+
+// point returns the position on the page of the given copy of an item's
+// point, where each copy is offset from the previous one away from the
+// point's corner.
+func (r *sheetRenderer) point(p DrawingSheetPoint, i int, incrX, incrY float64) Position {
+	x := p.X + incrX*float64(i)
+	y := p.Y + incrY*float64(i)
+	switch p.Corner {
+	case "ltcorner":
+		return Position{X: r.lt.X + x, Y: r.lt.Y + y}
+	case "lbcorner":
+		return Position{X: r.lt.X + x, Y: r.rb.Y - y}
+	case "rtcorner":
+		return Position{X: r.rb.X - x, Y: r.lt.Y + y}
+	default:
+		return Position{X: r.rb.X - x, Y: r.rb.Y - y}
+	}
+}
+
+// inside returns true if the given position is within the margins of the
+// page, allowing for rounding errors.
+func (r *sheetRenderer) inside(p Position) bool {
+	const epsilon = 1e-6
+	return p.X >= r.lt.X-epsilon && p.X <= r.rb.X+epsilon &&
+		p.Y >= r.lt.Y-epsilon && p.Y <= r.rb.Y+epsilon
+}
+
+// expand replaces the text variables in the given text of a drawing sheet.
+func (r *sheetRenderer) expand(text string) string {
+	return ExpandTextVars(convertLegacyTextVars(text), r.resolve)
+}
+
+func (r *sheetRenderer) resolve(name string) (string, bool) {
+	page := r.page
+	tb := &page.TitleBlock
+	switch name {
+	case "#":
+		return strconv.Itoa(page.SheetNumber), true
+	case "##":
+		return strconv.Itoa(page.SheetCount), true
+	case "SHEETNAME":
+		return page.SheetName, true
+	case "SHEETPATH":
+		return page.SheetPath, true
+	case "FILENAME":
+		return page.Filename, true
+	case "LAYER":
+		return page.Layer, true
+	case "PAPER":
+		return page.Paper.Name, true
+	case "KICAD_VERSION":
+		return page.KiCadVersion, true
+	case "TITLE":
+		return tb.Title, true
+	case "ISSUE_DATE":
+		return tb.Date, true
+	case "REVISION":
+		return tb.Revision, true
+	case "COMPANY":
+		return tb.Company, true
+	}
+	if len(name) == len("COMMENT1") && strings.HasPrefix(name, "COMMENT") && name[7] >= '1' && name[7] <= '9' {
+		return tb.Comment(int(name[7] - '0')), true
+	}
+	v, ok := page.Vars[name]
+	return v, ok
+}
+
+// legacyTextVars are the text variables that KiCad 5 drawing sheets refer
+// to using a percent sign and a letter. Comments are written as %C0 to %C9
+// and are handled separately.
+var legacyTextVars = map[byte]string{
+	'K': "KICAD_VERSION",
+	'Z': "PAPER",
+	'Y': "COMPANY",
+	'D': "ISSUE_DATE",
+	'R': "REVISION",
+	'S': "#",
+	'N': "##",
+	'F': "FILENAME",
+	'L': "LAYER",
+	'P': "SHEETPATH",
+	'T': "TITLE",
+}
+
+// convertLegacyTextVars replaces the variable references of KiCad 5
+// drawing sheets, like %T, with the equivalent ${TITLE}.
+func convertLegacyTextVars(text string) string {
+	if !strings.Contains(text, "%") {
+		return text
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(text); i++ {
+		if text[i] != '%' || i+1 >= len(text) {
+			b.WriteByte(text[i])
+			continue
+		}
+		code := text[i+1]
+		switch {
+		case code == '%':
+			b.WriteByte('%')
+			i++
+		case code == 'C' && i+2 < len(text) && text[i+2] >= '0' && text[i+2] <= '8':
+			fmt.Fprintf(&b, "${COMMENT%c}", text[i+2]+1)
+			i += 2
+		case legacyTextVars[code] != "":
+			b.WriteString("${" + legacyTextVars[code] + "}")
+			i++
+		default:
+			b.WriteByte('%')
+		}
+	}
+	return b.String()
+}
+
+// incrementLabel returns the given label with n added to its last
+// character, or to its last digit as a number if it ends in a digit, so
+// that "A" becomes "C" and "9" becomes "11" when n is 2.
+func incrementLabel(label string, n int) string {
+	if n == 0 || label == "" {
+		return label
+	}
+	last, size := utf8.DecodeLastRuneInString(label)
+	prefix := label[:len(label)-size]
+	if last >= '0' && last <= '9' {
+		return prefix + strconv.Itoa(int(last-'0')+n)
+	}
+	return prefix + string(last+rune(n))
+}
+
+// textJustify converts the justification of a drawing sheet text item,
+// which is left aligned and vertically centered by default, to that of
+// other text.
+func textJustify(j DrawingSheetJustify) TextJustify {
+	ret := TextJustify{
+		Left:   !j.Center && !j.Right,
+		Right:  j.Right,
+		Top:    j.Top,
+		Bottom: j.Bottom,
+	}
+	if j.Left {
+		ret.Left, ret.Right = true, false
+	}
+	return ret
+}
+
+func repeatCount(n int) int {
+	if n < 1 {
+		return 1
+	}
+	return n
+}
+
+// DefaultDrawingSheet returns KiCad's built-in drawing sheet, which is used
+// for documents that don't specify another one.
+func DefaultDrawingSheet() *DrawingSheet {
+	ds, err := ReadDrawingSheet(strings.NewReader(defaultDrawingSheet))
+	if err != nil {
+		// Should never happen, since the default sheet is constant
+		panic(fmt.Sprintf("invalid default drawing sheet: %s", err))
+	}
+	return ds
+}
+
+const defaultDrawingSheet = `(kicad_wks (version 20220228) (generator "pl_editor")
+  (setup (textsize 1.5 1.5) (linewidth 0.15) (textlinewidth 0.15)
+    (left_margin 10) (right_margin 10) (top_margin 10) (bottom_margin 10))
+  (rect (name "") (start 110 34) (end 2 2) (comment "rect around the title block"))
+  (rect (name "") (start 0 0 ltcorner) (end 0 0) (repeat 2) (incrx 2) (incry 2))
+  (line (name "") (start 50 2 ltcorner) (end 50 0 ltcorner) (repeat 30) (incrx 50))
+  (tbtext "1" (name "") (pos 25 1 ltcorner) (font (size 1.3 1.3)) (justify center) (repeat 100) (incrx 50))
+  (line (name "") (start 50 2 lbcorner) (end 50 0 lbcorner) (repeat 30) (incrx 50))
+  (tbtext "1" (name "") (pos 25 1 lbcorner) (font (size 1.3 1.3)) (justify center) (repeat 100) (incrx 50))
+  (line (name "") (start 0 50 ltcorner) (end 2 50 ltcorner) (repeat 30) (incry 50))
+  (tbtext "A" (name "") (pos 1 25 ltcorner) (font (size 1.3 1.3)) (justify center) (repeat 100) (incry 50))
+  (line (name "") (start 0 50 rtcorner) (end 2 50 rtcorner) (repeat 30) (incry 50))
+  (tbtext "A" (name "") (pos 1 25 rtcorner) (font (size 1.3 1.3)) (justify center) (repeat 100) (incry 50))
+  (tbtext "Date: ${ISSUE_DATE}" (name "") (pos 87 6.9))
+  (line (name "") (start 110 5.5) (end 2 5.5))
+  (tbtext "${KICAD_VERSION}" (name "") (pos 109 4.1) (comment "Kicad version"))
+  (line (name "") (start 110 8.5) (end 2 8.5))
+  (tbtext "Rev: ${REVISION}" (name "") (pos 24 6.9) (font bold))
+  (tbtext "Size: ${PAPER}" (name "") (pos 109 6.9) (comment "Paper format name"))
+  (tbtext "Id: ${#}/${##}" (name "") (pos 24 4.1) (comment "Sheet id"))
+  (line (name "") (start 110 12.5) (end 2 12.5))
+  (tbtext "Title: ${TITLE}" (name "") (pos 109 10.7) (font (size 2 2) bold italic))
+  (tbtext "File: ${FILENAME}" (name "") (pos 109 14.3))
+  (line (name "") (start 110 18.5) (end 2 18.5))
+  (tbtext "Sheet: ${SHEETPATH}" (name "") (pos 109 17))
+  (tbtext "${COMPANY}" (name "") (pos 109 20) (font bold) (comment "Company name"))
+  (tbtext "${COMMENT1}" (name "") (pos 109 23) (comment "Comment 0"))
+  (tbtext "${COMMENT2}" (name "") (pos 109 26) (comment "Comment 1"))
+  (tbtext "${COMMENT3}" (name "") (pos 109 29) (comment "Comment 2"))
+  (tbtext "${COMMENT4}" (name "") (pos 109 32) (comment "Comment 3"))
+  (line (name "") (start 90 8.5) (end 90 5.5))
+  (line (name "") (start 26 8.5) (end 26 2))
+)
+`
@@ -0,0 +1,354 @@
+package kicad
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/davecgh/go-spew/spew"
+)
+
+func TestReadDrawingSheet(t *testing.T) {
+	src := `(kicad_wks (version 20220228) (generator "pl_editor") (generator_version "8.0")
+  (setup (textsize 1.5 1.5)(linewidth 0.15)(textlinewidth 0.15)
+  (left_margin 10)(right_margin 10)(top_margin 10)(bottom_margin 10))
+  (rect (name "") (start 110 34) (end 2 2) (comment "rect around the title block"))
+  (line (name "") (start 50 2 ltcorner) (end 50 0 ltcorner) (repeat 30) (incrx 50))
+  (tbtext "1" (name "") (pos 25 1 ltcorner) (font (size 1.3 1.3)) (justify center) (repeat 100) (incrx 50))
+  (tbtext "Title: ${TITLE}" (name "") (pos 109 10.7) (font (linewidth 0.3) (size 2 2) bold italic) (maxlen 100))
+  (tbtext "Draft" (name "") (pos 20 20 ltcorner) (option page1only) (rotate 90) (justify right top))
+  (polygon (name "") (pos 5 5 lbcorner) (rotate 0) (linewidth 0.1)
+    (pts (xy 0 0) (xy 2 0) (xy 1 2))
+  )
+  (bitmap (name "") (pos 30 30) (scale 0.5)
+    (pngdata
+      (data 89 50 4E 47)
+      (data 0D 0A)
+    )
+  )
+)
+`
+	got, err := ReadDrawingSheet(strings.NewReader(src))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	want := &DrawingSheet{
+		Version:          20220228,
+		Generator:        "pl_editor",
+		GeneratorVersion: "8.0",
+		Setup: &DrawingSheetSetup{
+			TextSize:      Size{Width: 1.5, Height: 1.5},
+			LineWidth:     0.15,
+			TextLineWidth: 0.15,
+			LeftMargin:    10,
+			RightMargin:   10,
+			TopMargin:     10,
+			BottomMargin:  10,
+		},
+		Lines: []DrawingSheetLine{
+			{
+				Start:  DrawingSheetPoint{X: 50, Y: 2, Corner: "ltcorner"},
+				End:    DrawingSheetPoint{X: 50, Corner: "ltcorner"},
+				Repeat: 30,
+				IncrX:  50,
+			},
+		},
+		Rects: []DrawingSheetRect{
+			{
+				Start:   DrawingSheetPoint{X: 110, Y: 34},
+				End:     DrawingSheetPoint{X: 2, Y: 2},
+				Comment: "rect around the title block",
+			},
+		},
+		Texts: []DrawingSheetText{
+			{
+				Text:    "1",
+				Pos:     DrawingSheetPoint{X: 25, Y: 1, Corner: "ltcorner"},
+				Font:    DrawingSheetFont{Size: Size{Width: 1.3, Height: 1.3}},
+				Justify: DrawingSheetJustify{Center: true},
+				Repeat:  100,
+				IncrX:   50,
+			},
+			{
+				Text: "Title: ${TITLE}",
+				Pos:  DrawingSheetPoint{X: 109, Y: 10.7},
+				Font: DrawingSheetFont{
+					LineWidth: 0.3,
+					Size:      Size{Width: 2, Height: 2},
+					Bold:      true,
+					Italic:    true,
+				},
+				MaxLen: 100,
+			},
+			{
+				Text:    "Draft",
+				Pos:     DrawingSheetPoint{X: 20, Y: 20, Corner: "ltcorner"},
+				Option:  DrawingSheetPage1Only,
+				Justify: DrawingSheetJustify{Right: true, Top: true},
+				Rotate:  90,
+			},
+		},
+		Polygons: []DrawingSheetPolygon{
+			{
+				Pos:       DrawingSheetPoint{X: 5, Y: 5, Corner: "lbcorner"},
+				LineWidth: 0.1,
+				Outlines: []Points{
+					NewPoints(Position{X: 0, Y: 0}, Position{X: 2, Y: 0}, Position{X: 1, Y: 2}),
+				},
+			},
+		},
+		Bitmaps: []DrawingSheetBitmap{
+			{
+				Pos:   DrawingSheetPoint{X: 30, Y: 30},
+				Scale: 0.5,
+				PNGData: DrawingSheetPNGData{
+					Data: []DrawingSheetPNGRow{
+						{Bytes: []string{"89", "50", "4E", "47"}},
+						{Bytes: []string{"0D", "0A"}},
+					},
+				},
+			},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("incorrect result\ngot:  %swant: %s", spew.Sdump(got), spew.Sdump(want))
+	}
+
+	png, err := got.Bitmaps[0].PNGData.PNG()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if want := []byte{0x89, 'P', 'N', 'G', '\r', '\n'}; !bytes.Equal(png, want) {
+		t.Errorf("wrong PNG data %x; want %x", png, want)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteDrawingSheet(&buf, got); err != nil {
+		t.Fatalf("unexpected error writing: %s", err)
+	}
+	if !strings.Contains(buf.String(), "(data 89 50 4E 47)") {
+		t.Errorf("PNG data not written as raw bytes:\n%s", buf.String())
+	}
+	again, err := ReadDrawingSheet(&buf)
+	if err != nil {
+		t.Fatalf("unexpected error reading written sheet: %s", err)
+	}
+	if !reflect.DeepEqual(again, want) {
+		t.Errorf("incorrect round-trip\ngot:  %swant: %s", spew.Sdump(again), spew.Sdump(want))
+	}
+}
+
+func TestReadDrawingSheet_kicad5(t *testing.T) {
+	src := `(page_layout
+  (setup (textsize 1.5 1.5)(linewidth 0.15)(textlinewidth 0.15)
+  (left_margin 10)(right_margin 10)(top_margin 10)(bottom_margin 10))
+  (tbtext "Id: %S/%N" (name "") (pos 24 4.1))
+)
+`
+	got, err := ReadDrawingSheet(strings.NewReader(src))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(got.Texts) != 1 || got.Texts[0].Text != "Id: %S/%N" {
+		t.Fatalf("wrong texts\n%s", spew.Sdump(got.Texts))
+	}
+
+	frame, err := got.Render(&DrawingSheetPage{
+		Paper:       PaperSize{Name: "A4"},
+		SheetNumber: 2,
+		SheetCount:  3,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error rendering: %s", err)
+	}
+	if got, want := frame.Texts[0].Text, "Id: 2/3"; got != want {
+		t.Errorf("wrong text %q; want %q", got, want)
+	}
+}
+
+func TestDrawingSheetRender(t *testing.T) {
+	ds := DefaultDrawingSheet()
+	page := &DrawingSheetPage{
+		Paper: PaperSize{Name: "A4"},
+		TitleBlock: TitleBlock{
+			Title:    "Amplifier ${VARIANT}",
+			Revision: "C",
+			Comments: []TitleBlockComment{{Number: 2, Text: "Second"}},
+		},
+		SheetNumber: 1,
+		SheetCount:  2,
+		SheetPath:   "/",
+		Filename:    "amp.kicad_sch",
+		Vars:        map[string]string{"VARIANT": "Mono"},
+	}
+	frame, err := ds.Render(page)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if got, want := frame.Size, (Size{Width: 297, Height: 210}); got != want {
+		t.Errorf("wrong size %#v; want %#v", got, want)
+	}
+
+	texts := make(map[string]FrameText)
+	var topLabels []string
+	for _, text := range frame.Texts {
+		texts[text.Text] = text
+		if text.Pos.Y == 11 {
+			topLabels = append(topLabels, text.Text)
+		}
+	}
+	for _, s := range []string{"Title: Amplifier Mono", "Rev: C", "Id: 1/2", "Size: A4", "File: amp.kicad_sch", "Sheet: /", "Second", ""} {
+		if _, ok := texts[s]; !ok {
+			t.Errorf("missing text %q", s)
+		}
+	}
+
+	// The title is relative to the bottom right corner, inside the margins
+	title := texts["Title: Amplifier Mono"]
+	if got, want := title.Pos, (Position{X: 297 - 10 - 109, Y: 210 - 10 - 10.7}); got != want {
+		t.Errorf("wrong title position %#v; want %#v", got, want)
+	}
+	if got, want := title.Size, (Size{Width: 2, Height: 2}); got != want {
+		t.Errorf("wrong title size %#v; want %#v", got, want)
+	}
+	if !title.Bold || !title.Italic || !title.Justify.Left {
+		t.Errorf("wrong title style %#v", title)
+	}
+
+	// The border labels along the top repeat only as far as fits within
+	// the margins.
+	if got, want := topLabels, []string{"1", "2", "3", "4", "5", "6"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong top border labels %q; want %q", got, want)
+	}
+	if got := texts["1"].Justify; got != (TextJustify{}) {
+		t.Errorf("border label is not centered: %#v", got)
+	}
+
+	// The repeated rectangle makes a double border.
+	wantRects := []FrameRect{
+		{Start: Position{X: 177, Y: 166}, End: Position{X: 285, Y: 198}, Width: 0.15},
+		{Start: Position{X: 10, Y: 10}, End: Position{X: 287, Y: 200}, Width: 0.15},
+		{Start: Position{X: 12, Y: 12}, End: Position{X: 285, Y: 198}, Width: 0.15},
+	}
+	if !reflect.DeepEqual(frame.Rects, wantRects) {
+		t.Errorf("wrong rects\ngot:  %swant: %s", spew.Sdump(frame.Rects), spew.Sdump(wantRects))
+	}
+}
+
+func TestDrawingSheetRender_options(t *testing.T) {
+	ds := &DrawingSheet{
+		Texts: []DrawingSheetText{
+			{Text: "first", Option: DrawingSheetPage1Only},
+			{Text: "others", Option: DrawingSheetNotOnPage1},
+			{Text: "all"},
+		},
+	}
+	for number, want := range map[int][]string{1: {"first", "all"}, 2: {"others", "all"}} {
+		frame, err := ds.Render(&DrawingSheetPage{Paper: PaperSize{Name: "User", Width: 100, Height: 50}, SheetNumber: number})
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		var got []string
+		for _, text := range frame.Texts {
+			got = append(got, text.Text)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("wrong texts on page %d %q; want %q", number, got, want)
+		}
+	}
+
+	_, err := ds.Render(&DrawingSheetPage{Paper: PaperSize{Name: "Napkin"}})
+	if err == nil {
+		t.Errorf("no error for unknown paper size")
+	}
+}
+
+func TestPaperSizeDimensions(t *testing.T) {
+	tests := []struct {
+		paper PaperSize
+		want  Size
+		ok    bool
+	}{
+		{PaperSize{Name: "A4"}, Size{Width: 297, Height: 210}, true},
+		{PaperSize{Name: "A4", Portrait: true}, Size{Width: 210, Height: 297}, true},
+		{PaperSize{Name: "USLetter"}, Size{Width: 279.4, Height: 215.9}, true},
+		{PaperSize{Name: "User", Width: 100, Height: 200, Portrait: true}, Size{Width: 100, Height: 200}, true},
+		{PaperSize{Name: "Napkin"}, Size{}, false},
+	}
+	for _, test := range tests {
+		got, ok := test.paper.Dimensions()
+		if got != test.want || ok != test.ok {
+			t.Errorf("wrong dimensions for %#v: %#v, %t; want %#v, %t", test.paper, got, ok, test.want, test.ok)
+		}
+	}
+}
+
+func TestExpandTextVars(t *testing.T) {
+	vars := map[string]string{
+		"A":    "alpha",
+		"B":    "${A}-beta",
+		"LOOP": "${LOOP}",
+	}
+	resolve := func(name string) (string, bool) {
+		v, ok := vars[name]
+		return v, ok
+	}
+	tests := map[string]string{
+		"":                    "",
+		"plain":               "plain",
+		"${A}":                "alpha",
+		"x ${B} y":            "x alpha-beta y",
+		"${UNKNOWN} ${A}":     "${UNKNOWN} alpha",
+		"${A":                 "${A",
+		"$A {A}":              "$A {A}",
+		"${LOOP}":             "${LOOP}",
+		"${A}${A}":            "alphaalpha",
+		"100% ${A}":           "100% alpha",
+		"no closing ${ brace": "no closing ${ brace",
+	}
+	for input, want := range tests {
+		if got := ExpandTextVars(input, resolve); got != want {
+			t.Errorf("wrong result for %q: %q; want %q", input, got, want)
+		}
+	}
+}
+
+func TestIncrementLabel(t *testing.T) {
+	tests := []struct {
+		label string
+		n     int
+		want  string
+	}{
+		{"A", 0, "A"},
+		{"A", 2, "C"},
+		{"1", 1, "2"},
+		{"9", 2, "11"},
+		{"X1", 3, "X4"},
+		{"", 1, ""},
+	}
+	for _, test := range tests {
+		if got := incrementLabel(test.label, test.n); got != test.want {
+			t.Errorf("wrong result for %q + %d: %q; want %q", test.label, test.n, got, test.want)
+		}
+	}
+}
+
+func TestConvertLegacyTextVars(t *testing.T) {
+	tests := map[string]string{
+		"Id: %S/%N":   "Id: ${#}/${##}",
+		"%C0 %C8":     "${COMMENT1} ${COMMENT9}",
+		"100%% %T":    "100% ${TITLE}",
+		"50% off":     "50% off",
+		"trailing %":  "trailing %",
+		"no vars":     "no vars",
+		"%Z and %Y%R": "${PAPER} and ${COMPANY}${REVISION}",
+	}
+	for input, want := range tests {
+		if got := convertLegacyTextVars(input); got != want {
+			t.Errorf("wrong result for %q: %q; want %q", input, got, want)
+		}
+	}
+}
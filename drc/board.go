@@ -0,0 +1,716 @@
+package drc
+
+import (
+	"math"
+	"strings"
+
+	"github.com/apparentlymart/go-kicad"
+)
+
+// Board is a board prepared for evaluating rule conditions, which lists its
+// items and resolves the net classes of its nets.
+//
+// Net classes are taken from the project if given, which is where KiCad 6
+// and later keep them, and otherwise from the net classes of a KiCad 5
+// board. Nets not assigned to any class are in the "Default" class.
+type Board struct {
+	PCB     *kicad.PCB
+	Project *kicad.Project
+
+	items    []*Item
+	copper   []string
+	groupsOf map[string]*kicad.Group
+}
+
+// NewBoard returns a Board for the given board and project. The project may
+// be nil.
+func NewBoard(pcb *kicad.PCB, project *kicad.Project) *Board {
+	b := &Board{
+		PCB:      pcb,
+		Project:  project,
+		copper:   pcb.CopperLayers(),
+		groupsOf: make(map[string]*kicad.Group),
+	}
+	for i := range pcb.Groups {
+		g := &pcb.Groups[i]
+		for _, member := range g.Members {
+			b.groupsOf[member] = g
+		}
+	}
+	b.items = b.boardItems()
+	return b
+}
+
+// Items returns all of the items on the board, including the footprints
+// and the pads, zones, graphics and text within them.
+func (b *Board) Items() []*Item {
+	return b.items
+}
+
+// NetClass returns the name of the net class of the net with the given
+// name.
+func (b *Board) NetClass(net string) string {
+	if b.Project != nil {
+		settings := &b.Project.NetSettings
+		for _, pattern := range settings.NetClassPatterns {
+			if wildcardMatch(pattern.Pattern, net) {
+				return pattern.NetClass
+			}
+		}
+		for _, class := range settings.Classes {
+			for _, name := range class.Nets {
+				if name == net {
+					return class.Name
+				}
+			}
+		}
+	}
+	for _, class := range b.PCB.NetClasses {
+		for _, name := range class.Nets {
+			if name == net {
+				return class.Name
+			}
+		}
+	}
+	return "Default"
+}
+
+// Item is an item on a board that rule conditions can refer to.
+//
+// Type is the item's type as rule conditions name it, such as "Pad",
+// "Track" or "Footprint". Footprint is the footprint itself for footprint
+// items, or the footprint that contains the item otherwise, if any. The
+// field for the item's own type is also set, for those types that have
+// one.
+//
+// Layers are the names of the layers that the item is on, which for pads
+// may be wildcards like "*.Cu". Net is the number of the item's net, or
+// zero if it has none.
+type Item struct {
+	Type   string
+	UUID   string
+	Layers []string
+	Net    int
+
+	Footprint *kicad.Footprint
+	Pad       *kicad.Pad
+	Via       *kicad.Via
+	Segment   *kicad.Segment
+	Arc       *kicad.Arc
+	Zone      *kicad.Zone
+
+	locked bool
+	text   string
+	angle  float64
+
+	// points are sample points of the item's shape in board coordinates,
+	// used to approximate its area for functions like insideArea.
+	points []kicad.Position
+}
+
+// Property returns the value of the given property of the item, as a rule
+// condition like "A.NetClass == 'HV'" would see it, and false if the item
+// has no such property. Property names are matched without regard to case,
+// with underscores standing for spaces as in "Pad_Type".
+func (it *Item) Property(board *Board, name string) (string, bool) {
+	v := it.property(board, name)
+	return v.String(), v.kind != nullValue
+}
+
+func (it *Item) property(board *Board, name string) value {
+	name = strings.ReplaceAll(strings.ToLower(name), "_", " ")
+	switch name {
+	case "type":
+		return stringVal(it.Type)
+	case "layer":
+		if len(it.Layers) > 0 {
+			return stringVal(it.Layers[0])
+		}
+	case "locked":
+		return boolVal(it.locked)
+	case "uuid":
+		return stringVal(it.UUID)
+	case "orientation":
+		return numberVal(it.angle)
+	case "net", "netname", "net name":
+		if it.connectable() {
+			return stringVal(board.PCB.NetName(it.Net))
+		}
+	case "net code":
+		if it.connectable() {
+			return numberVal(float64(it.Net))
+		}
+	case "netclass", "net class":
+		if it.connectable() {
+			return stringVal(board.NetClass(board.PCB.NetName(it.Net)))
+		}
+	case "reference":
+		if it.Footprint != nil {
+			return stringVal(it.Footprint.Reference())
+		}
+	case "value":
+		if it.Footprint != nil {
+			return stringVal(it.Footprint.Value())
+		}
+	case "library link", "library id":
+		if it.Footprint != nil {
+			return stringVal(it.Footprint.LibID)
+		}
+	case "text":
+		if it.Type == "Text" || it.Type == "Text Box" {
+			return stringVal(it.text)
+		}
+	}
+
+	switch {
+	case it.Segment != nil:
+		switch name {
+		case "width", "track width":
+			return numberVal(it.Segment.Width)
+		case "length", "track length":
+			return numberVal(it.Segment.Length())
+		}
+	case it.Arc != nil:
+		switch name {
+		case "width", "track width":
+			return numberVal(it.Arc.Width)
+		case "length", "track length":
+			return numberVal(it.Arc.Length())
+		}
+	case it.Via != nil:
+		switch name {
+		case "via type":
+			return stringVal(viaTypeNames[it.Via.Type])
+		case "diameter", "via diameter", "width":
+			return numberVal(it.Via.Size)
+		case "hole size", "drill", "hole":
+			return numberVal(it.Via.Drill)
+		}
+	case it.Pad != nil:
+		switch name {
+		case "pad type":
+			return stringVal(padTypeNames[it.Pad.Type])
+		case "pad shape", "shape":
+			return stringVal(padShapeName(it.Pad))
+		case "pad number", "number":
+			return stringVal(it.Pad.Number)
+		case "pin name":
+			return stringVal(it.Pad.PinFunction)
+		case "pin type":
+			return stringVal(it.Pad.PinType)
+		case "size x":
+			return numberVal(it.Pad.Size.Width)
+		case "size y":
+			return numberVal(it.Pad.Size.Height)
+		case "hole size", "hole size x", "drill":
+			if it.Pad.HasDrill() {
+				return numberVal(it.Pad.Drill.Width)
+			}
+		case "hole size y":
+			if it.Pad.HasDrill() {
+				if it.Pad.Drill.Height > 0 {
+					return numberVal(it.Pad.Drill.Height)
+				}
+				return numberVal(it.Pad.Drill.Width)
+			}
+		}
+	case it.Zone != nil:
+		switch name {
+		case "name":
+			return stringVal(it.Zone.Name)
+		case "priority":
+			return numberVal(float64(it.Zone.Priority))
+		}
+	}
+	return value{}
+}
+
+// connectable returns true if the item can be connected to a net.
+func (it *Item) connectable() bool {
+	return it.Pad != nil || it.Via != nil || it.Segment != nil || it.Arc != nil || (it.Zone != nil && !it.Zone.IsRuleArea())
+}
+
+// DisallowType returns the name that a "disallow" constraint uses for the
+// item's type, such as "track" or "micro_via", or the empty string if
+// such constraints don't apply to it.
+func (it *Item) DisallowType() string {
+	switch {
+	case it.Segment != nil, it.Arc != nil:
+		return "track"
+	case it.Via != nil:
+		switch it.Via.Type {
+		case "micro":
+			return "micro_via"
+		case "blind":
+			return "buried_via"
+		default:
+			return "through_via"
+		}
+	case it.Pad != nil:
+		return "pad"
+	case it.Zone != nil:
+		if it.Zone.IsRuleArea() {
+			return ""
+		}
+		return "zone"
+	case it.Type == "Footprint":
+		return "footprint"
+	case it.Type == "Text", it.Type == "Text Box":
+		return "text"
+	case it.Type == "Graphic":
+		return "graphic"
+	default:
+		return ""
+	}
+}
+
+var viaTypeNames = map[string]string{
+	"":      "Through",
+	"blind": "Blind/buried",
+	"micro": "Micro",
+}
+
+var padTypeNames = map[string]string{
+	"thru_hole":    "Through-hole",
+	"smd":          "SMD",
+	"connect":      "Edge connector",
+	"np_thru_hole": "NPTH, mechanical",
+}
+
+func padShapeName(pad *kicad.Pad) string {
+	switch pad.Shape {
+	case "circle":
+		return "Circle"
+	case "rect":
+		return "Rectangle"
+	case "oval":
+		return "Oval"
+	case "trapezoid":
+		return "Trapezoid"
+	case "roundrect":
+		if pad.Chamfer != (kicad.PadChamfer{}) {
+			return "Chamfered rectangle"
+		}
+		return "Rounded rectangle"
+	case "custom":
+		return "Custom"
+	default:
+		return pad.Shape
+	}
+}
+
+func (b *Board) boardItems() []*Item {
+	pcb := b.PCB
+	var items []*Item
+
+	for i := range pcb.Footprints {
+		items = append(items, b.footprintItems(&pcb.Footprints[i])...)
+	}
+	for i := range pcb.Segments {
+		s := &pcb.Segments[i]
+		items = append(items, &Item{
+			Type:    "Track",
+			UUID:    itemUUID(s.UUID, s.TStamp),
+			Layers:  []string{s.Layer},
+			Net:     s.Net,
+			Segment: s,
+			locked:  s.Locked,
+			points:  []kicad.Position{s.Start, midpoint(s.Start, s.End), s.End},
+		})
+	}
+	for i := range pcb.Arcs {
+		a := &pcb.Arcs[i]
+		items = append(items, &Item{
+			Type:   "Arc",
+			UUID:   itemUUID(a.UUID, a.TStamp),
+			Layers: []string{a.Layer},
+			Net:    a.Net,
+			Arc:    a,
+			locked: a.Locked,
+			points: []kicad.Position{a.Start, a.Mid, a.End},
+		})
+	}
+	for i := range pcb.Vias {
+		v := &pcb.Vias[i]
+		items = append(items, &Item{
+			Type:   "Via",
+			UUID:   itemUUID(v.UUID, v.TStamp),
+			Layers: b.viaLayers(v.Layers),
+			Net:    v.Net,
+			Via:    v,
+			locked: v.Locked,
+			points: append(kicad.CirclePoints(v.At, v.Size/2), v.At),
+		})
+	}
+	for i := range pcb.Zones {
+		items = append(items, zoneItem(&pcb.Zones[i], nil))
+	}
+
+	for i := range pcb.GraphicLines {
+		g := &pcb.GraphicLines[i]
+		items = append(items, graphicItem(g.Layer, g.UUID, g.TStamp, g.Locked, nil, g.Start, g.End))
+	}
+	for i := range pcb.GraphicArcs {
+		g := &pcb.GraphicArcs[i]
+		items = append(items, graphicItem(g.Layer, g.UUID, g.TStamp, g.Locked, nil, g.Start, g.Mid, g.End))
+	}
+	for i := range pcb.GraphicCircles {
+		g := &pcb.GraphicCircles[i]
+		items = append(items, graphicItem(g.Layer, g.UUID, g.TStamp, g.Locked, nil, append(kicad.CirclePoints(g.Center, kicad.Distance(g.Center, g.End)), g.Center)...))
+	}
+	for i := range pcb.GraphicRects {
+		g := &pcb.GraphicRects[i]
+		items = append(items, graphicItem(g.Layer, g.UUID, g.TStamp, g.Locked, nil, kicad.RectPoints(g.Start, g.End)...))
+	}
+	for i := range pcb.GraphicPolys {
+		g := &pcb.GraphicPolys[i]
+		items = append(items, graphicItem(g.Layer, g.UUID, g.TStamp, g.Locked, nil, g.Points.Polyline()...))
+	}
+	for i := range pcb.GraphicCurves {
+		g := &pcb.GraphicCurves[i]
+		items = append(items, graphicItem(g.Layer, g.UUID, g.TStamp, g.Locked, nil, g.Points.Polyline()...))
+	}
+	for i := range pcb.Texts {
+		t := &pcb.Texts[i]
+		items = append(items, &Item{
+			Type:   "Text",
+			UUID:   itemUUID(t.UUID, t.TStamp),
+			Layers: []string{t.Layer.Name},
+			locked: t.Locked,
+			text:   t.Text,
+			angle:  t.At.Angle,
+			points: []kicad.Position{t.At.Position()},
+		})
+	}
+	for i := range pcb.TextBoxes {
+		t := &pcb.TextBoxes[i]
+		pts := t.Points.Polyline()
+		if len(pts) == 0 {
+			pts = kicad.RectPoints(t.Start, t.End)
+		}
+		items = append(items, &Item{
+			Type:   "Text Box",
+			UUID:   itemUUID(t.UUID, t.TStamp),
+			Layers: []string{t.Layer.Name},
+			locked: t.Locked,
+			text:   t.Text,
+			angle:  t.Angle,
+			points: pts,
+		})
+	}
+
+	return items
+}
+
+// footprintItems returns the item for the given footprint followed by the
+// items within it.
+func (b *Board) footprintItems(fp *kicad.Footprint) []*Item {
+	fpItem := &Item{
+		Type:      "Footprint",
+		UUID:      itemUUID(fp.UUID, fp.TStamp),
+		Layers:    []string{fp.Layer},
+		Footprint: fp,
+		locked:    fp.Locked,
+		angle:     fp.At.Angle,
+		points:    []kicad.Position{fp.At.Position()},
+	}
+	items := []*Item{fpItem}
+
+	for i := range fp.Pads {
+		pad := &fp.Pads[i]
+		center := fp.BoardPosition(pad.At.Position())
+		w, h := pad.Size.Width/2, pad.Size.Height/2
+		points := []kicad.Position{center}
+		for _, corner := range []kicad.Position{{X: -w, Y: -h}, {X: w, Y: -h}, {X: w, Y: h}, {X: -w, Y: h}} {
+			p := kicad.RotatePoint(corner, pad.At.Angle)
+			points = append(points, kicad.Position{X: center.X + p.X, Y: center.Y + p.Y})
+		}
+		fpItem.points = append(fpItem.points, points...)
+
+		items = append(items, &Item{
+			Type:      "Pad",
+			UUID:      itemUUID(pad.UUID, pad.TStamp),
+			Layers:    pad.Layers,
+			Net:       pad.Net.Number,
+			Footprint: fp,
+			Pad:       pad,
+			locked:    pad.Locked || fp.Locked,
+			angle:     pad.At.Angle,
+			points:    points,
+		})
+	}
+	for i := range fp.Zones {
+		items = append(items, zoneItem(&fp.Zones[i], fp))
+	}
+
+	for i := range fp.Lines {
+		g := &fp.Lines[i]
+		items = append(items, graphicItem(g.Layer, g.UUID, g.TStamp, g.Locked, fp, g.Start, g.End))
+	}
+	for i := range fp.Arcs {
+		g := &fp.Arcs[i]
+		items = append(items, graphicItem(g.Layer, g.UUID, g.TStamp, g.Locked, fp, g.Start, g.Mid, g.End))
+	}
+	for i := range fp.Circles {
+		g := &fp.Circles[i]
+		items = append(items, graphicItem(g.Layer, g.UUID, g.TStamp, g.Locked, fp, append(kicad.CirclePoints(g.Center, kicad.Distance(g.Center, g.End)), g.Center)...))
+	}
+	for i := range fp.Rects {
+		g := &fp.Rects[i]
+		items = append(items, graphicItem(g.Layer, g.UUID, g.TStamp, g.Locked, fp, kicad.RectPoints(g.Start, g.End)...))
+	}
+	for i := range fp.Polys {
+		g := &fp.Polys[i]
+		items = append(items, graphicItem(g.Layer, g.UUID, g.TStamp, g.Locked, fp, g.Points.Polyline()...))
+	}
+	for i := range fp.Texts {
+		t := &fp.Texts[i]
+		items = append(items, &Item{
+			Type:      "Text",
+			UUID:      itemUUID(t.UUID, t.TStamp),
+			Layers:    []string{t.Layer.Name},
+			Footprint: fp,
+			text:      t.Text,
+			angle:     t.At.Angle,
+			points:    []kicad.Position{fp.BoardPosition(t.At.Position())},
+		})
+	}
+
+	return items
+}
+
+func zoneItem(z *kicad.Zone, fp *kicad.Footprint) *Item {
+	item := &Item{
+		Type:      "Zone",
+		UUID:      itemUUID(z.UUID, z.TStamp),
+		Layers:    z.LayerNames(),
+		Net:       z.Net,
+		Footprint: fp,
+		Zone:      z,
+		locked:    z.Locked,
+	}
+	if z.IsRuleArea() {
+		item.Type = "Rule Area"
+		item.Net = 0
+	}
+	// Zone outlines are already in board coordinates, even within
+	// footprints.
+	for _, poly := range z.Outline {
+		item.points = append(item.points, poly.Points.Polyline()...)
+	}
+	return item
+}
+
+// graphicItem returns an item for a graphic shape with the given points.
+// fp is the footprint that contains the shape, if any, in which case the
+// points are relative to it.
+func graphicItem(layer, uuid, tstamp string, locked bool, fp *kicad.Footprint, points ...kicad.Position) *Item {
+	item := &Item{
+		Type:      "Graphic",
+		UUID:      itemUUID(uuid, tstamp),
+		Layers:    []string{layer},
+		Footprint: fp,
+		locked:    locked,
+	}
+	for _, p := range points {
+		if fp != nil {
+			p = fp.BoardPosition(p)
+		}
+		item.points = append(item.points, p)
+	}
+	return item
+}
+
+func itemUUID(uuid, tstamp string) string {
+	if uuid != "" {
+		return uuid
+	}
+	return tstamp
+}
+
+// viaLayers returns all of the copper layers between the given pair of
+// layers, which is the set of layers that a via is on.
+func (b *Board) viaLayers(pair []string) []string {
+	if len(pair) != 2 {
+		return pair
+	}
+	from, to := -1, -1
+	for i, name := range b.copper {
+		if name == pair[0] {
+			from = i
+		}
+		if name == pair[1] {
+			to = i
+		}
+	}
+	if from < 0 || to < 0 {
+		return pair
+	}
+	if from > to {
+		from, to = to, from
+	}
+	return b.copper[from : to+1]
+}
+
+// layerMatch returns true if the given layer name matches the given
+// pattern, as kicad.LayerMatch does, ignoring case. The pattern "outer"
+// matches the outer copper layers and "inner" matches the inner ones, as
+// in the layer clause of a rule.
+func layerMatch(pattern, layer string) bool {
+	switch pattern {
+	case "outer":
+		return layer == "F.Cu" || layer == "B.Cu" || layer == "*.Cu" || layer == "F&B.Cu"
+	case "inner":
+		return strings.HasPrefix(layer, "In") && strings.HasSuffix(layer, ".Cu") || layer == "*.Cu"
+	}
+	return kicad.LayerMatch(pattern, layer) || strings.EqualFold(pattern, layer)
+}
+
+// layersOverlap returns true if any of the first layers match any of the
+// second, in either direction. Items without layers overlap everything.
+func layersOverlap(a, b []string) bool {
+	if len(a) == 0 || len(b) == 0 {
+		return true
+	}
+	for _, x := range a {
+		for _, y := range b {
+			if layerMatch(x, y) || layerMatch(y, x) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// intersectsArea returns true if the given item intersects a zone or rule
+// area with the given name, or if enclosed is set, if it lies entirely
+// within such an area. The item's shape is approximated by sample points.
+func (b *Board) intersectsArea(item *Item, name string, enclosed bool) bool {
+	if len(item.points) == 0 {
+		return false
+	}
+	for _, area := range b.items {
+		if area.Zone == nil || area == item || !wildcardMatch(name, area.Zone.Name) {
+			continue
+		}
+		if !layersOverlap(item.Layers, area.Layers) {
+			continue
+		}
+
+		inside := 0
+		for _, p := range item.points {
+			for _, poly := range area.Zone.Outline {
+				if pointInPolygon(p, poly.Points.Polyline()) {
+					inside++
+					break
+				}
+			}
+		}
+		if enclosed && inside == len(item.points) || !enclosed && inside > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// intersectsCourtyard returns true if the given item intersects the
+// courtyard of a footprint with the given reference, on layers matching
+// the given pattern. The courtyard is approximated by the bounding box of
+// its graphics.
+func (b *Board) intersectsCourtyard(item *Item, ref, layerPattern string) bool {
+	for _, other := range b.items {
+		if other.Type != "Footprint" || !wildcardMatch(ref, other.Footprint.Reference()) {
+			continue
+		}
+		var courtyard []kicad.Position
+		for _, g := range b.items {
+			if g.Type == "Graphic" && g.Footprint == other.Footprint && len(g.Layers) > 0 && layerMatch(layerPattern, g.Layers[0]) {
+				courtyard = append(courtyard, g.points...)
+			}
+		}
+		if len(courtyard) == 0 {
+			continue
+		}
+		min, max := bounds(courtyard)
+		for _, p := range item.points {
+			if p.X >= min.X && p.X <= max.X && p.Y >= min.Y && p.Y <= max.Y {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// memberOfGroup returns true if the given item, or the footprint that
+// contains it, is in a group with the given name or in a group nested
+// within such a group.
+func (b *Board) memberOfGroup(item *Item, name string) bool {
+	ids := []string{item.UUID}
+	if item.Footprint != nil {
+		ids = append(ids, itemUUID(item.Footprint.UUID, item.Footprint.TStamp))
+	}
+	for _, id := range ids {
+		seen := make(map[*kicad.Group]bool)
+		for g := b.groupsOf[id]; g != nil && !seen[g]; g = b.groupsOf[itemUUID(g.UUID, g.ID)] {
+			if wildcardMatch(name, g.Name) {
+				return true
+			}
+			seen[g] = true
+		}
+	}
+	return false
+}
+
+// diffPairSuffixes are the pairs of net name suffixes that KiCad recognizes
+// as the positive and negative nets of a differential pair.
+var diffPairSuffixes = [][2]string{
+	{"_P", "_N"},
+	{"+", "-"},
+	{"P", "N"},
+}
+
+// diffPair returns the base name of the differential pair that the given
+// net belongs to and the name of the other net in the pair, or false if
+// the net isn't part of a pair. Both nets of the pair must exist.
+func (b *Board) diffPair(net string) (base, complement string, ok bool) {
+	for _, suffixes := range diffPairSuffixes {
+		for i, suffix := range suffixes {
+			if !strings.HasSuffix(net, suffix) {
+				continue
+			}
+			base = strings.TrimSuffix(net, suffix)
+			complement = base + suffixes[1-i]
+			if b.PCB.NetNumber(complement) >= 0 {
+				return base, complement, true
+			}
+			return "", "", false
+		}
+	}
+	return "", "", false
+}
+
+func midpoint(a, b kicad.Position) kicad.Position {
+	return kicad.Position{X: (a.X + b.X) / 2, Y: (a.Y + b.Y) / 2}
+}
+
+func bounds(points []kicad.Position) (min, max kicad.Position) {
+	min, max = points[0], points[0]
+	for _, p := range points[1:] {
+		min.X, min.Y = math.Min(min.X, p.X), math.Min(min.Y, p.Y)
+		max.X, max.Y = math.Max(max.X, p.X), math.Max(max.Y, p.Y)
+	}
+	return min, max
+}
+
+// pointInPolygon returns true if the given point is inside the polygon
+// with the given vertices, using the even-odd rule.
+func pointInPolygon(p kicad.Position, poly []kicad.Position) bool {
+	inside := false
+	for i, j := 0, len(poly)-1; i < len(poly); j, i = i, i+1 {
+		a, b := poly[i], poly[j]
+		if (a.Y > p.Y) != (b.Y > p.Y) && p.X < (b.X-a.X)*(p.Y-a.Y)/(b.Y-a.Y)+a.X {
+			inside = !inside
+		}
+	}
+	return inside
+}
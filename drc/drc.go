@@ -0,0 +1,143 @@
+// Package drc evaluates KiCad's custom design rules against a board, so
+// that other checks can reuse the rules that a board's designers have
+// already written.
+//
+// The rules themselves are read with kicad.ReadDesignRules. A Checker then
+// finds which of them apply to particular items of a Board, by evaluating
+// the condition expression of each rule. The geometric functions of the
+// expression language, like insideArea, approximate the shape of each item
+// by a few points on it, and so may differ from KiCad's own results for
+// items that only partially overlap an area.
+package drc
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/apparentlymart/go-kicad"
+)
+
+// Checker finds the custom design rules that apply to items on a board.
+type Checker struct {
+	Board *Board
+
+	rules []checkerRule
+}
+
+type checkerRule struct {
+	rule *kicad.DesignRule
+	cond *Expr
+}
+
+// NewChecker returns a Checker for the given board and rules. It returns an
+// error if the condition of any rule is invalid.
+func NewChecker(board *Board, rules *kicad.DesignRules) (*Checker, error) {
+	c := &Checker{Board: board}
+	for i := range rules.Rules {
+		rule := &rules.Rules[i]
+		cr := checkerRule{rule: rule}
+		if rule.Condition != "" {
+			cond, err := ParseExpr(rule.Condition)
+			if err != nil {
+				return nil, fmt.Errorf("invalid condition for rule %q: %w", rule.Name, err)
+			}
+			cr.cond = cond
+		}
+		c.rules = append(c.rules, cr)
+	}
+	return c, nil
+}
+
+// Rules returns the rules that apply to the given items on the given layer,
+// with the rule that takes precedence first, which is the one that appears
+// latest in the rules file.
+//
+// b may be nil for rules about a single item. Otherwise a rule applies if
+// its condition is true either way around, as KiCad checks constraints like
+// "clearance" that are between two items. If layer is empty then rules
+// with a layer clause apply if any of the first item's layers match it.
+func (c *Checker) Rules(a, b *Item, layer string) ([]*kicad.DesignRule, error) {
+	var ret []*kicad.DesignRule
+	for i := len(c.rules) - 1; i >= 0; i-- {
+		cr := c.rules[i]
+		if !ruleOnLayer(cr.rule, a, layer) {
+			continue
+		}
+		if cr.cond != nil {
+			match, err := cr.cond.Match(c.Board, a, b)
+			if err != nil {
+				return nil, fmt.Errorf("rule %q: %w", cr.rule.Name, err)
+			}
+			if !match && b != nil {
+				match, err = cr.cond.Match(c.Board, b, a)
+				if err != nil {
+					return nil, fmt.Errorf("rule %q: %w", cr.rule.Name, err)
+				}
+			}
+			if !match {
+				continue
+			}
+		}
+		ret = append(ret, cr.rule)
+	}
+	return ret, nil
+}
+
+// Constraint returns the constraint of the given type, such as
+// "clearance", that applies to the given items on the given layer, along
+// with the rule that it belongs to. It returns nil if no rule gives such a
+// constraint for the items.
+func (c *Checker) Constraint(typ string, a, b *Item, layer string) (*kicad.DesignRule, *kicad.RuleConstraint, error) {
+	rules, err := c.Rules(a, b, layer)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, rule := range rules {
+		if constraint := rule.Constraint(typ); constraint != nil {
+			return rule, constraint, nil
+		}
+	}
+	return nil, nil, nil
+}
+
+// Disallowed returns the rule whose "disallow" constraint forbids the given
+// item, or nil if no rule does.
+func (c *Checker) Disallowed(item *Item) (*kicad.DesignRule, error) {
+	typ := item.DisallowType()
+	if typ == "" {
+		return nil, nil
+	}
+
+	rules, err := c.Rules(item, nil, "")
+	if err != nil {
+		return nil, err
+	}
+	for _, rule := range rules {
+		for _, constraint := range rule.Constraints {
+			if constraint.Type != "disallow" {
+				continue
+			}
+			for _, arg := range constraint.Args {
+				if arg == typ || arg == "via" && strings.HasSuffix(typ, "_via") {
+					return rule, nil
+				}
+			}
+		}
+	}
+	return nil, nil
+}
+
+func ruleOnLayer(rule *kicad.DesignRule, item *Item, layer string) bool {
+	if rule.Layer == "" {
+		return true
+	}
+	if layer != "" {
+		return layerMatch(rule.Layer, layer)
+	}
+	for _, itemLayer := range item.Layers {
+		if layerMatch(rule.Layer, itemLayer) || layerMatch(itemLayer, rule.Layer) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,264 @@
+package drc
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/apparentlymart/go-kicad"
+)
+
+const testBoardSrc = `(kicad_pcb (version 20221018) (generator pcbnew)
+  (layers
+    (0 "F.Cu" signal)
+    (1 "In1.Cu" signal)
+    (2 "In2.Cu" signal)
+    (31 "B.Cu" signal)
+    (37 "F.SilkS" user "F.Silkscreen")
+  )
+  (net 0 "")
+  (net 1 "/HV")
+  (net 2 "GND")
+  (net 3 "/CLK_P")
+  (net 4 "/CLK_N")
+  (footprint "Connector:Conn_01x02" (layer "F.Cu")
+    (at 10 10 90)
+    (property "Reference" "J1")
+    (property "MPN" "ABC-123")
+    (sheetname "/Power/")
+    (uuid "fp-j1")
+    (fp_line (start -2 -1) (end 2 -1) (layer "F.CrtYd") (stroke (width 0.05) (type solid)))
+    (fp_line (start -2 1) (end 2 1) (layer "F.CrtYd") (stroke (width 0.05) (type solid)))
+    (pad "1" thru_hole rect (at 0 0 90) (size 1.7 1.7) (drill 1) (layers "*.Cu" "*.Mask") (net 1 "/HV") (uuid "pad-1"))
+    (pad "2" thru_hole oval (at 2.54 0 90) (size 1.7 1.7) (drill 1) (layers "*.Cu" "*.Mask") (net 2 "GND") (uuid "pad-2"))
+    (pad "" np_thru_hole circle (at -3 0 90) (size 2 2) (drill 2) (layers "*.Cu" "*.Mask") (uuid "pad-mh"))
+  )
+  (segment (start 10 10) (end 20 10) (width 0.5) (layer "F.Cu") (net 1) (uuid "seg-hv"))
+  (segment (start 30 30) (end 40 30) (width 0.2) (layer "B.Cu") (net 3) (uuid "seg-clk-p"))
+  (segment (start 30 31) (end 40 31) (width 0.2) (layer "B.Cu") (net 4) (uuid "seg-clk-n"))
+  (via (at 15 15) (size 0.8) (drill 0.4) (layers "F.Cu" "B.Cu") (net 2) (uuid "via-gnd"))
+  (via micro (at 35 35) (size 0.3) (drill 0.1) (layers "F.Cu" "In1.Cu") (net 2) (uuid "via-micro"))
+  (zone (net 0) (net_name "") (layers "F.Cu" "B.Cu") (uuid "ra-1") (name "Keepout")
+    (hatch edge 0.5)
+    (connect_pads (clearance 0))
+    (min_thickness 0.25)
+    (keepout (tracks not_allowed) (vias not_allowed) (pads allowed) (copperpour allowed) (footprints allowed))
+    (fill (thermal_gap 0.5) (thermal_bridge_width 0.5))
+    (polygon (pts (xy 12 5) (xy 25 5) (xy 25 20) (xy 12 20)))
+  )
+  (group "Power stage" (id "grp-power") (members "fp-j1" "seg-hv"))
+  (group "Outer" (id "grp-outer") (members "grp-power"))
+)
+`
+
+func testBoard(t *testing.T) *Board {
+	t.Helper()
+	pcb, err := kicad.ReadPCB(strings.NewReader(testBoardSrc))
+	if err != nil {
+		t.Fatalf("reading board: %s", err)
+	}
+	project := &kicad.Project{
+		NetSettings: kicad.ProjectNetSettings{
+			Classes: []kicad.ProjectNetClass{{Name: "Default"}, {Name: "HV"}},
+			NetClassPatterns: []kicad.ProjectNetClassPattern{
+				{NetClass: "HV", Pattern: "/HV*"},
+			},
+		},
+	}
+	return NewBoard(pcb, project)
+}
+
+func findItem(t *testing.T, board *Board, uuid string) *Item {
+	t.Helper()
+	for _, item := range board.Items() {
+		if item.UUID == uuid {
+			return item
+		}
+	}
+	t.Fatalf("no item %q", uuid)
+	return nil
+}
+
+func TestBoardItems(t *testing.T) {
+	board := testBoard(t)
+
+	counts := make(map[string]int)
+	for _, item := range board.Items() {
+		counts[item.Type]++
+	}
+	want := map[string]int{
+		"Footprint": 1,
+		"Pad":       3,
+		"Graphic":   2,
+		"Track":     3,
+		"Via":       2,
+		"Rule Area": 1,
+	}
+	for typ, n := range want {
+		if counts[typ] != n {
+			t.Errorf("got %d %s items; want %d", counts[typ], typ, n)
+		}
+	}
+
+	via := findItem(t, board, "via-gnd")
+	if got, want := strings.Join(via.Layers, " "), "F.Cu In1.Cu In2.Cu B.Cu"; got != want {
+		t.Errorf("wrong via layers %q; want %q", got, want)
+	}
+
+	pad := findItem(t, board, "pad-2")
+	props := map[string]string{
+		"Type":      "Pad",
+		"Reference": "J1",
+		"NetName":   "GND",
+		"NetClass":  "Default",
+		"Pad_Type":  "Through-hole",
+		"Pad_Shape": "Oval",
+		"Hole_Size": "1",
+	}
+	for name, want := range props {
+		got, ok := pad.Property(board, name)
+		if !ok || got != want {
+			t.Errorf("wrong %s %q (%t); want %q", name, got, ok, want)
+		}
+	}
+	if _, ok := pad.Property(board, "Width"); ok {
+		t.Errorf("pad has Width property")
+	}
+	// The footprint is rotated by 90 degrees, so pad 2 is above pad 1
+	if got := pad.points[0]; math.Abs(got.X-10) > 1e-9 || math.Abs(got.Y-7.46) > 1e-9 {
+		t.Errorf("wrong pad position %#v", got)
+	}
+}
+
+func TestExprFunctions(t *testing.T) {
+	board := testBoard(t)
+
+	tests := []struct {
+		src  string
+		a, b string
+		want bool
+	}{
+		{"A.insideArea('Keepout')", "seg-hv", "", true},
+		{"A.enclosedByArea('Keepout')", "seg-hv", "", false},
+		{"A.enclosedByArea('Keep*')", "via-gnd", "", true},
+		{"A.insideArea('Keepout')", "seg-clk-p", "", false},
+		{"A.hasNetclass('HV')", "seg-hv", "", true},
+		{"A.hasNetclass('HV')", "pad-1", "", true},
+		{"A.hasNetclass('HV')", "pad-2", "", false},
+		{"A.NetClass == 'HV'", "pad-1", "", true},
+		{"A.memberOfGroup('Power stage')", "seg-hv", "", true},
+		{"A.memberOfGroup('Power stage')", "pad-1", "", true},
+		{"A.memberOfGroup('Outer')", "pad-1", "", true},
+		{"A.memberOfGroup('Power stage')", "via-gnd", "", false},
+		{"A.memberOfFootprint('J*')", "pad-2", "", true},
+		{"A.memberOfFootprint('J1')", "via-gnd", "", false},
+		{"A.memberOfSheet('/Power')", "pad-2", "", true},
+		{"A.getField('mpn') == 'ABC-123'", "pad-1", "", true},
+		{"A.isPlated()", "pad-1", "", true},
+		{"A.isPlated()", "pad-mh", "", false},
+		{"A.isPlated()", "via-gnd", "", true},
+		{"A.isMicroVia()", "via-micro", "", true},
+		{"A.isMicroVia()", "via-gnd", "", false},
+		{"A.existsOnLayer('In2.Cu')", "via-gnd", "", true},
+		{"A.existsOnLayer('In2.Cu')", "via-micro", "", false},
+		{"A.existsOnLayer('B.Cu')", "pad-1", "", true},
+		{"A.inDiffPair('/CLK')", "seg-clk-p", "", true},
+		{"A.inDiffPair('/CLK')", "seg-hv", "", false},
+		{"A.isCoupledDiffPair()", "seg-clk-p", "seg-clk-n", true},
+		{"A.isCoupledDiffPair()", "seg-clk-p", "seg-hv", false},
+		{"A.intersectsCourtyard('J1')", "pad-1", "", true},
+		{"A.intersectsFrontCourtyard('J1')", "seg-clk-n", "", false},
+		{"A.intersectsBackCourtyard('J1')", "pad-1", "", false},
+		{"insideArea('Keepout')", "seg-hv", "", true},
+	}
+	for _, test := range tests {
+		t.Run(test.src+" "+test.a, func(t *testing.T) {
+			expr, err := ParseExpr(test.src)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			a := findItem(t, board, test.a)
+			var b *Item
+			if test.b != "" {
+				b = findItem(t, board, test.b)
+			}
+			got, err := expr.Match(board, a, b)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if got != test.want {
+				t.Errorf("got %t; want %t", got, test.want)
+			}
+		})
+	}
+}
+
+func TestChecker(t *testing.T) {
+	board := testBoard(t)
+	rules, err := kicad.ReadDesignRules(strings.NewReader(`(version 1)
+(rule "Default outer width"
+	(layer outer)
+	(constraint track_width (min 0.2mm)))
+(rule "HV clearance"
+	(constraint clearance (min 1.5mm))
+	(condition "A.NetClass == 'HV' && B.NetClass != 'HV'"))
+(rule "HV width"
+	(layer outer)
+	(constraint track_width (min 0.4mm))
+	(condition "A.hasNetclass('HV')"))
+(rule "No vias in keepout"
+	(constraint disallow via)
+	(condition "A.insideArea('Keepout')"))
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checker, err := NewChecker(board, rules)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	hv := findItem(t, board, "seg-hv")
+	clk := findItem(t, board, "seg-clk-p")
+	via := findItem(t, board, "via-gnd")
+
+	rule, constraint, err := checker.Constraint("track_width", hv, nil, "F.Cu")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if rule == nil || rule.Name != "HV width" || constraint.Min != "0.4mm" {
+		t.Errorf("wrong rule %#v for HV track width", rule)
+	}
+	rule, _, _ = checker.Constraint("track_width", clk, nil, "")
+	if rule == nil || rule.Name != "Default outer width" {
+		t.Errorf("wrong rule %#v for clock track width", rule)
+	}
+	rule, _, _ = checker.Constraint("track_width", hv, nil, "In1.Cu")
+	if rule != nil {
+		t.Errorf("got rule %q for inner layer; want none", rule.Name)
+	}
+
+	// Clearance conditions apply either way around
+	for _, pair := range [][2]*Item{{hv, via}, {via, hv}} {
+		rule, _, _ := checker.Constraint("clearance", pair[0], pair[1], "F.Cu")
+		if rule == nil || rule.Name != "HV clearance" {
+			t.Errorf("wrong clearance rule %#v for %s/%s", rule, pair[0].UUID, pair[1].UUID)
+		}
+	}
+
+	rule, err = checker.Disallowed(via)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if rule == nil || rule.Name != "No vias in keepout" {
+		t.Errorf("via in keepout not disallowed")
+	}
+	if rule, _ := checker.Disallowed(hv); rule != nil {
+		t.Errorf("track disallowed by %q", rule.Name)
+	}
+
+	_, err = NewChecker(board, &kicad.DesignRules{Rules: []kicad.DesignRule{{Name: "bad", Condition: "A.Width >"}}})
+	if err == nil || !strings.Contains(err.Error(), `invalid condition for rule "bad"`) {
+		t.Errorf("wrong error for invalid condition: %v", err)
+	}
+}
@@ -0,0 +1,378 @@
+package drc
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// value is the result of evaluating an expression, which is either a
+// number, a string, or null for a property that the item doesn't have.
+type value struct {
+	kind valueKind
+	num  float64
+	str  string
+}
+
+type valueKind int
+
+const (
+	nullValue valueKind = iota
+	numberValue
+	stringValue
+)
+
+func numberVal(n float64) value {
+	return value{kind: numberValue, num: n}
+}
+
+func stringVal(s string) value {
+	return value{kind: stringValue, str: s}
+}
+
+func boolVal(b bool) value {
+	if b {
+		return numberVal(1)
+	}
+	return numberVal(0)
+}
+
+func (v value) truthy() bool {
+	switch v.kind {
+	case numberValue:
+		return v.num != 0
+	case stringValue:
+		return v.str != ""
+	default:
+		return false
+	}
+}
+
+// number returns the value as a number, converting a string if it looks
+// like one.
+func (v value) number() (float64, bool) {
+	switch v.kind {
+	case numberValue:
+		return v.num, true
+	case stringValue:
+		n, err := strconv.ParseFloat(strings.TrimSpace(v.str), 64)
+		return n, err == nil
+	default:
+		return 0, false
+	}
+}
+
+func (v value) String() string {
+	switch v.kind {
+	case numberValue:
+		return strconv.FormatFloat(v.num, 'g', -1, 64)
+	case stringValue:
+		return v.str
+	default:
+		return ""
+	}
+}
+
+// equal compares two values as KiCad does: strings are compared without
+// regard to case, with the right-hand side allowed to be a wildcard
+// pattern, and null is equal to nothing.
+func equal(x, y value) bool {
+	if x.kind == nullValue || y.kind == nullValue {
+		return false
+	}
+	if x.kind == numberValue || y.kind == numberValue {
+		xn, xok := x.number()
+		yn, yok := y.number()
+		if xok && yok {
+			return math.Abs(xn-yn) <= 1e-9*math.Max(1, math.Max(math.Abs(xn), math.Abs(yn)))
+		}
+	}
+	return wildcardMatch(y.String(), x.String())
+}
+
+// Match evaluates the expression for the given items, which are referred to
+// as A and B, and returns true if the result is true. b may be nil for
+// conditions about a single item, in which case properties of B are null.
+func (e *Expr) Match(board *Board, a, b *Item) (bool, error) {
+	ev := &evaluator{board: board, a: a, b: b}
+	v, err := ev.eval(e.root)
+	if err != nil {
+		return false, fmt.Errorf("evaluating %q: %w", e.src, err)
+	}
+	return v.truthy(), nil
+}
+
+type evaluator struct {
+	board *Board
+	a, b  *Item
+}
+
+func (ev *evaluator) item(name string) *Item {
+	if name == "B" {
+		return ev.b
+	}
+	return ev.a
+}
+
+func (ev *evaluator) eval(n node) (value, error) {
+	switch n := n.(type) {
+	case *numberNode:
+		return numberVal(n.val), nil
+	case *stringNode:
+		return stringVal(n.val), nil
+	case *propertyNode:
+		item := ev.item(n.item)
+		if item == nil {
+			return value{}, nil
+		}
+		return item.property(ev.board, n.name), nil
+	case *callNode:
+		return ev.call(n)
+	case *unaryNode:
+		x, err := ev.eval(n.x)
+		if err != nil {
+			return value{}, err
+		}
+		if n.op == "!" {
+			return boolVal(!x.truthy()), nil
+		}
+		num, ok := x.number()
+		if !ok {
+			return value{}, fmt.Errorf("can't negate %q", x)
+		}
+		return numberVal(-num), nil
+	case *binaryNode:
+		return ev.evalBinary(n)
+	default:
+		// Should never happen, since the parser makes only the above
+		panic(fmt.Sprintf("unsupported node %T", n))
+	}
+}
+
+func (ev *evaluator) evalBinary(n *binaryNode) (value, error) {
+	x, err := ev.eval(n.x)
+	if err != nil {
+		return value{}, err
+	}
+
+	// The logical operators short-circuit, so that conditions like
+	// "A.Type == 'Pad' && A.isPlated()" don't evaluate their second
+	// operand needlessly.
+	switch n.op {
+	case "&&":
+		if !x.truthy() {
+			return boolVal(false), nil
+		}
+	case "||":
+		if x.truthy() {
+			return boolVal(true), nil
+		}
+	}
+
+	y, err := ev.eval(n.y)
+	if err != nil {
+		return value{}, err
+	}
+
+	switch n.op {
+	case "&&", "||":
+		return boolVal(y.truthy()), nil
+	case "==":
+		return boolVal(equal(x, y)), nil
+	case "!=":
+		return boolVal(!equal(x, y)), nil
+	}
+
+	xn, xok := x.number()
+	yn, yok := y.number()
+	switch n.op {
+	case "<", "<=", ">", ">=":
+		if !xok || !yok {
+			// Comparing with null or a non-numeric string is never true
+			return boolVal(false), nil
+		}
+		switch n.op {
+		case "<":
+			return boolVal(xn < yn), nil
+		case "<=":
+			return boolVal(xn <= yn), nil
+		case ">":
+			return boolVal(xn > yn), nil
+		default:
+			return boolVal(xn >= yn), nil
+		}
+	}
+
+	if !xok || !yok {
+		return value{}, fmt.Errorf("operator %s requires numbers, but got %q and %q", n.op, x, y)
+	}
+	switch n.op {
+	case "+":
+		return numberVal(xn + yn), nil
+	case "-":
+		return numberVal(xn - yn), nil
+	case "*":
+		return numberVal(xn * yn), nil
+	case "/":
+		if yn == 0 {
+			return value{}, fmt.Errorf("division by zero")
+		}
+		return numberVal(xn / yn), nil
+	default:
+		// Should never happen, since the parser makes only the above
+		panic(fmt.Sprintf("unsupported operator %s", n.op))
+	}
+}
+
+func (ev *evaluator) call(n *callNode) (value, error) {
+	fn := itemFuncs[strings.ToLower(n.name)]
+	if len(n.args) != fn.args {
+		return value{}, fmt.Errorf("%s takes %d arguments, but got %d", n.name, fn.args, len(n.args))
+	}
+	args := make([]string, len(n.args))
+	for i, arg := range n.args {
+		v, err := ev.eval(arg)
+		if err != nil {
+			return value{}, err
+		}
+		args[i] = v.String()
+	}
+
+	item := ev.item(n.item)
+	if item == nil {
+		return value{}, nil
+	}
+	other := ev.b
+	if n.item == "B" {
+		other = ev.a
+	}
+	return fn.fn(ev.board, item, other, args), nil
+}
+
+// itemFunc is a function that can be called on an item in an expression.
+// other is the other item being checked, which may be nil.
+type itemFunc struct {
+	args int
+	fn   func(board *Board, item, other *Item, args []string) value
+}
+
+// itemFuncs are the functions available in expressions, by their lowercase
+// names since KiCad matches function names without regard to case.
+var itemFuncs = map[string]itemFunc{
+	"insidearea": {1, func(board *Board, item, _ *Item, args []string) value {
+		return boolVal(board.intersectsArea(item, args[0], false))
+	}},
+	"intersectsarea": {1, func(board *Board, item, _ *Item, args []string) value {
+		return boolVal(board.intersectsArea(item, args[0], false))
+	}},
+	"enclosedbyarea": {1, func(board *Board, item, _ *Item, args []string) value {
+		return boolVal(board.intersectsArea(item, args[0], true))
+	}},
+	"intersectscourtyard": {1, func(board *Board, item, _ *Item, args []string) value {
+		return boolVal(board.intersectsCourtyard(item, args[0], "*.CrtYd"))
+	}},
+	"intersectsfrontcourtyard": {1, func(board *Board, item, _ *Item, args []string) value {
+		return boolVal(board.intersectsCourtyard(item, args[0], "F.CrtYd"))
+	}},
+	"intersectsbackcourtyard": {1, func(board *Board, item, _ *Item, args []string) value {
+		return boolVal(board.intersectsCourtyard(item, args[0], "B.CrtYd"))
+	}},
+	"hasnetclass": {1, func(board *Board, item, _ *Item, args []string) value {
+		return boolVal(item.connectable() && wildcardMatch(args[0], board.NetClass(board.PCB.NetName(item.Net))))
+	}},
+	"hasexactnetclass": {1, func(board *Board, item, _ *Item, args []string) value {
+		return boolVal(item.connectable() && board.NetClass(board.PCB.NetName(item.Net)) == args[0])
+	}},
+	"memberofgroup": {1, func(board *Board, item, _ *Item, args []string) value {
+		return boolVal(board.memberOfGroup(item, args[0]))
+	}},
+	"memberoffootprint": {1, func(board *Board, item, _ *Item, args []string) value {
+		return boolVal(item.Footprint != nil && wildcardMatch(args[0], item.Footprint.Reference()))
+	}},
+	"memberofsheet": {1, func(board *Board, item, _ *Item, args []string) value {
+		if item.Footprint == nil {
+			return boolVal(false)
+		}
+		sheet := strings.TrimSuffix(item.Footprint.SheetName, "/")
+		return boolVal(wildcardMatch(strings.TrimSuffix(args[0], "/"), sheet))
+	}},
+	"existsonlayer": {1, func(board *Board, item, _ *Item, args []string) value {
+		for _, layer := range item.Layers {
+			if layerMatch(args[0], layer) || layerMatch(layer, args[0]) {
+				return boolVal(true)
+			}
+		}
+		return boolVal(false)
+	}},
+	"isplated": {0, func(board *Board, item, _ *Item, args []string) value {
+		switch {
+		case item.Via != nil:
+			return boolVal(true)
+		case item.Pad != nil:
+			return boolVal(item.Pad.HasDrill() && item.Pad.Type != "np_thru_hole")
+		default:
+			return boolVal(false)
+		}
+	}},
+	"ismicrovia": {0, func(board *Board, item, _ *Item, args []string) value {
+		return boolVal(item.Via != nil && item.Via.Type == "micro")
+	}},
+	"isblindburiedvia": {0, func(board *Board, item, _ *Item, args []string) value {
+		return boolVal(item.Via != nil && item.Via.Type == "blind")
+	}},
+	"indiffpair": {1, func(board *Board, item, _ *Item, args []string) value {
+		base, _, ok := board.diffPair(board.PCB.NetName(item.Net))
+		return boolVal(ok && wildcardMatch(args[0], base))
+	}},
+	"iscoupleddiffpair": {0, func(board *Board, item, other *Item, args []string) value {
+		if other == nil {
+			return boolVal(false)
+		}
+		_, complement, ok := board.diffPair(board.PCB.NetName(item.Net))
+		return boolVal(ok && board.PCB.NetName(other.Net) == complement)
+	}},
+	"getfield": {1, func(board *Board, item, _ *Item, args []string) value {
+		if item.Footprint == nil {
+			return value{}
+		}
+		for _, prop := range item.Footprint.Properties {
+			if strings.EqualFold(prop.Name, args[0]) {
+				return stringVal(prop.Value)
+			}
+		}
+		return value{}
+	}},
+}
+
+// wildcardMatch returns true if the given string matches the given pattern,
+// in which "*" matches any sequence of characters and "?" matches any
+// single character. The match ignores case, as KiCad's does.
+func wildcardMatch(pattern, s string) bool {
+	pattern = strings.ToLower(pattern)
+	s = strings.ToLower(s)
+
+	// Backtracking matcher, remembering only the most recent star
+	p, i := 0, 0
+	star, mark := -1, 0
+	for i < len(s) {
+		switch {
+		case p < len(pattern) && (pattern[p] == '?' || pattern[p] == s[i]):
+			p++
+			i++
+		case p < len(pattern) && pattern[p] == '*':
+			star, mark = p, i
+			p++
+		case star >= 0:
+			p = star + 1
+			mark++
+			i = mark
+		default:
+			return false
+		}
+	}
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+	return p == len(pattern)
+}
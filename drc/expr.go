@@ -0,0 +1,345 @@
+package drc
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Expr is a parsed condition expression, as used in the condition clause of
+// a custom design rule.
+//
+// Expressions refer to properties of the two items being checked as A and
+// B, as in "A.NetClass == 'HV'", and can call functions on them, as in
+// "A.insideArea('Keepout')". They use the usual arithmetic, comparison and
+// logical operators, along with string literals in single quotes and
+// numbers that may have a unit suffix such as "mm" or "mil".
+type Expr struct {
+	src  string
+	root node
+}
+
+// ParseExpr parses the given condition expression.
+func ParseExpr(src string) (*Expr, error) {
+	toks, err := lexExpr(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &exprParser{toks: toks}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.typ != tokenEOF {
+		return nil, fmt.Errorf("unexpected %s at column %d", tok, tok.pos+1)
+	}
+	return &Expr{src: src, root: root}, nil
+}
+
+// String returns the source of the expression.
+func (e *Expr) String() string {
+	return e.src
+}
+
+type node interface{}
+
+type numberNode struct {
+	val float64
+}
+
+type stringNode struct {
+	val string
+}
+
+// propertyNode is a property of one of the items, like A.NetClass. Item is
+// either "A" or "B".
+type propertyNode struct {
+	item string
+	name string
+}
+
+// callNode is a function called on one of the items, like
+// A.insideArea('Keepout'). Functions called without an item apply to A.
+type callNode struct {
+	item string
+	name string
+	args []node
+}
+
+type unaryNode struct {
+	op string
+	x  node
+}
+
+type binaryNode struct {
+	op   string
+	x, y node
+}
+
+type tokenType int
+
+const (
+	tokenEOF tokenType = iota
+	tokenNumber
+	tokenString
+	tokenIdent
+	tokenOp
+)
+
+type token struct {
+	typ tokenType
+	val string
+	num float64
+	pos int
+}
+
+func (t token) String() string {
+	switch t.typ {
+	case tokenEOF:
+		return "end of expression"
+	case tokenString:
+		return strconv.Quote(t.val)
+	default:
+		return fmt.Sprintf("%q", t.val)
+	}
+}
+
+// exprOps are the operators of the expression language, with those that
+// begin with another operator listed first.
+var exprOps = []string{
+	"==", "!=", "<=", ">=", "&&", "||",
+	"<", ">", "!", "+", "-", "*", "/", "(", ")", ",", ".",
+}
+
+// unitScales gives the number of millimeters in each distance unit, and
+// the identity for angle units, so that all distances in expressions are
+// in millimeters and all angles in degrees.
+var unitScales = map[string]float64{
+	"mm":   1,
+	"um":   0.001,
+	"mil":  0.0254,
+	"mils": 0.0254,
+	"thou": 0.0254,
+	"th":   0.0254,
+	"in":   25.4,
+	"deg":  1,
+}
+
+func lexExpr(src string) ([]token, error) {
+	var toks []token
+	i := 0
+Tokens:
+	for i < len(src) {
+		ch := src[i]
+		switch {
+		case ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r':
+			i++
+
+		case ch >= '0' && ch <= '9' || ch == '.' && i+1 < len(src) && src[i+1] >= '0' && src[i+1] <= '9':
+			start := i
+			for i < len(src) && (src[i] >= '0' && src[i] <= '9' || src[i] == '.') {
+				i++
+			}
+			num, err := strconv.ParseFloat(src[start:i], 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid number %q at column %d", src[start:i], start+1)
+			}
+			unitStart := i
+			for i < len(src) && isIdentByte(src[i]) {
+				i++
+			}
+			if unit := strings.ToLower(src[unitStart:i]); unit != "" {
+				scale, ok := unitScales[unit]
+				if !ok {
+					return nil, fmt.Errorf("invalid unit %q at column %d", src[unitStart:i], unitStart+1)
+				}
+				num *= scale
+			}
+			toks = append(toks, token{typ: tokenNumber, val: src[start:i], num: num, pos: start})
+
+		case ch == '\'' || ch == '"':
+			start := i
+			end := strings.IndexByte(src[i+1:], ch)
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated string at column %d", start+1)
+			}
+			toks = append(toks, token{typ: tokenString, val: src[i+1 : i+1+end], pos: start})
+			i += end + 2
+
+		case isIdentByte(ch):
+			start := i
+			for i < len(src) && (isIdentByte(src[i]) || src[i] >= '0' && src[i] <= '9') {
+				i++
+			}
+			toks = append(toks, token{typ: tokenIdent, val: src[start:i], pos: start})
+
+		default:
+			for _, op := range exprOps {
+				if strings.HasPrefix(src[i:], op) {
+					toks = append(toks, token{typ: tokenOp, val: op, pos: i})
+					i += len(op)
+					continue Tokens
+				}
+			}
+			return nil, fmt.Errorf("unexpected %q at column %d", ch, i+1)
+		}
+	}
+	return append(toks, token{typ: tokenEOF, pos: len(src)}), nil
+}
+
+func isIdentByte(ch byte) bool {
+	return ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z' || ch == '_'
+}
+
+type exprParser struct {
+	toks []token
+	pos  int
+}
+
+func (p *exprParser) peek() token {
+	return p.toks[p.pos]
+}
+
+func (p *exprParser) next() token {
+	tok := p.toks[p.pos]
+	if tok.typ != tokenEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *exprParser) isOp(ops ...string) bool {
+	tok := p.peek()
+	if tok.typ != tokenOp {
+		return false
+	}
+	for _, op := range ops {
+		if tok.val == op {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *exprParser) expectOp(op string) error {
+	if !p.isOp(op) {
+		tok := p.peek()
+		return fmt.Errorf("expected %q but found %s at column %d", op, tok, tok.pos+1)
+	}
+	p.next()
+	return nil
+}
+
+// parseBinary parses a sequence of operands separated by any of the given
+// operators, which are left-associative.
+func (p *exprParser) parseBinary(operand func() (node, error), ops ...string) (node, error) {
+	x, err := operand()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp(ops...) {
+		op := p.next().val
+		y, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		x = &binaryNode{op: op, x: x, y: y}
+	}
+	return x, nil
+}
+
+func (p *exprParser) parseOr() (node, error) {
+	return p.parseBinary(p.parseAnd, "||")
+}
+
+func (p *exprParser) parseAnd() (node, error) {
+	return p.parseBinary(p.parseEquality, "&&")
+}
+
+func (p *exprParser) parseEquality() (node, error) {
+	return p.parseBinary(p.parseRelational, "==", "!=")
+}
+
+func (p *exprParser) parseRelational() (node, error) {
+	return p.parseBinary(p.parseAdditive, "<", "<=", ">", ">=")
+}
+
+func (p *exprParser) parseAdditive() (node, error) {
+	return p.parseBinary(p.parseMultiplicative, "+", "-")
+}
+
+func (p *exprParser) parseMultiplicative() (node, error) {
+	return p.parseBinary(p.parseUnary, "*", "/")
+}
+
+func (p *exprParser) parseUnary() (node, error) {
+	if p.isOp("!", "-") {
+		op := p.next().val
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &unaryNode{op: op, x: x}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *exprParser) parsePrimary() (node, error) {
+	tok := p.next()
+	switch tok.typ {
+	case tokenNumber:
+		return &numberNode{val: tok.num}, nil
+	case tokenString:
+		return &stringNode{val: tok.val}, nil
+	case tokenIdent:
+		item, name := "A", tok.val
+		if p.isOp(".") {
+			if tok.val != "A" && tok.val != "B" {
+				return nil, fmt.Errorf("unknown item %q at column %d; must be A or B", tok.val, tok.pos+1)
+			}
+			p.next()
+			nameTok := p.next()
+			if nameTok.typ != tokenIdent {
+				return nil, fmt.Errorf("expected property name but found %s at column %d", nameTok, nameTok.pos+1)
+			}
+			item, name = tok.val, nameTok.val
+		} else if !p.isOp("(") {
+			return nil, fmt.Errorf("unknown identifier %q at column %d", tok.val, tok.pos+1)
+		}
+
+		if !p.isOp("(") {
+			return &propertyNode{item: item, name: name}, nil
+		}
+		p.next()
+		call := &callNode{item: item, name: name}
+		for !p.isOp(")") {
+			if len(call.args) > 0 {
+				if err := p.expectOp(","); err != nil {
+					return nil, err
+				}
+			}
+			arg, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			call.args = append(call.args, arg)
+		}
+		p.next()
+		if _, ok := itemFuncs[strings.ToLower(name)]; !ok {
+			return nil, fmt.Errorf("unknown function %q at column %d", name, tok.pos+1)
+		}
+		return call, nil
+	case tokenOp:
+		if tok.val == "(" {
+			x, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expectOp(")"); err != nil {
+				return nil, err
+			}
+			return x, nil
+		}
+	}
+	return nil, fmt.Errorf("unexpected %s at column %d", tok, tok.pos+1)
+}
@@ -0,0 +1,118 @@
+package drc
+
+import (
+	"testing"
+
+	"github.com/apparentlymart/go-kicad"
+)
+
+func TestParseExpr_invalid(t *testing.T) {
+	tests := map[string]string{
+		"A.NetClass ==":         `unexpected end of expression at column 14`,
+		"C.NetClass == 'HV'":    `unknown item "C" at column 1; must be A or B`,
+		"NetClass == 'HV'":      `unknown identifier "NetClass" at column 1`,
+		"A.frobnicate('x')":     `unknown function "frobnicate" at column 1`,
+		"A.Width > 0.2furlongs": `invalid unit "furlongs" at column 14`,
+		"A.NetName == 'VCC":     `unterminated string at column 14`,
+		"(A.Width > 1":          `expected ")" but found end of expression at column 13`,
+		"A.Width 1":             `unexpected "1" at column 9`,
+	}
+	for src, want := range tests {
+		t.Run(src, func(t *testing.T) {
+			_, err := ParseExpr(src)
+			if err == nil {
+				t.Fatalf("no error; want %q", want)
+			}
+			if got := err.Error(); got != want {
+				t.Errorf("wrong error\ngot:  %s\nwant: %s", got, want)
+			}
+		})
+	}
+}
+
+func TestExprMatch(t *testing.T) {
+	pcb := &kicad.PCB{
+		Nets: []kicad.PCBNet{{Number: 0}, {Number: 1, Name: "/HV_OUT"}, {Number: 2, Name: "GND"}},
+		Segments: []kicad.Segment{
+			{Width: 0.25, Layer: "F.Cu", Net: 1},
+			{Width: 0.5, Layer: "B.Cu", Net: 2},
+		},
+	}
+	board := NewBoard(pcb, nil)
+	a, b := board.Items()[0], board.Items()[1]
+
+	tests := []struct {
+		src  string
+		want bool
+	}{
+		{"A.Type == 'Track'", true},
+		{"A.type == 'track'", true},
+		{"A.NetName == '/HV*'", true},
+		{"A.NetName == '/hv_?UT'", true},
+		{"B.NetName == '/HV*'", false},
+		{"A.NetName != 'GND' && B.NetName == 'GND'", true},
+		{"A.Width == 0.25mm", true},
+		{"A.Width > 0.2mm && A.Width < 9mil", false},
+		{"A.Width >= 9.8425mil", true},
+		{"B.Width - A.Width == 0.25", true},
+		{"A.Width * 2 == B.Width", true},
+		{"!(A.Layer == 'B.Cu')", true},
+		{"-A.Width < 0", true},
+		{"A.Hole_Size > 0", false},
+		{"A.Hole_Size != 1", true},
+		{"A.NetClass == 'Default'", true},
+		{"A.existsOnLayer('*.Cu')", true},
+		{"B.existsOnLayer('F.Cu')", false},
+		{"A.Type == 'Via' || A.Layer == 'F.Cu'", true},
+		{"1 + 2 * 3 == 7", true},
+	}
+	for _, test := range tests {
+		t.Run(test.src, func(t *testing.T) {
+			expr, err := ParseExpr(test.src)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			got, err := expr.Match(board, a, b)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if got != test.want {
+				t.Errorf("got %t; want %t", got, test.want)
+			}
+		})
+	}
+
+	// Properties of B are null when there is no second item
+	expr, _ := ParseExpr("B.NetName == 'GND' || B.Width > 0")
+	if got, err := expr.Match(board, a, nil); err != nil || got {
+		t.Errorf("got %t (%v) with no B; want false", got, err)
+	}
+
+	expr, _ = ParseExpr("A.NetName + 1 > 0")
+	if _, err := expr.Match(board, a, b); err == nil {
+		t.Errorf("no error for adding string to number")
+	}
+}
+
+func TestWildcardMatch(t *testing.T) {
+	tests := []struct {
+		pattern, s string
+		want       bool
+	}{
+		{"", "", true},
+		{"*", "", true},
+		{"*", "/a/b", true},
+		{"/HV*", "/hv/out", true},
+		{"a?c", "abc", true},
+		{"a?c", "ac", false},
+		{"*_P", "CLK_P", true},
+		{"*_P", "CLK_N", false},
+		{"a*b*c", "aXbYbZc", true},
+		{"a*b*c", "aXbYbZ", false},
+	}
+	for _, test := range tests {
+		if got := wildcardMatch(test.pattern, test.s); got != test.want {
+			t.Errorf("wildcardMatch(%q, %q) = %t; want %t", test.pattern, test.s, got, test.want)
+		}
+	}
+}
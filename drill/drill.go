@@ -0,0 +1,338 @@
+// Package drill writes the holes of a KiCad board as Excellon drill files,
+// along with a report describing the files and the tools they use.
+//
+// Holes are split across files in the same way as KiCad splits them:
+// plated holes through the whole board, then plated holes for each pair of
+// copper layers joined by blind or buried vias, and finally non-plated
+// holes. Oval holes are written as slots.
+package drill
+
+import (
+	"math"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/apparentlymart/go-kicad"
+)
+
+// Hole is a hole to be drilled in a board. A round hole is drilled at
+// Start, which is the same as End. An oval hole is a slot routed from Start
+// to End with a tool of the given Diameter.
+type Hole struct {
+	Start    kicad.Position
+	End      kicad.Position
+	Diameter float64
+	Plated   bool
+
+	// Via is set for the holes of vias, and not for the holes of pads.
+	Via bool
+
+	// Pair gives the outermost copper layers that the hole passes
+	// through.
+	Pair LayerPair
+}
+
+// IsSlot returns true if the hole is an oval slot rather than a round
+// hole.
+func (h *Hole) IsSlot() bool {
+	return h.Start != h.End
+}
+
+// LayerPair is a pair of copper layers, with Top above Bottom.
+type LayerPair struct {
+	Top    string
+	Bottom string
+}
+
+// Holes returns all of the holes in the given board, from both its pads
+// and its vias.
+func Holes(pcb *kicad.PCB) []Hole {
+	copper := pcb.CopperLayers()
+	through := LayerPair{Top: "F.Cu", Bottom: "B.Cu"}
+	if len(copper) > 0 {
+		through = LayerPair{Top: copper[0], Bottom: copper[len(copper)-1]}
+	}
+
+	var ret []Hole
+	for i := range pcb.Footprints {
+		fp := &pcb.Footprints[i]
+		for j := range fp.Pads {
+			pad := &fp.Pads[j]
+			if !pad.HasDrill() {
+				continue
+			}
+			at := fp.BoardPosition(pad.At.Position())
+			hole := Hole{
+				Start:    at,
+				End:      at,
+				Diameter: pad.Drill.Width,
+				Plated:   pad.Type != "np_thru_hole",
+				Pair:     through,
+			}
+			w, h := pad.Drill.Width, pad.Drill.Height
+			if pad.Drill.Oval && h > 0 && w != h {
+				var half kicad.Position
+				if w > h {
+					half = kicad.Position{X: (w - h) / 2}
+					hole.Diameter = h
+				} else {
+					half = kicad.Position{Y: (h - w) / 2}
+				}
+				half = kicad.RotatePoint(half, pad.At.Angle)
+				hole.Start = kicad.Position{X: at.X - half.X, Y: at.Y - half.Y}
+				hole.End = kicad.Position{X: at.X + half.X, Y: at.Y + half.Y}
+			}
+			ret = append(ret, hole)
+		}
+	}
+	for _, via := range pcb.Vias {
+		pair := through
+		if via.Type != "" && len(via.Layers) == 2 {
+			pair = orderPair(copper, via.Layers[0], via.Layers[1])
+		}
+		ret = append(ret, Hole{
+			Start:    via.At,
+			End:      via.At,
+			Diameter: via.Drill,
+			Plated:   true,
+			Via:      true,
+			Pair:     pair,
+		})
+	}
+	return ret
+}
+
+// orderPair returns the given layers as a pair ordered from top to bottom.
+func orderPair(copper []string, a, b string) LayerPair {
+	if slices.Index(copper, a) > slices.Index(copper, b) {
+		a, b = b, a
+	}
+	return LayerPair{Top: a, Bottom: b}
+}
+
+// File is the content of one drill file.
+type File struct {
+	Pair   LayerPair
+	Plated bool
+
+	// Holes are the holes in the file, ordered by the tool that drills
+	// them.
+	Holes []Hole
+
+	// Tools are the tools that drill the holes, ordered by increasing
+	// diameter and numbered from 1.
+	Tools []Tool
+
+	// Through is set for a file of holes passing through the whole
+	// board, and Layers gives the number of copper layers in the board.
+	Through bool
+	Layers  int
+
+	// topIndex and bottomIndex are the positions of the layers of Pair
+	// among the board's copper layers, counting from 1.
+	topIndex, bottomIndex int
+}
+
+// Tool is a drill bit used for the holes of a File.
+type Tool struct {
+	Number   int
+	Diameter float64
+	Plated   bool
+
+	// Via is set for a tool that drills only the holes of vias.
+	Via bool
+
+	// Holes counts all of the holes that the tool drills, including
+	// Slots, which counts only the slots.
+	Holes int
+	Slots int
+}
+
+// Files returns the drill files needed for the given board: first a file
+// of plated holes through the whole board, then one for each pair of
+// layers joined by blind or buried vias, and finally one for non-plated
+// holes. Files that would have no holes are omitted.
+func Files(pcb *kicad.PCB) []*File {
+	copper := pcb.CopperLayers()
+	holes := Holes(pcb)
+
+	type fileKey struct {
+		pair   LayerPair
+		plated bool
+	}
+	files := make(map[fileKey]*File)
+	var keys []fileKey
+	for _, hole := range holes {
+		key := fileKey{pair: hole.Pair, plated: hole.Plated}
+		f, ok := files[key]
+		if !ok {
+			f = &File{
+				Pair:        hole.Pair,
+				Plated:      hole.Plated,
+				Layers:      len(copper),
+				topIndex:    slices.Index(copper, hole.Pair.Top) + 1,
+				bottomIndex: slices.Index(copper, hole.Pair.Bottom) + 1,
+			}
+			f.Through = f.topIndex == 1 && f.bottomIndex == len(copper)
+			files[key] = f
+			keys = append(keys, key)
+		}
+		f.Holes = append(f.Holes, hole)
+	}
+
+	ret := make([]*File, 0, len(keys))
+	for _, key := range keys {
+		f := files[key]
+		f.assignTools()
+		ret = append(ret, f)
+	}
+	sort.SliceStable(ret, func(i, j int) bool {
+		a, b := ret[i], ret[j]
+		if a.Plated != b.Plated {
+			return a.Plated
+		}
+		if a.Through != b.Through {
+			return a.Through
+		}
+		if a.topIndex != b.topIndex {
+			return a.topIndex < b.topIndex
+		}
+		return a.bottomIndex < b.bottomIndex
+	})
+	return ret
+}
+
+// assignTools chooses the tools for the holes of the file, and sorts the
+// holes by tool and then by position so that the output doesn't depend on
+// the order of items in the board.
+func (f *File) assignTools() {
+	type toolKey struct {
+		diameter int64 // in nanometers, to avoid rounding differences
+		via      bool
+	}
+	byKey := make(map[toolKey]*Tool)
+	var tools []*Tool
+	holeTools := make([]toolKey, len(f.Holes))
+	for i, hole := range f.Holes {
+		key := toolKey{diameter: int64(math.Round(hole.Diameter * 1e6)), via: hole.Via}
+		holeTools[i] = key
+		t, ok := byKey[key]
+		if !ok {
+			t = &Tool{Diameter: hole.Diameter, Plated: f.Plated, Via: hole.Via}
+			byKey[key] = t
+			tools = append(tools, t)
+		}
+		t.Holes++
+		if hole.IsSlot() {
+			t.Slots++
+		}
+	}
+	sort.SliceStable(tools, func(i, j int) bool {
+		if tools[i].Diameter != tools[j].Diameter {
+			return tools[i].Diameter < tools[j].Diameter
+		}
+		// Vias before pads of the same size
+		return tools[i].Via && !tools[j].Via
+	})
+	f.Tools = make([]Tool, len(tools))
+	for i, t := range tools {
+		t.Number = i + 1
+		f.Tools[i] = *t
+	}
+
+	order := make([]int, len(f.Holes))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		a, b := &f.Holes[order[i]], &f.Holes[order[j]]
+		ta, tb := byKey[holeTools[order[i]]].Number, byKey[holeTools[order[j]]].Number
+		if ta != tb {
+			return ta < tb
+		}
+		if a.Start.Y != b.Start.Y {
+			return a.Start.Y < b.Start.Y
+		}
+		return a.Start.X < b.Start.X
+	})
+	holes := make([]Hole, len(f.Holes))
+	for i, idx := range order {
+		holes[i] = f.Holes[idx]
+	}
+	f.Holes = holes
+}
+
+// Tool returns the tool that drills the given hole of the file.
+func (f *File) Tool(hole *Hole) *Tool {
+	d := math.Round(hole.Diameter * 1e6)
+	for i := range f.Tools {
+		t := &f.Tools[i]
+		if math.Round(t.Diameter*1e6) == d && t.Via == hole.Via {
+			return t
+		}
+	}
+	return nil
+}
+
+// Name returns the name KiCad gives to the drill file, given the base
+// name of the board file without its extension. Through-hole files are
+// named "-PTH" and "-NPTH", while files for pairs of layers are named for
+// the layers, such as "-front-in1" or "-in1-in2".
+func (f *File) Name(base string) string {
+	switch {
+	case f.Through && f.Plated:
+		return base + "-PTH.drl"
+	case f.Through:
+		return base + "-NPTH.drl"
+	default:
+		return base + "-" + pairLayerName(f.Pair.Top) + "-" + pairLayerName(f.Pair.Bottom) + ".drl"
+	}
+}
+
+func pairLayerName(layer string) string {
+	switch layer {
+	case "F.Cu":
+		return "front"
+	case "B.Cu":
+		return "back"
+	default:
+		return strings.ToLower(strings.TrimSuffix(layer, ".Cu"))
+	}
+}
+
+// FileFunction returns the value of the .FileFunction attribute of the
+// file, such as "Plated,1,4,PTH" or "Plated,1,2,Blind".
+func (f *File) FileFunction() string {
+	span := strconv.Itoa(f.topIndex) + "," + strconv.Itoa(f.bottomIndex)
+	switch {
+	case !f.Plated:
+		return "NonPlated," + span + ",NPTH"
+	case f.Through:
+		return "Plated," + span + ",PTH"
+	case f.topIndex == 1 || f.bottomIndex == f.Layers:
+		return "Plated," + span + ",Blind"
+	default:
+		return "Plated," + span + ",Buried"
+	}
+}
+
+// AperFunction returns the value of the .AperFunction attribute of the
+// given tool of the file, such as "Plated,PTH,ViaDrill".
+func (f *File) AperFunction(t *Tool) string {
+	kind := "ComponentDrill"
+	if t.Via {
+		kind = "ViaDrill"
+	}
+	switch {
+	case !f.Plated:
+		return "NonPlated,NPTH," + kind
+	case f.Through:
+		return "Plated,PTH," + kind
+	case f.topIndex == 1 || f.bottomIndex == f.Layers:
+		return "Plated,Blind," + kind
+	default:
+		return "Plated,Buried," + kind
+	}
+}
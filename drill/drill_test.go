@@ -0,0 +1,255 @@
+package drill
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apparentlymart/go-kicad"
+	"github.com/apparentlymart/go-kicad/internal/testboard"
+	"github.com/davecgh/go-spew/spew"
+)
+
+const testBoardSrc = `(kicad_pcb (version 20221018) (generator pcbnew)
+  (layers
+    (0 "F.Cu" signal)
+    (1 "In1.Cu" signal)
+    (2 "In2.Cu" signal)
+    (31 "B.Cu" signal)
+  )
+  (setup
+    (aux_axis_origin 100 100)
+  )
+  (net 0 "")
+  (footprint "Test:Holes" (layer "F.Cu")
+    (at 110 90 90)
+    (property "Reference" "J1")
+    (pad "1" thru_hole circle (at 0 0 90) (size 1.7 1.7) (drill 1) (layers "*.Cu" "*.Mask"))
+    (pad "2" thru_hole oval (at 0 2.54 90) (size 1.7 3) (drill oval 1 2) (layers "*.Cu" "*.Mask"))
+    (pad "" np_thru_hole circle (at -5 0 90) (size 3 3) (drill 3) (layers "*.Cu" "*.Mask"))
+  )
+  (via (at 120 95) (size 0.6) (drill 0.3) (layers "F.Cu" "B.Cu"))
+  (via (at 115 95) (size 0.6) (drill 1) (layers "F.Cu" "B.Cu"))
+  (via blind (at 121 96) (size 0.45) (drill 0.2) (layers "In1.Cu" "F.Cu"))
+  (via blind (at 122 97) (size 0.45) (drill 0.2) (layers "In1.Cu" "In2.Cu"))
+)
+`
+
+func testBoard(t *testing.T) *kicad.PCB {
+	t.Helper()
+	pcb, err := kicad.ReadPCB(strings.NewReader(testBoardSrc))
+	if err != nil {
+		t.Fatalf("reading board: %s", err)
+	}
+	return pcb
+}
+
+func TestFiles(t *testing.T) {
+	pcb := testBoard(t)
+	files := Files(pcb)
+
+	type summary struct {
+		Name, Function string
+		Holes, Tools   int
+	}
+	var got []summary
+	for _, f := range files {
+		got = append(got, summary{f.Name("board"), f.FileFunction(), len(f.Holes), len(f.Tools)})
+	}
+	want := []summary{
+		{"board-PTH.drl", "Plated,1,4,PTH", 4, 3},
+		{"board-front-in1.drl", "Plated,1,2,Blind", 1, 1},
+		{"board-in1-in2.drl", "Plated,2,3,Buried", 1, 1},
+		{"board-NPTH.drl", "NonPlated,1,4,NPTH", 1, 1},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("wrong files\ngot:  %swant: %s", spew.Sdump(got), spew.Sdump(want))
+	}
+
+	// The slot of the oval pad is rotated along with the footprint
+	slot := files[0].Holes[3]
+	if !slot.IsSlot() || slot.Start != (kicad.Position{X: 112.04, Y: 90}) || slot.End != (kicad.Position{X: 113.04, Y: 90}) || slot.Diameter != 1 {
+		t.Errorf("wrong slot %#v", slot)
+	}
+	if tool := files[0].Tool(&slot); tool.Number != 3 || tool.Holes != 2 || tool.Slots != 1 || tool.Via {
+		t.Errorf("wrong tool %#v", tool)
+	}
+}
+
+func TestFiles_layerPairs(t *testing.T) {
+	pcb := testboard.Read(t)
+	var got []string
+	for _, f := range Files(pcb) {
+		got = append(got, f.Name("board")+" "+f.FileFunction())
+	}
+	want := []string{
+		"board-PTH.drl Plated,1,4,PTH",
+		"board-front-in1.drl Plated,1,2,Blind",
+		"board-in1-in2.drl Plated,2,3,Buried",
+		"board-NPTH.drl NonPlated,1,4,NPTH",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong files\ngot:  %q\nwant: %q", got, want)
+	}
+}
+
+func TestWrite(t *testing.T) {
+	pcb := testBoard(t)
+	files := Files(pcb)
+	opts := &Options{
+		CreationDate: time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC),
+	}
+	var buf bytes.Buffer
+	err := Write(&buf, pcb, files[0], opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := buf.String()
+	want := `M48
+; DRILL file {go-kicad} date 2024-03-01T12:30:00+00:00
+; FORMAT={-:-/ absolute / metric / decimal}
+; #@! TF.CreationDate,2024-03-01T12:30:00+00:00
+; #@! TF.GenerationSoftware,apparentlymart,go-kicad
+; #@! TF.FileFunction,Plated,1,4,PTH
+FMAT,2
+METRIC
+; #@! TA.AperFunction,Plated,PTH,ViaDrill
+T1C0.300
+; #@! TA.AperFunction,Plated,PTH,ViaDrill
+T2C1.000
+; #@! TA.AperFunction,Plated,PTH,ComponentDrill
+T3C1.000
+%
+G90
+G05
+T1
+X120.0Y-95.0
+T2
+X115.0Y-95.0
+T3
+X110.0Y-90.0
+G00X112.04Y-90.0
+M15
+G01X113.04Y-90.0
+M16
+G05
+M30
+`
+	if got != want {
+		t.Errorf("incorrect result\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestWrite_noTool(t *testing.T) {
+	pcb := testBoard(t)
+	f := Files(pcb)[0]
+	f.Holes = append(f.Holes, Hole{Start: kicad.Position{X: 1, Y: 2}, End: kicad.Position{X: 1, Y: 2}, Diameter: 5})
+	var buf bytes.Buffer
+	if err := Write(&buf, pcb, f, nil); err == nil {
+		t.Fatal("no error")
+	}
+}
+
+func TestWrite_formats(t *testing.T) {
+	pcb := testBoard(t)
+	files := Files(pcb)
+
+	tests := []struct {
+		opts Options
+		want []string
+	}{
+		{
+			Options{Zeros: SuppressLeading, UseAuxOrigin: true},
+			[]string{"; FORMAT={3:3/ absolute / metric / suppress leading zeros}\n", "METRIC,TZ\n", "X20000Y5000\n", "G00X12040Y10000\n"},
+		},
+		{
+			Options{Zeros: SuppressTrailing, UseAuxOrigin: true},
+			[]string{"METRIC,LZ\n", "X02Y005\n", "G00X01204Y01\n"},
+		},
+		{
+			Options{Zeros: KeepZeros, UseAuxOrigin: true},
+			[]string{"METRIC,TZ\n", "X020000Y005000\n"},
+		},
+		{
+			Options{Units: Inches, Zeros: SuppressTrailing, UseAuxOrigin: true, G85Slots: true},
+			[]string{"; FORMAT={2:4/ absolute / inch / suppress trailing zeros}\n", "INCH,LZ\n", "T1C0.0118\n", "X007874Y001969\n", "X00474Y003937G85X005134Y003937\n"},
+		},
+		{
+			Options{Units: Inches},
+			[]string{"INCH\n", "X4.7244Y-3.7402\n"},
+		},
+	}
+	for _, test := range tests {
+		var buf bytes.Buffer
+		err := Write(&buf, pcb, files[0], &test.opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := buf.String()
+		for _, want := range test.want {
+			if !strings.Contains(got, want) {
+				t.Errorf("missing %q for %#v in result:\n%s", want, test.opts, got)
+			}
+		}
+	}
+}
+
+func TestWriteReport(t *testing.T) {
+	pcb := testBoard(t)
+	var buf bytes.Buffer
+	err := WriteReport(&buf, pcb, "board", Files(pcb), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := buf.String()
+	want := `Drill report for board.kicad_pcb
+
+Copper Layer Stackup:
+    =============================================================
+    L1 :  F.Cu                      front
+    L2 :  In1.Cu                    inner1
+    L3 :  In2.Cu                    inner2
+    L4 :  B.Cu                      back
+
+
+Drill file 'board-PTH.drl' contains
+    plated through holes:
+    =============================================================
+    T1  0.30mm  0.012"  (1 hole)
+    T2  1.00mm  0.039"  (1 hole)
+    T3  1.00mm  0.039"  (2 holes)  (with 1 slot)
+
+    Total plated holes count 4
+
+
+Drill file 'board-front-in1.drl' contains
+    holes connecting layer pair: 'F.Cu and In1.Cu' (blind via)
+    =============================================================
+    T1  0.20mm  0.008"  (1 hole)
+
+    Total plated holes count 1
+
+
+Drill file 'board-in1-in2.drl' contains
+    holes connecting layer pair: 'In1.Cu and In2.Cu' (buried via)
+    =============================================================
+    T1  0.20mm  0.008"  (1 hole)
+
+    Total plated holes count 1
+
+
+Drill file 'board-NPTH.drl' contains
+    unplated through holes:
+    =============================================================
+    T1  3.00mm  0.118"  (1 hole)
+
+    Total unplated holes count 1
+`
+	if got != want {
+		t.Errorf("incorrect result\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
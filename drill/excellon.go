@@ -0,0 +1,276 @@
+package drill
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/apparentlymart/go-kicad"
+	"github.com/apparentlymart/go-kicad/internal/fileio"
+)
+
+// Units selects the units of the numbers in a drill file.
+type Units int
+
+const (
+	Millimeters Units = iota
+	Inches
+)
+
+// ZeroFormat selects how coordinates are written in a drill file. Decimal
+// coordinates include a decimal point, while the other formats write
+// coordinates as integers in a fixed format of 3.3 digits for millimeters
+// or 2.4 digits for inches, with the given zeros omitted.
+type ZeroFormat int
+
+const (
+	Decimal ZeroFormat = iota
+	SuppressLeading
+	SuppressTrailing
+	KeepZeros
+)
+
+// Options customizes the files that Write and WriteFiles produce. A nil
+// *Options is equivalent to a pointer to the zero value, which writes
+// decimal coordinates in millimeters.
+type Options struct {
+	Units Units
+	Zeros ZeroFormat
+
+	// UseAuxOrigin places the origin of the files at the board's
+	// auxiliary axis origin, rather than at the origin of the board
+	// document.
+	UseAuxOrigin bool
+
+	// G85Slots writes slots with the G85 canned slot command, rather than
+	// routing them with the M15 and M16 commands. Some manufacturers
+	// support only one of these forms.
+	G85Slots bool
+
+	// CreationDate is recorded in the header of each file. If it is zero
+	// then no date is recorded, so that the output depends only on the
+	// board.
+	CreationDate time.Time
+}
+
+// Write writes the given drill file of the given board to the given writer
+// in Excellon format. It is an error for the file to have a hole that none
+// of its tools can drill, which can only happen if the file has been
+// changed since Files returned it.
+func Write(w io.Writer, pcb *kicad.PCB, f *File, opts *Options) error {
+	if opts == nil {
+		opts = &Options{}
+	}
+	var origin kicad.Position
+	if opts.UseAuxOrigin {
+		origin = pcb.Setup.AuxAxisOrigin
+	}
+	ef := excellonFormat{opts: opts, origin: origin}
+
+	var buf bytes.Buffer
+	buf.WriteString("M48\n")
+	if opts.CreationDate.IsZero() {
+		buf.WriteString("; DRILL file {go-kicad}\n")
+	} else {
+		fmt.Fprintf(&buf, "; DRILL file {go-kicad} date %s\n", opts.CreationDate.Format("2006-01-02T15:04:05-07:00"))
+	}
+	fmt.Fprintf(&buf, "; FORMAT={%s/ absolute / %s / %s}\n", ef.digits(), ef.unitsName(), ef.zerosName())
+	if !opts.CreationDate.IsZero() {
+		fmt.Fprintf(&buf, "; #@! TF.CreationDate,%s\n", opts.CreationDate.Format("2006-01-02T15:04:05-07:00"))
+	}
+	buf.WriteString("; #@! TF.GenerationSoftware,apparentlymart,go-kicad\n")
+	fmt.Fprintf(&buf, "; #@! TF.FileFunction,%s\n", f.FileFunction())
+	buf.WriteString("FMAT,2\n")
+	buf.WriteString(ef.unitsHeader() + "\n")
+	for i := range f.Tools {
+		t := &f.Tools[i]
+		fmt.Fprintf(&buf, "; #@! TA.AperFunction,%s\n", f.AperFunction(t))
+		fmt.Fprintf(&buf, "T%dC%s\n", t.Number, ef.diameter(t.Diameter))
+	}
+	buf.WriteString("%\n")
+	buf.WriteString("G90\n")
+	buf.WriteString("G05\n")
+
+	current := 0
+	for i := range f.Holes {
+		hole := &f.Holes[i]
+		t := f.Tool(hole)
+		if t == nil {
+			return fmt.Errorf("drill file has no tool for the %gmm hole at %g,%g", hole.Diameter, hole.Start.X, hole.Start.Y)
+		}
+		if t.Number != current {
+			fmt.Fprintf(&buf, "T%d\n", t.Number)
+			current = t.Number
+		}
+		switch {
+		case !hole.IsSlot():
+			buf.WriteString(ef.coord(hole.Start) + "\n")
+		case opts.G85Slots:
+			buf.WriteString(ef.coord(hole.Start) + "G85" + ef.coord(hole.End) + "\n")
+		default:
+			buf.WriteString("G00" + ef.coord(hole.Start) + "\n")
+			buf.WriteString("M15\n")
+			buf.WriteString("G01" + ef.coord(hole.End) + "\n")
+			buf.WriteString("M16\n")
+			buf.WriteString("G05\n")
+		}
+	}
+	buf.WriteString("M30\n")
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// WriteFiles writes each of the drill files returned by Files into the
+// given directory, naming each as File.Name does, along with a drill
+// report named with the suffix "-drl.rpt". It returns the paths of the
+// files it wrote.
+func WriteFiles(dir, base string, pcb *kicad.PCB, opts *Options) ([]string, error) {
+	files := Files(pcb)
+	var ret []string
+	for _, f := range files {
+		filename := filepath.Join(dir, f.Name(base))
+		err := fileio.WriteFile(filename, func(w io.Writer) error {
+			return Write(w, pcb, f, opts)
+		})
+		if err != nil {
+			return ret, err
+		}
+		ret = append(ret, filename)
+	}
+
+	filename := filepath.Join(dir, base+"-drl.rpt")
+	err := fileio.WriteFile(filename, func(w io.Writer) error {
+		return WriteReport(w, pcb, base, files, opts)
+	})
+	if err != nil {
+		return ret, err
+	}
+	return append(ret, filename), nil
+}
+
+// excellonFormat formats numbers for a drill file with particular options.
+type excellonFormat struct {
+	opts   *Options
+	origin kicad.Position
+}
+
+// intDigits and fracDigits give the digits of the integer and fractional
+// parts of coordinates in the non-decimal formats.
+func (ef excellonFormat) intDigits() int {
+	if ef.opts.Units == Inches {
+		return 2
+	}
+	return 3
+}
+
+func (ef excellonFormat) fracDigits() int {
+	if ef.opts.Units == Inches {
+		return 4
+	}
+	return 3
+}
+
+func (ef excellonFormat) digits() string {
+	if ef.opts.Zeros == Decimal {
+		return "-:-"
+	}
+	return fmt.Sprintf("%d:%d", ef.intDigits(), ef.fracDigits())
+}
+
+func (ef excellonFormat) unitsName() string {
+	if ef.opts.Units == Inches {
+		return "inch"
+	}
+	return "metric"
+}
+
+func (ef excellonFormat) zerosName() string {
+	switch ef.opts.Zeros {
+	case SuppressLeading:
+		return "suppress leading zeros"
+	case SuppressTrailing:
+		return "suppress trailing zeros"
+	case KeepZeros:
+		return "keep zeros"
+	default:
+		return "decimal"
+	}
+}
+
+// unitsHeader returns the header command selecting the units, which for
+// the integer formats also says which zeros are present: "TZ" means
+// trailing zeros are present, and "LZ" that leading zeros are.
+func (ef excellonFormat) unitsHeader() string {
+	units := "METRIC"
+	if ef.opts.Units == Inches {
+		units = "INCH"
+	}
+	switch ef.opts.Zeros {
+	case SuppressLeading, KeepZeros:
+		return units + ",TZ"
+	case SuppressTrailing:
+		return units + ",LZ"
+	default:
+		return units
+	}
+}
+
+// scale converts a length in millimeters to the file's units.
+func (ef excellonFormat) scale(v float64) float64 {
+	if ef.opts.Units == Inches {
+		return v / 25.4
+	}
+	return v
+}
+
+// diameter formats a tool diameter, which is always given with a decimal
+// point.
+func (ef excellonFormat) diameter(v float64) string {
+	return strconv.FormatFloat(ef.scale(v), 'f', ef.fracDigits(), 64)
+}
+
+// coord formats a position in document coordinates, relative to the origin
+// of the file and with Y increasing upwards.
+func (ef excellonFormat) coord(p kicad.Position) string {
+	return "X" + ef.number(p.X-ef.origin.X) + "Y" + ef.number(ef.origin.Y-p.Y)
+}
+
+func (ef excellonFormat) number(v float64) string {
+	frac := ef.fracDigits()
+	n := int64(math.Round(ef.scale(v) * math.Pow10(frac)))
+	sign := ""
+	if n < 0 {
+		sign = "-"
+		n = -n
+	}
+
+	if ef.opts.Zeros == Decimal {
+		s := strconv.FormatInt(n, 10)
+		if len(s) <= frac {
+			s = strings.Repeat("0", frac-len(s)+1) + s
+		}
+		intPart, fracPart := s[:len(s)-frac], strings.TrimRight(s[len(s)-frac:], "0")
+		if fracPart == "" {
+			fracPart = "0"
+		}
+		return sign + intPart + "." + fracPart
+	}
+
+	s := fmt.Sprintf("%0*d", ef.intDigits()+frac, n)
+	switch ef.opts.Zeros {
+	case SuppressLeading:
+		s = strings.TrimLeft(s, "0")
+	case SuppressTrailing:
+		s = strings.TrimRight(s, "0")
+	}
+	if s == "" {
+		return "0"
+	}
+	return sign + s
+}
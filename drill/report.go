@@ -0,0 +1,97 @@
+package drill
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/apparentlymart/go-kicad"
+)
+
+// WriteReport writes a drill report, which lists the copper layers of the
+// given board and the tools used by each of the given drill files, in the
+// same form as KiCad's own drill report. The base name of the board file
+// is used to name the drill files in the report.
+//
+// The report always gives tool diameters in both millimeters and inches,
+// so the units chosen in opts don't affect it.
+func WriteReport(w io.Writer, pcb *kicad.PCB, base string, files []*File, opts *Options) error {
+	if opts == nil {
+		opts = &Options{}
+	}
+	const rule = "    =============================================================\n"
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "Drill report for %s.kicad_pcb\n", base)
+	if !opts.CreationDate.IsZero() {
+		fmt.Fprintf(&buf, "Created on %s\n", opts.CreationDate.Format("2006-01-02T15:04:05-07:00"))
+	}
+
+	buf.WriteString("\nCopper Layer Stackup:\n")
+	buf.WriteString(rule)
+	copper := pcb.CopperLayers()
+	for i, name := range copper {
+		fmt.Fprintf(&buf, "    L%d :  %-25s %s\n", i+1, name, stackupLayerName(pcb, name))
+	}
+
+	for _, f := range files {
+		fmt.Fprintf(&buf, "\n\nDrill file '%s' contains\n", f.Name(base))
+		switch {
+		case !f.Plated:
+			buf.WriteString("    unplated through holes:\n")
+		case f.Through:
+			buf.WriteString("    plated through holes:\n")
+		default:
+			kind := "buried via"
+			if f.topIndex == 1 || f.bottomIndex == f.Layers {
+				kind = "blind via"
+			}
+			fmt.Fprintf(&buf, "    holes connecting layer pair: '%s and %s' (%s)\n", f.Pair.Top, f.Pair.Bottom, kind)
+		}
+		buf.WriteString(rule)
+
+		total := 0
+		for _, t := range f.Tools {
+			fmt.Fprintf(&buf, "    T%d  %.2fmm  %.3f\"  (%s)", t.Number, t.Diameter, t.Diameter/25.4, plural(t.Holes, "hole"))
+			if t.Slots > 0 {
+				fmt.Fprintf(&buf, "  (with %s)", plural(t.Slots, "slot"))
+			}
+			buf.WriteString("\n")
+			total += t.Holes
+		}
+		plated := "plated"
+		if !f.Plated {
+			plated = "unplated"
+		}
+		fmt.Fprintf(&buf, "\n    Total %s holes count %d\n", plated, total)
+	}
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// stackupLayerName returns the name the user gave to the given layer,
+// or else a description of its position in the board.
+func stackupLayerName(pcb *kicad.PCB, name string) string {
+	for _, layer := range pcb.Layers {
+		if layer.Name == name && layer.UserName != "" {
+			return layer.UserName
+		}
+	}
+	switch name {
+	case "F.Cu":
+		return "front"
+	case "B.Cu":
+		return "back"
+	default:
+		return "inner" + strings.TrimSuffix(strings.TrimPrefix(name, "In"), ".Cu")
+	}
+}
+
+func plural(n int, noun string) string {
+	if n == 1 {
+		return "1 " + noun
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
@@ -0,0 +1,97 @@
+// Package dxf converts between the graphic items of a KiCad board and DXF
+// drawings, the format mechanical CAD tools use to exchange board outlines
+// and enclosure drawings.
+//
+// Read converts the LINE, ARC, CIRCLE, LWPOLYLINE and SPLINE entities of a
+// drawing into graphic items on a board layer. Other entities, such as text,
+// dimensions and block references, are ignored. Write exports the graphic
+// items on chosen layers of a board, such as Edge.Cuts and the user layers,
+// as an AutoCAD R12 drawing that almost any CAD tool can read.
+//
+// DXF drawings have the Y axis increasing upwards, so their Y coordinates
+// are negated in both directions.
+package dxf
+
+import (
+	"math"
+	"strconv"
+)
+
+// Units selects the units of the coordinates in a DXF drawing.
+type Units int
+
+const (
+	// DefaultUnits uses the units recorded in a drawing's header when
+	// reading, or millimeters if it records none. Drawings are written
+	// in millimeters.
+	DefaultUnits Units = iota
+
+	Millimeters
+	Centimeters
+	Meters
+	Inches
+	Mils
+)
+
+// millimeters returns the length of one unit in millimeters.
+func (u Units) millimeters() float64 {
+	switch u {
+	case Centimeters:
+		return 10
+	case Meters:
+		return 1000
+	case Inches:
+		return 25.4
+	case Mils:
+		return 0.0254
+	default:
+		return 1
+	}
+}
+
+// insUnits returns the value of the $INSUNITS header variable for the
+// units.
+func (u Units) insUnits() int {
+	switch u {
+	case Centimeters:
+		return 5
+	case Meters:
+		return 6
+	case Inches:
+		return 1
+	case Mils:
+		return 9
+	default:
+		return 4
+	}
+}
+
+// unitsFromInsUnits returns the units for the given value of the
+// $INSUNITS header variable, or DefaultUnits for values that don't
+// correspond to any of the supported units, including 0 for unitless
+// drawings.
+func unitsFromInsUnits(v int) Units {
+	switch v {
+	case 1:
+		return Inches
+	case 4:
+		return Millimeters
+	case 5:
+		return Centimeters
+	case 6:
+		return Meters
+	case 9:
+		return Mils
+	}
+	return DefaultUnits
+}
+
+// num formats a coordinate, rounded to avoid noise in the last digits from
+// converting between units.
+func num(v float64) string {
+	v = math.Round(v*1e6) / 1e6
+	if v == 0 {
+		v = 0 // avoid "-0"
+	}
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
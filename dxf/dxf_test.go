@@ -0,0 +1,406 @@
+package dxf
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/apparentlymart/go-kicad"
+)
+
+// testDrawing returns a DXF drawing with the given header variables and
+// entities, each given as alternating group codes and values separated by
+// spaces.
+func testDrawing(header, entities string) string {
+	var buf strings.Builder
+	pairs := func(s string) {
+		fields := strings.Fields(s)
+		for i := 0; i+1 < len(fields); i += 2 {
+			fmt.Fprintf(&buf, "%3s\n%s\n", fields[i], fields[i+1])
+		}
+	}
+	pairs("0 SECTION 2 HEADER " + header + " 0 ENDSEC")
+	pairs("0 SECTION 2 ENTITIES " + entities + " 0 ENDSEC 0 EOF")
+	return buf.String()
+}
+
+// summary describes the given graphic items one per line, with rounded
+// coordinates.
+func summary(g *Graphics) string {
+	var buf strings.Builder
+	pos := func(p kicad.Position) string {
+		return num(math.Round(p.X*1e4)/1e4) + "," + num(math.Round(p.Y*1e4)/1e4)
+	}
+	for _, l := range g.Lines {
+		fmt.Fprintf(&buf, "line %s %s %s %s\n", l.Layer, pos(l.Start), pos(l.End), num(l.Stroke.Width))
+	}
+	for _, a := range g.Arcs {
+		fmt.Fprintf(&buf, "arc %s %s %s %s %s\n", a.Layer, pos(a.Start), pos(a.Mid), pos(a.End), num(a.Stroke.Width))
+	}
+	for _, c := range g.Circles {
+		fmt.Fprintf(&buf, "circle %s %s %s\n", c.Layer, pos(c.Center), pos(c.End))
+	}
+	for _, c := range g.Curves {
+		fmt.Fprintf(&buf, "curve %s", c.Layer)
+		for _, p := range c.Points.Polyline() {
+			fmt.Fprintf(&buf, " %s", pos(p))
+		}
+		buf.WriteString("\n")
+	}
+	return buf.String()
+}
+
+func TestRead(t *testing.T) {
+	src := testDrawing("", `
+		0 LINE 8 0 10 0 20 0 11 10 21 5
+		0 ARC 8 0 10 0 20 0 40 10 50 0 51 90
+		0 ARC 8 0 10 0 20 0 40 10 50 270 51 0
+		0 CIRCLE 8 0 10 5 20 5 40 2
+		0 LWPOLYLINE 8 0 90 3 70 1 43 0.2
+			10 0 20 0
+			10 10 20 0 42 1
+			10 10 20 10
+		0 TEXT 8 0 10 0 20 0 1 ignored
+	`)
+	g, err := Read(strings.NewReader(src), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := summary(g)
+	want := `line Edge.Cuts 0,0 10,-5 0.1
+line Edge.Cuts 0,0 10,0 0.2
+line Edge.Cuts 10,-10 0,0 0.2
+arc Edge.Cuts 10,0 7.0711,-7.0711 0,-10 0.1
+arc Edge.Cuts 0,10 7.0711,7.0711 10,0 0.1
+arc Edge.Cuts 10,0 15,-5 10,-10 0.2
+circle Edge.Cuts 5,-5 7,-5
+`
+	if got != want {
+		t.Errorf("incorrect result\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestRead_options(t *testing.T) {
+	src := testDrawing("9 $INSUNITS 70 1", `
+		0 LINE 8 0 10 0 20 0 11 1 21 1
+	`)
+
+	g, err := Read(strings.NewReader(src), &ReadOptions{
+		Layer:     "User.1",
+		Origin:    kicad.Position{X: 100, Y: 100},
+		LineWidth: 0.05,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := summary(g)
+	want := "line User.1 100,100 125.4,74.6 0.05\n"
+	if got != want {
+		t.Errorf("incorrect result with units from header\ngot:\n%s\nwant:\n%s", got, want)
+	}
+
+	g, err = Read(strings.NewReader(src), &ReadOptions{Units: Centimeters})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got = summary(g)
+	want = "line Edge.Cuts 0,0 10,-10 0.1\n"
+	if got != want {
+		t.Errorf("incorrect result with units overridden\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestRead_spline(t *testing.T) {
+	// A clamped cubic spline made of three Bézier curves.
+	src := testDrawing("", `
+		0 SPLINE 8 0 70 8 71 3 72 10 73 6
+			40 0 40 0 40 0 40 0 40 1 40 2 40 3 40 3 40 3 40 3
+			10 0 20 0
+			10 1 20 2
+			10 3 20 3
+			10 4 20 0
+			10 6 20 -1
+			10 7 20 1
+	`)
+	g, err := Read(strings.NewReader(src), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(g.Curves) != 3 {
+		t.Fatalf("got %d curves; want 3\n%s", len(g.Curves), summary(g))
+	}
+
+	knots := []float64{0, 0, 0, 0, 1, 2, 3, 3, 3, 3}
+	ctrl := []kicad.Position{{X: 0, Y: 0}, {X: 1, Y: 2}, {X: 3, Y: 3}, {X: 4, Y: 0}, {X: 6, Y: -1}, {X: 7, Y: 1}}
+	for i, curve := range g.Curves {
+		for _, tt := range []float64{0, 0.25, 0.5, 1} {
+			want := deBoor(3, knots, ctrl, float64(i)+tt)
+			want.Y = -want.Y
+			got := bezierAt(curve.Points.Polyline(), tt)
+			if kicad.Distance(got, want) > 1e-9 {
+				t.Errorf("curve %d at %g is %v; want %v", i, tt, got, want)
+			}
+		}
+	}
+}
+
+func bezierAt(ctrl []kicad.Position, t float64) kicad.Position {
+	u := 1 - t
+	a, b, c, d := u*u*u, 3*u*u*t, 3*u*t*t, t*t*t
+	return kicad.Position{
+		X: a*ctrl[0].X + b*ctrl[1].X + c*ctrl[2].X + d*ctrl[3].X,
+		Y: a*ctrl[0].Y + b*ctrl[1].Y + c*ctrl[2].Y + d*ctrl[3].Y,
+	}
+}
+
+func TestRead_errors(t *testing.T) {
+	tests := map[string]string{
+		"not a number":   "  0\nSECTION\n  2\nENTITIES\n  0\nLINE\n 10\nabc\n",
+		"no value":       "  0\nSECTION\n  2\n",
+		"bad group code": "x\nSECTION\n",
+		"binary":         "AutoCAD Binary DXF\r\n",
+	}
+	for name, src := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := Read(strings.NewReader(src), nil)
+			if err == nil {
+				t.Fatal("no error")
+			}
+		})
+	}
+}
+
+func TestRead_blankLines(t *testing.T) {
+	src := testDrawing("", "0 LINE 8 0 10 0 20 0 11 1 21 0") + "\n\n"
+	g, err := Read(strings.NewReader(src), nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got, want := len(g.Lines), 1; got != want {
+		t.Errorf("wrong number of lines %d; want %d", got, want)
+	}
+
+	// The blank line is skipped, so the error is at the value that then
+	// appears in place of a group code.
+	_, err = Read(strings.NewReader("  0\nSECTION\n\nENTITIES\n"), nil)
+	if err == nil {
+		t.Fatal("no error")
+	}
+	if got, want := err.Error(), "line 4: "; !strings.HasPrefix(got, want) {
+		t.Errorf("wrong error %q; want prefix %q", got, want)
+	}
+}
+
+const testBoardSrc = `(kicad_pcb (version 20240108) (generator pcbnew)
+  (setup (aux_axis_origin 100 100))
+  (footprint "MountingHole:MountingHole_3.2mm" (layer "F.Cu")
+    (at 110 90 90)
+    (fp_line (start 0 0) (end 2 0) (layer "Edge.Cuts") (stroke (width 0.1)))
+    (fp_line (start 0 0) (end 0 2) (layer "F.SilkS") (stroke (width 0.1)))
+  )
+  (gr_line (start 100 100) (end 130 100) (layer "Edge.Cuts") (stroke (width 0.1)))
+  (gr_arc (start 130 100) (mid 135 95) (end 140 100) (layer "Edge.Cuts") (stroke (width 0.1)))
+  (gr_circle (center 120 90) (end 121.5 90) (layer "User.1") (stroke (width 0.1)))
+  (gr_rect (start 100 80) (end 102 81) (layer "User.1") (stroke (width 0.1)))
+)
+`
+
+func TestWrite(t *testing.T) {
+	pcb, err := kicad.ReadPCB(strings.NewReader(testBoardSrc))
+	if err != nil {
+		t.Fatalf("reading board: %s", err)
+	}
+
+	var buf bytes.Buffer
+	err = Write(&buf, pcb, &WriteOptions{Layers: []string{"Edge.Cuts", "User.1"}, UseAuxOrigin: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := buf.String()
+	want := testDrawingHeader + `  0
+SECTION
+  2
+ENTITIES
+  0
+LINE
+  8
+Edge_Cuts
+ 10
+0
+ 20
+0
+ 11
+30
+ 21
+0
+  0
+ARC
+  8
+Edge_Cuts
+ 10
+35
+ 20
+0
+ 40
+5
+ 50
+0
+ 51
+180
+  0
+CIRCLE
+  8
+User_1
+ 10
+20
+ 20
+10
+ 40
+1.5
+  0
+LINE
+  8
+User_1
+ 10
+0
+ 20
+20
+ 11
+2
+ 21
+20
+  0
+LINE
+  8
+User_1
+ 10
+2
+ 20
+20
+ 11
+2
+ 21
+19
+  0
+LINE
+  8
+User_1
+ 10
+2
+ 20
+19
+ 11
+0
+ 21
+19
+  0
+LINE
+  8
+User_1
+ 10
+0
+ 20
+19
+ 11
+0
+ 21
+20
+  0
+LINE
+  8
+Edge_Cuts
+ 10
+10
+ 20
+10
+ 11
+10
+ 21
+12
+  0
+ENDSEC
+  0
+EOF
+`
+	if got != want {
+		t.Errorf("incorrect result\ngot:\n%s\nwant:\n%s", got, want)
+	}
+
+	// The drawing must read back as the same items.
+	g, err := Read(strings.NewReader(got), &ReadOptions{Origin: pcb.Setup.AuxAxisOrigin})
+	if err != nil {
+		t.Fatal(err)
+	}
+	gotItems := summary(g)
+	wantItems := `line Edge.Cuts 100,100 130,100 0.1
+line Edge.Cuts 100,80 102,80 0.1
+line Edge.Cuts 102,80 102,81 0.1
+line Edge.Cuts 102,81 100,81 0.1
+line Edge.Cuts 100,81 100,80 0.1
+line Edge.Cuts 110,90 110,88 0.1
+arc Edge.Cuts 140,100 135,95 130,100 0.1
+circle Edge.Cuts 120,90 121.5,90
+`
+	if gotItems != wantItems {
+		t.Errorf("incorrect result reading back\ngot:\n%s\nwant:\n%s", gotItems, wantItems)
+	}
+}
+
+const testDrawingHeader = `  0
+SECTION
+  2
+HEADER
+  9
+$ACADVER
+  1
+AC1009
+  9
+$INSUNITS
+ 70
+4
+  0
+ENDSEC
+  0
+SECTION
+  2
+TABLES
+  0
+TABLE
+  2
+LAYER
+ 70
+2
+  0
+LAYER
+  2
+Edge_Cuts
+ 70
+0
+ 62
+7
+  6
+CONTINUOUS
+  0
+LAYER
+  2
+User_1
+ 70
+0
+ 62
+7
+  6
+CONTINUOUS
+  0
+ENDTAB
+  0
+ENDSEC
+`
+
+func TestFilename(t *testing.T) {
+	if got, want := Filename("widget", "Edge.Cuts"), "widget-Edge_Cuts.dxf"; got != want {
+		t.Errorf("wrong filename %q; want %q", got, want)
+	}
+}
@@ -0,0 +1,514 @@
+package dxf
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/apparentlymart/go-kicad"
+)
+
+// ReadOptions customizes how Read converts a drawing. A nil *ReadOptions is
+// equivalent to a pointer to the zero value, which puts the items on
+// Edge.Cuts with the origin of the drawing at the origin of the board.
+type ReadOptions struct {
+	// Layer is the board layer to put the items on. If it is empty then
+	// the items are put on Edge.Cuts.
+	Layer string
+
+	// Units overrides the units recorded in the drawing's header, for
+	// drawings that record none or record the wrong ones.
+	Units Units
+
+	// Origin is the position on the board that the origin of the drawing
+	// is placed at.
+	Origin kicad.Position
+
+	// LineWidth is the width of the items, in millimeters. If it is zero
+	// then DefaultLineWidth is used. Polylines that give a width of their
+	// own keep it.
+	LineWidth float64
+}
+
+// DefaultLineWidth is the width in millimeters that Read gives to items
+// when ReadOptions doesn't give one.
+const DefaultLineWidth = 0.1
+
+// Graphics are the graphic items converted from a drawing, ready to be
+// added to a board.
+type Graphics struct {
+	Lines   []kicad.GraphicLine
+	Arcs    []kicad.GraphicArc
+	Circles []kicad.GraphicCircle
+	Curves  []kicad.GraphicCurve
+}
+
+// AddTo adds the items to the given board.
+func (g *Graphics) AddTo(pcb *kicad.PCB) {
+	pcb.GraphicLines = append(pcb.GraphicLines, g.Lines...)
+	pcb.GraphicArcs = append(pcb.GraphicArcs, g.Arcs...)
+	pcb.GraphicCircles = append(pcb.GraphicCircles, g.Circles...)
+	pcb.GraphicCurves = append(pcb.GraphicCurves, g.Curves...)
+}
+
+// Read reads an ASCII DXF drawing from the given reader and converts its
+// entities into graphic items.
+//
+// Polylines become lines and arcs, one for each of their segments. Cubic
+// and quadratic splines become Bézier curves, while other splines are
+// approximated by lines. The weights of rational splines are ignored.
+func Read(r io.Reader, opts *ReadOptions) (*Graphics, error) {
+	if opts == nil {
+		opts = &ReadOptions{}
+	}
+	pairs, err := readPairs(r)
+	if err != nil {
+		return nil, err
+	}
+
+	units := opts.Units
+	var entities []*entity
+	section := ""
+	for i := 0; i < len(pairs); i++ {
+		p := pairs[i]
+		switch {
+		case p.code == 0 && p.value == "SECTION":
+			if i+1 < len(pairs) && pairs[i+1].code == 2 {
+				section = pairs[i+1].value
+				i++
+			}
+		case p.code == 0 && p.value == "ENDSEC":
+			section = ""
+		case section == "HEADER" && p.code == 9 && p.value == "$INSUNITS":
+			if i+1 < len(pairs) && units == DefaultUnits {
+				v, err := strconv.Atoi(pairs[i+1].value)
+				if err != nil {
+					return nil, pairs[i+1].errorf("invalid $INSUNITS value %q", pairs[i+1].value)
+				}
+				units = unitsFromInsUnits(v)
+				i++
+			}
+		case section == "ENTITIES" && p.code == 0:
+			e := &entity{kind: p.value, line: p.line}
+			for i+1 < len(pairs) && pairs[i+1].code != 0 {
+				i++
+				e.pairs = append(e.pairs, pairs[i])
+			}
+			entities = append(entities, e)
+		}
+	}
+
+	c := &converter{
+		g:     &Graphics{},
+		layer: opts.Layer,
+		scale: units.millimeters(),
+		width: opts.LineWidth,
+		opts:  opts,
+	}
+	if c.layer == "" {
+		c.layer = "Edge.Cuts"
+	}
+	if c.width <= 0 {
+		c.width = DefaultLineWidth
+	}
+	for _, e := range entities {
+		var err error
+		switch e.kind {
+		case "LINE":
+			err = c.lineEntity(e)
+		case "ARC":
+			err = c.arcEntity(e)
+		case "CIRCLE":
+			err = c.circleEntity(e)
+		case "LWPOLYLINE":
+			err = c.polylineEntity(e)
+		case "SPLINE":
+			err = c.splineEntity(e)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return c.g, nil
+}
+
+// ReadFile reads the named DXF file, as Read does.
+func ReadFile(filename string, opts *ReadOptions) (*Graphics, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Read(f, opts)
+}
+
+// pair is a group code and its value, which together are the basic unit
+// of a DXF file.
+type pair struct {
+	code  int
+	value string
+	line  int
+}
+
+func (p pair) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("line %d: %s", p.line, fmt.Sprintf(format, args...))
+}
+
+// readPairs reads all of the group code pairs of a DXF file. Each pair is
+// two lines: the group code, and then its value.
+func readPairs(r io.Reader) ([]pair, error) {
+	s := bufio.NewScanner(r)
+	s.Buffer(nil, 1024*1024)
+	var ret []pair
+	line := 0
+	for s.Scan() {
+		line++
+		codeText := strings.TrimSpace(s.Text())
+		if line == 1 && strings.HasPrefix(codeText, "AutoCAD Binary DXF") {
+			return nil, fmt.Errorf("binary DXF files are not supported")
+		}
+		if codeText == "" {
+			// Blank lines can only be values, so one in place of a group
+			// code is an extra line, such as at the end of the file.
+			continue
+		}
+		code, err := strconv.Atoi(codeText)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid group code %q", line, codeText)
+		}
+		if !s.Scan() {
+			return nil, fmt.Errorf("line %d: group code %d has no value", line, code)
+		}
+		line++
+		p := pair{code: code, value: strings.TrimSpace(strings.TrimRight(s.Text(), "\r")), line: line}
+		ret = append(ret, p)
+		if code == 0 && p.value == "EOF" {
+			break
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// entity is an entity of a drawing, with the group code pairs that follow
+// its type.
+type entity struct {
+	kind  string
+	line  int
+	pairs []pair
+}
+
+// float returns the value of the first pair with the given group code as
+// a number, or zero if there is none.
+func (e *entity) float(code int) (float64, error) {
+	for _, p := range e.pairs {
+		if p.code == code {
+			return parseFloat(p)
+		}
+	}
+	return 0, nil
+}
+
+// floats returns the values of the pairs with the given group codes as
+// numbers, in the same order as the codes.
+func (e *entity) floats(codes ...int) ([]float64, error) {
+	ret := make([]float64, len(codes))
+	for i, code := range codes {
+		v, err := e.float(code)
+		if err != nil {
+			return nil, err
+		}
+		ret[i] = v
+	}
+	return ret, nil
+}
+
+func parseFloat(p pair) (float64, error) {
+	v, err := strconv.ParseFloat(p.value, 64)
+	if err != nil {
+		return 0, p.errorf("invalid number %q for group code %d", p.value, p.code)
+	}
+	return v, nil
+}
+
+// converter converts the entities of a drawing into graphic items.
+type converter struct {
+	g     *Graphics
+	layer string
+	scale float64
+	width float64
+	opts  *ReadOptions
+}
+
+// point converts a point of the drawing into board coordinates.
+func (c *converter) point(x, y float64) kicad.Position {
+	return kicad.Position{
+		X: c.opts.Origin.X + x*c.scale,
+		Y: c.opts.Origin.Y - y*c.scale,
+	}
+}
+
+func (c *converter) stroke(width float64) kicad.Stroke {
+	if width <= 0 {
+		width = c.width
+	}
+	return kicad.Stroke{Width: width, Type: "default"}
+}
+
+func (c *converter) addLine(start, end kicad.Position, width float64) {
+	c.g.Lines = append(c.g.Lines, kicad.GraphicLine{
+		Start:  start,
+		End:    end,
+		Layer:  c.layer,
+		Stroke: c.stroke(width),
+	})
+}
+
+func (c *converter) addArc(start, mid, end kicad.Position, width float64) {
+	c.g.Arcs = append(c.g.Arcs, kicad.GraphicArc{
+		Start:  start,
+		Mid:    mid,
+		End:    end,
+		Layer:  c.layer,
+		Stroke: c.stroke(width),
+	})
+}
+
+func (c *converter) lineEntity(e *entity) error {
+	v, err := e.floats(10, 20, 11, 21)
+	if err != nil {
+		return err
+	}
+	c.addLine(c.point(v[0], v[1]), c.point(v[2], v[3]), 0)
+	return nil
+}
+
+// ocs returns a function converting points in the object coordinate system
+// of the given entity into points in the drawing. Entities drawn in the XY
+// plane have the same coordinates in both, unless they are seen from below,
+// when their X coordinates are negated.
+func ocs(e *entity) (func(x, y float64) (float64, float64), error) {
+	z, err := e.float(230)
+	if err != nil {
+		return nil, err
+	}
+	return func(x, y float64) (float64, float64) {
+		if z < 0 {
+			return -x, y
+		}
+		return x, y
+	}, nil
+}
+
+func (c *converter) arcEntity(e *entity) error {
+	v, err := e.floats(10, 20, 40, 50, 51)
+	if err != nil {
+		return err
+	}
+	tr, err := ocs(e)
+	if err != nil {
+		return err
+	}
+	cx, cy, r := v[0], v[1], v[2]
+	a0, a1 := v[3]*math.Pi/180, v[4]*math.Pi/180
+
+	// Arcs run counterclockwise from their start angle to their end
+	// angle.
+	sweep := a1 - a0
+	for sweep <= 0 {
+		sweep += 2 * math.Pi
+	}
+	at := func(a float64) kicad.Position {
+		return c.point(tr(cx+r*math.Cos(a), cy+r*math.Sin(a)))
+	}
+	c.addArc(at(a0), at(a0+sweep/2), at(a0+sweep), 0)
+	return nil
+}
+
+func (c *converter) circleEntity(e *entity) error {
+	v, err := e.floats(10, 20, 40)
+	if err != nil {
+		return err
+	}
+	tr, err := ocs(e)
+	if err != nil {
+		return err
+	}
+	c.g.Circles = append(c.g.Circles, kicad.GraphicCircle{
+		Center: c.point(tr(v[0], v[1])),
+		End:    c.point(tr(v[0]+v[2], v[1])),
+		Layer:  c.layer,
+		Stroke: c.stroke(0),
+		Fill:   "none",
+	})
+	return nil
+}
+
+// polylineEntity converts a lightweight polyline, whose segments are each
+// either straight or an arc given by its bulge: the tangent of a quarter
+// of the angle the arc sweeps, which is positive for arcs running
+// counterclockwise.
+func (c *converter) polylineEntity(e *entity) error {
+	type vertex struct {
+		x, y, bulge float64
+	}
+	var vertices []vertex
+	closed := false
+	width := 0.0
+	for _, p := range e.pairs {
+		var v float64
+		switch p.code {
+		case 10, 20, 42, 43:
+			var err error
+			v, err = parseFloat(p)
+			if err != nil {
+				return err
+			}
+		}
+		switch p.code {
+		case 70:
+			flags, err := strconv.Atoi(p.value)
+			if err != nil {
+				return p.errorf("invalid flags %q", p.value)
+			}
+			closed = flags&1 != 0
+		case 43:
+			width = v * c.scale
+		case 10:
+			vertices = append(vertices, vertex{x: v})
+		case 20:
+			if len(vertices) > 0 {
+				vertices[len(vertices)-1].y = v
+			}
+		case 42:
+			if len(vertices) > 0 {
+				vertices[len(vertices)-1].bulge = v
+			}
+		}
+	}
+	tr, err := ocs(e)
+	if err != nil {
+		return err
+	}
+
+	n := len(vertices) - 1
+	if closed {
+		n = len(vertices)
+	}
+	for i := 0; i < n; i++ {
+		a, b := vertices[i], vertices[(i+1)%len(vertices)]
+		start, end := c.point(tr(a.x, a.y)), c.point(tr(b.x, b.y))
+		if a.bulge == 0 || (a.x == b.x && a.y == b.y) {
+			c.addLine(start, end, width)
+			continue
+		}
+		// The middle of the arc is off the middle of the chord by the
+		// sagitta, to the right of the chord for a counterclockwise
+		// arc.
+		dx, dy := b.x-a.x, b.y-a.y
+		s := a.bulge / 2
+		mx, my := (a.x+b.x)/2+s*dy, (a.y+b.y)/2-s*dx
+		c.addArc(start, c.point(tr(mx, my)), end, width)
+	}
+	return nil
+}
+
+func (c *converter) splineEntity(e *entity) error {
+	var degree int
+	var knots []float64
+	var ctrl, fit []kicad.Position
+	for _, p := range e.pairs {
+		switch p.code {
+		case 71:
+			var err error
+			degree, err = strconv.Atoi(p.value)
+			if err != nil {
+				return p.errorf("invalid degree %q", p.value)
+			}
+		case 40, 10, 20, 11, 21:
+			v, err := parseFloat(p)
+			if err != nil {
+				return err
+			}
+			switch p.code {
+			case 40:
+				knots = append(knots, v)
+			case 10:
+				ctrl = append(ctrl, kicad.Position{X: v})
+			case 20:
+				if len(ctrl) > 0 {
+					ctrl[len(ctrl)-1].Y = v
+				}
+			case 11:
+				fit = append(fit, kicad.Position{X: v})
+			case 21:
+				if len(fit) > 0 {
+					fit[len(fit)-1].Y = v
+				}
+			}
+		}
+	}
+	tr, err := ocs(e)
+	if err != nil {
+		return err
+	}
+	toBoard := func(p kicad.Position) kicad.Position {
+		return c.point(tr(p.X, p.Y))
+	}
+
+	if len(ctrl) == 0 {
+		// A spline given only by the points it passes through is
+		// approximated by lines between them.
+		c.addPolyline(kicad.TransformPoints(fit, toBoard))
+		return nil
+	}
+	if len(knots) != len(ctrl)+degree+1 || degree < 1 {
+		return fmt.Errorf("line %d: spline has %d control points and %d knots, which don't match its degree %d", e.line, len(ctrl), len(knots), degree)
+	}
+
+	var segments [][]kicad.Position
+	ok := false
+	if degree <= 3 {
+		segments, ok = bezierSegments(degree, knots, ctrl)
+	}
+	if !ok {
+		c.addPolyline(kicad.TransformPoints(sampleSpline(degree, knots, ctrl), toBoard))
+		return nil
+	}
+	for _, seg := range segments {
+		seg = kicad.TransformPoints(seg, toBoard)
+		switch degree {
+		case 1:
+			c.addLine(seg[0], seg[1], 0)
+		case 2:
+			// Raise the quadratic curve to the equivalent cubic one.
+			q0, q1, q2 := seg[0], seg[1], seg[2]
+			seg = []kicad.Position{
+				q0,
+				{X: q0.X + 2*(q1.X-q0.X)/3, Y: q0.Y + 2*(q1.Y-q0.Y)/3},
+				{X: q2.X + 2*(q1.X-q2.X)/3, Y: q2.Y + 2*(q1.Y-q2.Y)/3},
+				q2,
+			}
+			fallthrough
+		case 3:
+			c.g.Curves = append(c.g.Curves, kicad.GraphicCurve{
+				Points: kicad.NewPoints(seg...),
+				Layer:  c.layer,
+				Stroke: c.stroke(0),
+			})
+		}
+	}
+	return nil
+}
+
+func (c *converter) addPolyline(points []kicad.Position) {
+	for i := 1; i < len(points); i++ {
+		c.addLine(points[i-1], points[i], 0)
+	}
+}
@@ -0,0 +1,121 @@
+package dxf
+
+import (
+	"github.com/apparentlymart/go-kicad"
+)
+
+// bezierSegments splits a B-spline of the given degree into the Bézier
+// curves it is made of, returning the control points of each. It returns
+// false if the spline is not clamped, meaning that it doesn't start and
+// end at its first and last control points, or if it has breaks where
+// interior knots are repeated more times than its degree.
+//
+// The curves are found by inserting knots until every interior knot is
+// repeated as many times as the degree of the spline.
+func bezierSegments(degree int, knots []float64, ctrl []kicad.Position) ([][]kicad.Position, bool) {
+	n := len(knots)
+	for i := 1; i <= degree; i++ {
+		if knots[i] != knots[0] || knots[n-1-i] != knots[n-1] {
+			return nil, false
+		}
+	}
+
+	knots = append([]float64(nil), knots...)
+	ctrl = append([]kicad.Position(nil), ctrl...)
+	for i := degree + 1; i < len(knots)-degree-1; {
+		u := knots[i]
+		mult := 0
+		for j := i; j < len(knots) && knots[j] == u; j++ {
+			mult++
+		}
+		if mult > degree {
+			return nil, false
+		}
+		for ; mult < degree; mult++ {
+			knots, ctrl = insertKnot(degree, knots, ctrl, u)
+		}
+		i += mult
+	}
+
+	var ret [][]kicad.Position
+	for i := 0; i+degree < len(ctrl); i += degree {
+		ret = append(ret, ctrl[i:i+degree+1])
+	}
+	return ret, true
+}
+
+// insertKnot inserts a knot into a B-spline without changing its shape,
+// returning the new knots and control points.
+func insertKnot(degree int, knots []float64, ctrl []kicad.Position, u float64) ([]float64, []kicad.Position) {
+	k := span(knots, u)
+	newCtrl := make([]kicad.Position, len(ctrl)+1)
+	for i := range newCtrl {
+		switch {
+		case i <= k-degree:
+			newCtrl[i] = ctrl[i]
+		case i > k:
+			newCtrl[i] = ctrl[i-1]
+		default:
+			a := (u - knots[i]) / (knots[i+degree] - knots[i])
+			newCtrl[i] = lerp(ctrl[i-1], ctrl[i], a)
+		}
+	}
+	newKnots := make([]float64, 0, len(knots)+1)
+	newKnots = append(newKnots, knots[:k+1]...)
+	newKnots = append(newKnots, u)
+	newKnots = append(newKnots, knots[k+1:]...)
+	return newKnots, newCtrl
+}
+
+// sampleSpline returns points along a B-spline of the given degree, for
+// approximating it by straight lines.
+func sampleSpline(degree int, knots []float64, ctrl []kicad.Position) []kicad.Position {
+	const samplesPerSpan = 16
+	start, end := knots[degree], knots[len(ctrl)]
+	count := samplesPerSpan * (len(ctrl) - degree)
+	ret := make([]kicad.Position, count+1)
+	for i := range ret {
+		u := start + (end-start)*float64(i)/float64(count)
+		ret[i] = deBoor(degree, knots, ctrl, u)
+	}
+	return ret
+}
+
+// deBoor returns the point of a B-spline at the given parameter.
+func deBoor(degree int, knots []float64, ctrl []kicad.Position, u float64) kicad.Position {
+	k := span(knots, u)
+	if k >= len(ctrl) {
+		k = len(ctrl) - 1
+	}
+	if k < degree {
+		k = degree
+	}
+	d := make([]kicad.Position, degree+1)
+	copy(d, ctrl[k-degree:k+1])
+	for r := 1; r <= degree; r++ {
+		for j := degree; j >= r; j-- {
+			lo, hi := knots[j+k-degree], knots[j+1+k-r]
+			a := 0.0
+			if hi != lo {
+				a = (u - lo) / (hi - lo)
+			}
+			d[j] = lerp(d[j-1], d[j], a)
+		}
+	}
+	return d[degree]
+}
+
+// span returns the index of the last knot that is no greater than u.
+func span(knots []float64, u float64) int {
+	k := 0
+	for i, t := range knots {
+		if t <= u {
+			k = i
+		}
+	}
+	return k
+}
+
+func lerp(a, b kicad.Position, t float64) kicad.Position {
+	return kicad.Position{X: a.X + (b.X-a.X)*t, Y: a.Y + (b.Y-a.Y)*t}
+}
@@ -0,0 +1,242 @@
+package dxf
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+
+	"github.com/apparentlymart/go-kicad"
+	"github.com/apparentlymart/go-kicad/internal/fileio"
+)
+
+// WriteOptions customizes the drawing that Write produces. A nil
+// *WriteOptions is equivalent to a pointer to the zero value.
+type WriteOptions struct {
+	// Layers are the names of the board layers whose graphic items are
+	// exported, each as a layer of the drawing with a similar name. If it
+	// is empty then only Edge.Cuts is exported.
+	Layers []string
+
+	// UseAuxOrigin places the origin of the drawing at the board's
+	// auxiliary axis origin, rather than at the origin of the board
+	// document.
+	UseAuxOrigin bool
+
+	// Units are the units of the coordinates in the drawing. The default
+	// is millimeters.
+	Units Units
+}
+
+// Write writes the graphic items of the given board that are on the chosen
+// layers to the given writer as a DXF drawing, including the items of
+// footprints.
+//
+// Lines, arcs and circles are written as the corresponding entities, and
+// rectangles and polygons as lines along their edges. Bézier curves are
+// approximated by lines. The widths and fills of items are not kept, and
+// text is not included.
+//
+// Boards from KiCad 5 describe arcs differently, and should be upgraded
+// using PCB.Upgrade before writing.
+func Write(w io.Writer, pcb *kicad.PCB, opts *WriteOptions) error {
+	if opts == nil {
+		opts = &WriteOptions{}
+	}
+	layers := opts.Layers
+	if len(layers) == 0 {
+		layers = []string{"Edge.Cuts"}
+	}
+	dw := &drawing{
+		layers: make(map[string]string),
+		scale:  opts.Units.millimeters(),
+	}
+	if opts.UseAuxOrigin {
+		dw.origin = pcb.Setup.AuxAxisOrigin
+	}
+
+	dw.section("HEADER")
+	dw.group(9, "$ACADVER")
+	dw.group(1, "AC1009")
+	dw.group(9, "$INSUNITS")
+	dw.group(70, fmt.Sprint(opts.Units.insUnits()))
+	dw.group(0, "ENDSEC")
+
+	dw.section("TABLES")
+	dw.group(0, "TABLE")
+	dw.group(2, "LAYER")
+	dw.group(70, fmt.Sprint(len(layers)))
+	for _, l := range layers {
+		name := layerName(l)
+		dw.layers[l] = name
+		dw.group(0, "LAYER")
+		dw.group(2, name)
+		dw.group(70, "0")
+		dw.group(62, "7")
+		dw.group(6, "CONTINUOUS")
+	}
+	dw.group(0, "ENDTAB")
+	dw.group(0, "ENDSEC")
+
+	dw.section("ENTITIES")
+	dw.graphicItems(pcb.GraphicLines, pcb.GraphicArcs, pcb.GraphicCircles, pcb.GraphicRects, pcb.GraphicPolys, pcb.GraphicCurves, nil)
+	for i := range pcb.Footprints {
+		fp := &pcb.Footprints[i]
+		dw.graphicItems(fp.Lines, fp.Arcs, fp.Circles, fp.Rects, fp.Polys, fp.Curves, fp)
+	}
+	dw.group(0, "ENDSEC")
+	dw.group(0, "EOF")
+
+	_, err := w.Write(dw.buf.Bytes())
+	return err
+}
+
+// WriteFile writes the given board to the named file, as Write does.
+func WriteFile(filename string, pcb *kicad.PCB, opts *WriteOptions) error {
+	return fileio.WriteFile(filename, func(w io.Writer) error {
+		return Write(w, pcb, opts)
+	})
+}
+
+// Filename returns the name KiCad gives to the DXF drawing of a board
+// layer, given the base name of the board file without its extension.
+func Filename(base, layer string) string {
+	return base + "-" + strings.ReplaceAll(layer, ".", "_") + ".dxf"
+}
+
+// layerName returns the name of the drawing layer for a board layer. Names
+// of R12 layers may only contain letters, digits and the characters "_",
+// "-" and "$", so other characters are replaced by underscores.
+func layerName(layer string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'A' && r <= 'Z', r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			return r
+		case r == '_', r == '-', r == '$':
+			return r
+		}
+		return '_'
+	}, layer)
+}
+
+// drawing accumulates the group codes of a DXF drawing.
+type drawing struct {
+	buf    bytes.Buffer
+	origin kicad.Position
+	scale  float64
+
+	// layers maps the names of the exported board layers to the names of
+	// their drawing layers.
+	layers map[string]string
+}
+
+func (dw *drawing) group(code int, value string) {
+	fmt.Fprintf(&dw.buf, "%3d\n%s\n", code, value)
+}
+
+func (dw *drawing) section(name string) {
+	dw.group(0, "SECTION")
+	dw.group(2, name)
+}
+
+// point writes the coordinates of a board position with the given group
+// codes, relative to the drawing's origin and with Y increasing upwards.
+func (dw *drawing) point(xCode, yCode int, p kicad.Position) {
+	dw.group(xCode, num((p.X-dw.origin.X)/dw.scale))
+	dw.group(yCode, num((dw.origin.Y-p.Y)/dw.scale))
+}
+
+func (dw *drawing) entity(kind, layer string) {
+	dw.group(0, kind)
+	dw.group(8, dw.layers[layer])
+}
+
+func (dw *drawing) line(layer string, start, end kicad.Position) {
+	dw.entity("LINE", layer)
+	dw.point(10, 20, start)
+	dw.point(11, 21, end)
+}
+
+func (dw *drawing) polyline(layer string, points []kicad.Position) {
+	for i := 1; i < len(points); i++ {
+		dw.line(layer, points[i-1], points[i])
+	}
+}
+
+func (dw *drawing) circle(layer string, center kicad.Position, r float64) {
+	dw.entity("CIRCLE", layer)
+	dw.point(10, 20, center)
+	dw.group(40, num(r/dw.scale))
+}
+
+// arc writes the arc from start through mid to end, or a line if the
+// points are collinear.
+func (dw *drawing) arc(layer string, start, mid, end kicad.Position) {
+	center, ok := kicad.ArcCenter(start, mid, end)
+	if !ok {
+		dw.line(layer, start, end)
+		return
+	}
+	// DXF arcs run counterclockwise, so clockwise arcs are written from
+	// their end to their start.
+	if kicad.ArcSweep(center, start, mid, end) > 0 {
+		start, end = end, start
+	}
+	angle := func(p kicad.Position) string {
+		a := math.Atan2(center.Y-p.Y, p.X-center.X) * 180 / math.Pi
+		return num(kicad.NormalizeDegrees(a))
+	}
+	dw.entity("ARC", layer)
+	dw.point(10, 20, center)
+	dw.group(40, num(kicad.Distance(center, start)/dw.scale))
+	dw.group(50, angle(start))
+	dw.group(51, angle(end))
+}
+
+// graphicItems writes the given graphic items that are on the exported
+// layers. If fp is not nil then the items belong to that footprint, and
+// their positions are relative to it.
+func (dw *drawing) graphicItems(lines []kicad.GraphicLine, arcs []kicad.GraphicArc, circles []kicad.GraphicCircle, rects []kicad.GraphicRect, polys []kicad.GraphicPoly, curves []kicad.GraphicCurve, fp *kicad.Footprint) {
+	tr := func(p kicad.Position) kicad.Position {
+		if fp == nil {
+			return p
+		}
+		return fp.BoardPosition(p)
+	}
+	exported := func(layer string) bool {
+		_, ok := dw.layers[layer]
+		return ok
+	}
+
+	for _, line := range lines {
+		if exported(line.Layer) {
+			dw.line(line.Layer, tr(line.Start), tr(line.End))
+		}
+	}
+	for _, arc := range arcs {
+		if exported(arc.Layer) {
+			dw.arc(arc.Layer, tr(arc.Start), tr(arc.Mid), tr(arc.End))
+		}
+	}
+	for _, circle := range circles {
+		if exported(circle.Layer) {
+			dw.circle(circle.Layer, tr(circle.Center), kicad.Distance(circle.Center, circle.End))
+		}
+	}
+	for _, rect := range rects {
+		if exported(rect.Layer) {
+			dw.polyline(rect.Layer, kicad.ClosePolyline(kicad.TransformPoints(kicad.RectPoints(rect.Start, rect.End), tr)))
+		}
+	}
+	for _, poly := range polys {
+		if exported(poly.Layer) {
+			dw.polyline(poly.Layer, kicad.ClosePolyline(kicad.TransformPoints(poly.Points.Polyline(), tr)))
+		}
+	}
+	for _, curve := range curves {
+		if exported(curve.Layer) {
+			dw.polyline(curve.Layer, kicad.TransformPoints(kicad.BezierPoints(curve.Points.Polyline()), tr))
+		}
+	}
+}
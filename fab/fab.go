@@ -0,0 +1,215 @@
+// Package fab produces the complete set of files that a manufacturer needs
+// to make and assemble a board from a KiCad project, packaged as a zip
+// archive.
+//
+// The package contains the Gerber files from package gerber, the drill
+// files and drill report from package drill, a Gerber job file describing
+// the other files along with the board's stackup and design rules, the
+// position files from package pos, a bill of materials from package bom
+// and an IPC-D-356A netlist from package ipc356.
+//
+// Archives are deterministic: the same project and options always give
+// byte-identical archives, so that they can be hashed to track releases.
+package fab
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/apparentlymart/go-kicad"
+	"github.com/apparentlymart/go-kicad/bom"
+	"github.com/apparentlymart/go-kicad/drill"
+	"github.com/apparentlymart/go-kicad/gerber"
+	"github.com/apparentlymart/go-kicad/internal/fileio"
+	"github.com/apparentlymart/go-kicad/ipc356"
+	"github.com/apparentlymart/go-kicad/pos"
+)
+
+// Options customizes the files of a fabrication package. A nil *Options is
+// equivalent to a pointer to the zero value.
+type Options struct {
+	// UseAuxOrigin places the origin of the Gerber, drill, position and
+	// netlist files at the board's auxiliary axis origin, rather than at
+	// the origin of the board document.
+	UseAuxOrigin bool
+
+	// CreationDate is recorded in the headers of the files and as the
+	// modification time of each file in the archive. If it is zero then no
+	// date is recorded in the files and the archive gives each file the
+	// earliest time a zip archive can represent, so that the output
+	// depends only on the project.
+	CreationDate time.Time
+
+	// BOM customizes the fields and grouping of the bill of materials.
+	BOM *bom.Options
+}
+
+// File is one of the files of a fabrication package.
+type File struct {
+	Name string
+	Data []byte
+}
+
+// Files returns the files of the fabrication package for the given
+// project, named after the project as KiCad names its own outputs.
+//
+// The bill of materials is built from the project's schematic, or from the
+// footprints of its board if it has no schematic. It is an error for the
+// project to have no board, or for its board to be older than KiCad 6:
+// such boards describe some items differently and must first be upgraded
+// using PCB.Upgrade.
+func Files(lp *kicad.LoadedProject, opts *Options) ([]File, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+	pcb := lp.PCB
+	if pcb == nil {
+		return nil, fmt.Errorf("project %s has no board", lp.Name)
+	}
+	if pcb.Version < kicad.PCBVersionKiCad6 {
+		return nil, fmt.Errorf("board of project %s has format version %d, older than KiCad 6 (%d); upgrade it using PCB.Upgrade", lp.Name, pcb.Version, kicad.PCBVersionKiCad6)
+	}
+	base := lp.Name
+
+	var ret []File
+	add := func(name string, write func(w io.Writer) error) error {
+		var buf bytes.Buffer
+		if err := write(&buf); err != nil {
+			return fmt.Errorf("generating %s: %w", name, err)
+		}
+		ret = append(ret, File{Name: name, Data: buf.Bytes()})
+		return nil
+	}
+
+	gerberOpts := &gerber.Options{UseAuxOrigin: opts.UseAuxOrigin, CreationDate: opts.CreationDate}
+	for _, layer := range gerber.Layers(pcb) {
+		err := add(gerber.Filename(base, layer), func(w io.Writer) error {
+			return gerber.WriteLayer(w, pcb, layer, gerberOpts)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	err := add(JobFilename(base), func(w io.Writer) error {
+		return WriteJob(w, lp, opts)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	drillOpts := &drill.Options{UseAuxOrigin: opts.UseAuxOrigin, CreationDate: opts.CreationDate}
+	drillFiles := drill.Files(pcb)
+	for _, f := range drillFiles {
+		err := add(f.Name(base), func(w io.Writer) error {
+			return drill.Write(w, pcb, f, drillOpts)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = add(base+"-drl.rpt", func(w io.Writer) error {
+		return drill.WriteReport(w, pcb, base, drillFiles, drillOpts)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	posOpts := &pos.Options{CreationDate: opts.CreationDate}
+	if opts.UseAuxOrigin {
+		posOpts.Origin = pos.AuxOrigin
+	}
+	for _, side := range []pos.Side{pos.Top, pos.Bottom} {
+		placements := pos.Placements(pcb, side, posOpts)
+		if len(placements) == 0 {
+			continue
+		}
+		err := add(pos.Filename(base, side, false), func(w io.Writer) error {
+			return pos.WriteCSV(w, placements, posOpts)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var lines []bom.Line
+	if lp.Schematic != nil {
+		lines = bom.FromHierarchy(lp.Schematic, opts.BOM)
+	} else {
+		lines = bom.FromBoard(pcb, opts.BOM)
+	}
+	err = add(base+"-bom.csv", func(w io.Writer) error {
+		return bom.WriteCSV(w, lines, opts.BOM)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = add(ipc356.Filename(base), func(w io.Writer) error {
+		return ipc356.Write(w, pcb, &ipc356.Options{UseAuxOrigin: opts.UseAuxOrigin, CreationDate: opts.CreationDate})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// zipEpoch is the earliest modification time that the MS-DOS timestamps
+// of a zip archive can represent.
+var zipEpoch = time.Date(1980, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// WriteZip writes the fabrication package for the given project to the
+// given writer as a zip archive, with the files in the order that Files
+// returns them.
+func WriteZip(w io.Writer, lp *kicad.LoadedProject, opts *Options) error {
+	if opts == nil {
+		opts = &Options{}
+	}
+	files, err := Files(lp, opts)
+	if err != nil {
+		return err
+	}
+	modified := opts.CreationDate
+	if modified.IsZero() {
+		modified = zipEpoch
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, f := range files {
+		fh := &zip.FileHeader{
+			Name:     f.Name,
+			Method:   zip.Deflate,
+			Modified: modified,
+		}
+		fh.SetMode(0644)
+		fw, err := zw.CreateHeader(fh)
+		if err != nil {
+			return err
+		}
+		if _, err := fw.Write(f.Data); err != nil {
+			return err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+// WriteZipFile writes the fabrication package for the given project to the
+// named file, as WriteZip does.
+func WriteZipFile(filename string, lp *kicad.LoadedProject, opts *Options) error {
+	return fileio.WriteFile(filename, func(w io.Writer) error {
+		return WriteZip(w, lp, opts)
+	})
+}
+
+// Filename returns the name of the archive holding the fabrication package
+// of a project, given the name of the project.
+func Filename(base string) string {
+	return base + "-fab.zip"
+}
@@ -0,0 +1,309 @@
+package fab
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apparentlymart/go-kicad"
+)
+
+const testBoardSrc = `(kicad_pcb (version 20240108) (generator pcbnew)
+  (general (thickness 1.6))
+  (title_block (title "Widget") (rev "B"))
+  (layers
+    (0 "F.Cu" signal)
+    (31 "B.Cu" signal)
+    (37 "F.SilkS" user "F.Silkscreen")
+    (38 "B.Mask" user)
+    (39 "F.Mask" user)
+    (44 "Edge.Cuts" user)
+  )
+  (setup
+    (stackup
+      (layer "F.SilkS" (type "Top Silk Screen") (color "White"))
+      (layer "F.Mask" (type "Top Solder Mask") (color "Green") (thickness 0.01))
+      (layer "F.Cu" (type "copper") (thickness 0.035))
+      (layer "dielectric 1" (type "core") (thickness 1.51) (material "FR4") (epsilon_r 4.5) (loss_tangent 0.02))
+      (layer "B.Cu" (type "copper") (thickness 0.035))
+      (layer "B.Mask" (type "Bottom Solder Mask") (color "Green") (thickness 0.01))
+      (copper_finish "ENIG")
+    )
+  )
+  (net 0 "")
+  (net 1 "GND")
+  (footprint "Resistor_SMD:R_0603" (layer "F.Cu")
+    (at 110 90)
+    (property "Reference" "R1")
+    (property "Value" "10k")
+    (attr smd)
+    (pad "1" smd rect (at -0.8 0) (size 0.8 0.95) (layers "F.Cu" "F.Paste" "F.Mask") (net 1 "GND"))
+    (pad "2" smd rect (at 0.8 0) (size 0.8 0.95) (layers "F.Cu" "F.Paste" "F.Mask"))
+  )
+  (footprint "Connector:Conn_01x01" (layer "B.Cu")
+    (at 120 90)
+    (property "Reference" "J1")
+    (property "Value" "Conn")
+    (attr through_hole)
+    (pad "1" thru_hole circle (at 0 0) (size 1.7 1.7) (drill 1) (layers "*.Cu" "*.Mask") (net 1 "GND"))
+  )
+  (gr_rect (start 100 80) (end 130 100) (layer "Edge.Cuts") (stroke (width 0.1)))
+)
+`
+
+func testProject(t *testing.T) *kicad.LoadedProject {
+	t.Helper()
+	pcb, err := kicad.ReadPCB(strings.NewReader(testBoardSrc))
+	if err != nil {
+		t.Fatalf("reading board: %s", err)
+	}
+	return &kicad.LoadedProject{
+		Name: "widget",
+		PCB:  pcb,
+		Project: &kicad.Project{
+			NetSettings: kicad.ProjectNetSettings{
+				Classes: []kicad.ProjectNetClass{
+					{Name: "Default", Clearance: 0.2, TrackWidth: 0.25},
+					{Name: "Power", Clearance: 0.3, TrackWidth: 0.5},
+				},
+			},
+		},
+	}
+}
+
+func TestFiles(t *testing.T) {
+	files, err := Files(testProject(t), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, f := range files {
+		got = append(got, f.Name)
+	}
+	want := []string{
+		"widget-F_Cu.gbr",
+		"widget-B_Cu.gbr",
+		"widget-F_SilkS.gbr",
+		"widget-F_Mask.gbr",
+		"widget-B_Mask.gbr",
+		"widget-Edge_Cuts.gbr",
+		"widget-job.gbrjob",
+		"widget-PTH.drl",
+		"widget-drl.rpt",
+		"widget-top-pos.csv",
+		"widget-bottom-pos.csv",
+		"widget-bom.csv",
+		"widget.d356",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong files\ngot:  %q\nwant: %q", got, want)
+	}
+}
+
+func TestFiles_noBoard(t *testing.T) {
+	_, err := Files(&kicad.LoadedProject{Name: "empty"}, nil)
+	if err == nil {
+		t.Fatal("no error")
+	}
+}
+
+func TestFiles_legacyBoard(t *testing.T) {
+	lp := testProject(t)
+	lp.PCB.Version = 20171130
+	_, err := Files(lp, nil)
+	if err == nil {
+		t.Fatal("no error")
+	}
+}
+
+func TestWriteZip(t *testing.T) {
+	lp := testProject(t)
+	var a, b bytes.Buffer
+	if err := WriteZip(&a, lp, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteZip(&b, lp, nil); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(a.Bytes(), b.Bytes()) {
+		t.Error("archives of the same project are not identical")
+	}
+
+	files, err := Files(lp, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(a.Bytes()), int64(a.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(zr.File) != len(files) {
+		t.Fatalf("archive has %d files; want %d", len(zr.File), len(files))
+	}
+	for i, zf := range zr.File {
+		if zf.Name != files[i].Name {
+			t.Errorf("file %d is named %q; want %q", i, zf.Name, files[i].Name)
+		}
+		if !zf.Modified.Equal(zipEpoch) {
+			t.Errorf("%s was modified at %s; want %s", zf.Name, zf.Modified, zipEpoch)
+		}
+		r, err := zf.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(r)
+		r.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(data, files[i].Data) {
+			t.Errorf("wrong content for %s", zf.Name)
+		}
+	}
+}
+
+func TestWriteZip_creationDate(t *testing.T) {
+	date := time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC)
+	var buf bytes.Buffer
+	if err := WriteZip(&buf, testProject(t), &Options{CreationDate: date}); err != nil {
+		t.Fatal(err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, zf := range zr.File {
+		if !zf.Modified.Equal(date) {
+			t.Errorf("%s was modified at %s; want %s", zf.Name, zf.Modified, date)
+		}
+	}
+}
+
+func TestWriteJob(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteJob(&buf, testProject(t), nil); err != nil {
+		t.Fatal(err)
+	}
+	got := buf.String()
+	want := `{
+  "Header": {
+    "GenerationSoftware": {
+      "Vendor": "apparentlymart",
+      "Application": "go-kicad"
+    }
+  },
+  "GeneralSpecs": {
+    "ProjectId": {
+      "Name": "widget",
+      "Revision": "B"
+    },
+    "Size": {
+      "X": 30,
+      "Y": 20
+    },
+    "LayerNumber": 2,
+    "BoardThickness": 1.6,
+    "Finish": "ENIG"
+  },
+  "DesignRules": [
+    {
+      "Layers": "Outer",
+      "PadToPad": 0.2,
+      "PadToTrack": 0.2,
+      "TrackToTrack": 0.2,
+      "MinLineWidth": 0.25
+    }
+  ],
+  "FilesAttributes": [
+    {
+      "Path": "widget-F_Cu.gbr",
+      "FileFunction": "Copper,L1,Top",
+      "FilePolarity": "Positive"
+    },
+    {
+      "Path": "widget-B_Cu.gbr",
+      "FileFunction": "Copper,L2,Bot",
+      "FilePolarity": "Positive"
+    },
+    {
+      "Path": "widget-F_SilkS.gbr",
+      "FileFunction": "Legend,Top",
+      "FilePolarity": "Positive"
+    },
+    {
+      "Path": "widget-F_Mask.gbr",
+      "FileFunction": "Soldermask,Top",
+      "FilePolarity": "Negative"
+    },
+    {
+      "Path": "widget-B_Mask.gbr",
+      "FileFunction": "Soldermask,Bot",
+      "FilePolarity": "Negative"
+    },
+    {
+      "Path": "widget-Edge_Cuts.gbr",
+      "FileFunction": "Profile,NP",
+      "FilePolarity": "Positive"
+    },
+    {
+      "Path": "widget-PTH.drl",
+      "FileFunction": "Plated,1,2,PTH",
+      "FilePolarity": "Positive"
+    }
+  ],
+  "MaterialStackup": [
+    {
+      "Type": "Legend",
+      "Color": "White",
+      "Name": "F.SilkS"
+    },
+    {
+      "Type": "SolderMask",
+      "Color": "Green",
+      "Thickness": 0.01,
+      "Name": "F.Mask"
+    },
+    {
+      "Type": "Copper",
+      "Thickness": 0.035,
+      "Name": "F.Cu"
+    },
+    {
+      "Type": "Dielectric",
+      "Thickness": 1.51,
+      "Material": "FR4",
+      "DielectricConstant": 4.5,
+      "LossTangent": 0.02,
+      "Name": "dielectric 1"
+    },
+    {
+      "Type": "Copper",
+      "Thickness": 0.035,
+      "Name": "B.Cu"
+    },
+    {
+      "Type": "SolderMask",
+      "Color": "Green",
+      "Thickness": 0.01,
+      "Name": "B.Mask"
+    }
+  ]
+}
+`
+	if got != want {
+		t.Errorf("incorrect result\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestFilename(t *testing.T) {
+	if got, want := Filename("widget"), "widget-fab.zip"; got != want {
+		t.Errorf("wrong filename %q; want %q", got, want)
+	}
+	if got, want := JobFilename("widget"), "widget-job.gbrjob"; got != want {
+		t.Errorf("wrong job filename %q; want %q", got, want)
+	}
+}
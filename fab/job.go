@@ -0,0 +1,255 @@
+package fab
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+
+	"github.com/apparentlymart/go-kicad"
+	"github.com/apparentlymart/go-kicad/drill"
+	"github.com/apparentlymart/go-kicad/gerber"
+	"github.com/apparentlymart/go-kicad/internal/fileio"
+)
+
+// JobFilename returns the name KiCad gives to the Gerber job file of a
+// board, given the base name of the board file without its extension.
+func JobFilename(base string) string {
+	return base + "-job.gbrjob"
+}
+
+// The types below describe the JSON structure of a Gerber job file, as
+// defined by version 1.0 of the Gerber job format specification.
+
+type job struct {
+	Header          jobHeader         `json:"Header"`
+	GeneralSpecs    jobGeneralSpecs   `json:"GeneralSpecs"`
+	DesignRules     []jobDesignRules  `json:"DesignRules,omitempty"`
+	FilesAttributes []jobFile         `json:"FilesAttributes"`
+	MaterialStackup []jobStackupLayer `json:"MaterialStackup,omitempty"`
+}
+
+type jobHeader struct {
+	GenerationSoftware jobSoftware `json:"GenerationSoftware"`
+	CreationDate       string      `json:"CreationDate,omitempty"`
+}
+
+type jobSoftware struct {
+	Vendor      string `json:"Vendor"`
+	Application string `json:"Application"`
+}
+
+type jobGeneralSpecs struct {
+	ProjectID      jobProjectID `json:"ProjectId"`
+	Size           *jobSize     `json:"Size,omitempty"`
+	LayerNumber    int          `json:"LayerNumber"`
+	BoardThickness float64      `json:"BoardThickness,omitempty"`
+	Finish         string       `json:"Finish,omitempty"`
+	Castellated    bool         `json:"Castellated,omitempty"`
+	EdgePlating    bool         `json:"EdgePlating,omitempty"`
+}
+
+type jobProjectID struct {
+	Name     string `json:"Name"`
+	Revision string `json:"Revision,omitempty"`
+}
+
+type jobSize struct {
+	X float64 `json:"X"`
+	Y float64 `json:"Y"`
+}
+
+type jobDesignRules struct {
+	Layers       string  `json:"Layers"`
+	PadToPad     float64 `json:"PadToPad"`
+	PadToTrack   float64 `json:"PadToTrack"`
+	TrackToTrack float64 `json:"TrackToTrack"`
+	MinLineWidth float64 `json:"MinLineWidth,omitempty"`
+}
+
+type jobFile struct {
+	Path         string `json:"Path"`
+	FileFunction string `json:"FileFunction"`
+	FilePolarity string `json:"FilePolarity"`
+}
+
+type jobStackupLayer struct {
+	Type               string  `json:"Type"`
+	Color              string  `json:"Color,omitempty"`
+	Thickness          float64 `json:"Thickness,omitempty"`
+	Material           string  `json:"Material,omitempty"`
+	DielectricConstant float64 `json:"DielectricConstant,omitempty"`
+	LossTangent        float64 `json:"LossTangent,omitempty"`
+	Name               string  `json:"Name"`
+}
+
+// WriteJob writes a Gerber job file for the given project's board to the
+// given writer. The job file lists the Gerber and drill files that Files
+// produces, and describes the board's size, stackup and the design rules
+// of the project's net classes.
+func WriteJob(w io.Writer, lp *kicad.LoadedProject, opts *Options) error {
+	if opts == nil {
+		opts = &Options{}
+	}
+	pcb := lp.PCB
+	if pcb == nil {
+		return fmt.Errorf("project %s has no board", lp.Name)
+	}
+	base := lp.Name
+	copper := pcb.CopperLayers()
+
+	j := job{
+		Header: jobHeader{
+			GenerationSoftware: jobSoftware{Vendor: "apparentlymart", Application: "go-kicad"},
+		},
+		GeneralSpecs: jobGeneralSpecs{
+			ProjectID:      jobProjectID{Name: base, Revision: pcb.TitleBlock.Revision},
+			LayerNumber:    len(copper),
+			BoardThickness: mm(boardThickness(pcb)),
+			Finish:         pcb.Setup.Stackup.CopperFinish,
+			Castellated:    pcb.Setup.Stackup.CastellatedPads,
+			EdgePlating:    pcb.Setup.Stackup.EdgePlating,
+		},
+	}
+	if !opts.CreationDate.IsZero() {
+		j.Header.CreationDate = opts.CreationDate.Format("2006-01-02T15:04:05-07:00")
+	}
+	if min, max, ok := pcb.OutlineBounds(); ok {
+		j.GeneralSpecs.Size = &jobSize{X: mm(max.X - min.X), Y: mm(max.Y - min.Y)}
+	}
+
+	if clearance, width := designRules(lp.Project); clearance > 0 {
+		layers := []string{"Outer"}
+		if len(copper) > 2 {
+			layers = append(layers, "Inner")
+		}
+		for _, l := range layers {
+			j.DesignRules = append(j.DesignRules, jobDesignRules{
+				Layers:       l,
+				PadToPad:     mm(clearance),
+				PadToTrack:   mm(clearance),
+				TrackToTrack: mm(clearance),
+				MinLineWidth: mm(width),
+			})
+		}
+	}
+
+	for _, layer := range gerber.Layers(pcb) {
+		j.FilesAttributes = append(j.FilesAttributes, jobFile{
+			Path:         gerber.Filename(base, layer),
+			FileFunction: gerber.FileFunction(pcb, layer),
+			FilePolarity: gerber.FilePolarity(layer),
+		})
+	}
+	for _, f := range drill.Files(pcb) {
+		j.FilesAttributes = append(j.FilesAttributes, jobFile{
+			Path:         f.Name(base),
+			FileFunction: f.FileFunction(),
+			FilePolarity: "Positive",
+		})
+	}
+
+	for _, l := range pcb.Setup.Stackup.Layers {
+		typ := stackupType(l.Type)
+		if typ == "" {
+			continue
+		}
+		sl := jobStackupLayer{
+			Type:      typ,
+			Thickness: mm(l.Thickness.Value),
+			Material:  l.Material,
+			Name:      l.Name,
+		}
+		if typ != "Copper" && typ != "Dielectric" {
+			sl.Color = l.Color
+		}
+		if typ == "Dielectric" {
+			sl.DielectricConstant = l.EpsilonR
+			sl.LossTangent = l.LossTangent
+		}
+		j.MaterialStackup = append(j.MaterialStackup, sl)
+	}
+
+	buf, err := json.MarshalIndent(j, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding JSON: %w", err)
+	}
+	buf = append(buf, '\n')
+	_, err = w.Write(buf)
+	return err
+}
+
+// WriteJobFile writes a Gerber job file for the given project's board to
+// the named file, as WriteJob does.
+func WriteJobFile(filename string, lp *kicad.LoadedProject, opts *Options) error {
+	return fileio.WriteFile(filename, func(w io.Writer) error {
+		return WriteJob(w, lp, opts)
+	})
+}
+
+// boardThickness returns the thickness of the given board, from its general
+// settings or else the total thickness of its stackup.
+func boardThickness(pcb *kicad.PCB) float64 {
+	if pcb.General.Thickness > 0 {
+		return pcb.General.Thickness
+	}
+	total := 0.0
+	for _, l := range pcb.Setup.Stackup.Layers {
+		total += l.Thickness.Value
+	}
+	return total
+}
+
+// stackupType returns the type that a job file gives to a layer of the
+// given stackup type, or the empty string for layers that it doesn't
+// describe.
+func stackupType(typ string) string {
+	switch {
+	case typ == "copper":
+		return "Copper"
+	case typ == "core" || typ == "prepreg":
+		return "Dielectric"
+	case strings.HasSuffix(typ, "Silk Screen"):
+		return "Legend"
+	case strings.HasSuffix(typ, "Solder Paste"):
+		return "SolderPaste"
+	case strings.HasSuffix(typ, "Solder Mask"):
+		return "SolderMask"
+	}
+	return ""
+}
+
+// designRules returns the smallest clearance and track width of the net
+// classes of the given project, or the project's minimums if its net
+// classes don't give them. The project may be nil, in which case both are
+// zero.
+func designRules(proj *kicad.Project) (clearance, width float64) {
+	if proj == nil {
+		return 0, 0
+	}
+	smallest := func(a, b float64) float64 {
+		if a == 0 || (b > 0 && b < a) {
+			return b
+		}
+		return a
+	}
+	for _, nc := range proj.NetSettings.Classes {
+		clearance = smallest(clearance, nc.Clearance)
+		width = smallest(width, nc.TrackWidth)
+	}
+	rules := &proj.Board.DesignSettings.Rules
+	if clearance == 0 {
+		clearance = rules.MinClearance
+	}
+	if width == 0 {
+		width = rules.MinTrackWidth
+	}
+	return clearance, width
+}
+
+// mm rounds a length in millimeters, to avoid noise in the last digits
+// from calculations.
+func mm(v float64) float64 {
+	return math.Round(v*1e6) / 1e6
+}
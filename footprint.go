@@ -0,0 +1,289 @@
+package kicad
+
+// Footprint represents a footprint, either placed on a board or stored
+// standalone in a footprint library.
+//
+// KiCad 5 calls footprints "modules", but the content is otherwise largely
+// the same and so both forms decode into this type.
+type Footprint struct {
+	LibID  string `kicad:""`
+	Locked bool   `kicad:"locked,bare"`
+	Placed bool   `kicad:"placed,bare"`
+
+	// Version and Generator are set only for footprints stored in their
+	// own files, rather than placed on a board.
+	Version          int    `kicad:"version"`
+	Generator        string `kicad:"generator"`
+	GeneratorVersion string `kicad:"generator_version"`
+
+	Layer       string              `kicad:"layer"`
+	TEdit       string              `kicad:"tedit"`
+	UUID        string              `kicad:"uuid"`
+	TStamp      string              `kicad:"tstamp"`
+	At          PositionAngle       `kicad:"at,flat"`
+	Description string              `kicad:"descr"`
+	Tags        string              `kicad:"tags"`
+	Properties  []FootprintProperty `kicad:"property,multi,flat"`
+	Path        string              `kicad:"path"`
+	SheetName   string              `kicad:"sheetname"`
+	SheetFile   string              `kicad:"sheetfile"`
+	Attr        FootprintAttr       `kicad:"attr,flat"`
+
+	SolderMaskMargin       float64 `kicad:"solder_mask_margin"`
+	SolderPasteMargin      float64 `kicad:"solder_paste_margin"`
+	SolderPasteMarginRatio float64 `kicad:"solder_paste_margin_ratio"`
+	Clearance              float64 `kicad:"clearance"`
+	ZoneConnect            int     `kicad:"zone_connect"`
+
+	Texts   []FootprintText `kicad:"fp_text,multi,flat"`
+	Lines   []GraphicLine   `kicad:"fp_line,multi,flat"`
+	Arcs    []GraphicArc    `kicad:"fp_arc,multi,flat"`
+	Circles []GraphicCircle `kicad:"fp_circle,multi,flat"`
+	Rects   []GraphicRect   `kicad:"fp_rect,multi,flat"`
+	Polys   []GraphicPoly   `kicad:"fp_poly,multi,flat"`
+	Curves  []GraphicCurve  `kicad:"fp_curve,multi,flat"`
+	Pads    []Pad           `kicad:"pad,multi,flat"`
+	Zones   []Zone          `kicad:"zone,multi,flat"`
+	Models  []Model3D       `kicad:"model,multi,flat"`
+}
+
+// Reference returns the reference designator of the footprint, such as
+// "R1", from either its "Reference" property or its reference text.
+func (f *Footprint) Reference() string {
+	return f.fieldText("Reference", "reference")
+}
+
+// Value returns the value of the footprint, such as "10k", from either its
+// "Value" property or its value text.
+func (f *Footprint) Value() string {
+	return f.fieldText("Value", "value")
+}
+
+// Property returns the value of the property with the given name, or the
+// empty string if there is no such property.
+func (f *Footprint) Property(name string) string {
+	for _, prop := range f.Properties {
+		if prop.Name == name {
+			return prop.Value
+		}
+	}
+	return ""
+}
+
+// Pad returns the first pad with the given number, or nil if there is no
+// such pad.
+func (f *Footprint) Pad(number string) *Pad {
+	for i := range f.Pads {
+		if f.Pads[i].Number == number {
+			return &f.Pads[i]
+		}
+	}
+	return nil
+}
+
+// BoardPosition converts a position relative to the footprint, such as the
+// position of one of its pads or graphic items, into board coordinates by
+// rotating it by the footprint's angle and offsetting it by the footprint's
+// position.
+func (f *Footprint) BoardPosition(p Position) Position {
+	r := RotatePoint(p, f.At.Angle)
+	return Position{X: f.At.X + r.X, Y: f.At.Y + r.Y}
+}
+
+// ExpandText expands the text variables in the given text of one of the
+// footprint's text items or properties, such as ${REFERENCE}, from the
+// footprint's fields and properties.
+func (f *Footprint) ExpandText(text string) string {
+	return ExpandTextVars(text, func(name string) (string, bool) {
+		switch name {
+		case "REFERENCE":
+			return f.Reference(), true
+		case "VALUE":
+			return f.Value(), true
+		}
+		for _, prop := range f.Properties {
+			if prop.Name == name {
+				return prop.Value, true
+			}
+		}
+		return "", false
+	})
+}
+
+// BoardTextAt converts the position of one of the footprint's text items
+// or properties into board coordinates. The position of the text is
+// relative to the footprint, but its angle already includes the
+// footprint's rotation, so only the position is converted.
+func (f *Footprint) BoardTextAt(at PositionAngle) PositionAngle {
+	pos := f.BoardPosition(at.Position())
+	return PositionAngle{X: pos.X, Y: pos.Y, Angle: at.Angle}
+}
+
+func (f *Footprint) fieldText(propName, textType string) string {
+	// KiCad 8 uses properties for these fields, while earlier versions
+	// use special fp_text items.
+	for _, prop := range f.Properties {
+		if prop.Name == propName {
+			return prop.Value
+		}
+	}
+	for _, text := range f.Texts {
+		if text.Type == textType {
+			return text.Text
+		}
+	}
+	return ""
+}
+
+// FootprintAttr describes the fabrication attributes of a footprint.
+//
+// Type is "smd" or "through_hole", or empty for footprints of neither
+// kind. KiCad 5 documents may also use the type "virtual" for footprints
+// that have no physical part, which later versions represent as BoardOnly.
+type FootprintAttr struct {
+	Type                   string `kicad:",optional"`
+	BoardOnly              bool   `kicad:"board_only,bare"`
+	ExcludeFromPosFiles    bool   `kicad:"exclude_from_pos_files,bare"`
+	ExcludeFromBOM         bool   `kicad:"exclude_from_bom,bare"`
+	AllowMissingCourtyard  bool   `kicad:"allow_missing_courtyard,bare"`
+	AllowSolderMaskBridges bool   `kicad:"allow_soldermask_bridges,bare"`
+	DNP                    bool   `kicad:"dnp,bare"`
+}
+
+// FootprintProperty is a named field of a footprint.
+//
+// KiCad 6 and 7 use properties only to store additional data, and so give
+// only a name and value. In KiCad 8 the reference and value fields are also
+// properties, which are drawn on the board like FootprintText items.
+type FootprintProperty struct {
+	Name     string        `kicad:""`
+	Value    string        `kicad:""`
+	At       PositionAngle `kicad:"at,flat"`
+	Unlocked bool          `kicad:"unlocked"`
+	Layer    TextLayer     `kicad:"layer,flat"`
+	Hide     bool          `kicad:"hide"`
+	UUID     string        `kicad:"uuid"`
+	Effects  TextEffects   `kicad:"effects,flat"`
+}
+
+// FootprintText is a text item within a footprint. Type is "reference" or
+// "value" for the text showing those fields, or "user" for other text.
+type FootprintText struct {
+	Type    string        `kicad:""`
+	Text    string        `kicad:""`
+	At      PositionAngle `kicad:"at,flat,always"`
+	Layer   TextLayer     `kicad:"layer,flat"`
+	Hide    bool          `kicad:"hide,bare"`
+	Effects TextEffects   `kicad:"effects,flat"`
+	UUID    string        `kicad:"uuid"`
+	TStamp  string        `kicad:"tstamp"`
+}
+
+// Pad is a pad of a footprint.
+//
+// Type is one of "thru_hole", "smd", "connect" or "np_thru_hole". Shape is
+// one of "circle", "rect", "oval", "trapezoid", "roundrect" or "custom".
+//
+// The position of a pad is relative to its footprint, but its angle
+// includes the rotation of the footprint itself.
+type Pad struct {
+	Number             string        `kicad:""`
+	Type               string        `kicad:""`
+	Shape              string        `kicad:""`
+	Locked             bool          `kicad:"locked,bare"`
+	At                 PositionAngle `kicad:"at,flat,always"`
+	Size               Size          `kicad:"size,flat"`
+	RectDelta          Size          `kicad:"rect_delta,flat"`
+	Drill              PadDrill      `kicad:"drill,flat"`
+	Layers             []string      `kicad:"layers,flat"`
+	RemoveUnusedLayers bool          `kicad:"remove_unused_layers,empty"`
+	KeepEndLayers      bool          `kicad:"keep_end_layers,empty"`
+	RoundRectRatio     float64       `kicad:"roundrect_rratio"`
+	ChamferRatio       float64       `kicad:"chamfer_ratio"`
+	Chamfer            PadChamfer    `kicad:"chamfer,flat"`
+	Net                PCBNet        `kicad:"net,flat"`
+	PinFunction        string        `kicad:"pinfunction"`
+	PinType            string        `kicad:"pintype"`
+	DieLength          float64       `kicad:"die_length"`
+
+	SolderMaskMargin       float64 `kicad:"solder_mask_margin"`
+	SolderPasteMargin      float64 `kicad:"solder_paste_margin"`
+	SolderPasteMarginRatio float64 `kicad:"solder_paste_margin_ratio"`
+	Clearance              float64 `kicad:"clearance"`
+	ZoneConnect            int     `kicad:"zone_connect"`
+	ThermalWidth           float64 `kicad:"thermal_width"`
+	ThermalBridgeWidth     float64 `kicad:"thermal_bridge_width"`
+	ThermalGap             float64 `kicad:"thermal_gap"`
+
+	Options    PadOptions    `kicad:"options,flat"`
+	Primitives PadPrimitives `kicad:"primitives,flat"`
+	UUID       string        `kicad:"uuid"`
+	TStamp     string        `kicad:"tstamp"`
+}
+
+// HasDrill returns true if the pad has a hole.
+func (p *Pad) HasDrill() bool {
+	return p.Drill.Width > 0
+}
+
+// PadDrill describes the hole of a pad. A round hole has only Width, which
+// is its diameter, while an oval hole also has Height.
+type PadDrill struct {
+	Oval   bool     `kicad:"oval,bare"`
+	Width  float64  `kicad:""`
+	Height float64  `kicad:",optional"`
+	Offset Position `kicad:"offset,flat"`
+}
+
+// PadChamfer selects which corners of a "roundrect" pad are chamfered, by
+// the amount given in Pad.ChamferRatio.
+type PadChamfer struct {
+	TopLeft     bool `kicad:"top_left,bare"`
+	TopRight    bool `kicad:"top_right,bare"`
+	BottomLeft  bool `kicad:"bottom_left,bare"`
+	BottomRight bool `kicad:"bottom_right,bare"`
+}
+
+// PadOptions holds the settings for a pad with the "custom" shape. Anchor
+// is the shape of the anchor pad that the primitives are added to, and
+// Clearance is either "outline" or "convexhull".
+type PadOptions struct {
+	Clearance string `kicad:"clearance"`
+	Anchor    string `kicad:"anchor"`
+}
+
+// PadPrimitives are the graphic items that, along with the anchor pad,
+// make up the shape of a custom pad. Their positions are relative to the
+// pad.
+//
+// KiCad 5 documents give polygons here a zero width and no fill, but they
+// are nonetheless drawn filled.
+type PadPrimitives struct {
+	Width   float64         `kicad:"width"`
+	Lines   []GraphicLine   `kicad:"gr_line,multi,flat"`
+	Arcs    []GraphicArc    `kicad:"gr_arc,multi,flat"`
+	Circles []GraphicCircle `kicad:"gr_circle,multi,flat"`
+	Rects   []GraphicRect   `kicad:"gr_rect,multi,flat"`
+	Polys   []GraphicPoly   `kicad:"gr_poly,multi,flat"`
+	Curves  []GraphicCurve  `kicad:"gr_curve,multi,flat"`
+}
+
+// Model3D refers to a 3D model of a footprint, used for 3D viewing and
+// for export to mechanical CAD tools.
+//
+// Offset is in millimeters. KiCad 5 documents instead give the offset in
+// inches in At.
+type Model3D struct {
+	Path    string      `kicad:""`
+	Hide    bool        `kicad:"hide,bare"`
+	Opacity float64     `kicad:"opacity"`
+	Offset  ModelVector `kicad:"offset,flat"`
+	At      ModelVector `kicad:"at,flat"`
+	Scale   ModelVector `kicad:"scale,flat"`
+	Rotate  ModelVector `kicad:"rotate,flat"`
+}
+
+// ModelVector wraps a Vector3 in the "xyz" tuple that Model3D uses.
+type ModelVector struct {
+	XYZ Vector3 `kicad:"xyz,flat"`
+}
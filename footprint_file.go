@@ -0,0 +1,81 @@
+package kicad
+
+import (
+	"io"
+	"os"
+
+	"github.com/apparentlymart/go-kicad/sexp"
+)
+
+const (
+	// legacyFootprintVersion is the format version assumed for KiCad 5
+	// footprint files, which don't declare their version.
+	legacyFootprintVersion = 20171130
+
+	// footprintKeywordVersion is the first format version in which
+	// footprints are called "footprint" rather than "module".
+	footprintKeywordVersion = 20201115
+)
+
+// ReadFootprint reads a stream containing a standalone footprint, as found
+// in a .kicad_mod file, and returns a Footprint structure describing it.
+//
+// Both the KiCad 5 "module" form and the later "footprint" form are
+// accepted. Since KiCad 5 footprint files don't declare their format
+// version, the returned footprint has the KiCad 5 format version in that
+// case so that WriteFootprint will write it in the same form.
+func ReadFootprint(r io.Reader) (*Footprint, error) {
+	fp := &Footprint{}
+	typeName, err := sexp.DecodeAny(r, []string{"footprint", "module"}, fp)
+	if err == nil && typeName == "module" && fp.Version == 0 {
+		fp.Version = legacyFootprintVersion
+	}
+	return fp, err
+}
+
+// ReadFootprintFile is a convenience wrapper around ReadFootprint that takes
+// a filename and opens the given file for reading before calling
+// ReadFootprint.
+func ReadFootprintFile(filename string) (*Footprint, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ReadFootprint(f)
+}
+
+// WriteFootprint writes the given footprint to the given writer as a
+// standalone footprint, as found in a .kicad_mod file.
+//
+// The footprint is written in the KiCad 5 "module" form if its Version is
+// set to a format version older than KiCad 6, and in the "footprint" form
+// otherwise.
+//
+// The Footprint structure is not a comprehensive representation of the
+// file format, so writing a footprint previously returned by ReadFootprint
+// will lose any information that the structure doesn't capture.
+func WriteFootprint(w io.Writer, fp *Footprint) error {
+	typeName := "footprint"
+	if fp.Version != 0 && fp.Version < footprintKeywordVersion {
+		typeName = "module"
+	}
+	return sexp.Encode(w, typeName, fp)
+}
+
+// WriteFootprintFile is a convenience wrapper around WriteFootprint that
+// creates or replaces the given file before calling WriteFootprint.
+func WriteFootprintFile(filename string, fp *Footprint) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+
+	err = WriteFootprint(f, fp)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
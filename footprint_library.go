@@ -0,0 +1,303 @@
+package kicad
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/apparentlymart/go-kicad/sexp"
+)
+
+const (
+	footprintLibraryExt = ".pretty"
+	footprintFileExt    = ".kicad_mod"
+)
+
+// FootprintLibrary is a footprint library stored as a .pretty directory,
+// which contains one .kicad_mod file for each footprint.
+//
+// Footprints are read from disk only when first requested, and are then
+// cached for subsequent requests. A FootprintLibrary is not safe for
+// concurrent use.
+type FootprintLibrary struct {
+	Dir string
+
+	cache map[string]*Footprint
+}
+
+// OpenFootprintLibrary returns a FootprintLibrary for the existing .pretty
+// directory at the given path.
+func OpenFootprintLibrary(dir string) (*FootprintLibrary, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", dir)
+	}
+
+	return &FootprintLibrary{
+		Dir:   dir,
+		cache: make(map[string]*Footprint),
+	}, nil
+}
+
+// CreateFootprintLibrary creates a new, empty .pretty directory at the
+// given path and returns a FootprintLibrary for it.
+func CreateFootprintLibrary(dir string) (*FootprintLibrary, error) {
+	err := os.Mkdir(dir, 0777)
+	if err != nil {
+		return nil, err
+	}
+	return OpenFootprintLibrary(dir)
+}
+
+// Name returns the default nickname of the library, which is the name of
+// its directory without the .pretty suffix.
+func (l *FootprintLibrary) Name() string {
+	return strings.TrimSuffix(filepath.Base(l.Dir), footprintLibraryExt)
+}
+
+// Names returns the names of all of the footprints in the library, in
+// lexical order.
+func (l *FootprintLibrary) Names() ([]string, error) {
+	entries, err := os.ReadDir(l.Dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != footprintFileExt {
+			continue
+		}
+		names = append(names, strings.TrimSuffix(entry.Name(), footprintFileExt))
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// Footprint returns the footprint with the given name, reading it from disk
+// if it hasn't been requested before.
+//
+// The returned footprint is shared with subsequent callers, so callers must
+// not modify it.
+func (l *FootprintLibrary) Footprint(name string) (*Footprint, error) {
+	if fp, ok := l.cache[name]; ok {
+		return fp, nil
+	}
+	if err := checkFootprintName(name); err != nil {
+		return nil, err
+	}
+
+	fp, err := ReadFootprintFile(l.footprintPath(name))
+	if err != nil {
+		return nil, fmt.Errorf("footprint %q in library %q: %w", name, l.Name(), err)
+	}
+	l.cache[name] = fp
+	return fp, nil
+}
+
+// Add writes the given footprint into the library under the given name,
+// which must not already be in use.
+//
+// The footprint's LibID is set to the new name in the written file, since
+// footprints in libraries do not include a library nickname. If the
+// footprint was taken from a board then its placement is also removed: it
+// is stored unrotated, without the position, UUID and schematic sheet that
+// tie it to the board. A footprint flipped to the back of the board can't
+// be added, since library footprints are always on the front. The given
+// footprint itself is not modified.
+func (l *FootprintLibrary) Add(name string, fp *Footprint) error {
+	if err := checkFootprintName(name); err != nil {
+		return err
+	}
+	if fp.Layer == "B.Cu" {
+		return fmt.Errorf("footprint %q is on the back of the board, so can't be added to library %q", name, l.Name())
+	}
+
+	// Encoding the footprint also serves to copy it, so that the cached
+	// footprint shares nothing with the caller's.
+	var buf bytes.Buffer
+	err := WriteFootprint(&buf, libraryFootprint(fp, name))
+	if err != nil {
+		return err
+	}
+	stored, err := ReadFootprint(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(l.footprintPath(name), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0666)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(buf.Bytes())
+	if err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	err = f.Close()
+	if err != nil {
+		return err
+	}
+
+	l.cache[name] = stored
+	return nil
+}
+
+// libraryFootprint returns a shallow copy of the given footprint with the
+// given name and with the fields describing its placement on a board reset.
+//
+// Pads, texts and properties give their angles including the rotation of
+// the footprint, so those are copied too in order to remove that rotation.
+func libraryFootprint(fp *Footprint, name string) *Footprint {
+	ret := *fp
+	ret.LibID = name
+	ret.At = PositionAngle{}
+	ret.UUID = ""
+	ret.TStamp = ""
+	ret.Path = ""
+	ret.SheetName = ""
+	ret.SheetFile = ""
+	if fp.At.Angle != 0 {
+		ret.Pads = slices.Clone(fp.Pads)
+		for i := range ret.Pads {
+			ret.Pads[i].At.Angle = NormalizeDegrees(ret.Pads[i].At.Angle - fp.At.Angle)
+		}
+		ret.Texts = slices.Clone(fp.Texts)
+		for i := range ret.Texts {
+			ret.Texts[i].At.Angle = NormalizeDegrees(ret.Texts[i].At.Angle - fp.At.Angle)
+		}
+		ret.Properties = slices.Clone(fp.Properties)
+		for i := range ret.Properties {
+			ret.Properties[i].At.Angle = NormalizeDegrees(ret.Properties[i].At.Angle - fp.At.Angle)
+		}
+	}
+	return &ret
+}
+
+// Rename changes the name of the footprint with the given old name, which
+// also updates the name recorded within its file. The new name must not
+// already be in use.
+//
+// Only the name token within the file is replaced, so that the rest of its
+// content is preserved exactly, including anything that the Footprint
+// structure doesn't capture. The new content is written to a temporary file
+// that is then moved into place, so the old file is left as it was if
+// writing fails.
+func (l *FootprintLibrary) Rename(oldName, newName string) error {
+	if err := checkFootprintName(oldName); err != nil {
+		return err
+	}
+	if err := checkFootprintName(newName); err != nil {
+		return err
+	}
+	oldPath, newPath := l.footprintPath(oldName), l.footprintPath(newName)
+
+	info, err := os.Stat(oldPath)
+	if err != nil {
+		return err
+	}
+	src, err := os.ReadFile(oldPath)
+	if err != nil {
+		return err
+	}
+	src, err = renameFootprintSource(src, newName)
+	if err != nil {
+		return fmt.Errorf("footprint %q in library %q: %w", oldName, l.Name(), err)
+	}
+
+	// os.Rename replaces any existing file, so we must check first.
+	if _, err := os.Lstat(newPath); err == nil {
+		return &os.LinkError{Op: "rename", Old: oldPath, New: newPath, Err: fs.ErrExist}
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	f, err := os.CreateTemp(l.Dir, ".*.tmp")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(src)
+	if err == nil {
+		// CreateTemp makes the file private, so we give it the
+		// permissions of the file it replaces.
+		err = f.Chmod(info.Mode().Perm())
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), newPath)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+
+	delete(l.cache, oldName)
+	return os.Remove(oldPath)
+}
+
+// renameFootprintSource returns the given content of a footprint file with
+// the name of the footprint replaced by the given name.
+func renameFootprintSource(src []byte, name string) ([]byte, error) {
+	s := sexp.NewScanner(bytes.NewReader(src))
+	if tok := s.Read(); tok.Type != sexp.LEFT {
+		return nil, fmt.Errorf("not a footprint file")
+	}
+	keyword := s.Read()
+	if keyword.Data != "footprint" && keyword.Data != "module" {
+		return nil, fmt.Errorf("not a footprint file")
+	}
+	tok := s.Read()
+	if tok.Type != sexp.QUOTE_STRING && tok.Type != sexp.RAW_STRING {
+		return nil, fmt.Errorf("footprint has no name")
+	}
+
+	// Only whitespace separates the opening parenthesis, the keyword and
+	// the name, so the first match of each after the previous is the
+	// token itself.
+	start := bytes.IndexByte(src, '(')
+	start += bytes.Index(src[start:], []byte(keyword.Data)) + len(keyword.Data)
+	start += bytes.Index(src[start:], []byte(tok.Data))
+	end := start + len(tok.Data)
+
+	var quoted bytes.Buffer
+	err := sexp.NewWriter(&quoted).WriteQuoteString(name)
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]byte, 0, len(src)-len(tok.Data)+quoted.Len())
+	ret = append(ret, src[:start]...)
+	ret = append(ret, quoted.Bytes()...)
+	return append(ret, src[end:]...), nil
+}
+
+// Delete removes the footprint with the given name from the library.
+func (l *FootprintLibrary) Delete(name string) error {
+	if err := checkFootprintName(name); err != nil {
+		return err
+	}
+	delete(l.cache, name)
+	return os.Remove(l.footprintPath(name))
+}
+
+func (l *FootprintLibrary) footprintPath(name string) string {
+	return filepath.Join(l.Dir, name+footprintFileExt)
+}
+
+func checkFootprintName(name string) error {
+	if name == "" || strings.ContainsAny(name, `/\:`) {
+		return fmt.Errorf("invalid footprint name %q", name)
+	}
+	return nil
+}
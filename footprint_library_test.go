@@ -0,0 +1,295 @@
+package kicad
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/davecgh/go-spew/spew"
+)
+
+func TestReadWriteFootprint(t *testing.T) {
+	tests := map[string]struct {
+		Src         string
+		WantKeyword string
+	}{
+		"kicad5": {
+			`(module R_0603 (layer F.Cu) (tedit 5B301BBD)
+  (descr "Resistor SMD 0603")
+  (attr smd)
+  (fp_text reference REF** (at 0 -1.43) (layer F.SilkS)
+    (effects (font (size 1 1) (thickness 0.15)))
+  )
+  (fp_text value R_0603 (at 0 1.43) (layer F.Fab) hide
+    (effects (font (size 1 1) (thickness 0.15)) (justify mirror))
+  )
+  (fp_line (start -0.8 0.4) (end -0.8 -0.4) (layer F.Fab) (width 0.1))
+  (pad 1 smd roundrect (at -0.7875 0) (size 0.875 0.95) (layers F.Cu F.Paste F.Mask)
+    (roundrect_rratio 0.25))
+  (pad 2 thru_hole oval (at 0.7875 0) (size 1 2) (drill oval 0.6 1.2) (layers *.Cu *.Mask))
+  (model ${KISYS3DMOD}/R_0603.wrl
+    (at (xyz 0 0 0))
+    (scale (xyz 1 1 1))
+    (rotate (xyz 0 0 0))
+  )
+)
+`,
+			"(module",
+		},
+		"kicad8": {
+			`(footprint "R_0603"
+	(version 20240108)
+	(generator "pcbnew")
+	(generator_version "8.0")
+	(layer "F.Cu")
+	(descr "Resistor SMD 0603")
+	(property "Reference" "REF**"
+		(at 0 -1.43 0)
+		(layer "F.SilkS")
+		(uuid "1f4b54a4-4a0d-4d4c-9c8b-2e1d7d1a0b01")
+		(effects (font (size 1 1) (thickness 0.15)))
+	)
+	(attr smd exclude_from_bom)
+	(fp_rect (start -1 -0.5) (end 1 0.5) (stroke (width 0.05) (type solid)) (fill none) (layer "F.CrtYd"))
+	(fp_text user "${REFERENCE}" (at 0 0 0) (layer "F.Fab" knockout)
+		(effects (font (size 0.4 0.4) (thickness 0.06) bold italic) (justify left top))
+	)
+	(pad "1" smd roundrect (at -0.775 0) (size 0.9 0.95) (layers "F.Cu" "F.Paste" "F.Mask")
+		(roundrect_rratio 0.25) (chamfer_ratio 0.2) (chamfer top_left bottom_right)
+	)
+)
+`,
+			"(footprint",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			fp, err := ReadFootprint(strings.NewReader(test.Src))
+			if err != nil {
+				t.Fatalf("unexpected error reading: %s", err)
+			}
+
+			var buf bytes.Buffer
+			err = WriteFootprint(&buf, fp)
+			if err != nil {
+				t.Fatalf("unexpected error writing: %s", err)
+			}
+			if !strings.HasPrefix(buf.String(), test.WantKeyword) {
+				t.Errorf("written footprint should start with %q\n%s", test.WantKeyword, buf.String())
+			}
+
+			got, err := ReadFootprint(&buf)
+			if err != nil {
+				t.Fatalf("unexpected error re-reading: %s", err)
+			}
+			if !reflect.DeepEqual(got, fp) {
+				t.Errorf("incorrect round-trip\ngot:  %swant: %s", spew.Sdump(got), spew.Sdump(fp))
+			}
+		})
+	}
+}
+
+func TestFootprintLibrary(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "Test.pretty")
+	lib, err := CreateFootprintLibrary(dir)
+	if err != nil {
+		t.Fatalf("failed to create library: %s", err)
+	}
+	if got, want := lib.Name(), "Test"; got != want {
+		t.Errorf("wrong library name %q; want %q", got, want)
+	}
+
+	fp := &Footprint{
+		LibID:   "Other:R_0603",
+		Version: 20221018,
+		Layer:   "F.Cu",
+		Pads: []Pad{
+			{Number: "1", Type: "smd", Shape: "rect", Size: Size{Width: 1, Height: 1}},
+		},
+	}
+	if err := lib.Add("R_0603", fp); err != nil {
+		t.Fatalf("failed to add footprint: %s", err)
+	}
+	if err := lib.Add("R_0603", fp); !errors.Is(err, os.ErrExist) {
+		t.Errorf("adding duplicate footprint should fail with ErrExist; got %v", err)
+	}
+	if err := lib.Add("C_0603", fp); err != nil {
+		t.Fatalf("failed to add footprint: %s", err)
+	}
+	if got, want := fp.LibID, "Other:R_0603"; got != want {
+		t.Errorf("Add modified the given footprint's LibID to %q", got)
+	}
+
+	if err := lib.Rename("C_0603", "C_0805"); err != nil {
+		t.Fatalf("failed to rename footprint: %s", err)
+	}
+
+	// A separate library object for the same directory has nothing
+	// cached, so it must read everything from disk.
+	lib2, err := OpenFootprintLibrary(dir)
+	if err != nil {
+		t.Fatalf("failed to open library: %s", err)
+	}
+	names, err := lib2.Names()
+	if err != nil {
+		t.Fatalf("failed to list footprints: %s", err)
+	}
+	if got, want := names, []string{"C_0805", "R_0603"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong footprint names %#v; want %#v", got, want)
+	}
+	renamed, err := lib2.Footprint("C_0805")
+	if err != nil {
+		t.Fatalf("failed to load footprint: %s", err)
+	}
+	if got, want := renamed.LibID, "C_0805"; got != want {
+		t.Errorf("wrong LibID in renamed footprint %q; want %q", got, want)
+	}
+	if got, want := len(renamed.Pads), 1; got != want {
+		t.Errorf("wrong number of pads %d; want %d", got, want)
+	}
+
+	if err := lib2.Delete("R_0603"); err != nil {
+		t.Fatalf("failed to delete footprint: %s", err)
+	}
+	if _, err := lib2.Footprint("R_0603"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("loading deleted footprint should fail with ErrNotExist; got %v", err)
+	}
+	if _, err := lib2.Footprint("../R_0603"); err == nil {
+		t.Errorf("loading footprint with invalid name should fail")
+	}
+}
+
+func TestFootprintLibraryAddPlaced(t *testing.T) {
+	lib, err := CreateFootprintLibrary(filepath.Join(t.TempDir(), "Test.pretty"))
+	if err != nil {
+		t.Fatalf("failed to create library: %s", err)
+	}
+
+	// A footprint as placed on a board, rotated by 90 degrees
+	fp := &Footprint{
+		LibID:     "Other:R_0603",
+		Layer:     "F.Cu",
+		UUID:      "7d0f6a34-5b7e-4bc4-9d4c-1ad3c9e4b1f0",
+		At:        PositionAngle{X: 110, Y: 90, Angle: 90},
+		Path:      "/0b4e7f6a-8b0b-4d6f-9a53-4f1c8a3e6a21",
+		SheetName: "Root",
+		SheetFile: "board.kicad_sch",
+		Properties: []FootprintProperty{
+			{Name: "Reference", Value: "R1", At: PositionAngle{Y: -1.4, Angle: 90}},
+		},
+		Pads: []Pad{
+			{Number: "1", Type: "smd", Shape: "rect", At: PositionAngle{X: -0.8, Angle: 90}, Size: Size{Width: 1, Height: 1}},
+		},
+	}
+	if err := lib.Add("R_0603", fp); err != nil {
+		t.Fatalf("failed to add footprint: %s", err)
+	}
+	if got, want := fp.Pads[0].At.Angle, 90.0; got != want {
+		t.Errorf("Add modified the given footprint's pad angle to %g", got)
+	}
+
+	got, err := ReadFootprintFile(filepath.Join(lib.Dir, "R_0603.kicad_mod"))
+	if err != nil {
+		t.Fatalf("failed to read footprint file: %s", err)
+	}
+	want := &Footprint{
+		LibID: "R_0603",
+		Layer: "F.Cu",
+		Properties: []FootprintProperty{
+			{Name: "Reference", Value: "R1", At: PositionAngle{Y: -1.4}},
+		},
+		Pads: []Pad{
+			{Number: "1", Type: "smd", Shape: "rect", At: PositionAngle{X: -0.8}, Size: Size{Width: 1, Height: 1}},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("incorrect result\ngot:  %swant: %s", spew.Sdump(got), spew.Sdump(want))
+	}
+
+	// The cached footprint must not share anything with the given one.
+	fp.Pads[0].Number = "2"
+	cached, err := lib.Footprint("R_0603")
+	if err != nil {
+		t.Fatalf("failed to load footprint: %s", err)
+	}
+	if got, want := cached.Pads[0].Number, "1"; got != want {
+		t.Errorf("cached footprint has pad %q; want %q", got, want)
+	}
+
+	// A footprint on the back of the board can't be stored unflipped.
+	fp.Layer = "B.Cu"
+	if err := lib.Add("R_0603_Back", fp); err == nil {
+		t.Errorf("adding a footprint on the back of the board should fail")
+	}
+	if _, err := os.Stat(filepath.Join(lib.Dir, "R_0603_Back.kicad_mod")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("failed Add should not create a file; got %v", err)
+	}
+}
+
+func TestFootprintLibraryRename(t *testing.T) {
+	lib, err := CreateFootprintLibrary(filepath.Join(t.TempDir(), "Test.pretty"))
+	if err != nil {
+		t.Fatalf("failed to create library: %s", err)
+	}
+	// The comment and unknown_thing are not captured by the Footprint
+	// structure, so they would be lost if the file were re-encoded.
+	src := `(footprint "C_0603" (version 20221018) (generator pcbnew)
+  (layer "F.Cu")
+  # keep this comment
+  (unknown_thing 1)
+)
+`
+	if err := os.WriteFile(filepath.Join(lib.Dir, "C_0603.kicad_mod"), []byte(src), 0666); err != nil {
+		t.Fatalf("failed to write footprint file: %s", err)
+	}
+	if err := os.WriteFile(filepath.Join(lib.Dir, "R_0603.kicad_mod"), []byte(src), 0666); err != nil {
+		t.Fatalf("failed to write footprint file: %s", err)
+	}
+	if _, err := lib.Footprint("C_0603"); err != nil {
+		t.Fatalf("failed to load footprint: %s", err)
+	}
+
+	if err := lib.Rename("C_0603", "R_0603"); !errors.Is(err, os.ErrExist) {
+		t.Errorf("renaming over existing footprint should fail with ErrExist; got %v", err)
+	}
+	if err := lib.Rename("C_0603", "C 0805"); err != nil {
+		t.Fatalf("failed to rename footprint: %s", err)
+	}
+
+	got, err := os.ReadFile(filepath.Join(lib.Dir, "C 0805.kicad_mod"))
+	if err != nil {
+		t.Fatalf("failed to read renamed file: %s", err)
+	}
+	want := strings.Replace(src, `"C_0603"`, `"C 0805"`, 1)
+	if string(got) != want {
+		t.Errorf("incorrect result\ngot:\n%s\nwant:\n%s", got, want)
+	}
+	if _, err := lib.Footprint("C_0603"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("loading old name should fail with ErrNotExist; got %v", err)
+	}
+	renamed, err := lib.Footprint("C 0805")
+	if err != nil {
+		t.Fatalf("failed to load footprint: %s", err)
+	}
+	if got, want := renamed.LibID, "C 0805"; got != want {
+		t.Errorf("wrong LibID in renamed footprint %q; want %q", got, want)
+	}
+
+	// Only the renamed and untouched files should be left.
+	entries, err := os.ReadDir(lib.Dir)
+	if err != nil {
+		t.Fatalf("failed to read library directory: %s", err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	if want := []string{"C 0805.kicad_mod", "R_0603.kicad_mod"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("wrong files after rename %q; want %q", names, want)
+	}
+}
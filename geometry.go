@@ -0,0 +1,200 @@
+package kicad
+
+import (
+	"fmt"
+	"math"
+)
+
+// Position is a point in a document, in millimeters. The Y axis increases
+// downwards, as on the screen.
+type Position struct {
+	X float64 `kicad:""`
+	Y float64 `kicad:""`
+}
+
+// PositionAngle is a point in a document along with a rotation angle in
+// degrees, counterclockwise as seen from above.
+//
+// Unlocked is used only for footprint text, where it indicates that the
+// text does not rotate along with its footprint.
+type PositionAngle struct {
+	X        float64 `kicad:""`
+	Y        float64 `kicad:""`
+	Angle    float64 `kicad:",optional"`
+	Unlocked bool    `kicad:"unlocked,bare"`
+}
+
+// Position returns the point part of the receiver, without its angle.
+func (p PositionAngle) Position() Position {
+	return Position{X: p.X, Y: p.Y}
+}
+
+type BoundingBox struct {
+	X1 float64 `kicad:""`
+	Y1 float64 `kicad:""`
+	X2 float64 `kicad:""`
+	Y2 float64 `kicad:""`
+}
+
+type Size struct {
+	Width  float64 `kicad:""`
+	Height float64 `kicad:""`
+}
+
+// Vector3 is a three-dimensional vector, used to describe the placement of
+// 3D models.
+type Vector3 struct {
+	X float64 `kicad:""`
+	Y float64 `kicad:""`
+	Z float64 `kicad:""`
+}
+
+// Points is a list of points, as used to describe polygons and curves.
+//
+// Since KiCad 7 a polygon outline can also include arcs, so each item of
+// the list is either a single point or an arc, in the order they appear
+// along the outline.
+type Points struct {
+	Items []PointsItem `kicad:"xy|arc,multi,choice"`
+}
+
+// PointsItem is an item of a Points list, which is either a single point
+// in XY or an arc segment in Arc.
+type PointsItem struct {
+	XY  *Position `kicad:"xy,flat"`
+	Arc *PointArc `kicad:"arc,flat"`
+}
+
+// PointArc is an arc segment within a Points list, passing through Mid.
+type PointArc struct {
+	Start Position `kicad:"start,flat"`
+	Mid   Position `kicad:"mid,flat"`
+	End   Position `kicad:"end,flat"`
+}
+
+// NewPoints returns a Points list of the given single points.
+func NewPoints(xy ...Position) Points {
+	items := make([]PointsItem, len(xy))
+	for i := range xy {
+		items[i].XY = &xy[i]
+	}
+	return Points{Items: items}
+}
+
+// Polyline returns the points of the list in order, with each arc
+// approximated by straight segments as by ArcPoints.
+func (p Points) Polyline() []Position {
+	ret := make([]Position, 0, len(p.Items))
+	for _, item := range p.Items {
+		switch {
+		case item.XY != nil:
+			ret = append(ret, *item.XY)
+		case item.Arc != nil:
+			arc := ArcPoints(item.Arc.Start, item.Arc.Mid, item.Arc.End)
+			if len(ret) > 0 && ret[len(ret)-1] == arc[0] {
+				arc = arc[1:]
+			}
+			ret = append(ret, arc...)
+		}
+	}
+	return ret
+}
+
+// HasArcs returns true if any of the items of the list are arcs.
+func (p Points) HasArcs() bool {
+	for _, item := range p.Items {
+		if item.Arc != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// Color is an RGBA color, with each of the color channels between 0 and
+// 255 and an alpha channel between 0 and 1. The zero value represents the
+// default color for whatever item it is used with.
+type Color struct {
+	R int     `kicad:""`
+	G int     `kicad:""`
+	B int     `kicad:""`
+	A float64 `kicad:""`
+}
+
+// Hex returns the color in the hexadecimal form used by HTML and SVG, such
+// as "#C83434". The alpha channel is not included.
+func (c Color) Hex() string {
+	return fmt.Sprintf("#%02X%02X%02X", c.R, c.G, c.B)
+}
+
+// Stroke describes how a line or outline is drawn. Type is a line style
+// such as "solid", "dash" or "dot", or "default".
+type Stroke struct {
+	Width float64 `kicad:"width"`
+	Type  string  `kicad:"type"`
+	Color Color   `kicad:"color,flat"`
+}
+
+// LineWidth returns the width of lines drawn with the stroke. KiCad 5
+// documents give the width of a graphic item directly rather than in its
+// stroke, so the given width of the item is returned if the stroke has none.
+func (s Stroke) LineWidth(width float64) float64 {
+	if s.Width > 0 {
+		return s.Width
+	}
+	return width
+}
+
+// ArcCenter finds the center of the circle passing through the three given
+// points. The result is false if the points are collinear, in which case
+// there is no such circle.
+func ArcCenter(start, mid, end Position) (Position, bool) {
+	ax, ay := start.X, start.Y
+	bx, by := mid.X, mid.Y
+	cx, cy := end.X, end.Y
+
+	d := 2 * (ax*(by-cy) + bx*(cy-ay) + cx*(ay-by))
+	if math.Abs(d) < 1e-12 {
+		return Position{}, false
+	}
+
+	a2 := ax*ax + ay*ay
+	b2 := bx*bx + by*by
+	c2 := cx*cx + cy*cy
+	return Position{
+		X: (a2*(by-cy) + b2*(cy-ay) + c2*(ay-by)) / d,
+		Y: (a2*(cx-bx) + b2*(ax-cx) + c2*(bx-ax)) / d,
+	}, true
+}
+
+// ArcSweep returns the angle in radians swept by an arc around center from
+// start through mid to end. The result is positive if the arc runs in the
+// direction of increasing angle in document coordinates, which is clockwise
+// as seen on screen since the Y axis increases downwards.
+func ArcSweep(center, start, mid, end Position) float64 {
+	a0 := math.Atan2(start.Y-center.Y, start.X-center.X)
+	a1 := math.Atan2(mid.Y-center.Y, mid.X-center.X)
+	a2 := math.Atan2(end.Y-center.Y, end.X-center.X)
+
+	sweep := normalizeAngle(a2 - a0)
+	if normalizeAngle(a1-a0) > sweep {
+		// mid isn't between start and end going the positive way, so
+		// the arc must go the other way around.
+		sweep -= 2 * math.Pi
+	}
+	return sweep
+}
+
+// normalizeAngle returns the given angle in radians as an equivalent angle
+// in the range [0, 2π).
+func normalizeAngle(a float64) float64 {
+	a = math.Mod(a, 2*math.Pi)
+	if a < 0 {
+		a += 2 * math.Pi
+	}
+	return a
+}
+
+// Distance returns the distance between two positions.
+func Distance(a, b Position) float64 {
+	return math.Hypot(b.X-a.X, b.Y-a.Y)
+}
@@ -0,0 +1,434 @@
+package gerber
+
+import (
+	"math"
+	"slices"
+	"strings"
+
+	"github.com/apparentlymart/go-kicad"
+	"github.com/apparentlymart/go-kicad/strokefont"
+)
+
+// defaultLineWidth is the width in millimeters of graphic items that have
+// no width of their own, when the board's plot settings don't give one.
+const defaultLineWidth = 0.1
+
+// layerPlot plots the items of a board that are on one of its layers.
+type layerPlot struct {
+	*plotter
+	pcb    *kicad.PCB
+	layer  string
+	copper []string
+
+	isCopper  bool
+	lineWidth float64
+}
+
+func newLayerPlot(p *plotter, pcb *kicad.PCB, layer string) *layerPlot {
+	lp := &layerPlot{
+		plotter:   p,
+		pcb:       pcb,
+		layer:     layer,
+		copper:    pcb.CopperLayers(),
+		isCopper:  strings.HasSuffix(layer, ".Cu"),
+		lineWidth: pcb.Setup.PlotParams.LineWidth,
+	}
+	if lp.lineWidth <= 0 {
+		lp.lineWidth = defaultLineWidth
+	}
+	return lp
+}
+
+// plot draws everything on the layer. Knockout text is drawn first, since
+// the text is cleared from a box that would otherwise hide other items.
+func (lp *layerPlot) plot() {
+	for i := range lp.pcb.Texts {
+		text := &lp.pcb.Texts[i]
+		if text.Layer.Name == lp.layer && text.Layer.Knockout && !text.Effects.Hide {
+			lp.knockoutText(text)
+		}
+	}
+	lp.zones()
+	lp.tracks()
+	lp.vias()
+	lp.pads()
+	lp.graphics()
+	lp.texts()
+}
+
+// graphicFunction returns the .AperFunction attribute value for graphic
+// items on the layer.
+func (lp *layerPlot) graphicFunction() string {
+	switch {
+	case lp.layer == "Edge.Cuts":
+		return "Profile"
+	case lp.isCopper:
+		return "NonConductor"
+	default:
+		return ""
+	}
+}
+
+func (lp *layerPlot) netAttrs(net int) []string {
+	if !lp.isCopper {
+		return nil
+	}
+	return []string{".N," + escapeField(lp.pcb.NetName(net))}
+}
+
+func (lp *layerPlot) zones() {
+	function := ""
+	if lp.isCopper {
+		function = "Conductor"
+	}
+	plotZone := func(z *kicad.Zone) {
+		if z.IsRuleArea() || !kicad.OnLayer(z.LayerNames(), lp.layer) {
+			return
+		}
+		lp.setObject(lp.netAttrs(z.Net)...)
+		for _, poly := range z.Filled {
+			layer := poly.Layer
+			if layer == "" {
+				layer = z.Layer
+			}
+			if layer != lp.layer {
+				continue
+			}
+			lp.region(poly.Points.Polyline(), function)
+			if z.FilledAreasThickness && z.MinThickness > 0 {
+				lp.stroke(kicad.ClosePolyline(poly.Points.Polyline()), z.MinThickness, function)
+			}
+		}
+	}
+	for i := range lp.pcb.Zones {
+		plotZone(&lp.pcb.Zones[i])
+	}
+	for i := range lp.pcb.Footprints {
+		fp := &lp.pcb.Footprints[i]
+		for j := range fp.Zones {
+			plotZone(&fp.Zones[j])
+		}
+	}
+}
+
+func (lp *layerPlot) tracks() {
+	for _, seg := range lp.pcb.Segments {
+		if seg.Layer != lp.layer {
+			continue
+		}
+		lp.setObject(lp.netAttrs(seg.Net)...)
+		lp.stroke([]kicad.Position{seg.Start, seg.End}, seg.Width, "Conductor")
+	}
+	for _, arc := range lp.pcb.Arcs {
+		if arc.Layer != lp.layer {
+			continue
+		}
+		lp.setObject(lp.netAttrs(arc.Net)...)
+		lp.drawArc(arc.Start, arc.Mid, arc.End, arc.Width, "Conductor")
+	}
+}
+
+func (lp *layerPlot) vias() {
+	mask := lp.layer == "F.Mask" || lp.layer == "B.Mask"
+	if !lp.isCopper && !(mask && lp.pcb.Setup.PlotParams.ViasOnMask) {
+		return
+	}
+	for _, via := range lp.pcb.Vias {
+		layers := lp.viaLayers(&via)
+		if lp.isCopper {
+			if !slices.Contains(layers, lp.layer) {
+				continue
+			}
+			lp.setObject(lp.netAttrs(via.Net)...)
+			lp.flash(via.At, lp.circleAperture(via.Size, "ViaPad"))
+			continue
+		}
+		outer := "F.Cu"
+		if lp.layer == "B.Mask" {
+			outer = "B.Cu"
+		}
+		if !slices.Contains(layers, outer) {
+			continue
+		}
+		lp.setObject()
+		lp.flash(via.At, lp.circleAperture(via.Size+2*lp.pcb.Setup.PadToMaskClearance, ""))
+	}
+}
+
+// viaLayers returns the copper layers that the given via is on. A through
+// via is on all of them, while blind and micro vias are on the layers
+// between the pair given in the via.
+func (lp *layerPlot) viaLayers(via *kicad.Via) []string {
+	if via.Type == "" || len(via.Layers) != 2 {
+		return lp.copper
+	}
+	from, to := slices.Index(lp.copper, via.Layers[0]), slices.Index(lp.copper, via.Layers[1])
+	if from < 0 || to < 0 {
+		return via.Layers
+	}
+	if from > to {
+		from, to = to, from
+	}
+	return lp.copper[from : to+1]
+}
+
+func (lp *layerPlot) pads() {
+	for i := range lp.pcb.Footprints {
+		fp := &lp.pcb.Footprints[i]
+		for j := range fp.Pads {
+			pad := &fp.Pads[j]
+			if !kicad.OnLayer(pad.Layers, lp.layer) {
+				continue
+			}
+			lp.pad(fp, pad)
+		}
+	}
+}
+
+func (lp *layerPlot) pad(fp *kicad.Footprint, pad *kicad.Pad) {
+	margin := lp.pcb.PadMargin(fp, pad, lp.layer)
+	function := ""
+	switch {
+	case lp.isCopper:
+		if pad.Type == "np_thru_hole" && pad.Size.Width <= pad.Drill.Width && pad.Size.Height <= math.Max(pad.Drill.Width, pad.Drill.Height) {
+			// A hole without any copper around it
+			return
+		}
+		function = padFunction(pad)
+		attrs := []string{".P," + escapeField(fp.Reference()) + "," + escapeField(pad.Number)}
+		if pad.PinFunction != "" {
+			attrs[0] += "," + escapeField(pad.PinFunction)
+		}
+		if pad.Net.Name != "" || pad.Net.Number != 0 {
+			name := pad.Net.Name
+			if name == "" {
+				name = lp.pcb.NetName(pad.Net.Number)
+			}
+			attrs = append(attrs, ".N,"+escapeField(name))
+		}
+		attrs = append(attrs, ".C,"+escapeField(fp.Reference()))
+		lp.setObject(attrs...)
+	default:
+		lp.setObject()
+	}
+	lp.flashPad(fp, pad, margin, function)
+}
+
+// graphics draws the graphic items of the board and of its footprints.
+func (lp *layerPlot) graphics() {
+	lp.setObject()
+	pcb := lp.pcb
+	lp.graphicItems(pcb.GraphicLines, pcb.GraphicArcs, pcb.GraphicCircles, pcb.GraphicRects, pcb.GraphicPolys, pcb.GraphicCurves, nil)
+	for i := range pcb.Footprints {
+		fp := &pcb.Footprints[i]
+		lp.graphicItems(fp.Lines, fp.Arcs, fp.Circles, fp.Rects, fp.Polys, fp.Curves, fp)
+	}
+}
+
+// graphicItems draws the given graphic items that are on the layer. If fp
+// is not nil then the items belong to that footprint, and their positions
+// are relative to it.
+func (lp *layerPlot) graphicItems(lines []kicad.GraphicLine, arcs []kicad.GraphicArc, circles []kicad.GraphicCircle, rects []kicad.GraphicRect, polys []kicad.GraphicPoly, curves []kicad.GraphicCurve, fp *kicad.Footprint) {
+	tr := func(p kicad.Position) kicad.Position {
+		if fp == nil {
+			return p
+		}
+		return fp.BoardPosition(p)
+	}
+	function := lp.graphicFunction()
+	fill := lp.layer != "Edge.Cuts"
+
+	for _, line := range lines {
+		if line.Layer == lp.layer {
+			lp.stroke([]kicad.Position{tr(line.Start), tr(line.End)}, lp.width(line.Width, line.Stroke), function)
+		}
+	}
+	for _, arc := range arcs {
+		if arc.Layer == lp.layer {
+			lp.drawArc(tr(arc.Start), tr(arc.Mid), tr(arc.End), lp.width(arc.Width, arc.Stroke), function)
+		}
+	}
+	for _, circle := range circles {
+		if circle.Layer != lp.layer {
+			continue
+		}
+		center, end := tr(circle.Center), tr(circle.End)
+		width := lp.width(circle.Width, circle.Stroke)
+		if fill && circle.Filled() {
+			lp.flash(center, lp.circleAperture(2*kicad.Distance(center, end)+width, function))
+			continue
+		}
+		lp.arc(end, center, end, false, width, function)
+	}
+	for _, rect := range rects {
+		if rect.Layer != lp.layer {
+			continue
+		}
+		pts := kicad.TransformPoints(kicad.RectPoints(rect.Start, rect.End), tr)
+		if fill && rect.Filled() {
+			lp.region(pts, function)
+		}
+		lp.stroke(kicad.ClosePolyline(pts), lp.width(rect.Width, rect.Stroke), function)
+	}
+	for _, poly := range polys {
+		if poly.Layer != lp.layer {
+			continue
+		}
+		pts := kicad.TransformPoints(poly.Points.Polyline(), tr)
+		if fill && poly.Filled() {
+			lp.region(pts, function)
+		}
+		lp.stroke(kicad.ClosePolyline(pts), lp.width(poly.Width, poly.Stroke), function)
+	}
+	for _, curve := range curves {
+		if curve.Layer == lp.layer {
+			lp.stroke(kicad.TransformPoints(kicad.BezierPoints(curve.Points.Polyline()), tr), lp.width(curve.Width, curve.Stroke), function)
+		}
+	}
+}
+
+// width returns the line width of a graphic item, or the default line
+// width for items without one.
+func (lp *layerPlot) width(width float64, stroke kicad.Stroke) float64 {
+	if w := stroke.LineWidth(width); w > 0 {
+		return w
+	}
+	return lp.lineWidth
+}
+
+// drawArc draws the arc from start through mid to end, or a straight line
+// if the points are collinear.
+func (lp *layerPlot) drawArc(start, mid, end kicad.Position, width float64, function string) {
+	center, ok := kicad.ArcCenter(start, mid, end)
+	if !ok {
+		lp.stroke([]kicad.Position{start, end}, width, function)
+		return
+	}
+	clockwise := kicad.ArcSweep(center, start, mid, end) > 0
+	lp.arc(start, center, end, clockwise, width, function)
+}
+
+// texts draws the text of the board and of its footprints.
+func (lp *layerPlot) texts() {
+	lp.setObject()
+	function := lp.graphicFunction()
+	for _, text := range lp.pcb.Texts {
+		if text.Layer.Name == lp.layer && !text.Layer.Knockout && !text.Effects.Hide {
+			lp.text(text.Text, text.At, &text.Effects, function)
+		}
+	}
+	for _, dim := range lp.pcb.Dimensions {
+		if dim.Layer == lp.layer && dim.Text.Text != "" && !dim.Text.Effects.Hide {
+			lp.text(dim.Text.Text, dim.Text.At, &dim.Text.Effects, function)
+		}
+	}
+	for i := range lp.pcb.TextBoxes {
+		if box := &lp.pcb.TextBoxes[i]; box.Layer.Name == lp.layer {
+			lp.textBox(box, function)
+		}
+	}
+	for i := range lp.pcb.Footprints {
+		fp := &lp.pcb.Footprints[i]
+		for _, text := range fp.Texts {
+			if text.Layer.Name != lp.layer || text.Hide || text.Effects.Hide {
+				continue
+			}
+			lp.text(fp.ExpandText(text.Text), fp.BoardTextAt(text.At), &text.Effects, function)
+		}
+		for _, prop := range fp.Properties {
+			if prop.Layer.Name != lp.layer || prop.Hide || prop.Effects.Hide {
+				continue
+			}
+			lp.text(fp.ExpandText(prop.Value), fp.BoardTextAt(prop.At), &prop.Effects, function)
+		}
+	}
+}
+
+func (lp *layerPlot) text(text string, at kicad.PositionAngle, effects *kicad.TextEffects, function string) {
+	width := strokefont.Thickness(effects)
+	for _, stroke := range strokefont.Strokes(text, at, effects) {
+		lp.stroke(stroke, width, function)
+	}
+}
+
+// knockoutText draws a filled box around the given text with the text
+// itself cleared from it.
+func (lp *layerPlot) knockoutText(text *kicad.BoardText) {
+	lp.setObject()
+	effects := &text.Effects
+	width := strokefont.Thickness(effects)
+
+	// Lay the text out unrotated at the origin to find the box around it,
+	// and then rotate both the box and the text into place.
+	strokes := strokefont.Strokes(text.Text, kicad.PositionAngle{}, effects)
+	if len(strokes) == 0 {
+		return
+	}
+	min, max := strokes[0][0], strokes[0][0]
+	for _, stroke := range strokes {
+		for _, p := range stroke {
+			min.X, min.Y = math.Min(min.X, p.X), math.Min(min.Y, p.Y)
+			max.X, max.Y = math.Max(max.X, p.X), math.Max(max.Y, p.Y)
+		}
+	}
+	margin := width/2 + effects.Font.Size.Height/5
+	min.X, min.Y = min.X-margin, min.Y-margin
+	max.X, max.Y = max.X+margin, max.Y+margin
+
+	place := func(p kicad.Position) kicad.Position {
+		p = kicad.RotatePoint(p, text.At.Angle)
+		return kicad.Position{X: p.X + text.At.X, Y: p.Y + text.At.Y}
+	}
+	lp.region(kicad.TransformPoints(kicad.RectPoints(min, max), place), "")
+	lp.setClear(true)
+	for _, stroke := range strokes {
+		lp.stroke(kicad.TransformPoints(stroke, place), width, "")
+	}
+	lp.setClear(false)
+}
+
+// textBox draws the border of a text box, if it has one, and its text
+// positioned within the box's margins.
+func (lp *layerPlot) textBox(box *kicad.TextBox, function string) {
+	corners := box.Points.Polyline()
+	if len(corners) != 4 {
+		corners = kicad.RectPoints(box.Start, box.End)
+	}
+	if box.Border {
+		lp.stroke(kicad.ClosePolyline(corners), lp.width(0, box.Stroke), function)
+	}
+	if box.Effects.Hide {
+		return
+	}
+
+	// Work in the box's own unrotated frame, relative to its first
+	// corner, to find where the text is anchored.
+	origin := corners[0]
+	local := func(p kicad.Position) kicad.Position {
+		return kicad.RotatePoint(kicad.Position{X: p.X - origin.X, Y: p.Y - origin.Y}, -box.Angle)
+	}
+	a, b := local(corners[0]), local(corners[2])
+	left, right := math.Min(a.X, b.X)+box.Margins.Left, math.Max(a.X, b.X)-box.Margins.Right
+	top, bottom := math.Min(a.Y, b.Y)+box.Margins.Top, math.Max(a.Y, b.Y)-box.Margins.Bottom
+
+	var anchor kicad.Position
+	switch {
+	case box.Effects.Justify.Right:
+		anchor.X = right
+	case box.Effects.Justify.Left:
+		anchor.X = left
+	default:
+		anchor.X = (left + right) / 2
+	}
+	switch {
+	case box.Effects.Justify.Bottom:
+		anchor.Y = bottom
+	case box.Effects.Justify.Top:
+		anchor.Y = top
+	default:
+		anchor.Y = (top + bottom) / 2
+	}
+	anchor = kicad.RotatePoint(anchor, box.Angle)
+	at := kicad.PositionAngle{X: origin.X + anchor.X, Y: origin.Y + anchor.Y, Angle: box.Angle}
+	lp.text(box.Text, at, &box.Effects, function)
+}
@@ -0,0 +1,165 @@
+package gerber
+
+import (
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/apparentlymart/go-kicad"
+	"github.com/apparentlymart/go-kicad/drill"
+)
+
+// minSymbolSize is the smallest size in millimeters of the symbols marking
+// holes in a drill map, so that the symbols of small holes are legible.
+const minSymbolSize = 0.5
+
+// legendPitch is the distance between the lines of a drill map's legend,
+// and legendTextSize is the size of its text.
+const (
+	legendPitch    = 2.5
+	legendTextSize = 1.0
+)
+
+// WriteDrillMap writes a Gerber drill map for the given drill file of the
+// given board, which shows the board outline with a symbol marking each
+// hole and a legend below the board listing the symbol of each tool.
+func WriteDrillMap(w io.Writer, pcb *kicad.PCB, f *drill.File, opts *Options) error {
+	if opts == nil {
+		opts = &Options{}
+	}
+	var origin kicad.Position
+	if opts.UseAuxOrigin {
+		origin = pcb.Setup.AuxAxisOrigin
+	}
+	p := newPlotter(origin)
+	lp := newLayerPlot(p, pcb, "Edge.Cuts")
+	lp.graphics()
+
+	for i := range f.Holes {
+		hole := &f.Holes[i]
+		t := f.Tool(hole)
+		lp.drillSymbol(hole.Start, symbolSize(t.Diameter), t.Number-1)
+		if hole.IsSlot() {
+			lp.slotOutline(hole)
+		}
+	}
+
+	min, max, ok := pcb.OutlineBounds()
+	if !ok {
+		for i, hole := range f.Holes {
+			if i == 0 {
+				min, max = hole.Start, hole.Start
+			}
+			for _, pt := range []kicad.Position{hole.Start, hole.End} {
+				min.X, min.Y = math.Min(min.X, pt.X), math.Min(min.Y, pt.Y)
+				max.X, max.Y = math.Max(max.X, pt.X), math.Max(max.Y, pt.Y)
+			}
+		}
+	}
+	effects := &kicad.TextEffects{
+		Font:    kicad.Font{Size: kicad.TextSize{Height: legendTextSize, Width: legendTextSize}},
+		Justify: kicad.TextJustify{Left: true},
+	}
+	y := max.Y + 2*legendPitch
+	for i := range f.Tools {
+		t := &f.Tools[i]
+		lp.drillSymbol(kicad.Position{X: min.X + legendTextSize, Y: y}, legendTextSize, t.Number-1)
+		text := fmt.Sprintf("%.2fmm  %d", t.Diameter, t.Holes)
+		if t.Holes == 1 {
+			text += " hole"
+		} else {
+			text += " holes"
+		}
+		if t.Slots > 0 {
+			text += fmt.Sprintf("  (%d with slots)", t.Slots)
+		}
+		at := kicad.PositionAngle{X: min.X + 3*legendTextSize, Y: y}
+		lp.text(text, at, effects, "")
+		y += legendPitch
+	}
+
+	attrs := []string{"GenerationSoftware,apparentlymart,go-kicad"}
+	if !opts.CreationDate.IsZero() {
+		attrs = append(attrs, "CreationDate,"+opts.CreationDate.Format("2006-01-02T15:04:05-07:00"))
+	}
+	attrs = append(attrs,
+		"SameCoordinates,Original",
+		"FileFunction,Drillmap",
+		"FilePolarity,Positive",
+	)
+	return p.writeTo(w, attrs)
+}
+
+func symbolSize(diameter float64) float64 {
+	return math.Max(diameter, minSymbolSize)
+}
+
+// drillSymbol draws the symbol for the tool with the given index, centered
+// on the given position. There are a few distinct symbols, which repeat
+// for boards that use more tools than that.
+func (lp *layerPlot) drillSymbol(at kicad.Position, size float64, index int) {
+	r := size / 2
+	pt := func(x, y float64) kicad.Position {
+		return kicad.Position{X: at.X + x*r, Y: at.Y + y*r}
+	}
+	plus := func() {
+		lp.stroke([]kicad.Position{pt(-1, 0), pt(1, 0)}, lp.lineWidth, "")
+		lp.stroke([]kicad.Position{pt(0, -1), pt(0, 1)}, lp.lineWidth, "")
+	}
+	cross := func() {
+		lp.stroke([]kicad.Position{pt(-1, -1), pt(1, 1)}, lp.lineWidth, "")
+		lp.stroke([]kicad.Position{pt(-1, 1), pt(1, -1)}, lp.lineWidth, "")
+	}
+	circle := func() {
+		start := pt(1, 0)
+		lp.arc(start, at, start, false, lp.lineWidth, "")
+	}
+	square := func() {
+		lp.stroke([]kicad.Position{pt(-1, -1), pt(1, -1), pt(1, 1), pt(-1, 1), pt(-1, -1)}, lp.lineWidth, "")
+	}
+	diamond := func() {
+		lp.stroke([]kicad.Position{pt(0, -1), pt(1, 0), pt(0, 1), pt(-1, 0), pt(0, -1)}, lp.lineWidth, "")
+	}
+
+	switch index % 8 {
+	case 0:
+		plus()
+	case 1:
+		cross()
+	case 2:
+		plus()
+		circle()
+	case 3:
+		cross()
+		square()
+	case 4:
+		plus()
+		diamond()
+	case 5:
+		cross()
+		circle()
+	case 6:
+		plus()
+		square()
+	case 7:
+		cross()
+		diamond()
+	}
+}
+
+// slotOutline draws the outline of a slot, as two straight sides joined by
+// semicircles around its ends.
+func (lp *layerPlot) slotOutline(hole *drill.Hole) {
+	r := hole.Diameter / 2
+	l := kicad.Distance(hole.Start, hole.End)
+	d := kicad.Position{X: (hole.End.X - hole.Start.X) / l * r, Y: (hole.End.Y - hole.Start.Y) / l * r}
+	n := kicad.Position{X: -d.Y, Y: d.X}
+	add := func(a, b kicad.Position, k float64) kicad.Position {
+		return kicad.Position{X: a.X + k*b.X, Y: a.Y + k*b.Y}
+	}
+	s, e := hole.Start, hole.End
+	lp.stroke([]kicad.Position{add(s, n, 1), add(e, n, 1)}, lp.lineWidth, "")
+	lp.drawArc(add(e, n, 1), add(e, d, 1), add(e, n, -1), lp.lineWidth, "")
+	lp.stroke([]kicad.Position{add(e, n, -1), add(s, n, -1)}, lp.lineWidth, "")
+	lp.drawArc(add(s, n, -1), add(s, d, -1), add(s, n, 1), lp.lineWidth, "")
+}
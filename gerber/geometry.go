@@ -0,0 +1,11 @@
+package gerber
+
+import (
+	"github.com/apparentlymart/go-kicad"
+)
+
+// flipY converts a position between document coordinates, where Y
+// increases downwards, and Gerber coordinates, where it increases upwards.
+func flipY(p kicad.Position) kicad.Position {
+	return kicad.Position{X: p.X, Y: -p.Y}
+}
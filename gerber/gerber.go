@@ -0,0 +1,161 @@
+// Package gerber writes the layers of a KiCad board as Gerber X2 files, the
+// format that board manufacturers take as the artwork for each layer.
+//
+// The files include the X2 attributes that describe the function of each
+// file and aperture, along with the nets, pads and components of the
+// copper items. Text is drawn with the font from package strokefont, which
+// is not the same as KiCad's font, and so text will differ in appearance
+// from KiCad's own plots. Images and the lines and arrows of dimensions
+// are not drawn.
+//
+// WriteDrillMap also plots drill maps, which show where the holes of one of
+// the drill files from package drill are to be drilled.
+//
+// Boards from KiCad 5 describe some graphic items differently, and should
+// be upgraded using PCB.Upgrade before plotting.
+package gerber
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/apparentlymart/go-kicad"
+	"github.com/apparentlymart/go-kicad/internal/fileio"
+)
+
+// Options customizes the files that WriteLayer and WriteFiles produce. A
+// nil *Options is equivalent to a pointer to the zero value.
+type Options struct {
+	// CreationDate is recorded in the header of each file. If it is zero
+	// then no date is recorded, so that the output depends only on the
+	// board.
+	CreationDate time.Time
+
+	// UseAuxOrigin places the origin of the files at the board's
+	// auxiliary axis origin, rather than at the origin of the board
+	// document.
+	UseAuxOrigin bool
+}
+
+// Layers returns the names of the layers of the given board that a
+// manufacturer needs to make it, in the order they are conventionally
+// listed: the copper layers from top to bottom, followed by those for
+// paste, silkscreen and solder mask, and finally the board outline.
+func Layers(pcb *kicad.PCB) []string {
+	layers := pcb.CopperLayers()
+	for _, name := range []string{"F.Paste", "B.Paste", "F.SilkS", "B.SilkS", "F.Mask", "B.Mask", "Edge.Cuts"} {
+		for _, layer := range pcb.Layers {
+			if layer.Name == name {
+				layers = append(layers, name)
+				break
+			}
+		}
+	}
+	return layers
+}
+
+// FileFunction returns the value of the .FileFunction attribute of the
+// Gerber file for the given layer of the given board, such as
+// "Copper,L1,Top" or "Soldermask,Bot".
+func FileFunction(pcb *kicad.PCB, layer string) string {
+	side := "Top"
+	if strings.HasPrefix(layer, "B.") {
+		side = "Bot"
+	}
+	switch {
+	case strings.HasSuffix(layer, ".Cu"):
+		copper := pcb.CopperLayers()
+		n := slices.Index(copper, layer) + 1
+		if layer != "F.Cu" && layer != "B.Cu" {
+			side = "Inr"
+		}
+		return fmt.Sprintf("Copper,L%d,%s", n, side)
+	case layer == "F.Mask" || layer == "B.Mask":
+		return "Soldermask," + side
+	case layer == "F.Paste" || layer == "B.Paste":
+		return "Paste," + side
+	case layer == "F.SilkS" || layer == "B.SilkS":
+		return "Legend," + side
+	case layer == "F.Fab" || layer == "B.Fab":
+		return "AssemblyDrawing," + side
+	case layer == "Edge.Cuts":
+		return "Profile,NP"
+	default:
+		return "Other,User"
+	}
+}
+
+// FilePolarity returns the value of the .FilePolarity attribute of the
+// Gerber file for the given layer. Solder mask layers are negative, since
+// they show where there is no mask.
+func FilePolarity(layer string) string {
+	if layer == "F.Mask" || layer == "B.Mask" {
+		return "Negative"
+	}
+	return "Positive"
+}
+
+// Filename returns the name KiCad would give to the Gerber file for the
+// given layer, given the base name of the board file without its
+// extension.
+func Filename(base, layer string) string {
+	return base + "-" + strings.ReplaceAll(layer, ".", "_") + ".gbr"
+}
+
+// WriteLayer writes the given layer of the given board to the given writer
+// as a Gerber X2 file.
+func WriteLayer(w io.Writer, pcb *kicad.PCB, layer string, opts *Options) error {
+	if opts == nil {
+		opts = &Options{}
+	}
+	found := false
+	for _, l := range pcb.Layers {
+		if l.Name == layer {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return fmt.Errorf("board has no layer named %q", layer)
+	}
+
+	var origin kicad.Position
+	if opts.UseAuxOrigin {
+		origin = pcb.Setup.AuxAxisOrigin
+	}
+	p := newPlotter(origin)
+	newLayerPlot(p, pcb, layer).plot()
+
+	attrs := []string{"GenerationSoftware,apparentlymart,go-kicad"}
+	if !opts.CreationDate.IsZero() {
+		attrs = append(attrs, "CreationDate,"+opts.CreationDate.Format("2006-01-02T15:04:05-07:00"))
+	}
+	attrs = append(attrs,
+		"SameCoordinates,Original",
+		"FileFunction,"+FileFunction(pcb, layer),
+		"FilePolarity,"+FilePolarity(layer),
+	)
+	return p.writeTo(w, attrs)
+}
+
+// WriteFiles writes a Gerber file for each of the layers returned by Layers
+// into the given directory, naming each as Filename does. It returns the
+// paths of the files it wrote.
+func WriteFiles(dir, base string, pcb *kicad.PCB, opts *Options) ([]string, error) {
+	var ret []string
+	for _, layer := range Layers(pcb) {
+		filename := filepath.Join(dir, Filename(base, layer))
+		err := fileio.WriteFile(filename, func(w io.Writer) error {
+			return WriteLayer(w, pcb, layer, opts)
+		})
+		if err != nil {
+			return ret, err
+		}
+		ret = append(ret, filename)
+	}
+	return ret, nil
+}
@@ -0,0 +1,364 @@
+package gerber
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apparentlymart/go-kicad"
+	"github.com/apparentlymart/go-kicad/drill"
+	"github.com/apparentlymart/go-kicad/internal/testboard"
+)
+
+const testBoardSrc = `(kicad_pcb (version 20221018) (generator pcbnew)
+  (layers
+    (0 "F.Cu" signal)
+    (31 "B.Cu" signal)
+    (35 "F.Paste" user)
+    (37 "F.SilkS" user "F.Silkscreen")
+    (39 "F.Mask" user)
+    (44 "Edge.Cuts" user)
+  )
+  (setup
+    (pad_to_mask_clearance 0.05)
+    (aux_axis_origin 0 25)
+    (pcbplotparams (linewidth 0.1))
+  )
+  (net 0 "")
+  (net 1 "VCC")
+  (net 2 "GND")
+  (footprint "Test:Pads" (layer "F.Cu")
+    (at 10 10)
+    (property "Reference" "U1" (at 0 -3 0) (layer "F.SilkS") (effects (font (size 1 1) (thickness 0.15))))
+    (property "Value" "Pads" (at 0 3 0) (layer "F.Fab") (effects (font (size 1 1) (thickness 0.15))))
+    (pad "1" smd rect (at -2 0) (size 1 0.5) (layers "F.Cu" "F.Paste" "F.Mask") (net 1 "VCC"))
+    (pad "2" smd roundrect (at 0 0 45) (size 1 0.5) (layers "F.Cu" "F.Paste" "F.Mask") (roundrect_rratio 0.25) (net 2 "GND") (pinfunction "GND"))
+    (pad "3" smd trapezoid (at 2 0) (size 1 1) (rect_delta 0 0.2) (layers "F.Cu" "F.Mask") (net 2 "GND"))
+    (pad "4" smd custom (at 0 2) (size 0.5 0.5) (layers "F.Cu" "F.Mask")
+      (options (clearance outline) (anchor circle))
+      (primitives
+        (gr_poly (pts (xy 0 0) (xy 1 0) (xy 1 1)) (width 0) (fill yes))
+      )
+    )
+  )
+  (segment (start 8 10) (end 8 15) (width 0.25) (layer "F.Cu") (net 1))
+  (arc (start 12 10) (mid 13 11) (end 14 10) (width 0.25) (layer "F.Cu") (net 2))
+  (via (at 8 15) (size 0.6) (drill 0.3) (layers "F.Cu" "B.Cu") (net 1))
+  (zone (net 2) (net_name "GND") (layer "F.Cu") (hatch edge 0.5)
+    (connect_pads (clearance 0.2))
+    (min_thickness 0.2)
+    (fill yes (thermal_gap 0.5) (thermal_bridge_width 0.5))
+    (polygon (pts (xy 0 20) (xy 20 20) (xy 20 25) (xy 0 25)))
+    (filled_polygon (layer "F.Cu") (pts (xy 0.1 20.1) (xy 19.9 20.1) (xy 19.9 24.9) (xy 0.1 24.9)))
+  )
+  (gr_rect (start 0 0) (end 20 25) (layer "Edge.Cuts") (stroke (width 0.05) (type solid)) (fill none))
+  (gr_text "Hi" (at 5 5) (layer "F.SilkS") (effects (font (size 1 1) (thickness 0.15))))
+)
+`
+
+func testBoard(t *testing.T) *kicad.PCB {
+	t.Helper()
+	pcb, err := kicad.ReadPCB(strings.NewReader(testBoardSrc))
+	if err != nil {
+		t.Fatalf("reading board: %s", err)
+	}
+	return pcb
+}
+
+func TestWriteLayer_copper(t *testing.T) {
+	pcb := testBoard(t)
+	opts := &Options{
+		CreationDate: time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC),
+	}
+	var buf bytes.Buffer
+	err := WriteLayer(&buf, pcb, "F.Cu", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := buf.String()
+	want := `%TF.GenerationSoftware,apparentlymart,go-kicad*%
+%TF.CreationDate,2024-03-01T12:30:00+00:00*%
+%TF.SameCoordinates,Original*%
+%TF.FileFunction,Copper,L1,Top*%
+%TF.FilePolarity,Positive*%
+%FSLAX46Y46*%
+%MOMM*%
+%LPD*%
+G01*
+G75*
+%AMRoundRect*
+0 Rectangle with rounded corners, given by the rounding radius and the four corners of the rectangle between the centers of the rounded corners*
+4,1,4,$2,$3,$4,$5,$6,$7,$8,$9,$2,$3,0*
+1,1,$1+$1,$2,$3*
+1,1,$1+$1,$4,$5*
+1,1,$1+$1,$6,$7*
+1,1,$1+$1,$8,$9*
+20,1,$1+$1,$2,$3,$4,$5,0*
+20,1,$1+$1,$4,$5,$6,$7,0*
+20,1,$1+$1,$6,$7,$8,$9,0*
+20,1,$1+$1,$8,$9,$2,$3,0*%
+%AMOutline4P*
+0 Quadrilateral, given by its four corners*
+4,1,4,$1,$2,$3,$4,$5,$6,$7,$8,$1,$2,0*%
+%AMFreePoly2*
+1,1,0.500000,0,0*
+4,1,3,0.000000,0.000000,1.000000,0.000000,1.000000,-1.000000,0.000000,0.000000,0*%
+%TA.AperFunction,Conductor*%
+%ADD10C,0.250000*%
+%TD*%
+%TA.AperFunction,ViaPad*%
+%ADD11C,0.600000*%
+%TD*%
+%TA.AperFunction,SMDPad,CuDef*%
+%ADD12R,1.000000X0.500000*%
+%TD*%
+%TA.AperFunction,SMDPad,CuDef*%
+%ADD13RoundRect,0.125000X-0.353553X-0.176777X0.176777X0.353553X0.353553X0.176777X-0.176777X-0.353553*%
+%TD*%
+%TA.AperFunction,SMDPad,CuDef*%
+%ADD14Outline4P,-0.600000X-0.500000X-0.400000X0.500000X0.400000X0.500000X0.600000X-0.500000*%
+%TD*%
+%TA.AperFunction,SMDPad,CuDef*%
+%ADD15FreePoly2*%
+%TD*%
+%TO.N,GND*%
+%TA.AperFunction,Conductor*%
+G36*
+X100000Y-20100000D02*
+X19900000Y-20100000D01*
+X19900000Y-24900000D01*
+X100000Y-24900000D01*
+X100000Y-20100000D01*
+G37*
+%TD.AperFunction*%
+%TD*%
+%TO.N,VCC*%
+D10*
+X8000000Y-10000000D02*
+X8000000Y-15000000D01*
+%TD*%
+%TO.N,GND*%
+X12000000Y-10000000D02*
+G03*
+X14000000Y-10000000I1000000J0D01*
+G01*
+%TD*%
+%TO.N,VCC*%
+D11*
+X8000000Y-15000000D03*
+%TD*%
+%TO.P,U1,1*%
+%TO.N,VCC*%
+%TO.C,U1*%
+D12*
+X8000000Y-10000000D03*
+%TD*%
+%TO.P,U1,2,GND*%
+%TO.N,GND*%
+%TO.C,U1*%
+D13*
+X10000000Y-10000000D03*
+%TD*%
+%TO.P,U1,3*%
+%TO.N,GND*%
+%TO.C,U1*%
+D14*
+X12000000Y-10000000D03*
+%TD*%
+%TO.P,U1,4*%
+%TO.C,U1*%
+D15*
+X10000000Y-12000000D03*
+%TD*%
+M02*
+`
+	if got != want {
+		t.Errorf("incorrect result\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestWriteLayer_mask(t *testing.T) {
+	pcb := testBoard(t)
+	var buf bytes.Buffer
+	err := WriteLayer(&buf, pcb, "F.Mask", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := buf.String()
+	for _, want := range []string{
+		"%TF.FilePolarity,Negative*%\n",
+		// The pads are enlarged by the board's mask clearance
+		"%ADD10R,1.100000X0.600000*%\n",
+		"%ADD11RoundRect,0.175000X",
+		"%ADD12Outline4P,-0.650000X-0.550000X",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("missing %q in result:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "%TO") || strings.Contains(got, "CreationDate") {
+		t.Errorf("unexpected attributes in result:\n%s", got)
+	}
+	if strings.Contains(got, "X8000000Y-15000000D03*") {
+		t.Errorf("via is not tented:\n%s", got)
+	}
+
+	pcb.Setup.PlotParams.ViasOnMask = true
+	buf.Reset()
+	err = WriteLayer(&buf, pcb, "F.Mask", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); !strings.Contains(got, "%ADD10C,0.700000*%") || !strings.Contains(got, "X8000000Y-15000000D03*") {
+		t.Errorf("via is missing from mask:\n%s", got)
+	}
+}
+
+func TestWriteLayer_outline(t *testing.T) {
+	pcb := testBoard(t)
+	var buf bytes.Buffer
+	err := WriteLayer(&buf, pcb, "Edge.Cuts", &Options{UseAuxOrigin: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := buf.String()
+	want := `%TF.GenerationSoftware,apparentlymart,go-kicad*%
+%TF.SameCoordinates,Original*%
+%TF.FileFunction,Profile,NP*%
+%TF.FilePolarity,Positive*%
+%FSLAX46Y46*%
+%MOMM*%
+%LPD*%
+G01*
+G75*
+%TA.AperFunction,Profile*%
+%ADD10C,0.050000*%
+%TD*%
+D10*
+X0Y25000000D02*
+X20000000Y25000000D01*
+X20000000Y0D01*
+X0Y0D01*
+X0Y25000000D01*
+M02*
+`
+	if got != want {
+		t.Errorf("incorrect result\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestWriteLayer_text(t *testing.T) {
+	pcb := testBoard(t)
+	var buf bytes.Buffer
+	err := WriteLayer(&buf, pcb, "F.SilkS", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The board text and the footprint's reference are both drawn on the
+	// silkscreen, while the value is on the fabrication layer.
+	got := buf.String()
+	if !strings.Contains(got, "%ADD10C,0.150000*%\n") {
+		t.Errorf("missing text aperture in result:\n%s", got)
+	}
+	var strokes int
+	for _, line := range strings.Split(got, "\n") {
+		if strings.HasSuffix(line, "D02*") {
+			strokes++
+		}
+	}
+	// "Hi" is 3 strokes for H and 2 for i, and "U1" is 1 for U and 2
+	// for 1.
+	if got, want := strokes, 8; got != want {
+		t.Errorf("wrong number of strokes %d; want %d", got, want)
+	}
+
+	buf.Reset()
+	pcb.Texts[0].Layer.Knockout = true
+	err = WriteLayer(&buf, pcb, "F.SilkS", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got = buf.String()
+	for _, want := range []string{"G36*\n", "%LPC*%\n", "%LPD*%\nX9316667"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("missing %q in result:\n%s", want, got)
+		}
+	}
+}
+
+func TestWriteLayer_noLayer(t *testing.T) {
+	pcb := testBoard(t)
+	err := WriteLayer(io.Discard, pcb, "In1.Cu", nil)
+	if err == nil {
+		t.Fatal("succeeded; want error")
+	}
+}
+
+func TestLayers(t *testing.T) {
+	pcb := testBoard(t)
+	got := Layers(pcb)
+	want := []string{"F.Cu", "B.Cu", "F.Paste", "F.SilkS", "F.Mask", "Edge.Cuts"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong layers %q; want %q", got, want)
+	}
+
+	var functions []string
+	for _, layer := range got {
+		functions = append(functions, FileFunction(pcb, layer))
+	}
+	wantFunctions := []string{"Copper,L1,Top", "Copper,L2,Bot", "Paste,Top", "Legend,Top", "Soldermask,Top", "Profile,NP"}
+	if !reflect.DeepEqual(functions, wantFunctions) {
+		t.Errorf("wrong file functions %q; want %q", functions, wantFunctions)
+	}
+
+	if got, want := Filename("board", "F.SilkS"), "board-F_SilkS.gbr"; got != want {
+		t.Errorf("wrong filename %q; want %q", got, want)
+	}
+}
+
+func TestLayers_innerLayers(t *testing.T) {
+	pcb := testboard.Read(t)
+	tests := map[string]string{
+		"F.Cu":   "Copper,L1,Top",
+		"In1.Cu": "Copper,L2,Inr",
+		"In2.Cu": "Copper,L3,Inr",
+		"B.Cu":   "Copper,L4,Bot",
+	}
+	for layer, want := range tests {
+		if got := FileFunction(pcb, layer); got != want {
+			t.Errorf("wrong file function for %s %q; want %q", layer, got, want)
+		}
+	}
+}
+
+func TestWriteDrillMap(t *testing.T) {
+	pcb := testBoard(t)
+	files := drill.Files(pcb)
+	var buf bytes.Buffer
+	err := WriteDrillMap(&buf, pcb, files[0], nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := buf.String()
+	for _, want := range []string{
+		"%TF.FileFunction,Drillmap*%\n",
+		// The board outline
+		"X0Y0D02*\nX20000000Y0D01*\n",
+		// The symbol of the via, enlarged to the minimum symbol size
+		"X7750000Y-15000000D02*\nX8250000Y-15000000D01*\n",
+		// The symbol of the tool in the legend below the board
+		"X500000Y-30000000D02*\nX1500000Y-30000000D01*\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("missing %q in result:\n%s", want, got)
+		}
+	}
+}
@@ -0,0 +1,271 @@
+package gerber
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/apparentlymart/go-kicad"
+)
+
+// The aperture macros used to flash pads whose shapes the standard
+// apertures can't represent. Each takes the corners of the shape relative
+// to the flash position, already rotated, so that one macro serves pads at
+// any angle.
+var (
+	roundRectMacro = []string{
+		"0 Rectangle with rounded corners, given by the rounding radius and the four corners of the rectangle between the centers of the rounded corners",
+		"4,1,4,$2,$3,$4,$5,$6,$7,$8,$9,$2,$3,0",
+		"1,1,$1+$1,$2,$3",
+		"1,1,$1+$1,$4,$5",
+		"1,1,$1+$1,$6,$7",
+		"1,1,$1+$1,$8,$9",
+		"20,1,$1+$1,$2,$3,$4,$5,0",
+		"20,1,$1+$1,$4,$5,$6,$7,0",
+		"20,1,$1+$1,$6,$7,$8,$9,0",
+		"20,1,$1+$1,$8,$9,$2,$3,0",
+	}
+	outline4PMacro = []string{
+		"0 Quadrilateral, given by its four corners",
+		"4,1,4,$1,$2,$3,$4,$5,$6,$7,$8,$1,$2,0",
+	}
+	rotOvalMacro = []string{
+		"0 Oval, given by its width and the centers of its two ends",
+		"1,1,$1,$2,$3",
+		"1,1,$1,$4,$5",
+		"20,1,$1,$2,$3,$4,$5,0",
+	}
+)
+
+// padFunction returns the .AperFunction attribute value for the given pad.
+func padFunction(pad *kicad.Pad) string {
+	switch pad.Type {
+	case "smd":
+		return "SMDPad,CuDef"
+	case "connect":
+		return "ConnectorPad"
+	case "np_thru_hole":
+		return "WasherPad"
+	default:
+		return "ComponentPad"
+	}
+}
+
+// flashPad flashes the given pad, enlarged by the given margin on each
+// axis. The margin may be negative to shrink the pad.
+func (p *plotter) flashPad(fp *kicad.Footprint, pad *kicad.Pad, margin kicad.Size, function string) {
+	angle := pad.At.Angle
+	center := fp.BoardPosition(pad.At.Position())
+	if pad.Drill.Offset != (kicad.Position{}) {
+		off := kicad.RotatePoint(pad.Drill.Offset, angle)
+		center.X += off.X
+		center.Y += off.Y
+	}
+
+	w := pad.Size.Width + 2*margin.Width
+	h := pad.Size.Height + 2*margin.Height
+	if w <= 0 || h <= 0 {
+		return
+	}
+
+	switch pad.Shape {
+	case "circle":
+		p.flash(center, p.circleAperture(w, function))
+	case "rect":
+		p.flashRect(center, w, h, angle, function)
+	case "oval":
+		p.flashOval(center, w, h, angle, function)
+	case "roundrect":
+		r := pad.RoundRectRatio*math.Min(pad.Size.Width, pad.Size.Height) + math.Min(margin.Width, margin.Height)
+		r = math.Min(r, math.Min(w, h)/2)
+		if pad.Chamfer != (kicad.PadChamfer{}) && pad.ChamferRatio > 0 {
+			c := pad.ChamferRatio * math.Min(w, h)
+			p.flashPolygon(center, kicad.ChamferedRect(w, h, r, c, pad.Chamfer), angle, function)
+		} else if r <= 0 {
+			p.flashRect(center, w, h, angle, function)
+		} else {
+			p.flashRoundRect(center, w, h, r, angle, function)
+		}
+	case "trapezoid":
+		p.flashPolygon(center, kicad.TrapezoidCorners(w, h, pad.RectDelta), angle, function)
+	case "custom":
+		p.flashCustom(center, pad, margin, function)
+	}
+}
+
+func (p *plotter) flashRect(center kicad.Position, w, h, angle float64, function string) {
+	switch kicad.NormalizeDegrees(angle) {
+	case 0, 180:
+		p.flash(center, p.aperture("R,"+num(w)+"X"+num(h), function))
+	case 90, 270:
+		p.flash(center, p.aperture("R,"+num(h)+"X"+num(w), function))
+	default:
+		p.flashPolygon(center, kicad.RectCorners(w, h), angle, function)
+	}
+}
+
+func (p *plotter) flashOval(center kicad.Position, w, h, angle float64, function string) {
+	switch kicad.NormalizeDegrees(angle) {
+	case 0, 180:
+		p.flash(center, p.aperture("O,"+num(w)+"X"+num(h), function))
+	case 90, 270:
+		p.flash(center, p.aperture("O,"+num(h)+"X"+num(w), function))
+	default:
+		if w == h {
+			p.flash(center, p.circleAperture(w, function))
+			return
+		}
+		d := math.Min(w, h)
+		var a kicad.Position
+		if w > h {
+			a = kicad.Position{X: (w - h) / 2}
+		} else {
+			a = kicad.Position{Y: (h - w) / 2}
+		}
+		a = kicad.RotatePoint(a, angle)
+		name := p.macro("RotOval", rotOvalMacro)
+		p.flash(center, p.aperture(name+","+macroParams([]float64{d}, []kicad.Position{{X: -a.X, Y: -a.Y}, a}), function))
+	}
+}
+
+func (p *plotter) flashRoundRect(center kicad.Position, w, h, r, angle float64, function string) {
+	corners := kicad.RectCorners(w-2*r, h-2*r)
+	for i := range corners {
+		corners[i] = kicad.RotatePoint(corners[i], angle)
+	}
+	name := p.macro("RoundRect", roundRectMacro)
+	p.flash(center, p.aperture(name+","+macroParams([]float64{r}, corners), function))
+}
+
+// flashPolygon flashes a polygon with the given vertices relative to its
+// center, rotated by the given angle. Quadrilaterals use a shared macro,
+// while other polygons each have a macro of their own.
+func (p *plotter) flashPolygon(center kicad.Position, points []kicad.Position, angle float64, function string) {
+	rotated := make([]kicad.Position, len(points))
+	for i, pt := range points {
+		rotated[i] = kicad.RotatePoint(pt, angle)
+	}
+	if len(rotated) == 4 {
+		name := p.macro("Outline4P", outline4PMacro)
+		p.flash(center, p.aperture(name+","+macroParams(nil, rotated), function))
+		return
+	}
+	name := p.macro("", []string{outlinePrimitive(rotated)})
+	p.flash(center, p.aperture(name, function))
+}
+
+// flashCustom flashes a pad with the "custom" shape, as a macro combining
+// its anchor pad with its primitives. Only the anchor pad is enlarged by
+// the margin.
+func (p *plotter) flashCustom(center kicad.Position, pad *kicad.Pad, margin kicad.Size, function string) {
+	angle := pad.At.Angle
+	var prims []string
+
+	w := pad.Size.Width + 2*margin.Width
+	h := pad.Size.Height + 2*margin.Height
+	if pad.Options.Anchor == "rect" {
+		corners := kicad.RectCorners(w, h)
+		for i := range corners {
+			corners[i] = kicad.RotatePoint(corners[i], angle)
+		}
+		prims = append(prims, outlinePrimitive(corners))
+	} else {
+		prims = append(prims, "1,1,"+num(w)+",0,0")
+	}
+
+	tr := func(pt kicad.Position) kicad.Position {
+		return kicad.RotatePoint(pt, angle)
+	}
+	g := &pad.Primitives
+	lineWidth := func(width float64, stroke kicad.Stroke) float64 {
+		if w := stroke.LineWidth(width); w > 0 {
+			return w
+		}
+		return g.Width
+	}
+	for _, poly := range g.Polys {
+		pts := kicad.TransformPoints(poly.Points.Polyline(), tr)
+		if len(pts) >= 3 {
+			prims = append(prims, outlinePrimitive(pts))
+		}
+		prims = append(prims, polylinePrimitives(kicad.ClosePolyline(pts), lineWidth(poly.Width, poly.Stroke))...)
+	}
+	for _, line := range g.Lines {
+		pts := []kicad.Position{tr(line.Start), tr(line.End)}
+		prims = append(prims, polylinePrimitives(pts, lineWidth(line.Width, line.Stroke))...)
+	}
+	for _, arc := range g.Arcs {
+		pts := kicad.TransformPoints(kicad.ArcPoints(arc.Start, arc.Mid, arc.End), tr)
+		prims = append(prims, polylinePrimitives(pts, lineWidth(arc.Width, arc.Stroke))...)
+	}
+	for _, curve := range g.Curves {
+		pts := kicad.TransformPoints(kicad.BezierPoints(curve.Points.Polyline()), tr)
+		prims = append(prims, polylinePrimitives(pts, lineWidth(curve.Width, curve.Stroke))...)
+	}
+	for _, rect := range g.Rects {
+		pts := kicad.TransformPoints(kicad.RectPoints(rect.Start, rect.End), tr)
+		width := lineWidth(rect.Width, rect.Stroke)
+		if rect.Filled() || width == 0 {
+			prims = append(prims, outlinePrimitive(pts))
+		}
+		prims = append(prims, polylinePrimitives(kicad.ClosePolyline(pts), width)...)
+	}
+	for _, circle := range g.Circles {
+		c := flipY(tr(circle.Center))
+		r := kicad.Distance(circle.Center, circle.End)
+		width := lineWidth(circle.Width, circle.Stroke)
+		prims = append(prims, "1,1,"+num(2*r+width)+","+num(c.X)+","+num(c.Y))
+		if !circle.Filled() && width > 0 && r > width/2 {
+			prims = append(prims, "1,0,"+num(2*r-width)+","+num(c.X)+","+num(c.Y))
+		}
+	}
+
+	name := p.macro("", prims)
+	p.flash(center, p.aperture(name, function))
+}
+
+// outlinePrimitive returns an outline macro primitive for the polygon with
+// the given vertices.
+func outlinePrimitive(points []kicad.Position) string {
+	var b strings.Builder
+	b.WriteString("4,1,")
+	b.WriteString(strconv.Itoa(len(points)))
+	for _, pt := range append(points, points[0]) {
+		pt = flipY(pt)
+		b.WriteString("," + num(pt.X) + "," + num(pt.Y))
+	}
+	b.WriteString(",0")
+	return b.String()
+}
+
+// polylinePrimitives returns macro primitives drawing the given polyline
+// with a round pen of the given width.
+func polylinePrimitives(points []kicad.Position, width float64) []string {
+	if width <= 0 || len(points) == 0 {
+		return nil
+	}
+	var ret []string
+	for i, pt := range points {
+		pt = flipY(pt)
+		ret = append(ret, "1,1,"+num(width)+","+num(pt.X)+","+num(pt.Y))
+		if i > 0 {
+			prev := flipY(points[i-1])
+			ret = append(ret, "20,1,"+num(width)+","+num(prev.X)+","+num(prev.Y)+","+num(pt.X)+","+num(pt.Y)+",0")
+		}
+	}
+	return ret
+}
+
+// macroParams formats the parameters of a macro aperture, as the given
+// numbers followed by the coordinates of the given points.
+func macroParams(values []float64, points []kicad.Position) string {
+	var parts []string
+	for _, v := range values {
+		parts = append(parts, num(v))
+	}
+	for _, pt := range points {
+		pt = flipY(pt)
+		parts = append(parts, num(pt.X), num(pt.Y))
+	}
+	return strings.Join(parts, "X")
+}
@@ -0,0 +1,268 @@
+package gerber
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/apparentlymart/go-kicad"
+)
+
+// plotter accumulates the content of a Gerber file. Apertures and macros
+// are defined as the body refers to them, but are written ahead of the body
+// since the attributes of each aperture must be set before defining it.
+type plotter struct {
+	origin kicad.Position
+
+	macros    []string
+	macroDefs map[string]string // definition to name
+
+	apertures   []aperture
+	apertureIDs map[aperture]int
+
+	body     bytes.Buffer
+	current  int    // D code of the selected aperture, or zero
+	interp   string // current interpolation mode, "G01", "G02" or "G03"
+	clear    bool   // true if the polarity is currently clear
+	objAttrs string // object attributes currently set
+}
+
+// aperture is an aperture defined in the file. Template is the part of the
+// definition after the D code, such as "C,0.500000", and Function is the
+// value of its .AperFunction attribute, if any.
+type aperture struct {
+	Template string
+	Function string
+}
+
+// firstDCode is the lowest D code available for apertures.
+const firstDCode = 10
+
+func newPlotter(origin kicad.Position) *plotter {
+	return &plotter{
+		origin:      origin,
+		macroDefs:   make(map[string]string),
+		apertureIDs: make(map[aperture]int),
+		interp:      "G01",
+	}
+}
+
+// writeTo writes the complete file, with the given file attributes in its
+// header.
+func (p *plotter) writeTo(w io.Writer, fileAttrs []string) error {
+	var buf bytes.Buffer
+	for _, attr := range fileAttrs {
+		fmt.Fprintf(&buf, "%%TF.%s*%%\n", attr)
+	}
+	buf.WriteString("%FSLAX46Y46*%\n")
+	buf.WriteString("%MOMM*%\n")
+	buf.WriteString("%LPD*%\n")
+	buf.WriteString("G01*\n")
+	buf.WriteString("G75*\n")
+	for _, def := range p.macros {
+		buf.WriteString(def)
+	}
+	for i, ap := range p.apertures {
+		if ap.Function != "" {
+			fmt.Fprintf(&buf, "%%TA.AperFunction,%s*%%\n", ap.Function)
+		}
+		fmt.Fprintf(&buf, "%%ADD%d%s*%%\n", firstDCode+i, ap.Template)
+		if ap.Function != "" {
+			buf.WriteString("%TD*%\n")
+		}
+	}
+	buf.Write(p.body.Bytes())
+	if p.objAttrs != "" {
+		buf.WriteString("%TD*%\n")
+	}
+	buf.WriteString("M02*\n")
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// macro returns the name of an aperture macro with the given primitives,
+// defining it if necessary. If name is empty then a name is chosen
+// automatically.
+func (p *plotter) macro(name string, primitives []string) string {
+	body := strings.Join(primitives, "*\n") + "*"
+	if existing, ok := p.macroDefs[body]; ok {
+		return existing
+	}
+	if name == "" {
+		name = fmt.Sprintf("FreePoly%d", len(p.macros))
+	}
+	p.macroDefs[body] = name
+	p.macros = append(p.macros, fmt.Sprintf("%%AM%s*\n%s%%\n", name, body))
+	return name
+}
+
+// aperture returns the D code of the aperture with the given template and
+// function, defining it if necessary.
+func (p *plotter) aperture(template, function string) int {
+	key := aperture{Template: template, Function: function}
+	if id, ok := p.apertureIDs[key]; ok {
+		return id
+	}
+	id := firstDCode + len(p.apertures)
+	p.apertures = append(p.apertures, key)
+	p.apertureIDs[key] = id
+	return id
+}
+
+// circleAperture returns the D code of a circular aperture of the given
+// diameter.
+func (p *plotter) circleAperture(diameter float64, function string) int {
+	return p.aperture("C,"+num(diameter), function)
+}
+
+// setObject sets the object attributes of the operations that follow,
+// given as attribute names and values such as ".N,GND".
+func (p *plotter) setObject(attrs ...string) {
+	s := strings.Join(attrs, "\n")
+	if s == p.objAttrs {
+		return
+	}
+	if p.objAttrs != "" {
+		p.body.WriteString("%TD*%\n")
+	}
+	for _, attr := range attrs {
+		fmt.Fprintf(&p.body, "%%TO%s*%%\n", attr)
+	}
+	p.objAttrs = s
+}
+
+// setClear selects clear polarity if clear is true, or dark polarity
+// otherwise.
+func (p *plotter) setClear(clear bool) {
+	if clear == p.clear {
+		return
+	}
+	if clear {
+		p.body.WriteString("%LPC*%\n")
+	} else {
+		p.body.WriteString("%LPD*%\n")
+	}
+	p.clear = clear
+}
+
+func (p *plotter) selectAperture(id int) {
+	if id != p.current {
+		fmt.Fprintf(&p.body, "D%d*\n", id)
+		p.current = id
+	}
+}
+
+func (p *plotter) setInterp(mode string) {
+	if mode != p.interp {
+		fmt.Fprintf(&p.body, "%s*\n", mode)
+		p.interp = mode
+	}
+}
+
+// flash flashes the given aperture at the given position.
+func (p *plotter) flash(at kicad.Position, id int) {
+	p.selectAperture(id)
+	fmt.Fprintf(&p.body, "%sD03*\n", p.coord(at))
+}
+
+// stroke draws a polyline with a round pen of the given width. A single
+// point is drawn as a dot.
+func (p *plotter) stroke(points []kicad.Position, width float64, function string) {
+	if len(points) == 0 {
+		return
+	}
+	id := p.circleAperture(width, function)
+	if len(points) == 1 {
+		p.flash(points[0], id)
+		return
+	}
+	p.selectAperture(id)
+	p.setInterp("G01")
+	fmt.Fprintf(&p.body, "%sD02*\n", p.coord(points[0]))
+	for _, pt := range points[1:] {
+		fmt.Fprintf(&p.body, "%sD01*\n", p.coord(pt))
+	}
+}
+
+// arc draws a circular arc around center with a round pen of the given
+// width, from start to end in the given direction as seen on screen. If
+// start and end are the same then a full circle is drawn.
+func (p *plotter) arc(start, center, end kicad.Position, clockwise bool, width float64, function string) {
+	p.selectAperture(p.circleAperture(width, function))
+	fmt.Fprintf(&p.body, "%sD02*\n", p.coord(start))
+	if clockwise {
+		p.setInterp("G02")
+	} else {
+		p.setInterp("G03")
+	}
+	// Offsets are in the file's coordinate system, where Y increases
+	// upwards.
+	i := nm(center.X - start.X)
+	j := nm(start.Y - center.Y)
+	fmt.Fprintf(&p.body, "%sI%dJ%dD01*\n", p.coord(end), i, j)
+	p.setInterp("G01")
+}
+
+// region fills the polygon with the given vertices.
+func (p *plotter) region(points []kicad.Position, function string) {
+	if len(points) < 3 {
+		return
+	}
+	if function != "" {
+		fmt.Fprintf(&p.body, "%%TA.AperFunction,%s*%%\n", function)
+	}
+	p.setInterp("G01")
+	p.body.WriteString("G36*\n")
+	fmt.Fprintf(&p.body, "%sD02*\n", p.coord(points[0]))
+	for _, pt := range points[1:] {
+		fmt.Fprintf(&p.body, "%sD01*\n", p.coord(pt))
+	}
+	if points[len(points)-1] != points[0] {
+		fmt.Fprintf(&p.body, "%sD01*\n", p.coord(points[0]))
+	}
+	p.body.WriteString("G37*\n")
+	if function != "" {
+		p.body.WriteString("%TD.AperFunction*%\n")
+	}
+}
+
+// coord formats a position in document coordinates as Gerber coordinates,
+// which are relative to the plot origin and have Y increasing upwards.
+func (p *plotter) coord(pos kicad.Position) string {
+	return fmt.Sprintf("X%dY%d", nm(pos.X-p.origin.X), nm(p.origin.Y-pos.Y))
+}
+
+// nm converts a length in millimeters to the integer nanometers used for
+// coordinates in the 4.6 format.
+func nm(v float64) int64 {
+	return int64(math.Round(v * 1e6))
+}
+
+// num formats a length in millimeters as a decimal number for aperture
+// definitions.
+func num(v float64) string {
+	v = math.Round(v*1e6) / 1e6
+	if v == 0 {
+		// Avoid writing negative zero
+		v = 0
+	}
+	return strconv.FormatFloat(v, 'f', 6, 64)
+}
+
+// escapeField escapes the characters that can't appear literally in a
+// field of an attribute value.
+func escapeField(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch r {
+		case ',', '*', '%', '\\':
+			fmt.Fprintf(&b, "\\u%04X", r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
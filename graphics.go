@@ -0,0 +1,183 @@
+package kicad
+
+import (
+	"encoding/base64"
+	"strings"
+)
+
+// The graphic item types in this file are used both for the "fp_" items
+// within footprints and for the "gr_" items drawn directly on a board, and
+// also for the primitives that make up custom pad shapes.
+//
+// Documents from KiCad 5 give a line width directly in Width, whereas later
+// versions use Stroke. Fill is "solid" or "none" in KiCad 6 and 7 documents,
+// and "yes" or "no" in later versions; the Filled method of each item type
+// hides that difference.
+
+// GraphicLine is a straight line segment.
+type GraphicLine struct {
+	Start  Position `kicad:"start,flat,always"`
+	End    Position `kicad:"end,flat,always"`
+	Layer  string   `kicad:"layer"`
+	Width  float64  `kicad:"width"`
+	Stroke Stroke   `kicad:"stroke,flat"`
+	Locked bool     `kicad:"locked,bare"`
+	UUID   string   `kicad:"uuid"`
+	TStamp string   `kicad:"tstamp"`
+}
+
+// GraphicArc is a circular arc.
+//
+// In KiCad 6 and later an arc runs from Start through Mid to End. KiCad 5
+// documents instead give the center of the arc in Start, its starting point
+// in End and the clockwise sweep in degrees in Angle.
+type GraphicArc struct {
+	Start  Position `kicad:"start,flat,always"`
+	Mid    Position `kicad:"mid,flat"`
+	End    Position `kicad:"end,flat,always"`
+	Angle  float64  `kicad:"angle"`
+	Layer  string   `kicad:"layer"`
+	Width  float64  `kicad:"width"`
+	Stroke Stroke   `kicad:"stroke,flat"`
+	Locked bool     `kicad:"locked,bare"`
+	UUID   string   `kicad:"uuid"`
+	TStamp string   `kicad:"tstamp"`
+}
+
+// GraphicCircle is a circle around Center, passing through End.
+type GraphicCircle struct {
+	Center Position `kicad:"center,flat,always"`
+	End    Position `kicad:"end,flat,always"`
+	Layer  string   `kicad:"layer"`
+	Width  float64  `kicad:"width"`
+	Stroke Stroke   `kicad:"stroke,flat"`
+	Fill   string   `kicad:"fill"`
+	Locked bool     `kicad:"locked,bare"`
+	UUID   string   `kicad:"uuid"`
+	TStamp string   `kicad:"tstamp"`
+}
+
+// Filled returns true if the circle is drawn as a solid disc.
+func (c *GraphicCircle) Filled() bool {
+	return fillIsSolid(c.Fill)
+}
+
+// GraphicRect is an axis-aligned rectangle with opposite corners at Start
+// and End.
+type GraphicRect struct {
+	Start  Position `kicad:"start,flat,always"`
+	End    Position `kicad:"end,flat,always"`
+	Layer  string   `kicad:"layer"`
+	Width  float64  `kicad:"width"`
+	Stroke Stroke   `kicad:"stroke,flat"`
+	Fill   string   `kicad:"fill"`
+	Locked bool     `kicad:"locked,bare"`
+	UUID   string   `kicad:"uuid"`
+	TStamp string   `kicad:"tstamp"`
+}
+
+// Filled returns true if the rectangle is drawn as a solid area.
+func (r *GraphicRect) Filled() bool {
+	return fillIsSolid(r.Fill)
+}
+
+// GraphicPoly is a closed polygon.
+type GraphicPoly struct {
+	Points Points  `kicad:"pts,flat"`
+	Layer  string  `kicad:"layer"`
+	Width  float64 `kicad:"width"`
+	Stroke Stroke  `kicad:"stroke,flat"`
+	Fill   string  `kicad:"fill"`
+	Locked bool    `kicad:"locked,bare"`
+	UUID   string  `kicad:"uuid"`
+	TStamp string  `kicad:"tstamp"`
+}
+
+// Filled returns true if the polygon is drawn as a solid area.
+func (p *GraphicPoly) Filled() bool {
+	return fillIsSolid(p.Fill)
+}
+
+// GraphicCurve is a cubic Bézier curve, whose four control points are given
+// in order in Points.
+type GraphicCurve struct {
+	Points Points  `kicad:"pts,flat"`
+	Layer  string  `kicad:"layer"`
+	Width  float64 `kicad:"width"`
+	Stroke Stroke  `kicad:"stroke,flat"`
+	Locked bool    `kicad:"locked,bare"`
+	UUID   string  `kicad:"uuid"`
+	TStamp string  `kicad:"tstamp"`
+}
+
+func fillIsSolid(fill string) bool {
+	return fill == "solid" || fill == "yes"
+}
+
+// BoardText is a text item drawn directly on a board. Dimensions also use
+// this type for the text showing their measurement.
+type BoardText struct {
+	Text    string        `kicad:""`
+	Locked  bool          `kicad:"locked,bare"`
+	At      PositionAngle `kicad:"at,flat,always"`
+	Layer   TextLayer     `kicad:"layer,flat"`
+	UUID    string        `kicad:"uuid"`
+	TStamp  string        `kicad:"tstamp"`
+	Effects TextEffects   `kicad:"effects,flat"`
+}
+
+// TextBox is a text item drawn within a box, introduced in KiCad 7.
+//
+// An unrotated box is given by its corners Start and End, while a rotated
+// box is given instead as four corner points in Points.
+type TextBox struct {
+	Text    string      `kicad:""`
+	Locked  bool        `kicad:"locked,bare"`
+	Start   Position    `kicad:"start,flat"`
+	End     Position    `kicad:"end,flat"`
+	Points  Points      `kicad:"pts,flat"`
+	Angle   float64     `kicad:"angle"`
+	Margins Margins     `kicad:"margins,flat"`
+	Layer   TextLayer   `kicad:"layer,flat"`
+	UUID    string      `kicad:"uuid"`
+	TStamp  string      `kicad:"tstamp"`
+	Effects TextEffects `kicad:"effects,flat"`
+	Border  bool        `kicad:"border"`
+	Stroke  Stroke      `kicad:"stroke,flat"`
+}
+
+// Margins gives the space between the border of a TextBox and its text.
+type Margins struct {
+	Left   float64 `kicad:""`
+	Top    float64 `kicad:""`
+	Right  float64 `kicad:""`
+	Bottom float64 `kicad:""`
+}
+
+// Image is a bitmap image placed on a board, centered on At. Data holds the
+// base64-encoded PNG data split across several strings; the PNG method
+// returns the decoded image file.
+type Image struct {
+	At     Position `kicad:"at,flat"`
+	Layer  string   `kicad:"layer"`
+	Scale  float64  `kicad:"scale"`
+	Locked bool     `kicad:"locked,bare"`
+	UUID   string   `kicad:"uuid"`
+	Data   []string `kicad:"data,flat"`
+}
+
+// PNG returns the PNG image file embedded in the image.
+func (i *Image) PNG() ([]byte, error) {
+	return base64.StdEncoding.DecodeString(strings.Join(i.Data, ""))
+}
+
+// Group is a named group of board items, which are identified by their
+// UUIDs in Members. Documents from before KiCad 8 identify the group itself
+// with ID rather than UUID.
+type Group struct {
+	Name    string   `kicad:""`
+	Locked  bool     `kicad:"locked,bare"`
+	ID      string   `kicad:"id"`
+	UUID    string   `kicad:"uuid"`
+	Members []string `kicad:"members,flat"`
+}
@@ -0,0 +1,24 @@
+// Package fileio contains helpers for the packages that write their output
+// to files.
+package fileio
+
+import (
+	"io"
+	"os"
+)
+
+// WriteFile creates or truncates the named file and calls write to produce
+// its content, closing the file afterwards.
+func WriteFile(filename string, write func(w io.Writer) error) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+
+	err = write(f)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,26 @@
+// Package testboard provides the board that the tests of the exporter
+// packages share, so that each output format is checked against the same
+// input.
+package testboard
+
+import (
+	_ "embed"
+	"strings"
+	"testing"
+
+	"github.com/apparentlymart/go-kicad"
+)
+
+//go:embed testdata/board.kicad_pcb
+var src string
+
+// Read decodes the test board, failing the test if it can't be read. Each
+// call returns a new board, so tests can modify it freely.
+func Read(t testing.TB) *kicad.PCB {
+	t.Helper()
+	pcb, err := kicad.ReadPCB(strings.NewReader(src))
+	if err != nil {
+		t.Fatalf("reading test board: %s", err)
+	}
+	return pcb
+}
@@ -0,0 +1,279 @@
+package ipc2581
+
+import (
+	"bytes"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/apparentlymart/go-kicad"
+	"github.com/apparentlymart/go-kicad/bom"
+)
+
+// components adds the packages of the board's footprints and the
+// components placed using them to the step.
+//
+// A package describes a footprint in its own coordinates, as it is placed
+// on the board rather than as it is stored in its library, so footprints
+// from the same library footprint that have been changed on the board or
+// flipped to the other side have packages of their own.
+func (d *document) components(step *node) {
+	var components []*node
+	// defined maps the content of each package to its name, and count
+	// the number of packages named after each library footprint.
+	defined := make(map[string]string)
+	count := make(map[string]int)
+	for i := range d.pcb.Footprints {
+		fp := &d.pcb.Footprints[i]
+		ref := fp.Reference()
+		pkg := d.footprintPackage(fp)
+		if ref == "" || pkg == nil {
+			continue
+		}
+
+		var key bytes.Buffer
+		pkg.write(&key, 0)
+		name, ok := defined[key.String()]
+		if !ok {
+			name = fp.LibID
+			if name == "" {
+				name = ref
+			}
+			if count[name]++; count[name] > 1 {
+				name += "_" + strconv.Itoa(count[name])
+			}
+			defined[key.String()] = name
+			pkg.attrs = append([]attr{str("name", name)}, pkg.attrs...)
+			step.children = append(step.children, pkg)
+		}
+		d.packages[ref] = name
+
+		mount := "OTHER"
+		switch fp.Attr.Type {
+		case "smd":
+			mount = "SMT"
+		case "through_hole":
+			mount = "THMT"
+		}
+		c := newNode("Component",
+			str("refDes", ref),
+			str("packageRef", name),
+			str("layerRef", fp.Layer),
+			str("part", partNumber(fp.LibID, fp.Value())),
+			str("mountType", mount),
+		)
+		if angle := kicad.NormalizeDegrees(fp.At.Angle); angle != 0 {
+			c.add("Xform", length("rotation", angle))
+		}
+		d.location(c, fp.At.Position())
+		components = append(components, c)
+	}
+	step.children = append(step.children, components...)
+}
+
+// footprintPackage returns the package describing the given footprint, not
+// yet named, or nil if the footprint has neither a courtyard nor pads.
+//
+// The outline of the package is the bounding box of the footprint's
+// courtyard, or of its pads if it has no courtyard.
+func (d *document) footprintPackage(fp *kicad.Footprint) *node {
+	var min, max kicad.Position
+	empty := true
+	extend := func(p kicad.Position) {
+		if empty {
+			min, max, empty = p, p, false
+			return
+		}
+		min.X, min.Y = math.Min(min.X, p.X), math.Min(min.Y, p.Y)
+		max.X, max.Y = math.Max(max.X, p.X), math.Max(max.Y, p.Y)
+	}
+	courtyard := func(layer string) bool {
+		return strings.HasSuffix(layer, ".CrtYd")
+	}
+	for _, line := range fp.Lines {
+		if courtyard(line.Layer) {
+			extend(line.Start)
+			extend(line.End)
+		}
+	}
+	for _, arc := range fp.Arcs {
+		if courtyard(arc.Layer) {
+			for _, p := range kicad.ArcPoints(arc.Start, arc.Mid, arc.End) {
+				extend(p)
+			}
+		}
+	}
+	for _, circle := range fp.Circles {
+		if courtyard(circle.Layer) {
+			r := kicad.Distance(circle.Center, circle.End)
+			extend(kicad.Position{X: circle.Center.X - r, Y: circle.Center.Y - r})
+			extend(kicad.Position{X: circle.Center.X + r, Y: circle.Center.Y + r})
+		}
+	}
+	for _, rect := range fp.Rects {
+		if courtyard(rect.Layer) {
+			extend(rect.Start)
+			extend(rect.End)
+		}
+	}
+	for _, poly := range fp.Polys {
+		if courtyard(poly.Layer) {
+			for _, p := range poly.Points.Polyline() {
+				extend(p)
+			}
+		}
+	}
+	if empty {
+		for _, pad := range fp.Pads {
+			r := math.Max(pad.Size.Width, pad.Size.Height) / 2
+			extend(kicad.Position{X: pad.At.X - r, Y: pad.At.Y - r})
+			extend(kicad.Position{X: pad.At.X + r, Y: pad.At.Y + r})
+		}
+	}
+	if empty {
+		return nil
+	}
+
+	pkg := newNode("Package", str("type", "OTHER"))
+	if len(fp.Pads) > 0 {
+		pkg.set("pinOne", fp.Pads[0].Number)
+	}
+	outline := polygon(pkg, "Outline", kicad.RectPoints(min, max), localXY)
+	lineDesc(outline, 0)
+
+	for j := range fp.Pads {
+		pad := &fp.Pads[j]
+		if pad.Number == "" {
+			continue
+		}
+		pinType, electrical := "SURFACE", "ELECTRICAL"
+		if pad.HasDrill() {
+			pinType = "THRU"
+		}
+		if pad.Type == "np_thru_hole" {
+			electrical = "MECHANICAL"
+		}
+		pin := pkg.add("Pin", str("number", pad.Number), str("type", pinType), str("electricalType", electrical))
+
+		// Pad angles include the rotation of the footprint, which the
+		// component gives separately.
+		angle := kicad.NormalizeDegrees(pad.At.Angle - fp.At.Angle)
+		if angle != 0 {
+			pin.add("Xform", length("rotation", angle))
+		}
+		off := kicad.RotatePoint(pad.Drill.Offset, angle)
+		pin.add("Location", localXY("x", "y", kicad.Position{X: pad.At.X + off.X, Y: pad.At.Y + off.Y})...)
+		if shape, ok := d.dict.padShape(pad, kicad.Size{}); ok {
+			shape.element(pin)
+		}
+	}
+	return pkg
+}
+
+// partNumber returns the part number that the document gives to parts with
+// the given footprint and value, which ties components to the lines of the
+// bill of materials.
+func partNumber(libID, value string) string {
+	_, name, ok := strings.Cut(libID, ":")
+	if !ok {
+		name = libID
+	}
+	if name == "" {
+		return value
+	}
+	return value + "_" + name
+}
+
+// logicalNets adds the nets of the board to the step, with the pins
+// connected to each.
+func (d *document) logicalNets(step *node) {
+	pins := make(map[string][]*node)
+	for i := range d.pcb.Footprints {
+		fp := &d.pcb.Footprints[i]
+		ref := fp.Reference()
+		for j := range fp.Pads {
+			pad := &fp.Pads[j]
+			net := d.padNet(pad)
+			if net == "" || pad.Number == "" || ref == "" {
+				continue
+			}
+			pins[net] = append(pins[net], newNode("PinRef", str("componentRef", ref), str("pin", pad.Number)))
+		}
+	}
+	for _, net := range d.pcb.Nets {
+		if net.Name == "" {
+			continue
+		}
+		n := step.add("LogicalNet", str("name", net.Name))
+		n.children = pins[net.Name]
+	}
+}
+
+// padNet returns the name of the net that the given pad is connected to.
+func (d *document) padNet(pad *kicad.Pad) string {
+	if pad.Net.Name != "" {
+		return pad.Net.Name
+	}
+	return d.pcb.NetName(pad.Net.Number)
+}
+
+func (d *document) bomName() string {
+	return d.name + "_BOM"
+}
+
+// bom returns the element holding the bill of materials of the board, with
+// the manufacturer fields from package bom as characteristics of each
+// line.
+func (d *document) bom() *node {
+	pinCount := make(map[string]int)
+	for i := range d.pcb.Footprints {
+		fp := &d.pcb.Footprints[i]
+		pinCount[fp.Reference()] = len(fp.Pads)
+	}
+
+	n := newNode("Bom", str("name", d.bomName()))
+	header := n.add("BomHeader", str("assembly", d.name), str("revision", firstNonEmpty(d.pcb.TitleBlock.Revision, "1")))
+	header.add("StepRef", str("name", d.name))
+	for _, line := range bom.FromBoard(d.pcb, &bom.Options{Fields: bom.ManufacturerFields}) {
+		item := n.add("BomItem",
+			str("OEMDesignNumberRef", partNumber(line.Footprint, line.Value)),
+			integer("quantity", line.Quantity()),
+			integer("pinCount", pinCount[line.References[0]]),
+			str("category", "ELECTRICAL"),
+		)
+		for _, ref := range line.References {
+			r := item.add("RefDes", str("name", ref))
+			if pkg := d.packages[ref]; pkg != "" {
+				r.set("packageRef", pkg)
+			}
+			r.attrs = append(r.attrs, boolean("populate", !line.DNP))
+			for i := range d.pcb.Footprints {
+				if fp := &d.pcb.Footprints[i]; fp.Reference() == ref {
+					r.set("layerRef", fp.Layer)
+					break
+				}
+			}
+		}
+		chars := item.add("Characteristics", str("category", "ELECTRICAL"))
+		textual := func(name, value string) {
+			if value != "" {
+				chars.add("Textual", str("definitionSource", "KICAD"), str("textualCharacteristicName", name), str("textualCharacteristicValue", value))
+			}
+		}
+		textual("Value", line.Value)
+		textual("Footprint", line.Footprint)
+		for _, f := range bom.ManufacturerFields {
+			textual(f.Name, line.Fields[f.Name])
+		}
+	}
+	return n
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,441 @@
+package ipc2581
+
+import (
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/apparentlymart/go-kicad"
+	"github.com/apparentlymart/go-kicad/drill"
+	"github.com/apparentlymart/go-kicad/strokefont"
+)
+
+// layerFeatures adds the features of each layer of the document to the
+// step. Each item on a layer is a set of its own, which for items on copper
+// layers gives the net the item is connected to.
+func (d *document) layerFeatures(step *node) {
+	for i := range d.layers {
+		l := &d.layers[i]
+		lf := newNode("LayerFeature", str("layerRef", l.name))
+		switch {
+		case l.drill != nil:
+			d.holes(lf, l.drill)
+		case l.board != "":
+			fl := &featureLayer{
+				document: d,
+				layer:    l.board,
+				parent:   lf,
+				isCopper: strings.HasSuffix(l.board, ".Cu"),
+			}
+			fl.build()
+		}
+		if len(lf.children) > 0 {
+			step.children = append(step.children, lf)
+		}
+	}
+}
+
+// featureLayer builds the features of one layer of the board.
+type featureLayer struct {
+	*document
+	layer    string
+	parent   *node
+	isCopper bool
+}
+
+func (fl *featureLayer) build() {
+	fl.zones()
+	fl.tracks()
+	fl.vias()
+	fl.pads()
+	fl.graphics()
+	fl.texts()
+}
+
+// set adds a set to the layer's features, connected to the given net if
+// the layer is a copper layer.
+func (fl *featureLayer) set(net int, attrs ...attr) *node {
+	set := fl.parent.add("Set", attrs...)
+	if name := fl.pcb.NetName(net); fl.isCopper && name != "" {
+		set.attrs = append([]attr{str("net", name)}, set.attrs...)
+	}
+	return set
+}
+
+// features adds a features element to the given set, whose coordinates are
+// relative to the document's origin.
+func (fl *featureLayer) features(set *node) *node {
+	f := set.add("Features")
+	f.add("Location", length("x", 0), length("y", 0))
+	return f
+}
+
+func (fl *featureLayer) zones() {
+	addZone := func(z *kicad.Zone) {
+		if z.IsRuleArea() || !kicad.OnLayer(z.LayerNames(), fl.layer) {
+			return
+		}
+		for _, poly := range z.Filled {
+			layer := poly.Layer
+			if layer == "" {
+				layer = z.Layer
+			}
+			if layer != fl.layer || len(poly.Points.Polyline()) < 3 {
+				continue
+			}
+			f := fl.features(fl.set(z.Net))
+			polygon(f.add("Contour"), "Polygon", poly.Points.Polyline(), fl.xy)
+		}
+	}
+	for i := range fl.pcb.Zones {
+		addZone(&fl.pcb.Zones[i])
+	}
+	for i := range fl.pcb.Footprints {
+		fp := &fl.pcb.Footprints[i]
+		for j := range fp.Zones {
+			addZone(&fp.Zones[j])
+		}
+	}
+}
+
+func (fl *featureLayer) tracks() {
+	for _, seg := range fl.pcb.Segments {
+		if seg.Layer == fl.layer {
+			fl.line(fl.features(fl.set(seg.Net)), seg.Start, seg.End, seg.Width)
+		}
+	}
+	for _, arc := range fl.pcb.Arcs {
+		if arc.Layer != fl.layer {
+			continue
+		}
+		f := fl.features(fl.set(arc.Net))
+		center, ok := kicad.ArcCenter(arc.Start, arc.Mid, arc.End)
+		if !ok {
+			fl.line(f, arc.Start, arc.End, arc.Width)
+			continue
+		}
+		n := f.add("Arc", fl.xy("startX", "startY", arc.Start)...)
+		n.attrs = append(n.attrs, fl.xy("endX", "endY", arc.End)...)
+		n.attrs = append(n.attrs, fl.xy("centerX", "centerY", center)...)
+		n.attrs = append(n.attrs, boolean("clockwise", kicad.ArcSweep(center, arc.Start, arc.Mid, arc.End) > 0))
+		lineDesc(n, arc.Width)
+	}
+}
+
+func (fl *featureLayer) line(f *node, start, end kicad.Position, width float64) {
+	n := f.add("Line", fl.xy("startX", "startY", start)...)
+	n.attrs = append(n.attrs, fl.xy("endX", "endY", end)...)
+	lineDesc(n, width)
+}
+
+func (fl *featureLayer) vias() {
+	mask := fl.layer == "F.Mask" || fl.layer == "B.Mask"
+	if !fl.isCopper && !(mask && fl.pcb.Setup.PlotParams.ViasOnMask) {
+		return
+	}
+	for i := range fl.pcb.Vias {
+		via := &fl.pcb.Vias[i]
+		layers := fl.viaLayers(via)
+		if fl.isCopper {
+			if !slices.Contains(layers, fl.layer) {
+				continue
+			}
+			pad := fl.set(via.Net, str("padUsage", "VIA")).add("Pad", str("padstackDefRef", fl.padstacks.via(via)))
+			fl.location(pad, via.At)
+			fl.dict.circle(via.Size).element(pad)
+			continue
+		}
+		outer := "F.Cu"
+		if fl.layer == "B.Mask" {
+			outer = "B.Cu"
+		}
+		if !slices.Contains(layers, outer) {
+			continue
+		}
+		pad := fl.set(0).add("Pad")
+		fl.location(pad, via.At)
+		fl.dict.circle(via.Size + 2*fl.pcb.Setup.PadToMaskClearance).element(pad)
+	}
+}
+
+func (fl *featureLayer) pads() {
+	for i := range fl.pcb.Footprints {
+		fp := &fl.pcb.Footprints[i]
+		for j := range fp.Pads {
+			pad := &fp.Pads[j]
+			if kicad.OnLayer(pad.Layers, fl.layer) {
+				fl.pad(fp, pad)
+			}
+		}
+	}
+}
+
+// pad adds the given pad of the given footprint. Pads on solder mask and
+// solder paste layers are enlarged or shrunk by the margins that apply to
+// them.
+func (fl *featureLayer) pad(fp *kicad.Footprint, pad *kicad.Pad) {
+	margin := fl.pcb.PadMargin(fp, pad, fl.layer)
+	var set, n *node
+	if fl.isCopper {
+		if !hasCopper(pad) {
+			return
+		}
+		set = fl.parent.add("Set", str("padUsage", "TERMINATION"))
+		if net := fl.padNet(pad); net != "" {
+			set.attrs = append([]attr{str("net", net)}, set.attrs...)
+		}
+		n = set.add("Pad", str("padstackDefRef", fl.padstacks.pad(pad)))
+	}
+	shape, ok := fl.dict.padShape(pad, margin)
+	if !ok {
+		if set != nil {
+			fl.parent.children = fl.parent.children[:len(fl.parent.children)-1]
+		}
+		return
+	}
+	if n == nil {
+		n = fl.set(0).add("Pad")
+	}
+
+	angle := kicad.NormalizeDegrees(pad.At.Angle)
+	if angle != 0 {
+		n.add("Xform", length("rotation", angle))
+	}
+	center := fp.BoardPosition(pad.At.Position())
+	off := kicad.RotatePoint(pad.Drill.Offset, pad.At.Angle)
+	fl.location(n, kicad.Position{X: center.X + off.X, Y: center.Y + off.Y})
+	shape.element(n)
+	if fl.isCopper && fp.Reference() != "" && pad.Number != "" {
+		n.add("PinRef", str("componentRef", fp.Reference()), str("pin", pad.Number))
+	}
+}
+
+// graphics adds the graphic items of the board and of its footprints.
+func (fl *featureLayer) graphics() {
+	pcb := fl.pcb
+	fl.graphicItems(pcb.GraphicLines, pcb.GraphicArcs, pcb.GraphicCircles, pcb.GraphicRects, pcb.GraphicPolys, pcb.GraphicCurves, nil)
+	for i := range pcb.Footprints {
+		fp := &pcb.Footprints[i]
+		fl.graphicItems(fp.Lines, fp.Arcs, fp.Circles, fp.Rects, fp.Polys, fp.Curves, fp)
+	}
+}
+
+// graphicItems adds the given graphic items that are on the layer. If fp is
+// not nil then the items belong to that footprint, and their positions are
+// relative to it.
+func (fl *featureLayer) graphicItems(lines []kicad.GraphicLine, arcs []kicad.GraphicArc, circles []kicad.GraphicCircle, rects []kicad.GraphicRect, polys []kicad.GraphicPoly, curves []kicad.GraphicCurve, fp *kicad.Footprint) {
+	tr := func(p kicad.Position) kicad.Position {
+		if fp == nil {
+			return p
+		}
+		return fp.BoardPosition(p)
+	}
+	fill := fl.layer != "Edge.Cuts"
+
+	for _, line := range lines {
+		if line.Layer == fl.layer {
+			fl.line(fl.features(fl.set(0)), tr(line.Start), tr(line.End), line.Stroke.LineWidth(line.Width))
+		}
+	}
+	for _, arc := range arcs {
+		if arc.Layer == fl.layer {
+			fl.polyline(kicad.TransformPoints(kicad.ArcPoints(arc.Start, arc.Mid, arc.End), tr), arc.Stroke.LineWidth(arc.Width))
+		}
+	}
+	for _, circle := range circles {
+		if circle.Layer != fl.layer {
+			continue
+		}
+		center := tr(circle.Center)
+		r := kicad.Distance(circle.Center, circle.End)
+		width := circle.Stroke.LineWidth(circle.Width)
+		f := fl.set(0).add("Features")
+		fl.location(f, center)
+		if fill && circle.Filled() {
+			f.add("Circle", length("diameter", 2*r+width)).add("FillDesc", str("fillProperty", "FILL"))
+			continue
+		}
+		n := f.add("Circle", length("diameter", 2*r))
+		lineDesc(n, width)
+		n.add("FillDesc", str("fillProperty", "HOLLOW"))
+	}
+	for _, rect := range rects {
+		if rect.Layer == fl.layer {
+			fl.shape(kicad.TransformPoints(kicad.RectPoints(rect.Start, rect.End), tr), rect.Stroke.LineWidth(rect.Width), fill && rect.Filled())
+		}
+	}
+	for _, poly := range polys {
+		if poly.Layer == fl.layer && len(poly.Points.Polyline()) >= 3 {
+			fl.shape(kicad.TransformPoints(poly.Points.Polyline(), tr), poly.Stroke.LineWidth(poly.Width), fill && poly.Filled())
+		}
+	}
+	for _, curve := range curves {
+		if curve.Layer == fl.layer && len(curve.Points.Polyline()) > 0 {
+			fl.polyline(kicad.TransformPoints(kicad.BezierPoints(curve.Points.Polyline()), tr), curve.Stroke.LineWidth(curve.Width))
+		}
+	}
+}
+
+// shape adds a closed shape with the given vertices, which is either
+// filled or has only its outline drawn.
+func (fl *featureLayer) shape(points []kicad.Position, width float64, filled bool) {
+	if filled {
+		f := fl.features(fl.set(0))
+		polygon(f.add("Contour"), "Polygon", points, fl.xy)
+		if width <= 0 {
+			return
+		}
+	}
+	fl.polyline(append(points[:len(points):len(points)], points[0]), width)
+}
+
+func (fl *featureLayer) polyline(points []kicad.Position, width float64) {
+	if len(points) < 2 {
+		return
+	}
+	f := fl.features(fl.set(0))
+	lineDesc(polyline(f, "Polyline", points, fl.xy), width)
+}
+
+// texts adds the text of the board and of its footprints. Knockout text is
+// drawn in the same way as other text, without the box around it.
+func (fl *featureLayer) texts() {
+	for _, text := range fl.pcb.Texts {
+		if text.Layer.Name == fl.layer && !text.Effects.Hide {
+			fl.text(text.Text, text.At, &text.Effects)
+		}
+	}
+	for _, dim := range fl.pcb.Dimensions {
+		if dim.Layer == fl.layer && dim.Text.Text != "" && !dim.Text.Effects.Hide {
+			fl.text(dim.Text.Text, dim.Text.At, &dim.Text.Effects)
+		}
+	}
+	for i := range fl.pcb.TextBoxes {
+		if box := &fl.pcb.TextBoxes[i]; box.Layer.Name == fl.layer {
+			fl.textBox(box)
+		}
+	}
+	for i := range fl.pcb.Footprints {
+		fp := &fl.pcb.Footprints[i]
+		for _, text := range fp.Texts {
+			if text.Layer.Name != fl.layer || text.Hide || text.Effects.Hide {
+				continue
+			}
+			fl.text(fp.ExpandText(text.Text), fp.BoardTextAt(text.At), &text.Effects)
+		}
+		for _, prop := range fp.Properties {
+			if prop.Layer.Name != fl.layer || prop.Hide || prop.Effects.Hide {
+				continue
+			}
+			fl.text(fp.ExpandText(prop.Value), fp.BoardTextAt(prop.At), &prop.Effects)
+		}
+	}
+}
+
+// text adds the strokes of the given text as a single set.
+func (fl *featureLayer) text(text string, at kicad.PositionAngle, effects *kicad.TextEffects) {
+	strokes := strokefont.Strokes(text, at, effects)
+	if len(strokes) == 0 {
+		return
+	}
+	width := strokefont.Thickness(effects)
+	set := fl.set(0, str("geometryUsage", "TEXT"))
+	for _, stroke := range strokes {
+		if len(stroke) < 2 {
+			continue
+		}
+		f := fl.features(set)
+		lineDesc(polyline(f, "Polyline", stroke, fl.xy), width)
+	}
+}
+
+// textBox adds the border of a text box, if it has one, and its text
+// positioned within the box's margins.
+func (fl *featureLayer) textBox(box *kicad.TextBox) {
+	corners := box.Points.Polyline()
+	if len(corners) != 4 {
+		corners = kicad.RectPoints(box.Start, box.End)
+	}
+	if box.Border {
+		fl.shape(corners, box.Stroke.LineWidth(0), false)
+	}
+	if box.Effects.Hide {
+		return
+	}
+
+	// Work in the box's own unrotated frame, relative to its first
+	// corner, to find where the text is anchored.
+	origin := corners[0]
+	local := func(p kicad.Position) kicad.Position {
+		return kicad.RotatePoint(kicad.Position{X: p.X - origin.X, Y: p.Y - origin.Y}, -box.Angle)
+	}
+	a, b := local(corners[0]), local(corners[2])
+	left, right := math.Min(a.X, b.X)+box.Margins.Left, math.Max(a.X, b.X)-box.Margins.Right
+	top, bottom := math.Min(a.Y, b.Y)+box.Margins.Top, math.Max(a.Y, b.Y)-box.Margins.Bottom
+
+	var anchor kicad.Position
+	switch {
+	case box.Effects.Justify.Right:
+		anchor.X = right
+	case box.Effects.Justify.Left:
+		anchor.X = left
+	default:
+		anchor.X = (left + right) / 2
+	}
+	switch {
+	case box.Effects.Justify.Bottom:
+		anchor.Y = bottom
+	case box.Effects.Justify.Top:
+		anchor.Y = top
+	default:
+		anchor.Y = (top + bottom) / 2
+	}
+	anchor = kicad.RotatePoint(anchor, box.Angle)
+	at := kicad.PositionAngle{X: origin.X + anchor.X, Y: origin.Y + anchor.Y, Angle: box.Angle}
+	fl.text(box.Text, at, &box.Effects)
+}
+
+// holes adds the holes of the given drill file. Round holes are holes and
+// oval holes are slots, both of which are numbered throughout the document
+// so that their names are unique.
+func (d *document) holes(lf *node, f *drill.File) {
+	for _, h := range f.Holes {
+		plating := "NONPLATED"
+		switch {
+		case h.Via:
+			plating = "VIA"
+		case h.Plated:
+			plating = "PLATED"
+		}
+		d.holeCount++
+		name := "H" + strconv.Itoa(d.holeCount)
+		set := lf.add("Set")
+		if !h.IsSlot() {
+			hole := set.add("Hole",
+				str("name", name),
+				length("diameter", h.Diameter),
+				str("platingStatus", plating),
+				length("plusTol", 0),
+				length("minusTol", 0),
+			)
+			hole.attrs = append(hole.attrs, d.xy("x", "y", h.Start)...)
+			continue
+		}
+
+		slot := set.add("SlotCavity",
+			str("name", name),
+			str("platingStatus", plating),
+			length("plusTol", 0),
+			length("minusTol", 0),
+		)
+		// The angle of the slot is counterclockwise with Y upwards, so
+		// the Y axis of the board is reversed.
+		angle := kicad.NormalizeDegrees(math.Atan2(h.Start.Y-h.End.Y, h.End.X-h.Start.X) * 180 / math.Pi)
+		if angle != 0 {
+			slot.add("Xform", length("rotation", angle))
+		}
+		d.location(slot, kicad.Position{X: (h.Start.X + h.End.X) / 2, Y: (h.Start.Y + h.End.Y) / 2})
+		slot.add("Oval", length("width", kicad.Distance(h.Start, h.End)+h.Diameter), length("height", h.Diameter))
+	}
+}
@@ -0,0 +1,202 @@
+// Package ipc2581 writes a KiCad board as an IPC-2581 revision B document,
+// an XML format that carries everything a manufacturer needs to fabricate
+// and assemble a board in a single file.
+//
+// The document includes the board's stackup, the features of each of its
+// layers, the padstacks of its pads and vias, its components and their
+// packages, its netlist and its bill of materials. Holes are described by
+// drill layers matching the files that package drill writes.
+//
+// Text is drawn as strokes with the font from package strokefont, which is
+// not the same as KiCad's font. Arcs in pads and graphic items are
+// approximated by straight segments, although tracks and the board profile
+// use true arcs. Images and the lines and arrows of dimensions are not
+// included.
+//
+// Boards from KiCad 5 describe some graphic items differently, and should
+// be upgraded using PCB.Upgrade before writing.
+package ipc2581
+
+import (
+	"bytes"
+	"io"
+	"time"
+
+	"github.com/apparentlymart/go-kicad"
+	"github.com/apparentlymart/go-kicad/internal/fileio"
+)
+
+// Options customizes the document that Write produces. A nil *Options is
+// equivalent to a pointer to the zero value.
+type Options struct {
+	// Name names the design in the document. If it is empty then the
+	// title from the board's title block is used, or "board" if that is
+	// also empty.
+	Name string
+
+	// UseAuxOrigin places the origin of the document at the board's
+	// auxiliary axis origin, rather than at the origin of the board
+	// document.
+	UseAuxOrigin bool
+
+	// CreationDate is recorded in the history of the document. If it is
+	// zero then the document has no history record, so that the output
+	// depends only on the board.
+	CreationDate time.Time
+}
+
+// Write writes the given board to the given writer as an IPC-2581 document.
+func Write(w io.Writer, pcb *kicad.PCB, opts *Options) error {
+	if opts == nil {
+		opts = &Options{}
+	}
+	doc := newDocument(pcb, opts)
+
+	var buf bytes.Buffer
+	buf.WriteString("<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n")
+	doc.build().write(&buf, 0)
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// WriteFile writes the given board to the named file, as Write does.
+func WriteFile(filename string, pcb *kicad.PCB, opts *Options) error {
+	return fileio.WriteFile(filename, func(w io.Writer) error {
+		return Write(w, pcb, opts)
+	})
+}
+
+// Filename returns the name KiCad gives to the IPC-2581 document of a
+// board, given the base name of the board file without its extension.
+func Filename(base string) string {
+	return base + ".xml"
+}
+
+// document collects the parts of an IPC-2581 document for a board.
+type document struct {
+	pcb    *kicad.PCB
+	opts   *Options
+	name   string
+	origin kicad.Position
+	copper []string
+	layers []layer
+
+	// dict holds the shapes that pads and padstacks refer to, and
+	// padstacks the padstack definitions.
+	dict      *dictionary
+	padstacks *padstacks
+
+	// packages maps the reference designator of each footprint to the
+	// name of its package.
+	packages map[string]string
+
+	// holeCount numbers the holes of the drill layers.
+	holeCount int
+}
+
+func newDocument(pcb *kicad.PCB, opts *Options) *document {
+	d := &document{
+		pcb:      pcb,
+		opts:     opts,
+		name:     opts.Name,
+		copper:   pcb.CopperLayers(),
+		dict:     newDictionary(),
+		packages: make(map[string]string),
+	}
+	d.padstacks = newPadstacks(d)
+	if d.name == "" {
+		d.name = pcb.TitleBlock.Title
+	}
+	if d.name == "" {
+		d.name = "board"
+	}
+	if opts.UseAuxOrigin {
+		d.origin = pcb.Setup.AuxAxisOrigin
+	}
+	d.layers = boardLayers(pcb)
+	return d
+}
+
+// build returns the root element of the document.
+func (d *document) build() *node {
+	// The dictionaries in the content section are filled in while
+	// building the CAD data, so that is built first.
+	ecad := d.ecad()
+	bom := d.bom()
+
+	root := newNode("IPC-2581",
+		str("revision", "B"),
+		str("xmlns", "http://webstds.ipc.org/2581"),
+		str("xmlns:xsi", "http://www.w3.org/2001/XMLSchema-instance"),
+	)
+	content := root.add("Content", str("roleRef", "Owner"))
+	content.add("FunctionMode", str("mode", "ASSEMBLY"))
+	content.add("StepRef", str("name", d.name))
+	for _, l := range d.layers {
+		content.add("LayerRef", str("name", l.name))
+	}
+	content.add("BomRef", str("name", d.bomName()))
+	if std := d.dict.standard(); std != nil {
+		content.children = append(content.children, std)
+	}
+	if user := d.dict.user(); user != nil {
+		content.children = append(content.children, user)
+	}
+
+	d.logisticHeader(root)
+	if date := d.opts.CreationDate; !date.IsZero() {
+		stamp := date.Format(time.RFC3339)
+		history := root.add("HistoryRecord", integer("number", 1), str("origination", stamp), str("software", "go-kicad"), str("lastChange", stamp))
+		rev := history.add("FileRevision", str("fileRevisionId", "1"), str("comment", ""), str("label", ""))
+		pkg := rev.add("SoftwarePackage", str("name", "go-kicad"), str("vendor", "go-kicad"))
+		pkg.add("Certification", str("certificationStatus", "SELFTEST"))
+	}
+	root.children = append(root.children, bom, ecad)
+	return root
+}
+
+func (d *document) logisticHeader(root *node) {
+	enterprise := d.pcb.TitleBlock.Company
+	if enterprise == "" {
+		enterprise = "UNKNOWN"
+	}
+	header := root.add("LogisticHeader")
+	header.add("Role", str("id", "Owner"), str("roleFunction", "SENDER"))
+	header.add("Enterprise", str("id", enterprise), str("code", "NONE"))
+	header.add("Person", str("name", "UNKNOWN"), str("enterpriseRef", enterprise), str("roleRef", "Owner"))
+}
+
+// ecad returns the element holding the CAD data of the board.
+func (d *document) ecad() *node {
+	ecad := newNode("Ecad", str("name", d.name))
+	header := ecad.add("CadHeader", str("units", "MILLIMETER"))
+	data := ecad.add("CadData")
+	for _, l := range d.layers {
+		l.element(data)
+	}
+	d.stackup(header, data)
+
+	step := data.add("Step", str("name", d.name))
+	// Padstacks come first in the step, but are only known once the
+	// components and layer features using them have been built.
+	step.add("Datum", length("x", 0), length("y", 0))
+	d.profile(step)
+	d.components(step)
+	d.logicalNets(step)
+	d.layerFeatures(step)
+	step.children = append(d.padstacks.elements(), step.children...)
+	return ecad
+}
+
+// xy returns the attributes giving the position of the given point with
+// the given attribute names, relative to the document's origin and with Y
+// increasing upwards.
+func (d *document) xy(x, y string, p kicad.Position) []attr {
+	return []attr{length(x, p.X-d.origin.X), length(y, d.origin.Y-p.Y)}
+}
+
+// location adds a Location element for the given point to the given
+// element.
+func (d *document) location(parent *node, p kicad.Position) {
+	parent.add("Location", d.xy("x", "y", p)...)
+}
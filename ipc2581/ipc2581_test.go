@@ -0,0 +1,399 @@
+package ipc2581
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apparentlymart/go-kicad"
+	"github.com/apparentlymart/go-kicad/internal/testboard"
+)
+
+const testBoardSrc = `(kicad_pcb (version 20240108) (generator pcbnew)
+  (general (thickness 1.6))
+  (title_block (title "Widget") (rev "B") (company "Example & Co"))
+  (layers
+    (0 "F.Cu" signal)
+    (31 "B.Cu" power)
+    (37 "F.SilkS" user "F.Silkscreen")
+    (38 "B.Mask" user)
+    (39 "F.Mask" user)
+    (35 "F.Paste" user)
+    (44 "Edge.Cuts" user)
+    (46 "F.CrtYd" user "F.Courtyard")
+  )
+  (setup
+    (stackup
+      (layer "F.SilkS" (type "Top Silk Screen"))
+      (layer "F.Paste" (type "Top Solder Paste"))
+      (layer "F.Mask" (type "Top Solder Mask") (thickness 0.01))
+      (layer "F.Cu" (type "copper") (thickness 0.035))
+      (layer "dielectric 1" (type "core") (thickness 1.51) (material "FR4") (epsilon_r 4.5) (loss_tangent 0.02))
+      (layer "B.Cu" (type "copper") (thickness 0.035))
+      (layer "B.Mask" (type "Bottom Solder Mask") (thickness 0.01))
+      (copper_finish "ENIG")
+    )
+    (pad_to_mask_clearance 0.05)
+  )
+  (net 0 "")
+  (net 1 "GND")
+  (net 2 "VCC")
+  (footprint "Resistor_SMD:R_0603" (layer "F.Cu")
+    (at 110 90 90)
+    (property "Reference" "R1" (at 0 -1.5 90) (layer "F.SilkS") (effects (font (size 1 1) (thickness 0.15))))
+    (property "Value" "10k")
+    (property "MPN" "RC0603FR-0710KL")
+    (attr smd)
+    (fp_rect (start -1.5 -0.75) (end 1.5 0.75) (layer "F.CrtYd") (stroke (width 0.05)))
+    (pad "1" smd roundrect (at -0.8 0 90) (size 0.8 0.95) (layers "F.Cu" "F.Paste" "F.Mask") (roundrect_rratio 0.25) (net 1 "GND"))
+    (pad "2" smd roundrect (at 0.8 0 90) (size 0.8 0.95) (layers "F.Cu" "F.Paste" "F.Mask") (roundrect_rratio 0.25) (net 2 "VCC"))
+  )
+  (footprint "Resistor_SMD:R_0603" (layer "F.Cu")
+    (at 115 90 90)
+    (property "Reference" "R2")
+    (property "Value" "10k")
+    (attr smd dnp)
+    (fp_rect (start -1.5 -0.75) (end 1.5 0.75) (layer "F.CrtYd") (stroke (width 0.05)))
+    (pad "1" smd roundrect (at -0.8 0 90) (size 0.8 0.95) (layers "F.Cu" "F.Paste" "F.Mask") (roundrect_rratio 0.25) (net 1 "GND"))
+    (pad "2" smd roundrect (at 0.8 0 90) (size 0.8 0.95) (layers "F.Cu" "F.Paste" "F.Mask") (roundrect_rratio 0.25) (net 2 "VCC"))
+  )
+  (footprint "Connector:Conn_01x02" (layer "F.Cu")
+    (at 120 90)
+    (property "Reference" "J1")
+    (property "Value" "Conn")
+    (attr through_hole)
+    (pad "1" thru_hole rect (at 0 0) (size 1.7 1.7) (drill 1) (layers "*.Cu" "*.Mask") (net 1 "GND"))
+    (pad "2" thru_hole oval (at 0 2.54) (size 1.7 2.5) (drill oval 1 1.5) (layers "*.Cu" "*.Mask") (net 2 "VCC"))
+    (pad "" np_thru_hole circle (at 5 0) (size 3 3) (drill 3) (layers "*.Cu" "*.Mask"))
+  )
+  (gr_rect (start 100 80) (end 130 100) (layer "Edge.Cuts") (stroke (width 0.1)))
+  (gr_circle (center 125 85) (end 126 85) (layer "Edge.Cuts") (stroke (width 0.1)))
+  (gr_text "REV B" (at 105 95) (layer "F.SilkS") (effects (font (size 1 1) (thickness 0.15))))
+  (segment (start 110.8 90) (end 115 95) (width 0.25) (layer "F.Cu") (net 2))
+  (arc (start 115 95) (mid 117 96) (end 119 95) (width 0.25) (layer "B.Cu") (net 2))
+  (via (at 115 95) (size 0.6) (drill 0.3) (layers "F.Cu" "B.Cu") (net 2))
+  (zone (net 1) (net_name "GND") (layer "B.Cu")
+    (filled_polygon (layer "B.Cu") (pts (xy 101 81) (xy 109 81) (xy 109 89)))
+  )
+)
+`
+
+func testBoard(t *testing.T) *kicad.PCB {
+	t.Helper()
+	pcb, err := kicad.ReadPCB(strings.NewReader(testBoardSrc))
+	if err != nil {
+		t.Fatalf("reading board: %s", err)
+	}
+	return pcb
+}
+
+func write(t *testing.T, pcb *kicad.PCB, opts *Options) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := Write(&buf, pcb, opts); err != nil {
+		t.Fatal(err)
+	}
+
+	// The document must at least be well-formed XML.
+	dec := xml.NewDecoder(bytes.NewReader(buf.Bytes()))
+	for {
+		_, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("output is not well-formed: %s\n%s", err, buf.String())
+		}
+	}
+	return buf.String()
+}
+
+// unindent removes the indentation from each line of the given XML, so
+// that the tests can compare fragments of documents without depending on
+// how deeply they are nested.
+func unindent(s string) string {
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimLeft(line, " ")
+	}
+	return strings.Join(lines, "\n")
+}
+
+func TestWrite(t *testing.T) {
+	got := write(t, testBoard(t), nil)
+
+	for _, want := range []string{
+		`<IPC-2581 revision="B" xmlns="http://webstds.ipc.org/2581" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance">`,
+		`<StepRef name="Widget"/>`,
+		`<BomRef name="Widget_BOM"/>`,
+		`<Enterprise id="Example &amp; Co" code="NONE"/>`,
+
+		// Shapes are described once, unrotated, and enlarged by the
+		// margins on mask layers.
+		`<EntryStandard id="ROUNDRECT_0.8X0.95_R0.2">
+        <RectRound width="0.8" height="0.95" radius="0.2" upperRight="true" upperLeft="true" lowerRight="true" lowerLeft="true"/>
+      </EntryStandard>`,
+		`<EntryStandard id="RECT_1.8X1.8">`,
+
+		// Layers follow the stackup, with its dielectric layers
+		// between the copper layers, and then the drill layers.
+		`<Layer name="F.Cu" layerFunction="SIGNAL" side="TOP" polarity="POSITIVE"/>
+        <Layer name="dielectric_1" layerFunction="DIELCORE" side="INTERNAL" polarity="POSITIVE"/>
+        <Layer name="B.Cu" layerFunction="PLANE" side="BOTTOM" polarity="POSITIVE"/>
+        <Layer name="B.Mask" layerFunction="SOLDERMASK" side="BOTTOM" polarity="POSITIVE"/>
+        <Layer name="Edge.Cuts" layerFunction="BOARD_OUTLINE" side="ALL" polarity="POSITIVE"/>`,
+		`<Layer name="DRILL-PTH" layerFunction="DRILL" side="ALL" polarity="POSITIVE">
+          <Span fromLayer="F.Cu" toLayer="B.Cu"/>
+        </Layer>`,
+		`<Spec name="SPEC_dielectric_1">
+          <General type="MATERIAL">
+            <Property text="FR4"/>
+          </General>
+          <Dielectric type="DIELECTRIC_CONSTANT">
+            <Property value="4.5"/>
+          </Dielectric>`,
+		`<Stackup name="PRIMARY" overallThickness="1.6" tolPlus="0" tolMinus="0" whereMeasured="METAL">`,
+		`<StackupLayer layerOrGroupRef="dielectric_1" thickness="1.51" tolPlus="0" tolMinus="0" sequence="5">
+              <SpecRef id="SPEC_dielectric_1"/>
+            </StackupLayer>`,
+
+		`<PadStackDef name="PADSTACK_1">
+            <PadstackHoleDef name="H_0.3_VIA" diameter="0.3" platingStatus="VIA" plusTol="0" minusTol="0" x="0" y="0"/>
+            <PadstackPadDef layerRef="F.Cu" padUse="REGULAR">
+              <Location x="0" y="0"/>
+              <StandardPrimitiveRef id="CIRCLE_0.6"/>
+            </PadstackPadDef>`,
+
+		// The rectangle is the outside of the board and the circle a
+		// cutout within it.
+		`<Profile>
+            <Polygon>
+              <PolyBegin x="100" y="-80"/>
+              <PolyStepSegment x="130" y="-80"/>
+              <PolyStepSegment x="130" y="-100"/>
+              <PolyStepSegment x="100" y="-100"/>
+              <PolyStepSegment x="100" y="-80"/>
+            </Polygon>
+            <Cutout>
+              <PolyBegin x="126" y="-85"/>
+              <PolyStepCurve x="124" y="-85" centerX="125" centerY="-85" clockwise="true"/>
+              <PolyStepCurve x="126" y="-85" centerX="125" centerY="-85" clockwise="true"/>
+            </Cutout>
+          </Profile>`,
+
+		// R1 and R2 share a package.
+		`<Package name="Resistor_SMD:R_0603" type="OTHER" pinOne="1">
+            <Outline>
+              <PolyBegin x="-1.5" y="0.75"/>`,
+		`<Pin number="2" type="THRU" electricalType="ELECTRICAL">
+              <Location x="0" y="-2.54"/>
+              <StandardPrimitiveRef id="OVAL_1.7X2.5"/>
+            </Pin>`,
+		`<Component refDes="R2" packageRef="Resistor_SMD:R_0603" layerRef="F.Cu" part="10k_R_0603" mountType="SMT">
+            <Xform rotation="90"/>
+            <Location x="115" y="-90"/>
+          </Component>`,
+		`<LogicalNet name="GND">
+            <PinRef componentRef="R1" pin="1"/>
+            <PinRef componentRef="R2" pin="1"/>
+            <PinRef componentRef="J1" pin="1"/>
+          </LogicalNet>`,
+
+		`<Set net="GND" padUsage="TERMINATION">
+              <Pad padstackDefRef="PADSTACK_2">
+                <Xform rotation="90"/>
+                <Location x="110" y="-90.8"/>
+                <StandardPrimitiveRef id="ROUNDRECT_0.8X0.95_R0.2"/>
+                <PinRef componentRef="R1" pin="1"/>
+              </Pad>
+            </Set>`,
+		`<Line startX="110.8" startY="-90" endX="115" endY="-95">
+                  <LineDesc lineEnd="ROUND" lineWidth="0.25"/>
+                </Line>`,
+		`<Arc startX="115" startY="-95" endX="119" endY="-95" centerX="117" centerY="-93.5" clockwise="false">`,
+		`<Set net="VCC" padUsage="VIA">
+              <Pad padstackDefRef="PADSTACK_1">
+                <Location x="115" y="-95"/>`,
+		`<Set net="GND">
+              <Features>
+                <Location x="0" y="0"/>
+                <Contour>
+                  <Polygon>
+                    <PolyBegin x="101" y="-81"/>`,
+		`<Set geometryUsage="TEXT">`,
+
+		`<Hole name="H1" diameter="0.3" platingStatus="VIA" plusTol="0" minusTol="0" x="115" y="-95"/>`,
+		`<SlotCavity name="H3" platingStatus="PLATED" plusTol="0" minusTol="0">
+                <Xform rotation="270"/>
+                <Location x="120" y="-92.54"/>
+                <Oval width="1.5" height="1"/>
+              </SlotCavity>`,
+		`<Hole name="H4" diameter="3" platingStatus="NONPLATED" plusTol="0" minusTol="0" x="125" y="-90"/>`,
+
+		// The DNP resistor has a line of its own, and is not populated.
+		`<BomItem OEMDesignNumberRef="10k_R_0603" quantity="1" pinCount="2" category="ELECTRICAL">
+        <RefDes name="R2" packageRef="Resistor_SMD:R_0603" populate="false" layerRef="F.Cu"/>`,
+		`<Textual definitionSource="KICAD" textualCharacteristicName="MPN" textualCharacteristicValue="RC0603FR-0710KL"/>`,
+	} {
+		if !strings.Contains(unindent(got), unindent(want)) {
+			t.Errorf("output does not contain\n%s\n\ngot:\n%s", want, got)
+		}
+	}
+
+	// Without a creation date there is no history record, so that the
+	// output depends only on the board.
+	if strings.Contains(got, "HistoryRecord") {
+		t.Errorf("output has a history record without a creation date")
+	}
+}
+
+func TestWrite_options(t *testing.T) {
+	pcb := testBoard(t)
+	pcb.Setup.AuxAxisOrigin = kicad.Position{X: 100, Y: 100}
+	got := write(t, pcb, &Options{
+		Name:         "widget-v2",
+		UseAuxOrigin: true,
+		CreationDate: time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC),
+	})
+
+	for _, want := range []string{
+		`<StepRef name="widget-v2"/>`,
+		`<HistoryRecord number="1" origination="2024-03-01T12:30:00Z" software="go-kicad" lastChange="2024-03-01T12:30:00Z">`,
+		`<Component refDes="J1" packageRef="Connector:Conn_01x02" layerRef="F.Cu" part="Conn_Conn_01x02" mountType="THMT">
+            <Location x="20" y="10"/>
+          </Component>`,
+	} {
+		if !strings.Contains(unindent(got), unindent(want)) {
+			t.Errorf("output does not contain\n%s\n\ngot:\n%s", want, got)
+		}
+	}
+}
+
+func TestWrite_noStackup(t *testing.T) {
+	pcb := testBoard(t)
+	pcb.Setup.Stackup = kicad.PCBStackup{}
+	got := write(t, pcb, nil)
+
+	if strings.Contains(got, "<Stackup") || strings.Contains(got, "dielectric") {
+		t.Errorf("output has a stackup for a board without one\n%s", got)
+	}
+	want := `<Layer name="F.SilkS" layerFunction="SILKSCREEN" side="TOP" polarity="POSITIVE"/>
+        <Layer name="F.Paste" layerFunction="SOLDERPASTE" side="TOP" polarity="POSITIVE"/>
+        <Layer name="F.Mask" layerFunction="SOLDERMASK" side="TOP" polarity="POSITIVE"/>
+        <Layer name="F.Cu" layerFunction="SIGNAL" side="TOP" polarity="POSITIVE"/>
+        <Layer name="B.Cu" layerFunction="PLANE" side="BOTTOM" polarity="POSITIVE"/>
+        <Layer name="B.Mask" layerFunction="SOLDERMASK" side="BOTTOM" polarity="POSITIVE"/>
+        <Layer name="Edge.Cuts" layerFunction="BOARD_OUTLINE" side="ALL" polarity="POSITIVE"/>
+        <Layer name="F.CrtYd" layerFunction="DOCUMENT" side="TOP" polarity="POSITIVE"/>`
+	if !strings.Contains(unindent(got), unindent(want)) {
+		t.Errorf("wrong layers\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestWrite_innerLayers(t *testing.T) {
+	got := write(t, testboard.Read(t), nil)
+
+	// Dielectrics sit between the copper layers in stackup order, and
+	// each pair of layers with blind or buried vias has a drill layer.
+	var layers []string
+	for _, line := range strings.Split(got, "\n") {
+		if name, ok := strings.CutPrefix(strings.TrimSpace(line), `<LayerRef name="`); ok {
+			name, _, _ = strings.Cut(name, `"`)
+			layers = append(layers, name)
+		}
+	}
+	want := []string{
+		"F.SilkS", "F.Paste", "F.Mask",
+		"F.Cu", "dielectric_1", "In1.Cu", "dielectric_2", "In2.Cu", "dielectric_3", "B.Cu",
+		"B.Mask", "Edge.Cuts", "F.CrtYd",
+		"DRILL-PTH", "DRILL-front-in1", "DRILL-in1-in2", "DRILL-NPTH",
+	}
+	if !reflect.DeepEqual(layers, want) {
+		t.Errorf("wrong layers\ngot:  %q\nwant: %q", layers, want)
+	}
+}
+
+func TestChainEdges(t *testing.T) {
+	p := func(x, y float64) kicad.Position { return kicad.Position{X: x, Y: y} }
+	// A triangle drawn as three lines in no particular order and
+	// direction, and a stray line that doesn't close.
+	chains := [][]edge{
+		{{start: p(0, 0), end: p(10, 0)}},
+		{{start: p(0, 0), end: p(5, 5)}},
+		{{start: p(20, 20), end: p(30, 30)}},
+		{{start: p(10, 0), end: p(5, 5)}},
+	}
+	loops := chainEdges(chains)
+	if len(loops) != 1 {
+		t.Fatalf("wrong number of loops %d; want 1", len(loops))
+	}
+	var got []kicad.Position
+	for _, e := range loops[0] {
+		got = append(got, e.start)
+	}
+	want := []kicad.Position{p(0, 0), p(10, 0), p(5, 5)}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong loop\ngot:  %v\nwant: %v", got, want)
+	}
+}
+
+func TestFilename(t *testing.T) {
+	if got, want := Filename("widget"), "widget.xml"; got != want {
+		t.Errorf("wrong filename %q; want %q", got, want)
+	}
+}
+
+func TestPadShape_custom(t *testing.T) {
+	pad := &kicad.Pad{
+		Shape:   "custom",
+		Size:    kicad.Size{Width: 1, Height: 1},
+		Options: kicad.PadOptions{Anchor: "rect"},
+		Primitives: kicad.PadPrimitives{
+			Polys: []kicad.GraphicPoly{{
+				Points: kicad.NewPoints(kicad.Position{X: 0, Y: 0}, kicad.Position{X: 2, Y: 0}, kicad.Position{X: 2, Y: 1}),
+				Fill:   "yes",
+			}},
+		},
+	}
+	dict := newDictionary()
+	ref, ok := dict.padShape(pad, kicad.Size{})
+	if !ok || !ref.user {
+		t.Fatalf("wrong shape %#v; want a user entry", ref)
+	}
+	if again, _ := dict.padShape(pad, kicad.Size{}); again != ref {
+		t.Errorf("same pad has a different shape %#v; want %#v", again, ref)
+	}
+
+	var buf bytes.Buffer
+	dict.user().write(&buf, 0)
+	got := buf.String()
+	want := `<DictionaryUser units="MILLIMETER">
+  <EntryUser id="CUSTOM_1">
+    <UserSpecial>
+      <Outline>
+        <PolyBegin x="-0.5" y="0.5"/>
+        <PolyStepSegment x="0.5" y="0.5"/>
+        <PolyStepSegment x="0.5" y="-0.5"/>
+        <PolyStepSegment x="-0.5" y="-0.5"/>
+        <PolyStepSegment x="-0.5" y="0.5"/>
+        <LineDesc lineEnd="ROUND" lineWidth="0"/>
+      </Outline>
+      <Outline>
+        <PolyBegin x="0" y="0"/>
+        <PolyStepSegment x="2" y="0"/>
+        <PolyStepSegment x="2" y="-1"/>
+        <PolyStepSegment x="0" y="0"/>
+        <LineDesc lineEnd="ROUND" lineWidth="0"/>
+      </Outline>
+    </UserSpecial>
+  </EntryUser>
+</DictionaryUser>
+`
+	if got != want {
+		t.Errorf("incorrect result\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
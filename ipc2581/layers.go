@@ -0,0 +1,205 @@
+package ipc2581
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/apparentlymart/go-kicad"
+	"github.com/apparentlymart/go-kicad/drill"
+)
+
+// layer is a layer of the document, which is either a layer of the board,
+// a dielectric layer from its stackup or a drill layer.
+type layer struct {
+	name     string
+	function string
+	side     string
+
+	// board is the name of the board layer, for layers that have one.
+	board string
+
+	// drill is the drill file whose holes are on a drill layer.
+	drill *drill.File
+
+	stackup *kicad.PCBStackupLayer
+}
+
+func (l *layer) element(parent *node) {
+	n := parent.add("Layer",
+		str("name", l.name),
+		str("layerFunction", l.function),
+		str("side", l.side),
+		str("polarity", "POSITIVE"),
+	)
+	if l.drill != nil {
+		n.add("Span", str("fromLayer", l.drill.Pair.Top), str("toLayer", l.drill.Pair.Bottom))
+	}
+}
+
+// boardLayers returns the layers of the document for the given board. The
+// layers in the board's stackup come first, in its order from top to
+// bottom, followed by the other layers of the board and then the drill
+// layers.
+func boardLayers(pcb *kicad.PCB) []layer {
+	copper := pcb.CopperLayers()
+	var ret []layer
+	done := make(map[string]bool)
+	for i := range pcb.Setup.Stackup.Layers {
+		sl := &pcb.Setup.Stackup.Layers[i]
+		if isDielectric(sl) {
+			function := "DIELPREG"
+			if sl.Type == "core" {
+				function = "DIELCORE"
+			}
+			ret = append(ret, layer{
+				name:     strings.ReplaceAll(sl.Name, " ", "_"),
+				function: function,
+				side:     "INTERNAL",
+				stackup:  sl,
+			})
+			continue
+		}
+		for _, bl := range pcb.Layers {
+			if bl.Name == sl.Name {
+				l := boardLayer(&bl, copper)
+				l.stackup = sl
+				ret = append(ret, l)
+				done[bl.Name] = true
+			}
+		}
+	}
+
+	// Without a stackup the layers are in the conventional order of a
+	// fabrication drawing.
+	order := []string{"F.SilkS", "F.Paste", "F.Mask"}
+	order = append(order, copper...)
+	order = append(order, "B.Mask", "B.Paste", "B.SilkS", "Edge.Cuts")
+	for _, name := range order {
+		for _, bl := range pcb.Layers {
+			if bl.Name == name && !done[name] {
+				ret = append(ret, boardLayer(&bl, copper))
+				done[name] = true
+			}
+		}
+	}
+	for _, bl := range pcb.Layers {
+		if !done[bl.Name] {
+			ret = append(ret, boardLayer(&bl, copper))
+		}
+	}
+
+	for _, f := range drill.Files(pcb) {
+		ret = append(ret, layer{
+			name:     "DRILL" + strings.TrimSuffix(f.Name(""), ".drl"),
+			function: "DRILL",
+			side:     "ALL",
+			drill:    f,
+		})
+	}
+	return ret
+}
+
+// boardLayer returns the document layer for the given board layer.
+func boardLayer(bl *kicad.PCBLayer, copper []string) layer {
+	l := layer{name: bl.Name, board: bl.Name, side: "NONE"}
+	switch {
+	case strings.HasPrefix(bl.Name, "F."):
+		l.side = "TOP"
+	case strings.HasPrefix(bl.Name, "B."):
+		l.side = "BOTTOM"
+	}
+
+	switch kind := bl.Name[strings.LastIndex(bl.Name, ".")+1:]; {
+	case kind == "Cu":
+		if slices.Contains(copper, bl.Name) && l.side == "NONE" {
+			l.side = "INTERNAL"
+		}
+		switch bl.Type {
+		case "power":
+			l.function = "PLANE"
+		case "mixed":
+			l.function = "MIXED"
+		default:
+			l.function = "SIGNAL"
+		}
+	case kind == "Mask":
+		l.function = "SOLDERMASK"
+	case kind == "Paste":
+		l.function = "SOLDERPASTE"
+	case kind == "SilkS":
+		l.function = "SILKSCREEN"
+	case kind == "Adhes":
+		l.function = "GLUE"
+	case bl.Name == "Edge.Cuts":
+		l.function = "BOARD_OUTLINE"
+		l.side = "ALL"
+	default:
+		l.function = "DOCUMENT"
+	}
+	return l
+}
+
+func isDielectric(sl *kicad.PCBStackupLayer) bool {
+	return strings.HasPrefix(sl.Name, "dielectric")
+}
+
+// stackup adds the board's stackup to the CAD data, along with the
+// specifications of the materials of its dielectric layers to the CAD
+// header. Boards without a stackup in their setup have none in the
+// document.
+func (d *document) stackup(header, data *node) {
+	var layers []*layer
+	total := 0.0
+	for i := range d.layers {
+		if l := &d.layers[i]; l.stackup != nil {
+			layers = append(layers, l)
+			total += l.stackup.Thickness.Value
+		}
+	}
+	if len(layers) == 0 {
+		return
+	}
+	if t := d.pcb.General.Thickness; t > 0 {
+		total = t
+	}
+
+	stackup := data.add("Stackup",
+		str("name", "PRIMARY"),
+		length("overallThickness", total),
+		length("tolPlus", 0),
+		length("tolMinus", 0),
+		str("whereMeasured", "METAL"),
+	)
+	group := stackup.add("StackupGroup",
+		str("name", "GROUP_PRIMARY"),
+		length("thickness", total),
+		length("tolPlus", 0),
+		length("tolMinus", 0),
+	)
+	for i, l := range layers {
+		sl := l.stackup
+		n := group.add("StackupLayer",
+			str("layerOrGroupRef", l.name),
+			length("thickness", sl.Thickness.Value),
+			length("tolPlus", 0),
+			length("tolMinus", 0),
+			integer("sequence", i+1),
+		)
+		if sl.Material == "" && sl.EpsilonR == 0 && sl.LossTangent == 0 {
+			continue
+		}
+		spec := "SPEC_" + l.name
+		n.add("SpecRef", str("id", spec))
+		s := header.add("Spec", str("name", spec))
+		if sl.Material != "" {
+			s.add("General", str("type", "MATERIAL")).add("Property", str("text", sl.Material))
+		}
+		if sl.EpsilonR > 0 {
+			s.add("Dielectric", str("type", "DIELECTRIC_CONSTANT")).add("Property", str("value", strconv.FormatFloat(sl.EpsilonR, 'f', -1, 64)))
+		}
+		if sl.LossTangent > 0 {
+			s.add("Dielectric", str("type", "LOSS_TANGENT")).add("Property", str("value", strconv.FormatFloat(sl.LossTangent, 'f', -1, 64)))
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package ipc2581
+
+import (
+	"bytes"
+	"math"
+	"slices"
+	"strconv"
+
+	"github.com/apparentlymart/go-kicad"
+)
+
+// padstacks collects the padstack definitions of the pads and vias of a
+// board. Pads and vias with the same hole and the same shapes on the same
+// copper layers share a definition.
+type padstacks struct {
+	d     *document
+	defs  []*node
+	names map[string]string
+}
+
+func newPadstacks(d *document) *padstacks {
+	return &padstacks{d: d, names: make(map[string]string)}
+}
+
+// pad returns the name of the padstack definition for the given pad. The
+// definition is unrotated and centered on the pad's position, which is the
+// position of its hole; the shape of the pad may be offset from it.
+func (ps *padstacks) pad(pad *kicad.Pad) string {
+	def := newNode("PadStackDef")
+	if pad.HasDrill() {
+		diameter := pad.Drill.Width
+		if pad.Drill.Oval && pad.Drill.Height > 0 {
+			diameter = math.Min(pad.Drill.Width, pad.Drill.Height)
+		}
+		plating := "PLATED"
+		if pad.Type == "np_thru_hole" {
+			plating = "NONPLATED"
+		}
+		holeDef(def, diameter, plating)
+	}
+	if hasCopper(pad) {
+		for _, layer := range ps.d.copper {
+			if !kicad.OnLayer(pad.Layers, layer) {
+				continue
+			}
+			shape, ok := ps.d.dict.padShape(pad, kicad.Size{})
+			if !ok {
+				continue
+			}
+			n := def.add("PadstackPadDef", str("layerRef", layer), str("padUse", "REGULAR"))
+			n.add("Location", localXY("x", "y", pad.Drill.Offset)...)
+			shape.element(n)
+		}
+	}
+	return ps.define(def)
+}
+
+// via returns the name of the padstack definition for the given via.
+func (ps *padstacks) via(via *kicad.Via) string {
+	def := newNode("PadStackDef")
+	holeDef(def, via.Drill, "VIA")
+	shape := ps.d.dict.circle(via.Size)
+	for _, layer := range ps.d.viaLayers(via) {
+		n := def.add("PadstackPadDef", str("layerRef", layer), str("padUse", "REGULAR"))
+		n.add("Location", length("x", 0), length("y", 0))
+		shape.element(n)
+	}
+	return ps.define(def)
+}
+
+// define adds the given definition, which has no name yet, unless there is
+// already one with the same content, and returns its name.
+func (ps *padstacks) define(def *node) string {
+	var key bytes.Buffer
+	def.write(&key, 0)
+	if name, ok := ps.names[key.String()]; ok {
+		return name
+	}
+	name := "PADSTACK_" + strconv.Itoa(len(ps.defs)+1)
+	ps.names[key.String()] = name
+	def.attrs = append([]attr{str("name", name)}, def.attrs...)
+	ps.defs = append(ps.defs, def)
+	return name
+}
+
+// elements returns the padstack definitions.
+func (ps *padstacks) elements() []*node {
+	return ps.defs
+}
+
+func holeDef(def *node, diameter float64, plating string) {
+	def.add("PadstackHoleDef",
+		str("name", "H_"+num(diameter)+"_"+plating),
+		length("diameter", diameter),
+		str("platingStatus", plating),
+		length("plusTol", 0),
+		length("minusTol", 0),
+		length("x", 0),
+		length("y", 0),
+	)
+}
+
+// hasCopper returns true if the given pad has copper around its hole, or
+// has no hole at all. Non-plated holes are often given pads no larger than
+// the hole, which leave no copper behind.
+func hasCopper(pad *kicad.Pad) bool {
+	if pad.Type != "np_thru_hole" {
+		return true
+	}
+	return pad.Size.Width > pad.Drill.Width || pad.Size.Height > math.Max(pad.Drill.Width, pad.Drill.Height)
+}
+
+// viaLayers returns the copper layers that the given via is on. A through
+// via is on all of them, while blind and micro vias are on the layers
+// between the pair given in the via.
+func (d *document) viaLayers(via *kicad.Via) []string {
+	if via.Type == "" || len(via.Layers) != 2 {
+		return d.copper
+	}
+	from, to := slices.Index(d.copper, via.Layers[0]), slices.Index(d.copper, via.Layers[1])
+	if from < 0 || to < 0 {
+		return via.Layers
+	}
+	if from > to {
+		from, to = to, from
+	}
+	return d.copper[from : to+1]
+}
@@ -0,0 +1,189 @@
+package ipc2581
+
+import (
+	"math"
+
+	"github.com/apparentlymart/go-kicad"
+)
+
+// edge is a straight or curved piece of the outline of a board.
+type edge struct {
+	start, end kicad.Position
+
+	// arc is set for curved edges, which run around center in the given
+	// direction as seen on screen.
+	arc       bool
+	center    kicad.Position
+	clockwise bool
+}
+
+func (e edge) reversed() edge {
+	e.start, e.end = e.end, e.start
+	e.clockwise = !e.clockwise
+	return e
+}
+
+// joinTolerance is the greatest distance in millimeters between the ends of
+// two edges for them to be joined in an outline.
+const joinTolerance = 0.001
+
+// profile adds the outline of the board, drawn by the items on the
+// Edge.Cuts layer, to the step. The largest closed outline is the outside of
+// the board and any others are cutouts within it. Items that don't form a
+// closed outline are left out.
+func (d *document) profile(step *node) {
+	var open [][]edge
+	var loops [][]edge
+
+	addItems := func(lines []kicad.GraphicLine, arcs []kicad.GraphicArc, circles []kicad.GraphicCircle, rects []kicad.GraphicRect, polys []kicad.GraphicPoly, fp *kicad.Footprint) {
+		tr := func(p kicad.Position) kicad.Position {
+			if fp == nil {
+				return p
+			}
+			return fp.BoardPosition(p)
+		}
+		for _, line := range lines {
+			if line.Layer == "Edge.Cuts" {
+				open = append(open, []edge{{start: tr(line.Start), end: tr(line.End)}})
+			}
+		}
+		for _, arc := range arcs {
+			if arc.Layer != "Edge.Cuts" {
+				continue
+			}
+			start, mid, end := tr(arc.Start), tr(arc.Mid), tr(arc.End)
+			center, ok := kicad.ArcCenter(start, mid, end)
+			if !ok {
+				open = append(open, []edge{{start: start, end: end}})
+				continue
+			}
+			clockwise := kicad.ArcSweep(center, start, mid, end) > 0
+			open = append(open, []edge{{start: start, end: end, arc: true, center: center, clockwise: clockwise}})
+		}
+		for _, circle := range circles {
+			if circle.Layer != "Edge.Cuts" {
+				continue
+			}
+			center, end := tr(circle.Center), tr(circle.End)
+			opposite := kicad.Position{X: 2*center.X - end.X, Y: 2*center.Y - end.Y}
+			loops = append(loops, []edge{
+				{start: end, end: opposite, arc: true, center: center, clockwise: true},
+				{start: opposite, end: end, arc: true, center: center, clockwise: true},
+			})
+		}
+		for _, rect := range rects {
+			if rect.Layer == "Edge.Cuts" {
+				loops = append(loops, polygonEdges(kicad.TransformPoints(kicad.RectPoints(rect.Start, rect.End), tr)))
+			}
+		}
+		for _, poly := range polys {
+			if poly.Layer == "Edge.Cuts" && len(poly.Points.Polyline()) >= 3 {
+				loops = append(loops, polygonEdges(kicad.TransformPoints(poly.Points.Polyline(), tr)))
+			}
+		}
+	}
+	pcb := d.pcb
+	addItems(pcb.GraphicLines, pcb.GraphicArcs, pcb.GraphicCircles, pcb.GraphicRects, pcb.GraphicPolys, nil)
+	for i := range pcb.Footprints {
+		fp := &pcb.Footprints[i]
+		addItems(fp.Lines, fp.Arcs, fp.Circles, fp.Rects, fp.Polys, fp)
+	}
+	loops = append(loops, chainEdges(open)...)
+	if len(loops) == 0 {
+		return
+	}
+
+	outer := 0
+	for i := range loops {
+		if loopArea(loops[i]) > loopArea(loops[outer]) {
+			outer = i
+		}
+	}
+	profile := step.add("Profile")
+	d.outline(profile, "Polygon", loops[outer])
+	for i, loop := range loops {
+		if i != outer {
+			d.outline(profile, "Cutout", loop)
+		}
+	}
+}
+
+// outline adds an element with the given name describing the closed
+// outline made up of the given edges.
+func (d *document) outline(parent *node, name string, loop []edge) {
+	n := parent.add(name)
+	n.add("PolyBegin", d.xy("x", "y", loop[0].start)...)
+	for _, e := range loop {
+		if !e.arc {
+			n.add("PolyStepSegment", d.xy("x", "y", e.end)...)
+			continue
+		}
+		step := n.add("PolyStepCurve", d.xy("x", "y", e.end)...)
+		step.attrs = append(step.attrs, d.xy("centerX", "centerY", e.center)...)
+		step.attrs = append(step.attrs, boolean("clockwise", e.clockwise))
+	}
+}
+
+func polygonEdges(points []kicad.Position) []edge {
+	ret := make([]edge, len(points))
+	for i, p := range points {
+		ret[i] = edge{start: p, end: points[(i+1)%len(points)]}
+	}
+	return ret
+}
+
+// chainEdges joins the given chains of edges end to end, and returns those
+// that form closed loops.
+func chainEdges(chains [][]edge) [][]edge {
+	var loops [][]edge
+	for len(chains) > 0 {
+		chain := chains[0]
+		chains = chains[1:]
+		for {
+			first, last := chain[0].start, chain[len(chain)-1].end
+			if len(chain) > 1 && kicad.Distance(first, last) <= joinTolerance {
+				loops = append(loops, chain)
+				break
+			}
+			joined := false
+			for i, other := range chains {
+				switch {
+				case kicad.Distance(last, other[0].start) <= joinTolerance:
+					chain = append(chain, other...)
+				case kicad.Distance(last, other[len(other)-1].end) <= joinTolerance:
+					for j := len(other) - 1; j >= 0; j-- {
+						chain = append(chain, other[j].reversed())
+					}
+				default:
+					continue
+				}
+				chains = append(chains[:i:i], chains[i+1:]...)
+				joined = true
+				break
+			}
+			if !joined {
+				break
+			}
+		}
+	}
+	return loops
+}
+
+// loopArea returns the area of the bounding box of the given loop, which
+// is enough to tell the outside of a board from the cutouts within it.
+func loopArea(loop []edge) float64 {
+	min, max := loop[0].start, loop[0].start
+	extend := func(p kicad.Position) {
+		min.X, min.Y = math.Min(min.X, p.X), math.Min(min.Y, p.Y)
+		max.X, max.Y = math.Max(max.X, p.X), math.Max(max.Y, p.Y)
+	}
+	for _, e := range loop {
+		extend(e.end)
+		if e.arc {
+			r := kicad.Distance(e.center, e.start)
+			extend(kicad.Position{X: e.center.X - r, Y: e.center.Y - r})
+			extend(kicad.Position{X: e.center.X + r, Y: e.center.Y + r})
+		}
+	}
+	return (max.X - min.X) * (max.Y - min.Y)
+}
@@ -0,0 +1,275 @@
+package ipc2581
+
+import (
+	"bytes"
+	"math"
+	"strconv"
+
+	"github.com/apparentlymart/go-kicad"
+)
+
+// dictionary collects the shapes of pads, so that each distinct shape is
+// described once in the content section of the document and referred to
+// by its id elsewhere.
+//
+// Shapes that IPC-2581 has a standard primitive for are standard entries,
+// while the shapes of custom pads are user entries made up of several
+// features.
+type dictionary struct {
+	standardEntries []*node
+	userEntries     []*node
+
+	// ids maps the key of each entry to its id.
+	ids map[string]string
+}
+
+// shapeRef refers to an entry of the dictionary.
+type shapeRef struct {
+	id   string
+	user bool
+}
+
+func newDictionary() *dictionary {
+	return &dictionary{ids: make(map[string]string)}
+}
+
+// element adds the element referring to the shape to the given element.
+func (r shapeRef) element(parent *node) {
+	if r.user {
+		parent.add("UserPrimitiveRef", str("id", r.id))
+		return
+	}
+	parent.add("StandardPrimitiveRef", str("id", r.id))
+}
+
+// entry adds a standard entry with the given primitive to the dictionary,
+// unless it already has one with the same shape, and returns a reference to
+// it. If id is empty then an id is chosen from the given prefix.
+func (dict *dictionary) entry(id, prefix string, prim *node) shapeRef {
+	var key bytes.Buffer
+	prim.write(&key, 0)
+	if existing, ok := dict.ids[key.String()]; ok {
+		return shapeRef{id: existing}
+	}
+	if id == "" {
+		id = prefix + "_" + strconv.Itoa(len(dict.standardEntries)+1)
+	}
+	dict.ids[key.String()] = id
+	entry := newNode("EntryStandard", str("id", id))
+	entry.children = []*node{prim}
+	dict.standardEntries = append(dict.standardEntries, entry)
+	return shapeRef{id: id}
+}
+
+func (dict *dictionary) circle(diameter float64) shapeRef {
+	return dict.entry("CIRCLE_"+num(diameter), "", newNode("Circle", length("diameter", diameter)))
+}
+
+func (dict *dictionary) rect(w, h float64) shapeRef {
+	return dict.entry("RECT_"+num(w)+"X"+num(h), "", newNode("RectCenter", length("width", w), length("height", h)))
+}
+
+func (dict *dictionary) oval(w, h float64) shapeRef {
+	return dict.entry("OVAL_"+num(w)+"X"+num(h), "", newNode("Oval", length("width", w), length("height", h)))
+}
+
+func (dict *dictionary) roundRect(w, h, r float64) shapeRef {
+	prim := newNode("RectRound",
+		length("width", w),
+		length("height", h),
+		length("radius", r),
+		boolean("upperRight", true),
+		boolean("upperLeft", true),
+		boolean("lowerRight", true),
+		boolean("lowerLeft", true),
+	)
+	return dict.entry("ROUNDRECT_"+num(w)+"X"+num(h)+"_R"+num(r), "", prim)
+}
+
+// contour adds a polygon with the given vertices, relative to the center
+// of the shape, to the dictionary.
+func (dict *dictionary) contour(points []kicad.Position) shapeRef {
+	prim := newNode("Contour")
+	polygon(prim, "Polygon", points, localXY)
+	return dict.entry("", "CONTOUR", prim)
+}
+
+// custom adds a user entry made up of the given features to the
+// dictionary, unless it already has one with the same features.
+func (dict *dictionary) custom(features []*node) shapeRef {
+	special := newNode("UserSpecial")
+	special.children = features
+	var key bytes.Buffer
+	special.write(&key, 0)
+	if existing, ok := dict.ids[key.String()]; ok {
+		return shapeRef{id: existing, user: true}
+	}
+	id := "CUSTOM_" + strconv.Itoa(len(dict.userEntries)+1)
+	dict.ids[key.String()] = id
+	entry := newNode("EntryUser", str("id", id))
+	entry.children = []*node{special}
+	dict.userEntries = append(dict.userEntries, entry)
+	return shapeRef{id: id, user: true}
+}
+
+// standard returns the element holding the standard entries of the
+// dictionary, or nil if there are none.
+func (dict *dictionary) standard() *node {
+	if len(dict.standardEntries) == 0 {
+		return nil
+	}
+	n := newNode("DictionaryStandard", str("units", "MILLIMETER"))
+	n.children = dict.standardEntries
+	return n
+}
+
+// user returns the element holding the user entries of the dictionary, or
+// nil if there are none.
+func (dict *dictionary) user() *node {
+	if len(dict.userEntries) == 0 {
+		return nil
+	}
+	n := newNode("DictionaryUser", str("units", "MILLIMETER"))
+	n.children = dict.userEntries
+	return n
+}
+
+// padShape adds the shape of the given pad to the dictionary, enlarged by
+// the given margin on each axis, and returns a reference to it. The shape
+// is unrotated and centered on the center of the pad. It returns false if
+// the margin leaves nothing of the pad.
+func (dict *dictionary) padShape(pad *kicad.Pad, margin kicad.Size) (shapeRef, bool) {
+	w := pad.Size.Width + 2*margin.Width
+	h := pad.Size.Height + 2*margin.Height
+	if w <= 0 || h <= 0 {
+		return shapeRef{}, false
+	}
+
+	switch pad.Shape {
+	case "circle":
+		return dict.circle(w), true
+	case "rect":
+		return dict.rect(w, h), true
+	case "oval":
+		if w == h {
+			return dict.circle(w), true
+		}
+		return dict.oval(w, h), true
+	case "roundrect":
+		r := pad.RoundRectRatio*math.Min(pad.Size.Width, pad.Size.Height) + math.Min(margin.Width, margin.Height)
+		r = math.Min(r, math.Min(w, h)/2)
+		switch {
+		case pad.Chamfer != (kicad.PadChamfer{}) && pad.ChamferRatio > 0:
+			c := pad.ChamferRatio * math.Min(w, h)
+			return dict.contour(kicad.ChamferedRect(w, h, r, c, pad.Chamfer)), true
+		case r <= 0:
+			return dict.rect(w, h), true
+		default:
+			return dict.roundRect(w, h, r), true
+		}
+	case "trapezoid":
+		return dict.contour(kicad.TrapezoidCorners(w, h, pad.RectDelta)), true
+	case "custom":
+		return dict.custom(customFeatures(pad, margin)), true
+	}
+	return shapeRef{}, false
+}
+
+// customFeatures returns the features making up the shape of a custom
+// pad, which are its anchor pad and its primitives. Only the anchor pad is
+// enlarged by the margin.
+func customFeatures(pad *kicad.Pad, margin kicad.Size) []*node {
+	parent := newNode("")
+	addOutline := func(points []kicad.Position, width float64) {
+		if len(points) < 3 {
+			return
+		}
+		n := polygon(parent, "Outline", points, localXY)
+		lineDesc(n, width)
+	}
+	addPolyline := func(points []kicad.Position, width float64) {
+		if len(points) < 2 || width <= 0 {
+			return
+		}
+		n := polyline(parent, "Polyline", points, localXY)
+		lineDesc(n, width)
+	}
+
+	w := pad.Size.Width + 2*margin.Width
+	h := pad.Size.Height + 2*margin.Height
+	if pad.Options.Anchor == "rect" {
+		addOutline(kicad.RectCorners(w, h), 0)
+	} else {
+		addOutline(kicad.CirclePoints(kicad.Position{}, w/2), 0)
+	}
+
+	g := &pad.Primitives
+	lineWidth := func(width float64, stroke kicad.Stroke) float64 {
+		if w := stroke.LineWidth(width); w > 0 {
+			return w
+		}
+		return g.Width
+	}
+	for _, poly := range g.Polys {
+		addOutline(poly.Points.Polyline(), lineWidth(poly.Width, poly.Stroke))
+	}
+	for _, line := range g.Lines {
+		addPolyline([]kicad.Position{line.Start, line.End}, lineWidth(line.Width, line.Stroke))
+	}
+	for _, arc := range g.Arcs {
+		addPolyline(kicad.ArcPoints(arc.Start, arc.Mid, arc.End), lineWidth(arc.Width, arc.Stroke))
+	}
+	for _, curve := range g.Curves {
+		addPolyline(kicad.BezierPoints(curve.Points.Polyline()), lineWidth(curve.Width, curve.Stroke))
+	}
+	for _, rect := range g.Rects {
+		pts := kicad.RectPoints(rect.Start, rect.End)
+		width := lineWidth(rect.Width, rect.Stroke)
+		if rect.Filled() || width == 0 {
+			addOutline(pts, width)
+		} else {
+			addPolyline(append(pts, pts[0]), width)
+		}
+	}
+	for _, circle := range g.Circles {
+		r := kicad.Distance(circle.Center, circle.End)
+		width := lineWidth(circle.Width, circle.Stroke)
+		pts := kicad.CirclePoints(circle.Center, r)
+		if circle.Filled() || width == 0 {
+			addOutline(pts, width)
+		} else {
+			addPolyline(append(pts, pts[0]), width)
+		}
+	}
+	return parent.children
+}
+
+// polygon adds an element with the given name to the given element,
+// describing the closed polygon with the given vertices. xy converts the
+// vertices into attributes.
+func polygon(parent *node, name string, points []kicad.Position, xy func(x, y string, p kicad.Position) []attr) *node {
+	n := polyline(parent, name, points, xy)
+	n.add("PolyStepSegment", xy("x", "y", points[0])...)
+	return n
+}
+
+// polyline adds an element with the given name to the given element,
+// describing the open polyline through the given points.
+func polyline(parent *node, name string, points []kicad.Position, xy func(x, y string, p kicad.Position) []attr) *node {
+	n := parent.add(name)
+	n.add("PolyBegin", xy("x", "y", points[0])...)
+	for _, p := range points[1:] {
+		n.add("PolyStepSegment", xy("x", "y", p)...)
+	}
+	return n
+}
+
+// localXY returns the attributes giving the position of a point relative
+// to the center of a shape, with Y increasing upwards.
+func localXY(x, y string, p kicad.Position) []attr {
+	return []attr{length(x, p.X), length(y, -p.Y)}
+}
+
+func lineDesc(parent *node, width float64) {
+	parent.add("LineDesc", str("lineEnd", "ROUND"), length("lineWidth", width))
+}
@@ -0,0 +1,86 @@
+package ipc2581
+
+import (
+	"bytes"
+	"encoding/xml"
+	"math"
+	"strconv"
+)
+
+// node is an element of the XML document, built up in the order its
+// children must appear.
+type node struct {
+	name     string
+	attrs    []attr
+	children []*node
+}
+
+type attr struct {
+	name, value string
+}
+
+func newNode(name string, attrs ...attr) *node {
+	return &node{name: name, attrs: attrs}
+}
+
+// add appends a new child element to the node and returns it.
+func (n *node) add(name string, attrs ...attr) *node {
+	child := newNode(name, attrs...)
+	n.children = append(n.children, child)
+	return child
+}
+
+// set adds an attribute to the node.
+func (n *node) set(name, value string) *node {
+	n.attrs = append(n.attrs, attr{name, value})
+	return n
+}
+
+func (n *node) write(buf *bytes.Buffer, indent int) {
+	for i := 0; i < indent; i++ {
+		buf.WriteString("  ")
+	}
+	buf.WriteString("<" + n.name)
+	for _, a := range n.attrs {
+		buf.WriteString(" " + a.name + "=\"")
+		xml.EscapeText(buf, []byte(a.value))
+		buf.WriteString("\"")
+	}
+	if len(n.children) == 0 {
+		buf.WriteString("/>\n")
+		return
+	}
+	buf.WriteString(">\n")
+	for _, child := range n.children {
+		child.write(buf, indent+1)
+	}
+	for i := 0; i < indent; i++ {
+		buf.WriteString("  ")
+	}
+	buf.WriteString("</" + n.name + ">\n")
+}
+
+func str(name, value string) attr {
+	return attr{name, value}
+}
+
+func integer(name string, v int) attr {
+	return attr{name, strconv.Itoa(v)}
+}
+
+func boolean(name string, v bool) attr {
+	return attr{name, strconv.FormatBool(v)}
+}
+
+func length(name string, v float64) attr {
+	return attr{name, num(v)}
+}
+
+// num formats a length in millimeters, rounded to the nearest nanometer.
+func num(v float64) string {
+	v = math.Round(v*1e6) / 1e6
+	if v == 0 {
+		v = 0 // avoid "-0"
+	}
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
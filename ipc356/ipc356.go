@@ -0,0 +1,172 @@
+// Package ipc356 writes the nets of a KiCad board as an IPC-D-356A netlist,
+// which bare-board testers such as flying probes use to check that each
+// net is continuous and isolated from the others.
+//
+// Each pad and via of the board is a test point. Pads are end points of
+// their nets, while vias are marked as mid-points, as KiCad does.
+package ipc356
+
+import (
+	"math"
+	"slices"
+
+	"github.com/apparentlymart/go-kicad"
+)
+
+// SolderMask is the set of sides of a test point that are covered by
+// solder mask, as a bitmask in the same form as the solder mask access code
+// of an IPC-D-356A record.
+type SolderMask int
+
+const (
+	MaskUncovered     SolderMask = 0
+	MaskCoveredTop    SolderMask = 1
+	MaskCoveredBottom SolderMask = 2
+	MaskCoveredBoth              = MaskCoveredTop | MaskCoveredBottom
+)
+
+// TestPoint is a pad or via that a tester can probe.
+type TestPoint struct {
+	// Net is the name of the net the test point belongs to, or the empty
+	// string if it isn't connected.
+	Net string
+
+	// Reference is the reference designator of a pad's footprint, or
+	// "VIA" for a via. Pin is the number of a pad, and empty for a via.
+	Reference string
+	Pin       string
+
+	// MidPoint is set for test points that aren't end points of their
+	// nets, which are vias.
+	MidPoint bool
+
+	// Position is in board coordinates, in millimeters with Y increasing
+	// downwards.
+	Position kicad.Position
+
+	// Size is the size of a pad before it is rotated by Rotation, in
+	// degrees counterclockwise.
+	Size     kicad.Size
+	Rotation float64
+
+	// Drill is the diameter of the test point's hole, or zero if it has
+	// none. For an oval hole it is the smaller of its width and height.
+	Drill  float64
+	Plated bool
+
+	// Access is the copper layer the test point can be probed from,
+	// numbered from 1 at the top of the board, or 0 for a test point that
+	// passes through the whole board.
+	Access int
+
+	SolderMask SolderMask
+}
+
+// TestPoints returns the test points of the given board: first the pads of
+// each footprint that have copper, and then the vias.
+func TestPoints(pcb *kicad.PCB) []TestPoint {
+	copper := pcb.CopperLayers()
+
+	var ret []TestPoint
+	for i := range pcb.Footprints {
+		fp := &pcb.Footprints[i]
+		for j := range fp.Pads {
+			pad := &fp.Pads[j]
+			var layers []string
+			for _, layer := range copper {
+				if kicad.OnLayer(pad.Layers, layer) {
+					layers = append(layers, layer)
+				}
+			}
+			if len(layers) == 0 {
+				continue
+			}
+
+			size := pad.Size
+			if pad.Shape == "circle" {
+				size.Height = size.Width
+			}
+			tp := TestPoint{
+				Net:        pad.Net.Name,
+				Reference:  fp.Reference(),
+				Pin:        pad.Number,
+				Position:   fp.BoardPosition(pad.At.Position()),
+				Size:       size,
+				Rotation:   kicad.NormalizeDegrees(pad.At.Angle),
+				Access:     access(copper, layers),
+				SolderMask: solderMask(kicad.OnLayer(pad.Layers, "F.Mask"), kicad.OnLayer(pad.Layers, "B.Mask")),
+			}
+			if pad.HasDrill() {
+				tp.Drill = pad.Drill.Width
+				if h := pad.Drill.Height; pad.Drill.Oval && h > 0 {
+					tp.Drill = math.Min(tp.Drill, h)
+				}
+				tp.Plated = pad.Type != "np_thru_hole"
+			}
+			ret = append(ret, tp)
+		}
+	}
+
+	open := pcb.Setup.PlotParams.ViasOnMask
+	for _, via := range pcb.Vias {
+		layers := copper
+		if via.Type != "" && len(via.Layers) == 2 {
+			// Blind and buried vias give only their outermost layers.
+			top, bottom := slices.Index(copper, via.Layers[0]), slices.Index(copper, via.Layers[1])
+			if top > bottom {
+				top, bottom = bottom, top
+			}
+			if top >= 0 {
+				layers = copper[top : bottom+1]
+			}
+		}
+		ret = append(ret, TestPoint{
+			Net:        pcb.NetName(via.Net),
+			Reference:  "VIA",
+			MidPoint:   true,
+			Position:   via.At,
+			Size:       kicad.Size{Width: via.Size, Height: via.Size},
+			Drill:      via.Drill,
+			Plated:     true,
+			Access:     access(copper, layers),
+			SolderMask: solderMask(open, open),
+		})
+	}
+	return ret
+}
+
+// access returns the IPC-D-356 access code for a feature on the given
+// copper layers, which must be in the same order as copper.
+func access(copper, layers []string) int {
+	if len(copper) == 0 {
+		return 0
+	}
+	top := layers[0] == copper[0]
+	bottom := layers[len(layers)-1] == copper[len(copper)-1]
+	switch {
+	case top && bottom:
+		return 0
+	case top:
+		return 1
+	case bottom:
+		return len(copper)
+	default:
+		// A feature only on inner layers can't be probed, but is still
+		// given the number of its topmost layer.
+		return slices.Index(copper, layers[0]) + 1
+	}
+}
+
+// solderMask returns the solder mask covering a test point with the given
+// openings in the mask on the top and bottom of the board. Test points
+// start covered on both sides, and each opening uncovers one of them.
+func solderMask(openTop, openBottom bool) SolderMask {
+	mask := MaskCoveredBoth
+	if openTop {
+		mask &^= MaskCoveredTop
+	}
+	if openBottom {
+		mask &^= MaskCoveredBottom
+	}
+	return mask
+}
@@ -0,0 +1,136 @@
+package ipc356
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apparentlymart/go-kicad"
+	"github.com/apparentlymart/go-kicad/internal/testboard"
+	"github.com/davecgh/go-spew/spew"
+)
+
+const testBoardSrc = `(kicad_pcb (version 20240108) (generator pcbnew)
+  (layers
+    (0 "F.Cu" signal)
+    (1 "In1.Cu" signal)
+    (2 "In2.Cu" signal)
+    (31 "B.Cu" signal)
+  )
+  (setup
+    (aux_axis_origin 100 100)
+  )
+  (net 0 "")
+  (net 1 "GND")
+  (net 2 "/power/VBUS_FILTERED")
+  (footprint "Resistor_SMD:R_0603_1608Metric" (layer "F.Cu")
+    (at 110 90 90)
+    (property "Reference" "R1")
+    (pad "1" smd roundrect (at -0.8 0 90) (size 0.8 0.95) (layers "F.Cu" "F.Paste" "F.Mask") (net 1 "GND"))
+    (pad "2" smd roundrect (at 0.8 0 90) (size 0.8 0.95) (layers "F.Cu" "F.Paste" "F.Mask") (net 2 "/power/VBUS_FILTERED"))
+  )
+  (footprint "Connector:Conn_01x02" (layer "B.Cu")
+    (at 120 90 180)
+    (property "Reference" "J1")
+    (pad "1" thru_hole circle (at 0 0 180) (size 1.7 1.7) (drill 1) (layers "*.Cu" "*.Mask") (net 1 "GND"))
+    (pad "2" thru_hole oval (at 0 2.54 180) (size 1.7 2.5) (drill oval 1 1.5) (layers "*.Cu" "*.Mask") (net 3 "unconnected-(J1-Pad2)"))
+    (pad "" np_thru_hole circle (at 5 0 180) (size 3 3) (drill 3) (layers "*.Cu" "*.Mask"))
+    (pad "3" smd rect (at 0 -3 180) (size 1 2) (layers "B.Cu" "B.Mask"))
+  )
+  (via (at 115 95) (size 0.6) (drill 0.3) (layers "F.Cu" "B.Cu") (net 1))
+  (via blind (at 121 96) (size 0.45) (drill 0.2) (layers "In1.Cu" "F.Cu") (net 2))
+  (via buried (at 122 97) (size 0.45) (drill 0.2) (layers "In1.Cu" "In2.Cu") (net 2))
+)
+`
+
+func testBoard(t *testing.T) *kicad.PCB {
+	t.Helper()
+	pcb, err := kicad.ReadPCB(strings.NewReader(testBoardSrc))
+	if err != nil {
+		t.Fatalf("reading board: %s", err)
+	}
+	return pcb
+}
+
+func TestWrite(t *testing.T) {
+	var buf bytes.Buffer
+	err := Write(&buf, testBoard(t), &Options{
+		UseAuxOrigin: true,
+		Job:          "test.kicad_pcb",
+		CreationDate: time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := buf.String()
+	want := "C  IPC-D-356A netlist generated by go-kicad\n" +
+		"C  Timestamp: 2024-03-01T12:30:00+00:00\n" +
+		"P  JOB   test.kicad_pcb\n" +
+		"P  UNITS CUST 0\n" +
+		"P  DIM   N\n" +
+		"P  NNAME1     /power/VBUS_FILTERED\n" +
+		"327GND              R1    -1          A01X+003937Y+003622X0315Y0374R090 S2      \n" +
+		"327NNAME1           R1    -2          A01X+003937Y+004252X0315Y0374R090 S2      \n" +
+		"317GND              J1    -1    D0394PA00X+007874Y+003937X0669Y0669R180 S0      \n" +
+		"317N/C              J1    -2    D0394PA00X+007874Y+004937X0669Y0984R180 S0      \n" +
+		"367N/C              J1          D1181UA00X+005906Y+003937X1181Y1181R180 S0      \n" +
+		"327N/C              J1    -3          A04X+007874Y+002756X0394Y0787R180 S1      \n" +
+		"317GND              VIA        MD0118PA00X+005906Y+001969X0236Y0236R000 S3      \n" +
+		"317NNAME1           VIA        MD0079PA01X+008268Y+001575X0177Y0177R000 S3      \n" +
+		"317NNAME1           VIA        MD0079PA02X+008661Y+001181X0177Y0177R000 S3      \n" +
+		"999\n"
+	if got != want {
+		t.Errorf("incorrect result\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestTestPoints(t *testing.T) {
+	pcb := testBoard(t)
+	pcb.Setup.PlotParams.ViasOnMask = true
+	got := TestPoints(pcb)
+	if got, want := len(got), 9; got != want {
+		t.Fatalf("wrong number of test points %d; want %d", got, want)
+	}
+
+	want := TestPoint{
+		Net:        "/power/VBUS_FILTERED",
+		Reference:  "VIA",
+		MidPoint:   true,
+		Position:   kicad.Position{X: 121, Y: 96},
+		Size:       kicad.Size{Width: 0.45, Height: 0.45},
+		Drill:      0.2,
+		Plated:     true,
+		Access:     1,
+		SolderMask: MaskUncovered,
+	}
+	if !reflect.DeepEqual(got[7], want) {
+		t.Errorf("incorrect blind via\ngot:\n%s\nwant:\n%s", spew.Sdump(got[7]), spew.Sdump(want))
+	}
+	if got, want := got[3].Drill, 1.0; got != want {
+		t.Errorf("wrong drill for oval hole %g; want %g", got, want)
+	}
+}
+
+func TestTestPoints_padShapes(t *testing.T) {
+	pcb := testboard.Read(t)
+	var got []string
+	for _, tp := range TestPoints(pcb) {
+		if tp.Reference == "U1" {
+			got = append(got, fmt.Sprintf("%s %v %gx%g R%g", tp.Pin, tp.Position, tp.Size.Width, tp.Size.Height, tp.Rotation))
+		}
+	}
+	// The rotated roundrect pad keeps its own size and angle, and the
+	// custom pad is described by its anchor.
+	want := []string{
+		"1 {103 85} 1x0.5 R0",
+		"2 {105 85} 1x0.5 R45",
+		"3 {107 85} 1x1 R0",
+		"4 {105 87} 0.5x0.5 R0",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong test points\ngot:  %q\nwant: %q", got, want)
+	}
+}
@@ -0,0 +1,165 @@
+package ipc356
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/apparentlymart/go-kicad"
+	"github.com/apparentlymart/go-kicad/internal/fileio"
+)
+
+// Options customizes the files that Write produces. A nil *Options is
+// equivalent to a pointer to the zero value.
+type Options struct {
+	// UseAuxOrigin places the origin of the file at the board's auxiliary
+	// axis origin, rather than at the origin of the board document.
+	UseAuxOrigin bool
+
+	// Job names the board in the header of the file. If it is empty then
+	// the title from the board's title block is used.
+	Job string
+
+	// CreationDate is recorded in the header of the file. If it is zero
+	// then no date is recorded, so that the output depends only on the
+	// board.
+	CreationDate time.Time
+}
+
+// Record lengths in IPC-D-356 are in units of 0.0001 inch, and must fit in
+// the fixed number of digits of their columns.
+const (
+	unitsPerMM  = 10000 / 25.4
+	maxCoord    = 999999
+	maxSize     = 9999
+	maxNetName  = 14
+	unconnected = "N/C"
+)
+
+// Write writes the test points of the given board to the given writer as an
+// IPC-D-356A netlist.
+func Write(w io.Writer, pcb *kicad.PCB, opts *Options) error {
+	if opts == nil {
+		opts = &Options{}
+	}
+	var origin kicad.Position
+	if opts.UseAuxOrigin {
+		origin = pcb.Setup.AuxAxisOrigin
+	}
+	points := TestPoints(pcb)
+
+	var buf bytes.Buffer
+	buf.WriteString("C  IPC-D-356A netlist generated by go-kicad\n")
+	if !opts.CreationDate.IsZero() {
+		fmt.Fprintf(&buf, "C  Timestamp: %s\n", opts.CreationDate.Format("2006-01-02T15:04:05-07:00"))
+	}
+	job := opts.Job
+	if job == "" {
+		job = pcb.TitleBlock.Title
+	}
+	fmt.Fprintf(&buf, "P  JOB   %s\n", job)
+	buf.WriteString("P  UNITS CUST 0\n")
+	buf.WriteString("P  DIM   N\n")
+
+	// Net names that don't fit in their column are replaced by aliases,
+	// which are defined before the records that use them.
+	aliases := make(map[string]string)
+	for _, tp := range points {
+		name := netName(tp.Net)
+		if len(name) <= maxNetName || aliases[name] != "" {
+			continue
+		}
+		alias := fmt.Sprintf("NNAME%d", len(aliases)+1)
+		aliases[name] = alias
+		fmt.Fprintf(&buf, "P  %-10s %s\n", alias, name)
+	}
+
+	for _, tp := range points {
+		net := netName(tp.Net)
+		if alias, ok := aliases[net]; ok {
+			net = alias
+		}
+
+		code := 327
+		if tp.Drill > 0 {
+			code = 317
+			if !tp.Plated {
+				code = 367
+			}
+		}
+		sep := ' '
+		if tp.Pin != "" {
+			sep = '-'
+		}
+		mid := ' '
+		if tp.MidPoint {
+			mid = 'M'
+		}
+		fmt.Fprintf(&buf, "%03d%-14.14s   %-6.6s%c%-4.4s%c", code, net, tp.Reference, sep, tp.Pin, mid)
+
+		if tp.Drill > 0 {
+			plated := 'P'
+			if !tp.Plated {
+				plated = 'U'
+			}
+			fmt.Fprintf(&buf, "D%04d%c", units(tp.Drill, maxSize), plated)
+		} else {
+			buf.WriteString("      ")
+		}
+
+		// Positions have Y increasing upwards.
+		x := units(tp.Position.X-origin.X, maxCoord)
+		y := units(origin.Y-tp.Position.Y, maxCoord)
+		rot := int(math.Round(tp.Rotation)) % 360
+		fmt.Fprintf(&buf, "A%02dX%+07dY%+07dX%04dY%04dR%03d S%d      \n",
+			tp.Access, x, y,
+			units(tp.Size.Width, maxSize), units(tp.Size.Height, maxSize), rot,
+			tp.SolderMask,
+		)
+	}
+	buf.WriteString("999\n")
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// WriteFile writes the test points of the given board to the named file,
+// as Write does.
+func WriteFile(filename string, pcb *kicad.PCB, opts *Options) error {
+	return fileio.WriteFile(filename, func(w io.Writer) error {
+		return Write(w, pcb, opts)
+	})
+}
+
+// Filename returns the name KiCad gives to the IPC-D-356 netlist of a
+// board, given the base name of the board file without its extension.
+func Filename(base string) string {
+	return base + ".d356"
+}
+
+// netName returns the name of a net as written in an IPC-D-356 record,
+// which for unconnected test points is "N/C".
+func netName(name string) string {
+	// KiCad 6 and later give each unconnected pad a net of its own,
+	// named after the pad, but to a tester they are all unconnected.
+	if name == "" || strings.HasPrefix(name, "unconnected-(") {
+		return unconnected
+	}
+	return name
+}
+
+// units converts a length in millimeters to IPC-D-356 units, limited to the
+// given magnitude.
+func units(mm float64, limit int) int {
+	v := int(math.Round(mm * unitsPerMM))
+	if v > limit {
+		return limit
+	}
+	if v < -limit {
+		return -limit
+	}
+	return v
+}
@@ -0,0 +1,224 @@
+// Package legacy reads the line-based file formats used by KiCad 5 and
+// earlier for schematics and symbol libraries, converting them into the
+// same structures that the kicad package uses for the s-expression formats.
+//
+// Coordinates in the legacy formats are in mils, and are converted to
+// millimeters. Eight-digit hexadecimal timestamps, which the legacy formats
+// use to identify items, are converted to UUIDs in the same way as KiCad 6
+// does when it opens a legacy document.
+package legacy
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// lineReader reads a legacy file one line at a time, keeping track of the
+// line number for error messages.
+type lineReader struct {
+	s    *bufio.Scanner
+	line int
+}
+
+func newLineReader(r io.Reader) *lineReader {
+	s := bufio.NewScanner(r)
+	s.Buffer(nil, 1024*1024)
+	return &lineReader{s: s}
+}
+
+// Next returns the next line with any trailing carriage return removed, or
+// false at the end of the file.
+func (r *lineReader) Next() (string, bool) {
+	if !r.s.Scan() {
+		return "", false
+	}
+	r.line++
+	return strings.TrimRight(r.s.Text(), "\r"), true
+}
+
+// NextTokens returns the tokens of the next non-blank line.
+func (r *lineReader) NextTokens() (*tokens, bool) {
+	for {
+		line, ok := r.Next()
+		if !ok {
+			return nil, false
+		}
+		if strings.TrimSpace(line) != "" {
+			return r.Tokens(line), true
+		}
+	}
+}
+
+// Tokens splits the given line into tokens.
+func (r *lineReader) Tokens(line string) *tokens {
+	return &tokens{toks: splitTokens(line), line: r.line}
+}
+
+// Err returns any error encountered while reading.
+func (r *lineReader) Err() error {
+	return r.s.Err()
+}
+
+func (r *lineReader) Errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("line %d: %s", r.line, fmt.Sprintf(format, args...))
+}
+
+// splitTokens splits a line on whitespace, keeping double-quoted strings
+// together. Quoted tokens keep their quotes, so that callers can tell them
+// apart from bare tokens.
+func splitTokens(line string) []string {
+	var ret []string
+	i := 0
+	for i < len(line) {
+		if line[i] == ' ' || line[i] == '\t' {
+			i++
+			continue
+		}
+		start := i
+		if line[i] == '"' {
+			i++
+			for i < len(line) && line[i] != '"' {
+				if line[i] == '\\' {
+					i++
+				}
+				i++
+			}
+			i++
+			if i > len(line) {
+				i = len(line)
+			}
+		} else {
+			for i < len(line) && line[i] != ' ' && line[i] != '\t' {
+				i++
+			}
+		}
+		ret = append(ret, line[start:i])
+	}
+	return ret
+}
+
+// unquote removes the quotes from a quoted token, along with its escapes.
+// Tokens without quotes are returned unchanged.
+func unquote(tok string) string {
+	if len(tok) < 2 || tok[0] != '"' {
+		return tok
+	}
+	tok = tok[1 : len(tok)-1]
+	if !strings.Contains(tok, `\`) {
+		return tok
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(tok); i++ {
+		if tok[i] == '\\' && i+1 < len(tok) {
+			i++
+		}
+		b.WriteByte(tok[i])
+	}
+	return b.String()
+}
+
+// tokens is a cursor over the tokens of a line. Errors are sticky, so that
+// a series of values can be read before checking Err once.
+type tokens struct {
+	toks []string
+	pos  int
+	line int
+	err  error
+}
+
+// More returns true if there are tokens remaining.
+func (t *tokens) More() bool {
+	return t.pos < len(t.toks)
+}
+
+// Len returns the total number of tokens on the line.
+func (t *tokens) Len() int {
+	return len(t.toks)
+}
+
+// Peek returns the next token without consuming it, or the empty string if
+// there are no more tokens.
+func (t *tokens) Peek() string {
+	if !t.More() {
+		return ""
+	}
+	return t.toks[t.pos]
+}
+
+// Raw returns the next token as written, including any quotes.
+func (t *tokens) Raw() string {
+	if !t.More() {
+		t.fail("unexpected end of line")
+		return ""
+	}
+	t.pos++
+	return t.toks[t.pos-1]
+}
+
+// String returns the next token with any quotes removed.
+func (t *tokens) String() string {
+	return unquote(t.Raw())
+}
+
+// Int returns the next token as an integer.
+func (t *tokens) Int() int {
+	tok := t.Raw()
+	if t.err != nil {
+		return 0
+	}
+	v, err := strconv.Atoi(tok)
+	if err != nil {
+		t.fail("invalid integer %q", tok)
+	}
+	return v
+}
+
+// Float returns the next token as a floating point number.
+func (t *tokens) Float() float64 {
+	tok := t.Raw()
+	if t.err != nil {
+		return 0
+	}
+	v, err := strconv.ParseFloat(tok, 64)
+	if err != nil {
+		t.fail("invalid number %q", tok)
+	}
+	return v
+}
+
+// Mils returns the next token as a length in mils, converted to
+// millimeters.
+func (t *tokens) Mils() float64 {
+	return mils(t.Float())
+}
+
+// Optional returns the next token, or the given default if there are no
+// more tokens.
+func (t *tokens) Optional(def string) string {
+	if !t.More() {
+		return def
+	}
+	return t.String()
+}
+
+func (t *tokens) fail(format string, args ...interface{}) {
+	if t.err == nil {
+		t.err = fmt.Errorf("line %d: %s", t.line, fmt.Sprintf(format, args...))
+	}
+}
+
+// Err returns the first error encountered while reading the line.
+func (t *tokens) Err() error {
+	return t.err
+}
+
+// mils converts a length in mils to millimeters, rounded to KiCad's
+// nanometer resolution to avoid noise from the conversion.
+func mils(v float64) float64 {
+	return math.Round(v*25.4e3) / 1e6
+}
@@ -0,0 +1,551 @@
+package legacy
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	kicad "github.com/apparentlymart/go-kicad"
+)
+
+// schematicVersion is the s-expression format version given to converted
+// schematics, which is that of KiCad 6.
+const schematicVersion = 20211123
+
+// ReadSchematic reads a stream containing a single legacy .sch schematic
+// sheet and returns the equivalent Schematic.
+//
+// Legacy schematics don't embed the symbols they use, so the result has no
+// LibSymbols. Use AddLibSymbols to add them from the project's cache
+// library, or LoadSchematicHierarchy to do so automatically.
+//
+// Reference designators for sheets that are used more than once are given
+// in the SymbolInstances of the sheet containing the symbols, which
+// kicad.SchematicHierarchy.SymbolReference takes into account.
+func ReadSchematic(r io.Reader) (*kicad.Schematic, error) {
+	lr := newLineReader(r)
+	line, ok := lr.Next()
+	if !ok || !strings.HasPrefix(line, "EESchema Schematic File") {
+		if err := lr.Err(); err != nil {
+			return nil, err
+		}
+		return nil, lr.Errorf("not a KiCad schematic")
+	}
+
+	sch := &kicad.Schematic{Version: schematicVersion}
+	for {
+		toks, ok := lr.NextTokens()
+		if !ok {
+			break
+		}
+
+		var err error
+		switch toks.Raw() {
+		case "$Descr":
+			err = readDescr(lr, toks, sch)
+		case "$Comp":
+			err = readComponent(lr, sch)
+		case "$Sheet":
+			err = readSheet(lr, sch)
+		case "$Bitmap":
+			err = skipUntil(lr, "$EndBitmap")
+		case "Wire":
+			err = readWire(lr, toks, sch)
+		case "Entry":
+			err = readEntry(lr, sch)
+		case "Connection":
+			toks.Raw() // ~
+			at := kicad.Position{X: toks.Mils(), Y: toks.Mils()}
+			sch.Junctions = append(sch.Junctions, kicad.Junction{At: at})
+			err = toks.Err()
+		case "NoConn":
+			toks.Raw() // ~
+			at := kicad.Position{X: toks.Mils(), Y: toks.Mils()}
+			sch.NoConnects = append(sch.NoConnects, kicad.NoConnect{At: at})
+			err = toks.Err()
+		case "Text":
+			err = readText(lr, toks, sch)
+		case "$EndSCHEMATC":
+			return sch, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := lr.Err(); err != nil {
+		return nil, err
+	}
+	return sch, nil
+}
+
+// ReadSchematicFile is a convenience wrapper around ReadSchematic that takes
+// a filename and opens the given file for reading before calling
+// ReadSchematic.
+func ReadSchematicFile(filename string) (*kicad.Schematic, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ReadSchematic(f)
+}
+
+// LoadSchematicHierarchy reads the legacy root sheet in the given file and
+// then all of the sheets below it, as kicad.LoadSchematicHierarchy does for
+// the s-expression format.
+//
+// If the project's cache library, named after the root sheet with a
+// "-cache.lib" suffix, is alongside the root sheet then the symbols it
+// contains are added to each sheet using AddLibSymbols.
+func LoadSchematicHierarchy(filename string) (*kicad.SchematicHierarchy, error) {
+	cacheFilename := strings.TrimSuffix(filename, ".sch") + "-cache.lib"
+	cache, err := ReadSymbolLibraryFile(cacheFilename)
+	if errors.Is(err, fs.ErrNotExist) {
+		cache = nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	return kicad.LoadSchematicHierarchyFunc(filename, func(filename string) (*kicad.Schematic, error) {
+		sch, err := ReadSchematicFile(filename)
+		if err == nil && cache != nil {
+			AddLibSymbols(sch, cache)
+		}
+		return sch, err
+	})
+}
+
+// AddLibSymbols adds the symbols used by the given schematic to its
+// LibSymbols, taking them from the given cache library.
+//
+// Cache libraries name each symbol after its library ID with the colon
+// replaced by an underscore, as in "Device_R" for "Device:R". The added
+// symbols are named by their library IDs, as in an s-expression schematic.
+// Symbols that are not in the cache library are skipped.
+func AddLibSymbols(sch *kicad.Schematic, cache *kicad.SymbolLibrary) {
+	for _, inst := range sch.Symbols {
+		if sch.LibSymbol(&inst) != nil {
+			continue
+		}
+		cacheName := strings.ReplaceAll(inst.LibID, ":", "_")
+		sym, err := cache.Resolve(cacheName)
+		if err != nil {
+			continue
+		}
+
+		oldPrefix := cacheName + "_"
+		newPrefix := inst.LibID
+		if i := strings.LastIndex(newPrefix, ":"); i >= 0 {
+			newPrefix = newPrefix[i+1:]
+		}
+		newPrefix += "_"
+		for i := range sym.Units {
+			sym.Units[i].Name = newPrefix + strings.TrimPrefix(sym.Units[i].Name, oldPrefix)
+		}
+		sym.Name = inst.LibID
+		sch.LibSymbols.Symbols = append(sch.LibSymbols.Symbols, *sym)
+	}
+}
+
+func skipUntil(lr *lineReader, end string) error {
+	for {
+		line, ok := lr.Next()
+		if !ok {
+			return lr.Errorf("missing %s", end)
+		}
+		if strings.TrimSpace(line) == end {
+			return nil
+		}
+	}
+}
+
+// readDescr reads the page description and title block, like:
+//
+//	$Descr A4 11693 8268
+//	encoding utf-8
+//	Sheet 1 1
+//	Title "Amplifier"
+//	Date "2020-01-01"
+//	Rev "1"
+//	Comp ""
+//	Comment1 ""
+//	$EndDescr
+func readDescr(lr *lineReader, toks *tokens, sch *kicad.Schematic) error {
+	sch.Paper.Name = toks.String()
+	width := toks.Mils()
+	height := toks.Mils()
+	sch.Paper.Portrait = toks.Optional("") == "portrait"
+	if err := toks.Err(); err != nil {
+		return err
+	}
+	if sch.Paper.Name == "User" {
+		sch.Paper.Width = width
+		sch.Paper.Height = height
+	}
+
+	tb := &sch.TitleBlock
+	for {
+		toks, ok := lr.NextTokens()
+		if !ok {
+			return lr.Errorf("missing $EndDescr")
+		}
+
+		key := toks.Raw()
+		switch key {
+		case "$EndDescr":
+			return nil
+		case "Title":
+			tb.Title = toks.String()
+		case "Date":
+			tb.Date = toks.String()
+		case "Rev":
+			tb.Revision = toks.String()
+		case "Comp":
+			tb.Company = toks.String()
+		default:
+			// KiCad 5 has Comment1 to Comment9, and omits the later ones
+			// from files written by earlier versions.
+			if len(key) == len("Comment1") && strings.HasPrefix(key, "Comment") && key[7] >= '1' && key[7] <= '9' {
+				if text := toks.String(); text != "" {
+					tb.SetComment(int(key[7]-'0'), text)
+				}
+			}
+		}
+		if err := toks.Err(); err != nil {
+			return err
+		}
+	}
+}
+
+// readComponent reads a symbol instance.
+func readComponent(lr *lineReader, sch *kicad.Schematic) error {
+	var sym kicad.SchSymbol
+	var ref string
+	for {
+		toks, ok := lr.NextTokens()
+		if !ok {
+			return lr.Errorf("missing $EndComp")
+		}
+
+		switch toks.Peek() {
+		case "$EndComp":
+			// KiCad 5 uses the reference from the L line in preference
+			// to field 0 when they differ.
+			for i := range sym.Properties {
+				if sym.Properties[i].Name == "Reference" {
+					sym.Properties[i].Value = ref
+				}
+			}
+			sch.Symbols = append(sch.Symbols, sym)
+			return nil
+		case "L":
+			toks.Raw()
+			sym.LibID = toks.String()
+			ref = toks.String()
+		case "U":
+			toks.Raw()
+			sym.Unit = toks.Int()
+			sym.BodyStyle = toks.Int()
+			sym.UUID = kicad.LegacyTimestampUUID(toks.String())
+		case "P":
+			toks.Raw()
+			sym.At.X = toks.Mils()
+			sym.At.Y = toks.Mils()
+		case "AR":
+			toks.Raw()
+			inst := kicad.SchInstancePath{}
+			for toks.More() {
+				key, value, _ := strings.Cut(toks.Raw(), "=")
+				value = unquote(value)
+				switch key {
+				case "Path":
+					inst.Path = kicad.LegacyTimestampPath(value)
+				case "Ref":
+					inst.Reference = value
+				case "Part":
+					inst.Unit, _ = strconv.Atoi(value)
+				}
+			}
+			sch.SymbolInstances.Paths = append(sch.SymbolInstances.Paths, inst)
+		case "F":
+			toks.Raw()
+			prop, err := readComponentField(toks)
+			if err != nil {
+				return err
+			}
+			sym.Properties = append(sym.Properties, prop)
+		default:
+			// The unit and position are repeated on a line of three
+			// values, followed by the orientation matrix on a line of
+			// four values.
+			if toks.Len() == 4 {
+				var m [4]int
+				for i := range m {
+					m[i] = toks.Int()
+				}
+				sym.At.Angle, sym.Mirror = orientation(m)
+			}
+		}
+		if err := toks.Err(); err != nil {
+			return err
+		}
+	}
+}
+
+// readComponentField reads a field of a symbol instance, like:
+//
+//	F 0 "R1" H 5070 3046 50  0000 L CNN
+func readComponentField(toks *tokens) (kicad.SchProperty, error) {
+	id := toks.Int()
+	text := toks.String()
+	orient := toks.String()
+	x := toks.Mils()
+	y := toks.Mils()
+	size := toks.Mils()
+	flags := toks.String()
+	hjust := toks.Optional("C")
+	style := toks.Optional("CNN")
+
+	var name string
+	if id < len(mandatoryFields) {
+		name = mandatoryFields[id]
+	} else {
+		name = toks.Optional("Field" + strconv.Itoa(id))
+	}
+
+	prop := kicad.SchProperty{
+		Name:    name,
+		Value:   text,
+		ID:      &id,
+		At:      kicad.PositionAngle{X: x, Y: y, Angle: orientAngle(orient)},
+		Effects: textEffects(size, hjust, style),
+	}
+	// The flags are a hexadecimal number whose lowest bit hides the field.
+	if v, err := strconv.ParseUint(flags, 16, 32); err == nil {
+		prop.Effects.Hide = v&1 != 0
+	}
+	return prop, toks.Err()
+}
+
+// orientation converts the orientation matrix of a legacy symbol instance
+// into an angle and mirror axis.
+//
+// The matrix maps symbol coordinates, where Y increases upwards, to
+// schematic coordinates, where Y increases downwards. A mirror is applied
+// after the rotation. Like KiCad, this never chooses a mirror about the X
+// axis with a rotation of 180 degrees, preferring the equivalent mirror
+// about the Y axis.
+func orientation(m [4]int) (angle float64, mirror string) {
+	for _, mirror := range []string{"", "x", "y"} {
+		for _, angle := range []float64{0, 90, 180, 270} {
+			if mirror == "x" && angle == 180 {
+				continue
+			}
+			s, c := math.Sincos(angle * math.Pi / 180)
+			sin, cos := int(math.Round(s)), int(math.Round(c))
+			want := [4]int{cos, -sin, -sin, -cos}
+			switch mirror {
+			case "x":
+				want[2], want[3] = -want[2], -want[3]
+			case "y":
+				want[0], want[1] = -want[0], -want[1]
+			}
+			if m == want {
+				return angle, mirror
+			}
+		}
+	}
+	return 0, ""
+}
+
+// readSheet reads a hierarchical sheet.
+func readSheet(lr *lineReader, sch *kicad.Schematic) error {
+	var sheet kicad.Sheet
+	for {
+		toks, ok := lr.NextTokens()
+		if !ok {
+			return lr.Errorf("missing $EndSheet")
+		}
+
+		kw := toks.Raw()
+		switch {
+		case kw == "$EndSheet":
+			sch.Sheets = append(sch.Sheets, sheet)
+			return nil
+		case kw == "S":
+			sheet.At = kicad.Position{X: toks.Mils(), Y: toks.Mils()}
+			sheet.Size = kicad.Size{Width: toks.Mils(), Height: toks.Mils()}
+		case kw == "U":
+			sheet.UUID = kicad.LegacyTimestampUUID(toks.String())
+		case kw == "F0" || kw == "F1":
+			id := int(kw[1] - '0')
+			text := toks.String()
+			size := toks.Mils()
+			prop := kicad.SchProperty{
+				Name:  "Sheetname",
+				Value: text,
+				ID:    &id,
+				At:    kicad.PositionAngle{X: sheet.At.X, Y: sheet.At.Y},
+			}
+			style := "BNN"
+			if id == 1 {
+				prop.Name = "Sheetfile"
+				prop.At.Y += sheet.Size.Height
+				style = "TNN"
+			}
+			prop.Effects = textEffects(size, "L", style)
+			sheet.Properties = append(sheet.Properties, prop)
+		case strings.HasPrefix(kw, "F"):
+			pin := kicad.SheetPin{Name: toks.String()}
+			pin.Type = labelShapes[toks.String()]
+			side := toks.String()
+			pin.At = kicad.PositionAngle{X: toks.Mils(), Y: toks.Mils(), Angle: sheetPinAngles[side]}
+			size := toks.Mils()
+			hjust := "L"
+			if side == "R" || side == "B" {
+				hjust = "R"
+			}
+			pin.Effects = textEffects(size, hjust, "CNN")
+			sheet.Pins = append(sheet.Pins, pin)
+		}
+		if err := toks.Err(); err != nil {
+			return err
+		}
+	}
+}
+
+var sheetPinAngles = map[string]float64{
+	"R": 0,
+	"T": 90,
+	"L": 180,
+	"B": 270,
+}
+
+var labelShapes = map[string]string{
+	"I":      "input",
+	"O":      "output",
+	"B":      "bidirectional",
+	"T":      "tri_state",
+	"U":      "passive",
+	"Input":  "input",
+	"Output": "output",
+	"BiDi":   "bidirectional",
+	"3State": "tri_state",
+	"UnSpc":  "passive",
+}
+
+// readWire reads a wire, bus or graphic line, like:
+//
+//	Wire Wire Line
+//		5000 3150 5000 3500
+func readWire(lr *lineReader, toks *tokens, sch *kicad.Schematic) error {
+	kind := toks.String()
+	pts, err := readSegment(lr)
+	if err != nil {
+		return err
+	}
+
+	switch kind {
+	case "Wire":
+		sch.Wires = append(sch.Wires, kicad.Wire{Points: pts, Stroke: kicad.Stroke{Type: "default"}})
+	case "Bus":
+		sch.Buses = append(sch.Buses, kicad.Wire{Points: pts, Stroke: kicad.Stroke{Type: "default"}})
+	case "Notes":
+		sch.Polylines = append(sch.Polylines, kicad.SchPolyline{
+			Points: pts,
+			Stroke: kicad.Stroke{Type: "dash"},
+			Fill:   kicad.SchFill{Type: "none"},
+		})
+	}
+	return nil
+}
+
+// readEntry reads a bus entry, whose second line is the same as a wire's.
+func readEntry(lr *lineReader, sch *kicad.Schematic) error {
+	pts, err := readSegment(lr)
+	if err != nil {
+		return err
+	}
+	xy := pts.Polyline()
+	start, end := xy[0], xy[1]
+	sch.BusEntries = append(sch.BusEntries, kicad.BusEntry{
+		At:     start,
+		Size:   kicad.Size{Width: roundNM(end.X - start.X), Height: roundNM(end.Y - start.Y)},
+		Stroke: kicad.Stroke{Type: "default"},
+	})
+	return nil
+}
+
+func readSegment(lr *lineReader) (kicad.Points, error) {
+	toks, ok := lr.NextTokens()
+	if !ok {
+		return kicad.Points{}, lr.Errorf("missing line coordinates")
+	}
+	pts := kicad.NewPoints(
+		kicad.Position{X: toks.Mils(), Y: toks.Mils()},
+		kicad.Position{X: toks.Mils(), Y: toks.Mils()},
+	)
+	return pts, toks.Err()
+}
+
+// readText reads a text item or label, whose text is on the following
+// line, like:
+//
+//	Text GLabel 4000 3000 0    50   Input ~ 0
+//	VBUS
+func readText(lr *lineReader, toks *tokens, sch *kicad.Schematic) error {
+	kind := toks.String()
+	at := kicad.PositionAngle{X: toks.Mils(), Y: toks.Mils()}
+	spin := toks.Int()
+	size := toks.Mils()
+	var shape string
+	if kind == "GLabel" || kind == "HLabel" {
+		shape = labelShapes[toks.String()]
+	}
+	italic := toks.Optional("~") == "Italic"
+	thickness := toks.Optional("0")
+	if err := toks.Err(); err != nil {
+		return err
+	}
+
+	text, ok := lr.Next()
+	if !ok {
+		return lr.Errorf("missing text")
+	}
+	text = strings.ReplaceAll(text, `\n`, "\n")
+
+	// The spin style gives the direction of the text from its position.
+	// Global and hierarchical labels are justified the other way around
+	// from text and local labels with the same spin style.
+	at.Angle = [4]float64{0, 90, 180, 270}[spin&3]
+	if kind == "GLabel" || kind == "HLabel" {
+		at.Angle = [4]float64{180, 270, 0, 90}[spin&3]
+	}
+	effects := textEffects(size, "L", "BNN")
+	if at.Angle == 180 || at.Angle == 270 {
+		effects.Justify.Left = false
+		effects.Justify.Right = true
+	}
+	if kind == "GLabel" || kind == "HLabel" {
+		effects.Justify.Bottom = false
+	}
+	effects.Font.Italic = italic
+	effects.Font.Bold = thickness != "0"
+
+	switch kind {
+	case "Notes":
+		sch.Texts = append(sch.Texts, kicad.SchText{Text: text, At: at, Effects: effects})
+	case "Label":
+		sch.Labels = append(sch.Labels, kicad.Label{Text: text, At: at, Effects: effects})
+	case "GLabel":
+		sch.GlobalLabels = append(sch.GlobalLabels, kicad.Label{Text: text, Shape: shape, At: at, Effects: effects})
+	case "HLabel":
+		sch.HierLabels = append(sch.HierLabels, kicad.Label{Text: text, Shape: shape, At: at, Effects: effects})
+	}
+	return nil
+}
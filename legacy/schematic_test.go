@@ -0,0 +1,265 @@
+package legacy
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	kicad "github.com/apparentlymart/go-kicad"
+)
+
+const testRootSch = `EESchema Schematic File Version 4
+EELAYER 30 0
+EELAYER END
+$Descr User 10000 8000 portrait
+encoding utf-8
+Sheet 1 3
+Title "Test"
+Date "2020-01-01"
+Rev "B"
+Comp "Example \"Co\""
+Comment1 "First"
+Comment2 ""
+$EndDescr
+$Comp
+L Device:R R1
+U 1 1 5C8B1234
+P 5000 3000
+F 0 "R1" H 5070 3046 50  0000 L CNN
+F 1 "10k" H 5070 2955 50  0000 L CNN
+F 2 "Resistor_SMD:R_0603" V 4930 3000 50  0001 C CNN
+F 3 "~" H 5000 3000 50  0001 C CNN
+F 4 "Yageo" H 5000 3000 50  0001 C CNN "Manufacturer"
+	1    5000 3000
+	0    -1   -1   0
+$EndComp
+Wire Wire Line
+	5000 3150 5000 3500
+Wire Bus Line
+	4000 2000 4000 4000
+Wire Notes Line
+	1000 1000 2000 1000
+Entry Wire Line
+	4000 3000 4100 3100
+Connection ~ 5000 3500
+NoConn ~ 6000 3000
+Text Notes 1000 900 0    50   ~ 0
+Line one\nLine two
+Text Label 5000 3500 0    50   ~ 0
+SDA
+Text GLabel 4000 3000 0    50   Input ~ 0
+VBUS
+Text HLabel 4000 3500 2    50   BiDi Italic 10
+SCL
+$Sheet
+S 6000 2000 1000 500 
+U 5C8B5678
+F0 "Left" 50
+F1 "channel.sch" 50
+F2 "IN" I L 6000 2100 50 
+$EndSheet
+$Sheet
+S 6000 3000 1000 500 
+U 5C8B9ABC
+F0 "Right" 50
+F1 "channel.sch" 50
+$EndSheet
+$EndSCHEMATC
+`
+
+const testChannelSch = `EESchema Schematic File Version 4
+$Descr A4 11693 8268
+$EndDescr
+$Comp
+L Device:R R?
+U 1 1 5C8BDEF0
+AR Path="/5C8B5678/5C8BDEF0" Ref="R2"  Part="1" 
+AR Path="/5C8B9ABC/5C8BDEF0" Ref="R3"  Part="1" 
+P 1000 1000
+F 0 "R2" H 1070 1046 50  0000 L CNN
+F 1 "1k" H 1070 955 50  0000 L CNN
+	1    1000 1000
+	1    0    0    -1  
+$EndComp
+$EndSCHEMATC
+`
+
+const testCacheLib = `EESchema-LIBRARY Version 2.4
+#encoding utf-8
+#
+# Device_R
+#
+DEF Device_R R 0 0 N Y 1 F N
+F0 "R" 80 0 50 V V C CNN
+F1 "Device_R" 0 0 50 V V C CNN
+DRAW
+S -40 -100 40 100 0 1 10 N
+X ~ 1 0 150 50 D 50 50 1 1 P
+ENDDRAW
+ENDDEF
+#
+#End Library
+`
+
+func TestReadSchematic(t *testing.T) {
+	sch, err := ReadSchematic(strings.NewReader(testRootSch))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	wantPaper := kicad.PaperSize{Name: "User", Width: 254, Height: 203.2, Portrait: true}
+	if sch.Paper != wantPaper {
+		t.Errorf("wrong paper %#v; want %#v", sch.Paper, wantPaper)
+	}
+
+	wantTitle := kicad.TitleBlock{
+		Title:    "Test",
+		Date:     "2020-01-01",
+		Revision: "B",
+		Company:  `Example "Co"`,
+		Comments: []kicad.TitleBlockComment{{Number: 1, Text: "First"}},
+	}
+	if !reflect.DeepEqual(sch.TitleBlock, wantTitle) {
+		t.Errorf("wrong title block %#v; want %#v", sch.TitleBlock, wantTitle)
+	}
+
+	if got, want := len(sch.Symbols), 1; got != want {
+		t.Fatalf("wrong number of symbols %d; want %d", got, want)
+	}
+	sym := &sch.Symbols[0]
+	if got, want := sym.LibID, "Device:R"; got != want {
+		t.Errorf("wrong lib ID %q; want %q", got, want)
+	}
+	if got, want := sym.UUID, "00000000-0000-0000-0000-00005c8b1234"; got != want {
+		t.Errorf("wrong UUID %q; want %q", got, want)
+	}
+	if got, want := sym.At, (kicad.PositionAngle{X: 127, Y: 76.2, Angle: 90}); got != want {
+		t.Errorf("wrong position %#v; want %#v", got, want)
+	}
+	props := make(map[string]string)
+	for _, prop := range sym.Properties {
+		props[prop.Name] = prop.Value
+	}
+	wantProps := map[string]string{
+		"Reference":    "R1",
+		"Value":        "10k",
+		"Footprint":    "Resistor_SMD:R_0603",
+		"Datasheet":    "~",
+		"Manufacturer": "Yageo",
+	}
+	if !reflect.DeepEqual(props, wantProps) {
+		t.Errorf("wrong properties %#v; want %#v", props, wantProps)
+	}
+	if !sym.Properties[2].Effects.Hide || sym.Properties[0].Effects.Hide {
+		t.Errorf("wrong field visibility")
+	}
+
+	if got, want := len(sch.Wires), 1; got != want {
+		t.Errorf("wrong number of wires %d; want %d", got, want)
+	}
+	if got, want := len(sch.Buses), 1; got != want {
+		t.Errorf("wrong number of buses %d; want %d", got, want)
+	}
+	if got, want := sch.Polylines[0].Stroke.Type, "dash"; got != want {
+		t.Errorf("wrong notes line stroke %q; want %q", got, want)
+	}
+	if got, want := sch.BusEntries[0].Size, (kicad.Size{Width: 2.54, Height: 2.54}); got != want {
+		t.Errorf("wrong bus entry size %#v; want %#v", got, want)
+	}
+	if len(sch.Junctions) != 1 || len(sch.NoConnects) != 1 {
+		t.Errorf("wrong junctions or no-connects")
+	}
+	if got, want := sch.Texts[0].Text, "Line one\nLine two"; got != want {
+		t.Errorf("wrong text %q; want %q", got, want)
+	}
+	if got, want := sch.Labels[0].Text, "SDA"; got != want {
+		t.Errorf("wrong label %q; want %q", got, want)
+	}
+	glabel := sch.GlobalLabels[0]
+	if glabel.Text != "VBUS" || glabel.Shape != "input" || glabel.At.Angle != 180 {
+		t.Errorf("wrong global label %#v", glabel)
+	}
+	hlabel := sch.HierLabels[0]
+	if hlabel.Shape != "bidirectional" || hlabel.At.Angle != 0 || !hlabel.Effects.Font.Italic || !hlabel.Effects.Font.Bold {
+		t.Errorf("wrong hierarchical label %#v", hlabel)
+	}
+
+	sheet := &sch.Sheets[0]
+	if sheet.Name() != "Left" || sheet.File() != "channel.sch" {
+		t.Errorf("wrong sheet name %q or file %q", sheet.Name(), sheet.File())
+	}
+	wantPin := kicad.SheetPin{
+		Name:    "IN",
+		Type:    "input",
+		At:      kicad.PositionAngle{X: 152.4, Y: 53.34, Angle: 180},
+		Effects: textEffects(1.27, "L", "CNN"),
+	}
+	if !reflect.DeepEqual(sheet.Pins, []kicad.SheetPin{wantPin}) {
+		t.Errorf("wrong sheet pins %#v", sheet.Pins)
+	}
+}
+
+func TestOrientation(t *testing.T) {
+	tests := []struct {
+		Matrix     [4]int
+		WantAngle  float64
+		WantMirror string
+	}{
+		{[4]int{1, 0, 0, -1}, 0, ""},
+		{[4]int{0, -1, -1, 0}, 90, ""},
+		{[4]int{-1, 0, 0, 1}, 180, ""},
+		{[4]int{0, 1, 1, 0}, 270, ""},
+		{[4]int{1, 0, 0, 1}, 0, "x"},
+		{[4]int{-1, 0, 0, -1}, 0, "y"},
+		{[4]int{0, -1, 1, 0}, 90, "x"},
+	}
+	for _, test := range tests {
+		angle, mirror := orientation(test.Matrix)
+		if angle != test.WantAngle || mirror != test.WantMirror {
+			t.Errorf("orientation(%v) = %v, %q; want %v, %q", test.Matrix, angle, mirror, test.WantAngle, test.WantMirror)
+		}
+	}
+}
+
+func TestLoadSchematicHierarchy(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"test.sch":       testRootSch,
+		"channel.sch":    testChannelSch,
+		"test-cache.lib": testCacheLib,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	h, err := LoadSchematicHierarchy(filepath.Join(dir, "test.sch"))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got, want := len(h.Files), 2; got != want {
+		t.Errorf("wrong number of files %d; want %d", got, want)
+	}
+
+	var refs []string
+	for _, hs := range h.Sheets {
+		for i := range hs.Schematic.Symbols {
+			ref, _ := h.SymbolReference(hs, &hs.Schematic.Symbols[i])
+			refs = append(refs, hs.Name+":"+ref)
+		}
+	}
+	if got, want := refs, []string{":R1", "Left:R2", "Right:R3"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong references %#v; want %#v", got, want)
+	}
+
+	lib := h.Root.LibSymbol(&h.Root.Symbols[0])
+	if lib == nil {
+		t.Fatalf("no library symbol for R1")
+	}
+	if got, want := lib.Units[0].Name, "R_0_1"; got != want {
+		t.Errorf("wrong unit name %q; want %q", got, want)
+	}
+}
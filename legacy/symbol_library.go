@@ -0,0 +1,638 @@
+package legacy
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	kicad "github.com/apparentlymart/go-kicad"
+)
+
+// symbolLibraryVersion is the s-expression format version given to
+// converted symbol libraries, which is that of KiCad 6.
+const symbolLibraryVersion = 20211014
+
+// mandatoryFields are the names of the fields that legacy documents
+// identify only by number.
+var mandatoryFields = []string{"Reference", "Value", "Footprint", "Datasheet"}
+
+// ReadSymbolLibrary reads a stream containing a legacy .lib symbol library
+// and returns the equivalent SymbolLibrary.
+//
+// Aliases become symbols that extend the symbol they are an alias of, as in
+// KiCad 6. Use ReadDocLibrary and ApplyDocs to add the descriptions and
+// keywords from the accompanying .dcm file.
+func ReadSymbolLibrary(r io.Reader) (*kicad.SymbolLibrary, error) {
+	lr := newLineReader(r)
+	line, ok := lr.Next()
+	if !ok || !strings.HasPrefix(line, "EESchema-LIBRARY") {
+		if err := lr.Err(); err != nil {
+			return nil, err
+		}
+		return nil, lr.Errorf("not a KiCad symbol library")
+	}
+
+	lib := &kicad.SymbolLibrary{Version: symbolLibraryVersion}
+	for {
+		toks, ok := lr.NextTokens()
+		if !ok {
+			break
+		}
+		switch toks.Peek() {
+		case "DEF":
+			syms, err := readSymbol(lr, toks)
+			if err != nil {
+				return nil, err
+			}
+			lib.Symbols = append(lib.Symbols, syms...)
+		}
+	}
+	return lib, lr.Err()
+}
+
+// ReadSymbolLibraryFile is a convenience wrapper around ReadSymbolLibrary
+// that takes a filename and opens the given file for reading before calling
+// ReadSymbolLibrary.
+//
+// If there is a .dcm file with the same name alongside the library then its
+// documentation is applied to the returned library.
+func ReadSymbolLibraryFile(filename string) (*kicad.SymbolLibrary, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	lib, err := ReadSymbolLibrary(f)
+	if err != nil {
+		return nil, err
+	}
+
+	dcmFilename := strings.TrimSuffix(filename, ".lib") + ".dcm"
+	df, err := os.Open(dcmFilename)
+	if errors.Is(err, fs.ErrNotExist) {
+		return lib, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer df.Close()
+
+	docs, err := ReadDocLibrary(df)
+	if err != nil {
+		return nil, err
+	}
+	ApplyDocs(lib, docs)
+	return lib, nil
+}
+
+func readSymbol(lr *lineReader, def *tokens) ([]kicad.Symbol, error) {
+	def.Raw() // DEF
+	name := strings.TrimPrefix(def.String(), "~")
+	def.Raw() // reference prefix, which is repeated in field 0
+	def.Int() // unused
+	textOffset := def.Mils()
+	drawNums := def.String()
+	drawNames := def.String()
+	def.Int() // unit count, which is implied by the draw items
+	def.Raw() // whether units are interchangeable
+	option := def.Optional("N")
+	if err := def.Err(); err != nil {
+		return nil, err
+	}
+
+	sym := kicad.Symbol{
+		Name:       name,
+		Power:      option == "P",
+		PinNumbers: &kicad.PinNumbers{Hide: drawNums == "N"},
+		PinNames:   &kicad.PinNames{Offset: &textOffset, Hide: drawNames == "N"},
+	}
+	var aliases []string
+	var fpFilters []string
+	units := make(map[[2]int]*kicad.SymbolUnit)
+
+	for {
+		toks, ok := lr.NextTokens()
+		if !ok {
+			return nil, lr.Errorf("missing ENDDEF for symbol %q", name)
+		}
+		kw := toks.Peek()
+		switch {
+		case kw == "ENDDEF":
+			sym.Units = sortedUnits(units)
+			if len(fpFilters) > 0 {
+				sym.Properties = append(sym.Properties, hiddenProperty(&sym, "ki_fp_filters", strings.Join(fpFilters, " ")))
+			}
+			ret := []kicad.Symbol{sym}
+			for _, alias := range aliases {
+				ret = append(ret, aliasSymbol(&sym, alias))
+			}
+			return ret, nil
+		case kw == "ALIAS":
+			toks.Raw()
+			for toks.More() {
+				aliases = append(aliases, toks.String())
+			}
+		case kw == "$FPLIST":
+			for {
+				line, ok := lr.Next()
+				if !ok {
+					return nil, lr.Errorf("missing $ENDFPLIST")
+				}
+				line = strings.TrimSpace(line)
+				if line == "$ENDFPLIST" {
+					break
+				}
+				if line != "" {
+					fpFilters = append(fpFilters, line)
+				}
+			}
+		case kw == "DRAW":
+			if err := readDraw(lr, name, units); err != nil {
+				return nil, err
+			}
+		case len(kw) > 1 && kw[0] == 'F' && kw[1] >= '0' && kw[1] <= '9':
+			prop, err := readLibField(toks)
+			if err != nil {
+				return nil, err
+			}
+			sym.Properties = append(sym.Properties, prop)
+		}
+	}
+}
+
+// readLibField reads a field line from a symbol definition, like:
+//
+//	F1 "R" 0 0 50 V V C CNN
+func readLibField(toks *tokens) (kicad.SchProperty, error) {
+	kw := toks.Raw()
+	id, err := strconv.Atoi(kw[1:])
+	if err != nil {
+		return kicad.SchProperty{}, fmt.Errorf("line %d: invalid field %q", toks.line, kw)
+	}
+	text := toks.String()
+	x := toks.Mils()
+	y := toks.Mils()
+	size := toks.Mils()
+	orient := toks.String()
+	visible := toks.String()
+	hjust := toks.Optional("C")
+	style := toks.Optional("CNN")
+
+	var name string
+	if id < len(mandatoryFields) {
+		name = mandatoryFields[id]
+	} else {
+		name = toks.Optional("Field" + strconv.Itoa(id))
+	}
+	if text == "~" && id < 2 {
+		text = ""
+	}
+
+	prop := kicad.SchProperty{
+		Name:    name,
+		Value:   text,
+		ID:      &id,
+		At:      kicad.PositionAngle{X: x, Y: y, Angle: orientAngle(orient)},
+		Effects: textEffects(size, hjust, style),
+	}
+	prop.Effects.Hide = visible == "I"
+	return prop, toks.Err()
+}
+
+func readDraw(lr *lineReader, symName string, units map[[2]int]*kicad.SymbolUnit) error {
+	unitFor := func(unit, convert int) *kicad.SymbolUnit {
+		key := [2]int{unit, convert}
+		if u, ok := units[key]; ok {
+			return u
+		}
+		u := &kicad.SymbolUnit{
+			Name: symName + "_" + strconv.Itoa(unit) + "_" + strconv.Itoa(convert),
+		}
+		units[key] = u
+		return u
+	}
+
+	for {
+		toks, ok := lr.NextTokens()
+		if !ok {
+			return lr.Errorf("missing ENDDRAW")
+		}
+		switch toks.Raw() {
+		case "ENDDRAW":
+			return nil
+
+		case "A":
+			center := kicad.Position{X: toks.Mils(), Y: toks.Mils()}
+			radius := toks.Mils()
+			t1 := toks.Float() / 10
+			t2 := toks.Float() / 10
+			unit, convert := toks.Int(), toks.Int()
+			stroke := libStroke(toks.Mils())
+			fill := libFill(toks.Optional("N"))
+			start := pointOnCircle(center, radius, t1)
+			end := pointOnCircle(center, radius, t2)
+			if toks.Len() >= 13 {
+				start = kicad.Position{X: toks.Mils(), Y: toks.Mils()}
+				end = kicad.Position{X: toks.Mils(), Y: toks.Mils()}
+			}
+			if err := toks.Err(); err != nil {
+				return err
+			}
+			u := unitFor(unit, convert)
+			u.Arcs = append(u.Arcs, kicad.SchArc{
+				Start:  start,
+				Mid:    arcMid(center, radius, start, end),
+				End:    end,
+				Stroke: stroke,
+				Fill:   fill,
+			})
+
+		case "C":
+			center := kicad.Position{X: toks.Mils(), Y: toks.Mils()}
+			radius := toks.Mils()
+			unit, convert := toks.Int(), toks.Int()
+			stroke := libStroke(toks.Mils())
+			fill := libFill(toks.Optional("N"))
+			if err := toks.Err(); err != nil {
+				return err
+			}
+			u := unitFor(unit, convert)
+			u.Circles = append(u.Circles, kicad.SchCircle{
+				Center: center,
+				Radius: radius,
+				Stroke: stroke,
+				Fill:   fill,
+			})
+
+		case "S":
+			start := kicad.Position{X: toks.Mils(), Y: toks.Mils()}
+			end := kicad.Position{X: toks.Mils(), Y: toks.Mils()}
+			unit, convert := toks.Int(), toks.Int()
+			stroke := libStroke(toks.Mils())
+			fill := libFill(toks.Optional("N"))
+			if err := toks.Err(); err != nil {
+				return err
+			}
+			u := unitFor(unit, convert)
+			u.Rectangles = append(u.Rectangles, kicad.SchRectangle{
+				Start:  start,
+				End:    end,
+				Stroke: stroke,
+				Fill:   fill,
+			})
+
+		case "P", "B":
+			kind := toks.toks[0]
+			count := toks.Int()
+			unit, convert := toks.Int(), toks.Int()
+			stroke := libStroke(toks.Mils())
+			var xy []kicad.Position
+			for i := 0; i < count; i++ {
+				xy = append(xy, kicad.Position{X: toks.Mils(), Y: toks.Mils()})
+			}
+			pts := kicad.NewPoints(xy...)
+			fill := libFill(toks.Optional("N"))
+			if err := toks.Err(); err != nil {
+				return err
+			}
+			u := unitFor(unit, convert)
+			if kind == "P" {
+				u.Polylines = append(u.Polylines, kicad.SchPolyline{Points: pts, Stroke: stroke, Fill: fill})
+			} else {
+				u.Beziers = append(u.Beziers, kicad.SchBezier{Points: pts, Stroke: stroke, Fill: fill})
+			}
+
+		case "T":
+			angle := toks.Float() / 10
+			at := kicad.PositionAngle{X: toks.Mils(), Y: toks.Mils(), Angle: angle}
+			size := toks.Mils()
+			hidden := toks.Int()
+			unit, convert := toks.Int(), toks.Int()
+			raw := toks.Raw()
+			text := unquote(raw)
+			if raw == text {
+				// Unquoted text uses ~ in place of spaces.
+				text = strings.ReplaceAll(text, "~", " ")
+			}
+			italic := toks.Optional("Normal")
+			bold := toks.Optional("0")
+			hjust := toks.Optional("C")
+			vjust := toks.Optional("C")
+			if err := toks.Err(); err != nil {
+				return err
+			}
+			style := vjust + "N" + "N"
+			effects := textEffects(size, hjust, style)
+			effects.Font.Italic = italic == "Italic"
+			effects.Font.Bold = bold != "0"
+			effects.Hide = hidden != 0
+			u := unitFor(unit, convert)
+			u.Texts = append(u.Texts, kicad.SchText{Text: text, At: at, Effects: effects})
+
+		case "X":
+			pin, unit, convert, err := readPin(toks)
+			if err != nil {
+				return err
+			}
+			u := unitFor(unit, convert)
+			u.Pins = append(u.Pins, pin)
+		}
+	}
+}
+
+// readPin reads a pin line from a symbol definition, like:
+//
+//	X ~ 1 0 150 50 D 50 50 1 1 P
+func readPin(toks *tokens) (pin kicad.Pin, unit, convert int, err error) {
+	name := toks.String()
+	number := toks.String()
+	x := toks.Mils()
+	y := toks.Mils()
+	length := toks.Mils()
+	orient := toks.String()
+	numSize := toks.Mils()
+	nameSize := toks.Mils()
+	unit = toks.Int()
+	convert = toks.Int()
+	etype := toks.String()
+	shape := toks.Optional("")
+	if err := toks.Err(); err != nil {
+		return pin, 0, 0, err
+	}
+
+	pin = kicad.Pin{
+		ElectricalType: pinElectricalTypes[etype],
+		GraphicStyle:   "line",
+		At:             kicad.PositionAngle{X: x, Y: y, Angle: pinAngles[orient]},
+		Length:         length,
+		Name:           kicad.PinText{Text: name, Effects: textEffects(nameSize, "C", "CNN")},
+		Number:         kicad.PinText{Text: number, Effects: textEffects(numSize, "C", "CNN")},
+	}
+	if pin.ElectricalType == "" {
+		pin.ElectricalType = "unspecified"
+	}
+
+	flags := make(map[rune]bool)
+	for _, ch := range shape {
+		flags[ch] = true
+	}
+	pin.Hide = flags['N']
+	switch {
+	case flags['I'] && flags['C']:
+		pin.GraphicStyle = "inverted_clock"
+	case flags['C'] && flags['L']:
+		pin.GraphicStyle = "clock_low"
+	case flags['I']:
+		pin.GraphicStyle = "inverted"
+	case flags['C']:
+		pin.GraphicStyle = "clock"
+	case flags['L']:
+		pin.GraphicStyle = "input_low"
+	case flags['V']:
+		pin.GraphicStyle = "output_low"
+	case flags['F']:
+		pin.GraphicStyle = "edge_clock_high"
+	case flags['X']:
+		pin.GraphicStyle = "non_logic"
+	}
+	return pin, unit, convert, nil
+}
+
+var pinElectricalTypes = map[string]string{
+	"I": "input",
+	"O": "output",
+	"B": "bidirectional",
+	"T": "tri_state",
+	"P": "passive",
+	"U": "unspecified",
+	"W": "power_in",
+	"w": "power_out",
+	"C": "open_collector",
+	"E": "open_emitter",
+	"N": "no_connect",
+}
+
+// pinAngles maps the direction that a pin points from its connection point
+// to the angle that the s-expression format uses for the same pin.
+var pinAngles = map[string]float64{
+	"R": 0,
+	"U": 90,
+	"L": 180,
+	"D": 270,
+}
+
+// sortedUnits returns the given units in order of unit number and then
+// body style, which is the order that KiCad writes them in.
+func sortedUnits(units map[[2]int]*kicad.SymbolUnit) []kicad.SymbolUnit {
+	keys := make([][2]int, 0, len(units))
+	for key := range units {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i][0] != keys[j][0] {
+			return keys[i][0] < keys[j][0]
+		}
+		return keys[i][1] < keys[j][1]
+	})
+
+	ret := make([]kicad.SymbolUnit, len(keys))
+	for i, key := range keys {
+		ret[i] = *units[key]
+	}
+	return ret
+}
+
+// aliasSymbol returns a symbol that extends the given one under the given
+// alias name, which is how KiCad 6 represents legacy aliases.
+func aliasSymbol(sym *kicad.Symbol, alias string) kicad.Symbol {
+	ret := kicad.Symbol{
+		Name:    alias,
+		Extends: sym.Name,
+	}
+	for _, prop := range sym.Properties {
+		if prop.Name == "Value" {
+			prop.Value = alias
+			ret.Properties = append(ret.Properties, prop)
+		}
+	}
+	return ret
+}
+
+// hiddenProperty returns a hidden property with the next free field number
+// of the given symbol.
+func hiddenProperty(sym *kicad.Symbol, name, value string) kicad.SchProperty {
+	id := 0
+	for _, prop := range sym.Properties {
+		if prop.ID != nil && *prop.ID >= id {
+			id = *prop.ID + 1
+		}
+	}
+	prop := kicad.SchProperty{
+		Name:    name,
+		Value:   value,
+		ID:      &id,
+		Effects: textEffects(1.27, "C", "CNN"),
+	}
+	prop.Effects.Hide = true
+	return prop
+}
+
+func libStroke(width float64) kicad.Stroke {
+	return kicad.Stroke{Width: width, Type: "default"}
+}
+
+func libFill(fill string) kicad.SchFill {
+	switch fill {
+	case "F":
+		return kicad.SchFill{Type: "outline"}
+	case "f":
+		return kicad.SchFill{Type: "background"}
+	default:
+		return kicad.SchFill{Type: "none"}
+	}
+}
+
+// textEffects returns the effects for a legacy text item with the given
+// size and justification. Style is the three-letter code giving the
+// vertical justification and whether the text is italic and bold, like
+// "CNN" or "BIB".
+func textEffects(size float64, hjust, style string) kicad.TextEffects {
+	effects := kicad.TextEffects{
+		Font: kicad.Font{Size: kicad.TextSize{Height: size, Width: size}},
+	}
+	switch hjust {
+	case "L":
+		effects.Justify.Left = true
+	case "R":
+		effects.Justify.Right = true
+	}
+	if len(style) > 0 {
+		switch style[0] {
+		case 'T':
+			effects.Justify.Top = true
+		case 'B':
+			effects.Justify.Bottom = true
+		}
+	}
+	effects.Font.Italic = len(style) > 1 && style[1] == 'I'
+	effects.Font.Bold = len(style) > 2 && style[2] == 'B'
+	return effects
+}
+
+func orientAngle(orient string) float64 {
+	if orient == "V" {
+		return 90
+	}
+	return 0
+}
+
+func pointOnCircle(center kicad.Position, radius, degrees float64) kicad.Position {
+	s, c := math.Sincos(degrees * math.Pi / 180)
+	return kicad.Position{
+		X: center.X + radius*c,
+		Y: center.Y + radius*s,
+	}
+}
+
+// arcMid returns the midpoint of a legacy arc. KiCad 5 always draws the
+// shorter of the two arcs between the start and end points, taking the
+// clockwise one when they are opposite.
+func arcMid(center kicad.Position, radius float64, start, end kicad.Position) kicad.Position {
+	a1 := math.Atan2(start.Y-center.Y, start.X-center.X)
+	a2 := math.Atan2(end.Y-center.Y, end.X-center.X)
+	sweep := math.Remainder(a2-a1, 2*math.Pi)
+	if sweep >= math.Pi-1e-9 {
+		sweep = -math.Pi
+	}
+	mid := pointOnCircle(center, radius, (a1+sweep/2)*180/math.Pi)
+	return kicad.Position{X: roundNM(mid.X), Y: roundNM(mid.Y)}
+}
+
+func roundNM(v float64) float64 {
+	return math.Round(v*1e6) / 1e6
+}
+
+// SymbolDoc is the documentation for a symbol from a legacy .dcm file.
+type SymbolDoc struct {
+	Description string
+	Keywords    string
+	Datasheet   string
+}
+
+// ReadDocLibrary reads a stream containing a legacy .dcm documentation
+// file and returns the documentation it contains, keyed by symbol name.
+func ReadDocLibrary(r io.Reader) (map[string]SymbolDoc, error) {
+	lr := newLineReader(r)
+	docs := make(map[string]SymbolDoc)
+
+	var name string
+	var doc SymbolDoc
+	for {
+		line, ok := lr.Next()
+		if !ok {
+			break
+		}
+		kw, rest, _ := strings.Cut(line, " ")
+		rest = strings.TrimSpace(rest)
+		switch kw {
+		case "$CMP":
+			name = rest
+			doc = SymbolDoc{}
+		case "D":
+			doc.Description = rest
+		case "K":
+			doc.Keywords = rest
+		case "F":
+			doc.Datasheet = rest
+		case "$ENDCMP":
+			if name == "" {
+				return nil, lr.Errorf("$ENDCMP without $CMP")
+			}
+			docs[name] = doc
+			name = ""
+		}
+	}
+	return docs, lr.Err()
+}
+
+// ApplyDocs adds the given documentation to the symbols of the given
+// library, as the ki_description and ki_keywords properties and as the
+// datasheet if the symbol doesn't already have one.
+func ApplyDocs(lib *kicad.SymbolLibrary, docs map[string]SymbolDoc) {
+	for i := range lib.Symbols {
+		sym := &lib.Symbols[i]
+		doc, ok := docs[sym.Name]
+		if !ok {
+			continue
+		}
+
+		if doc.Datasheet != "" && doc.Datasheet != "~" {
+			if ds := sym.Property("Datasheet"); ds == "" || ds == "~" {
+				setProperty(sym, "Datasheet", doc.Datasheet)
+			}
+		}
+		if doc.Description != "" {
+			sym.Properties = append(sym.Properties, hiddenProperty(sym, "ki_description", doc.Description))
+		}
+		if doc.Keywords != "" {
+			sym.Properties = append(sym.Properties, hiddenProperty(sym, "ki_keywords", doc.Keywords))
+		}
+	}
+}
+
+func setProperty(sym *kicad.Symbol, name, value string) {
+	for i := range sym.Properties {
+		if sym.Properties[i].Name == name {
+			sym.Properties[i].Value = value
+			return
+		}
+	}
+	sym.Properties = append(sym.Properties, hiddenProperty(sym, name, value))
+}
@@ -0,0 +1,213 @@
+package legacy
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/davecgh/go-spew/spew"
+
+	kicad "github.com/apparentlymart/go-kicad"
+)
+
+const testLib = `EESchema-LIBRARY Version 2.4
+#encoding utf-8
+#
+# R
+#
+DEF R R 0 0 N Y 1 F N
+F0 "R" 80 0 50 V V C CNN
+F1 "R" 0 0 50 V V C CNN
+F2 "" -70 0 50 V I C CNN
+F3 "" 0 0 50 H I C CNN
+ALIAS R_Small
+$FPLIST
+ R_*
+ Resistor_*
+$ENDFPLIST
+DRAW
+S -40 -100 40 100 0 1 10 N
+X ~ 1 0 150 50 D 50 50 1 1 P
+X ~ 2 0 -150 50 U 50 50 1 1 P
+ENDDRAW
+ENDDEF
+#
+# 74LS00
+#
+DEF 74LS00 U 0 40 Y Y 2 L N
+F0 "U" 0 50 50 H V C CNN
+F1 "74LS00" 0 -50 50 H V C CNN
+F4 "TI" 0 -150 50 H I C CNN "Manufacturer"
+DRAW
+A 0 0 100 900 -900 1 1 10 f 0 100 0 -100
+T 0 0 -200 50 0 1 1 "Quad NAND" Italic 0 L C
+T 0 0 -300 50 0 0 1 Gate~A Normal 1 C C
+P 2 0 1 10 -100 50 -100 -50 N
+X VCC 14 0 300 100 D 50 50 0 0 W N
+X ~ 1 -200 50 100 R 50 50 1 1 I
+X ~ 3 200 0 100 L 50 50 1 1 O I
+X CLK 2 -200 -50 100 R 50 50 2 1 I C
+ENDDRAW
+ENDDEF
+#
+#End Library
+`
+
+const testDoc = `EESchema-DOCLIB  Version 2.0
+#
+$CMP R
+D Resistor
+K R res resistor
+F ~
+$ENDCMP
+#
+$CMP R_Small
+D Resistor, small symbol
+F https://example.com/r.pdf
+$ENDCMP
+#
+#End Doc Library
+`
+
+func TestReadSymbolLibrary(t *testing.T) {
+	lib, err := ReadSymbolLibrary(strings.NewReader(testLib))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	docs, err := ReadDocLibrary(strings.NewReader(testDoc))
+	if err != nil {
+		t.Fatalf("unexpected error reading docs: %s", err)
+	}
+	ApplyDocs(lib, docs)
+
+	var names []string
+	for _, sym := range lib.Symbols {
+		names = append(names, sym.Name)
+	}
+	if got, want := names, []string{"R", "R_Small", "74LS00"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("wrong symbols %#v; want %#v", got, want)
+	}
+
+	r := lib.Symbol("R")
+	if !r.PinNumbers.Hide || r.PinNames.Hide {
+		t.Errorf("wrong pin display settings %s", spew.Sdump(r.PinNumbers, r.PinNames))
+	}
+	if got, want := r.Property("ki_fp_filters"), "R_* Resistor_*"; got != want {
+		t.Errorf("wrong footprint filters %q; want %q", got, want)
+	}
+	if got, want := r.Property("ki_keywords"), "R res resistor"; got != want {
+		t.Errorf("wrong keywords %q; want %q", got, want)
+	}
+	wantUnits := []kicad.SymbolUnit{
+		{
+			Name: "R_0_1",
+			Rectangles: []kicad.SchRectangle{{
+				Start:  kicad.Position{X: -1.016, Y: -2.54},
+				End:    kicad.Position{X: 1.016, Y: 2.54},
+				Stroke: kicad.Stroke{Width: 0.254, Type: "default"},
+				Fill:   kicad.SchFill{Type: "none"},
+			}},
+		},
+		{
+			Name: "R_1_1",
+			Pins: []kicad.Pin{
+				{
+					ElectricalType: "passive",
+					GraphicStyle:   "line",
+					At:             kicad.PositionAngle{X: 0, Y: 3.81, Angle: 270},
+					Length:         1.27,
+					Name:           kicad.PinText{Text: "~", Effects: textEffects(1.27, "C", "CNN")},
+					Number:         kicad.PinText{Text: "1", Effects: textEffects(1.27, "C", "CNN")},
+				},
+				{
+					ElectricalType: "passive",
+					GraphicStyle:   "line",
+					At:             kicad.PositionAngle{X: 0, Y: -3.81, Angle: 90},
+					Length:         1.27,
+					Name:           kicad.PinText{Text: "~", Effects: textEffects(1.27, "C", "CNN")},
+					Number:         kicad.PinText{Text: "2", Effects: textEffects(1.27, "C", "CNN")},
+				},
+			},
+		},
+	}
+	if !reflect.DeepEqual(r.Units, wantUnits) {
+		t.Errorf("wrong units\ngot:  %swant: %s", spew.Sdump(r.Units), spew.Sdump(wantUnits))
+	}
+
+	small, err := lib.Resolve("R_Small")
+	if err != nil {
+		t.Fatalf("unexpected error resolving alias: %s", err)
+	}
+	if got, want := small.Property("Value"), "R_Small"; got != want {
+		t.Errorf("wrong alias value %q; want %q", got, want)
+	}
+	if got, want := small.Property("Datasheet"), "https://example.com/r.pdf"; got != want {
+		t.Errorf("wrong alias datasheet %q; want %q", got, want)
+	}
+	if got, want := small.Property("ki_description"), "Resistor, small symbol"; got != want {
+		t.Errorf("wrong alias description %q; want %q", got, want)
+	}
+	if got, want := len(small.Pins()), 2; got != want {
+		t.Errorf("wrong number of alias pins %d; want %d", got, want)
+	}
+
+	gate := lib.Symbol("74LS00")
+	if got, want := gate.UnitCount(), 2; got != want {
+		t.Errorf("wrong unit count %d; want %d", got, want)
+	}
+	if got, want := gate.Property("Manufacturer"), "TI"; got != want {
+		t.Errorf("wrong manufacturer %q; want %q", got, want)
+	}
+	styles := make(map[string]string)
+	for _, pin := range gate.Pins() {
+		styles[pin.Number.Text] = pin.ElectricalType + " " + pin.GraphicStyle
+		if pin.Number.Text == "14" && !pin.Hide {
+			t.Errorf("pin 14 should be hidden")
+		}
+	}
+	wantStyles := map[string]string{
+		"14": "power_in line",
+		"1":  "input line",
+		"3":  "output inverted",
+		"2":  "input clock",
+	}
+	if !reflect.DeepEqual(styles, wantStyles) {
+		t.Errorf("wrong pin styles %#v; want %#v", styles, wantStyles)
+	}
+
+	unit1 := gate.UnitItems(1, 1)
+	var arc *kicad.SchArc
+	var texts []string
+	for _, u := range unit1 {
+		for i := range u.Arcs {
+			arc = &u.Arcs[i]
+		}
+		for _, text := range u.Texts {
+			texts = append(texts, text.Text)
+		}
+	}
+	if arc == nil {
+		t.Fatalf("no arc in unit 1")
+	}
+	if got, want := arc.Mid, (kicad.Position{X: 2.54, Y: 0}); got != want {
+		t.Errorf("wrong arc midpoint %#v; want %#v", got, want)
+	}
+	if got, want := texts, []string{"Gate A", "Quad NAND"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong texts %#v; want %#v", got, want)
+	}
+
+	// The converted library must survive being written in the
+	// s-expression format.
+	var buf bytes.Buffer
+	if err := kicad.WriteSymbolLibrary(&buf, lib); err != nil {
+		t.Fatalf("unexpected error writing: %s", err)
+	}
+	got, err := kicad.ReadSymbolLibrary(&buf)
+	if err != nil {
+		t.Fatalf("unexpected error re-reading: %s", err)
+	}
+	if !reflect.DeepEqual(got, lib) {
+		t.Errorf("incorrect round-trip\ngot:  %swant: %s", spew.Sdump(got), spew.Sdump(lib))
+	}
+}
@@ -0,0 +1,350 @@
+package kicad
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/apparentlymart/go-kicad/sexp"
+)
+
+const (
+	// FootprintLibTableName is the name of the file that lists the
+	// footprint libraries of a project, or of the global configuration.
+	FootprintLibTableName = "fp-lib-table"
+
+	// SymbolLibTableName is the name of the file that lists the symbol
+	// libraries of a project, or of the global configuration.
+	SymbolLibTableName = "sym-lib-table"
+)
+
+// ReadLibTable reads a stream containing a footprint or symbol library
+// table, as found in fp-lib-table and sym-lib-table files, and returns a
+// LibTable structure describing it.
+func ReadLibTable(r io.Reader) (*LibTable, error) {
+	t := &LibTable{}
+	typeName, err := sexp.DecodeAny(r, []string{"fp_lib_table", "sym_lib_table"}, t)
+	t.Type = typeName
+	return t, err
+}
+
+// ReadLibTableFile is a convenience wrapper around ReadLibTable that takes a
+// filename and opens the given file for reading before calling
+// ReadLibTable. The filename is recorded in the result.
+func ReadLibTableFile(filename string) (*LibTable, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	t, err := ReadLibTable(f)
+	t.Filename = filename
+	return t, err
+}
+
+// WriteLibTable writes the given library table to the given writer.
+func WriteLibTable(w io.Writer, t *LibTable) error {
+	if t.Type == "" {
+		return fmt.Errorf("library table has no type")
+	}
+	return sexp.Encode(w, t.Type, t)
+}
+
+// WriteLibTableFile is a convenience wrapper around WriteLibTable that
+// creates or replaces the given file before calling WriteLibTable.
+func WriteLibTableFile(filename string, t *LibTable) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+
+	err = WriteLibTable(f, t)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// LibTable represents a library table, which gives the nicknames and
+// locations of footprint or symbol libraries.
+//
+// Type is the table's keyword, either "fp_lib_table" or "sym_lib_table".
+// Filename is the file the table was read from, if any, which is used to
+// find nested tables given by relative paths.
+type LibTable struct {
+	Type     string
+	Filename string
+
+	Version int        `kicad:"version"`
+	Libs    []LibEntry `kicad:"lib,multi,flat"`
+}
+
+// Lib returns the entry with the given nickname directly within the table,
+// or nil if there is no such entry.
+func (t *LibTable) Lib(name string) *LibEntry {
+	for i := range t.Libs {
+		if t.Libs[i].Name == name {
+			return &t.Libs[i]
+		}
+	}
+	return nil
+}
+
+// LibEntry is a library in a library table.
+//
+// Type is the library's plugin type, such as "KiCad" or "Legacy", or is
+// "Table" for an entry whose URI is another library table that contributes
+// its libraries to this one. URI may refer to environment variables as
+// ${NAME}.
+type LibEntry struct {
+	Name     string `kicad:"name"`
+	Type     string `kicad:"type"`
+	URI      string `kicad:"uri"`
+	Options  string `kicad:"options,always"`
+	Descr    string `kicad:"descr,always"`
+	Disabled bool   `kicad:"disabled,empty"`
+	Hidden   bool   `kicad:"hidden,empty"`
+}
+
+// LibTypeTable is the LibEntry type of entries that refer to nested library
+// tables.
+const LibTypeTable = "Table"
+
+// LibResolver finds libraries by nickname using a project's library table
+// and the global one, where the project's entries take precedence over
+// global entries with the same nickname.
+//
+// Library URIs are expanded using Expand. A LibResolver is not safe for
+// concurrent use.
+type LibResolver struct {
+	// ProjectDir is the project directory, which is the value of
+	// ${KIPRJMOD}.
+	ProjectDir string
+
+	// Project and Global are the project and global library tables. Either
+	// may be nil.
+	Project *LibTable
+	Global  *LibTable
+
+	// Vars are user-defined path variables, as configured in KiCad's
+	// preferences. Environment variables take precedence over them, as in
+	// KiCad itself.
+	Vars map[string]string
+
+	// LookupEnv looks up environment variables. If it is nil then
+	// os.LookupEnv is used.
+	LookupEnv func(name string) (string, bool)
+
+	nested map[string]*LibTable
+}
+
+// LoadLibResolver returns a LibResolver for the library table with the
+// given name, such as FootprintLibTableName, in the given project
+// directory, along with the given global table file.
+//
+// The global filename may be empty, and neither file need exist, in which
+// case the corresponding table is nil.
+func LoadLibResolver(projectDir, globalFilename, tableName string) (*LibResolver, error) {
+	r := &LibResolver{
+		ProjectDir: projectDir,
+	}
+
+	filename := filepath.Join(projectDir, tableName)
+	t, err := ReadLibTableFile(filename)
+	if err := optionalFileErr(filename, err); err != nil {
+		return nil, err
+	}
+	if err == nil {
+		r.Project = t
+	}
+
+	if globalFilename != "" {
+		t, err := ReadLibTableFile(globalFilename)
+		if err := optionalFileErr(globalFilename, err); err != nil {
+			return nil, err
+		}
+		if err == nil {
+			r.Global = t
+		}
+	}
+
+	return r, nil
+}
+
+// Expand replaces references to variables in the given string, written as
+// ${NAME} or $(NAME), with their values. ${KIPRJMOD} refers to the project
+// directory, and other names refer to environment variables or to Vars.
+//
+// References to undefined variables are left unchanged.
+func (r *LibResolver) Expand(s string) string {
+	var b strings.Builder
+	for {
+		i := strings.IndexByte(s, '$')
+		if i < 0 || i+1 >= len(s) {
+			break
+		}
+		var close byte
+		switch s[i+1] {
+		case '{':
+			close = '}'
+		case '(':
+			close = ')'
+		default:
+			b.WriteString(s[:i+1])
+			s = s[i+1:]
+			continue
+		}
+		end := strings.IndexByte(s[i+2:], close)
+		if end < 0 {
+			break
+		}
+		ref := s[i : i+2+end+1]
+		b.WriteString(s[:i])
+		if val, ok := r.lookupVar(s[i+2 : i+2+end]); ok {
+			b.WriteString(val)
+		} else {
+			b.WriteString(ref)
+		}
+		s = s[i+len(ref):]
+	}
+	b.WriteString(s)
+	return b.String()
+}
+
+func (r *LibResolver) lookupVar(name string) (string, bool) {
+	if name == "KIPRJMOD" && r.ProjectDir != "" {
+		return r.ProjectDir, true
+	}
+	lookupEnv := r.LookupEnv
+	if lookupEnv == nil {
+		lookupEnv = os.LookupEnv
+	}
+	if val, ok := lookupEnv(name); ok {
+		return val, true
+	}
+	val, ok := r.Vars[name]
+	return val, ok
+}
+
+// Lib returns the library with the given nickname, searching the project
+// table before the global one, along with the table that contains it.
+// Libraries in nested tables are found as if they were in the table that
+// refers to them, but the table returned is the nested one, against which
+// a relative URI of the library is resolved.
+//
+// Lib returns an error if there is no such library or if it is disabled.
+func (r *LibResolver) Lib(name string) (*LibEntry, *LibTable, error) {
+	for _, t := range []*LibTable{r.Project, r.Global} {
+		if t == nil {
+			continue
+		}
+		lib, table, err := r.findLib(t, name, nil)
+		if err != nil {
+			return nil, nil, err
+		}
+		if lib == nil {
+			continue
+		}
+		if lib.Disabled {
+			return nil, nil, fmt.Errorf("library %q is disabled", name)
+		}
+		return lib, table, nil
+	}
+	return nil, nil, fmt.Errorf("no library named %q", name)
+}
+
+func (r *LibResolver) findLib(t *LibTable, name string, seen []string) (*LibEntry, *LibTable, error) {
+	for i := range t.Libs {
+		lib := &t.Libs[i]
+		if lib.Type != LibTypeTable {
+			if lib.Name == name {
+				return lib, t, nil
+			}
+			continue
+		}
+		if lib.Disabled {
+			continue
+		}
+
+		filename := r.libPath(t, lib)
+		for _, prev := range seen {
+			if prev == filename {
+				return nil, nil, fmt.Errorf("library table %s includes itself", filename)
+			}
+		}
+		nested, err := r.nestedTable(filename)
+		if err != nil {
+			return nil, nil, err
+		}
+		found, table, err := r.findLib(nested, name, append(seen, filename))
+		if found != nil || err != nil {
+			return found, table, err
+		}
+	}
+	return nil, nil, nil
+}
+
+func (r *LibResolver) nestedTable(filename string) (*LibTable, error) {
+	if t, ok := r.nested[filename]; ok {
+		return t, nil
+	}
+	t, err := ReadLibTableFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("reading library table: %w", err)
+	}
+	if r.nested == nil {
+		r.nested = make(map[string]*LibTable)
+	}
+	r.nested[filename] = t
+	return t, nil
+}
+
+// libPath returns the expanded URI of the given library from the given
+// table, interpreting a relative path as relative to the table's file.
+func (r *LibResolver) libPath(t *LibTable, lib *LibEntry) string {
+	path := r.Expand(lib.URI)
+	if !filepath.IsAbs(path) && t.Filename != "" {
+		path = filepath.Join(filepath.Dir(t.Filename), path)
+	}
+	return path
+}
+
+// LibPath returns the location of the library with the given nickname,
+// which is its expanded URI. A relative URI is interpreted as relative to
+// the file of the table that contains the library.
+func (r *LibResolver) LibPath(name string) (string, error) {
+	lib, table, err := r.Lib(name)
+	if err != nil {
+		return "", err
+	}
+	return r.libPath(table, lib), nil
+}
+
+// FootprintPath returns the path of the .kicad_mod file for the footprint
+// with the given library ID, as in Footprint.LibID, such as
+// "Resistor_SMD:R_0603_1608Metric".
+//
+// The library must be a .pretty directory, which has the plugin type
+// "KiCad". The file is not required to exist.
+func (r *LibResolver) FootprintPath(libID string) (string, error) {
+	libName, name, found := strings.Cut(libID, ":")
+	if !found || libName == "" || name == "" {
+		return "", fmt.Errorf("footprint ID %q has no library nickname", libID)
+	}
+	if err := checkFootprintName(name); err != nil {
+		return "", err
+	}
+
+	lib, table, err := r.Lib(libName)
+	if err != nil {
+		return "", err
+	}
+	if lib.Type != "KiCad" {
+		return "", fmt.Errorf("library %q has unsupported type %q", libName, lib.Type)
+	}
+	return filepath.Join(r.libPath(table, lib), name+footprintFileExt), nil
+}
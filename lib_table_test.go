@@ -0,0 +1,160 @@
+package kicad
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/davecgh/go-spew/spew"
+)
+
+func TestReadLibTable(t *testing.T) {
+	tests := map[string]struct {
+		src  string
+		want *LibTable
+	}{
+		"KiCad 5": {
+			`(fp_lib_table
+  (lib (name Local)(type KiCad)(uri ${KIPRJMOD}/Local.pretty)(options "")(descr "Project footprints"))
+)
+`,
+			&LibTable{
+				Type: "fp_lib_table",
+				Libs: []LibEntry{
+					{Name: "Local", Type: "KiCad", URI: "${KIPRJMOD}/Local.pretty", Descr: "Project footprints"},
+				},
+			},
+		},
+		"KiCad 8": {
+			`(sym_lib_table
+  (version 7)
+  (lib (name "Device")(type "KiCad")(uri "${KICAD8_SYMBOL_DIR}/Device.kicad_sym")(options "")(descr "Generic symbols"))
+  (lib (name "Old")(type "Legacy")(uri "${KIPRJMOD}/old.lib")(options "")(descr "")(disabled)(hidden))
+)
+`,
+			&LibTable{
+				Type:    "sym_lib_table",
+				Version: 7,
+				Libs: []LibEntry{
+					{Name: "Device", Type: "KiCad", URI: "${KICAD8_SYMBOL_DIR}/Device.kicad_sym", Descr: "Generic symbols"},
+					{Name: "Old", Type: "Legacy", URI: "${KIPRJMOD}/old.lib", Disabled: true, Hidden: true},
+				},
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := ReadLibTable(strings.NewReader(test.src))
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Fatalf("incorrect result\ngot:  %swant: %s", spew.Sdump(got), spew.Sdump(test.want))
+			}
+
+			var buf bytes.Buffer
+			if err := WriteLibTable(&buf, got); err != nil {
+				t.Fatalf("unexpected error writing: %s", err)
+			}
+			again, err := ReadLibTable(&buf)
+			if err != nil {
+				t.Fatalf("unexpected error reading result: %s", err)
+			}
+			if !reflect.DeepEqual(again, test.want) {
+				t.Errorf("incorrect round-trip\ngot:  %swant: %s", spew.Sdump(again), spew.Sdump(test.want))
+			}
+		})
+	}
+}
+
+func TestLibResolver(t *testing.T) {
+	dir := t.TempDir()
+	projectDir := filepath.Join(dir, "project")
+	configDir := filepath.Join(dir, "config")
+	for _, d := range []string{projectDir, configDir} {
+		if err := os.Mkdir(d, 0777); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write := func(filename, src string) {
+		t.Helper()
+		if err := os.WriteFile(filename, []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(filepath.Join(projectDir, FootprintLibTableName), `(fp_lib_table (version 7)
+  (lib (name "Local")(type "KiCad")(uri "${KIPRJMOD}/Local.pretty")(options "")(descr ""))
+  (lib (name "Resistor_SMD")(type "KiCad")(uri "${KIPRJMOD}/lib/Resistor_SMD.pretty")(options "")(descr ""))
+  (lib (name "Broken")(type "KiCad")(uri "$(MISSING)/Broken.pretty")(options "")(descr "")(disabled))
+)`)
+	write(filepath.Join(configDir, FootprintLibTableName), `(fp_lib_table (version 7)
+  (lib (name "Resistor_SMD")(type "KiCad")(uri "${KICAD8_FOOTPRINT_DIR}/Resistor_SMD.pretty")(options "")(descr ""))
+  (lib (name "Capacitor_SMD")(type "KiCad")(uri "${KICAD8_FOOTPRINT_DIR}/Capacitor_SMD.pretty")(options "")(descr ""))
+  (lib (name "Company")(type "Table")(uri "company-fp-lib-table")(options "")(descr ""))
+  (lib (name "Eagle")(type "Eagle")(uri "${MYLIBS}/parts.lbr")(options "")(descr ""))
+)`)
+	write(filepath.Join(configDir, "company-fp-lib-table"), `(fp_lib_table (version 7)
+  (lib (name "Company")(type "KiCad")(uri "${MYLIBS}/Company.pretty")(options "")(descr ""))
+  (lib (name "Shared")(type "KiCad")(uri "shared/Shared.pretty")(options "")(descr ""))
+)`)
+
+	r, err := LoadLibResolver(projectDir, filepath.Join(configDir, FootprintLibTableName), FootprintLibTableName)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	r.Vars = map[string]string{
+		"MYLIBS":               "/srv/libs",
+		"KICAD8_FOOTPRINT_DIR": "/ignored",
+	}
+	r.LookupEnv = func(name string) (string, bool) {
+		if name == "KICAD8_FOOTPRINT_DIR" {
+			return "/usr/share/kicad/footprints", true
+		}
+		return "", false
+	}
+
+	tests := []struct {
+		libID   string
+		want    string
+		wantErr string
+	}{
+		{"Local:TP", filepath.Join(projectDir, "Local.pretty", "TP.kicad_mod"), ""},
+		{"Resistor_SMD:R_0603", filepath.Join(projectDir, "lib", "Resistor_SMD.pretty", "R_0603.kicad_mod"), ""},
+		{"Capacitor_SMD:C_0603", "/usr/share/kicad/footprints/Capacitor_SMD.pretty/C_0603.kicad_mod", ""},
+		{"Company:Logo", "/srv/libs/Company.pretty/Logo.kicad_mod", ""},
+		{"Shared:TP", filepath.Join(configDir, "shared", "Shared.pretty", "TP.kicad_mod"), ""},
+		{"Broken:X", "", `library "Broken" is disabled`},
+		{"Eagle:X", "", `library "Eagle" has unsupported type "Eagle"`},
+		{"Nope:X", "", `no library named "Nope"`},
+		{"R_0603", "", `footprint ID "R_0603" has no library nickname`},
+	}
+	for _, test := range tests {
+		t.Run(test.libID, func(t *testing.T) {
+			got, err := r.FootprintPath(test.libID)
+			if test.wantErr != "" {
+				if err == nil || err.Error() != test.wantErr {
+					t.Fatalf("wrong error %v; want %q", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if got != filepath.FromSlash(test.want) {
+				t.Errorf("wrong path %q; want %q", got, test.want)
+			}
+		})
+	}
+
+	if got, err := r.LibPath("Shared"); err != nil || got != filepath.Join(configDir, "shared", "Shared.pretty") {
+		t.Errorf("wrong library path %q (error %v); want it relative to the nested table", got, err)
+	}
+
+	if got, want := r.Expand("${MISSING}/a/$(MYLIBS)/$x"), "${MISSING}/a//srv/libs/$x"; got != want {
+		t.Errorf("wrong expansion %q; want %q", got, want)
+	}
+}
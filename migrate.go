@@ -0,0 +1,685 @@
+package kicad
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// The format versions written by each release of KiCad, for both boards
+// and standalone footprints. Development versions of KiCad write the
+// versions in between.
+const (
+	PCBVersionKiCad5 = 20171130
+	PCBVersionKiCad6 = 20211014
+	PCBVersionKiCad7 = 20221018
+	PCBVersionKiCad8 = 20240108
+
+	// PCBVersion is the format version that Upgrade converts documents to.
+	PCBVersion = PCBVersionKiCad8
+)
+
+// KiCadVersion returns the major version of the KiCad release that writes
+// the given board or footprint format version. A development version is
+// reported as the release it led up to, and a version older than KiCad 5
+// is reported as 5. The result is zero for versions newer than any that
+// this package knows about.
+func KiCadVersion(formatVersion int) int {
+	switch {
+	case formatVersion <= PCBVersionKiCad5:
+		return 5
+	case formatVersion <= PCBVersionKiCad6:
+		return 6
+	case formatVersion <= PCBVersionKiCad7:
+		return 7
+	case formatVersion <= PCBVersionKiCad8:
+		return 8
+	default:
+		return 0
+	}
+}
+
+// LegacyTimestampUUID converts a hexadecimal timestamp, which KiCad 5 uses
+// to identify items, into the UUID that KiCad 6 uses for the same item.
+func LegacyTimestampUUID(ts string) string {
+	ts = strings.ToLower(ts)
+	if len(ts) < 8 {
+		ts = strings.Repeat("0", 8-len(ts)) + ts
+	}
+	return "00000000-0000-0000-0000-0000" + ts
+}
+
+// LegacyTimestampPath converts a path of KiCad 5 timestamps, such as the
+// path of a symbol within the sheet hierarchy, into a path of UUIDs. Parts
+// of the path that are already UUIDs are left unchanged.
+func LegacyTimestampPath(path string) string {
+	parts := strings.Split(path, "/")
+	for i, part := range parts {
+		if isLegacyTimestamp(part) {
+			parts[i] = LegacyTimestampUUID(part)
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+func isLegacyTimestamp(s string) bool {
+	if s == "" || len(s) > 8 {
+		return false
+	}
+	for _, c := range s {
+		if !strings.ContainsRune("0123456789abcdefABCDEF", c) {
+			return false
+		}
+	}
+	return true
+}
+
+// Upgrade converts a board from an older version of KiCad to the current
+// format version, PCBVersion, so that its fields are used in the same way
+// as those of a board saved by the current version of KiCad.
+//
+// KiCad 6 moved net classes and some design rules from the board into the
+// project file. If project is not nil, Upgrade moves them into the given
+// project, discarding any of those settings that Project does not model.
+// If project is nil they are left on the board, where KiCad will find them
+// and move them itself when it next loads the board.
+//
+// Upgrade does nothing to a board that is already in the current format.
+func (p *PCB) Upgrade(project *Project) {
+	if p.Version >= PCBVersion {
+		return
+	}
+
+	if p.Version < PCBVersionKiCad6 {
+		if p.Generator == "" {
+			p.Generator = p.Host.Program
+		}
+		p.Host = PCBHost{}
+		p.General = PCBGeneral{Thickness: p.General.Thickness}
+		p.eachID(func(uuid, tstamp *string) {
+			if isLegacyTimestamp(*tstamp) {
+				*tstamp = LegacyTimestampUUID(*tstamp)
+			}
+		})
+		p.graphics().upgradeLegacy()
+		if project != nil {
+			p.upgradeProjectSettings(project)
+		}
+	}
+	if p.Version < PCBVersionKiCad7 {
+		p.graphics().upgradeStrokes()
+	}
+	if p.Version < PCBVersionKiCad8 {
+		p.eachID(func(uuid, tstamp *string) {
+			if *uuid == "" {
+				*uuid = *tstamp
+			}
+			*tstamp = ""
+		})
+		for i := range p.Groups {
+			g := &p.Groups[i]
+			if g.UUID == "" {
+				g.UUID = g.ID
+			}
+			g.ID = ""
+		}
+		p.graphics().convertFills("yes", "no")
+	}
+
+	for i := range p.Footprints {
+		upgradeFootprint(&p.Footprints[i], p.Version)
+	}
+	p.Version = PCBVersion
+}
+
+// Upgrade converts a standalone footprint from an older version of KiCad
+// to the current format version, PCBVersion, in the same way as
+// PCB.Upgrade does for the footprints on a board. A footprint whose
+// Version is zero is assumed to be in the current format already.
+func (f *Footprint) Upgrade() {
+	if f.Version == 0 || f.Version >= PCBVersion {
+		return
+	}
+	f.eachID(func(uuid, tstamp *string) {
+		if f.Version < PCBVersionKiCad6 && isLegacyTimestamp(*tstamp) {
+			*tstamp = LegacyTimestampUUID(*tstamp)
+		}
+		if f.Version < PCBVersionKiCad8 {
+			if *uuid == "" {
+				*uuid = *tstamp
+			}
+			*tstamp = ""
+		}
+	})
+	upgradeFootprint(f, f.Version)
+	f.Version = PCBVersion
+}
+
+// upgradeFootprint makes the changes that Upgrade makes to a footprint
+// from the given format version, other than to the identifiers of its
+// items, which the caller converts along with those of the rest of the
+// document.
+func upgradeFootprint(fp *Footprint, version int) {
+	if version < PCBVersionKiCad6 {
+		fp.Path = LegacyTimestampPath(fp.Path)
+		switch fp.Attr.Type {
+		case "":
+			fp.Attr.Type = "through_hole"
+		case "virtual":
+			fp.Attr.Type = ""
+			fp.Attr.BoardOnly = true
+			fp.Attr.ExcludeFromPosFiles = true
+			fp.Attr.ExcludeFromBOM = true
+		}
+		for i := range fp.Models {
+			m := &fp.Models[i]
+			if m.At != (ModelVector{}) && m.Offset == (ModelVector{}) {
+				// KiCad 5 gives this offset in inches
+				m.Offset.XYZ = Vector3{
+					X: roundNM(m.At.XYZ.X * 25.4),
+					Y: roundNM(m.At.XYZ.Y * 25.4),
+					Z: roundNM(m.At.XYZ.Z * 25.4),
+				}
+			}
+			m.At = ModelVector{}
+		}
+		fp.graphics().upgradeLegacy()
+		for i := range fp.Pads {
+			fp.Pads[i].Primitives.graphics().upgradeLegacy()
+		}
+	}
+	if version < PCBVersionKiCad7 {
+		fp.graphics().upgradeStrokes()
+	}
+	if version < PCBVersionKiCad8 {
+		fp.TEdit = ""
+		fp.graphics().convertFills("yes", "no")
+		for i := range fp.Pads {
+			fp.Pads[i].Primitives.graphics().convertFills("yes", "no")
+		}
+		upgradeFootprintFields(fp)
+	}
+}
+
+// upgradeFootprintFields converts the reference and value texts of a
+// footprint into the properties that KiCad 8 uses instead, and moves the
+// sheet name and file from the properties where KiCad 6 and 7 store them
+// into their own fields.
+func upgradeFootprintFields(fp *Footprint) {
+	var fields []FootprintProperty
+	var texts []FootprintText
+	for _, text := range fp.Texts {
+		var name string
+		switch text.Type {
+		case "reference":
+			name = "Reference"
+		case "value":
+			name = "Value"
+		}
+		if name == "" {
+			texts = append(texts, text)
+			continue
+		}
+		at := text.At
+		at.Unlocked = false
+		fields = append(fields, FootprintProperty{
+			Name:     name,
+			Value:    text.Text,
+			At:       at,
+			Unlocked: text.At.Unlocked,
+			Layer:    text.Layer,
+			Hide:     text.Hide,
+			UUID:     text.UUID,
+			Effects:  text.Effects,
+		})
+	}
+	fp.Texts = texts
+
+	var props []FootprintProperty
+	for _, prop := range fp.Properties {
+		switch prop.Name {
+		case "Sheetname":
+			fp.SheetName = prop.Value
+		case "Sheetfile":
+			fp.SheetFile = prop.Value
+		default:
+			props = append(props, prop)
+		}
+	}
+	fp.Properties = append(fields, props...)
+}
+
+// upgradeProjectSettings moves the net classes and design rules of a
+// KiCad 5 board into the given project.
+func (p *PCB) upgradeProjectSettings(project *Project) {
+	settings := &project.NetSettings
+	for _, nc := range p.NetClasses {
+		c := settings.netClass(nc.Name)
+		c.Clearance = nc.Clearance
+		c.TrackWidth = nc.TraceWidth
+		c.ViaDiameter = nc.ViaDiameter
+		c.ViaDrill = nc.ViaDrill
+		c.MicroviaDiameter = nc.MicroViaDiameter
+		c.MicroviaDrill = nc.MicroViaDrill
+		c.DiffPairWidth = nc.DiffPairWidth
+		c.DiffPairGap = nc.DiffPairGap
+
+		// Nets not assigned to any other class belong to the default
+		// class, so there's no need to list its nets.
+		if nc.Name == "Default" {
+			continue
+		}
+		for _, net := range nc.Nets {
+			settings.NetClassPatterns = append(settings.NetClassPatterns, ProjectNetClassPattern{
+				NetClass: nc.Name,
+				Pattern:  net,
+			})
+		}
+	}
+	p.NetClasses = nil
+
+	design := &project.Board.DesignSettings
+	s := &p.Setup
+	if s.TraceMin != 0 {
+		design.Rules.MinTrackWidth = s.TraceMin
+	}
+	if s.ViaMinSize != 0 {
+		design.Rules.MinViaDiameter = s.ViaMinSize
+	}
+	if s.ViaMinDrill != 0 {
+		design.Rules.MinThroughHoleDiameter = s.ViaMinDrill
+	}
+	if s.UViaMinSize != 0 {
+		design.Rules.MinMicroviaDiameter = s.UViaMinSize
+	}
+	if s.UViaMinDrill != 0 {
+		design.Rules.MinMicroviaDrill = s.UViaMinDrill
+	}
+	if s.UViasAllowed {
+		design.Rules.AllowMicrovias = true
+	}
+	if len(s.UserTraceWidth) > 0 {
+		// The first entry stands for the net class's own track width.
+		design.TrackWidths = append([]float64{0}, s.UserTraceWidth...)
+	}
+
+	p.Setup = PCBSetup{
+		Stackup:                            s.Stackup,
+		PadToMaskClearance:                 s.PadToMaskClearance,
+		SolderMaskMinWidth:                 s.SolderMaskMinWidth,
+		PadToPasteClearance:                s.PadToPasteClearance,
+		PadToPasteClearanceRatio:           s.PadToPasteClearanceRatio,
+		AllowSolderMaskBridgesInFootprints: s.AllowSolderMaskBridgesInFootprints,
+		AuxAxisOrigin:                      s.AuxAxisOrigin,
+		GridOrigin:                         s.GridOrigin,
+		PlotParams:                         s.PlotParams,
+	}
+}
+
+// netClass returns the net class with the given name, adding a new one if
+// there is no such class.
+func (s *ProjectNetSettings) netClass(name string) *ProjectNetClass {
+	for i := range s.Classes {
+		if s.Classes[i].Name == name {
+			return &s.Classes[i]
+		}
+	}
+	s.Classes = append(s.Classes, ProjectNetClass{Name: name})
+	return &s.Classes[len(s.Classes)-1]
+}
+
+// Downgrade converts a board to the given older format version, so that
+// it can be written for an older version of KiCad. Only the format
+// versions of KiCad 6 and later are supported.
+//
+// Downgrade returns an error, leaving the board unchanged, if the board
+// uses a feature that the older version has no equivalent for, such as
+// the text boxes introduced in KiCad 7. Other information that the older
+// version cannot represent, such as the line styles of graphic items, is
+// discarded. A board in a format older than KiCad 6 must be upgraded with
+// Upgrade first.
+func (p *PCB) Downgrade(version int) error {
+	if version < PCBVersionKiCad6 {
+		return fmt.Errorf("cannot downgrade to format version %d: versions older than KiCad 6 (%d) are not supported", version, PCBVersionKiCad6)
+	}
+	if p.Version < PCBVersionKiCad6 {
+		return fmt.Errorf("cannot downgrade a board in format version %d: upgrade it first", p.Version)
+	}
+	if version >= p.Version {
+		return nil
+	}
+
+	if version < PCBVersionKiCad8 && len(p.Images) > 0 {
+		return fmt.Errorf("cannot downgrade to format version %d: board has images, which require KiCad 8", version)
+	}
+	if version < PCBVersionKiCad7 {
+		if len(p.TextBoxes) > 0 {
+			return fmt.Errorf("cannot downgrade to format version %d: board has text boxes, which require KiCad 7", version)
+		}
+		hasArcs := p.graphics().hasPolyArcs()
+		for i := range p.Footprints {
+			hasArcs = hasArcs || p.Footprints[i].graphics().hasPolyArcs()
+		}
+		if hasArcs {
+			return fmt.Errorf("cannot downgrade to format version %d: board has polygons with arcs, which require KiCad 7", version)
+		}
+	}
+
+	if version < PCBVersionKiCad8 {
+		p.GeneratorVersion = ""
+		p.General.LegacyTeardrops = false
+		for i := range p.Footprints {
+			fp := &p.Footprints[i]
+			fp.Attr.DNP = false
+			downgradeFootprintFields(fp)
+			fp.graphics().convertFills("solid", "none")
+			for j := range fp.Pads {
+				fp.Pads[j].Primitives.graphics().convertFills("solid", "none")
+			}
+		}
+		p.graphics().convertFills("solid", "none")
+		p.eachID(func(uuid, tstamp *string) {
+			if *tstamp == "" {
+				*tstamp = *uuid
+			}
+			*uuid = ""
+		})
+		for i := range p.Groups {
+			g := &p.Groups[i]
+			if g.ID == "" {
+				g.ID = g.UUID
+			}
+			g.UUID = ""
+		}
+	}
+	if version < PCBVersionKiCad7 {
+		p.Setup.AllowSolderMaskBridgesInFootprints = false
+		p.graphics().downgradeStrokes()
+		for i := range p.Texts {
+			p.Texts[i].Layer.Knockout = false
+		}
+		for i := range p.Dimensions {
+			p.Dimensions[i].Text.Layer.Knockout = false
+		}
+		for i := range p.Footprints {
+			fp := &p.Footprints[i]
+			fp.Attr.AllowMissingCourtyard = false
+			fp.Attr.AllowSolderMaskBridges = false
+			fp.graphics().downgradeStrokes()
+			for j := range fp.Texts {
+				fp.Texts[j].Layer.Knockout = false
+			}
+			for j := range fp.Pads {
+				fp.Pads[j].Primitives.graphics().downgradeStrokes()
+			}
+		}
+	}
+
+	p.Version = version
+	return nil
+}
+
+// downgradeFootprintFields reverses upgradeFootprintFields, converting the
+// reference and value properties of a footprint back into texts and
+// reducing its other properties to the name and value that KiCad 6 and 7
+// support.
+func downgradeFootprintFields(fp *Footprint) {
+	var texts []FootprintText
+	var props []FootprintProperty
+	for _, prop := range fp.Properties {
+		switch prop.Name {
+		case "Reference", "Value":
+			at := prop.At
+			at.Unlocked = prop.Unlocked
+			texts = append(texts, FootprintText{
+				Type:    strings.ToLower(prop.Name),
+				Text:    prop.Value,
+				At:      at,
+				Layer:   prop.Layer,
+				Hide:    prop.Hide,
+				Effects: prop.Effects,
+				UUID:    prop.UUID,
+			})
+		default:
+			props = append(props, FootprintProperty{Name: prop.Name, Value: prop.Value})
+		}
+	}
+	if fp.SheetName != "" {
+		props = append(props, FootprintProperty{Name: "Sheetname", Value: fp.SheetName})
+	}
+	if fp.SheetFile != "" {
+		props = append(props, FootprintProperty{Name: "Sheetfile", Value: fp.SheetFile})
+	}
+	fp.SheetName = ""
+	fp.SheetFile = ""
+	fp.Texts = append(texts, fp.Texts...)
+	fp.Properties = props
+}
+
+// eachID calls fn with the UUID and timestamp fields of each item on the
+// board, including the items within its footprints. Items from KiCad 8
+// onwards are identified by their UUID, while earlier versions use the
+// timestamp field instead.
+func (p *PCB) eachID(fn func(uuid, tstamp *string)) {
+	for i := range p.Footprints {
+		p.Footprints[i].eachID(fn)
+	}
+	for i := range p.Segments {
+		fn(&p.Segments[i].UUID, &p.Segments[i].TStamp)
+	}
+	for i := range p.Arcs {
+		fn(&p.Arcs[i].UUID, &p.Arcs[i].TStamp)
+	}
+	for i := range p.Vias {
+		fn(&p.Vias[i].UUID, &p.Vias[i].TStamp)
+	}
+	for i := range p.Zones {
+		fn(&p.Zones[i].UUID, &p.Zones[i].TStamp)
+	}
+	for i := range p.Texts {
+		fn(&p.Texts[i].UUID, &p.Texts[i].TStamp)
+	}
+	for i := range p.TextBoxes {
+		fn(&p.TextBoxes[i].UUID, &p.TextBoxes[i].TStamp)
+	}
+	for i := range p.Dimensions {
+		d := &p.Dimensions[i]
+		fn(&d.UUID, &d.TStamp)
+		fn(&d.Text.UUID, &d.Text.TStamp)
+	}
+	p.graphics().eachID(fn)
+}
+
+// eachID calls fn with the UUID and timestamp fields of the footprint and
+// of each item within it.
+func (f *Footprint) eachID(fn func(uuid, tstamp *string)) {
+	fn(&f.UUID, &f.TStamp)
+	for i := range f.Texts {
+		fn(&f.Texts[i].UUID, &f.Texts[i].TStamp)
+	}
+	for i := range f.Pads {
+		fn(&f.Pads[i].UUID, &f.Pads[i].TStamp)
+		f.Pads[i].Primitives.graphics().eachID(fn)
+	}
+	for i := range f.Zones {
+		fn(&f.Zones[i].UUID, &f.Zones[i].TStamp)
+	}
+	f.graphics().eachID(fn)
+}
+
+// graphicSet refers to the graphic items of a board, a footprint or a
+// custom pad, so that the conversions between format versions can treat
+// them all alike.
+type graphicSet struct {
+	Lines   []GraphicLine
+	Arcs    []GraphicArc
+	Circles []GraphicCircle
+	Rects   []GraphicRect
+	Polys   []GraphicPoly
+	Curves  []GraphicCurve
+}
+
+func (p *PCB) graphics() graphicSet {
+	return graphicSet{p.GraphicLines, p.GraphicArcs, p.GraphicCircles, p.GraphicRects, p.GraphicPolys, p.GraphicCurves}
+}
+
+func (f *Footprint) graphics() graphicSet {
+	return graphicSet{f.Lines, f.Arcs, f.Circles, f.Rects, f.Polys, f.Curves}
+}
+
+func (p *PadPrimitives) graphics() graphicSet {
+	return graphicSet{p.Lines, p.Arcs, p.Circles, p.Rects, p.Polys, p.Curves}
+}
+
+func (g graphicSet) eachID(fn func(uuid, tstamp *string)) {
+	for i := range g.Lines {
+		fn(&g.Lines[i].UUID, &g.Lines[i].TStamp)
+	}
+	for i := range g.Arcs {
+		fn(&g.Arcs[i].UUID, &g.Arcs[i].TStamp)
+	}
+	for i := range g.Circles {
+		fn(&g.Circles[i].UUID, &g.Circles[i].TStamp)
+	}
+	for i := range g.Rects {
+		fn(&g.Rects[i].UUID, &g.Rects[i].TStamp)
+	}
+	for i := range g.Polys {
+		fn(&g.Polys[i].UUID, &g.Polys[i].TStamp)
+	}
+	for i := range g.Curves {
+		fn(&g.Curves[i].UUID, &g.Curves[i].TStamp)
+	}
+}
+
+// upgradeLegacy converts graphic items from the form that KiCad 5 uses.
+// Arcs given by their center and sweep angle are converted into arcs
+// through three points, and polygons, which KiCad 5 always fills, are
+// marked as filled.
+func (g graphicSet) upgradeLegacy() {
+	for i := range g.Arcs {
+		a := &g.Arcs[i]
+		if a.Angle == 0 {
+			continue
+		}
+		center, start := a.Start, a.End
+		a.Start = start
+		a.Mid = rotateAround(start, center, a.Angle/2)
+		a.End = rotateAround(start, center, a.Angle)
+		a.Angle = 0
+	}
+	for i := range g.Polys {
+		if g.Polys[i].Fill == "" {
+			g.Polys[i].Fill = "solid"
+		}
+	}
+}
+
+// upgradeStrokes moves the line widths of graphic items into the strokes
+// that KiCad 7 introduced.
+func (g graphicSet) upgradeStrokes() {
+	upgrade := func(width *float64, stroke *Stroke) {
+		if *stroke == (Stroke{}) {
+			*stroke = Stroke{Width: *width, Type: "solid"}
+		}
+		*width = 0
+	}
+	for i := range g.Lines {
+		upgrade(&g.Lines[i].Width, &g.Lines[i].Stroke)
+	}
+	for i := range g.Arcs {
+		upgrade(&g.Arcs[i].Width, &g.Arcs[i].Stroke)
+	}
+	for i := range g.Circles {
+		upgrade(&g.Circles[i].Width, &g.Circles[i].Stroke)
+	}
+	for i := range g.Rects {
+		upgrade(&g.Rects[i].Width, &g.Rects[i].Stroke)
+	}
+	for i := range g.Polys {
+		upgrade(&g.Polys[i].Width, &g.Polys[i].Stroke)
+	}
+	for i := range g.Curves {
+		upgrade(&g.Curves[i].Width, &g.Curves[i].Stroke)
+	}
+}
+
+// downgradeStrokes reverses upgradeStrokes, discarding the line style and
+// color of each stroke.
+func (g graphicSet) downgradeStrokes() {
+	downgrade := func(width *float64, stroke *Stroke) {
+		if *width == 0 {
+			*width = stroke.Width
+		}
+		*stroke = Stroke{}
+	}
+	for i := range g.Lines {
+		downgrade(&g.Lines[i].Width, &g.Lines[i].Stroke)
+	}
+	for i := range g.Arcs {
+		downgrade(&g.Arcs[i].Width, &g.Arcs[i].Stroke)
+	}
+	for i := range g.Circles {
+		downgrade(&g.Circles[i].Width, &g.Circles[i].Stroke)
+	}
+	for i := range g.Rects {
+		downgrade(&g.Rects[i].Width, &g.Rects[i].Stroke)
+	}
+	for i := range g.Polys {
+		downgrade(&g.Polys[i].Width, &g.Polys[i].Stroke)
+	}
+	for i := range g.Curves {
+		downgrade(&g.Curves[i].Width, &g.Curves[i].Stroke)
+	}
+}
+
+// convertFills rewrites the fill of each filled shape using the given
+// keywords for filled and unfilled shapes, which are "yes" and "no" from
+// KiCad 8 onwards and "solid" and "none" in earlier versions.
+func (g graphicSet) convertFills(solid, none string) {
+	convert := func(fill *string) {
+		switch {
+		case *fill == "":
+			// Leave the default in place
+		case fillIsSolid(*fill):
+			*fill = solid
+		default:
+			*fill = none
+		}
+	}
+	for i := range g.Circles {
+		convert(&g.Circles[i].Fill)
+	}
+	for i := range g.Rects {
+		convert(&g.Rects[i].Fill)
+	}
+	for i := range g.Polys {
+		convert(&g.Polys[i].Fill)
+	}
+}
+
+func (g graphicSet) hasPolyArcs() bool {
+	for i := range g.Polys {
+		if g.Polys[i].Points.HasArcs() {
+			return true
+		}
+	}
+	return false
+}
+
+// rotateAround rotates p around center by the given angle in degrees,
+// which is clockwise as seen on screen.
+func rotateAround(p, center Position, degrees float64) Position {
+	sin, cos := math.Sincos(degrees * math.Pi / 180)
+	dx, dy := p.X-center.X, p.Y-center.Y
+	return Position{
+		X: roundNM(center.X + dx*cos - dy*sin),
+		Y: roundNM(center.Y + dx*sin + dy*cos),
+	}
+}
+
+// roundNM rounds a length in millimeters to KiCad's nanometer resolution,
+// to avoid noise from conversions.
+func roundNM(v float64) float64 {
+	return math.Round(v*1e6) / 1e6
+}
@@ -0,0 +1,617 @@
+package kicad
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/davecgh/go-spew/spew"
+)
+
+func TestPCBUpgrade_kicad5(t *testing.T) {
+	src := `(kicad_pcb (version 20171130) (host pcbnew "(5.1.9)-1")
+  (general
+    (thickness 1.6)
+    (drawings 1)
+    (tracks 1)
+    (modules 1)
+    (nets 3)
+  )
+  (page A4)
+  (layers
+    (0 F.Cu signal)
+    (31 B.Cu signal)
+  )
+  (setup
+    (last_trace_width 0.25)
+    (user_trace_width 0.5)
+    (user_trace_width 1)
+    (trace_clearance 0.2)
+    (trace_min 0.2)
+    (via_min_size 0.4)
+    (via_min_drill 0.3)
+    (uvias_allowed yes)
+    (pad_to_mask_clearance 0.05)
+    (pcbplotparams
+      (usegerberextensions false)
+      (mirror false))
+  )
+  (net 0 "")
+  (net 1 GND)
+  (net 2 +5V)
+  (net_class Default "This is the default net class."
+    (clearance 0.2)
+    (trace_width 0.25)
+    (via_dia 0.8)
+    (via_drill 0.4)
+    (uvia_dia 0.3)
+    (uvia_drill 0.1)
+    (add_net GND)
+  )
+  (net_class Power ""
+    (clearance 0.3)
+    (trace_width 0.5)
+    (add_net +5V)
+  )
+  (module Resistor_SMD:R_0603 (layer F.Cu) (tedit 5F68FEEE) (tstamp 5E8F4A1B)
+    (at 100 50 90)
+    (path /5E8F4A00)
+    (attr virtual)
+    (fp_text reference R1 (at 0 -1.5 90) (layer F.SilkS)
+      (effects (font (size 1 1) (thickness 0.15)))
+    )
+    (fp_text value 10k (at 0 1.5 90) (layer F.Fab) hide
+      (effects (font (size 1 1) (thickness 0.15)))
+    )
+    (fp_line (start -1 -1) (end 1 -1) (layer F.SilkS) (width 0.12))
+    (fp_arc (start 0 0) (end 1 0) (angle 90) (layer F.Fab) (width 0.1))
+    (pad 1 smd rect (at -0.8 0 90) (size 0.8 0.9) (layers F.Cu F.Paste F.Mask)
+      (net 1 GND))
+    (model ${KISYS3DMOD}/R_0603.wrl
+      (at (xyz 0.1 0 0))
+      (scale (xyz 1 1 1))
+      (rotate (xyz 0 0 0))
+    )
+  )
+  (gr_line (start 0 0) (end 10 0) (layer Edge.Cuts) (width 0.05) (tstamp 5E8F4B00))
+  (segment (start 1 1) (end 2 1) (width 0.25) (layer F.Cu) (net 1) (tstamp 5E8F4C00))
+)`
+	got, err := ReadPCB(strings.NewReader(src))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	gotProject := &Project{}
+	got.Upgrade(gotProject)
+
+	font := Font{Size: TextSize{Height: 1, Width: 1}, Thickness: 0.15}
+	want := &PCB{
+		Version:   PCBVersion,
+		Generator: "pcbnew",
+		General:   PCBGeneral{Thickness: 1.6},
+		Paper:     PaperSize{Name: "A4"},
+		Layers: []PCBLayer{
+			{Ordinal: 0, Name: "F.Cu", Type: "signal"},
+			{Ordinal: 31, Name: "B.Cu", Type: "signal"},
+		},
+		Setup: PCBSetup{PadToMaskClearance: 0.05},
+		Nets: []PCBNet{
+			{Number: 0, Name: ""},
+			{Number: 1, Name: "GND"},
+			{Number: 2, Name: "+5V"},
+		},
+		Footprints: []Footprint{
+			{
+				LibID: "Resistor_SMD:R_0603",
+				Layer: "F.Cu",
+				UUID:  "00000000-0000-0000-0000-00005e8f4a1b",
+				At:    PositionAngle{X: 100, Y: 50, Angle: 90},
+				Properties: []FootprintProperty{
+					{
+						Name:    "Reference",
+						Value:   "R1",
+						At:      PositionAngle{X: 0, Y: -1.5, Angle: 90},
+						Layer:   TextLayer{Name: "F.SilkS"},
+						Effects: TextEffects{Font: font},
+					},
+					{
+						Name:    "Value",
+						Value:   "10k",
+						At:      PositionAngle{X: 0, Y: 1.5, Angle: 90},
+						Layer:   TextLayer{Name: "F.Fab"},
+						Hide:    true,
+						Effects: TextEffects{Font: font},
+					},
+				},
+				Path: "/00000000-0000-0000-0000-00005e8f4a00",
+				Attr: FootprintAttr{
+					BoardOnly:           true,
+					ExcludeFromPosFiles: true,
+					ExcludeFromBOM:      true,
+				},
+				Lines: []GraphicLine{
+					{
+						Start:  Position{X: -1, Y: -1},
+						End:    Position{X: 1, Y: -1},
+						Layer:  "F.SilkS",
+						Stroke: Stroke{Width: 0.12, Type: "solid"},
+					},
+				},
+				Arcs: []GraphicArc{
+					{
+						Start:  Position{X: 1, Y: 0},
+						Mid:    Position{X: 0.707107, Y: 0.707107},
+						End:    Position{X: 0, Y: 1},
+						Layer:  "F.Fab",
+						Stroke: Stroke{Width: 0.1, Type: "solid"},
+					},
+				},
+				Pads: []Pad{
+					{
+						Number: "1",
+						Type:   "smd",
+						Shape:  "rect",
+						At:     PositionAngle{X: -0.8, Y: 0, Angle: 90},
+						Size:   Size{Width: 0.8, Height: 0.9},
+						Layers: []string{"F.Cu", "F.Paste", "F.Mask"},
+						Net:    PCBNet{Number: 1, Name: "GND"},
+					},
+				},
+				Models: []Model3D{
+					{
+						Path:   "${KISYS3DMOD}/R_0603.wrl",
+						Offset: ModelVector{XYZ: Vector3{X: 2.54}},
+						Scale:  ModelVector{XYZ: Vector3{X: 1, Y: 1, Z: 1}},
+					},
+				},
+			},
+		},
+		Segments: []Segment{
+			{
+				Start: Position{X: 1, Y: 1},
+				End:   Position{X: 2, Y: 1},
+				Width: 0.25,
+				Layer: "F.Cu",
+				Net:   1,
+				UUID:  "00000000-0000-0000-0000-00005e8f4c00",
+			},
+		},
+		GraphicLines: []GraphicLine{
+			{
+				End:    Position{X: 10, Y: 0},
+				Layer:  "Edge.Cuts",
+				Stroke: Stroke{Width: 0.05, Type: "solid"},
+				UUID:   "00000000-0000-0000-0000-00005e8f4b00",
+			},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("incorrect board\ngot:  %swant: %s", spew.Sdump(got), spew.Sdump(want))
+	}
+
+	wantProject := &Project{
+		Board: ProjectBoard{
+			DesignSettings: ProjectDesignSettings{
+				Rules: ProjectDesignRules{
+					MinTrackWidth:          0.2,
+					MinViaDiameter:         0.4,
+					MinThroughHoleDiameter: 0.3,
+					AllowMicrovias:         true,
+				},
+				TrackWidths: []float64{0, 0.5, 1},
+			},
+		},
+		NetSettings: ProjectNetSettings{
+			Classes: []ProjectNetClass{
+				{
+					Name:             "Default",
+					Clearance:        0.2,
+					TrackWidth:       0.25,
+					ViaDiameter:      0.8,
+					ViaDrill:         0.4,
+					MicroviaDiameter: 0.3,
+					MicroviaDrill:    0.1,
+				},
+				{
+					Name:       "Power",
+					Clearance:  0.3,
+					TrackWidth: 0.5,
+				},
+			},
+			NetClassPatterns: []ProjectNetClassPattern{
+				{NetClass: "Power", Pattern: "+5V"},
+			},
+		},
+	}
+	if !reflect.DeepEqual(gotProject, wantProject) {
+		t.Errorf("incorrect project\ngot:  %swant: %s", spew.Sdump(gotProject), spew.Sdump(wantProject))
+	}
+}
+
+func TestPCBUpgrade_kicad7(t *testing.T) {
+	src := `(kicad_pcb (version 20221018) (generator pcbnew)
+  (footprint "Connector:TestPoint" (layer "F.Cu")
+    (tstamp 4a1f0e2c-6c8a-4c61-9d3e-2f0a6b1c0001)
+    (at 10 20)
+    (property "Sheetfile" "board.kicad_sch")
+    (property "Sheetname" "")
+    (property "MPN" "5000")
+    (attr smd)
+    (fp_text reference "TP1" (at 0 -2 unlocked) (layer "F.SilkS")
+      (effects (font (size 1 1) (thickness 0.15)))
+      (tstamp 4a1f0e2c-6c8a-4c61-9d3e-2f0a6b1c0002)
+    )
+    (fp_text user "${REFERENCE}" (at 0 2) (layer "F.Fab")
+      (effects (font (size 1 1) (thickness 0.15)))
+      (tstamp 4a1f0e2c-6c8a-4c61-9d3e-2f0a6b1c0003)
+    )
+    (fp_circle (center 0 0) (end 1 0)
+      (stroke (width 0.1) (type solid)) (fill solid) (layer "F.Fab")
+      (tstamp 4a1f0e2c-6c8a-4c61-9d3e-2f0a6b1c0004))
+  )
+  (gr_rect (start 0 0) (end 5 5)
+    (stroke (width 0.1) (type dash)) (fill none) (layer "Cmts.User")
+    (tstamp 4a1f0e2c-6c8a-4c61-9d3e-2f0a6b1c0005))
+  (group "" (id 4a1f0e2c-6c8a-4c61-9d3e-2f0a6b1c0006)
+    (members 4a1f0e2c-6c8a-4c61-9d3e-2f0a6b1c0005)
+  )
+)`
+	got, err := ReadPCB(strings.NewReader(src))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	got.Upgrade(nil)
+
+	font := Font{Size: TextSize{Height: 1, Width: 1}, Thickness: 0.15}
+	want := &PCB{
+		Version:   PCBVersion,
+		Generator: "pcbnew",
+		Footprints: []Footprint{
+			{
+				LibID: "Connector:TestPoint",
+				Layer: "F.Cu",
+				UUID:  "4a1f0e2c-6c8a-4c61-9d3e-2f0a6b1c0001",
+				At:    PositionAngle{X: 10, Y: 20},
+				Properties: []FootprintProperty{
+					{
+						Name:     "Reference",
+						Value:    "TP1",
+						At:       PositionAngle{X: 0, Y: -2},
+						Unlocked: true,
+						Layer:    TextLayer{Name: "F.SilkS"},
+						UUID:     "4a1f0e2c-6c8a-4c61-9d3e-2f0a6b1c0002",
+						Effects:  TextEffects{Font: font},
+					},
+					{Name: "MPN", Value: "5000"},
+				},
+				SheetFile: "board.kicad_sch",
+				Attr:      FootprintAttr{Type: "smd"},
+				Texts: []FootprintText{
+					{
+						Type:    "user",
+						Text:    "${REFERENCE}",
+						At:      PositionAngle{X: 0, Y: 2},
+						Layer:   TextLayer{Name: "F.Fab"},
+						Effects: TextEffects{Font: font},
+						UUID:    "4a1f0e2c-6c8a-4c61-9d3e-2f0a6b1c0003",
+					},
+				},
+				Circles: []GraphicCircle{
+					{
+						Center: Position{X: 0, Y: 0},
+						End:    Position{X: 1, Y: 0},
+						Layer:  "F.Fab",
+						Stroke: Stroke{Width: 0.1, Type: "solid"},
+						Fill:   "yes",
+						UUID:   "4a1f0e2c-6c8a-4c61-9d3e-2f0a6b1c0004",
+					},
+				},
+			},
+		},
+		GraphicRects: []GraphicRect{
+			{
+				Start:  Position{X: 0, Y: 0},
+				End:    Position{X: 5, Y: 5},
+				Layer:  "Cmts.User",
+				Stroke: Stroke{Width: 0.1, Type: "dash"},
+				Fill:   "no",
+				UUID:   "4a1f0e2c-6c8a-4c61-9d3e-2f0a6b1c0005",
+			},
+		},
+		Groups: []Group{
+			{
+				UUID:    "4a1f0e2c-6c8a-4c61-9d3e-2f0a6b1c0006",
+				Members: []string{"4a1f0e2c-6c8a-4c61-9d3e-2f0a6b1c0005"},
+			},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("incorrect result\ngot:  %swant: %s", spew.Sdump(got), spew.Sdump(want))
+	}
+}
+
+func TestFootprintUpgrade(t *testing.T) {
+	src := `(module R_0603 (layer F.Cu) (tedit 5F68FEEE)
+  (fp_text reference REF** (at 0 -1.5) (layer F.SilkS)
+    (effects (font (size 1 1) (thickness 0.15)))
+  )
+  (fp_poly (pts (xy 0 0) (xy 1 0) (xy 1 1)) (layer F.Cu) (width 0))
+  (pad 1 smd rect (at -0.8 0) (size 0.8 0.9) (layers F.Cu F.Paste F.Mask))
+)`
+	got, err := ReadFootprint(strings.NewReader(src))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	got.Upgrade()
+
+	want := &Footprint{
+		LibID:   "R_0603",
+		Version: PCBVersion,
+		Layer:   "F.Cu",
+		Properties: []FootprintProperty{
+			{
+				Name:  "Reference",
+				Value: "REF**",
+				At:    PositionAngle{X: 0, Y: -1.5},
+				Layer: TextLayer{Name: "F.SilkS"},
+				Effects: TextEffects{
+					Font: Font{Size: TextSize{Height: 1, Width: 1}, Thickness: 0.15},
+				},
+			},
+		},
+		Attr: FootprintAttr{Type: "through_hole"},
+		Polys: []GraphicPoly{
+			{
+				Points: NewPoints(Position{X: 0, Y: 0}, Position{X: 1, Y: 0}, Position{X: 1, Y: 1}),
+				Layer:  "F.Cu",
+				Stroke: Stroke{Type: "solid"},
+				Fill:   "yes",
+			},
+		},
+		Pads: []Pad{
+			{
+				Number: "1",
+				Type:   "smd",
+				Shape:  "rect",
+				At:     PositionAngle{X: -0.8, Y: 0},
+				Size:   Size{Width: 0.8, Height: 0.9},
+				Layers: []string{"F.Cu", "F.Paste", "F.Mask"},
+			},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("incorrect result\ngot:  %swant: %s", spew.Sdump(got), spew.Sdump(want))
+	}
+}
+
+func TestPCBDowngrade(t *testing.T) {
+	src := `(kicad_pcb
+	(version 20240108)
+	(generator "pcbnew")
+	(generator_version "8.0")
+	(general
+		(thickness 1.6)
+		(legacy_teardrops no)
+	)
+	(paper "A4")
+	(layers
+		(0 "F.Cu" signal)
+		(31 "B.Cu" signal)
+	)
+	(setup
+		(pad_to_mask_clearance 0)
+		(allow_soldermask_bridges_in_footprints yes)
+		(pcbplotparams
+			(layerselection 0x00010fc_ffffffff)
+			(usegerberextensions yes)
+			(mirror no)
+			(outputdirectory "")
+		)
+	)
+	(net 0 "")
+	(net 1 "GND")
+	(footprint "Resistor_SMD:R_0603"
+		(layer "F.Cu")
+		(uuid "4a1f0e2c-6c8a-4c61-9d3e-2f0a6b1c0001")
+		(at 100 50)
+		(property "Reference" "R1"
+			(at 0 -1.5 0)
+			(layer "F.SilkS")
+			(uuid "4a1f0e2c-6c8a-4c61-9d3e-2f0a6b1c0002")
+			(effects
+				(font
+					(size 1 1)
+					(thickness 0.15)
+				)
+			)
+		)
+		(property "Value" "10k"
+			(at 0 1.5 0)
+			(layer "F.Fab")
+			(hide yes)
+			(uuid "4a1f0e2c-6c8a-4c61-9d3e-2f0a6b1c0003")
+			(effects
+				(font
+					(size 1 1)
+					(thickness 0.15)
+				)
+			)
+		)
+		(property "MPN" "RC0603FR-0710KL"
+			(at 0 0 0)
+			(layer "F.Fab")
+			(hide yes)
+			(uuid "4a1f0e2c-6c8a-4c61-9d3e-2f0a6b1c0004")
+		)
+		(sheetname "Root")
+		(sheetfile "board.kicad_sch")
+		(attr smd allow_missing_courtyard dnp)
+		(fp_rect
+			(start -1 -1)
+			(end 1 1)
+			(stroke
+				(width 0.1)
+				(type solid)
+			)
+			(fill no)
+			(layer "F.Fab")
+			(uuid "4a1f0e2c-6c8a-4c61-9d3e-2f0a6b1c0005")
+		)
+		(pad "1" smd rect
+			(at -0.8 0)
+			(size 0.8 0.9)
+			(layers "F.Cu" "F.Paste" "F.Mask")
+			(net 1 "GND")
+			(uuid "4a1f0e2c-6c8a-4c61-9d3e-2f0a6b1c0006")
+		)
+	)
+	(via
+		(at 5 5)
+		(size 0.6)
+		(drill 0.3)
+		(layers "F.Cu" "B.Cu")
+		(free yes)
+		(net 1)
+		(uuid "4a1f0e2c-6c8a-4c61-9d3e-2f0a6b1c0007")
+	)
+	(gr_line
+		(start 0 0)
+		(end 10 0)
+		(stroke
+			(width 0.05)
+			(type default)
+		)
+		(layer "Edge.Cuts")
+		(uuid "4a1f0e2c-6c8a-4c61-9d3e-2f0a6b1c0008")
+	)
+	(group ""
+		(uuid "4a1f0e2c-6c8a-4c61-9d3e-2f0a6b1c0009")
+		(members "4a1f0e2c-6c8a-4c61-9d3e-2f0a6b1c0008")
+	)
+)`
+	pcb, err := ReadPCB(strings.NewReader(src))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := pcb.Downgrade(PCBVersionKiCad6); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	var buf bytes.Buffer
+	if err := WritePCB(&buf, pcb); err != nil {
+		t.Fatalf("unexpected error writing: %s", err)
+	}
+	got := buf.String()
+	want := `(kicad_pcb
+  (version 20211014)
+  (generator pcbnew)
+  (general
+    (thickness 1.6))
+  (paper "A4")
+  (layers
+    (0 "F.Cu" signal)
+    (31 "B.Cu" signal))
+  (setup
+    (pcbplotparams
+      (layerselection 0x00010fc_ffffffff)
+      (usegerberextensions true)))
+  (net 0 "")
+  (net 1 "GND")
+  (footprint "Resistor_SMD:R_0603"
+    (layer "F.Cu")
+    (tstamp "4a1f0e2c-6c8a-4c61-9d3e-2f0a6b1c0001")
+    (at 100 50)
+    (property "MPN" "RC0603FR-0710KL")
+    (property "Sheetname" "Root")
+    (property "Sheetfile" "board.kicad_sch")
+    (attr smd)
+    (fp_text reference "R1"
+      (at 0 -1.5)
+      (layer "F.SilkS")
+      (effects
+        (font
+          (size 1 1)
+          (thickness 0.15)))
+      (tstamp "4a1f0e2c-6c8a-4c61-9d3e-2f0a6b1c0002"))
+    (fp_text value "10k"
+      (at 0 1.5)
+      (layer "F.Fab") hide
+      (effects
+        (font
+          (size 1 1)
+          (thickness 0.15)))
+      (tstamp "4a1f0e2c-6c8a-4c61-9d3e-2f0a6b1c0003"))
+    (fp_rect
+      (start -1 -1)
+      (end 1 1)
+      (layer "F.Fab")
+      (width 0.1)
+      (fill none)
+      (tstamp "4a1f0e2c-6c8a-4c61-9d3e-2f0a6b1c0005"))
+    (pad "1" smd rect
+      (at -0.8 0)
+      (size 0.8 0.9)
+      (layers "F.Cu" "F.Paste" "F.Mask")
+      (net 1 "GND")
+      (tstamp "4a1f0e2c-6c8a-4c61-9d3e-2f0a6b1c0006")))
+  (via
+    (at 5 5)
+    (size 0.6)
+    (drill 0.3)
+    (layers "F.Cu" "B.Cu")
+    (free)
+    (net 1)
+    (tstamp "4a1f0e2c-6c8a-4c61-9d3e-2f0a6b1c0007"))
+  (gr_line
+    (start 0 0)
+    (end 10 0)
+    (layer "Edge.Cuts")
+    (width 0.05)
+    (tstamp "4a1f0e2c-6c8a-4c61-9d3e-2f0a6b1c0008"))
+  (group ""
+    (id "4a1f0e2c-6c8a-4c61-9d3e-2f0a6b1c0009")
+    (members "4a1f0e2c-6c8a-4c61-9d3e-2f0a6b1c0008")))
+`
+	if got != want {
+		t.Errorf("incorrect output\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestPCBDowngrade_unsupported(t *testing.T) {
+	pcb := &PCB{
+		Version:   PCBVersionKiCad8,
+		TextBoxes: []TextBox{{Text: "hello", UUID: "4a1f0e2c-6c8a-4c61-9d3e-2f0a6b1c0001"}},
+	}
+	if err := pcb.Downgrade(PCBVersionKiCad5); err == nil {
+		t.Errorf("no error for downgrade to KiCad 5")
+	}
+	if err := pcb.Downgrade(PCBVersionKiCad6); err == nil {
+		t.Errorf("no error for downgrade of text box to KiCad 6")
+	}
+	if pcb.Version != PCBVersionKiCad8 || pcb.TextBoxes[0].TStamp != "" {
+		t.Errorf("board changed by failed downgrade:\n%s", spew.Sdump(pcb))
+	}
+	if err := pcb.Downgrade(PCBVersionKiCad7); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	if got, want := pcb.TextBoxes[0].TStamp, "4a1f0e2c-6c8a-4c61-9d3e-2f0a6b1c0001"; got != want {
+		t.Errorf("wrong text box tstamp %q; want %q", got, want)
+	}
+}
+
+func TestKiCadVersion(t *testing.T) {
+	tests := map[int]int{
+		4:        5,
+		20171130: 5,
+		20200829: 6,
+		20211014: 6,
+		20221018: 7,
+		20240108: 8,
+		20241229: 0,
+	}
+	for formatVersion, want := range tests {
+		if got := KiCadVersion(formatVersion); got != want {
+			t.Errorf("wrong result for %d: got %d, want %d", formatVersion, got, want)
+		}
+	}
+}
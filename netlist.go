@@ -0,0 +1,292 @@
+package kicad
+
+import (
+	"io"
+	"os"
+
+	"github.com/apparentlymart/go-kicad/sexp"
+)
+
+// ReadNetlist reads a stream containing a netlist in KiCad's own
+// s-expression netlist format, as exported by eeschema, and returns a
+// Netlist structure describing it.
+func ReadNetlist(r io.Reader) (*Netlist, error) {
+	n := &Netlist{}
+	err := sexp.Decode(r, "export", n)
+	return n, err
+}
+
+// ReadNetlistFile is a convenience wrapper around ReadNetlist that takes a
+// filename and opens the given file for reading before calling ReadNetlist.
+func ReadNetlistFile(filename string) (*Netlist, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ReadNetlist(f)
+}
+
+// WriteNetlist writes the given netlist to the given writer in KiCad's own
+// s-expression netlist format.
+func WriteNetlist(w io.Writer, n *Netlist) error {
+	return sexp.Encode(w, "export", n)
+}
+
+// WriteNetlistFile is a convenience wrapper around WriteNetlist that creates
+// or replaces the given file before calling WriteNetlist.
+func WriteNetlistFile(filename string, n *Netlist) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+
+	err = WriteNetlist(f, n)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Netlist represents a netlist exported from a schematic, which lists the
+// components of a design and the nets that connect their pins.
+//
+// Version is "D" for netlists from KiCad 5 and "E" for later versions.
+type Netlist struct {
+	Version    string           `kicad:"version"`
+	Design     NetlistDesign    `kicad:"design,flat"`
+	Components NetlistComps     `kicad:"components,flat"`
+	LibParts   NetlistLibParts  `kicad:"libparts,flat"`
+	Libraries  NetlistLibraries `kicad:"libraries,flat"`
+	Nets       NetlistNets      `kicad:"nets,flat"`
+}
+
+// Component returns the component with the given reference designator, or
+// nil if there is no such component.
+func (n *Netlist) Component(ref string) *NetlistComp {
+	for i := range n.Components.Comps {
+		if n.Components.Comps[i].Ref == ref {
+			return &n.Components.Comps[i]
+		}
+	}
+	return nil
+}
+
+// Net returns the net with the given name, or nil if there is no such net.
+func (n *Netlist) Net(name string) *NetlistNet {
+	for i := range n.Nets.Nets {
+		if n.Nets.Nets[i].Name == name {
+			return &n.Nets.Nets[i]
+		}
+	}
+	return nil
+}
+
+// LibPart returns the library part with the given library and part name,
+// or nil if there is no such part.
+func (n *Netlist) LibPart(lib, part string) *NetlistLibPart {
+	for i := range n.LibParts.Parts {
+		p := &n.LibParts.Parts[i]
+		if p.Lib == lib && p.Part == part {
+			return p
+		}
+	}
+	return nil
+}
+
+// NetlistDesign describes the schematic that a netlist was exported from.
+type NetlistDesign struct {
+	Source string         `kicad:"source"`
+	Date   string         `kicad:"date"`
+	Tool   string         `kicad:"tool"`
+	Sheets []NetlistSheet `kicad:"sheet,multi,flat"`
+}
+
+// NetlistSheet describes one sheet instance of the schematic. Name and
+// TStamps are the sheet's path, given as sheet names and as sheet
+// timestamps or UUIDs respectively.
+type NetlistSheet struct {
+	Number     int               `kicad:"number"`
+	Name       string            `kicad:"name"`
+	TStamps    string            `kicad:"tstamps"`
+	TitleBlock NetlistTitleBlock `kicad:"title_block,flat"`
+}
+
+// NetlistTitleBlock is the title block of a sheet of the schematic.
+type NetlistTitleBlock struct {
+	Title    string           `kicad:"title"`
+	Company  string           `kicad:"company"`
+	Rev      string           `kicad:"rev"`
+	Date     string           `kicad:"date"`
+	Source   string           `kicad:"source"`
+	Comments []NetlistComment `kicad:"comment,multi,flat"`
+}
+
+// NetlistComment is one of the numbered comments of a title block.
+type NetlistComment struct {
+	Number int    `kicad:"number"`
+	Value  string `kicad:"value"`
+}
+
+// NetlistComps is the list of components in a netlist.
+type NetlistComps struct {
+	Comps []NetlistComp `kicad:"comp,multi,flat"`
+}
+
+// NetlistComp is a component of the design, which combines all of the
+// units of a symbol with the same reference designator.
+//
+// TStamps holds the UUIDs of the symbol instances that make up the
+// component, or the single timestamp of the symbol in KiCad 5 netlists.
+type NetlistComp struct {
+	Ref         string            `kicad:"ref"`
+	Value       string            `kicad:"value"`
+	Footprint   string            `kicad:"footprint"`
+	Datasheet   string            `kicad:"datasheet"`
+	Description string            `kicad:"description"`
+	Fields      NetlistFields     `kicad:"fields,flat"`
+	LibSource   NetlistLibSource  `kicad:"libsource,flat"`
+	Properties  []NetlistProperty `kicad:"property,multi,flat"`
+	SheetPath   NetlistSheetPath  `kicad:"sheetpath,flat"`
+	TStamps     []string          `kicad:"tstamps|tstamp,flat"`
+}
+
+// Field returns the value of the field with the given name, or the empty
+// string if there is no such field.
+func (c *NetlistComp) Field(name string) string {
+	return c.Fields.Get(name)
+}
+
+// Property returns the value of the property with the given name, or the
+// empty string if there is no such property.
+func (c *NetlistComp) Property(name string) string {
+	for _, prop := range c.Properties {
+		if prop.Name == name {
+			return prop.Value
+		}
+	}
+	return ""
+}
+
+// NetlistFields is a list of named fields of a component or library part.
+type NetlistFields struct {
+	Fields []NetlistField `kicad:"field,multi,flat"`
+}
+
+// Get returns the value of the field with the given name, or the empty
+// string if there is no such field.
+func (f *NetlistFields) Get(name string) string {
+	for _, field := range f.Fields {
+		if field.Name == name {
+			return field.Value
+		}
+	}
+	return ""
+}
+
+// NetlistField is a named field, written as (field (name "MPN") "1234").
+type NetlistField struct {
+	Name  string `kicad:"name"`
+	Value string `kicad:",trailing"`
+}
+
+// NetlistLibSource identifies the library symbol that a component was
+// placed from.
+type NetlistLibSource struct {
+	Lib         string `kicad:"lib"`
+	Part        string `kicad:"part"`
+	Description string `kicad:"description"`
+}
+
+// NetlistProperty is a property of a component, such as the name and file
+// of the sheet that contains it.
+type NetlistProperty struct {
+	Name  string `kicad:"name"`
+	Value string `kicad:"value"`
+}
+
+// NetlistSheetPath is the path of the sheet containing a component, given
+// as sheet names and as sheet timestamps or UUIDs.
+type NetlistSheetPath struct {
+	Names   string `kicad:"names"`
+	TStamps string `kicad:"tstamps"`
+}
+
+// NetlistLibParts is the list of library parts used by the components of a
+// netlist.
+type NetlistLibParts struct {
+	Parts []NetlistLibPart `kicad:"libpart,multi,flat"`
+}
+
+// NetlistLibPart describes a library symbol used in the design. Aliases
+// appear only in KiCad 5 netlists.
+type NetlistLibPart struct {
+	Lib         string            `kicad:"lib"`
+	Part        string            `kicad:"part"`
+	Description string            `kicad:"description"`
+	Docs        string            `kicad:"docs"`
+	Aliases     NetlistAliases    `kicad:"aliases,flat"`
+	Footprints  NetlistFootprints `kicad:"footprints,flat"`
+	Fields      NetlistFields     `kicad:"fields,flat"`
+	Pins        NetlistPins       `kicad:"pins,flat"`
+}
+
+// NetlistAliases is the list of alias names of a library part.
+type NetlistAliases struct {
+	Aliases []string `kicad:"alias,multi"`
+}
+
+// NetlistFootprints is the list of footprint filters of a library part.
+type NetlistFootprints struct {
+	Filters []string `kicad:"fp,multi"`
+}
+
+// NetlistPins is the list of pins of a library part.
+type NetlistPins struct {
+	Pins []NetlistPin `kicad:"pin,multi,flat"`
+}
+
+// NetlistPin is a pin of a library part. Type is the electrical type of the
+// pin, as described for Pin.
+type NetlistPin struct {
+	Num  string `kicad:"num"`
+	Name string `kicad:"name"`
+	Type string `kicad:"type"`
+}
+
+// NetlistLibraries is the list of symbol libraries used by the design.
+type NetlistLibraries struct {
+	Libs []NetlistLibrary `kicad:"library,multi,flat"`
+}
+
+// NetlistLibrary is a symbol library, identified by its nickname in the
+// symbol library table.
+type NetlistLibrary struct {
+	Logical string `kicad:"logical"`
+	URI     string `kicad:"uri"`
+}
+
+// NetlistNets is the list of nets in a netlist.
+type NetlistNets struct {
+	Nets []NetlistNet `kicad:"net,multi,flat"`
+}
+
+// NetlistNet is a net, listing the component pins that it connects. Class
+// is the name of the net's net class, and is present only in netlists from
+// KiCad 8 and later.
+type NetlistNet struct {
+	Code  int           `kicad:"code"`
+	Name  string        `kicad:"name"`
+	Class string        `kicad:"class"`
+	Nodes []NetlistNode `kicad:"node,multi,flat"`
+}
+
+// NetlistNode is a component pin connected to a net.
+type NetlistNode struct {
+	Ref         string `kicad:"ref"`
+	Pin         string `kicad:"pin"`
+	PinFunction string `kicad:"pinfunction"`
+	PinType     string `kicad:"pintype"`
+}
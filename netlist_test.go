@@ -0,0 +1,188 @@
+package kicad
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/davecgh/go-spew/spew"
+)
+
+func TestReadWriteNetlist(t *testing.T) {
+	tests := map[string]struct {
+		Src         string
+		WantVersion string
+		WantTStamps []string
+	}{
+		"kicad5": {
+			`(export (version D)
+  (design
+    (source /home/user/test/test.sch)
+    (date "Mon 01 Apr 2019 12:00:00 PM")
+    (tool "Eeschema 5.1.0")
+    (sheet (number 1) (name /) (tstamps /)
+      (title_block
+        (title)
+        (company)
+        (rev)
+        (date)
+        (source test.sch)
+        (comment (number 1) (value ""))
+        (comment (number 2) (value "")))))
+  (components
+    (comp (ref R1)
+      (value 10k)
+      (footprint Resistor_SMD:R_0603)
+      (datasheet ~)
+      (fields
+        (field (name MPN) RC0603))
+      (libsource (lib Device) (part R) (description Resistor))
+      (sheetpath (names /) (tstamps /))
+      (tstamp 5C8B1234)))
+  (libparts
+    (libpart (lib Device) (part R)
+      (aliases
+        (alias R_Small))
+      (description Resistor)
+      (docs ~)
+      (footprints
+        (fp R_*))
+      (fields
+        (field (name Reference) R)
+        (field (name Value) R))
+      (pins
+        (pin (num 1) (name ~) (type passive))
+        (pin (num 2) (name ~) (type passive)))))
+  (libraries
+    (library (logical Device)
+      (uri /usr/share/kicad/library/Device.lib)))
+  (nets
+    (net (code 1) (name GND)
+      (node (ref R1) (pin 2)))
+    (net (code 2) (name "Net-(R1-Pad1)")
+      (node (ref R1) (pin 1)))))
+`,
+			"D",
+			[]string{"5C8B1234"},
+		},
+		"kicad8": {
+			`(export (version "E")
+  (design
+    (source "/home/user/test/test.kicad_sch")
+    (date "2024-04-01T12:00:00+0000")
+    (tool "Eeschema 8.0.1")
+    (sheet (number "1") (name "/") (tstamps "/")
+      (title_block
+        (title "Test")
+        (company)
+        (rev "A")
+        (date)
+        (source "test.kicad_sch")
+        (comment (number "1") (value "")))))
+  (components
+    (comp (ref "U1")
+      (value "74LS00")
+      (footprint "Package_DIP:DIP-14_W7.62mm")
+      (datasheet "~")
+      (description "Quad NAND")
+      (fields
+        (field (name "Footprint") "Package_DIP:DIP-14_W7.62mm")
+        (field (name "Datasheet") "~")
+        (field (name "Description") "Quad NAND")
+        (field (name "DNP")))
+      (libsource (lib "74xx") (part "74LS00") (description "Quad NAND"))
+      (property (name "Sheetname") (value "Root"))
+      (property (name "Sheetfile") (value "test.kicad_sch"))
+      (sheetpath (names "/") (tstamps "/"))
+      (tstamps "3b3d5e5c-1a67-4c5a-9f53-8f5d0b1f0a01" "3b3d5e5c-1a67-4c5a-9f53-8f5d0b1f0a02")))
+  (libparts
+    (libpart (lib "74xx") (part "74LS00")
+      (description "Quad NAND")
+      (docs "~")
+      (fields
+        (field (name "Reference") "U"))
+      (pins
+        (pin (num "1") (name "~") (type "input"))
+        (pin (num "3") (name "~") (type "output")))))
+  (libraries
+    (library (logical "74xx")
+      (uri "/usr/share/kicad/symbols/74xx.kicad_sym")))
+  (nets
+    (net (code "1") (name "GND") (class "Default")
+      (node (ref "U1") (pin "7") (pinfunction "GND") (pintype "power_in")))
+    (net (code "2") (name "/IN") (class "Default")
+      (node (ref "U1") (pin "1") (pintype "input")))))
+`,
+			"E",
+			[]string{"3b3d5e5c-1a67-4c5a-9f53-8f5d0b1f0a01", "3b3d5e5c-1a67-4c5a-9f53-8f5d0b1f0a02"},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			n, err := ReadNetlist(strings.NewReader(test.Src))
+			if err != nil {
+				t.Fatalf("unexpected error reading: %s", err)
+			}
+			if got, want := n.Version, test.WantVersion; got != want {
+				t.Errorf("wrong version %q; want %q", got, want)
+			}
+			if got, want := len(n.Components.Comps), 1; got != want {
+				t.Fatalf("wrong number of components %d; want %d", got, want)
+			}
+			if got, want := n.Components.Comps[0].TStamps, test.WantTStamps; !reflect.DeepEqual(got, want) {
+				t.Errorf("wrong tstamps %#v; want %#v", got, want)
+			}
+			if got, want := len(n.Nets.Nets), 2; got != want {
+				t.Errorf("wrong number of nets %d; want %d", got, want)
+			}
+			if net := n.Net("GND"); net == nil || net.Code != 1 || len(net.Nodes) != 1 {
+				t.Errorf("wrong GND net %#v", net)
+			}
+			if got, want := len(n.Design.Sheets[0].TitleBlock.Comments), 1; got < want {
+				t.Errorf("wrong number of title block comments %d", got)
+			}
+
+			var buf bytes.Buffer
+			if err := WriteNetlist(&buf, n); err != nil {
+				t.Fatalf("unexpected error writing: %s", err)
+			}
+			got, err := ReadNetlist(&buf)
+			if err != nil {
+				t.Fatalf("unexpected error re-reading: %s", err)
+			}
+			if !reflect.DeepEqual(got, n) {
+				t.Errorf("incorrect round-trip\ngot:  %swant: %s", spew.Sdump(got), spew.Sdump(n))
+			}
+		})
+	}
+}
+
+func TestNetlistAccessors(t *testing.T) {
+	n := &Netlist{
+		Components: NetlistComps{Comps: []NetlistComp{{
+			Ref:        "R1",
+			Fields:     NetlistFields{Fields: []NetlistField{{Name: "MPN", Value: "RC0603"}}},
+			Properties: []NetlistProperty{{Name: "Sheetname", Value: "Root"}},
+		}}},
+		LibParts: NetlistLibParts{Parts: []NetlistLibPart{{Lib: "Device", Part: "R"}}},
+	}
+
+	comp := n.Component("R1")
+	if comp == nil {
+		t.Fatalf("no component R1")
+	}
+	if got, want := comp.Field("MPN"), "RC0603"; got != want {
+		t.Errorf("wrong MPN %q; want %q", got, want)
+	}
+	if got, want := comp.Property("Sheetname"), "Root"; got != want {
+		t.Errorf("wrong sheet name %q; want %q", got, want)
+	}
+	if n.Component("R2") != nil {
+		t.Errorf("found nonexistent component R2")
+	}
+	if n.LibPart("Device", "R") == nil {
+		t.Errorf("no library part Device:R")
+	}
+}
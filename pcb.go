@@ -2,7 +2,11 @@ package kicad
 
 import (
 	"io"
+	"math"
 	"os"
+	"sort"
+	"strconv"
+	"strings"
 
 	"github.com/apparentlymart/go-kicad/sexp"
 )
@@ -26,43 +30,420 @@ func ReadPCBFile(filename string) (*PCB, error) {
 	if err != nil {
 		return nil, err
 	}
+	defer f.Close()
 
 	return ReadPCB(f)
 }
 
+// WritePCB writes the given PCB to the given writer in the pcbnew file
+// format.
+//
+// The board is written in whatever form its fields describe, regardless of
+// its Version. To write a board that an older version of KiCad can read,
+// first call Downgrade.
+func WritePCB(w io.Writer, pcb *PCB) error {
+	return sexp.Encode(w, "kicad_pcb", pcb)
+}
+
+// WritePCBFile is a convenience wrapper around WritePCB that creates or
+// replaces the given file before calling WritePCB.
+func WritePCBFile(filename string, pcb *PCB) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+
+	err = WritePCB(f, pcb)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
 // PCB represents a KiCad pcbnew PCB document.
+//
+// Version is the format version of the document, which KiCad increases
+// whenever it changes the file format. Documents from older versions of
+// KiCad decode into the same structure but use some fields differently, as
+// described on the affected types. Upgrade converts such a document to the
+// current form.
 type PCB struct {
-	Version int        `kicad:"version"`
-	Host    string     `kicad:"host"`
-	General PCBGeneral `kicad:"general"`
+	Version          int           `kicad:"version"`
+	Host             PCBHost       `kicad:"host,flat"`
+	Generator        string        `kicad:"generator"`
+	GeneratorVersion string        `kicad:"generator_version"`
+	General          PCBGeneral    `kicad:"general,flat"`
+	Paper            PaperSize     `kicad:"paper|page,flat"`
+	TitleBlock       TitleBlock    `kicad:"title_block,flat"`
+	Layers           []PCBLayer    `kicad:"layers,flat"`
+	Setup            PCBSetup      `kicad:"setup,flat"`
+	Nets             []PCBNet      `kicad:"net,multi,flat"`
+	NetClasses       []PCBNetClass `kicad:"net_class,multi,flat"`
+	Footprints       []Footprint   `kicad:"footprint|module,multi,flat"`
+	Segments         []Segment     `kicad:"segment,multi,flat"`
+	Arcs             []Arc         `kicad:"arc,multi,flat"`
+	Vias             []Via         `kicad:"via,multi,flat"`
+	Zones            []Zone        `kicad:"zone,multi,flat"`
+
+	GraphicLines   []GraphicLine   `kicad:"gr_line,multi,flat"`
+	GraphicArcs    []GraphicArc    `kicad:"gr_arc,multi,flat"`
+	GraphicCircles []GraphicCircle `kicad:"gr_circle,multi,flat"`
+	GraphicRects   []GraphicRect   `kicad:"gr_rect,multi,flat"`
+	GraphicPolys   []GraphicPoly   `kicad:"gr_poly,multi,flat"`
+	GraphicCurves  []GraphicCurve  `kicad:"gr_curve,multi,flat"`
+	Texts          []BoardText     `kicad:"gr_text,multi,flat"`
+	TextBoxes      []TextBox       `kicad:"gr_text_box,multi,flat"`
+	Dimensions     []Dimension     `kicad:"dimension,multi,flat"`
+	Images         []Image         `kicad:"image,multi,flat"`
+	Groups         []Group         `kicad:"group,multi,flat"`
 }
 
+// PCBHost describes the program that generated a KiCad 5 PCB document.
+// Later versions use PCB.Generator and PCB.GeneratorVersion instead.
+type PCBHost struct {
+	Program string `kicad:""`
+	Version string `kicad:",optional"`
+}
+
+// PCBGeneral holds the summary information from the "general" section of
+// a PCB document.
+//
+// Only Thickness is present in documents from KiCad 6 onwards. The other
+// fields are counters that KiCad 5 wrote for informational purposes, which
+// are left at zero for later versions.
 type PCBGeneral struct {
-	Links      int         `kicad:"links"`
-	NoConnects int         `kicad:"no_connects"`
-	Area       BoundingBox `kicad:"area"`
-	Thickness  float64     `kicad:"thickness"`
-	Drawings   int         `kicad:"drawings"`
-	Tracks     int         `kicad:"tracks"`
-	Zones      int         `kicad:"zones"`
-	Modules    int         `kicad:"modules"`
-	Nets       int         `kicad:"nets"`
+	Links           int         `kicad:"links"`
+	NoConnects      int         `kicad:"no_connects"`
+	Area            BoundingBox `kicad:"area,flat"`
+	Thickness       float64     `kicad:"thickness"`
+	Drawings        int         `kicad:"drawings"`
+	Tracks          int         `kicad:"tracks"`
+	Zones           int         `kicad:"zones"`
+	Modules         int         `kicad:"modules"`
+	Nets            int         `kicad:"nets"`
+	LegacyTeardrops bool        `kicad:"legacy_teardrops"`
 }
 
-type Position struct {
-	X float64 `kicad:""`
-	Y float64 `kicad:""`
+// PaperSize describes the page size of a document, which is either one of
+// the standard names like "A4" or "USLetter", or "User" with an explicit
+// width and height in millimeters.
+type PaperSize struct {
+	Name     string  `kicad:""`
+	Width    float64 `kicad:",optional"`
+	Height   float64 `kicad:",optional"`
+	Portrait bool    `kicad:"portrait,bare"`
+}
+
+// paperSizes are the dimensions of the standard paper sizes in landscape
+// orientation, in millimeters.
+var paperSizes = map[string]Size{
+	"A5":       {Width: 210, Height: 148},
+	"A4":       {Width: 297, Height: 210},
+	"A3":       {Width: 420, Height: 297},
+	"A2":       {Width: 594, Height: 420},
+	"A1":       {Width: 841, Height: 594},
+	"A0":       {Width: 1189, Height: 841},
+	"A":        {Width: 279.4, Height: 215.9},
+	"B":        {Width: 431.8, Height: 279.4},
+	"C":        {Width: 558.8, Height: 431.8},
+	"D":        {Width: 863.6, Height: 558.8},
+	"E":        {Width: 1117.6, Height: 863.6},
+	"GERBER":   {Width: 812.8, Height: 812.8},
+	"USLetter": {Width: 279.4, Height: 215.9},
+	"USLegal":  {Width: 355.6, Height: 215.9},
+	"USLedger": {Width: 431.8, Height: 279.4},
+}
+
+// Dimensions returns the width and height of the page in millimeters,
+// taking into account its orientation. It returns false if the receiver
+// names a paper size that is not known.
+func (p PaperSize) Dimensions() (Size, bool) {
+	if p.Name == "User" {
+		return Size{Width: p.Width, Height: p.Height}, true
+	}
+	size, ok := paperSizes[p.Name]
+	if !ok {
+		return Size{}, false
+	}
+	if p.Portrait {
+		size.Width, size.Height = size.Height, size.Width
+	}
+	return size, true
+}
+
+// PCBLayer is an entry in the layer table of a PCB document, mapping a
+// layer ordinal to its canonical name.
+//
+// Type is one of "signal", "power", "mixed" or "jumper" for copper layers,
+// and "user" for all other layers. UserName is set only if the user has
+// given the layer a name other than its canonical one.
+type PCBLayer struct {
+	Ordinal  int    `kicad:""`
+	Name     string `kicad:""`
+	Type     string `kicad:""`
+	UserName string `kicad:",optional"`
+}
+
+// CopperLayers returns the names of the board's copper layers, from top
+// to bottom.
+func (p *PCB) CopperLayers() []string {
+	var inner []string
+	var names []string
+	for _, layer := range p.Layers {
+		if !strings.HasSuffix(layer.Name, ".Cu") {
+			continue
+		}
+		switch layer.Name {
+		case "F.Cu", "B.Cu":
+		default:
+			inner = append(inner, layer.Name)
+		}
+	}
+	sort.Slice(inner, func(i, j int) bool {
+		return InnerLayerNumber(inner[i]) < InnerLayerNumber(inner[j])
+	})
+	if p.hasLayer("F.Cu") {
+		names = append(names, "F.Cu")
+	}
+	names = append(names, inner...)
+	if p.hasLayer("B.Cu") {
+		names = append(names, "B.Cu")
+	}
+	return names
+}
+
+// InnerLayerNumber returns the number of an inner copper layer, such as 2
+// for "In2.Cu", or zero if the given name is not that of an inner copper
+// layer.
+func InnerLayerNumber(name string) int {
+	if !strings.HasPrefix(name, "In") || !strings.HasSuffix(name, ".Cu") {
+		return 0
+	}
+	n, _ := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(name, "In"), ".Cu"))
+	return n
+}
+
+// OutlineBounds returns the corners of the box around the board outline,
+// drawn by the items on Edge.Cuts. The result is false if the board has no
+// outline.
+func (p *PCB) OutlineBounds() (min, max Position, ok bool) {
+	add := func(pts ...Position) {
+		for _, pt := range pts {
+			if !ok {
+				min, max, ok = pt, pt, true
+				continue
+			}
+			min.X, min.Y = math.Min(min.X, pt.X), math.Min(min.Y, pt.Y)
+			max.X, max.Y = math.Max(max.X, pt.X), math.Max(max.Y, pt.Y)
+		}
+	}
+	addItems := func(lines []GraphicLine, arcs []GraphicArc, circles []GraphicCircle, rects []GraphicRect, polys []GraphicPoly, tr func(Position) Position) {
+		for _, line := range lines {
+			if line.Layer == "Edge.Cuts" {
+				add(tr(line.Start), tr(line.End))
+			}
+		}
+		for _, arc := range arcs {
+			if arc.Layer == "Edge.Cuts" {
+				add(TransformPoints(ArcPoints(arc.Start, arc.Mid, arc.End), tr)...)
+			}
+		}
+		for _, circle := range circles {
+			if circle.Layer == "Edge.Cuts" {
+				c, r := tr(circle.Center), Distance(circle.Center, circle.End)
+				add(Position{X: c.X - r, Y: c.Y - r}, Position{X: c.X + r, Y: c.Y + r})
+			}
+		}
+		for _, rect := range rects {
+			if rect.Layer == "Edge.Cuts" {
+				add(TransformPoints(RectPoints(rect.Start, rect.End), tr)...)
+			}
+		}
+		for _, poly := range polys {
+			if poly.Layer == "Edge.Cuts" {
+				add(TransformPoints(poly.Points.Polyline(), tr)...)
+			}
+		}
+	}
+
+	addItems(p.GraphicLines, p.GraphicArcs, p.GraphicCircles, p.GraphicRects, p.GraphicPolys, func(pos Position) Position { return pos })
+	for i := range p.Footprints {
+		fp := &p.Footprints[i]
+		addItems(fp.Lines, fp.Arcs, fp.Circles, fp.Rects, fp.Polys, fp.BoardPosition)
+	}
+	return min, max, ok
+}
+
+// PadMargin returns the amount to enlarge the given pad of the given
+// footprint by on each axis on the given layer, which is nonzero only on
+// solder mask and solder paste layers. Each margin is taken from the pad,
+// or else from the footprint, or else from the board setup.
+func (p *PCB) PadMargin(fp *Footprint, pad *Pad, layer string) Size {
+	switch {
+	case strings.HasSuffix(layer, ".Mask"):
+		m := firstNonZero(pad.SolderMaskMargin, fp.SolderMaskMargin, p.Setup.PadToMaskClearance)
+		return Size{Width: m, Height: m}
+	case strings.HasSuffix(layer, ".Paste"):
+		m := firstNonZero(pad.SolderPasteMargin, fp.SolderPasteMargin, p.Setup.PadToPasteClearance)
+		ratio := firstNonZero(pad.SolderPasteMarginRatio, fp.SolderPasteMarginRatio, p.Setup.PadToPasteClearanceRatio)
+		return Size{
+			Width:  m + ratio*pad.Size.Width,
+			Height: m + ratio*pad.Size.Height,
+		}
+	default:
+		return Size{}
+	}
+}
+
+func firstNonZero(values ...float64) float64 {
+	for _, v := range values {
+		if v != 0 {
+			return v
+		}
+	}
+	return 0
+}
+
+func (p *PCB) hasLayer(name string) bool {
+	for _, layer := range p.Layers {
+		if layer.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// PCBSetup holds the board setup and plotting settings of a PCB document.
+//
+// Some of the design rule defaults here were moved to the project file
+// in KiCad 6, and so are left at zero for documents from later versions.
+type PCBSetup struct {
+	Stackup                            PCBStackup    `kicad:"stackup,flat"`
+	PadToMaskClearance                 float64       `kicad:"pad_to_mask_clearance"`
+	SolderMaskMinWidth                 float64       `kicad:"solder_mask_min_width"`
+	PadToPasteClearance                float64       `kicad:"pad_to_paste_clearance"`
+	PadToPasteClearanceRatio           float64       `kicad:"pad_to_paste_clearance_ratio"`
+	AllowSolderMaskBridgesInFootprints bool          `kicad:"allow_soldermask_bridges_in_footprints"`
+	AuxAxisOrigin                      Position      `kicad:"aux_axis_origin,flat"`
+	GridOrigin                         Position      `kicad:"grid_origin,flat"`
+	PlotParams                         PCBPlotParams `kicad:"pcbplotparams,flat"`
+
+	// The following are present only in KiCad 5 documents.
+	LastTraceWidth float64   `kicad:"last_trace_width"`
+	TraceClearance float64   `kicad:"trace_clearance"`
+	ZoneClearance  float64   `kicad:"zone_clearance"`
+	Zone45Only     bool      `kicad:"zone_45_only"`
+	TraceMin       float64   `kicad:"trace_min"`
+	ViaSize        float64   `kicad:"via_size"`
+	ViaDrill       float64   `kicad:"via_drill"`
+	ViaMinSize     float64   `kicad:"via_min_size"`
+	ViaMinDrill    float64   `kicad:"via_min_drill"`
+	UViaSize       float64   `kicad:"uvia_size"`
+	UViaDrill      float64   `kicad:"uvia_drill"`
+	UViasAllowed   bool      `kicad:"uvias_allowed"`
+	UViaMinSize    float64   `kicad:"uvia_min_size"`
+	UViaMinDrill   float64   `kicad:"uvia_min_drill"`
+	EdgeWidth      float64   `kicad:"edge_width"`
+	SegmentWidth   float64   `kicad:"segment_width"`
+	PCBTextWidth   float64   `kicad:"pcb_text_width"`
+	PCBTextSize    Size      `kicad:"pcb_text_size,flat"`
+	ModEdgeWidth   float64   `kicad:"mod_edge_width"`
+	ModTextSize    Size      `kicad:"mod_text_size,flat"`
+	ModTextWidth   float64   `kicad:"mod_text_width"`
+	PadSize        Size      `kicad:"pad_size,flat"`
+	PadDrill       float64   `kicad:"pad_drill"`
+	VisibleElems   string    `kicad:"visible_elements"`
+	UserTraceWidth []float64 `kicad:"user_trace_width,multi"`
+}
+
+// PCBStackup describes the physical board stackup, from the top silkscreen
+// down to the bottom silkscreen.
+type PCBStackup struct {
+	Layers                []PCBStackupLayer `kicad:"layer,multi,flat"`
+	CopperFinish          string            `kicad:"copper_finish"`
+	DielectricConstraints bool              `kicad:"dielectric_constraints"`
+	EdgeConnector         string            `kicad:"edge_connector"`
+	CastellatedPads       bool              `kicad:"castellated_pads"`
+	EdgePlating           bool              `kicad:"edge_plating"`
+}
+
+// PCBStackupLayer is a single layer in a PCBStackup. Name is either the
+// canonical name of a board layer or a name like "dielectric 1" for the
+// dielectric layers between them.
+type PCBStackupLayer struct {
+	Name        string           `kicad:""`
+	Type        string           `kicad:"type"`
+	Color       string           `kicad:"color"`
+	Thickness   StackupThickness `kicad:"thickness,flat"`
+	Material    string           `kicad:"material"`
+	EpsilonR    float64          `kicad:"epsilon_r"`
+	LossTangent float64          `kicad:"loss_tangent"`
+}
+
+// StackupThickness is the thickness of a PCBStackupLayer, in millimeters.
+// Locked is set if the thickness of a dielectric layer is fixed, rather than
+// adjusted to meet the overall board thickness.
+type StackupThickness struct {
+	Value  float64 `kicad:""`
+	Locked bool    `kicad:"locked,bare"`
+}
+
+// PCBPlotParams holds the most recent settings used to plot fabrication
+// outputs from a PCB document.
+//
+// LayerSelection is the set of layers to plot, as a hexadecimal bitmask of
+// layer ordinals in the same format as KiCad writes it.
+type PCBPlotParams struct {
+	LayerSelection              string  `kicad:"layerselection,raw"`
+	PlotOnAllLayersSelection    string  `kicad:"plot_on_all_layers_selection,raw"`
+	DisableApertureMacros       bool    `kicad:"disableapertmacros,truefalse"`
+	UseGerberExtensions         bool    `kicad:"usegerberextensions,truefalse"`
+	UseGerberAttributes         bool    `kicad:"usegerberattributes,truefalse"`
+	UseGerberAdvancedAttributes bool    `kicad:"usegerberadvancedattributes,truefalse"`
+	CreateGerberJobFile         bool    `kicad:"creategerberjobfile,truefalse"`
+	GerberPrecision             int     `kicad:"gerberprecision"`
+	ExcludeEdgeLayer            bool    `kicad:"excludeedgelayer,truefalse"`
+	LineWidth                   float64 `kicad:"linewidth"`
+	PlotFrameRef                bool    `kicad:"plotframeref,truefalse"`
+	ViasOnMask                  bool    `kicad:"viasonmask,truefalse"`
+	Mode                        int     `kicad:"mode"`
+	UseAuxOrigin                bool    `kicad:"useauxorigin,truefalse"`
+	PSNegative                  bool    `kicad:"psnegative,truefalse"`
+	PSA4Output                  bool    `kicad:"psa4output,truefalse"`
+	PlotReference               bool    `kicad:"plotreference,truefalse"`
+	PlotValue                   bool    `kicad:"plotvalue,truefalse"`
+	PlotFootprintText           bool    `kicad:"plotfptext,truefalse"`
+	PlotInvisibleText           bool    `kicad:"plotinvisibletext,truefalse"`
+	SketchPadsOnFab             bool    `kicad:"sketchpadsonfab,truefalse"`
+	SubtractMaskFromSilk        bool    `kicad:"subtractmaskfromsilk,truefalse"`
+	OutputFormat                int     `kicad:"outputformat"`
+	Mirror                      bool    `kicad:"mirror,truefalse"`
+	DrillShape                  int     `kicad:"drillshape"`
+	ScaleSelection              int     `kicad:"scaleselection"`
+	OutputDirectory             string  `kicad:"outputdirectory"`
 }
 
-type PositionAngle struct {
-	X     float64 `kicad:""`
-	Y     float64 `kicad:""`
-	Angle float64 `kicad:""`
+// PCBNet declares a net on the board, giving the number that items on the
+// board use to refer to it. Net zero is always the unconnected net, whose
+// name is the empty string.
+type PCBNet struct {
+	Number int    `kicad:""`
+	Name   string `kicad:""`
 }
 
-type BoundingBox struct {
-	X1 float64 `kicad:""`
-	Y1 float64 `kicad:""`
-	X2 float64 `kicad:""`
-	Y2 float64 `kicad:""`
+// PCBNetClass is a KiCad 5 net class definition. KiCad 6 moved net classes
+// into the project file, so later documents contain none.
+type PCBNetClass struct {
+	Name             string   `kicad:""`
+	Description      string   `kicad:""`
+	Clearance        float64  `kicad:"clearance"`
+	TraceWidth       float64  `kicad:"trace_width"`
+	ViaDiameter      float64  `kicad:"via_dia"`
+	ViaDrill         float64  `kicad:"via_drill"`
+	MicroViaDiameter float64  `kicad:"uvia_dia"`
+	MicroViaDrill    float64  `kicad:"uvia_drill"`
+	DiffPairWidth    float64  `kicad:"diff_pair_width"`
+	DiffPairGap      float64  `kicad:"diff_pair_gap"`
+	Nets             []string `kicad:"add_net,multi"`
 }
@@ -0,0 +1,752 @@
+package kicad
+
+import (
+	"bytes"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/davecgh/go-spew/spew"
+)
+
+func TestReadPCB_kicad5(t *testing.T) {
+	src := `(kicad_pcb (version 20171130) (host pcbnew "(5.1.9)-1")
+  (general
+    (thickness 1.6)
+    (drawings 4)
+    (tracks 12)
+    (zones 0)
+    (modules 2)
+    (nets 3)
+  )
+  (page A4)
+  (layers
+    (0 F.Cu signal)
+    (31 B.Cu power)
+    (44 Edge.Cuts user)
+  )
+  (setup
+    (last_trace_width 0.25)
+    (trace_clearance 0.2)
+    (zone_clearance 0.508)
+    (zone_45_only no)
+    (pcb_text_size 1.5 1.5)
+    (aux_axis_origin 10 20)
+    (pcbplotparams
+      (layerselection 0x010fc_ffffffff)
+      (usegerberextensions false)
+      (outputdirectory "gerbers/"))
+  )
+  (net 0 "")
+  (net 1 GND)
+  (net 2 "Net-(R1-Pad2)")
+  (net_class Default "This is the default net class."
+    (clearance 0.2)
+    (trace_width 0.25)
+    (via_dia 0.8)
+    (via_drill 0.4)
+    (add_net GND)
+    (add_net "Net-(R1-Pad2)")
+  )
+)`
+	got, err := ReadPCB(strings.NewReader(src))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	want := &PCB{
+		Version: 20171130,
+		Host:    PCBHost{Program: "pcbnew", Version: "(5.1.9)-1"},
+		General: PCBGeneral{
+			Thickness: 1.6,
+			Drawings:  4,
+			Tracks:    12,
+			Modules:   2,
+			Nets:      3,
+		},
+		Paper: PaperSize{Name: "A4"},
+		Layers: []PCBLayer{
+			{Ordinal: 0, Name: "F.Cu", Type: "signal"},
+			{Ordinal: 31, Name: "B.Cu", Type: "power"},
+			{Ordinal: 44, Name: "Edge.Cuts", Type: "user"},
+		},
+		Setup: PCBSetup{
+			LastTraceWidth: 0.25,
+			TraceClearance: 0.2,
+			ZoneClearance:  0.508,
+			PCBTextSize:    Size{Width: 1.5, Height: 1.5},
+			AuxAxisOrigin:  Position{X: 10, Y: 20},
+			PlotParams: PCBPlotParams{
+				LayerSelection:  "0x010fc_ffffffff",
+				OutputDirectory: "gerbers/",
+			},
+		},
+		Nets: []PCBNet{
+			{Number: 0, Name: ""},
+			{Number: 1, Name: "GND"},
+			{Number: 2, Name: "Net-(R1-Pad2)"},
+		},
+		NetClasses: []PCBNetClass{
+			{
+				Name:        "Default",
+				Description: "This is the default net class.",
+				Clearance:   0.2,
+				TraceWidth:  0.25,
+				ViaDiameter: 0.8,
+				ViaDrill:    0.4,
+				Nets:        []string{"GND", "Net-(R1-Pad2)"},
+			},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("incorrect result\ngot:  %swant: %s", spew.Sdump(got), spew.Sdump(want))
+	}
+}
+
+func TestReadPCB_kicad8(t *testing.T) {
+	src := `(kicad_pcb
+	(version 20240108)
+	(generator "pcbnew")
+	(generator_version "8.0")
+	(general
+		(thickness 1.6)
+		(legacy_teardrops no)
+	)
+	(paper "User" 100 80)
+	(title_block
+		(title "Widget")
+		(rev "2")
+		(comment 1 "Assembly")
+	)
+	(layers
+		(0 "F.Cu" signal)
+		(31 "B.Cu" signal)
+		(36 "B.SilkS" user "B.Silkscreen")
+	)
+	(setup
+		(stackup
+			(layer "F.Cu"
+				(type "copper")
+				(thickness 0.035)
+			)
+			(layer "dielectric 1"
+				(type "core")
+				(thickness 1.51 locked)
+				(material "FR4")
+				(epsilon_r 4.5)
+				(loss_tangent 0.02)
+			)
+			(copper_finish "None")
+			(dielectric_constraints no)
+		)
+		(pad_to_mask_clearance 0)
+		(allow_soldermask_bridges_in_footprints no)
+		(pcbplotparams
+			(layerselection 0x00010fc_ffffffff)
+			(usegerberattributes yes)
+			(outputformat 1)
+			(outputdirectory "")
+		)
+	)
+	(net 0 "")
+	(net 1 "GND")
+)`
+	got, err := ReadPCB(strings.NewReader(src))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	want := &PCB{
+		Version:          20240108,
+		Generator:        "pcbnew",
+		GeneratorVersion: "8.0",
+		General:          PCBGeneral{Thickness: 1.6},
+		Paper:            PaperSize{Name: "User", Width: 100, Height: 80},
+		TitleBlock: TitleBlock{
+			Title:    "Widget",
+			Revision: "2",
+			Comments: []TitleBlockComment{{Number: 1, Text: "Assembly"}},
+		},
+		Layers: []PCBLayer{
+			{Ordinal: 0, Name: "F.Cu", Type: "signal"},
+			{Ordinal: 31, Name: "B.Cu", Type: "signal"},
+			{Ordinal: 36, Name: "B.SilkS", Type: "user", UserName: "B.Silkscreen"},
+		},
+		Setup: PCBSetup{
+			Stackup: PCBStackup{
+				Layers: []PCBStackupLayer{
+					{
+						Name:      "F.Cu",
+						Type:      "copper",
+						Thickness: StackupThickness{Value: 0.035},
+					},
+					{
+						Name:        "dielectric 1",
+						Type:        "core",
+						Thickness:   StackupThickness{Value: 1.51, Locked: true},
+						Material:    "FR4",
+						EpsilonR:    4.5,
+						LossTangent: 0.02,
+					},
+				},
+				CopperFinish: "None",
+			},
+			PlotParams: PCBPlotParams{
+				LayerSelection:      "0x00010fc_ffffffff",
+				UseGerberAttributes: true,
+				OutputFormat:        1,
+			},
+		},
+		Nets: []PCBNet{
+			{Number: 0, Name: ""},
+			{Number: 1, Name: "GND"},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("incorrect result\ngot:  %swant: %s", spew.Sdump(got), spew.Sdump(want))
+	}
+}
+
+func TestReadPCB_footprints(t *testing.T) {
+	src := `(kicad_pcb (version 20171130) (host pcbnew 5.1.9)
+  (module Resistor_SMD:R_0603_1608Metric locked (layer F.Cu) (tedit 5F68FEEE) (tstamp 5F0BD2E1)
+    (at 100 50 90)
+    (descr "Resistor SMD 0603")
+    (path /5F0BD2E1)
+    (attr smd)
+    (fp_text reference R1 (at 0 -1.43 90) (layer F.SilkS)
+      (effects (font (size 1 1) (thickness 0.15)))
+    )
+    (fp_text value 10k (at 0 1.43 90) (layer F.Fab) hide
+      (effects (font (size 1 1) (thickness 0.15)) (justify left mirror))
+    )
+    (fp_line (start -0.8 0.4) (end -0.8 -0.4) (layer F.Fab) (width 0.1))
+    (pad 1 smd roundrect (at -0.7875 0 90) (size 0.875 0.95) (layers F.Cu F.Paste F.Mask) (roundrect_rratio 0.25)
+      (net 1 GND))
+    (model ${KISYS3DMOD}/Resistor_SMD.3dshapes/R_0603_1608Metric.wrl
+      (at (xyz 0 0 0))
+      (scale (xyz 1 1 1))
+      (rotate (xyz 0 0 0))
+    )
+  )
+  (footprint "Connector:Pin_1" placed (layer "B.Cu")
+    (uuid "0c5b4fbf-8d42-4f3a-a3a6-6b8d2b5f8f1e")
+    (at 10 20 180)
+    (property "Reference" "J1" (at 0 -2 0) (unlocked yes) (layer "B.SilkS") (hide yes)
+      (effects (font (size 1 1) (thickness 0.15)))
+    )
+    (property "Value" "Conn")
+    (attr through_hole exclude_from_bom)
+    (fp_circle (center 0 0) (end 1 0) (stroke (width 0.12) (type solid)) (fill none) (layer "B.SilkS"))
+    (pad "1" thru_hole custom (at 0 0 180) (size 1.7 1.7) (drill oval 1 1.2 (offset 0.1 0)) (layers "*.Cu" "*.Mask")
+      (remove_unused_layers no)
+      (options (clearance outline) (anchor circle))
+      (primitives
+        (gr_poly (pts (xy -1 -1) (xy 1 -1) (xy 0 1)) (width 0) (fill yes))
+      )
+      (pinfunction "Pin_1") (pintype "passive")
+    )
+  )
+)`
+	pcb, err := ReadPCB(strings.NewReader(src))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got, want := len(pcb.Footprints), 2; got != want {
+		t.Fatalf("wrong number of footprints %d; want %d", got, want)
+	}
+
+	got := pcb.Footprints[0]
+	want := Footprint{
+		LibID:       "Resistor_SMD:R_0603_1608Metric",
+		Locked:      true,
+		Layer:       "F.Cu",
+		TEdit:       "5F68FEEE",
+		TStamp:      "5F0BD2E1",
+		At:          PositionAngle{X: 100, Y: 50, Angle: 90},
+		Description: "Resistor SMD 0603",
+		Path:        "/5F0BD2E1",
+		Attr:        FootprintAttr{Type: "smd"},
+		Texts: []FootprintText{
+			{
+				Type:  "reference",
+				Text:  "R1",
+				At:    PositionAngle{X: 0, Y: -1.43, Angle: 90},
+				Layer: TextLayer{Name: "F.SilkS"},
+				Effects: TextEffects{
+					Font: Font{Size: TextSize{Height: 1, Width: 1}, Thickness: 0.15},
+				},
+			},
+			{
+				Type:  "value",
+				Text:  "10k",
+				At:    PositionAngle{X: 0, Y: 1.43, Angle: 90},
+				Layer: TextLayer{Name: "F.Fab"},
+				Hide:  true,
+				Effects: TextEffects{
+					Font:    Font{Size: TextSize{Height: 1, Width: 1}, Thickness: 0.15},
+					Justify: TextJustify{Left: true, Mirror: true},
+				},
+			},
+		},
+		Lines: []GraphicLine{
+			{
+				Start: Position{X: -0.8, Y: 0.4},
+				End:   Position{X: -0.8, Y: -0.4},
+				Layer: "F.Fab",
+				Width: 0.1,
+			},
+		},
+		Pads: []Pad{
+			{
+				Number:         "1",
+				Type:           "smd",
+				Shape:          "roundrect",
+				At:             PositionAngle{X: -0.7875, Y: 0, Angle: 90},
+				Size:           Size{Width: 0.875, Height: 0.95},
+				Layers:         []string{"F.Cu", "F.Paste", "F.Mask"},
+				RoundRectRatio: 0.25,
+				Net:            PCBNet{Number: 1, Name: "GND"},
+			},
+		},
+		Models: []Model3D{
+			{
+				Path:  "${KISYS3DMOD}/Resistor_SMD.3dshapes/R_0603_1608Metric.wrl",
+				Scale: ModelVector{XYZ: Vector3{X: 1, Y: 1, Z: 1}},
+			},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("incorrect module\ngot:  %swant: %s", spew.Sdump(got), spew.Sdump(want))
+	}
+	if got, want := got.Reference(), "R1"; got != want {
+		t.Errorf("wrong module reference %q; want %q", got, want)
+	}
+	if got, want := got.Value(), "10k"; got != want {
+		t.Errorf("wrong module value %q; want %q", got, want)
+	}
+
+	fp := pcb.Footprints[1]
+	if got, want := fp.Reference(), "J1"; got != want {
+		t.Errorf("wrong footprint reference %q; want %q", got, want)
+	}
+	if !fp.Placed || fp.Locked {
+		t.Errorf("wrong flags: placed=%t locked=%t", fp.Placed, fp.Locked)
+	}
+	if got, want := fp.Attr, (FootprintAttr{Type: "through_hole", ExcludeFromBOM: true}); got != want {
+		t.Errorf("wrong attr %#v; want %#v", got, want)
+	}
+	if prop := fp.Properties[0]; !prop.Hide || !prop.Unlocked || prop.Layer.Name != "B.SilkS" {
+		t.Errorf("wrong reference property %#v", prop)
+	}
+	if fp.Circles[0].Filled() {
+		t.Errorf("circle should not be filled")
+	}
+	pad := fp.Pad("1")
+	if pad == nil {
+		t.Fatalf("pad 1 not found")
+	}
+	wantDrill := PadDrill{Oval: true, Width: 1, Height: 1.2, Offset: Position{X: 0.1}}
+	if pad.Drill != wantDrill {
+		t.Errorf("wrong drill %#v; want %#v", pad.Drill, wantDrill)
+	}
+	if got, want := len(pad.Primitives.Polys), 1; got != want {
+		t.Fatalf("wrong number of primitive polygons %d; want %d", got, want)
+	}
+	if poly := pad.Primitives.Polys[0]; len(poly.Points.Polyline()) != 3 || !poly.Filled() {
+		t.Errorf("wrong primitive polygon %#v", poly)
+	}
+	if got, want := pad.PinFunction, "Pin_1"; got != want {
+		t.Errorf("wrong pin function %q; want %q", got, want)
+	}
+}
+
+func TestReadPCB_routing(t *testing.T) {
+	src := `(kicad_pcb (version 20221018) (generator pcbnew)
+  (net 0 "")
+  (net 1 "GND")
+  (segment (start 0 0) (end 3 4) (width 0.25) (layer "F.Cu") (net 1) (tstamp 1b2c))
+  (segment locked (start 3 4) (end 3 10) (width 0.25) (layer "F.Cu") (net 1) (tstamp 3d4e))
+  (arc (start 0 10) (mid 7.0710678 7.0710678) (end 10 0) (width 0.2) (layer "B.Cu") (net 1) (tstamp 5f6a))
+  (via (at 3 10) (size 0.8) (drill 0.4) (layers "F.Cu" "B.Cu") (free) (net 1) (tstamp 7b8c))
+  (via blind locked (at 5 5) (size 0.6) (drill 0.3) (layers "F.Cu" "In1.Cu") (remove_unused_layers yes) (net 1) (uuid "9d0e"))
+)`
+	pcb, err := ReadPCB(strings.NewReader(src))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	wantVias := []Via{
+		{
+			At:     Position{X: 3, Y: 10},
+			Size:   0.8,
+			Drill:  0.4,
+			Layers: []string{"F.Cu", "B.Cu"},
+			Free:   true,
+			Net:    1,
+			TStamp: "7b8c",
+		},
+		{
+			Type:               "blind",
+			Locked:             true,
+			At:                 Position{X: 5, Y: 5},
+			Size:               0.6,
+			Drill:              0.3,
+			Layers:             []string{"F.Cu", "In1.Cu"},
+			RemoveUnusedLayers: true,
+			Net:                1,
+			UUID:               "9d0e",
+		},
+	}
+	if !reflect.DeepEqual(pcb.Vias, wantVias) {
+		t.Errorf("incorrect vias\ngot:  %swant: %s", spew.Sdump(pcb.Vias), spew.Sdump(wantVias))
+	}
+
+	if got, want := len(pcb.Segments), 2; got != want {
+		t.Fatalf("wrong number of segments %d; want %d", got, want)
+	}
+	if !pcb.Segments[1].Locked || pcb.Segments[0].Locked {
+		t.Errorf("wrong locked flags on segments")
+	}
+	if got, want := pcb.Segments[0].Length(), 5.0; got != want {
+		t.Errorf("wrong segment length %g; want %g", got, want)
+	}
+
+	// The arc is a quarter circle of radius 10
+	wantArc := 10 * math.Pi / 2
+	if got := pcb.Arcs[0].Length(); math.Abs(got-wantArc) > 1e-6 {
+		t.Errorf("wrong arc length %g; want %g", got, wantArc)
+	}
+
+	net := pcb.NetNumber("GND")
+	if got, want := pcb.NetName(net), "GND"; got != want {
+		t.Errorf("wrong net name %q; want %q", got, want)
+	}
+	if got, want := pcb.TrackLength(net), 11+wantArc; math.Abs(got-want) > 1e-6 {
+		t.Errorf("wrong track length %g; want %g", got, want)
+	}
+}
+
+func TestReadPCB_zones(t *testing.T) {
+	src := `(kicad_pcb (version 20240108) (generator "pcbnew")
+	(zone
+		(net 1)
+		(net_name "GND")
+		(layers "F.Cu" "B.Cu")
+		(uuid "a1")
+		(hatch edge 0.5)
+		(connect_pads thru_hole_only
+			(clearance 0.3)
+		)
+		(min_thickness 0.25)
+		(filled_areas_thickness no)
+		(fill yes
+			(thermal_gap 0.5)
+			(thermal_bridge_width 0.5)
+			(island_removal_mode 2)
+			(island_area_min 10)
+		)
+		(polygon
+			(pts
+				(xy 0 0) (xy 10 0) (xy 10 10) (xy 0 10)
+			)
+		)
+		(filled_polygon
+			(layer "F.Cu")
+			(pts
+				(xy 0.5 0.5) (xy 9.5 0.5) (xy 9.5 9.5)
+			)
+		)
+		(filled_polygon
+			(layer "B.Cu")
+			(island)
+			(pts
+				(xy 1 1) (xy 2 1) (xy 2 2)
+			)
+		)
+	)
+	(zone (net 0) (net_name "") (layer "F.Cu") (tstamp 0) (hatch full 0.508)
+		(connect_pads (clearance 0.508))
+		(min_thickness 0.254)
+		(keepout (tracks not_allowed) (vias allowed) (copperpour not_allowed))
+		(fill (arc_segments 32) (thermal_gap 0.508) (thermal_bridge_width 0.508))
+		(polygon (pts (xy 0 0) (xy 1 0) (xy 1 1)))
+	)
+)`
+	pcb, err := ReadPCB(strings.NewReader(src))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got, want := len(pcb.Zones), 2; got != want {
+		t.Fatalf("wrong number of zones %d; want %d", got, want)
+	}
+
+	zone := pcb.Zones[0]
+	if got, want := zone.LayerNames(), []string{"F.Cu", "B.Cu"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong layers %#v; want %#v", got, want)
+	}
+	if got, want := zone.ConnectPads, (ZoneConnectPads{Mode: "thru_hole_only", Clearance: 0.3}); got != want {
+		t.Errorf("wrong connect_pads %#v; want %#v", got, want)
+	}
+	wantFill := ZoneFill{
+		Enabled:            true,
+		ThermalGap:         0.5,
+		ThermalBridgeWidth: 0.5,
+		IslandRemovalMode:  2,
+		IslandAreaMin:      10,
+	}
+	if zone.Fill != wantFill {
+		t.Errorf("wrong fill %#v; want %#v", zone.Fill, wantFill)
+	}
+	if zone.IsRuleArea() {
+		t.Errorf("copper zone reported as rule area")
+	}
+	if got, want := len(zone.Outline[0].Points.Polyline()), 4; got != want {
+		t.Errorf("wrong number of outline points %d; want %d", got, want)
+	}
+	if got, want := len(zone.Filled), 2; got != want {
+		t.Fatalf("wrong number of filled polygons %d; want %d", got, want)
+	}
+	if zone.Filled[0].Island || !zone.Filled[1].Island || zone.Filled[1].Layer != "B.Cu" {
+		t.Errorf("wrong filled polygons %#v", zone.Filled)
+	}
+
+	keepout := pcb.Zones[1]
+	if got, want := keepout.LayerNames(), []string{"F.Cu"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong layers %#v; want %#v", got, want)
+	}
+	if !keepout.IsRuleArea() {
+		t.Errorf("keepout zone not reported as rule area")
+	}
+	if got, want := keepout.Keepout.Tracks, "not_allowed"; got != want {
+		t.Errorf("wrong tracks keepout %q; want %q", got, want)
+	}
+	if keepout.Fill.Enabled || keepout.Fill.ArcSegments != 32 {
+		t.Errorf("wrong fill %#v", keepout.Fill)
+	}
+}
+
+func TestReadPCB_graphics(t *testing.T) {
+	src := `(kicad_pcb (version 20240108) (generator "pcbnew")
+	(gr_line (start 0 0) (end 100 0) (stroke (width 0.05) (type default)) (layer "Edge.Cuts") (uuid "l1"))
+	(gr_arc (start 0 10) (mid 2.9 2.9) (end 10 0) (stroke (width 0.05) (type solid)) (layer "Edge.Cuts") (uuid "a1"))
+	(gr_rect (start 1 1) (end 5 5) (stroke (width 0.1) (type dash)) (fill yes) (layer "F.SilkS") (uuid "r1"))
+	(gr_text "Rev A" (at 50 10 90) (layer "F.SilkS" knockout) (uuid "t1")
+		(effects (font (face "Arial") (size 1.5 1) (thickness 0.3) bold) (justify left bottom))
+	)
+	(gr_text_box "Notes" (start 10 10) (end 40 20) (margins 1 1 1 1) (layer "Cmts.User") (uuid "tb1")
+		(effects (font (size 1 1)))
+		(border yes)
+		(stroke (width 0.1) (type solid))
+	)
+	(dimension (type aligned) (layer "Dwgs.User") (uuid "d1")
+		(pts (xy 0 0) (xy 100 0))
+		(height -5)
+		(gr_text "100 mm" (at 50 -6) (layer "Dwgs.User") (uuid "d1t")
+			(effects (font (size 1 1) (thickness 0.15)))
+		)
+		(format (prefix "") (suffix "") (units 3) (units_format 1) (precision 4) (suppress_zeroes yes))
+		(style (thickness 0.1) (arrow_length 1.27) (text_position_mode 0) (arrow_direction outward) (extension_height 0.58642) (extension_offset 0.5) (keep_text_aligned yes))
+	)
+	(image (at 20 20) (layer "F.SilkS") (scale 2) (uuid "i1")
+		(data "iVBORw0KGgo=")
+	)
+	(group "Logo" (uuid "g1") (locked yes) (members "r1" "t1"))
+)`
+	pcb, err := ReadPCB(strings.NewReader(src))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if got, want := len(pcb.GraphicLines), 1; got != want {
+		t.Errorf("wrong number of lines %d; want %d", got, want)
+	}
+	if got, want := pcb.GraphicArcs[0].Mid, (Position{X: 2.9, Y: 2.9}); got != want {
+		t.Errorf("wrong arc midpoint %#v; want %#v", got, want)
+	}
+	if rect := pcb.GraphicRects[0]; !rect.Filled() || rect.Stroke.Type != "dash" {
+		t.Errorf("wrong rectangle %#v", rect)
+	}
+
+	wantText := BoardText{
+		Text:  "Rev A",
+		At:    PositionAngle{X: 50, Y: 10, Angle: 90},
+		Layer: TextLayer{Name: "F.SilkS", Knockout: true},
+		UUID:  "t1",
+		Effects: TextEffects{
+			Font: Font{
+				Face:      "Arial",
+				Size:      TextSize{Height: 1.5, Width: 1},
+				Thickness: 0.3,
+				Bold:      true,
+			},
+			Justify: TextJustify{Left: true, Bottom: true},
+		},
+	}
+	if got := pcb.Texts[0]; got != wantText {
+		t.Errorf("incorrect text\ngot:  %swant: %s", spew.Sdump(got), spew.Sdump(wantText))
+	}
+
+	box := pcb.TextBoxes[0]
+	if box.Text != "Notes" || !box.Border || box.Margins != (Margins{1, 1, 1, 1}) {
+		t.Errorf("wrong text box %#v", box)
+	}
+
+	dim := pcb.Dimensions[0]
+	if dim.Type != "aligned" || dim.Height != -5 || len(dim.Points.Polyline()) != 2 {
+		t.Errorf("wrong dimension %#v", dim)
+	}
+	if got, want := dim.Text.Text, "100 mm"; got != want {
+		t.Errorf("wrong dimension text %q; want %q", got, want)
+	}
+	if !dim.Format.SuppressZeroes || dim.Format.Precision != 4 || dim.Style.ArrowDirection != "outward" {
+		t.Errorf("wrong dimension format or style %#v %#v", dim.Format, dim.Style)
+	}
+
+	png, err := pcb.Images[0].PNG()
+	if err != nil {
+		t.Fatalf("failed to decode image: %s", err)
+	}
+	if got, want := string(png[1:4]), "PNG"; got != want {
+		t.Errorf("wrong image signature %q; want %q", got, want)
+	}
+
+	wantGroup := Group{Name: "Logo", Locked: true, UUID: "g1", Members: []string{"r1", "t1"}}
+	if got := pcb.Groups[0]; !reflect.DeepEqual(got, wantGroup) {
+		t.Errorf("wrong group %#v; want %#v", got, wantGroup)
+	}
+}
+
+func TestReadWritePCB_polyArcs(t *testing.T) {
+	src := `(kicad_pcb (version 20240108) (generator "pcbnew")
+	(gr_poly
+		(pts (xy 0 0) (xy 10 0) (arc (start 10 0) (mid 12.9 2.9) (end 10 10)) (xy 0 10))
+		(stroke (width 0.1) (type solid)) (fill none) (layer "Edge.Cuts") (uuid "p1")
+	)
+)`
+	pcb, err := ReadPCB(strings.NewReader(src))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	pts := pcb.GraphicPolys[0].Points
+	want := []PointsItem{
+		{XY: &Position{X: 0, Y: 0}},
+		{XY: &Position{X: 10, Y: 0}},
+		{Arc: &PointArc{Start: Position{X: 10, Y: 0}, Mid: Position{X: 12.9, Y: 2.9}, End: Position{X: 10, Y: 10}}},
+		{XY: &Position{X: 0, Y: 10}},
+	}
+	if !reflect.DeepEqual(pts.Items, want) {
+		t.Errorf("incorrect points\ngot:  %swant: %s", spew.Sdump(pts.Items), spew.Sdump(want))
+	}
+
+	// The arc must stay between the points around it in the polyline.
+	line := pts.Polyline()
+	if got, want := line[len(line)-1], (Position{X: 0, Y: 10}); got != want {
+		t.Errorf("wrong last polyline point %v; want %v", got, want)
+	}
+	if got := line[len(line)-2]; Distance(got, Position{X: 10, Y: 10}) > 1e-9 {
+		t.Errorf("wrong arc end in polyline %v; want 10,10", got)
+	}
+
+	var buf bytes.Buffer
+	err = WritePCB(&buf, pcb)
+	if err != nil {
+		t.Fatalf("unexpected error writing: %s", err)
+	}
+	got, err := ReadPCB(&buf)
+	if err != nil {
+		t.Fatalf("unexpected error re-reading: %s", err)
+	}
+	if !reflect.DeepEqual(got.GraphicPolys, pcb.GraphicPolys) {
+		t.Errorf("incorrect round-trip\ngot:  %swant: %s", spew.Sdump(got.GraphicPolys), spew.Sdump(pcb.GraphicPolys))
+	}
+}
+
+func TestFootprintBoardPosition(t *testing.T) {
+	fp := &Footprint{At: PositionAngle{X: 10, Y: 20, Angle: 90}}
+	tests := []struct {
+		local, want Position
+	}{
+		{Position{0, 0}, Position{10, 20}},
+		{Position{1, 0}, Position{10, 19}},
+		{Position{0, 1}, Position{11, 20}},
+	}
+	for _, test := range tests {
+		got := fp.BoardPosition(test.local)
+		if Distance(got, test.want) > 1e-9 {
+			t.Errorf("BoardPosition(%v) = %v; want %v", test.local, got, test.want)
+		}
+	}
+}
+
+func TestFootprintText(t *testing.T) {
+	fp := &Footprint{
+		At: PositionAngle{X: 10, Y: 20, Angle: 90},
+		Properties: []FootprintProperty{
+			{Name: "Reference", Value: "R1"},
+			{Name: "Value", Value: "10k"},
+			{Name: "Tolerance", Value: "1%"},
+		},
+	}
+	if got, want := fp.ExpandText("${REFERENCE} ${VALUE} ${Tolerance} ${OTHER}"), "R1 10k 1% ${OTHER}"; got != want {
+		t.Errorf("wrong expanded text %q; want %q", got, want)
+	}
+
+	// Only the position is converted, since text angles already include
+	// the footprint's rotation.
+	got := fp.BoardTextAt(PositionAngle{X: 1, Angle: 90})
+	if want := (PositionAngle{X: 10, Y: 19, Angle: 90}); Distance(got.Position(), want.Position()) > 1e-9 || got.Angle != want.Angle {
+		t.Errorf("wrong text position %v; want %v", got, want)
+	}
+}
+
+func TestPCBPadMargin(t *testing.T) {
+	pcb := &PCB{Setup: PCBSetup{PadToMaskClearance: 0.05, PadToPasteClearanceRatio: -0.1}}
+	fp := &Footprint{SolderMaskMargin: 0.1}
+	pad := &Pad{Size: Size{Width: 2, Height: 1}, SolderPasteMargin: -0.02}
+
+	tests := []struct {
+		layer string
+		want  Size
+	}{
+		{"F.Cu", Size{}},
+		{"F.Mask", Size{Width: 0.1, Height: 0.1}},
+		{"B.Paste", Size{Width: -0.22, Height: -0.12}},
+	}
+	for _, test := range tests {
+		got := pcb.PadMargin(fp, pad, test.layer)
+		if math.Abs(got.Width-test.want.Width) > 1e-9 || math.Abs(got.Height-test.want.Height) > 1e-9 {
+			t.Errorf("wrong margin on %s %v; want %v", test.layer, got, test.want)
+		}
+	}
+}
+
+func TestPCBOutlineBounds(t *testing.T) {
+	pcb := &PCB{
+		GraphicRects: []GraphicRect{
+			{Start: Position{X: 10, Y: 10}, End: Position{X: 50, Y: 40}, Layer: "Edge.Cuts"},
+			{Start: Position{X: 0, Y: 0}, End: Position{X: 100, Y: 100}, Layer: "F.SilkS"},
+		},
+		Footprints: []Footprint{{
+			At: PositionAngle{X: 50, Y: 25, Angle: 90},
+			Circles: []GraphicCircle{
+				{Center: Position{X: 5}, End: Position{X: 7}, Layer: "Edge.Cuts"},
+			},
+		}},
+	}
+	min, max, ok := pcb.OutlineBounds()
+	if !ok {
+		t.Fatalf("board has no outline")
+	}
+	// The footprint's circle is rotated to be centered at 50,20.
+	wantMin, wantMax := Position{X: 10, Y: 10}, Position{X: 52, Y: 40}
+	if Distance(min, wantMin) > 1e-9 || Distance(max, wantMax) > 1e-9 {
+		t.Errorf("wrong bounds %v %v; want %v %v", min, max, wantMin, wantMax)
+	}
+
+	if _, _, ok := (&PCB{}).OutlineBounds(); ok {
+		t.Errorf("empty board should have no outline")
+	}
+}
@@ -0,0 +1,144 @@
+package pos
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/apparentlymart/go-kicad"
+	"github.com/apparentlymart/go-kicad/internal/fileio"
+)
+
+// WriteCSV writes the given placements to the given writer in KiCad's CSV
+// position file format.
+func WriteCSV(w io.Writer, placements []Placement, opts *Options) error {
+	if opts == nil {
+		opts = &Options{}
+	}
+	var buf bytes.Buffer
+	buf.WriteString("Ref,Val,Package,PosX,PosY,Rot,Side\n")
+	for _, pl := range placements {
+		fmt.Fprintf(&buf, "%s,%s,%s,%.4f,%.4f,%.6f,%s\n",
+			quote(pl.Reference), quote(pl.Value), quote(pl.Package),
+			scale(pl.Position.X, opts), scale(pl.Position.Y, opts), pl.Rotation, pl.Side,
+		)
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// WriteASCII writes the given placements from the given side of a board to
+// the given writer in KiCad's fixed-width ASCII position file format.
+func WriteASCII(w io.Writer, placements []Placement, side Side, opts *Options) error {
+	if opts == nil {
+		opts = &Options{}
+	}
+	var buf bytes.Buffer
+	if opts.CreationDate.IsZero() {
+		buf.WriteString("### Footprint positions ###\n")
+	} else {
+		fmt.Fprintf(&buf, "### Footprint positions - created on %s ###\n", opts.CreationDate.Format("2006-01-02T15:04:05-07:00"))
+	}
+	buf.WriteString("### Printed by go-kicad\n")
+	units := "mm"
+	if opts.Units == Inches {
+		units = "inches"
+	}
+	fmt.Fprintf(&buf, "## Unit = %s, Angle = deg.\n", units)
+	switch side {
+	case Top:
+		buf.WriteString("## Side : top\n")
+	case Bottom:
+		buf.WriteString("## Side : bottom\n")
+	default:
+		buf.WriteString("## Side : All\n")
+	}
+
+	// The reference column is two characters wider in the rows than in
+	// the header, to make room for the comment marker at the start of the
+	// header.
+	refWidth, valWidth, pkgWidth := len("Ref"), len("Val"), len("Package")
+	for _, pl := range placements {
+		refWidth = max(refWidth, len(pl.Reference))
+		valWidth = max(valWidth, len(pl.Value))
+		pkgWidth = max(pkgWidth, len(pl.Package))
+	}
+	fmt.Fprintf(&buf, "# %-*s  %-*s  %-*s  %9s  %9s  %8s  Side\n", refWidth, "Ref", valWidth, "Val", pkgWidth, "Package", "PosX", "PosY", "Rot")
+	for _, pl := range placements {
+		fmt.Fprintf(&buf, "%-*s  %-*s  %-*s  %9.4f  %9.4f  %8.4f  %s\n",
+			refWidth+2, pl.Reference, valWidth, pl.Value, pkgWidth, pl.Package,
+			scale(pl.Position.X, opts), scale(pl.Position.Y, opts), pl.Rotation, pl.Side,
+		)
+	}
+	buf.WriteString("## End\n")
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// Filename returns the name KiCad gives to the position file for the given
+// side of a board, given the base name of the board file without its
+// extension.
+func Filename(base string, side Side, ascii bool) string {
+	var suffix string
+	switch side {
+	case Top:
+		suffix = "-top"
+	case Bottom:
+		suffix = "-bottom"
+	default:
+		suffix = "-all"
+	}
+	if ascii {
+		return base + suffix + ".pos"
+	}
+	return base + suffix + "-pos.csv"
+}
+
+// WriteFiles writes position files for the given board into the given
+// directory, naming each as Filename does. Unless opts selects a single
+// file, it writes one file for each side of the board that has footprints
+// on it. It returns the paths of the files it wrote.
+func WriteFiles(dir, base string, pcb *kicad.PCB, opts *Options) ([]string, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+	sides := []Side{Top, Bottom}
+	if opts.SingleFile {
+		sides = []Side{BothSides}
+	}
+
+	var ret []string
+	for _, side := range sides {
+		placements := Placements(pcb, side, opts)
+		if len(placements) == 0 && !opts.SingleFile {
+			continue
+		}
+		filename := filepath.Join(dir, Filename(base, side, opts.ASCII))
+		err := fileio.WriteFile(filename, func(w io.Writer) error {
+			if opts.ASCII {
+				return WriteASCII(w, placements, side, opts)
+			}
+			return WriteCSV(w, placements, opts)
+		})
+		if err != nil {
+			return ret, err
+		}
+		ret = append(ret, filename)
+	}
+	return ret, nil
+}
+
+// scale converts a length in millimeters to the units chosen in opts.
+func scale(v float64, opts *Options) float64 {
+	if opts.Units == Inches {
+		return v / 25.4
+	}
+	return v
+}
+
+func quote(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, `""`) + `"`
+}
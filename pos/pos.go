@@ -0,0 +1,200 @@
+// Package pos writes the component position files that assembly houses use
+// to place parts on a board, also known as pick-and-place or centroid
+// files, in the CSV and ASCII formats that KiCad produces.
+//
+// Footprints marked as excluded from position files are always left out,
+// as are KiCad 5 "virtual" footprints. Options can exclude others.
+package pos
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/apparentlymart/go-kicad"
+)
+
+// Origin selects the point that positions are measured from.
+type Origin int
+
+const (
+	// PageOrigin measures positions from the top left corner of the
+	// page, which is the origin of the board document.
+	PageOrigin Origin = iota
+
+	// AuxOrigin measures positions from the board's auxiliary axis
+	// origin, which KiCad calls the drill and place file origin.
+	AuxOrigin
+
+	// GridOrigin measures positions from the board's grid origin.
+	GridOrigin
+)
+
+// DrillOrigin is the origin KiCad uses for drill and position files when
+// asked to use the "drill/place file origin", which is the auxiliary axis
+// origin.
+const DrillOrigin = AuxOrigin
+
+// Units selects the units of positions in a position file.
+type Units int
+
+const (
+	Millimeters Units = iota
+	Inches
+)
+
+// Side selects which side of a board to include footprints from.
+type Side int
+
+const (
+	BothSides Side = iota
+	Top
+	Bottom
+)
+
+// Options customizes the footprints included in position files and the
+// form of their positions. A nil *Options is equivalent to a pointer to
+// the zero value, which includes all footprints with positions in
+// millimeters from the page origin.
+type Options struct {
+	Origin Origin
+	Units  Units
+
+	// NegateBottomX gives the positions of footprints on the bottom of
+	// the board as seen from below, by negating their X coordinates and
+	// mirroring their rotation to match.
+	NegateBottomX bool
+
+	// SMDOnly includes only footprints whose type is "smd".
+	SMDOnly bool
+
+	// ExcludeThroughHole leaves out footprints that have any through-hole
+	// pads.
+	ExcludeThroughHole bool
+
+	// ExcludeDNP leaves out footprints marked "do not populate".
+	ExcludeDNP bool
+
+	// ASCII selects KiCad's fixed-width text format for WriteFiles,
+	// rather than CSV.
+	ASCII bool
+
+	// SingleFile makes WriteFiles write the footprints of both sides into
+	// one file, rather than one file for each side.
+	SingleFile bool
+
+	// CreationDate is recorded in the header of ASCII files. If it is
+	// zero then no date is recorded, so that the output depends only on
+	// the board.
+	CreationDate time.Time
+}
+
+// Placement is the position of one footprint on a board.
+type Placement struct {
+	Reference string
+	Value     string
+	Package   string
+
+	// Position is relative to the chosen origin, in millimeters, with Y
+	// increasing upwards as is conventional for position files.
+	Position kicad.Position
+
+	// Rotation is the rotation of the footprint in degrees,
+	// counterclockwise and in the range (-180, 180].
+	Rotation float64
+
+	// Side is "top" or "bottom".
+	Side string
+}
+
+// Placements returns the placements of the footprints of the given board
+// on the given side, ordered by reference designator.
+func Placements(pcb *kicad.PCB, side Side, opts *Options) []Placement {
+	if opts == nil {
+		opts = &Options{}
+	}
+	var origin kicad.Position
+	switch opts.Origin {
+	case AuxOrigin:
+		origin = pcb.Setup.AuxAxisOrigin
+	case GridOrigin:
+		origin = pcb.Setup.GridOrigin
+	}
+
+	var ret []Placement
+	for i := range pcb.Footprints {
+		fp := &pcb.Footprints[i]
+		bottom := fp.Layer == "B.Cu"
+		if (side == Top && bottom) || (side == Bottom && !bottom) || !included(fp, opts) {
+			continue
+		}
+
+		pl := Placement{
+			Reference: fp.Reference(),
+			Value:     fp.Value(),
+			Package:   packageName(fp.LibID),
+			Position: kicad.Position{
+				X: fp.At.X - origin.X,
+				Y: origin.Y - fp.At.Y,
+			},
+			Rotation: fp.At.Angle,
+			Side:     "top",
+		}
+		if bottom {
+			pl.Side = "bottom"
+			if opts.NegateBottomX {
+				pl.Position.X = -pl.Position.X
+				pl.Rotation = 180 - pl.Rotation
+			}
+		}
+		pl.Rotation = normalizeRotation(pl.Rotation)
+		ret = append(ret, pl)
+	}
+	sort.SliceStable(ret, func(i, j int) bool {
+		return kicad.ReferenceLess(ret[i].Reference, ret[j].Reference)
+	})
+	return ret
+}
+
+func included(fp *kicad.Footprint, opts *Options) bool {
+	attr := &fp.Attr
+	if attr.ExcludeFromPosFiles || attr.Type == "virtual" {
+		return false
+	}
+	if opts.SMDOnly && attr.Type != "smd" {
+		return false
+	}
+	if opts.ExcludeDNP && attr.DNP {
+		return false
+	}
+	if opts.ExcludeThroughHole {
+		for _, pad := range fp.Pads {
+			if pad.Type == "thru_hole" {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// packageName returns the name of a footprint within its library, which
+// position files use to describe the package of a part.
+func packageName(libID string) string {
+	if _, name, ok := strings.Cut(libID, ":"); ok {
+		return name
+	}
+	return libID
+}
+
+// normalizeRotation returns the given angle in degrees as an equivalent
+// angle in the range (-180, 180].
+func normalizeRotation(angle float64) float64 {
+	angle = math.Mod(angle, 360)
+	if angle <= -180 {
+		angle += 360
+	} else if angle > 180 {
+		angle -= 360
+	}
+	return angle
+}
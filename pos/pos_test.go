@@ -0,0 +1,188 @@
+package pos
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/apparentlymart/go-kicad"
+	"github.com/apparentlymart/go-kicad/internal/testboard"
+	"github.com/davecgh/go-spew/spew"
+)
+
+const testBoardSrc = `(kicad_pcb (version 20240108) (generator pcbnew)
+  (layers
+    (0 "F.Cu" signal)
+    (31 "B.Cu" signal)
+  )
+  (setup
+    (aux_axis_origin 100 100)
+    (grid_origin 50 50)
+  )
+  (footprint "Resistor_SMD:R_0603_1608Metric" (layer "F.Cu")
+    (at 110 90 90)
+    (property "Reference" "R10")
+    (property "Value" "10k")
+    (attr smd)
+  )
+  (footprint "Resistor_SMD:R_0603_1608Metric" (layer "F.Cu")
+    (at 105.5 95)
+    (property "Reference" "R2")
+    (property "Value" "4k7")
+    (attr smd)
+  )
+  (footprint "Package_SO:SOIC-8_3.9x4.9mm_P1.27mm" (layer "B.Cu")
+    (at 120 80 270)
+    (property "Reference" "U1")
+    (property "Value" "NE555")
+    (attr smd)
+  )
+  (footprint "Connector:Conn_01x02" (layer "F.Cu")
+    (at 130 90)
+    (property "Reference" "J1")
+    (property "Value" "Conn_01x02")
+    (attr through_hole)
+    (pad "1" thru_hole circle (at 0 0) (size 1.7 1.7) (drill 1) (layers "*.Cu" "*.Mask"))
+  )
+  (footprint "TestPoint:TestPoint_Pad_D1.0mm" (layer "F.Cu")
+    (at 140 90)
+    (property "Reference" "TP1")
+    (property "Value" "TestPoint")
+    (attr smd exclude_from_pos_files)
+  )
+  (footprint "Capacitor_SMD:C_0603_1608Metric" (layer "B.Cu")
+    (at 115 85 180)
+    (property "Reference" "C1")
+    (property "Value" "100n")
+    (attr smd dnp)
+  )
+)
+`
+
+func testBoard(t *testing.T) *kicad.PCB {
+	t.Helper()
+	pcb, err := kicad.ReadPCB(strings.NewReader(testBoardSrc))
+	if err != nil {
+		t.Fatalf("reading board: %s", err)
+	}
+	return pcb
+}
+
+func TestPlacements(t *testing.T) {
+	pcb := testBoard(t)
+
+	got := Placements(pcb, BothSides, &Options{Origin: AuxOrigin})
+	want := []Placement{
+		{"C1", "100n", "C_0603_1608Metric", kicad.Position{X: 15, Y: 15}, 180, "bottom"},
+		{"J1", "Conn_01x02", "Conn_01x02", kicad.Position{X: 30, Y: 10}, 0, "top"},
+		{"R2", "4k7", "R_0603_1608Metric", kicad.Position{X: 5.5, Y: 5}, 0, "top"},
+		{"R10", "10k", "R_0603_1608Metric", kicad.Position{X: 10, Y: 10}, 90, "top"},
+		{"U1", "NE555", "SOIC-8_3.9x4.9mm_P1.27mm", kicad.Position{X: 20, Y: 20}, -90, "bottom"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong placements\ngot:  %swant: %s", spew.Sdump(got), spew.Sdump(want))
+	}
+
+	got = Placements(pcb, Bottom, &Options{NegateBottomX: true, ExcludeDNP: true})
+	want = []Placement{
+		{"U1", "NE555", "SOIC-8_3.9x4.9mm_P1.27mm", kicad.Position{X: -120, Y: -80}, -90, "bottom"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong bottom placements\ngot:  %swant: %s", spew.Sdump(got), spew.Sdump(want))
+	}
+
+	var refs []string
+	for _, pl := range Placements(pcb, Top, &Options{Origin: GridOrigin, SMDOnly: true}) {
+		refs = append(refs, pl.Reference)
+	}
+	if want := []string{"R2", "R10"}; !reflect.DeepEqual(refs, want) {
+		t.Errorf("wrong SMD placements %q; want %q", refs, want)
+	}
+
+	refs = nil
+	for _, pl := range Placements(pcb, Top, &Options{ExcludeThroughHole: true}) {
+		refs = append(refs, pl.Reference)
+	}
+	if want := []string{"R2", "R10"}; !reflect.DeepEqual(refs, want) {
+		t.Errorf("wrong placements without through-hole parts %q; want %q", refs, want)
+	}
+}
+
+func TestPlacements_gridOrigin(t *testing.T) {
+	pcb := testboard.Read(t)
+	var got []Placement
+	for _, pl := range Placements(pcb, Top, &Options{Origin: GridOrigin}) {
+		if pl.Reference == "R2" || pl.Reference == "U1" {
+			got = append(got, pl)
+		}
+	}
+	want := []Placement{
+		{"R2", "10k", "R_0603", kicad.Position{X: 60, Y: -40}, 90, "top"},
+		{"U1", "Pads", "Pads", kicad.Position{X: 55, Y: -35}, 0, "top"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong placements\ngot:  %swant: %s", spew.Sdump(got), spew.Sdump(want))
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	pcb := testBoard(t)
+	opts := &Options{Origin: AuxOrigin}
+	var buf bytes.Buffer
+	err := WriteCSV(&buf, Placements(pcb, Top, opts), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := buf.String()
+	want := `Ref,Val,Package,PosX,PosY,Rot,Side
+"J1","Conn_01x02","Conn_01x02",30.0000,10.0000,0.000000,top
+"R2","4k7","R_0603_1608Metric",5.5000,5.0000,0.000000,top
+"R10","10k","R_0603_1608Metric",10.0000,10.0000,90.000000,top
+`
+	if got != want {
+		t.Errorf("incorrect result\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestWriteASCII(t *testing.T) {
+	pcb := testBoard(t)
+	opts := &Options{Origin: AuxOrigin, Units: Inches}
+	var buf bytes.Buffer
+	err := WriteASCII(&buf, Placements(pcb, Bottom, opts), Bottom, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := buf.String()
+	want := `### Footprint positions ###
+### Printed by go-kicad
+## Unit = inches, Angle = deg.
+## Side : bottom
+# Ref  Val    Package                        PosX       PosY       Rot  Side
+C1     100n   C_0603_1608Metric            0.5906     0.5906  180.0000  bottom
+U1     NE555  SOIC-8_3.9x4.9mm_P1.27mm     0.7874     0.7874  -90.0000  bottom
+## End
+`
+	if got != want {
+		t.Errorf("incorrect result\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestFilename(t *testing.T) {
+	tests := []struct {
+		side  Side
+		ascii bool
+		want  string
+	}{
+		{Top, false, "board-top-pos.csv"},
+		{Bottom, true, "board-bottom.pos"},
+		{BothSides, false, "board-all-pos.csv"},
+	}
+	for _, test := range tests {
+		if got := Filename("board", test.side, test.ascii); got != test.want {
+			t.Errorf("wrong filename %q; want %q", got, test.want)
+		}
+	}
+}
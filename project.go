@@ -0,0 +1,547 @@
+package kicad
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ReadProject reads a stream containing a KiCad 6 or later project file, as
+// found in a .kicad_pro file, and returns a Project structure describing
+// it.
+//
+// The Project structure describes only some of the settings in a project
+// file, but the others are retained so that WriteProject can write them
+// back out unchanged.
+func ReadProject(r io.Reader) (*Project, error) {
+	p := &Project{}
+	err := json.NewDecoder(r).Decode(p)
+	return p, err
+}
+
+// ReadProjectFile is a convenience wrapper around ReadProject that takes a
+// filename and opens the given file for reading before calling ReadProject.
+func ReadProjectFile(filename string) (*Project, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ReadProject(f)
+}
+
+// WriteProject writes the given project to the given writer in the same
+// form as KiCad itself does.
+func WriteProject(w io.Writer, p *Project) error {
+	return writeSettingsJSON(w, p)
+}
+
+// WriteProjectFile is a convenience wrapper around WriteProject that
+// creates or replaces the given file before calling WriteProject.
+func WriteProjectFile(filename string, p *Project) error {
+	return writeSettingsFile(filename, p)
+}
+
+// ReadProjectLocal reads a stream containing the local settings of a
+// project, as found in a .kicad_prl file, and returns a ProjectLocal
+// structure describing it. As with ReadProject, settings that the
+// structure doesn't describe are retained.
+func ReadProjectLocal(r io.Reader) (*ProjectLocal, error) {
+	p := &ProjectLocal{}
+	err := json.NewDecoder(r).Decode(p)
+	return p, err
+}
+
+// ReadProjectLocalFile is a convenience wrapper around ReadProjectLocal that
+// takes a filename and opens the given file for reading before calling
+// ReadProjectLocal.
+func ReadProjectLocalFile(filename string) (*ProjectLocal, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ReadProjectLocal(f)
+}
+
+// WriteProjectLocal writes the given local settings to the given writer in
+// the same form as KiCad itself does.
+func WriteProjectLocal(w io.Writer, p *ProjectLocal) error {
+	return writeSettingsJSON(w, p)
+}
+
+// WriteProjectLocalFile is a convenience wrapper around WriteProjectLocal
+// that creates or replaces the given file before calling WriteProjectLocal.
+func WriteProjectLocalFile(filename string, p *ProjectLocal) error {
+	return writeSettingsFile(filename, p)
+}
+
+func writeSettingsJSON(w io.Writer, v interface{}) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	return enc.Encode(v)
+}
+
+func writeSettingsFile(filename string, v interface{}) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+
+	err = writeSettingsJSON(f, v)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Project represents the settings in a KiCad project file.
+//
+// TextVariables are the project's text variables, which can be used in
+// text items as ${NAME}. Sheets lists the UUID and name of each top-level
+// sheet of the schematic.
+type Project struct {
+	Board         ProjectBoard       `json:"board"`
+	ERC           ProjectERC         `json:"erc"`
+	Meta          ProjectMeta        `json:"meta"`
+	NetSettings   ProjectNetSettings `json:"net_settings"`
+	Schematic     ProjectSchematic   `json:"schematic"`
+	Sheets        [][]string         `json:"sheets"`
+	TextVariables map[string]string  `json:"text_variables"`
+
+	jsonObject
+}
+
+func (p *Project) UnmarshalJSON(data []byte) error {
+	type plain Project
+	return unmarshalObject(data, (*plain)(p), &p.jsonObject)
+}
+
+func (p Project) MarshalJSON() ([]byte, error) {
+	type plain Project
+	return marshalObject(plain(p), p.jsonObject)
+}
+
+// NetClass returns the net class with the given name, or nil if there is no
+// such net class.
+func (p *Project) NetClass(name string) *ProjectNetClass {
+	for i := range p.NetSettings.Classes {
+		if p.NetSettings.Classes[i].Name == name {
+			return &p.NetSettings.Classes[i]
+		}
+	}
+	return nil
+}
+
+// ProjectMeta identifies a settings file and its format version.
+type ProjectMeta struct {
+	Filename string `json:"filename,omitempty"`
+	Version  int    `json:"version"`
+}
+
+// ProjectBoard holds the board settings of a project.
+type ProjectBoard struct {
+	DesignSettings ProjectDesignSettings `json:"design_settings"`
+
+	jsonObject
+}
+
+func (b *ProjectBoard) UnmarshalJSON(data []byte) error {
+	type plain ProjectBoard
+	return unmarshalObject(data, (*plain)(b), &b.jsonObject)
+}
+
+func (b ProjectBoard) MarshalJSON() ([]byte, error) {
+	type plain ProjectBoard
+	return marshalObject(plain(b), b.jsonObject)
+}
+
+// ProjectDesignSettings holds the design rule defaults of a board.
+//
+// RuleSeverities maps the name of each design rule check to its severity,
+// which is "error", "warning" or "ignore". TrackWidths, ViaDimensions and
+// DiffPairDimensions are the predefined sizes offered while routing, where
+// the first entry of each is a placeholder for the net class's own size.
+type ProjectDesignSettings struct {
+	Rules              ProjectDesignRules         `json:"rules"`
+	RuleSeverities     map[string]string          `json:"rule_severities"`
+	TrackWidths        []float64                  `json:"track_widths"`
+	ViaDimensions      []ProjectViaDimension      `json:"via_dimensions"`
+	DiffPairDimensions []ProjectDiffPairDimension `json:"diff_pair_dimensions"`
+
+	jsonObject
+}
+
+func (s *ProjectDesignSettings) UnmarshalJSON(data []byte) error {
+	type plain ProjectDesignSettings
+	return unmarshalObject(data, (*plain)(s), &s.jsonObject)
+}
+
+func (s ProjectDesignSettings) MarshalJSON() ([]byte, error) {
+	type plain ProjectDesignSettings
+	return marshalObject(plain(s), s.jsonObject)
+}
+
+// ProjectDesignRules holds the board's minimum constraints, in millimeters.
+type ProjectDesignRules struct {
+	MinClearance           float64 `json:"min_clearance"`
+	MinTrackWidth          float64 `json:"min_track_width"`
+	MinViaDiameter         float64 `json:"min_via_diameter"`
+	MinViaAnnularWidth     float64 `json:"min_via_annular_width"`
+	MinThroughHoleDiameter float64 `json:"min_through_hole_diameter"`
+	MinHoleClearance       float64 `json:"min_hole_clearance"`
+	MinHoleToHole          float64 `json:"min_hole_to_hole"`
+	MinCopperEdgeClearance float64 `json:"min_copper_edge_clearance"`
+	MinMicroviaDiameter    float64 `json:"min_microvia_diameter"`
+	MinMicroviaDrill       float64 `json:"min_microvia_drill"`
+	MinSilkClearance       float64 `json:"min_silk_clearance"`
+	MinTextHeight          float64 `json:"min_text_height"`
+	MinTextThickness       float64 `json:"min_text_thickness"`
+	MinResolvedSpokes      int     `json:"min_resolved_spokes"`
+	MaxError               float64 `json:"max_error"`
+	AllowMicrovias         bool    `json:"allow_microvias"`
+	AllowBlindBuriedVias   bool    `json:"allow_blind_buried_vias"`
+
+	jsonObject
+}
+
+func (r *ProjectDesignRules) UnmarshalJSON(data []byte) error {
+	type plain ProjectDesignRules
+	return unmarshalObject(data, (*plain)(r), &r.jsonObject)
+}
+
+func (r ProjectDesignRules) MarshalJSON() ([]byte, error) {
+	type plain ProjectDesignRules
+	return marshalObject(plain(r), r.jsonObject)
+}
+
+// ProjectViaDimension is a predefined via size.
+type ProjectViaDimension struct {
+	Diameter float64 `json:"diameter"`
+	Drill    float64 `json:"drill"`
+
+	jsonObject
+}
+
+func (d *ProjectViaDimension) UnmarshalJSON(data []byte) error {
+	type plain ProjectViaDimension
+	return unmarshalObject(data, (*plain)(d), &d.jsonObject)
+}
+
+func (d ProjectViaDimension) MarshalJSON() ([]byte, error) {
+	type plain ProjectViaDimension
+	return marshalObject(plain(d), d.jsonObject)
+}
+
+// ProjectDiffPairDimension is a predefined differential pair size.
+type ProjectDiffPairDimension struct {
+	Width  float64 `json:"width"`
+	Gap    float64 `json:"gap"`
+	ViaGap float64 `json:"via_gap"`
+
+	jsonObject
+}
+
+func (d *ProjectDiffPairDimension) UnmarshalJSON(data []byte) error {
+	type plain ProjectDiffPairDimension
+	return unmarshalObject(data, (*plain)(d), &d.jsonObject)
+}
+
+func (d ProjectDiffPairDimension) MarshalJSON() ([]byte, error) {
+	type plain ProjectDiffPairDimension
+	return marshalObject(plain(d), d.jsonObject)
+}
+
+// ProjectERC holds the electrical rule check settings of a project.
+//
+// RuleSeverities maps the name of each check to its severity, as for
+// ProjectDesignSettings. PinMap gives the severity of connecting each pair
+// of pin electrical types, where 0 is no error, 1 is a warning and 2 is an
+// error.
+type ProjectERC struct {
+	RuleSeverities map[string]string `json:"rule_severities"`
+	PinMap         [][]int           `json:"pin_map"`
+
+	jsonObject
+}
+
+func (e *ProjectERC) UnmarshalJSON(data []byte) error {
+	type plain ProjectERC
+	return unmarshalObject(data, (*plain)(e), &e.jsonObject)
+}
+
+func (e ProjectERC) MarshalJSON() ([]byte, error) {
+	type plain ProjectERC
+	return marshalObject(plain(e), e.jsonObject)
+}
+
+// ProjectNetSettings holds the net classes of a project.
+//
+// NetClassPatterns assign net classes to the nets whose names match their
+// wildcard patterns. KiCad 6 projects instead list the nets of each class
+// in ProjectNetClass.Nets.
+type ProjectNetSettings struct {
+	Classes          []ProjectNetClass        `json:"classes"`
+	NetClassPatterns []ProjectNetClassPattern `json:"netclass_patterns"`
+
+	jsonObject
+}
+
+func (s *ProjectNetSettings) UnmarshalJSON(data []byte) error {
+	type plain ProjectNetSettings
+	return unmarshalObject(data, (*plain)(s), &s.jsonObject)
+}
+
+func (s ProjectNetSettings) MarshalJSON() ([]byte, error) {
+	type plain ProjectNetSettings
+	return marshalObject(plain(s), s.jsonObject)
+}
+
+// ProjectNetClass is a net class, which gives the default design rules for
+// its nets. Sizes are in millimeters, except for WireWidth and BusWidth
+// which are in mils.
+type ProjectNetClass struct {
+	Name             string   `json:"name"`
+	Clearance        float64  `json:"clearance"`
+	TrackWidth       float64  `json:"track_width"`
+	ViaDiameter      float64  `json:"via_diameter"`
+	ViaDrill         float64  `json:"via_drill"`
+	MicroviaDiameter float64  `json:"microvia_diameter"`
+	MicroviaDrill    float64  `json:"microvia_drill"`
+	DiffPairWidth    float64  `json:"diff_pair_width"`
+	DiffPairGap      float64  `json:"diff_pair_gap"`
+	DiffPairViaGap   float64  `json:"diff_pair_via_gap"`
+	WireWidth        float64  `json:"wire_width"`
+	BusWidth         float64  `json:"bus_width"`
+	LineStyle        int      `json:"line_style"`
+	PCBColor         string   `json:"pcb_color"`
+	SchematicColor   string   `json:"schematic_color"`
+	Nets             []string `json:"nets"`
+
+	jsonObject
+}
+
+func (c *ProjectNetClass) UnmarshalJSON(data []byte) error {
+	type plain ProjectNetClass
+	return unmarshalObject(data, (*plain)(c), &c.jsonObject)
+}
+
+func (c ProjectNetClass) MarshalJSON() ([]byte, error) {
+	type plain ProjectNetClass
+	return marshalObject(plain(c), c.jsonObject)
+}
+
+// ProjectNetClassPattern assigns a net class to nets matching a pattern.
+type ProjectNetClassPattern struct {
+	NetClass string `json:"netclass"`
+	Pattern  string `json:"pattern"`
+
+	jsonObject
+}
+
+func (p *ProjectNetClassPattern) UnmarshalJSON(data []byte) error {
+	type plain ProjectNetClassPattern
+	return unmarshalObject(data, (*plain)(p), &p.jsonObject)
+}
+
+func (p ProjectNetClassPattern) MarshalJSON() ([]byte, error) {
+	type plain ProjectNetClassPattern
+	return marshalObject(plain(p), p.jsonObject)
+}
+
+// ProjectSchematic holds the schematic settings of a project.
+//
+// DrawingSheetFile is the .kicad_wks file of the schematic's drawing
+// sheet, which may refer to ${KIPRJMOD} or other path variables. It is
+// empty if the schematic uses the default drawing sheet.
+type ProjectSchematic struct {
+	BOMSettings      ProjectBOMSettings `json:"bom_settings"`
+	DrawingSheetFile string             `json:"page_layout_descr_file"`
+
+	jsonObject
+}
+
+func (s *ProjectSchematic) UnmarshalJSON(data []byte) error {
+	type plain ProjectSchematic
+	return unmarshalObject(data, (*plain)(s), &s.jsonObject)
+}
+
+func (s ProjectSchematic) MarshalJSON() ([]byte, error) {
+	type plain ProjectSchematic
+	return marshalObject(plain(s), s.jsonObject)
+}
+
+// ProjectBOMSettings holds the settings of KiCad's bill of materials
+// editor, which KiCad 7 and later store in the project.
+type ProjectBOMSettings struct {
+	Name          string            `json:"name"`
+	FieldsOrdered []ProjectBOMField `json:"fields_ordered"`
+	FilterString  string            `json:"filter_string"`
+	GroupSymbols  bool              `json:"group_symbols"`
+	ExcludeDNP    bool              `json:"exclude_dnp"`
+	SortField     string            `json:"sort_field"`
+	SortAsc       bool              `json:"sort_asc"`
+
+	jsonObject
+}
+
+func (s *ProjectBOMSettings) UnmarshalJSON(data []byte) error {
+	type plain ProjectBOMSettings
+	return unmarshalObject(data, (*plain)(s), &s.jsonObject)
+}
+
+func (s ProjectBOMSettings) MarshalJSON() ([]byte, error) {
+	type plain ProjectBOMSettings
+	return marshalObject(plain(s), s.jsonObject)
+}
+
+// ProjectBOMField is a column of the bill of materials. Name is the symbol
+// field it shows, and Label is its heading.
+type ProjectBOMField struct {
+	Name    string `json:"name"`
+	Label   string `json:"label"`
+	Show    bool   `json:"show"`
+	GroupBy bool   `json:"group_by"`
+
+	jsonObject
+}
+
+func (f *ProjectBOMField) UnmarshalJSON(data []byte) error {
+	type plain ProjectBOMField
+	return unmarshalObject(data, (*plain)(f), &f.jsonObject)
+}
+
+func (f ProjectBOMField) MarshalJSON() ([]byte, error) {
+	type plain ProjectBOMField
+	return marshalObject(plain(f), f.jsonObject)
+}
+
+// ProjectLocal represents the local settings of a project, which record
+// the state of the user interface rather than the design itself.
+type ProjectLocal struct {
+	Board ProjectLocalBoard `json:"board"`
+	Meta  ProjectMeta       `json:"meta"`
+
+	jsonObject
+}
+
+func (p *ProjectLocal) UnmarshalJSON(data []byte) error {
+	type plain ProjectLocal
+	return unmarshalObject(data, (*plain)(p), &p.jsonObject)
+}
+
+func (p ProjectLocal) MarshalJSON() ([]byte, error) {
+	type plain ProjectLocal
+	return marshalObject(plain(p), p.jsonObject)
+}
+
+// ProjectLocalBoard holds the local board editor settings of a project.
+// VisibleLayers is a hexadecimal layer mask, like the layer selections of
+// PCBPlotParams.
+type ProjectLocalBoard struct {
+	ActiveLayer      int      `json:"active_layer"`
+	VisibleLayers    string   `json:"visible_layers"`
+	HiddenNets       []string `json:"hidden_nets"`
+	HiddenNetClasses []string `json:"hidden_netclasses"`
+
+	jsonObject
+}
+
+func (b *ProjectLocalBoard) UnmarshalJSON(data []byte) error {
+	type plain ProjectLocalBoard
+	return unmarshalObject(data, (*plain)(b), &b.jsonObject)
+}
+
+func (b ProjectLocalBoard) MarshalJSON() ([]byte, error) {
+	type plain ProjectLocalBoard
+	return marshalObject(plain(b), b.jsonObject)
+}
+
+// LoadedProject is a project loaded from its directory, with the documents
+// that make up the design.
+//
+// Local, PCB and Schematic are nil if the project has no such file.
+type LoadedProject struct {
+	Dir       string
+	Name      string
+	Project   *Project
+	Local     *ProjectLocal
+	PCB       *PCB
+	Schematic *SchematicHierarchy
+}
+
+// LoadProject finds the single KiCad project file in the given directory
+// and loads it along with the project's local settings, board and
+// schematic, which are the files in the same directory with the same name
+// as the project file.
+//
+// Only projects from KiCad 6 and later are supported. See the legacy
+// package for reading schematics from KiCad 5 projects.
+func LoadProject(dir string) (*LoadedProject, error) {
+	matches, err := filepath.Glob(filepath.Join(dir, "*.kicad_pro"))
+	if err != nil {
+		return nil, err
+	}
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("no KiCad project file in %s", dir)
+	case 1:
+	default:
+		return nil, fmt.Errorf("more than one KiCad project file in %s", dir)
+	}
+
+	base := strings.TrimSuffix(matches[0], ".kicad_pro")
+	lp := &LoadedProject{
+		Dir:  dir,
+		Name: filepath.Base(base),
+	}
+
+	lp.Project, err = ReadProjectFile(matches[0])
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", matches[0], err)
+	}
+
+	local, err := ReadProjectLocalFile(base + ".kicad_prl")
+	if err := optionalFileErr(base+".kicad_prl", err); err != nil {
+		return nil, err
+	}
+	if err == nil {
+		lp.Local = local
+	}
+
+	pcb, err := ReadPCBFile(base + ".kicad_pcb")
+	if err := optionalFileErr(base+".kicad_pcb", err); err != nil {
+		return nil, err
+	}
+	if err == nil {
+		lp.PCB = pcb
+	}
+
+	sch, err := LoadSchematicHierarchy(base + ".kicad_sch")
+	if err := optionalFileErr(base+".kicad_sch", err); err != nil {
+		return nil, err
+	}
+	if err == nil {
+		lp.Schematic = sch
+	}
+
+	return lp, nil
+}
+
+// optionalFileErr returns nil if the given error is nil or reports that the
+// file doesn't exist, and otherwise returns it annotated with the filename.
+func optionalFileErr(filename string, err error) error {
+	if err == nil || errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return fmt.Errorf("reading %s: %w", filename, err)
+}
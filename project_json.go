@@ -0,0 +1,77 @@
+package kicad
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// jsonObject holds the original members of a JSON object that was decoded
+// into a struct, so that members the struct doesn't describe can be written
+// back out unchanged.
+//
+// Types that embed it implement json.Unmarshaler and json.Marshaler using
+// unmarshalObject and marshalObject.
+type jsonObject struct {
+	raw map[string]json.RawMessage
+}
+
+// unmarshalObject decodes the given JSON object into v, which must be a
+// pointer to a struct type without its own UnmarshalJSON method, and
+// records all of its members in obj.
+func unmarshalObject(data []byte, v interface{}, obj *jsonObject) error {
+	if err := json.Unmarshal(data, v); err != nil {
+		return err
+	}
+	return json.Unmarshal(data, &obj.raw)
+}
+
+// marshalObject encodes v, which must be a struct type without its own
+// MarshalJSON method, merged with the original members recorded in obj.
+//
+// Members that v describes replace the original ones. Those that have
+// their zero value are omitted unless they were present originally, so
+// that encoding a decoded object doesn't add members that weren't there.
+func marshalObject(v interface{}, obj jsonObject) ([]byte, error) {
+	known, err := marshalJSON(v)
+	if err != nil {
+		return nil, err
+	}
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(known, &members); err != nil {
+		return nil, err
+	}
+
+	out := make(map[string]json.RawMessage, len(obj.raw)+len(members))
+	for k, val := range obj.raw {
+		out[k] = val
+	}
+	for k, val := range members {
+		if _, ok := obj.raw[k]; !ok && isZeroJSON(val) {
+			continue
+		}
+		out[k] = val
+	}
+	// Go's encoder sorts map keys, which matches KiCad's own ordering
+	return marshalJSON(out)
+}
+
+// marshalJSON is like json.Marshal, but without escaping the characters
+// that are special in HTML, which KiCad doesn't do.
+func marshalJSON(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+func isZeroJSON(val json.RawMessage) bool {
+	switch string(bytes.TrimSpace(val)) {
+	case "null", "0", `""`, "false", "[]", "{}":
+		return true
+	default:
+		return false
+	}
+}
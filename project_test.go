@@ -0,0 +1,332 @@
+package kicad
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testProjectSrc = `{
+  "board": {
+    "3dviewports": [],
+    "design_settings": {
+      "defaults": {
+        "board_outline_line_width": 0.1
+      },
+      "diff_pair_dimensions": [],
+      "meta": {
+        "version": 2
+      },
+      "rule_severities": {
+        "clearance": "error",
+        "silk_overlap": "ignore"
+      },
+      "rules": {
+        "allow_microvias": false,
+        "max_error": 0.005,
+        "min_clearance": 0.0,
+        "min_hole_to_hole": 0.25,
+        "min_resolved_spokes": 2,
+        "min_track_width": 0.2,
+        "min_via_diameter": 0.5,
+        "use_height_for_length_calcs": true
+      },
+      "track_widths": [0.0, 0.25, 0.5],
+      "via_dimensions": [{"diameter": 0.0, "drill": 0.0}, {"diameter": 0.8, "drill": 0.4}]
+    },
+    "layer_presets": []
+  },
+  "erc": {
+    "meta": {"version": 0},
+    "pin_map": [[0, 1], [1, 2]],
+    "rule_severities": {"pin_not_connected": "error"}
+  },
+  "meta": {
+    "filename": "test.kicad_pro",
+    "version": 1
+  },
+  "net_settings": {
+    "classes": [
+      {"bus_width": 12, "clearance": 0.2, "name": "Default", "pcb_color": "rgba(0, 0, 0, 0.000)", "track_width": 0.25, "via_diameter": 0.8, "via_drill": 0.4, "wire_width": 6},
+      {"clearance": 0.5, "name": "HV", "track_width": 0.5, "tuning_profile": "x"}
+    ],
+    "meta": {"version": 3},
+    "net_colors": null,
+    "netclass_patterns": [{"netclass": "HV", "pattern": "/HV*"}]
+  },
+  "pcbnew": {"page_layout_descr_file": ""},
+  "schematic": {
+    "bom_settings": {
+      "exclude_dnp": false,
+      "fields_ordered": [
+        {"group_by": false, "label": "Reference", "name": "Reference", "show": true},
+        {"group_by": true, "label": "Value", "name": "Value", "show": true}
+      ],
+      "filter_string": "",
+      "group_symbols": true,
+      "name": "Grouped By Value",
+      "sort_asc": true,
+      "sort_field": "Reference"
+    },
+    "subpart_first_id": 65
+  },
+  "sheets": [["0b8c3a4e-0000-4000-8000-000000000001", "Root"]],
+  "text_variables": {"REVISION": "A"}
+}
+`
+
+func TestReadProject(t *testing.T) {
+	p, err := ReadProject(strings.NewReader(testProjectSrc))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if got, want := p.Meta, (ProjectMeta{Filename: "test.kicad_pro", Version: 1}); got != want {
+		t.Errorf("wrong meta %#v; want %#v", got, want)
+	}
+	if got, want := p.TextVariables["REVISION"], "A"; got != want {
+		t.Errorf("wrong REVISION %q; want %q", got, want)
+	}
+	if got, want := p.Sheets, [][]string{{"0b8c3a4e-0000-4000-8000-000000000001", "Root"}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong sheets %#v; want %#v", got, want)
+	}
+
+	ds := p.Board.DesignSettings
+	if got, want := ds.Rules.MinTrackWidth, 0.2; got != want {
+		t.Errorf("wrong min track width %v; want %v", got, want)
+	}
+	if got, want := ds.Rules.MinResolvedSpokes, 2; got != want {
+		t.Errorf("wrong min resolved spokes %v; want %v", got, want)
+	}
+	if got, want := ds.RuleSeverities["silk_overlap"], "ignore"; got != want {
+		t.Errorf("wrong silk_overlap severity %q; want %q", got, want)
+	}
+	if got, want := len(ds.ViaDimensions), 2; got != want {
+		t.Fatalf("wrong number of via dimensions %d; want %d", got, want)
+	}
+	if got, want := ds.ViaDimensions[1].Diameter, 0.8; got != want {
+		t.Errorf("wrong via diameter %v; want %v", got, want)
+	}
+	if got, want := p.ERC.PinMap, [][]int{{0, 1}, {1, 2}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong pin map %#v; want %#v", got, want)
+	}
+
+	hv := p.NetClass("HV")
+	if hv == nil {
+		t.Fatalf("no HV net class")
+	}
+	if got, want := hv.Clearance, 0.5; got != want {
+		t.Errorf("wrong HV clearance %v; want %v", got, want)
+	}
+	if p.NetClass("LV") != nil {
+		t.Errorf("found nonexistent net class LV")
+	}
+	if got, want := len(p.NetSettings.NetClassPatterns), 1; got != want {
+		t.Fatalf("wrong number of patterns %d; want %d", got, want)
+	}
+	if got, want := p.NetSettings.NetClassPatterns[0].Pattern, "/HV*"; got != want {
+		t.Errorf("wrong pattern %q; want %q", got, want)
+	}
+
+	bom := p.Schematic.BOMSettings
+	if got, want := bom.SortField, "Reference"; got != want {
+		t.Errorf("wrong BOM sort field %q; want %q", got, want)
+	}
+	if got, want := len(bom.FieldsOrdered), 2; got != want {
+		t.Fatalf("wrong number of BOM fields %d; want %d", got, want)
+	}
+	if !bom.FieldsOrdered[1].GroupBy {
+		t.Errorf("BOM Value field is not grouped")
+	}
+}
+
+func TestWriteProject(t *testing.T) {
+	p, err := ReadProject(strings.NewReader(testProjectSrc))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	p.Board.DesignSettings.Rules.MinClearance = 0.15
+	p.TextVariables["REVISION"] = "B"
+	p.NetClass("HV").TrackWidth = 1
+
+	var buf bytes.Buffer
+	if err := WriteProject(&buf, p); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// Re-decoding generically shows what was written, including the
+	// members that Project doesn't describe.
+	var got, want interface{}
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("invalid result: %s\n%s", err, buf.String())
+	}
+	if err := json.Unmarshal([]byte(testProjectSrc), &want); err != nil {
+		t.Fatal(err)
+	}
+	wantObj := want.(map[string]interface{})
+	rules := wantObj["board"].(map[string]interface{})["design_settings"].(map[string]interface{})["rules"].(map[string]interface{})
+	rules["min_clearance"] = 0.15
+	wantObj["text_variables"].(map[string]interface{})["REVISION"] = "B"
+	classes := wantObj["net_settings"].(map[string]interface{})["classes"].([]interface{})
+	classes[1].(map[string]interface{})["track_width"] = 1.0
+
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("incorrect result\ngot:\n%s", buf.String())
+	}
+	if !strings.HasPrefix(buf.String(), "{\n  \"board\": {\n    \"3dviewports\": [],\n") {
+		t.Errorf("result not indented as KiCad does\n%s", buf.String())
+	}
+}
+
+func TestWriteProject_nested(t *testing.T) {
+	// Members that KiCad adds to the objects within lists must survive,
+	// and strings are written without escaping characters such as "&".
+	src := `{
+  "board": {
+    "design_settings": {
+      "diff_pair_dimensions": [
+        {
+          "gap": 0.2,
+          "via_gap": 0.3,
+          "width": 0.15,
+          "x_future": 1
+        }
+      ],
+      "via_dimensions": [
+        {
+          "diameter": 0.8,
+          "drill": 0.4,
+          "x_future": 2
+        }
+      ]
+    }
+  },
+  "meta": {
+    "filename": "nested.kicad_pro",
+    "version": 1
+  },
+  "net_settings": {
+    "netclass_patterns": [
+      {
+        "netclass": "HV",
+        "pattern": "/HV*",
+        "x_future": 3
+      }
+    ]
+  },
+  "schematic": {
+    "bom_settings": {
+      "fields_ordered": [
+        {
+          "group_by": false,
+          "label": "R&D Notes",
+          "name": "Notes",
+          "show": true,
+          "x_future": 4
+        }
+      ]
+    }
+  }
+}
+`
+	p, err := ReadProject(strings.NewReader(src))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteProject(&buf, p); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got, want := buf.String(), src; got != want {
+		t.Errorf("incorrect result\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestWriteProjectNew(t *testing.T) {
+	p := &Project{
+		Meta:          ProjectMeta{Filename: "new.kicad_pro", Version: 1},
+		TextVariables: map[string]string{"REVISION": "1"},
+	}
+
+	var buf bytes.Buffer
+	if err := WriteProject(&buf, p); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// Empty sections are omitted, as are the members of sections that
+	// would be written with their zero values.
+	want := `{
+  "meta": {
+    "filename": "new.kicad_pro",
+    "version": 1
+  },
+  "text_variables": {
+    "REVISION": "1"
+  }
+}
+`
+	if got := buf.String(); got != want {
+		t.Errorf("incorrect result\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestLoadProject(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, src string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("test.kicad_pro", testProjectSrc)
+	write("test.kicad_prl", `{"board": {"active_layer": 31, "hidden_nets": ["GND"], "visible_layers": "fffffff_ffffffff"}, "meta": {"filename": "test.kicad_prl", "version": 3}}`)
+	write("test.kicad_pcb", `(kicad_pcb (version 20221018) (generator pcbnew))`)
+	write("test.kicad_sch", `(kicad_sch (version 20231120) (generator "eeschema") (uuid "0b8c3a4e-0000-4000-8000-000000000001") (paper "A4"))`)
+
+	lp, err := LoadProject(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got, want := lp.Name, "test"; got != want {
+		t.Errorf("wrong name %q; want %q", got, want)
+	}
+	if lp.Project == nil || lp.Project.TextVariables["REVISION"] != "A" {
+		t.Errorf("project not loaded: %#v", lp.Project)
+	}
+	if lp.Local == nil {
+		t.Fatalf("local settings not loaded")
+	}
+	if got, want := lp.Local.Board.ActiveLayer, 31; got != want {
+		t.Errorf("wrong active layer %d; want %d", got, want)
+	}
+	if lp.PCB == nil || lp.PCB.Version != 20221018 {
+		t.Errorf("board not loaded: %#v", lp.PCB)
+	}
+	if lp.Schematic == nil || lp.Schematic.Root.UUID != "0b8c3a4e-0000-4000-8000-000000000001" {
+		t.Errorf("schematic not loaded: %#v", lp.Schematic)
+	}
+
+	// The board and schematic are optional.
+	os.Remove(filepath.Join(dir, "test.kicad_pcb"))
+	os.Remove(filepath.Join(dir, "test.kicad_prl"))
+	lp, err = LoadProject(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if lp.PCB != nil || lp.Local != nil {
+		t.Errorf("loaded nonexistent files")
+	}
+
+	write("other.kicad_pro", "{}")
+	if _, err := LoadProject(dir); err == nil {
+		t.Errorf("no error for ambiguous project")
+	}
+	if _, err := LoadProject(t.TempDir()); err == nil {
+		t.Errorf("no error for missing project")
+	}
+}
@@ -0,0 +1,44 @@
+package kicad
+
+import (
+	"strings"
+)
+
+// ReferenceLess returns true if reference designator a sorts before b.
+// Runs of digits compare by their numeric value, so that "R2" sorts before
+// "R10" as it does in KiCad's BOM and position files.
+func ReferenceLess(a, b string) bool {
+	for a != "" && b != "" {
+		ra, rb := a[0], b[0]
+		if isDigit(ra) && isDigit(rb) {
+			na, restA := leadingDigits(a)
+			nb, restB := leadingDigits(b)
+			ta, tb := strings.TrimLeft(na, "0"), strings.TrimLeft(nb, "0")
+			if len(ta) != len(tb) {
+				return len(ta) < len(tb)
+			}
+			if ta != tb {
+				return ta < tb
+			}
+			a, b = restA, restB
+			continue
+		}
+		if ra != rb {
+			return ra < rb
+		}
+		a, b = a[1:], b[1:]
+	}
+	return len(a) < len(b)
+}
+
+func leadingDigits(s string) (digits, rest string) {
+	i := 0
+	for i < len(s) && isDigit(s[i]) {
+		i++
+	}
+	return s[:i], s[i:]
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
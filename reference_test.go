@@ -0,0 +1,25 @@
+package kicad
+
+import (
+	"testing"
+)
+
+func TestReferenceLess(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"R2", "R10", true},
+		{"R10", "R2", false},
+		{"R1", "R1", false},
+		{"C10", "R1", true},
+		{"U1A", "U1B", true},
+		{"R02", "R3", true},
+		{"R1", "R1A", true},
+	}
+	for _, test := range tests {
+		if got := ReferenceLess(test.a, test.b); got != test.want {
+			t.Errorf("ReferenceLess(%q, %q) = %v; want %v", test.a, test.b, got, test.want)
+		}
+	}
+}
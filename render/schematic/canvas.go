@@ -0,0 +1,114 @@
+package schematic
+
+import (
+	"math"
+	"strconv"
+
+	"github.com/apparentlymart/go-kicad"
+)
+
+// canvas is a surface that a page is drawn on, which is implemented for
+// each output format. All positions and lengths are in millimeters from the
+// top left corner of the page.
+type canvas interface {
+	// path draws the given polylines, closing each of them if closed is
+	// true.
+	path(lines [][]kicad.Position, closed bool, st *style)
+
+	circle(center kicad.Position, r float64, st *style)
+
+	// image draws the given PNG image centered on center and scaled to
+	// the given size. Canvases that can't draw images ignore them.
+	image(center kicad.Position, size kicad.Size, png []byte)
+}
+
+// style describes how a shape is drawn. The outline is drawn only if width
+// is greater than zero, and the interior only if fill is not nil.
+type style struct {
+	width  float64
+	stroke kicad.Color
+	fill   *kicad.Color
+
+	// dash alternates the lengths of the dashes and of the gaps between
+	// them, or is nil for a solid line.
+	dash []float64
+}
+
+// dashPattern returns the dash lengths of the given KiCad line style with
+// the given width, or nil for a solid line.
+func dashPattern(lineType string, width float64) []float64 {
+	dash, dot, gap := 12*width, 0.2*width, 4*width
+	switch lineType {
+	case "dash":
+		return []float64{dash, gap}
+	case "dot":
+		return []float64{dot, gap}
+	case "dash_dot":
+		return []float64{dash, gap, dot, gap}
+	case "dash_dot_dot":
+		return []float64{dash, gap, dot, gap, dot, gap}
+	default:
+		return nil
+	}
+}
+
+// transform maps positions within a symbol, where Y increases upwards, to
+// positions on the page.
+type transform struct {
+	origin kicad.Position
+
+	// x1 and y1 give the page X coordinate and x2 and y2 the page Y
+	// coordinate of a symbol position, relative to the origin.
+	x1, y1, x2, y2 float64
+}
+
+// symbolTransform returns the transform of a symbol placed at the given
+// position with the given rotation and mirroring. The symbol is rotated
+// first and then mirrored about the given axis of the page.
+func symbolTransform(at kicad.PositionAngle, mirror string) transform {
+	t := transform{origin: at.Position()}
+	// Symbol coordinates have Y increasing upwards.
+	ex := kicad.RotatePoint(kicad.Position{X: 1}, at.Angle)
+	ey := kicad.RotatePoint(kicad.Position{Y: -1}, at.Angle)
+	switch mirror {
+	case "x":
+		ex.Y, ey.Y = -ex.Y, -ey.Y
+	case "y":
+		ex.X, ey.X = -ex.X, -ey.X
+	}
+	t.x1, t.y1 = ex.X, ey.X
+	t.x2, t.y2 = ex.Y, ey.Y
+	return t
+}
+
+// pageTransform is the transform of items that are placed directly on the
+// page, with no rotation or mirroring.
+var pageTransform = transform{x1: 1, y2: -1}
+
+func (t transform) apply(p kicad.Position) kicad.Position {
+	v := t.vector(p)
+	return kicad.Position{X: t.origin.X + v.X, Y: t.origin.Y + v.Y}
+}
+
+// vector transforms a direction, without moving it to the origin.
+func (t transform) vector(p kicad.Position) kicad.Position {
+	return kicad.Position{
+		X: t.x1*p.X + t.y1*p.Y,
+		Y: t.x2*p.X + t.y2*p.Y,
+	}
+}
+
+func (t transform) points(points []kicad.Position) []kicad.Position {
+	return kicad.TransformPoints(points, t.apply)
+}
+
+// num formats a length in millimeters, to the nearest tenth of a
+// micrometer and without trailing zeros.
+func num(v float64) string {
+	v = math.Round(v*1e4) / 1e4
+	if v == 0 {
+		// Avoid writing negative zero
+		v = 0
+	}
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
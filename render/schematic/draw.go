@@ -0,0 +1,354 @@
+package schematic
+
+import (
+	"bytes"
+	"image/png"
+	"math"
+
+	"github.com/apparentlymart/go-kicad"
+	"github.com/apparentlymart/go-kicad/strokefont"
+)
+
+// The colors of KiCad's default schematic color theme.
+var (
+	wireColor           = kicad.Color{R: 0, G: 150, B: 0, A: 1}
+	busColor            = kicad.Color{R: 0, G: 0, B: 132, A: 1}
+	junctionColor       = kicad.Color{R: 0, G: 150, B: 0, A: 1}
+	noConnectColor      = kicad.Color{R: 0, G: 0, B: 132, A: 1}
+	bodyColor           = kicad.Color{R: 132, G: 0, B: 0, A: 1}
+	bodyBackgroundColor = kicad.Color{R: 255, G: 255, B: 194, A: 1}
+	pinColor            = kicad.Color{R: 132, G: 0, B: 0, A: 1}
+	pinNameColor        = kicad.Color{R: 0, G: 100, B: 100, A: 1}
+	pinNumberColor      = kicad.Color{R: 169, G: 0, B: 0, A: 1}
+	referenceColor      = kicad.Color{R: 0, G: 100, B: 100, A: 1}
+	valueColor          = kicad.Color{R: 0, G: 100, B: 100, A: 1}
+	fieldColor          = kicad.Color{R: 132, G: 0, B: 132, A: 1}
+	labelColor          = kicad.Color{R: 15, G: 15, B: 15, A: 1}
+	globalLabelColor    = kicad.Color{R: 132, G: 0, B: 0, A: 1}
+	hierLabelColor      = kicad.Color{R: 114, G: 86, B: 0, A: 1}
+	noteColor           = kicad.Color{R: 0, G: 0, B: 194, A: 1}
+	sheetColor          = kicad.Color{R: 132, G: 0, B: 0, A: 1}
+	sheetNameColor      = kicad.Color{R: 0, G: 100, B: 100, A: 1}
+	sheetFileColor      = kicad.Color{R: 114, G: 86, B: 0, A: 1}
+	sheetPinColor       = kicad.Color{R: 114, G: 86, B: 0, A: 1}
+	frameColor          = kicad.Color{R: 132, G: 0, B: 0, A: 1}
+)
+
+// Default sizes of items in millimeters, for items that don't give their
+// own.
+const (
+	defaultLineWidth    = 0.1524
+	defaultBusWidth     = 0.3048
+	junctionDiameter    = 0.9144
+	noConnectSize       = 1.2192
+	bitmapPixelsPerInch = 300
+)
+
+// color returns the given color of an item, or the default color if it is
+// the zero value. In monochrome drawings every color is black.
+func (r *renderer) color(c, def kicad.Color) kicad.Color {
+	if c == (kicad.Color{}) {
+		c = def
+	}
+	if r.opts.Monochrome {
+		return kicad.Color{A: c.A}
+	}
+	return c
+}
+
+// lineStyle returns the style of a solid line of the given width and
+// color, using the defaults for any that are zero.
+func (r *renderer) lineStyle(width float64, c, def kicad.Color) *style {
+	if width == 0 {
+		width = defaultLineWidth
+	}
+	return &style{width: width, stroke: r.color(c, def)}
+}
+
+// strokeStyle returns the style of a line drawn with the given stroke,
+// whose width is defWidth if the stroke doesn't give one. A stroke with a
+// negative width isn't drawn.
+func (r *renderer) strokeStyle(stroke kicad.Stroke, def kicad.Color, defWidth float64) *style {
+	width := stroke.Width
+	if width == 0 {
+		width = defWidth
+	}
+	if width < 0 {
+		width = 0
+	}
+	return &style{
+		width:  width,
+		stroke: r.color(stroke.Color, def),
+		dash:   dashPattern(stroke.Type, width),
+	}
+}
+
+// shapePart selects which parts of a shape to draw. Symbols draw their
+// background fills before anything else, so that the fills don't hide
+// other parts of the symbol.
+type shapePart int
+
+const (
+	wholeShape shapePart = iota
+	backgroundOnly
+	foregroundOnly
+)
+
+// fillColor returns the color that fills a shape with the given fill and
+// outline color, or nil if the shape isn't filled in the given part.
+func (r *renderer) fillColor(fill kicad.SchFill, outline kicad.Color, part shapePart) *kicad.Color {
+	var c kicad.Color
+	switch fill.Type {
+	case "outline":
+		c = outline
+	case "background":
+		if r.opts.Monochrome {
+			return nil
+		}
+		c = bodyBackgroundColor
+	case "color":
+		if r.opts.Monochrome {
+			return nil
+		}
+		c = r.color(fill.Color, bodyBackgroundColor)
+	default:
+		return nil
+	}
+	background := fill.Type == "background"
+	if (part == backgroundOnly && !background) || (part == foregroundOnly && background) {
+		return nil
+	}
+	return &c
+}
+
+// shape draws a closed or open shape given as a polyline.
+func (r *renderer) shape(points []kicad.Position, closed bool, stroke kicad.Stroke, fill kicad.SchFill, def kicad.Color, part shapePart) {
+	st := r.strokeStyle(stroke, def, defaultLineWidth)
+	st.fill = r.fillColor(fill, st.stroke, part)
+	if part == backgroundOnly {
+		st.width = 0
+	}
+	if st.width > 0 || st.fill != nil {
+		r.c.path([][]kicad.Position{points}, closed, st)
+	}
+}
+
+// graphicItems draws the given graphic items, with their positions
+// transformed by tr. Items that are private to a symbol are shown only in
+// the symbol editor, and so aren't drawn.
+func (r *renderer) graphicItems(arcs []kicad.SchArc, circles []kicad.SchCircle, rects []kicad.SchRectangle, polylines []kicad.SchPolyline, beziers []kicad.SchBezier, tr func(kicad.Position) kicad.Position, def kicad.Color, part shapePart) {
+	for _, arc := range arcs {
+		if arc.Private {
+			continue
+		}
+		points := kicad.ArcPoints(tr(arc.Start), tr(arc.Mid), tr(arc.End))
+		r.shape(points, false, arc.Stroke, arc.Fill, def, part)
+	}
+	for _, circle := range circles {
+		if circle.Private {
+			continue
+		}
+		st := r.strokeStyle(circle.Stroke, def, defaultLineWidth)
+		st.fill = r.fillColor(circle.Fill, st.stroke, part)
+		if part == backgroundOnly {
+			st.width = 0
+		}
+		if st.width > 0 || st.fill != nil {
+			r.c.circle(tr(circle.Center), circle.Radius, st)
+		}
+	}
+	for _, rect := range rects {
+		if rect.Private {
+			continue
+		}
+		points := make([]kicad.Position, 0, 4)
+		for _, p := range kicad.RectPoints(rect.Start, rect.End) {
+			points = append(points, tr(p))
+		}
+		r.shape(points, true, rect.Stroke, rect.Fill, def, part)
+	}
+	for _, poly := range polylines {
+		if poly.Private {
+			continue
+		}
+		points := make([]kicad.Position, len(poly.Points.Polyline()))
+		for i, p := range poly.Points.Polyline() {
+			points[i] = tr(p)
+		}
+		closed := len(points) > 2 && points[0] == points[len(points)-1]
+		r.shape(points, closed, poly.Stroke, poly.Fill, def, part)
+	}
+	for _, bezier := range beziers {
+		if bezier.Private {
+			continue
+		}
+		ctrl := make([]kicad.Position, len(bezier.Points.Polyline()))
+		for i, p := range bezier.Points.Polyline() {
+			ctrl[i] = tr(p)
+		}
+		r.shape(kicad.BezierPoints(ctrl), false, bezier.Stroke, bezier.Fill, def, part)
+	}
+}
+
+// graphics draws the graphic items placed directly on the sheet.
+func (r *renderer) graphics() {
+	sch := r.sch
+	identity := func(p kicad.Position) kicad.Position { return p }
+	r.graphicItems(sch.Arcs, sch.Circles, sch.Rectangles, sch.Polylines, sch.Beziers, identity, noteColor, wholeShape)
+}
+
+// wires draws the wires and buses of the sheet, along with the junctions,
+// bus entries and no-connect markers on them.
+func (r *renderer) wires() {
+	for _, wire := range r.sch.Wires {
+		r.c.path([][]kicad.Position{wire.Points.Polyline()}, false, r.strokeStyle(wire.Stroke, wireColor, defaultLineWidth))
+	}
+	for _, bus := range r.sch.Buses {
+		r.c.path([][]kicad.Position{bus.Points.Polyline()}, false, r.strokeStyle(bus.Stroke, busColor, defaultBusWidth))
+	}
+	for _, entry := range r.sch.BusEntries {
+		end := kicad.Position{X: entry.At.X + entry.Size.Width, Y: entry.At.Y + entry.Size.Height}
+		r.c.path([][]kicad.Position{{entry.At, end}}, false, r.strokeStyle(entry.Stroke, wireColor, defaultLineWidth))
+	}
+	for _, junction := range r.sch.Junctions {
+		d := junction.Diameter
+		if d == 0 {
+			d = junctionDiameter
+		}
+		c := r.color(junction.Color, junctionColor)
+		r.c.circle(junction.At, d/2, &style{fill: &c})
+	}
+	for _, nc := range r.sch.NoConnects {
+		h := noConnectSize / 2
+		at := nc.At
+		lines := [][]kicad.Position{
+			{{X: at.X - h, Y: at.Y - h}, {X: at.X + h, Y: at.Y + h}},
+			{{X: at.X + h, Y: at.Y - h}, {X: at.X - h, Y: at.Y + h}},
+		}
+		r.c.path(lines, false, r.lineStyle(0, kicad.Color{}, noConnectColor))
+	}
+}
+
+// texts draws the free text items and text boxes of the sheet.
+func (r *renderer) texts() {
+	for _, text := range r.sch.Texts {
+		if text.Effects.Hide {
+			continue
+		}
+		at := text.At
+		at.Angle = readableAngle(at.Angle)
+		r.text(text.Text, at, &text.Effects, r.color(text.Effects.Font.Color, noteColor))
+	}
+	for i := range r.sch.TextBoxes {
+		r.textBox(&r.sch.TextBoxes[i], wholeShape)
+	}
+}
+
+// text draws the given text, whose angle must already be one that KiCad
+// would draw it at.
+func (r *renderer) text(text string, at kicad.PositionAngle, effects *kicad.TextEffects, color kicad.Color) {
+	var lines [][]kicad.Position
+	for _, stroke := range strokefont.Strokes(text, at, effects) {
+		if len(stroke) == 1 {
+			// A dot, drawn as a zero-length line so that the pen's
+			// round cap draws it.
+			stroke = append(stroke, stroke[0])
+		}
+		lines = append(lines, stroke)
+	}
+	if len(lines) == 0 {
+		return
+	}
+	r.c.path(lines, false, &style{width: strokefont.Thickness(effects), stroke: color})
+}
+
+// textBox draws a text box placed on the sheet.
+func (r *renderer) textBox(box *kicad.SchTextBox, part shapePart) {
+	// The box's size is given before its rotation, from its top left
+	// corner.
+	corners := kicad.RectPoints(kicad.Position{}, kicad.Position{X: box.Size.Width, Y: box.Size.Height})
+	for i, p := range corners {
+		p = kicad.RotatePoint(p, box.At.Angle)
+		corners[i] = kicad.Position{X: box.At.X + p.X, Y: box.At.Y + p.Y}
+	}
+	r.shape(corners, true, box.Stroke, box.Fill, noteColor, part)
+	if part == backgroundOnly || box.Effects.Hide {
+		return
+	}
+
+	left, right := box.Margins.Left, box.Size.Width-box.Margins.Right
+	top, bottom := box.Margins.Top, box.Size.Height-box.Margins.Bottom
+	var anchor kicad.Position
+	switch {
+	case box.Effects.Justify.Right:
+		anchor.X = right
+	case box.Effects.Justify.Left:
+		anchor.X = left
+	default:
+		anchor.X = (left + right) / 2
+	}
+	switch {
+	case box.Effects.Justify.Bottom:
+		anchor.Y = bottom
+	case box.Effects.Justify.Top:
+		anchor.Y = top
+	default:
+		anchor.Y = (top + bottom) / 2
+	}
+	anchor = kicad.RotatePoint(anchor, box.At.Angle)
+	at := kicad.PositionAngle{X: box.At.X + anchor.X, Y: box.At.Y + anchor.Y, Angle: readableAngle(box.At.Angle)}
+	r.text(box.Text, at, &box.Effects, r.color(box.Effects.Font.Color, noteColor))
+}
+
+// bitmap draws an image of the drawing sheet.
+func (r *renderer) bitmap(b kicad.FrameBitmap) {
+	cfg, err := png.DecodeConfig(bytes.NewReader(b.PNG))
+	if err != nil {
+		// Render has already checked the image data, so this should
+		// not happen.
+		return
+	}
+	scale := 25.4 / bitmapPixelsPerInch * b.Scale
+	size := kicad.Size{Width: float64(cfg.Width) * scale, Height: float64(cfg.Height) * scale}
+	r.c.image(b.Pos, size, b.PNG)
+}
+
+// readableAngle returns the angle that KiCad draws text at when its angle
+// is given, which is always horizontal or reading upwards so that the text
+// is never upside down.
+func readableAngle(angle float64) float64 {
+	angle = math.Mod(angle, 180)
+	if angle < 0 {
+		angle += 180
+	}
+	if angle >= 45 && angle < 135 {
+		return 90
+	}
+	return 0
+}
+
+// orientText returns the angle and justification to draw a text item with,
+// given its angle and justification within a symbol that is transformed by
+// t. Like KiCad, it keeps the text horizontal or reading upwards, and
+// adjusts the justification to keep the text on the same side of its
+// position.
+func orientText(t transform, angle float64, justify kicad.TextJustify) (float64, kicad.TextJustify) {
+	sin, cos := math.Sincos(angle * math.Pi / 180)
+	// Directions within the symbol, where Y increases upwards.
+	along := t.vector(kicad.Position{X: cos, Y: sin})
+	up := t.vector(kicad.Position{X: -sin, Y: cos})
+
+	ret := 0.0
+	reading, above := kicad.Position{X: 1}, kicad.Position{Y: -1}
+	if math.Abs(along.Y) > math.Abs(along.X) {
+		ret = 90
+		reading, above = kicad.Position{Y: -1}, kicad.Position{X: -1}
+	}
+	if along.X*reading.X+along.Y*reading.Y < 0 {
+		justify.Left, justify.Right = justify.Right, justify.Left
+	}
+	if up.X*above.X+up.Y*above.Y < 0 {
+		justify.Top, justify.Bottom = justify.Bottom, justify.Top
+	}
+	return ret, justify
+}
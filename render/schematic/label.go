@@ -0,0 +1,214 @@
+package schematic
+
+import (
+	"math"
+
+	"github.com/apparentlymart/go-kicad"
+	"github.com/apparentlymart/go-kicad/strokefont"
+)
+
+// Proportions of labels relative to the height of their text, as KiCad
+// draws them.
+const (
+	labelTextOffset   = 0.15
+	globalLabelMargin = 0.375
+)
+
+// labelFrame describes the direction of a label from its connection point.
+// Along points from the connection point towards the label's text, and up
+// is perpendicular to it on the side above the text.
+type labelFrame struct {
+	at         kicad.Position
+	along, up  kicad.Position
+	angle      float64
+	horizontal bool
+}
+
+// newLabelFrame returns the frame of a label at the given position, whose
+// text extends from it in the direction of the given angle.
+func newLabelFrame(at kicad.Position, angle float64) labelFrame {
+	along := kicad.RotatePoint(kicad.Position{X: 1}, angle)
+	along.X, along.Y = math.Round(along.X), math.Round(along.Y)
+	f := labelFrame{at: at, along: along, horizontal: along.Y == 0}
+	if f.horizontal {
+		f.up = kicad.Position{Y: -1}
+	} else {
+		f.up, f.angle = kicad.Position{X: -1}, 90
+	}
+	return f
+}
+
+// point returns the position the given distances along and above the
+// connection point of the label.
+func (f labelFrame) point(along, up float64) kicad.Position {
+	return kicad.Position{
+		X: f.at.X + f.along.X*along + f.up.X*up,
+		Y: f.at.Y + f.along.Y*along + f.up.Y*up,
+	}
+}
+
+// justify returns the horizontal justification of text that starts at the
+// label's connection point and extends along the label.
+func (f labelFrame) justify() kicad.TextJustify {
+	// Text reads rightwards, or upwards when it is vertical.
+	if f.along.X-f.along.Y > 0 {
+		return kicad.TextJustify{Left: true}
+	}
+	return kicad.TextJustify{Right: true}
+}
+
+// labels draws the net labels, global labels and hierarchical labels of
+// the sheet.
+func (r *renderer) labels() {
+	for _, label := range r.sch.Labels {
+		if label.Effects.Hide {
+			continue
+		}
+		f := newLabelFrame(label.At.Position(), label.At.Angle)
+		effects := label.Effects
+		effects.Justify = f.justify()
+		effects.Justify.Bottom = true
+		pos := f.point(0, labelTextOffset*effects.Font.Size.Height+strokefont.Thickness(&effects))
+		at := kicad.PositionAngle{X: pos.X, Y: pos.Y, Angle: f.angle}
+		r.text(label.Text, at, &effects, r.color(effects.Font.Color, labelColor))
+	}
+	for i := range r.sch.GlobalLabels {
+		label := &r.sch.GlobalLabels[i]
+		r.globalLabel(label)
+		r.labelFields(label)
+	}
+	for i := range r.sch.HierLabels {
+		label := &r.sch.HierLabels[i]
+		f := newLabelFrame(label.At.Position(), label.At.Angle)
+		r.flaggedText(f, label.Shape, label.Text, label.Effects, hierLabelColor)
+		r.labelFields(label)
+	}
+}
+
+// globalLabel draws a global label, whose text is surrounded by an outline
+// with ends pointed to show the direction of its signal.
+func (r *renderer) globalLabel(label *kicad.Label) {
+	if label.Effects.Hide {
+		return
+	}
+	effects := label.Effects
+	h := effects.Font.Size.Height
+	margin := globalLabelMargin * h
+	half := h/2 + margin
+	width := strokefont.Width(label.Text, &effects) + 2*margin
+
+	var startTip, endTip float64
+	switch label.Shape {
+	case "input":
+		startTip = half
+	case "output":
+		endTip = half
+	case "bidirectional", "tri_state":
+		startTip, endTip = half, half
+	}
+	f := newLabelFrame(label.At.Position(), label.At.Angle)
+	length := startTip + width + endTip
+	var outline []kicad.Position
+	if startTip > 0 {
+		outline = append(outline, f.point(0, 0))
+	}
+	outline = append(outline, f.point(startTip, half), f.point(length-endTip, half))
+	if endTip > 0 {
+		outline = append(outline, f.point(length, 0))
+	}
+	outline = append(outline, f.point(length-endTip, -half), f.point(startTip, -half))
+
+	color := r.color(effects.Font.Color, globalLabelColor)
+	r.c.path([][]kicad.Position{outline}, true, &style{width: strokefont.Thickness(&effects), stroke: color})
+
+	pos := f.point(startTip+width/2, 0)
+	effects.Justify = kicad.TextJustify{}
+	at := kicad.PositionAngle{X: pos.X, Y: pos.Y, Angle: f.angle}
+	r.text(label.Text, at, &effects, color)
+}
+
+// flaggedText draws the text of a hierarchical label or sheet pin, after a
+// small flag whose shape shows the direction of its signal.
+func (r *renderer) flaggedText(f labelFrame, shape, text string, effects kicad.TextEffects, def kicad.Color) {
+	if effects.Hide {
+		return
+	}
+	h := effects.Font.Size.Height
+	half := h / 2
+	var flag []kicad.Position
+	switch shape {
+	case "input":
+		flag = []kicad.Position{f.point(0, 0), f.point(half, half), f.point(h, half), f.point(h, -half), f.point(half, -half)}
+	case "output":
+		flag = []kicad.Position{f.point(0, half), f.point(half, half), f.point(h, 0), f.point(half, -half), f.point(0, -half)}
+	case "bidirectional", "tri_state":
+		flag = []kicad.Position{f.point(0, 0), f.point(half, half), f.point(h, 0), f.point(half, -half)}
+	default:
+		flag = []kicad.Position{f.point(0, half), f.point(h, half), f.point(h, -half), f.point(0, -half)}
+	}
+	color := r.color(effects.Font.Color, def)
+	r.c.path([][]kicad.Position{flag}, true, &style{width: strokefont.Thickness(&effects), stroke: color})
+
+	pos := f.point(h+labelTextOffset*h+strokefont.Thickness(&effects), 0)
+	effects.Justify = f.justify()
+	at := kicad.PositionAngle{X: pos.X, Y: pos.Y, Angle: f.angle}
+	r.text(text, at, &effects, color)
+}
+
+// labelFields draws the visible properties of a global or hierarchical
+// label, such as its list of intersheet references.
+func (r *renderer) labelFields(label *kicad.Label) {
+	for _, prop := range label.Properties {
+		if prop.Hide || prop.Effects.Hide || prop.Value == "" {
+			continue
+		}
+		r.field(prop.Value, prop, pageTransform, r.color(prop.Effects.Font.Color, fieldColor))
+	}
+}
+
+// sheets draws the hierarchical sheets placed on the sheet, along with
+// their pins and fields.
+func (r *renderer) sheets() {
+	for i := range r.sch.Sheets {
+		sheet := &r.sch.Sheets[i]
+		corners := kicad.RectPoints(sheet.At, kicad.Position{X: sheet.At.X + sheet.Size.Width, Y: sheet.At.Y + sheet.Size.Height})
+		st := r.strokeStyle(sheet.Stroke, sheetColor, defaultLineWidth)
+		if c := sheet.Fill.Color; c != (kicad.Color{}) && c.A > 0 && !r.opts.Monochrome {
+			st.fill = &c
+		}
+		r.c.path([][]kicad.Position{corners}, true, st)
+
+		for _, pin := range sheet.Pins {
+			// A pin's angle points out of the sheet, and its text is
+			// inside. Its flag is seen from inside the sheet, so the
+			// input and output shapes are reversed.
+			f := newLabelFrame(pin.At.Position(), pin.At.Angle+180)
+			shape := pin.Type
+			switch shape {
+			case "input":
+				shape = "output"
+			case "output":
+				shape = "input"
+			}
+			r.flaggedText(f, shape, pin.Name, pin.Effects, sheetPinColor)
+		}
+
+		for _, prop := range sheet.Properties {
+			if prop.Hide || prop.Effects.Hide || prop.Value == "" {
+				continue
+			}
+			text, color := prop.Value, fieldColor
+			switch prop.Name {
+			case "Sheetname", "Sheet name":
+				color = sheetNameColor
+			case "Sheetfile", "Sheet file":
+				text, color = "File: "+text, sheetFileColor
+			default:
+				if prop.ShowName {
+					text = prop.Name + ": " + text
+				}
+			}
+			r.field(text, prop, pageTransform, r.color(prop.Effects.Font.Color, color))
+		}
+	}
+}
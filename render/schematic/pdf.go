@@ -0,0 +1,241 @@
+package schematic
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/apparentlymart/go-kicad"
+)
+
+// pointsPerMM converts millimeters to PDF points, of which there are 72 to
+// the inch.
+const pointsPerMM = 72 / 25.4
+
+// pdfDocument collects the pages of a PDF document.
+type pdfDocument struct {
+	opts  *Options
+	pages []pdfPage
+}
+
+type pdfPage struct {
+	size    kicad.Size
+	content []byte
+}
+
+func newPDFDocument(opts *Options) *pdfDocument {
+	return &pdfDocument{opts: opts}
+}
+
+// addPage draws the given sheet as a new page at the end of the document.
+func (d *pdfDocument) addPage(sch *kicad.Schematic, opts *Options) error {
+	size, err := pageSize(sch)
+	if err != nil {
+		return err
+	}
+	c := newPDFCanvas(size, opts)
+	err = drawSheet(c, sch, opts)
+	if err != nil {
+		return err
+	}
+	d.pages = append(d.pages, pdfPage{size: size, content: c.bytes()})
+	return nil
+}
+
+// bytes returns the complete PDF document, with the given title in its
+// metadata.
+func (d *pdfDocument) bytes(title string) []byte {
+	var buf bytes.Buffer
+	var offsets []int
+	object := func(format string, args ...interface{}) {
+		offsets = append(offsets, buf.Len())
+		fmt.Fprintf(&buf, "%d 0 obj\n", len(offsets))
+		fmt.Fprintf(&buf, format, args...)
+		buf.WriteString("\nendobj\n")
+	}
+
+	// The first bytes after the header are not ASCII, to tell
+	// programs that the file is binary.
+	buf.WriteString("%PDF-1.4\n%\xE2\xE3\xCF\xD3\n")
+
+	// Objects 1 to 3 are the catalog, page tree and metadata, followed by
+	// a page object and a content stream for each page.
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	kids := make([]string, len(d.pages))
+	for i := range d.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 4+2*i)
+	}
+	object("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(d.pages))
+	info := "/Producer (go-kicad)"
+	if title != "" {
+		info += " /Title " + pdfString(title)
+	}
+	if date := d.opts.CreationDate; !date.IsZero() {
+		_, offset := date.Zone()
+		sign := '+'
+		if offset < 0 {
+			sign, offset = '-', -offset
+		}
+		info += fmt.Sprintf(" /CreationDate (D:%s%c%02d'%02d')", date.Format("20060102150405"), sign, offset/3600, offset/60%60)
+	}
+	object("<< %s >>", info)
+
+	for i, page := range d.pages {
+		object("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %s %s] /Resources << >> /Contents %d 0 R >>",
+			num(page.size.Width*pointsPerMM), num(page.size.Height*pointsPerMM), 5+2*i,
+		)
+		var z bytes.Buffer
+		zw := zlib.NewWriter(&z)
+		zw.Write(page.content)
+		zw.Close()
+		object("<< /Length %d /Filter /FlateDecode >>\nstream\n%s\nendstream", z.Len(), z.Bytes())
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R /Info 3 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+	return buf.Bytes()
+}
+
+// pdfString returns the given text as a PDF string literal, which is
+// written in UTF-16 if it isn't all printable ASCII.
+func pdfString(s string) string {
+	ascii := true
+	for _, r := range s {
+		if r < ' ' || r > '~' {
+			ascii = false
+			break
+		}
+	}
+	if ascii {
+		r := strings.NewReplacer(`\`, `\\`, `(`, `\(`, `)`, `\)`)
+		return "(" + r.Replace(s) + ")"
+	}
+	var b strings.Builder
+	b.WriteString("<FEFF")
+	for _, u := range utf16.Encode([]rune(s)) {
+		fmt.Fprintf(&b, "%04X", u)
+	}
+	b.WriteString(">")
+	return b.String()
+}
+
+// pdfCanvas draws a page as the content stream of a PDF page. It can't draw
+// images, and draws translucent colors as opaque.
+type pdfCanvas struct {
+	buf bytes.Buffer
+
+	// The current graphics state, to avoid repeating it for each shape.
+	stroke, fill, width, dash string
+}
+
+func newPDFCanvas(size kicad.Size, opts *Options) *pdfCanvas {
+	c := &pdfCanvas{}
+	// Scale the page to millimeters with Y increasing downwards, so that
+	// everything else can be drawn in page coordinates.
+	fmt.Fprintf(&c.buf, "%s 0 0 %s 0 %s cm\n", num(pointsPerMM), num(-pointsPerMM), num(size.Height*pointsPerMM))
+	c.buf.WriteString("1 J 1 j\n")
+	if bg := opts.Background; bg != (kicad.Color{}) {
+		fmt.Fprintf(&c.buf, "%s rg\n0 0 %s %s re f\n", pdfColor(bg), num(size.Width), num(size.Height))
+		c.fill = pdfColor(bg)
+	}
+	return c
+}
+
+func (c *pdfCanvas) path(lines [][]kicad.Position, closed bool, st *style) {
+	if len(lines) == 0 {
+		return
+	}
+	c.setStyle(st)
+	for _, line := range lines {
+		for i, p := range line {
+			op := "l"
+			if i == 0 {
+				op = "m"
+			}
+			fmt.Fprintf(&c.buf, "%s %s %s\n", num(p.X), num(p.Y), op)
+		}
+		if closed && len(line) > 0 {
+			c.buf.WriteString("h\n")
+		}
+	}
+	c.paint(st)
+}
+
+func (c *pdfCanvas) circle(center kicad.Position, r float64, st *style) {
+	c.setStyle(st)
+	// Four cubic Bézier curves, each approximating a quarter circle.
+	const k = 0.5523
+	x, y := center.X, center.Y
+	fmt.Fprintf(&c.buf, "%s %s m\n", num(x+r), num(y))
+	quarters := [][6]float64{
+		{x + r, y + k*r, x + k*r, y + r, x, y + r},
+		{x - k*r, y + r, x - r, y + k*r, x - r, y},
+		{x - r, y - k*r, x - k*r, y - r, x, y - r},
+		{x + k*r, y - r, x + r, y - k*r, x + r, y},
+	}
+	for _, q := range quarters {
+		fmt.Fprintf(&c.buf, "%s %s %s %s %s %s c\n", num(q[0]), num(q[1]), num(q[2]), num(q[3]), num(q[4]), num(q[5]))
+	}
+	c.buf.WriteString("h\n")
+	c.paint(st)
+}
+
+func (c *pdfCanvas) image(center kicad.Position, size kicad.Size, png []byte) {
+	// Not supported
+}
+
+// setStyle changes the graphics state to draw shapes in the given style.
+func (c *pdfCanvas) setStyle(st *style) {
+	if st.width > 0 {
+		if s := pdfColor(st.stroke); s != c.stroke {
+			fmt.Fprintf(&c.buf, "%s RG\n", s)
+			c.stroke = s
+		}
+		if w := num(st.width); w != c.width {
+			fmt.Fprintf(&c.buf, "%s w\n", w)
+			c.width = w
+		}
+		dash := make([]string, len(st.dash))
+		for i, v := range st.dash {
+			dash[i] = num(v)
+		}
+		if d := "[" + strings.Join(dash, " ") + "] 0 d"; d != c.dash && (c.dash != "" || len(dash) > 0) {
+			fmt.Fprintf(&c.buf, "%s\n", d)
+			c.dash = d
+		}
+	}
+	if st.fill != nil {
+		if s := pdfColor(*st.fill); s != c.fill {
+			fmt.Fprintf(&c.buf, "%s rg\n", s)
+			c.fill = s
+		}
+	}
+}
+
+// paint fills or strokes the current path, or both.
+func (c *pdfCanvas) paint(st *style) {
+	switch {
+	case st.fill != nil && st.width > 0:
+		c.buf.WriteString("B\n")
+	case st.fill != nil:
+		c.buf.WriteString("f\n")
+	case st.width > 0:
+		c.buf.WriteString("S\n")
+	default:
+		c.buf.WriteString("n\n")
+	}
+}
+
+func (c *pdfCanvas) bytes() []byte {
+	return c.buf.Bytes()
+}
+
+func pdfColor(c kicad.Color) string {
+	return num(float64(c.R)/255) + " " + num(float64(c.G)/255) + " " + num(float64(c.B)/255)
+}
@@ -0,0 +1,249 @@
+// Package schematic draws the sheets of KiCad schematics as SVG images or
+// PDF documents, without needing KiCad itself.
+//
+// Symbols are drawn from the copies of their library symbols cached in each
+// schematic, using the unit and body style chosen for each instance, along
+// with their pins and fields. Wires, buses, junctions, labels, hierarchical
+// sheets, graphics, text and the drawing sheet frame are drawn too, in the
+// colors of KiCad's default theme.
+//
+// All text is drawn with the font from package strokefont, which is not
+// the same as KiCad's font, and so text will differ in appearance from
+// KiCad's own output. Text in other fonts is drawn with the stroke font
+// too. Images placed on sheets are not drawn.
+package schematic
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/apparentlymart/go-kicad"
+	"github.com/apparentlymart/go-kicad/strokefont"
+)
+
+// Options customizes how a schematic sheet is drawn. A nil *Options is
+// equivalent to a pointer to the zero value, which draws the sheet in color
+// with KiCad's default drawing sheet.
+type Options struct {
+	// Hierarchy and Sheet identify the instance of the sheet that is
+	// drawn, which determines the reference designators of its symbols
+	// and the page number shown in its frame. If Hierarchy is nil then
+	// symbols are labeled with their Reference properties.
+	Hierarchy *kicad.SchematicHierarchy
+	Sheet     *kicad.HierarchySheet
+
+	// DrawingSheet is the drawing sheet that the frame and title block are
+	// drawn from. If nil, KiCad's default drawing sheet is used.
+	DrawingSheet *kicad.DrawingSheet
+
+	// NoFrame leaves out the drawing sheet frame and title block.
+	NoFrame bool
+
+	// Vars are the project's text variables, which are used in the text
+	// of the drawing sheet.
+	Vars map[string]string
+
+	// Monochrome draws everything in black, without any background fills.
+	Monochrome bool
+
+	// Background is the color of the page, which is left transparent in
+	// SVG images and white in PDF documents if the color is the zero value.
+	Background kicad.Color
+
+	// Scale gives the size of SVG images in pixels per millimeter. If
+	// zero, images are sized in millimeters so that they print at actual
+	// size. It does not affect PDF documents.
+	Scale float64
+
+	// CreationDate is recorded in the metadata of PDF documents. If it is
+	// zero then no date is recorded, so that the output depends only on
+	// the schematic.
+	CreationDate time.Time
+}
+
+// WriteSVG draws the given schematic sheet as an SVG image, writing the
+// image to the given writer.
+//
+// It returns an error if the sheet's paper size is not known.
+func WriteSVG(w io.Writer, sch *kicad.Schematic, opts *Options) error {
+	if opts == nil {
+		opts = &Options{}
+	}
+	size, err := pageSize(sch)
+	if err != nil {
+		return err
+	}
+	c := newSVGCanvas(size, opts)
+	err = drawSheet(c, sch, opts)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(c.bytes())
+	return err
+}
+
+// WritePDF draws the given schematic sheet as a single-page PDF document,
+// writing the document to the given writer.
+//
+// It returns an error if the sheet's paper size is not known.
+func WritePDF(w io.Writer, sch *kicad.Schematic, opts *Options) error {
+	if opts == nil {
+		opts = &Options{}
+	}
+	doc := newPDFDocument(opts)
+	err := doc.addPage(sch, opts)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(doc.bytes(sch.TitleBlock.Title))
+	return err
+}
+
+// WriteHierarchyPDF draws every sheet instance of the given hierarchy as a
+// page of a PDF document, in the order of the hierarchy's Sheets, writing
+// the document to the given writer. The Hierarchy and Sheet fields of opts
+// are ignored.
+func WriteHierarchyPDF(w io.Writer, h *kicad.SchematicHierarchy, opts *Options) error {
+	if opts == nil {
+		opts = &Options{}
+	}
+	doc := newPDFDocument(opts)
+	for _, hs := range h.Sheets {
+		sheetOpts := *opts
+		sheetOpts.Hierarchy, sheetOpts.Sheet = h, hs
+		err := doc.addPage(hs.Schematic, &sheetOpts)
+		if err != nil {
+			return fmt.Errorf("sheet %s: %w", hs.Path, err)
+		}
+	}
+	_, err := w.Write(doc.bytes(h.Root.TitleBlock.Title))
+	return err
+}
+
+// pageSize returns the size of the paper of the given sheet, which is A4
+// if the sheet doesn't give one.
+func pageSize(sch *kicad.Schematic) (kicad.Size, error) {
+	paper := sch.Paper
+	if paper.Name == "" {
+		paper.Name = "A4"
+	}
+	size, ok := paper.Dimensions()
+	if !ok {
+		return kicad.Size{}, fmt.Errorf("unknown paper size %q", paper.Name)
+	}
+	return size, nil
+}
+
+// drawSheet draws the content of a schematic sheet onto the given canvas.
+func drawSheet(c canvas, sch *kicad.Schematic, opts *Options) error {
+	r := &renderer{c: c, sch: sch, opts: opts}
+	if !opts.NoFrame {
+		err := r.frame()
+		if err != nil {
+			return err
+		}
+	}
+	r.sheets()
+	r.symbols()
+	r.graphics()
+	r.wires()
+	r.labels()
+	r.texts()
+	return nil
+}
+
+// renderer draws the items of one schematic sheet.
+type renderer struct {
+	c    canvas
+	sch  *kicad.Schematic
+	opts *Options
+}
+
+// frame draws the drawing sheet frame and title block.
+func (r *renderer) frame() error {
+	ds := r.opts.DrawingSheet
+	if ds == nil {
+		ds = kicad.DefaultDrawingSheet()
+	}
+	frame, err := ds.Render(r.page())
+	if err != nil {
+		return err
+	}
+
+	for _, line := range frame.Lines {
+		r.c.path([][]kicad.Position{{line.Start, line.End}}, false, r.lineStyle(line.Width, kicad.Color{}, frameColor))
+	}
+	for _, rect := range frame.Rects {
+		r.c.path([][]kicad.Position{kicad.RectPoints(rect.Start, rect.End)}, true, r.lineStyle(rect.Width, kicad.Color{}, frameColor))
+	}
+	for _, poly := range frame.Polygons {
+		st := r.lineStyle(poly.Width, kicad.Color{}, frameColor)
+		st.fill = &st.stroke
+		r.c.path(poly.Outlines, true, st)
+	}
+	for _, bitmap := range frame.Bitmaps {
+		r.bitmap(bitmap)
+	}
+	for _, text := range frame.Texts {
+		effects := kicad.TextEffects{
+			Font: kicad.Font{
+				Size:      kicad.TextSize{Height: text.Size.Height, Width: text.Size.Width},
+				Thickness: text.Width,
+				Bold:      text.Bold,
+				Italic:    text.Italic,
+			},
+			Justify: text.Justify,
+		}
+		if text.MaxLen > 0 {
+			// Shrink text that is too long for its space, as KiCad does
+			if w := strokefont.Width(text.Text, &effects); w > text.MaxLen {
+				effects.Font.Size.Width *= text.MaxLen / w
+			}
+		}
+		color := kicad.Color{}
+		if text.Color != nil {
+			color = *text.Color
+		}
+		at := kicad.PositionAngle{X: text.Pos.X, Y: text.Pos.Y, Angle: text.Angle}
+		r.text(text.Text, at, &effects, r.color(color, frameColor))
+	}
+	return nil
+}
+
+// page describes the page that the sheet is drawn on, for the text of the
+// drawing sheet.
+func (r *renderer) page() *kicad.DrawingSheetPage {
+	page := &kicad.DrawingSheetPage{
+		Paper:       r.sch.Paper,
+		TitleBlock:  r.sch.TitleBlock,
+		SheetNumber: 1,
+		SheetCount:  1,
+		SheetPath:   "/",
+		Vars:        r.opts.Vars,
+	}
+	if page.Paper.Name == "" {
+		page.Paper.Name = "A4"
+	}
+	h, hs := r.opts.Hierarchy, r.opts.Sheet
+	if h == nil || hs == nil {
+		return page
+	}
+	page.SheetCount = len(h.Sheets)
+	if n, err := strconv.Atoi(hs.Page); err == nil {
+		page.SheetNumber = n
+	}
+	page.SheetName = hs.Name
+	page.Filename = filepath.Base(hs.File)
+	var names []string
+	for s := hs; s.Parent != nil; s = s.Parent {
+		names = append([]string{s.Name}, names...)
+	}
+	if len(names) > 0 {
+		page.SheetPath = "/" + strings.Join(names, "/") + "/"
+	}
+	return page
+}
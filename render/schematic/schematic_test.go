@@ -0,0 +1,341 @@
+package schematic
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apparentlymart/go-kicad"
+)
+
+const testSchematicSrc = `(kicad_sch (version 20231120) (generator "eeschema") (generator_version "8.0")
+	(uuid "0b8c3a4e-0000-4000-8000-000000000001")
+	(paper "A4")
+	(title_block (title "Test"))
+	(lib_symbols
+		(symbol "Device:R" (pin_numbers hide) (pin_names (offset 0)) (in_bom yes) (on_board yes)
+			(property "Reference" "R" (at 2.032 0 90) (effects (font (size 1.27 1.27))))
+			(symbol "R_0_1"
+				(rectangle (start -1.016 2.54) (end 1.016 -2.54) (stroke (width 0.254) (type default)) (fill (type background)))
+			)
+			(symbol "R_1_1"
+				(pin passive line (at 0 3.81 270) (length 1.27)
+					(name "~" (effects (font (size 1.27 1.27))))
+					(number "1" (effects (font (size 1.27 1.27))))
+				)
+				(pin passive inverted (at 0 -3.81 90) (length 1.27)
+					(name "~" (effects (font (size 1.27 1.27))))
+					(number "2" (effects (font (size 1.27 1.27))))
+				)
+			)
+		)
+	)
+	(junction (at 50.8 50.8) (diameter 0) (color 0 0 0 0) (uuid "j1"))
+	(no_connect (at 60.96 50.8) (uuid "nc1"))
+	(wire (pts (xy 45.72 50.8) (xy 50.8 50.8)) (stroke (width 0) (type default)) (uuid "w1"))
+	(bus (pts (xy 40.64 30) (xy 40.64 60)) (stroke (width 0) (type default)) (uuid "b1"))
+	(polyline (pts (xy 10 10) (xy 20 10)) (stroke (width 0) (type dash)) (uuid "p1"))
+	(text "Note" (exclude_from_sim no) (at 10 20 0) (effects (font (size 1.27 1.27)) (justify left bottom)) (uuid "t1"))
+	(label "SDA" (at 45.72 50.8 0) (effects (font (size 1.27 1.27)) (justify left bottom)) (uuid "l1"))
+	(global_label "VBUS" (shape input) (at 30 30 180) (effects (font (size 1.27 1.27)) (justify right)) (uuid "g1"))
+	(hierarchical_label "SCL" (shape bidirectional) (at 70 50.8 0) (effects (font (size 1.27 1.27)) (justify left)) (uuid "h1"))
+	(symbol (lib_id "Device:R") (at 50.8 40.64 0) (unit 1)
+		(uuid "s1")
+		(property "Reference" "R1" (at 53 40 0) (effects (font (size 1.27 1.27)) (justify left)))
+		(property "Value" "10k" (at 53 42 0) (effects (font (size 1.27 1.27)) (justify left)))
+		(property "Footprint" "" (at 50.8 40.64 0) (effects (font (size 1.27 1.27)) hide))
+	)
+	(symbol (lib_id "Device:R") (at 80 40 90) (unit 1)
+		(uuid "s2")
+		(property "Reference" "R2" (at 80 37 0) (effects (font (size 1.27 1.27))))
+	)
+	(symbol (lib_id "Device:R") (at 100 40 0) (mirror x) (unit 1)
+		(uuid "s3")
+		(property "Reference" "R3" (at 103 40 0) (effects (font (size 1.27 1.27))))
+	)
+	(sheet (at 120 50) (size 20 10)
+		(stroke (width 0.1524) (type solid))
+		(fill (color 0 0 0 0.0000))
+		(uuid "sheet1")
+		(property "Sheetname" "Power" (at 120 49 0) (effects (font (size 1.27 1.27)) (justify left bottom)))
+		(property "Sheetfile" "power.kicad_sch" (at 120 61 0) (effects (font (size 1.27 1.27)) (justify left top)))
+		(pin "VBUS" input (at 120 55 180) (effects (font (size 1.27 1.27)) (justify left)) (uuid "sp1"))
+	)
+)
+`
+
+func testSchematic(t *testing.T) *kicad.Schematic {
+	t.Helper()
+	sch, err := kicad.ReadSchematic(strings.NewReader(testSchematicSrc))
+	if err != nil {
+		t.Fatalf("reading schematic: %s", err)
+	}
+	return sch
+}
+
+func writeSVG(t *testing.T, sch *kicad.Schematic, opts *Options) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := WriteSVG(&buf, sch, opts); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func TestWriteSVG(t *testing.T) {
+	got := writeSVG(t, testSchematic(t), &Options{NoFrame: true})
+
+	for _, want := range []string{
+		`<svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink" version="1.1" width="297mm" height="210mm" viewBox="0 0 297 210">`,
+		// The body of R1, first its background and then its outline
+		`<path d="M49.784 38.1 L51.816 38.1 L51.816 43.18 L49.784 43.18 Z" fill="#FFFFC2" stroke="none"/>`,
+		`<path d="M49.784 38.1 L51.816 38.1 L51.816 43.18 L49.784 43.18 Z" fill="none" stroke="#840000" stroke-width="0.254"/>`,
+		// R1's pins, the second with an inversion bubble
+		`<path d="M50.8 36.83 L50.8 38.1" fill="none" stroke="#840000" stroke-width="0.1524"/>`,
+		`<circle cx="50.8" cy="43.561" r="0.381" fill="none" stroke="#840000" stroke-width="0.1524"/>`,
+		`<path d="M50.8 44.45 L50.8 43.942" fill="none" stroke="#840000" stroke-width="0.1524"/>`,
+		// R2 is rotated so that its first pin points left
+		`<path d="M76.19 40 L77.46 40" fill="none" stroke="#840000" stroke-width="0.1524"/>`,
+		// R3 is mirrored so that its first pin points down
+		`<path d="M100 43.81 L100 42.54" fill="none" stroke="#840000" stroke-width="0.1524"/>`,
+		`<path d="M45.72 50.8 L50.8 50.8" fill="none" stroke="#009600" stroke-width="0.1524"/>`,
+		`<path d="M40.64 30 L40.64 60" fill="none" stroke="#000084" stroke-width="0.3048"/>`,
+		`<path d="M10 10 L20 10" fill="none" stroke="#0000C2" stroke-width="0.1524" stroke-dasharray="1.8288 0.6096"/>`,
+		`<circle cx="50.8" cy="50.8" r="0.4572" fill="#009600" stroke="none"/>`,
+		`<path d="M60.3504 50.1904 L61.5696 51.4096 M61.5696 50.1904 L60.3504 51.4096" fill="none" stroke="#000084" stroke-width="0.1524"/>`,
+		// The sheet and the flag of its pin
+		`<path d="M120 50 L140 50 L140 60 L120 60 Z" fill="none" stroke="#840000" stroke-width="0.1524"/>`,
+		`<path d="M120 54.365 L120.635 54.365 L121.27 55 L120.635 55.635 L120 55.635 Z" fill="none" stroke="#725600" stroke-width="0.1905"/>`,
+		// The flag of the hierarchical label
+		`<path d="M70 50.8 L70.635 50.165 L71.27 50.8 L70.635 51.435 Z" fill="none" stroke="#725600" stroke-width="0.1905"/>`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("missing %s", want)
+		}
+	}
+
+	// The background of a symbol must be drawn before its outline.
+	bg := strings.Index(got, `fill="#FFFFC2"`)
+	outline := strings.Index(got, `stroke-width="0.254"`)
+	if bg < 0 || outline < 0 || bg > outline {
+		t.Errorf("symbol background not drawn before its outline")
+	}
+
+	// Text is drawn in the colors of its items.
+	for _, color := range []string{
+		"#006464", // the reference and value of the symbols
+		"#0F0F0F", // the net label
+		"#0000C2", // the note
+	} {
+		if !strings.Contains(got, `stroke="`+color+`" stroke-width="0.1905"`) {
+			t.Errorf("no text in %s", color)
+		}
+	}
+	if t.Failed() {
+		t.Logf("output:\n%s", got)
+	}
+}
+
+func TestWriteSVG_frame(t *testing.T) {
+	got := writeSVG(t, testSchematic(t), &Options{
+		Background: kicad.Color{R: 255, G: 255, B: 255, A: 1},
+		Scale:      2,
+	})
+	for _, want := range []string{
+		` width="594" height="420" viewBox="0 0 297 210">`,
+		`<rect x="0" y="0" width="297" height="210" fill="#FFFFFF"/>`,
+		// The border around the page
+		`<path d="M10 10 L287 10 L287 200 L10 200 Z" fill="none" stroke="#840000" stroke-width="0.15"/>`,
+		// The box around the title block
+		`<path d="M177 166 L285 166 L285 198 L177 198 Z" fill="none" stroke="#840000" stroke-width="0.15"/>`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("missing %s", want)
+		}
+	}
+}
+
+func TestWriteSVG_monochrome(t *testing.T) {
+	got := writeSVG(t, testSchematic(t), &Options{NoFrame: true, Monochrome: true})
+	colors := regexp.MustCompile(`(fill|stroke)="(#[0-9A-F]+)"`).FindAllStringSubmatch(got, -1)
+	if len(colors) == 0 {
+		t.Fatalf("no colors in output")
+	}
+	for _, c := range colors {
+		if c[2] != "#000000" {
+			t.Errorf("%s in monochrome output", c[0])
+		}
+	}
+}
+
+func TestWriteSVG_unknownPaper(t *testing.T) {
+	sch := testSchematic(t)
+	sch.Paper = kicad.PaperSize{Name: "Z9"}
+	err := WriteSVG(io.Discard, sch, nil)
+	if err == nil || err.Error() != `unknown paper size "Z9"` {
+		t.Errorf("wrong error %v", err)
+	}
+}
+
+// pdfObjects checks the cross-reference table of a PDF document, returning
+// the content of each of its objects by number.
+func pdfObjects(t *testing.T, doc []byte) map[int]string {
+	t.Helper()
+	s := string(doc)
+	if !strings.HasPrefix(s, "%PDF-1.4\n") || !strings.HasSuffix(s, "%%EOF\n") {
+		t.Fatalf("not a PDF document:\n%q", s)
+	}
+	m := regexp.MustCompile(`startxref\n(\d+)\n%%EOF\n$`).FindStringSubmatch(s)
+	if m == nil {
+		t.Fatalf("no startxref")
+	}
+	xref, _ := strconv.Atoi(m[1])
+	if !strings.HasPrefix(s[xref:], "xref\n0 ") {
+		t.Fatalf("startxref doesn't point at the cross-reference table")
+	}
+	entries := regexp.MustCompile(`(\d{10}) 00000 n \n`).FindAllStringSubmatch(s[xref:], -1)
+	ret := make(map[int]string)
+	for i, e := range entries {
+		offset, _ := strconv.Atoi(e[1])
+		header := fmt.Sprintf("%d 0 obj\n", i+1)
+		if !strings.HasPrefix(s[offset:], header) {
+			t.Fatalf("cross-reference entry for object %d doesn't point at it", i+1)
+		}
+		body := s[offset+len(header):]
+		ret[i+1] = body[:strings.Index(body, "\nendobj\n")]
+	}
+	return ret
+}
+
+// pdfContent returns the decompressed content of a PDF stream object.
+func pdfContent(t *testing.T, obj string) string {
+	t.Helper()
+	_, data, ok := strings.Cut(obj, "stream\n")
+	if !ok {
+		t.Fatalf("object is not a stream:\n%s", obj)
+	}
+	data = strings.TrimSuffix(data, "\nendstream")
+	zr, err := zlib.NewReader(strings.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(content)
+}
+
+func TestWritePDF(t *testing.T) {
+	var buf bytes.Buffer
+	err := WritePDF(&buf, testSchematic(t), &Options{
+		NoFrame:      true,
+		CreationDate: time.Date(2024, 3, 1, 12, 30, 0, 0, time.FixedZone("", -5*3600)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	objs := pdfObjects(t, buf.Bytes())
+	if got, want := len(objs), 5; got != want {
+		t.Fatalf("wrong number of objects %d; want %d", got, want)
+	}
+	if got, want := objs[2], "<< /Type /Pages /Kids [4 0 R] /Count 1 >>"; got != want {
+		t.Errorf("wrong page tree\ngot:  %s\nwant: %s", got, want)
+	}
+	if got, want := objs[3], "<< /Producer (go-kicad) /Title (Test) /CreationDate (D:20240301123000-05'00') >>"; got != want {
+		t.Errorf("wrong metadata\ngot:  %s\nwant: %s", got, want)
+	}
+	if got, want := objs[4], "<< /Type /Page /Parent 2 0 R /MediaBox [0 0 841.8898 595.2756] /Resources << >> /Contents 5 0 R >>"; got != want {
+		t.Errorf("wrong page\ngot:  %s\nwant: %s", got, want)
+	}
+
+	content := pdfContent(t, objs[5])
+	for _, want := range []string{
+		"2.8346 0 0 -2.8346 0 595.2756 cm\n1 J 1 j\n",
+		// The wire, in green
+		"0 0.5882 0 RG\n[] 0 d\n45.72 50.8 m\n50.8 50.8 l\nS\n",
+		// The dashed polyline
+		"[1.8288 0.6096] 0 d\n10 10 m\n20 10 l\nS\n",
+	} {
+		if !strings.Contains(content, want) {
+			t.Errorf("missing %q in content:\n%s", want, content)
+		}
+	}
+}
+
+func TestWriteHierarchyPDF(t *testing.T) {
+	files := map[string]string{
+		"root.kicad_sch":  testSchematicSrc,
+		"power.kicad_sch": `(kicad_sch (version 20231120) (generator "eeschema") (paper "A5"))`,
+	}
+	h, err := kicad.LoadSchematicHierarchyFunc("root.kicad_sch", func(filename string) (*kicad.Schematic, error) {
+		src, ok := files[filename]
+		if !ok {
+			return nil, fmt.Errorf("no file %s", filename)
+		}
+		return kicad.ReadSchematic(strings.NewReader(src))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	err = WriteHierarchyPDF(&buf, h, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	objs := pdfObjects(t, buf.Bytes())
+	if got, want := objs[2], "<< /Type /Pages /Kids [4 0 R 6 0 R] /Count 2 >>"; got != want {
+		t.Errorf("wrong page tree\ngot:  %s\nwant: %s", got, want)
+	}
+	if got, want := objs[6], "/MediaBox [0 0 595.2756 419.5276]"; !strings.Contains(got, want) {
+		t.Errorf("second page is not A5\n%s", got)
+	}
+}
+
+func TestOrientText(t *testing.T) {
+	left := kicad.TextJustify{Left: true, Bottom: true}
+	tests := []struct {
+		at          kicad.PositionAngle
+		mirror      string
+		angle       float64
+		wantAngle   float64
+		wantJustify kicad.TextJustify
+	}{
+		{kicad.PositionAngle{}, "", 0, 0, left},
+		{kicad.PositionAngle{}, "", 90, 90, left},
+		{kicad.PositionAngle{Angle: 90}, "", 0, 90, left},
+		{kicad.PositionAngle{Angle: 90}, "", 90, 0, kicad.TextJustify{Right: true, Top: true}},
+		{kicad.PositionAngle{Angle: 180}, "", 0, 0, kicad.TextJustify{Right: true, Top: true}},
+		{kicad.PositionAngle{}, "y", 0, 0, kicad.TextJustify{Right: true, Bottom: true}},
+		{kicad.PositionAngle{}, "x", 0, 0, kicad.TextJustify{Left: true, Top: true}},
+	}
+	for _, test := range tests {
+		tr := symbolTransform(test.at, test.mirror)
+		angle, justify := orientText(tr, test.angle, left)
+		if angle != test.wantAngle || justify != test.wantJustify {
+			t.Errorf("wrong result for text at %g in symbol at %g mirrored %q\ngot:  %g %+v\nwant: %g %+v", test.angle, test.at.Angle, test.mirror, angle, justify, test.wantAngle, test.wantJustify)
+		}
+	}
+}
+
+func TestUnitSuffix(t *testing.T) {
+	tests := map[int]string{
+		1:  "A",
+		2:  "B",
+		26: "Z",
+		27: "AA",
+		28: "AB",
+	}
+	for unit, want := range tests {
+		if got := unitSuffix(unit); got != want {
+			t.Errorf("wrong suffix for unit %d: got %q, want %q", unit, got, want)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package schematic
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"github.com/apparentlymart/go-kicad"
+)
+
+// svgCanvas draws a page as an SVG image.
+type svgCanvas struct {
+	size kicad.Size
+	opts *Options
+	body bytes.Buffer
+}
+
+func newSVGCanvas(size kicad.Size, opts *Options) *svgCanvas {
+	return &svgCanvas{size: size, opts: opts}
+}
+
+func (c *svgCanvas) path(lines [][]kicad.Position, closed bool, st *style) {
+	var d strings.Builder
+	for _, line := range lines {
+		for i, p := range line {
+			if d.Len() > 0 {
+				d.WriteByte(' ')
+			}
+			cmd := "L"
+			if i == 0 {
+				cmd = "M"
+			}
+			d.WriteString(cmd + num(p.X) + " " + num(p.Y))
+		}
+		if closed && len(line) > 0 {
+			d.WriteString(" Z")
+		}
+	}
+	if d.Len() == 0 {
+		return
+	}
+	fmt.Fprintf(&c.body, "<path d=%q%s/>\n", d.String(), svgStyle(st))
+}
+
+func (c *svgCanvas) circle(center kicad.Position, r float64, st *style) {
+	fmt.Fprintf(&c.body, "<circle cx=\"%s\" cy=\"%s\" r=\"%s\"%s/>\n", num(center.X), num(center.Y), num(r), svgStyle(st))
+}
+
+func (c *svgCanvas) image(center kicad.Position, size kicad.Size, png []byte) {
+	fmt.Fprintf(&c.body, "<image x=\"%s\" y=\"%s\" width=\"%s\" height=\"%s\" xlink:href=\"data:image/png;base64,%s\"/>\n",
+		num(center.X-size.Width/2), num(center.Y-size.Height/2), num(size.Width), num(size.Height),
+		base64.StdEncoding.EncodeToString(png),
+	)
+}
+
+// bytes returns the complete SVG document.
+func (c *svgCanvas) bytes() []byte {
+	w, h := c.size.Width, c.size.Height
+	var buf bytes.Buffer
+	buf.WriteString("<?xml version=\"1.0\" standalone=\"no\"?>\n")
+	buf.WriteString("<svg xmlns=\"http://www.w3.org/2000/svg\" xmlns:xlink=\"http://www.w3.org/1999/xlink\" version=\"1.1\"")
+	if c.opts.Scale > 0 {
+		fmt.Fprintf(&buf, " width=\"%s\" height=\"%s\"", num(w*c.opts.Scale), num(h*c.opts.Scale))
+	} else {
+		fmt.Fprintf(&buf, " width=\"%smm\" height=\"%smm\"", num(w), num(h))
+	}
+	fmt.Fprintf(&buf, " viewBox=\"0 0 %s %s\">\n", num(w), num(h))
+	if bg := c.opts.Background; bg != (kicad.Color{}) {
+		fmt.Fprintf(&buf, "<rect x=\"0\" y=\"0\" width=\"%s\" height=\"%s\" fill=%q/>\n", num(w), num(h), bg.Hex())
+	}
+	buf.WriteString("<g stroke-linecap=\"round\" stroke-linejoin=\"round\">\n")
+	buf.Write(c.body.Bytes())
+	buf.WriteString("</g>\n")
+	buf.WriteString("</svg>\n")
+	return buf.Bytes()
+}
+
+// svgStyle returns the attributes that draw a shape in the given style.
+func svgStyle(st *style) string {
+	var b strings.Builder
+	if st.fill != nil {
+		fmt.Fprintf(&b, " fill=%q", st.fill.Hex())
+		if a := st.fill.A; a > 0 && a < 1 {
+			fmt.Fprintf(&b, " fill-opacity=\"%s\"", num(a))
+		}
+	} else {
+		b.WriteString(" fill=\"none\"")
+	}
+	if st.width <= 0 {
+		b.WriteString(" stroke=\"none\"")
+		return b.String()
+	}
+	fmt.Fprintf(&b, " stroke=%q stroke-width=\"%s\"", st.stroke.Hex(), num(st.width))
+	if a := st.stroke.A; a > 0 && a < 1 {
+		fmt.Fprintf(&b, " stroke-opacity=\"%s\"", num(a))
+	}
+	if len(st.dash) > 0 {
+		dash := make([]string, len(st.dash))
+		for i, v := range st.dash {
+			dash[i] = num(v)
+		}
+		fmt.Fprintf(&b, " stroke-dasharray=\"%s\"", strings.Join(dash, " "))
+	}
+	return b.String()
+}
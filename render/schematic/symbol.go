@@ -0,0 +1,295 @@
+package schematic
+
+import (
+	"math"
+
+	"github.com/apparentlymart/go-kicad"
+)
+
+// Dimensions of pin decorations in millimeters, as KiCad draws them.
+const (
+	pinSymbolSize      = 1.016
+	pinInvertRadius    = 0.381
+	pinTextMargin      = 0.1016
+	defaultPinNameOffs = 0.508
+)
+
+// symbols draws the symbols placed on the sheet, along with their fields.
+func (r *renderer) symbols() {
+	for i := range r.sch.Symbols {
+		sym := &r.sch.Symbols[i]
+		lib := r.sch.LibSymbol(sym)
+		ref, unit := r.reference(sym)
+		t := symbolTransform(sym.At, sym.Mirror)
+		if lib != nil {
+			r.symbolBody(sym, lib, unit, t)
+			if lib.UnitCount() > 1 && unit > 0 {
+				ref += unitSuffix(unit)
+			}
+		}
+		r.symbolFields(sym, ref, unit, t)
+	}
+}
+
+// reference returns the reference designator and unit number of the given
+// symbol in the sheet instance being drawn.
+func (r *renderer) reference(sym *kicad.SchSymbol) (string, int) {
+	ref, unit := sym.Property("Reference"), sym.Unit
+	if h, hs := r.opts.Hierarchy, r.opts.Sheet; h != nil && hs != nil {
+		ref, unit = h.SymbolReference(hs, sym)
+	}
+	if unit == 0 {
+		unit = 1
+	}
+	return ref, unit
+}
+
+// unitSuffix returns the letters KiCad appends to the reference designator
+// of a symbol to show its unit: "A" for the first unit, then "B" and so on,
+// continuing with "AA" after "Z".
+func unitSuffix(unit int) string {
+	var ret string
+	for unit > 0 {
+		unit--
+		ret = string(rune('A'+unit%26)) + ret
+		unit /= 26
+	}
+	return ret
+}
+
+// symbolBody draws the graphics and pins of the given unit of a symbol.
+func (r *renderer) symbolBody(sym *kicad.SchSymbol, lib *kicad.Symbol, unit int, t transform) {
+	bodyStyle := sym.BodyStyle
+	if bodyStyle == 0 {
+		bodyStyle = 1
+	}
+	units := lib.UnitItems(unit, bodyStyle)
+
+	for _, part := range []shapePart{backgroundOnly, foregroundOnly} {
+		for _, u := range units {
+			r.graphicItems(u.Arcs, u.Circles, u.Rectangles, u.Polylines, u.Beziers, t.apply, bodyColor, part)
+			for i := range u.TextBoxes {
+				if box := &u.TextBoxes[i]; !box.Private {
+					r.symbolTextBox(box, t, part)
+				}
+			}
+		}
+	}
+	for _, u := range units {
+		for _, text := range u.Texts {
+			if text.Private || text.Effects.Hide {
+				continue
+			}
+			effects := text.Effects
+			angle, justify := orientText(t, symbolTextAngle(text.At.Angle), effects.Justify)
+			effects.Justify = justify
+			pos := t.apply(text.At.Position())
+			at := kicad.PositionAngle{X: pos.X, Y: pos.Y, Angle: angle}
+			r.text(text.Text, at, &effects, r.color(effects.Font.Color, bodyColor))
+		}
+	}
+	for _, u := range units {
+		for i := range u.Pins {
+			r.pin(sym, lib, &u.Pins[i], t)
+		}
+	}
+}
+
+// symbolTextAngle returns the angle in degrees of a text item within a
+// symbol. Unlike the angles of every other item, KiCad writes these angles
+// in tenths of a degree.
+func symbolTextAngle(angle float64) float64 {
+	return angle / 10
+}
+
+// symbolTextBox draws a text box within a symbol, whose edges are always
+// drawn parallel to the edges of the page.
+func (r *renderer) symbolTextBox(box *kicad.SchTextBox, t transform, part shapePart) {
+	// Symbol coordinates have Y increasing upwards, so the box extends
+	// downwards from its top left corner by decreasing Y.
+	a := t.apply(box.At.Position())
+	b := t.apply(kicad.Position{X: box.At.X + box.Size.Width, Y: box.At.Y - box.Size.Height})
+	min := kicad.Position{X: math.Min(a.X, b.X), Y: math.Min(a.Y, b.Y)}
+	max := kicad.Position{X: math.Max(a.X, b.X), Y: math.Max(a.Y, b.Y)}
+
+	onPage := *box
+	angle, justify := orientText(t, box.At.Angle, box.Effects.Justify)
+	onPage.Effects.Justify = justify
+	if angle == 0 {
+		onPage.At = kicad.PositionAngle{X: min.X, Y: min.Y}
+		onPage.Size = kicad.Size{Width: max.X - min.X, Height: max.Y - min.Y}
+	} else {
+		// A box rotated to read upwards extends up from its bottom
+		// left corner.
+		onPage.At = kicad.PositionAngle{X: min.X, Y: max.Y, Angle: angle}
+		onPage.Size = kicad.Size{Width: max.Y - min.Y, Height: max.X - min.X}
+	}
+	r.textBox(&onPage, part)
+}
+
+// pin draws a pin of a symbol with its name and number.
+func (r *renderer) pin(sym *kicad.SchSymbol, lib *kicad.Symbol, pin *kicad.Pin, t transform) {
+	if pin.Hide {
+		return
+	}
+	name, graphicStyle := pin.Name.Text, pin.GraphicStyle
+	for _, sp := range sym.Pins {
+		if sp.Number != pin.Number.Text || sp.Alternate == "" {
+			continue
+		}
+		for _, alt := range pin.Alternates {
+			if alt.Name == sp.Alternate {
+				name, graphicStyle = alt.Name, alt.GraphicStyle
+			}
+		}
+	}
+
+	at, end := t.apply(pin.At.Position()), t.apply(pin.End())
+	length := kicad.Distance(at, end)
+	if length == 0 {
+		return
+	}
+	// dir points along the pin towards the body, and above is
+	// perpendicular to the pin on the side that its text is drawn above.
+	dir := kicad.Position{X: (end.X - at.X) / length, Y: (end.Y - at.Y) / length}
+	horizontal := math.Abs(dir.X) >= math.Abs(dir.Y)
+	above := kicad.Position{Y: -1}
+	if !horizontal {
+		above = kicad.Position{X: -1}
+	}
+	r.pinShape(at, end, dir, above, graphicStyle)
+
+	textAngle, reading := 0.0, kicad.Position{X: 1}
+	if !horizontal {
+		textAngle, reading = 90, kicad.Position{Y: -1}
+	}
+	offset := defaultPinNameOffs
+	if lib.PinNames != nil && lib.PinNames.Offset != nil {
+		offset = *lib.PinNames.Offset
+	}
+	showName := (lib.PinNames == nil || !lib.PinNames.Hide) && name != "" && name != "~"
+	showNumber := lib.PinNumbers == nil || !lib.PinNumbers.Hide
+	mid := kicad.Position{X: (at.X + end.X) / 2, Y: (at.Y + end.Y) / 2}
+	margin := pinTextMargin + defaultLineWidth
+
+	pinText := func(text string, effects kicad.TextEffects, pos kicad.Position, justify kicad.TextJustify, color kicad.Color) {
+		effects.Justify = justify
+		at := kicad.PositionAngle{X: pos.X, Y: pos.Y, Angle: textAngle}
+		r.text(text, at, &effects, r.color(effects.Font.Color, color))
+	}
+	offsetBy := func(p, v kicad.Position, d float64) kicad.Position {
+		return kicad.Position{X: p.X + v.X*d, Y: p.Y + v.Y*d}
+	}
+
+	if offset > 0 {
+		// The name is inside the body, reading away from the pin, and
+		// the number is above the pin.
+		if showName {
+			justify := kicad.TextJustify{Left: true}
+			if dir.X*reading.X+dir.Y*reading.Y < 0 {
+				justify = kicad.TextJustify{Right: true}
+			}
+			pinText(name, pin.Name.Effects, offsetBy(end, dir, offset), justify, pinNameColor)
+		}
+		if showNumber {
+			pinText(pin.Number.Text, pin.Number.Effects, offsetBy(mid, above, margin), kicad.TextJustify{Bottom: true}, pinNumberColor)
+		}
+		return
+	}
+	// The name is above the pin and the number below it.
+	if showName {
+		pinText(name, pin.Name.Effects, offsetBy(mid, above, margin), kicad.TextJustify{Bottom: true}, pinNameColor)
+	}
+	if showNumber {
+		pinText(pin.Number.Text, pin.Number.Effects, offsetBy(mid, above, -margin), kicad.TextJustify{Top: true}, pinNumberColor)
+	}
+}
+
+// pinShape draws the line of a pin from at to end, decorated to show its
+// graphic style.
+func (r *renderer) pinShape(at, end, dir, above kicad.Position, graphicStyle string) {
+	// p returns the point offset from the end of the pin by the given
+	// distances along the pin, towards the body, and above it.
+	p := func(along, up float64) kicad.Position {
+		return kicad.Position{
+			X: end.X + dir.X*along + above.X*up,
+			Y: end.Y + dir.Y*along + above.Y*up,
+		}
+	}
+	const d = pinSymbolSize
+
+	lineEnd := end
+	var lines [][]kicad.Position
+	switch graphicStyle {
+	case "inverted", "inverted_clock":
+		lineEnd = p(-2*pinInvertRadius, 0)
+		r.c.circle(p(-pinInvertRadius, 0), pinInvertRadius, r.lineStyle(0, kicad.Color{}, pinColor))
+	case "input_low", "clock_low":
+		lines = append(lines, []kicad.Position{p(-2*d, 0), p(-2*d, d), end})
+	case "output_low":
+		lines = append(lines, []kicad.Position{p(0, d), p(-2*d, 0)})
+	case "edge_clock_high":
+		lines = append(lines, []kicad.Position{p(0, d/2), p(-d, 0), p(0, -d/2)})
+	case "non_logic":
+		lines = append(lines,
+			[]kicad.Position{p(-d/2, d/2), p(d/2, -d/2)},
+			[]kicad.Position{p(-d/2, -d/2), p(d/2, d/2)},
+		)
+	}
+	switch graphicStyle {
+	case "clock", "inverted_clock", "clock_low", "edge_clock_high":
+		lines = append(lines, []kicad.Position{p(0, d/2), p(d, 0), p(0, -d/2)})
+	}
+	lines = append(lines, []kicad.Position{at, lineEnd})
+	r.c.path(lines, false, r.lineStyle(0, kicad.Color{}, pinColor))
+}
+
+// symbolFields draws the visible fields of a symbol, with the given
+// reference designator.
+func (r *renderer) symbolFields(sym *kicad.SchSymbol, ref string, unit int, t transform) {
+	resolve := func(name string) (string, bool) {
+		switch name {
+		case "REFERENCE":
+			return ref, true
+		case "UNIT":
+			return unitSuffix(unit), true
+		}
+		for _, prop := range sym.Properties {
+			if prop.Name == name {
+				return prop.Value, true
+			}
+		}
+		return "", false
+	}
+	for _, prop := range sym.Properties {
+		if prop.Hide || prop.Effects.Hide {
+			continue
+		}
+		text := prop.Value
+		color := fieldColor
+		switch prop.Name {
+		case "Reference":
+			text, color = ref, referenceColor
+		case "Value":
+			color = valueColor
+		}
+		text = kicad.ExpandTextVars(text, resolve)
+		if text == "" {
+			continue
+		}
+		if prop.ShowName {
+			text = prop.Name + ": " + text
+		}
+		r.field(text, prop, t, r.color(prop.Effects.Font.Color, color))
+	}
+}
+
+// field draws a field of a symbol, label or sheet, whose angle and
+// justification are relative to an item transformed by t.
+func (r *renderer) field(text string, prop kicad.SchProperty, t transform, color kicad.Color) {
+	effects := prop.Effects
+	angle, justify := orientText(t, prop.At.Angle, effects.Justify)
+	effects.Justify = justify
+	at := kicad.PositionAngle{X: prop.At.X, Y: prop.At.Y, Angle: angle}
+	r.text(text, at, &effects, color)
+}
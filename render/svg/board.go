@@ -0,0 +1,552 @@
+package svg
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+
+	"github.com/apparentlymart/go-kicad"
+	"github.com/apparentlymart/go-kicad/strokefont"
+)
+
+// defaultLineWidth is the width in millimeters of graphic items that have
+// no width of their own.
+const defaultLineWidth = 0.1
+
+// layerRender draws the items of a board that are on one of its layers.
+type layerRender struct {
+	buf    *bytes.Buffer
+	bounds *bounds
+	pcb    *kicad.PCB
+	layer  string
+	copper []string
+
+	isCopper bool
+}
+
+func newLayerRender(buf *bytes.Buffer, b *bounds, pcb *kicad.PCB, layer string) *layerRender {
+	return &layerRender{
+		buf:      buf,
+		bounds:   b,
+		pcb:      pcb,
+		layer:    layer,
+		copper:   pcb.CopperLayers(),
+		isCopper: strings.HasSuffix(layer, ".Cu"),
+	}
+}
+
+func (lr *layerRender) draw() {
+	lr.zones()
+	lr.tracks()
+	lr.vias()
+	lr.pads()
+	lr.graphics()
+	lr.texts()
+}
+
+// strokePath draws a path with a round pen of the given width. The points
+// give the extent of the path, for the bounds of the image.
+func (lr *layerRender) strokePath(d *pathData, width float64, points ...kicad.Position) {
+	if d.empty() {
+		return
+	}
+	fmt.Fprintf(lr.buf, "<path d=%q fill=\"none\" stroke-width=\"%s\"/>\n", d.String(), num(width))
+	lr.bounds.add(width/2, points...)
+}
+
+// fillPath fills a path, and also strokes its outline if width is greater
+// than zero.
+func (lr *layerRender) fillPath(d *pathData, width float64, points ...kicad.Position) {
+	if d.empty() {
+		return
+	}
+	if width > 0 {
+		fmt.Fprintf(lr.buf, "<path d=%q stroke-width=\"%s\"/>\n", d.String(), num(width))
+	} else {
+		fmt.Fprintf(lr.buf, "<path d=%q stroke=\"none\"/>\n", d.String())
+	}
+	lr.bounds.add(width/2, points...)
+}
+
+func (lr *layerRender) stroke(points []kicad.Position, width float64) {
+	if len(points) == 1 {
+		lr.disc(points[0], width/2)
+		return
+	}
+	var d pathData
+	d.polyline(points, false)
+	lr.strokePath(&d, width, points...)
+}
+
+func (lr *layerRender) disc(center kicad.Position, r float64) {
+	fmt.Fprintf(lr.buf, "<circle cx=\"%s\" cy=\"%s\" r=\"%s\" stroke=\"none\"/>\n", num(center.X), num(center.Y), num(r))
+	lr.bounds.add(r, center)
+}
+
+// arc draws the arc from start through mid to end, or a straight line if
+// the points are collinear.
+func (lr *layerRender) arc(start, mid, end kicad.Position, width float64) {
+	var d pathData
+	d.moveTo(start)
+	arcPath(&d, start, mid, end)
+	lr.strokePath(&d, width, arcBounds(start, mid, end)...)
+}
+
+// arcPath continues a path from start with the arc through mid to end.
+func arcPath(d *pathData, start, mid, end kicad.Position) {
+	center, ok := kicad.ArcCenter(start, mid, end)
+	if !ok {
+		d.lineTo(end)
+		return
+	}
+	sweep := kicad.ArcSweep(center, start, mid, end)
+	r := kicad.Distance(center, start)
+	if math.Abs(sweep) > math.Pi*1.999 {
+		// SVG can't draw a complete circle as one arc
+		d.arcTo(r, false, sweep > 0, mid)
+		d.arcTo(r, false, sweep > 0, end)
+		return
+	}
+	d.arcTo(r, math.Abs(sweep) > math.Pi, sweep > 0, end)
+}
+
+// arcBounds returns points at the extremes of an arc, for the bounds of
+// the image. For simplicity this is the whole circle.
+func arcBounds(start, mid, end kicad.Position) []kicad.Position {
+	center, ok := kicad.ArcCenter(start, mid, end)
+	if !ok {
+		return []kicad.Position{start, end}
+	}
+	r := kicad.Distance(center, start)
+	return []kicad.Position{
+		{X: center.X - r, Y: center.Y - r},
+		{X: center.X + r, Y: center.Y + r},
+	}
+}
+
+func (lr *layerRender) zones() {
+	draw := func(z *kicad.Zone) {
+		if z.IsRuleArea() || !kicad.OnLayer(z.LayerNames(), lr.layer) {
+			return
+		}
+		width := 0.0
+		if z.FilledAreasThickness {
+			width = z.MinThickness
+		}
+		for _, poly := range z.Filled {
+			layer := poly.Layer
+			if layer == "" {
+				layer = z.Layer
+			}
+			if layer != lr.layer {
+				continue
+			}
+			var d pathData
+			d.polyline(poly.Points.Polyline(), true)
+			lr.fillPath(&d, width, poly.Points.Polyline()...)
+		}
+	}
+	for i := range lr.pcb.Zones {
+		draw(&lr.pcb.Zones[i])
+	}
+	for i := range lr.pcb.Footprints {
+		fp := &lr.pcb.Footprints[i]
+		for j := range fp.Zones {
+			draw(&fp.Zones[j])
+		}
+	}
+}
+
+func (lr *layerRender) tracks() {
+	for _, seg := range lr.pcb.Segments {
+		if seg.Layer == lr.layer {
+			lr.stroke([]kicad.Position{seg.Start, seg.End}, seg.Width)
+		}
+	}
+	for _, arc := range lr.pcb.Arcs {
+		if arc.Layer == lr.layer {
+			lr.arc(arc.Start, arc.Mid, arc.End, arc.Width)
+		}
+	}
+}
+
+func (lr *layerRender) vias() {
+	if !lr.isCopper {
+		return
+	}
+	for i := range lr.pcb.Vias {
+		via := &lr.pcb.Vias[i]
+		if slices.Contains(lr.viaLayers(via), lr.layer) {
+			lr.disc(via.At, via.Size/2)
+		}
+	}
+}
+
+// viaLayers returns the copper layers that the given via is on. A through
+// via is on all of them, while blind and micro vias are on the layers
+// between the pair given in the via.
+func (lr *layerRender) viaLayers(via *kicad.Via) []string {
+	if via.Type == "" || len(via.Layers) != 2 {
+		return lr.copper
+	}
+	from, to := slices.Index(lr.copper, via.Layers[0]), slices.Index(lr.copper, via.Layers[1])
+	if from < 0 || to < 0 {
+		return via.Layers
+	}
+	if from > to {
+		from, to = to, from
+	}
+	return lr.copper[from : to+1]
+}
+
+func (lr *layerRender) pads() {
+	for i := range lr.pcb.Footprints {
+		fp := &lr.pcb.Footprints[i]
+		for j := range fp.Pads {
+			pad := &fp.Pads[j]
+			if !kicad.OnLayer(pad.Layers, lr.layer) {
+				continue
+			}
+			if lr.isCopper && pad.Type == "np_thru_hole" && pad.Size.Width <= pad.Drill.Width {
+				// A hole without any copper around it
+				continue
+			}
+			lr.pad(fp, pad, lr.pcb.PadMargin(fp, pad, lr.layer))
+		}
+	}
+}
+
+// pad draws the given pad, enlarged by the given margin on each axis.
+func (lr *layerRender) pad(fp *kicad.Footprint, pad *kicad.Pad, margin kicad.Size) {
+	center := fp.BoardPosition(pad.At.Position())
+	angle := pad.At.Angle
+	offset := kicad.RotatePoint(pad.Drill.Offset, angle)
+	center.X += offset.X
+	center.Y += offset.Y
+	tr := func(p kicad.Position) kicad.Position {
+		p = kicad.RotatePoint(p, angle)
+		return kicad.Position{X: center.X + p.X, Y: center.Y + p.Y}
+	}
+
+	w := pad.Size.Width + 2*margin.Width
+	h := pad.Size.Height + 2*margin.Height
+	if w <= 0 || h <= 0 {
+		return
+	}
+
+	var d pathData
+	var extent []kicad.Position
+	switch pad.Shape {
+	case "circle":
+		lr.disc(center, w/2)
+		return
+	case "oval":
+		extent = roundRectPath(&d, w, h, math.Min(w, h)/2, tr)
+	case "roundrect":
+		r := pad.RoundRectRatio*math.Min(pad.Size.Width, pad.Size.Height) + math.Min(margin.Width, margin.Height)
+		r = math.Max(0, math.Min(r, math.Min(w, h)/2))
+		if pad.Chamfer != (kicad.PadChamfer{}) && pad.ChamferRatio > 0 {
+			extent = kicad.TransformPoints(kicad.ChamferedRect(w, h, r, pad.ChamferRatio*math.Min(w, h), pad.Chamfer), tr)
+			d.polyline(extent, true)
+		} else {
+			extent = roundRectPath(&d, w, h, r, tr)
+		}
+	case "trapezoid":
+		extent = kicad.TransformPoints(kicad.TrapezoidCorners(w, h, pad.RectDelta), tr)
+		d.polyline(extent, true)
+	case "custom":
+		lr.customPad(pad, margin, center, tr)
+		return
+	default:
+		extent = roundRectPath(&d, w, h, 0, tr)
+	}
+	lr.fillPath(&d, 0, extent...)
+}
+
+// roundRectPath adds a rectangle of the given size centered on the origin
+// with corners rounded with radius r, transformed by tr, to the given path.
+// It returns the corners of the rectangle.
+func roundRectPath(d *pathData, w, h, r float64, tr func(kicad.Position) kicad.Position) []kicad.Position {
+	x, y := w/2, h/2
+	pt := func(px, py float64) kicad.Position {
+		return tr(kicad.Position{X: px, Y: py})
+	}
+	d.moveTo(pt(-x+r, -y))
+	d.lineTo(pt(x-r, -y))
+	if r > 0 {
+		d.arcTo(r, false, true, pt(x, -y+r))
+	}
+	d.lineTo(pt(x, y-r))
+	if r > 0 {
+		d.arcTo(r, false, true, pt(x-r, y))
+	}
+	d.lineTo(pt(-x+r, y))
+	if r > 0 {
+		d.arcTo(r, false, true, pt(-x, y-r))
+	}
+	if r > 0 {
+		d.lineTo(pt(-x, -y+r))
+		d.arcTo(r, false, true, pt(-x+r, -y))
+	}
+	d.b.WriteString(" Z")
+	return []kicad.Position{pt(-x, -y), pt(x, -y), pt(x, y), pt(-x, y)}
+}
+
+// customPad draws a pad with the "custom" shape, as its anchor pad along
+// with its primitives. Only the anchor pad is enlarged by the margin.
+func (lr *layerRender) customPad(pad *kicad.Pad, margin kicad.Size, center kicad.Position, tr func(kicad.Position) kicad.Position) {
+	w := pad.Size.Width + 2*margin.Width
+	h := pad.Size.Height + 2*margin.Height
+	if pad.Options.Anchor == "rect" {
+		var d pathData
+		extent := roundRectPath(&d, w, h, 0, tr)
+		lr.fillPath(&d, 0, extent...)
+	} else {
+		lr.disc(center, w/2)
+	}
+
+	g := &pad.Primitives
+	width := func(w float64, stroke kicad.Stroke) float64 {
+		if w := stroke.LineWidth(w); w > 0 {
+			return w
+		}
+		return g.Width
+	}
+	lr.graphicItems(g.Lines, g.Arcs, g.Circles, g.Rects, g.Polys, g.Curves, tr, width, true)
+}
+
+// graphics draws the graphic items of the board and of its footprints.
+func (lr *layerRender) graphics() {
+	pcb := lr.pcb
+	width := func(w float64, stroke kicad.Stroke) float64 {
+		if w := stroke.LineWidth(w); w > 0 {
+			return w
+		}
+		return defaultLineWidth
+	}
+	identity := func(p kicad.Position) kicad.Position { return p }
+	lr.graphicItems(pcb.GraphicLines, pcb.GraphicArcs, pcb.GraphicCircles, pcb.GraphicRects, pcb.GraphicPolys, pcb.GraphicCurves, identity, width, false)
+	for i := range pcb.Footprints {
+		fp := &pcb.Footprints[i]
+		lr.graphicItems(fp.Lines, fp.Arcs, fp.Circles, fp.Rects, fp.Polys, fp.Curves, fp.BoardPosition, width, false)
+	}
+}
+
+// graphicItems draws the given graphic items that are on the layer, with
+// their positions transformed by tr. If padShape is set then the items are
+// the primitives of a custom pad, which have no layer of their own and
+// whose polygons are always filled.
+func (lr *layerRender) graphicItems(lines []kicad.GraphicLine, arcs []kicad.GraphicArc, circles []kicad.GraphicCircle, rects []kicad.GraphicRect, polys []kicad.GraphicPoly, curves []kicad.GraphicCurve, tr func(kicad.Position) kicad.Position, width func(float64, kicad.Stroke) float64, padShape bool) {
+	fill := lr.layer != "Edge.Cuts"
+	onLayer := func(layer string) bool {
+		return padShape || layer == lr.layer
+	}
+
+	for _, line := range lines {
+		if !onLayer(line.Layer) {
+			continue
+		}
+		lr.stroke([]kicad.Position{tr(line.Start), tr(line.End)}, width(line.Width, line.Stroke))
+	}
+	for _, arc := range arcs {
+		if !onLayer(arc.Layer) {
+			continue
+		}
+		lr.arc(tr(arc.Start), tr(arc.Mid), tr(arc.End), width(arc.Width, arc.Stroke))
+	}
+	for _, circle := range circles {
+		if !onLayer(circle.Layer) {
+			continue
+		}
+		center, end := tr(circle.Center), tr(circle.End)
+		r := kicad.Distance(center, end)
+		w := width(circle.Width, circle.Stroke)
+		if fill && circle.Filled() {
+			lr.disc(center, r+w/2)
+			continue
+		}
+		var d pathData
+		d.circle(center, r)
+		lr.strokePath(&d, w, kicad.Position{X: center.X - r, Y: center.Y - r}, kicad.Position{X: center.X + r, Y: center.Y + r})
+	}
+	for _, rect := range rects {
+		if !onLayer(rect.Layer) {
+			continue
+		}
+		pts := kicad.TransformPoints(kicad.RectPoints(rect.Start, rect.End), tr)
+		var d pathData
+		d.polyline(pts, true)
+		w := width(rect.Width, rect.Stroke)
+		if (fill && rect.Filled()) || padShape {
+			lr.fillPath(&d, w, pts...)
+		} else {
+			lr.strokePath(&d, w, pts...)
+		}
+	}
+	for _, poly := range polys {
+		if !onLayer(poly.Layer) {
+			continue
+		}
+		pts := kicad.TransformPoints(poly.Points.Polyline(), tr)
+		var d pathData
+		d.polyline(pts, true)
+		w := width(poly.Width, poly.Stroke)
+		if (fill && poly.Filled()) || padShape {
+			lr.fillPath(&d, w, pts...)
+		} else {
+			lr.strokePath(&d, w, pts...)
+		}
+	}
+	for _, curve := range curves {
+		if !onLayer(curve.Layer) {
+			continue
+		}
+		pts := kicad.TransformPoints(curve.Points.Polyline(), tr)
+		if len(pts) != 4 {
+			continue
+		}
+		var d pathData
+		d.moveTo(pts[0])
+		d.curveTo(pts[1], pts[2], pts[3])
+		lr.strokePath(&d, width(curve.Width, curve.Stroke), pts...)
+	}
+}
+
+// texts draws the text of the board and of its footprints.
+func (lr *layerRender) texts() {
+	for _, text := range lr.pcb.Texts {
+		if text.Layer.Name == lr.layer && !text.Effects.Hide {
+			lr.text(text.Text, text.At, &text.Effects)
+		}
+	}
+	for _, dim := range lr.pcb.Dimensions {
+		if dim.Layer == lr.layer && dim.Text.Text != "" && !dim.Text.Effects.Hide {
+			lr.text(dim.Text.Text, dim.Text.At, &dim.Text.Effects)
+		}
+	}
+	for i := range lr.pcb.TextBoxes {
+		if box := &lr.pcb.TextBoxes[i]; box.Layer.Name == lr.layer {
+			lr.textBox(box)
+		}
+	}
+	for i := range lr.pcb.Footprints {
+		fp := &lr.pcb.Footprints[i]
+		for _, text := range fp.Texts {
+			if text.Layer.Name != lr.layer || text.Hide || text.Effects.Hide {
+				continue
+			}
+			lr.text(fp.ExpandText(text.Text), fp.BoardTextAt(text.At), &text.Effects)
+		}
+		for _, prop := range fp.Properties {
+			if prop.Layer.Name != lr.layer || prop.Hide || prop.Effects.Hide {
+				continue
+			}
+			lr.text(fp.ExpandText(prop.Value), fp.BoardTextAt(prop.At), &prop.Effects)
+		}
+	}
+}
+
+// text draws the given text as a single path.
+func (lr *layerRender) text(text string, at kicad.PositionAngle, effects *kicad.TextEffects) {
+	var d pathData
+	var extent []kicad.Position
+	for _, stroke := range strokefont.Strokes(text, at, effects) {
+		if len(stroke) == 1 {
+			// A dot, drawn as a zero-length line so that the pen's
+			// round cap draws it.
+			stroke = append(stroke, stroke[0])
+		}
+		d.polyline(stroke, false)
+		extent = append(extent, stroke...)
+	}
+	lr.strokePath(&d, strokefont.Thickness(effects), extent...)
+}
+
+// textBox draws the border of a text box, if it has one, and its text
+// positioned within the box's margins.
+func (lr *layerRender) textBox(box *kicad.TextBox) {
+	corners := box.Points.Polyline()
+	if len(corners) != 4 {
+		corners = kicad.RectPoints(box.Start, box.End)
+	}
+	if box.Border {
+		var d pathData
+		d.polyline(corners, true)
+		w := box.Stroke.Width
+		if w <= 0 {
+			w = defaultLineWidth
+		}
+		lr.strokePath(&d, w, corners...)
+	}
+	if box.Effects.Hide {
+		return
+	}
+
+	// Work in the box's own unrotated frame, relative to its first
+	// corner, to find where the text is anchored.
+	origin := corners[0]
+	local := func(p kicad.Position) kicad.Position {
+		return kicad.RotatePoint(kicad.Position{X: p.X - origin.X, Y: p.Y - origin.Y}, -box.Angle)
+	}
+	a, b := local(corners[0]), local(corners[2])
+	left, right := math.Min(a.X, b.X)+box.Margins.Left, math.Max(a.X, b.X)-box.Margins.Right
+	top, bottom := math.Min(a.Y, b.Y)+box.Margins.Top, math.Max(a.Y, b.Y)-box.Margins.Bottom
+
+	var anchor kicad.Position
+	switch {
+	case box.Effects.Justify.Right:
+		anchor.X = right
+	case box.Effects.Justify.Left:
+		anchor.X = left
+	default:
+		anchor.X = (left + right) / 2
+	}
+	switch {
+	case box.Effects.Justify.Bottom:
+		anchor.Y = bottom
+	case box.Effects.Justify.Top:
+		anchor.Y = top
+	default:
+		anchor.Y = (top + bottom) / 2
+	}
+	anchor = kicad.RotatePoint(anchor, box.Angle)
+	at := kicad.PositionAngle{X: origin.X + anchor.X, Y: origin.Y + anchor.Y, Angle: box.Angle}
+	lr.text(box.Text, at, &box.Effects)
+}
+
+// drawHoles draws the holes of the board's pads and vias.
+func drawHoles(buf *bytes.Buffer, pcb *kicad.PCB) {
+	for i := range pcb.Footprints {
+		fp := &pcb.Footprints[i]
+		for _, pad := range fp.Pads {
+			if !pad.HasDrill() {
+				continue
+			}
+			at := fp.BoardPosition(pad.At.Position())
+			w, h := pad.Drill.Width, pad.Drill.Height
+			if !pad.Drill.Oval || h <= 0 || w == h {
+				fmt.Fprintf(buf, "<circle cx=\"%s\" cy=\"%s\" r=\"%s\" stroke=\"none\"/>\n", num(at.X), num(at.Y), num(w/2))
+				continue
+			}
+			// A slot, drawn as a line with a round pen as wide as the
+			// slot.
+			var half kicad.Position
+			width := w
+			if w > h {
+				half = kicad.Position{X: (w - h) / 2}
+				width = h
+			} else {
+				half = kicad.Position{Y: (h - w) / 2}
+			}
+			half = kicad.RotatePoint(half, pad.At.Angle)
+			var d pathData
+			d.polyline([]kicad.Position{{X: at.X - half.X, Y: at.Y - half.Y}, {X: at.X + half.X, Y: at.Y + half.Y}}, false)
+			fmt.Fprintf(buf, "<path d=%q fill=\"none\" stroke-width=\"%s\"/>\n", d.String(), num(width))
+		}
+	}
+	for _, via := range pcb.Vias {
+		fmt.Fprintf(buf, "<circle cx=\"%s\" cy=\"%s\" r=\"%s\" stroke=\"none\"/>\n", num(via.At.X), num(via.At.Y), num(via.Drill/2))
+	}
+}
@@ -0,0 +1,117 @@
+package svg
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/apparentlymart/go-kicad"
+)
+
+// pathData builds the value of the "d" attribute of a path element.
+type pathData struct {
+	b strings.Builder
+}
+
+func (d *pathData) moveTo(p kicad.Position) {
+	d.cmd("M", p)
+}
+
+func (d *pathData) lineTo(p kicad.Position) {
+	d.cmd("L", p)
+}
+
+// polyline adds the given points as a new subpath, closing it if close is
+// true.
+func (d *pathData) polyline(points []kicad.Position, close bool) {
+	if len(points) == 0 {
+		return
+	}
+	d.moveTo(points[0])
+	for _, p := range points[1:] {
+		d.lineTo(p)
+	}
+	if close {
+		d.b.WriteString(" Z")
+	}
+}
+
+// arcTo adds a circular arc of radius r to the given end point. Clockwise
+// is as seen on screen, which SVG calls the positive direction.
+func (d *pathData) arcTo(r float64, large, clockwise bool, end kicad.Position) {
+	d.sep()
+	d.b.WriteString("A" + num(r) + " " + num(r) + " 0 " + flag(large) + " " + flag(clockwise) + " " + num(end.X) + " " + num(end.Y))
+}
+
+func (d *pathData) curveTo(c1, c2, end kicad.Position) {
+	d.sep()
+	d.b.WriteString("C" + num(c1.X) + " " + num(c1.Y) + " " + num(c2.X) + " " + num(c2.Y) + " " + num(end.X) + " " + num(end.Y))
+}
+
+// circle adds a full circle as a closed subpath of two semicircles.
+func (d *pathData) circle(center kicad.Position, r float64) {
+	left := kicad.Position{X: center.X - r, Y: center.Y}
+	right := kicad.Position{X: center.X + r, Y: center.Y}
+	d.moveTo(left)
+	d.arcTo(r, false, true, right)
+	d.arcTo(r, false, true, left)
+	d.b.WriteString(" Z")
+}
+
+func (d *pathData) cmd(c string, p kicad.Position) {
+	d.sep()
+	d.b.WriteString(c + num(p.X) + " " + num(p.Y))
+}
+
+func (d *pathData) sep() {
+	if d.b.Len() > 0 {
+		d.b.WriteByte(' ')
+	}
+}
+
+func (d *pathData) String() string {
+	return d.b.String()
+}
+
+func (d *pathData) empty() bool {
+	return d.b.Len() == 0
+}
+
+func flag(b bool) string {
+	if b {
+		return "1"
+	}
+	return "0"
+}
+
+// num formats a length in millimeters, to the nearest tenth of a
+// micrometer and without trailing zeros.
+func num(v float64) string {
+	v = math.Round(v*1e4) / 1e4
+	if v == 0 {
+		// Avoid writing negative zero
+		v = 0
+	}
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// bounds accumulates the box around the items drawn.
+type bounds struct {
+	min, max kicad.Position
+	ok       bool
+}
+
+// add extends the box to include the given points, enlarged by the given
+// margin in every direction.
+func (b *bounds) add(margin float64, points ...kicad.Position) {
+	for _, p := range points {
+		lo := kicad.Position{X: p.X - margin, Y: p.Y - margin}
+		hi := kicad.Position{X: p.X + margin, Y: p.Y + margin}
+		if !b.ok {
+			b.min, b.max, b.ok = lo, hi, true
+			continue
+		}
+		b.min.X, b.min.Y = math.Min(b.min.X, lo.X), math.Min(b.min.Y, lo.Y)
+		b.max.X, b.max.Y = math.Max(b.max.X, hi.X), math.Max(b.max.Y, hi.Y)
+	}
+}
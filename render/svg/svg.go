@@ -0,0 +1,198 @@
+// Package svg renders the layers of a KiCad board as an SVG image, for
+// previews of a board in documents and web pages.
+//
+// Each layer is drawn as a group of shapes in a single color, with the
+// layers stacked in the order given in Options. Text is drawn with the
+// font from package strokefont, which is not the same as KiCad's font, and
+// so text will differ in appearance from KiCad's own rendering. Images and
+// the lines and arrows of dimensions are not drawn.
+package svg
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"io"
+	"math"
+	"strings"
+
+	"github.com/apparentlymart/go-kicad"
+)
+
+// Options customizes how Render draws a board. A nil *Options is equivalent
+// to a pointer to the zero value, which draws the layers returned by
+// DefaultLayers in their default colors, at actual size.
+type Options struct {
+	// Layers are the layers to draw, from the bottom of the stack to the
+	// top. If empty, the layers returned by DefaultLayers are drawn.
+	Layers []string
+
+	// Colors overrides the colors of particular layers, by layer name.
+	// The layers not listed are drawn in the colors DefaultColor returns.
+	Colors map[string]kicad.Color
+
+	// Background is the color of the background, which is transparent if
+	// the color is the zero value.
+	Background kicad.Color
+
+	// Mirror flips the image horizontally, to show the bottom of the
+	// board as seen from below.
+	Mirror bool
+
+	// Scale gives the size of the image in pixels per millimeter. If zero,
+	// the image is sized in millimeters so that it prints at actual size.
+	Scale float64
+
+	// Viewport is the area of the board to show. If it is the zero value,
+	// the image shows the area inside the board outline, or if there is
+	// no outline then the area around everything drawn.
+	Viewport kicad.BoundingBox
+
+	// Margin is the space in millimeters to leave around the board when
+	// the viewport is chosen automatically.
+	Margin float64
+
+	// DrillHoles draws the holes of pads and vias in the background
+	// color, or in white if the background is transparent.
+	DrillHoles bool
+}
+
+// DefaultLayers returns the layers that Render draws by default for the
+// given board: the copper layers, followed by the silkscreen and the
+// board outline. For a top view the front copper and silkscreen layers are
+// drawn above the others, while for a mirrored view the back layers are.
+func DefaultLayers(pcb *kicad.PCB, mirror bool) []string {
+	copper := pcb.CopperLayers()
+	var ret []string
+	if mirror {
+		ret = append(ret, copper...)
+		ret = append(ret, "B.SilkS")
+	} else {
+		for i := len(copper) - 1; i >= 0; i-- {
+			ret = append(ret, copper[i])
+		}
+		ret = append(ret, "F.SilkS")
+	}
+	return append(ret, "Edge.Cuts")
+}
+
+// defaultColors are the colors of KiCad's default color theme.
+var defaultColors = map[string]kicad.Color{
+	"F.Cu":      {R: 200, G: 52, B: 52, A: 1},
+	"In1.Cu":    {R: 127, G: 200, B: 127, A: 1},
+	"In2.Cu":    {R: 206, G: 125, B: 44, A: 1},
+	"In3.Cu":    {R: 79, G: 203, B: 203, A: 1},
+	"In4.Cu":    {R: 219, G: 98, B: 139, A: 1},
+	"B.Cu":      {R: 77, G: 127, B: 196, A: 1},
+	"F.Adhes":   {R: 132, G: 0, B: 132, A: 1},
+	"B.Adhes":   {R: 0, G: 0, B: 132, A: 1},
+	"F.Paste":   {R: 180, G: 160, B: 154, A: 0.9},
+	"B.Paste":   {R: 0, G: 194, B: 194, A: 0.9},
+	"F.SilkS":   {R: 242, G: 237, B: 161, A: 1},
+	"B.SilkS":   {R: 232, G: 178, B: 167, A: 1},
+	"F.Mask":    {R: 216, G: 100, B: 255, A: 0.4},
+	"B.Mask":    {R: 2, G: 255, B: 238, A: 0.4},
+	"Dwgs.User": {R: 194, G: 194, B: 194, A: 1},
+	"Cmts.User": {R: 89, G: 148, B: 220, A: 1},
+	"Eco1.User": {R: 180, G: 219, B: 210, A: 1},
+	"Eco2.User": {R: 216, G: 200, B: 82, A: 1},
+	"Edge.Cuts": {R: 208, G: 210, B: 205, A: 1},
+	"Margin":    {R: 255, G: 38, B: 226, A: 1},
+	"F.CrtYd":   {R: 255, G: 38, B: 226, A: 1},
+	"B.CrtYd":   {R: 38, G: 233, B: 255, A: 1},
+	"F.Fab":     {R: 175, G: 175, B: 175, A: 1},
+	"B.Fab":     {R: 88, G: 93, B: 132, A: 1},
+}
+
+// DefaultColor returns the color of the given layer in KiCad's default
+// color theme.
+func DefaultColor(layer string) kicad.Color {
+	if c, ok := defaultColors[layer]; ok {
+		return c
+	}
+	if strings.HasSuffix(layer, ".Cu") {
+		return kicad.Color{R: 194, G: 194, B: 0, A: 1}
+	}
+	return kicad.Color{R: 128, G: 128, B: 128, A: 1}
+}
+
+// Render draws the given board as an SVG image, writing the image to the
+// given writer.
+func Render(w io.Writer, pcb *kicad.PCB, opts *Options) error {
+	if opts == nil {
+		opts = &Options{}
+	}
+	layers := opts.Layers
+	if len(layers) == 0 {
+		layers = DefaultLayers(pcb, opts.Mirror)
+	}
+
+	var body bytes.Buffer
+	var drawn bounds
+	for _, layer := range layers {
+		color, ok := opts.Colors[layer]
+		if !ok {
+			color = DefaultColor(layer)
+		}
+		fmt.Fprintf(&body, "<g id=%q fill=%q stroke=%q", html.EscapeString(layer), color.Hex(), color.Hex())
+		if color.A > 0 && color.A < 1 {
+			fmt.Fprintf(&body, " opacity=\"%s\"", num(color.A))
+		}
+		body.WriteString(">\n")
+		newLayerRender(&body, &drawn, pcb, layer).draw()
+		body.WriteString("</g>\n")
+	}
+	if opts.DrillHoles {
+		color := opts.Background
+		if color == (kicad.Color{}) {
+			color = kicad.Color{R: 255, G: 255, B: 255, A: 1}
+		}
+		fmt.Fprintf(&body, "<g id=\"holes\" fill=%q stroke=%q>\n", color.Hex(), color.Hex())
+		drawHoles(&body, pcb)
+		body.WriteString("</g>\n")
+	}
+
+	var min, max kicad.Position
+	switch {
+	case opts.Viewport != (kicad.BoundingBox{}):
+		v := opts.Viewport
+		min = kicad.Position{X: math.Min(v.X1, v.X2), Y: math.Min(v.Y1, v.Y2)}
+		max = kicad.Position{X: math.Max(v.X1, v.X2), Y: math.Max(v.Y1, v.Y2)}
+	default:
+		// Draw the outline into a scratch buffer just to find its extent
+		var outline bounds
+		newLayerRender(&bytes.Buffer{}, &outline, pcb, "Edge.Cuts").draw()
+		if !outline.ok {
+			outline = drawn
+		}
+		min, max = outline.min, outline.max
+		min.X, min.Y = min.X-opts.Margin, min.Y-opts.Margin
+		max.X, max.Y = max.X+opts.Margin, max.Y+opts.Margin
+	}
+	width, height := max.X-min.X, max.Y-min.Y
+
+	var buf bytes.Buffer
+	buf.WriteString("<?xml version=\"1.0\" standalone=\"no\"?>\n")
+	buf.WriteString("<svg xmlns=\"http://www.w3.org/2000/svg\" version=\"1.1\"")
+	if opts.Scale > 0 {
+		fmt.Fprintf(&buf, " width=\"%s\" height=\"%s\"", num(width*opts.Scale), num(height*opts.Scale))
+	} else {
+		fmt.Fprintf(&buf, " width=\"%smm\" height=\"%smm\"", num(width), num(height))
+	}
+	fmt.Fprintf(&buf, " viewBox=\"%s %s %s %s\">\n", num(min.X), num(min.Y), num(width), num(height))
+	if opts.Background != (kicad.Color{}) {
+		fmt.Fprintf(&buf, "<rect x=\"%s\" y=\"%s\" width=\"%s\" height=\"%s\" fill=%q/>\n", num(min.X), num(min.Y), num(width), num(height), opts.Background.Hex())
+	}
+	buf.WriteString("<g stroke-linecap=\"round\" stroke-linejoin=\"round\"")
+	if opts.Mirror {
+		// Reflect about the vertical center line of the viewport
+		fmt.Fprintf(&buf, " transform=\"matrix(-1 0 0 1 %s 0)\"", num(min.X+max.X))
+	}
+	buf.WriteString(">\n")
+	buf.Write(body.Bytes())
+	buf.WriteString("</g>\n")
+	buf.WriteString("</svg>\n")
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
@@ -0,0 +1,168 @@
+package svg
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/apparentlymart/go-kicad"
+	"github.com/apparentlymart/go-kicad/internal/testboard"
+)
+
+const testBoardSrc = `(kicad_pcb (version 20221018) (generator pcbnew)
+  (layers
+    (0 "F.Cu" signal)
+    (31 "B.Cu" signal)
+    (37 "F.SilkS" user "F.Silkscreen")
+    (39 "F.Mask" user)
+    (44 "Edge.Cuts" user)
+  )
+  (setup
+    (pad_to_mask_clearance 0.05)
+  )
+  (net 0 "")
+  (net 1 "VCC")
+  (footprint "Test:Pads" (layer "F.Cu")
+    (at 10 10 90)
+    (property "Reference" "U1" (at 0 -3 90) (layer "F.SilkS") (effects (font (size 1 1) (thickness 0.15)) hide))
+    (pad "1" thru_hole circle (at -2 0 90) (size 1.5 1.5) (drill 0.8) (layers "*.Cu" "*.Mask") (net 1 "VCC"))
+    (pad "2" smd rect (at 2 0 90) (size 1 0.5) (layers "F.Cu" "F.Mask"))
+    (fp_line (start -3 -1) (end 3 -1) (layer "F.SilkS") (stroke (width 0.12) (type solid)))
+  )
+  (segment (start 8 10) (end 8 15) (width 0.25) (layer "F.Cu") (net 1))
+  (arc (start 12 10) (mid 13 11) (end 14 10) (width 0.25) (layer "B.Cu") (net 1))
+  (via (at 8 15) (size 0.6) (drill 0.3) (layers "F.Cu" "B.Cu") (net 1))
+  (zone (net 1) (net_name "VCC") (layer "B.Cu") (hatch edge 0.5)
+    (connect_pads (clearance 0.2))
+    (min_thickness 0.2)
+    (fill yes (thermal_gap 0.5) (thermal_bridge_width 0.5))
+    (polygon (pts (xy 0 20) (xy 20 20) (xy 20 25) (xy 0 25)))
+    (filled_polygon (layer "B.Cu") (pts (xy 0.1 20.1) (xy 19.9 20.1) (xy 19.9 24.9) (xy 0.1 24.9)))
+  )
+  (gr_rect (start 0 0) (end 20 25) (layer "Edge.Cuts") (stroke (width 0.05) (type solid)) (fill none))
+  (gr_text "I" (at 5 5) (layer "F.SilkS") (effects (font (size 1 1) (thickness 0.15))))
+)
+`
+
+func testBoard(t *testing.T) *kicad.PCB {
+	t.Helper()
+	pcb, err := kicad.ReadPCB(strings.NewReader(testBoardSrc))
+	if err != nil {
+		t.Fatalf("reading board: %s", err)
+	}
+	return pcb
+}
+
+func render(t *testing.T, pcb *kicad.PCB, opts *Options) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := Render(&buf, pcb, opts); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func TestRender(t *testing.T) {
+	got := render(t, testBoard(t), nil)
+	want := `<?xml version="1.0" standalone="no"?>
+<svg xmlns="http://www.w3.org/2000/svg" version="1.1" width="20.05mm" height="25.05mm" viewBox="-0.025 -0.025 20.05 25.05">
+<g stroke-linecap="round" stroke-linejoin="round">
+<g id="B.Cu" fill="#4D7FC4" stroke="#4D7FC4">
+<path d="M0.1 20.1 L19.9 20.1 L19.9 24.9 L0.1 24.9 Z" stroke="none"/>
+<path d="M12 10 A1 1 0 0 0 14 10" fill="none" stroke-width="0.25"/>
+<circle cx="8" cy="15" r="0.3" stroke="none"/>
+<circle cx="10" cy="12" r="0.75" stroke="none"/>
+</g>
+<g id="F.Cu" fill="#C83434" stroke="#C83434">
+<path d="M8 10 L8 15" fill="none" stroke-width="0.25"/>
+<circle cx="8" cy="15" r="0.3" stroke="none"/>
+<circle cx="10" cy="12" r="0.75" stroke="none"/>
+<path d="M9.75 8.5 L9.75 7.5 L10.25 7.5 L10.25 8.5 Z" stroke="none"/>
+</g>
+<g id="F.SilkS" fill="#F2EDA1" stroke="#F2EDA1">
+<path d="M9 13 L9 7" fill="none" stroke-width="0.12"/>
+<path d="M4.8333 5.5 L5.1667 5.5 M5 5.5 L5 4.5 M4.8333 4.5 L5.1667 4.5" fill="none" stroke-width="0.15"/>
+</g>
+<g id="Edge.Cuts" fill="#D0D2CD" stroke="#D0D2CD">
+<path d="M0 0 L20 0 L20 25 L0 25 Z" fill="none" stroke-width="0.05"/>
+</g>
+</g>
+</svg>
+`
+	if got != want {
+		t.Errorf("incorrect result\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestRender_blindVias(t *testing.T) {
+	got := render(t, testboard.Read(t), nil)
+
+	// The blind via from F.Cu to In1.Cu appears only on those layers
+	via := `<circle cx="121" cy="96" r="0.225" stroke="none"/>`
+	var layers []string
+	for _, group := range strings.Split(got, `<g id="`)[1:] {
+		if strings.Contains(group, via) {
+			layer, _, _ := strings.Cut(group, `"`)
+			layers = append(layers, layer)
+		}
+	}
+	if want := []string{"In1.Cu", "F.Cu"}; !reflect.DeepEqual(layers, want) {
+		t.Errorf("blind via drawn on layers %q; want %q", layers, want)
+	}
+}
+
+func TestRender_mirror(t *testing.T) {
+	got := render(t, testBoard(t), &Options{Mirror: true})
+	if want := ` transform="matrix(-1 0 0 1 20 0)"`; !strings.Contains(got, want) {
+		t.Errorf("missing mirror transform %q in\n%s", want, got)
+	}
+	// In the bottom view the back copper is drawn above the front copper.
+	if strings.Index(got, `id="F.Cu"`) > strings.Index(got, `id="B.Cu"`) {
+		t.Errorf("F.Cu drawn above B.Cu in mirrored view\n%s", got)
+	}
+	if strings.Contains(got, `id="F.SilkS"`) {
+		t.Errorf("front silkscreen drawn in mirrored view\n%s", got)
+	}
+}
+
+func TestRender_options(t *testing.T) {
+	got := render(t, testBoard(t), &Options{
+		Layers: []string{"F.Cu"},
+		Colors: map[string]kicad.Color{
+			"F.Cu": {R: 255, G: 128, B: 0, A: 0.5},
+		},
+		Background: kicad.Color{R: 0, G: 0, B: 0, A: 1},
+		Scale:      10,
+		Viewport:   kicad.BoundingBox{X1: 5, Y1: 5, X2: 15, Y2: 20},
+		DrillHoles: true,
+	})
+	for _, want := range []string{
+		` width="100" height="150" viewBox="5 5 10 15">`,
+		`<rect x="5" y="5" width="10" height="15" fill="#000000"/>`,
+		`<g id="F.Cu" fill="#FF8000" stroke="#FF8000" opacity="0.5">`,
+		`<g id="holes" fill="#000000" stroke="#000000">`,
+		`<circle cx="10" cy="12" r="0.4" stroke="none"/>`,
+		`<circle cx="8" cy="15" r="0.15" stroke="none"/>`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("missing %q in\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, `id="B.Cu"`) {
+		t.Errorf("unselected layer drawn\n%s", got)
+	}
+}
+
+func TestRender_mask(t *testing.T) {
+	got := render(t, testBoard(t), &Options{Layers: []string{"F.Mask"}})
+	for _, want := range []string{
+		// Pads are enlarged by the board's mask clearance.
+		`<circle cx="10" cy="12" r="0.8" stroke="none"/>`,
+		`<path d="M9.7 8.55 L9.7 7.45 L10.3 7.45 L10.3 8.55 Z" stroke="none"/>`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("missing %q in\n%s", want, got)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package kicad
+
+// The graphic item types in this file are used both within symbols in
+// symbol libraries and directly on schematic sheets.
+
+// SchFill describes how the interior of a closed schematic shape is drawn.
+// Type is one of "none", "outline" to fill with the stroke color,
+// "background" to fill with the body background color, or "color" to fill
+// with Color.
+type SchFill struct {
+	Type  string `kicad:"type"`
+	Color Color  `kicad:"color,flat"`
+}
+
+// SchArc is a circular arc from Start through Mid to End.
+type SchArc struct {
+	Private bool     `kicad:"private,bare"`
+	Start   Position `kicad:"start,flat"`
+	Mid     Position `kicad:"mid,flat"`
+	End     Position `kicad:"end,flat"`
+	Stroke  Stroke   `kicad:"stroke,flat"`
+	Fill    SchFill  `kicad:"fill,flat"`
+	UUID    string   `kicad:"uuid"`
+}
+
+// SchCircle is a circle around Center.
+type SchCircle struct {
+	Private bool     `kicad:"private,bare"`
+	Center  Position `kicad:"center,flat"`
+	Radius  float64  `kicad:"radius"`
+	Stroke  Stroke   `kicad:"stroke,flat"`
+	Fill    SchFill  `kicad:"fill,flat"`
+	UUID    string   `kicad:"uuid"`
+}
+
+// SchRectangle is an axis-aligned rectangle with opposite corners at Start
+// and End.
+type SchRectangle struct {
+	Private bool     `kicad:"private,bare"`
+	Start   Position `kicad:"start,flat"`
+	End     Position `kicad:"end,flat"`
+	Stroke  Stroke   `kicad:"stroke,flat"`
+	Fill    SchFill  `kicad:"fill,flat"`
+	UUID    string   `kicad:"uuid"`
+}
+
+// SchPolyline is a series of connected straight lines, which is closed if
+// its first and last points are the same.
+type SchPolyline struct {
+	Private bool    `kicad:"private,bare"`
+	Points  Points  `kicad:"pts,flat"`
+	Stroke  Stroke  `kicad:"stroke,flat"`
+	Fill    SchFill `kicad:"fill,flat"`
+	UUID    string  `kicad:"uuid"`
+}
+
+// SchBezier is a cubic Bézier curve, whose four control points are given in
+// order in Points.
+type SchBezier struct {
+	Private bool    `kicad:"private,bare"`
+	Points  Points  `kicad:"pts,flat"`
+	Stroke  Stroke  `kicad:"stroke,flat"`
+	Fill    SchFill `kicad:"fill,flat"`
+	UUID    string  `kicad:"uuid"`
+}
+
+// SchText is a free text item.
+type SchText struct {
+	Private          bool          `kicad:"private,bare"`
+	Text             string        `kicad:""`
+	ExcludeFromSim   bool          `kicad:"exclude_from_sim"`
+	At               PositionAngle `kicad:"at,flat,always"`
+	FieldsAutoplaced bool          `kicad:"fields_autoplaced,empty"`
+	Effects          TextEffects   `kicad:"effects,flat"`
+	UUID             string        `kicad:"uuid"`
+}
+
+// SchTextBox is a text item drawn within a box, introduced in KiCad 7. The
+// box has its top left corner at At, before rotation.
+type SchTextBox struct {
+	Private        bool          `kicad:"private,bare"`
+	Text           string        `kicad:""`
+	ExcludeFromSim bool          `kicad:"exclude_from_sim"`
+	At             PositionAngle `kicad:"at,flat,always"`
+	Size           Size          `kicad:"size,flat"`
+	Margins        Margins       `kicad:"margins,flat"`
+	Stroke         Stroke        `kicad:"stroke,flat"`
+	Fill           SchFill       `kicad:"fill,flat"`
+	Effects        TextEffects   `kicad:"effects,flat"`
+	UUID           string        `kicad:"uuid"`
+}
+
+// SchProperty is a named field of a symbol or sheet, such as its reference
+// designator or value.
+//
+// ID is the field number used by KiCad 6 and 7, which identifies the
+// mandatory fields independently of their names. It is nil in documents
+// from later versions, which identify fields only by name.
+type SchProperty struct {
+	Name           string        `kicad:""`
+	Value          string        `kicad:""`
+	ID             *int          `kicad:"id"`
+	At             PositionAngle `kicad:"at,flat,always"`
+	ShowName       bool          `kicad:"show_name"`
+	DoNotAutoplace bool          `kicad:"do_not_autoplace"`
+	Hide           bool          `kicad:"hide"`
+	Effects        TextEffects   `kicad:"effects,flat"`
+}
@@ -0,0 +1,281 @@
+package kicad
+
+import (
+	"io"
+	"os"
+
+	"github.com/apparentlymart/go-kicad/sexp"
+)
+
+// ReadSchematic reads a stream containing a single schematic sheet, as found
+// in a .kicad_sch file, and returns a Schematic structure describing it.
+//
+// Sub-sheets are not loaded. Use LoadSchematicHierarchy to load a root
+// sheet along with all of the sheets below it.
+func ReadSchematic(r io.Reader) (*Schematic, error) {
+	sch := &Schematic{}
+	err := sexp.Decode(r, "kicad_sch", sch)
+	return sch, err
+}
+
+// ReadSchematicFile is a convenience wrapper around ReadSchematic that takes
+// a filename and opens the given file for reading before calling
+// ReadSchematic.
+func ReadSchematicFile(filename string) (*Schematic, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ReadSchematic(f)
+}
+
+// Schematic represents a single sheet of a KiCad eeschema schematic.
+//
+// LibSymbols holds copies of all of the library symbols used on the sheet,
+// so that the sheet can be drawn without access to the symbol libraries.
+//
+// SheetInstances and SymbolInstances are present only in the root sheet of
+// KiCad 6 documents, and give the page numbers and reference designators
+// for each instance of each sheet and symbol in the hierarchy. Later
+// versions instead record these in the Instances of each sheet and symbol.
+type Schematic struct {
+	Version          int              `kicad:"version"`
+	Generator        string           `kicad:"generator"`
+	GeneratorVersion string           `kicad:"generator_version"`
+	UUID             string           `kicad:"uuid"`
+	Paper            PaperSize        `kicad:"paper,flat"`
+	TitleBlock       TitleBlock       `kicad:"title_block,flat"`
+	LibSymbols       SchLibSymbols    `kicad:"lib_symbols,flat"`
+	Junctions        []Junction       `kicad:"junction,multi,flat"`
+	NoConnects       []NoConnect      `kicad:"no_connect,multi,flat"`
+	BusEntries       []BusEntry       `kicad:"bus_entry,multi,flat"`
+	Wires            []Wire           `kicad:"wire,multi,flat"`
+	Buses            []Wire           `kicad:"bus,multi,flat"`
+	Polylines        []SchPolyline    `kicad:"polyline,multi,flat"`
+	Arcs             []SchArc         `kicad:"arc,multi,flat"`
+	Circles          []SchCircle      `kicad:"circle,multi,flat"`
+	Rectangles       []SchRectangle   `kicad:"rectangle,multi,flat"`
+	Beziers          []SchBezier      `kicad:"bezier,multi,flat"`
+	Texts            []SchText        `kicad:"text,multi,flat"`
+	TextBoxes        []SchTextBox     `kicad:"text_box,multi,flat"`
+	Labels           []Label          `kicad:"label,multi,flat"`
+	GlobalLabels     []Label          `kicad:"global_label,multi,flat"`
+	HierLabels       []Label          `kicad:"hierarchical_label,multi,flat"`
+	Symbols          []SchSymbol      `kicad:"symbol,multi,flat"`
+	Sheets           []Sheet          `kicad:"sheet,multi,flat"`
+	SheetInstances   SchInstancePaths `kicad:"sheet_instances,flat"`
+	SymbolInstances  SchInstancePaths `kicad:"symbol_instances,flat"`
+}
+
+// LibSymbol returns the library symbol used by the given symbol instance,
+// or nil if the schematic has no such library symbol.
+func (s *Schematic) LibSymbol(sym *SchSymbol) *Symbol {
+	name := sym.LibName
+	if name == "" {
+		name = sym.LibID
+	}
+	for i := range s.LibSymbols.Symbols {
+		if s.LibSymbols.Symbols[i].Name == name {
+			return &s.LibSymbols.Symbols[i]
+		}
+	}
+	return nil
+}
+
+// SchLibSymbols is the cache of library symbols within a schematic.
+type SchLibSymbols struct {
+	Symbols []Symbol `kicad:"symbol,multi,flat"`
+}
+
+// Junction is a dot joining wires or buses that cross. A Diameter of zero
+// means the default size.
+type Junction struct {
+	At       Position `kicad:"at,flat,always"`
+	Diameter float64  `kicad:"diameter"`
+	Color    Color    `kicad:"color,flat"`
+	UUID     string   `kicad:"uuid"`
+}
+
+// NoConnect is a marker showing that a pin is intentionally unconnected.
+type NoConnect struct {
+	At   Position `kicad:"at,flat,always"`
+	UUID string   `kicad:"uuid"`
+}
+
+// BusEntry is a diagonal line joining a wire to a bus, from At to the
+// position offset from it by Size.
+type BusEntry struct {
+	At     Position `kicad:"at,flat,always"`
+	Size   Size     `kicad:"size,flat,always"`
+	Stroke Stroke   `kicad:"stroke,flat"`
+	UUID   string   `kicad:"uuid"`
+}
+
+// Wire is a wire or bus segment. It always has exactly two points.
+type Wire struct {
+	Points Points `kicad:"pts,flat"`
+	Stroke Stroke `kicad:"stroke,flat"`
+	UUID   string `kicad:"uuid"`
+}
+
+// Label is a net label, global label or hierarchical label.
+//
+// Shape is set only for global and hierarchical labels, and is one of
+// "input", "output", "bidirectional", "tri_state" or "passive". Global
+// labels may have properties, such as the list of sheets that the label
+// also appears on.
+type Label struct {
+	Text             string        `kicad:""`
+	Shape            string        `kicad:"shape"`
+	At               PositionAngle `kicad:"at,flat,always"`
+	FieldsAutoplaced bool          `kicad:"fields_autoplaced,empty"`
+	Effects          TextEffects   `kicad:"effects,flat"`
+	UUID             string        `kicad:"uuid"`
+	Properties       []SchProperty `kicad:"property,multi,flat"`
+}
+
+// SchSymbol is an instance of a symbol placed on a schematic sheet.
+//
+// LibID identifies the symbol in the symbol libraries. LibName is set only
+// when the cached library symbol differs from the library's current
+// version, in which case it gives the name of the cached copy in the
+// schematic's LibSymbols instead.
+//
+// Mirror is "x" or "y" if the symbol is mirrored about that axis. Unit and
+// BodyStyle select the unit and body style of the library symbol to draw.
+type SchSymbol struct {
+	LibName   string        `kicad:"lib_name"`
+	LibID     string        `kicad:"lib_id"`
+	At        PositionAngle `kicad:"at,flat,always"`
+	Mirror    string        `kicad:"mirror"`
+	Unit      int           `kicad:"unit"`
+	BodyStyle int           `kicad:"convert|body_style"`
+
+	ExcludeFromSim bool  `kicad:"exclude_from_sim"`
+	InBOM          *bool `kicad:"in_bom"`
+	OnBoard        *bool `kicad:"on_board"`
+	DNP            bool  `kicad:"dnp"`
+
+	FieldsAutoplaced bool           `kicad:"fields_autoplaced,empty"`
+	UUID             string         `kicad:"uuid"`
+	Properties       []SchProperty  `kicad:"property,multi,flat"`
+	Pins             []SchSymbolPin `kicad:"pin,multi,flat"`
+	Instances        SchInstances   `kicad:"instances,flat"`
+}
+
+// IncludeInBOM returns true if the symbol should be included in a bill of
+// materials.
+func (s *SchSymbol) IncludeInBOM() bool {
+	return s.InBOM == nil || *s.InBOM
+}
+
+// IncludeOnBoard returns true if the symbol should be exported to the board
+// when the schematic is updated.
+func (s *SchSymbol) IncludeOnBoard() bool {
+	return s.OnBoard == nil || *s.OnBoard
+}
+
+// Property returns the value of the property with the given name, or the
+// empty string if there is no such property.
+func (s *SchSymbol) Property(name string) string {
+	for _, prop := range s.Properties {
+		if prop.Name == name {
+			return prop.Value
+		}
+	}
+	return ""
+}
+
+// SchSymbolPin records the UUID of a pin of a symbol instance, which nets
+// and netlists use to refer to the pin. Alternate is the name of the
+// selected alternate function of the pin, if any.
+type SchSymbolPin struct {
+	Number    string `kicad:""`
+	Alternate string `kicad:"alternate"`
+	UUID      string `kicad:"uuid"`
+}
+
+// Sheet is a hierarchical sheet placed on a schematic sheet, which refers
+// to the file containing the sub-sheet's content.
+type Sheet struct {
+	At               Position `kicad:"at,flat,always"`
+	Size             Size     `kicad:"size,flat,always"`
+	ExcludeFromSim   bool     `kicad:"exclude_from_sim"`
+	InBOM            *bool    `kicad:"in_bom"`
+	OnBoard          *bool    `kicad:"on_board"`
+	DNP              bool     `kicad:"dnp"`
+	FieldsAutoplaced bool     `kicad:"fields_autoplaced,empty"`
+	Stroke           Stroke   `kicad:"stroke,flat"`
+	Fill             SchFill  `kicad:"fill,flat"`
+	UUID             string   `kicad:"uuid"`
+
+	Properties []SchProperty `kicad:"property,multi,flat"`
+	Pins       []SheetPin    `kicad:"pin,multi,flat"`
+	Instances  SchInstances  `kicad:"instances,flat"`
+}
+
+// Name returns the name of the sheet.
+func (s *Sheet) Name() string {
+	return s.property("Sheetname", "Sheet name")
+}
+
+// File returns the filename of the sheet's content, which is relative to
+// the directory of the file containing the sheet unless it is absolute.
+func (s *Sheet) File() string {
+	return s.property("Sheetfile", "Sheet file")
+}
+
+func (s *Sheet) property(name, legacyName string) string {
+	// KiCad 6 documents use names with spaces for these properties.
+	for _, prop := range s.Properties {
+		if prop.Name == name || prop.Name == legacyName {
+			return prop.Value
+		}
+	}
+	return ""
+}
+
+// SheetPin is a connection point on a hierarchical sheet, which connects
+// to the hierarchical label of the same name within the sheet. Type is one
+// of the label shapes described for Label.
+type SheetPin struct {
+	Name    string        `kicad:""`
+	Type    string        `kicad:""`
+	At      PositionAngle `kicad:"at,flat,always"`
+	Effects TextEffects   `kicad:"effects,flat"`
+	UUID    string        `kicad:"uuid"`
+}
+
+// SchInstances gives the instance data of a symbol or sheet for each
+// project that uses the sheet containing it.
+type SchInstances struct {
+	Projects []SchProject `kicad:"project,multi,flat"`
+}
+
+// SchProject gives the instance data of a symbol or sheet within a
+// particular project.
+type SchProject struct {
+	Name  string            `kicad:""`
+	Paths []SchInstancePath `kicad:"path,multi,flat"`
+}
+
+// SchInstancePaths is a list of instance data for sheets or symbols.
+type SchInstancePaths struct {
+	Paths []SchInstancePath `kicad:"path,multi,flat"`
+}
+
+// SchInstancePath gives the data for one instance of a symbol or sheet,
+// identified by a path of sheet UUIDs.
+//
+// Page is set only for sheets, and the other fields only for symbols. Value
+// and Footprint appear only in the symbol_instances of KiCad 6 documents.
+type SchInstancePath struct {
+	Path      string `kicad:""`
+	Reference string `kicad:"reference"`
+	Unit      int    `kicad:"unit"`
+	Value     string `kicad:"value"`
+	Footprint string `kicad:"footprint"`
+	Page      string `kicad:"page"`
+}
@@ -0,0 +1,203 @@
+package kicad
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// SchematicHierarchy is a schematic made up of a root sheet and all of the
+// sheets below it.
+//
+// A sheet file may be used by several sheets in the hierarchy, and so each
+// file is loaded only once and shared between its instances. Reference
+// designators and page numbers belong to each instance of a sheet rather
+// than to its file, so use SymbolReference to find the reference of a
+// symbol in a particular sheet instance.
+type SchematicHierarchy struct {
+	Root *Schematic
+
+	// Files maps the cleaned path of each loaded sheet file to its content.
+	Files map[string]*Schematic
+
+	// Sheets lists every sheet instance in the hierarchy, in depth-first
+	// order starting with the root sheet.
+	Sheets []*HierarchySheet
+
+	read func(filename string) (*Schematic, error)
+}
+
+// HierarchySheet is an instance of a sheet within a SchematicHierarchy.
+//
+// Path is the sequence of sheet UUIDs leading to the sheet from the root,
+// such as "/6f2c.../a91e...", or "/" for the root sheet itself. Sheet is the
+// sheet item in the parent that created this instance, and is nil for the
+// root sheet.
+type HierarchySheet struct {
+	Path      string
+	Name      string
+	File      string
+	Page      string
+	Parent    *HierarchySheet
+	Sheet     *Sheet
+	Schematic *Schematic
+}
+
+// LoadSchematicHierarchy reads the root sheet in the given file and then
+// all of the sheets below it, reading each distinct sheet file only once.
+func LoadSchematicHierarchy(filename string) (*SchematicHierarchy, error) {
+	return LoadSchematicHierarchyFunc(filename, ReadSchematicFile)
+}
+
+// LoadSchematicHierarchyFunc is like LoadSchematicHierarchy but reads each
+// sheet file using the given function, which allows loading schematics
+// stored in other formats.
+func LoadSchematicHierarchyFunc(filename string, read func(filename string) (*Schematic, error)) (*SchematicHierarchy, error) {
+	filename = filepath.Clean(filename)
+	root, err := read(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	h := &SchematicHierarchy{
+		Root:  root,
+		Files: map[string]*Schematic{filename: root},
+		read:  read,
+	}
+	rootSheet := &HierarchySheet{
+		Path:      "/",
+		File:      filename,
+		Page:      "1",
+		Schematic: root,
+	}
+	for _, inst := range root.SheetInstances.Paths {
+		if inst.Path == "/" && inst.Page != "" {
+			rootSheet.Page = inst.Page
+		}
+	}
+
+	err = h.load(rootSheet)
+	if err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+func (h *SchematicHierarchy) load(hs *HierarchySheet) error {
+	h.Sheets = append(h.Sheets, hs)
+
+	dir := filepath.Dir(hs.File)
+	for i := range hs.Schematic.Sheets {
+		sheet := &hs.Schematic.Sheets[i]
+
+		// Files saved on Windows may use backslashes as separators.
+		name := filepath.FromSlash(strings.ReplaceAll(sheet.File(), `\`, "/"))
+		if name == "" {
+			return fmt.Errorf("sheet %q in %s has no file", sheet.Name(), hs.File)
+		}
+		if !filepath.IsAbs(name) {
+			name = filepath.Join(dir, name)
+		}
+		name = filepath.Clean(name)
+
+		for anc := hs; anc != nil; anc = anc.Parent {
+			if anc.File == name {
+				return fmt.Errorf("sheet %q in %s recursively includes %s", sheet.Name(), hs.File, name)
+			}
+		}
+
+		sch, ok := h.Files[name]
+		if !ok {
+			var err error
+			sch, err = h.read(name)
+			if err != nil {
+				return fmt.Errorf("sheet %q in %s: %w", sheet.Name(), hs.File, err)
+			}
+			h.Files[name] = sch
+		}
+
+		child := &HierarchySheet{
+			Path:      strings.TrimSuffix(hs.Path, "/") + "/" + sheet.UUID,
+			Name:      sheet.Name(),
+			File:      name,
+			Parent:    hs,
+			Sheet:     sheet,
+			Schematic: sch,
+		}
+		child.Page = h.sheetPage(child)
+
+		if err := h.load(child); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sheetPage finds the page number of the given sheet instance, in either
+// the instances of the sheet itself or the sheet_instances of the root.
+func (h *SchematicHierarchy) sheetPage(hs *HierarchySheet) string {
+	want := h.instancePath(hs.Parent)
+	for _, proj := range hs.Sheet.Instances.Projects {
+		for _, inst := range proj.Paths {
+			if inst.Path == want {
+				return inst.Page
+			}
+		}
+	}
+	for _, inst := range h.Root.SheetInstances.Paths {
+		if legacyInstancePath(inst.Path) == hs.Path {
+			return inst.Page
+		}
+	}
+	return ""
+}
+
+// SymbolReference returns the reference designator and unit number of the
+// given symbol, which must belong to the given sheet instance.
+//
+// If the document doesn't record instance data for the symbol then the
+// result is its Reference property and Unit.
+func (h *SchematicHierarchy) SymbolReference(hs *HierarchySheet, sym *SchSymbol) (ref string, unit int) {
+	want := h.instancePath(hs)
+	for _, proj := range sym.Instances.Projects {
+		for _, inst := range proj.Paths {
+			if inst.Path == want {
+				return inst.Reference, inst.Unit
+			}
+		}
+	}
+
+	// KiCad 6 records symbol instances in the root sheet, with paths
+	// ending in the symbol's own UUID. Schematics converted from KiCad 5
+	// documents instead record them in the sheet containing the symbol.
+	legacy := strings.TrimSuffix(hs.Path, "/") + "/" + sym.UUID
+	for _, sch := range []*Schematic{h.Root, hs.Schematic} {
+		for _, inst := range sch.SymbolInstances.Paths {
+			if legacyInstancePath(inst.Path) == legacy {
+				return inst.Reference, inst.Unit
+			}
+		}
+	}
+
+	return sym.Property("Reference"), sym.Unit
+}
+
+// instancePath returns the path that KiCad 7 and later use to identify the
+// given sheet instance in the instances of its items, which begins with the
+// UUID of the root sheet.
+func (h *SchematicHierarchy) instancePath(hs *HierarchySheet) string {
+	if hs.Path == "/" {
+		return "/" + h.Root.UUID
+	}
+	return "/" + h.Root.UUID + hs.Path
+}
+
+// legacyInstancePath normalizes a KiCad 6 instance path, which may have a
+// trailing slash, to the form used by HierarchySheet.Path.
+func legacyInstancePath(path string) string {
+	path = strings.TrimSuffix(path, "/")
+	if path == "" {
+		return "/"
+	}
+	return path
+}
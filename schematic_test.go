@@ -0,0 +1,241 @@
+package kicad
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadSchematic(t *testing.T) {
+	src := `(kicad_sch (version 20231120) (generator "eeschema") (generator_version "8.0")
+	(uuid "0b8c3a4e-0000-4000-8000-000000000001")
+	(paper "A4")
+	(lib_symbols
+		(symbol "Device:R" (pin_numbers hide) (in_bom yes) (on_board yes)
+			(property "Reference" "R" (at 2.032 0 90) (effects (font (size 1.27 1.27))))
+			(symbol "R_1_1"
+				(pin passive line (at 0 3.81 270) (length 1.27)
+					(name "~" (effects (font (size 1.27 1.27))))
+					(number "1" (effects (font (size 1.27 1.27))))
+				)
+			)
+		)
+	)
+	(junction (at 50.8 50.8) (diameter 0) (color 0 0 0 0) (uuid "j1"))
+	(no_connect (at 60.96 50.8) (uuid "nc1"))
+	(bus_entry (at 40.64 45.72) (size 2.54 2.54) (stroke (width 0) (type default)) (uuid "be1"))
+	(wire (pts (xy 45.72 50.8) (xy 50.8 50.8)) (stroke (width 0) (type default)) (uuid "w1"))
+	(bus (pts (xy 40.64 30) (xy 40.64 60)) (stroke (width 0) (type default)) (uuid "b1"))
+	(polyline (pts (xy 10 10) (xy 20 10)) (stroke (width 0) (type dash)) (uuid "p1"))
+	(text "Note" (exclude_from_sim no) (at 10 20 0) (effects (font (size 1.27 1.27)) (justify left bottom)) (uuid "t1"))
+	(label "SDA" (at 45.72 50.8 0) (fields_autoplaced yes) (effects (font (size 1.27 1.27)) (justify left bottom)) (uuid "l1"))
+	(global_label "VBUS" (shape input) (at 30 30 180) (fields_autoplaced yes)
+		(effects (font (size 1.27 1.27)) (justify right))
+		(uuid "g1")
+		(property "Intersheetrefs" "${INTERSHEET_REFS}" (at 0 0 0) (effects (font (size 1.27 1.27)) hide))
+	)
+	(hierarchical_label "SCL" (shape bidirectional) (at 70 50.8 0) (effects (font (size 1.27 1.27)) (justify left)) (uuid "h1"))
+	(symbol (lib_id "Device:R") (at 50.8 40.64 90) (mirror x) (unit 1)
+		(exclude_from_sim no) (in_bom yes) (on_board yes) (dnp no)
+		(uuid "s1")
+		(property "Reference" "R1" (at 50.8 35 90) (effects (font (size 1.27 1.27))))
+		(property "Value" "10k" (at 50.8 38 90) (effects (font (size 1.27 1.27))))
+		(pin "1" (uuid "p1uuid"))
+		(instances
+			(project "test"
+				(path "/0b8c3a4e-0000-4000-8000-000000000001" (reference "R1") (unit 1))
+			)
+		)
+	)
+	(sheet (at 100 50) (size 20 10) (fields_autoplaced yes)
+		(stroke (width 0.1524) (type solid))
+		(fill (color 0 0 0 0.0000))
+		(uuid "sheet1")
+		(property "Sheetname" "Power" (at 100 49 0) (effects (font (size 1.27 1.27)) (justify left bottom)))
+		(property "Sheetfile" "power.kicad_sch" (at 100 61 0) (effects (font (size 1.27 1.27)) (justify left top)))
+		(pin "VBUS" input (at 100 55 180) (effects (font (size 1.27 1.27)) (justify left)) (uuid "sp1"))
+		(instances (project "test" (path "/0b8c3a4e-0000-4000-8000-000000000001" (page "2"))))
+	)
+	(sheet_instances (path "/" (page "1")))
+)
+`
+	sch, err := ReadSchematic(strings.NewReader(src))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	counts := map[string][2]int{
+		"junctions":   {len(sch.Junctions), 1},
+		"no_connects": {len(sch.NoConnects), 1},
+		"bus_entries": {len(sch.BusEntries), 1},
+		"wires":       {len(sch.Wires), 1},
+		"buses":       {len(sch.Buses), 1},
+		"polylines":   {len(sch.Polylines), 1},
+		"texts":       {len(sch.Texts), 1},
+		"labels":      {len(sch.Labels), 1},
+		"global":      {len(sch.GlobalLabels), 1},
+		"hier":        {len(sch.HierLabels), 1},
+		"symbols":     {len(sch.Symbols), 1},
+		"sheets":      {len(sch.Sheets), 1},
+	}
+	for name, c := range counts {
+		if c[0] != c[1] {
+			t.Errorf("wrong number of %s %d; want %d", name, c[0], c[1])
+		}
+	}
+
+	sym := &sch.Symbols[0]
+	if got, want := sym.Property("Value"), "10k"; got != want {
+		t.Errorf("wrong symbol value %q; want %q", got, want)
+	}
+	if got, want := sym.Pins, []SchSymbolPin{{Number: "1", UUID: "p1uuid"}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong symbol pins %#v; want %#v", got, want)
+	}
+	if lib := sch.LibSymbol(sym); lib == nil || lib.Name != "Device:R" {
+		t.Errorf("wrong library symbol %#v", lib)
+	}
+	if got, want := sch.GlobalLabels[0].Shape, "input"; got != want {
+		t.Errorf("wrong global label shape %q; want %q", got, want)
+	}
+	if !sch.Labels[0].FieldsAutoplaced {
+		t.Errorf("label fields should be autoplaced")
+	}
+
+	sheet := &sch.Sheets[0]
+	if got, want := sheet.Name(), "Power"; got != want {
+		t.Errorf("wrong sheet name %q; want %q", got, want)
+	}
+	if got, want := sheet.File(), "power.kicad_sch"; got != want {
+		t.Errorf("wrong sheet file %q; want %q", got, want)
+	}
+	if got, want := sheet.Pins[0].Type, "input"; got != want {
+		t.Errorf("wrong sheet pin type %q; want %q", got, want)
+	}
+}
+
+func TestLoadSchematicHierarchy(t *testing.T) {
+	dir := t.TempDir()
+	writeFile := func(name, content string) {
+		t.Helper()
+		err := os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0777)
+		if err == nil {
+			err = os.WriteFile(filepath.Join(dir, name), []byte(content), 0666)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A KiCad 8 root sheet that uses the same channel sheet twice.
+	writeFile("root.kicad_sch", `(kicad_sch (version 20231120) (uuid "root")
+	(sheet (at 0 0) (size 10 10) (uuid "a")
+		(property "Sheetname" "Left" (at 0 0 0))
+		(property "Sheetfile" "sub/channel.kicad_sch" (at 0 0 0))
+		(instances (project "p" (path "/root" (page "2"))))
+	)
+	(sheet (at 20 0) (size 10 10) (uuid "b")
+		(property "Sheetname" "Right" (at 0 0 0))
+		(property "Sheetfile" "sub\\channel.kicad_sch" (at 0 0 0))
+		(instances (project "p" (path "/root" (page "3"))))
+	)
+)
+`)
+	writeFile("sub/channel.kicad_sch", `(kicad_sch (version 20231120) (uuid "chan")
+	(symbol (lib_id "Device:R") (at 0 0 0) (unit 1) (uuid "r")
+		(property "Reference" "R?" (at 0 0 0))
+		(instances (project "p"
+			(path "/root/a" (reference "R1") (unit 1))
+			(path "/root/b" (reference "R2") (unit 1))
+		))
+	)
+)
+`)
+
+	h, err := LoadSchematicHierarchy(filepath.Join(dir, "root.kicad_sch"))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got, want := len(h.Files), 2; got != want {
+		t.Errorf("wrong number of files %d; want %d", got, want)
+	}
+
+	var got []string
+	for _, hs := range h.Sheets {
+		var ref string
+		if len(hs.Schematic.Symbols) > 0 {
+			ref, _ = h.SymbolReference(hs, &hs.Schematic.Symbols[0])
+		}
+		got = append(got, hs.Path+" "+hs.Name+" "+hs.Page+" "+ref)
+	}
+	want := []string{"/  1 ", "/a Left 2 R1", "/b Right 3 R2"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong sheets\ngot:  %#v\nwant: %#v", got, want)
+	}
+	if h.Sheets[1].Schematic != h.Sheets[2].Schematic {
+		t.Errorf("sheet instances of the same file should share a schematic")
+	}
+}
+
+func TestLoadSchematicHierarchy_kicad6(t *testing.T) {
+	dir := t.TempDir()
+	root := `(kicad_sch (version 20211123) (uuid "root")
+	(symbol (lib_id "Device:R") (at 0 0 0) (unit 1) (uuid "r0")
+		(property "Reference" "R?" (id 0) (at 0 0 0))
+	)
+	(sheet (at 0 0) (size 10 10) (uuid "a")
+		(property "Sheet name" "Sub" (id 0) (at 0 0 0))
+		(property "Sheet file" "sub.kicad_sch" (id 1) (at 0 0 0))
+	)
+	(sheet_instances (path "/" (page "1")) (path "/a/" (page "5")))
+	(symbol_instances
+		(path "/r0" (reference "R10") (unit 1) (value "1k") (footprint ""))
+		(path "/a/r1" (reference "R11") (unit 2) (value "1k") (footprint ""))
+	)
+)
+`
+	sub := `(kicad_sch (version 20211123) (uuid "sub")
+	(symbol (lib_id "Device:R") (at 0 0 0) (unit 1) (uuid "r1")
+		(property "Reference" "R?" (id 0) (at 0 0 0))
+	)
+)
+`
+	os.WriteFile(filepath.Join(dir, "root.kicad_sch"), []byte(root), 0666)
+	os.WriteFile(filepath.Join(dir, "sub.kicad_sch"), []byte(sub), 0666)
+
+	h, err := LoadSchematicHierarchy(filepath.Join(dir, "root.kicad_sch"))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got, want := len(h.Sheets), 2; got != want {
+		t.Fatalf("wrong number of sheets %d; want %d", got, want)
+	}
+	if got, want := h.Sheets[1].Page, "5"; got != want {
+		t.Errorf("wrong page %q; want %q", got, want)
+	}
+	ref, unit := h.SymbolReference(h.Sheets[0], &h.Root.Symbols[0])
+	if ref != "R10" || unit != 1 {
+		t.Errorf("wrong root symbol reference %q unit %d", ref, unit)
+	}
+	ref, unit = h.SymbolReference(h.Sheets[1], &h.Sheets[1].Schematic.Symbols[0])
+	if ref != "R11" || unit != 2 {
+		t.Errorf("wrong sub-sheet symbol reference %q unit %d", ref, unit)
+	}
+}
+
+func TestLoadSchematicHierarchy_recursive(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "loop.kicad_sch"), []byte(`(kicad_sch (version 20231120) (uuid "root")
+	(sheet (at 0 0) (size 10 10) (uuid "a")
+		(property "Sheetname" "Self" (at 0 0 0))
+		(property "Sheetfile" "loop.kicad_sch" (at 0 0 0))
+	)
+)
+`), 0666)
+
+	_, err := LoadSchematicHierarchy(filepath.Join(dir, "loop.kicad_sch"))
+	if err == nil {
+		t.Fatalf("loading recursive hierarchy should fail")
+	}
+}
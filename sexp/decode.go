@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"io"
 	"reflect"
+	"slices"
 	"strconv"
 	"strings"
 )
@@ -16,40 +17,76 @@ import (
 // gives the file type and whose remaining values are key/value tuples. this
 // function is the main way to parse such a file into a struct.
 func Decode(r io.Reader, typeName string, t interface{}) error {
+	_, err := DecodeAny(r, []string{typeName}, t)
+	return err
+}
+
+// DecodeAny is like Decode but accepts any of the given file type keywords,
+// returning the one that was found. This is useful for formats whose file
+// type keyword has changed between kicad versions.
+func DecodeAny(r io.Reader, typeNames []string, t interface{}) (string, error) {
 	v := reflect.ValueOf(t)
 
 	if v.Kind() != reflect.Ptr || v.IsNil() {
-		return &InvalidDecodeError{v.Type()}
+		return "", &InvalidDecodeError{v.Type()}
 	}
 
 	v = decodeIndirect(v)
 
 	if v.Type().Kind() != reflect.Struct {
-		return fmt.Errorf("Decode target must be pointer to struct, not %s", v.Type())
+		return "", fmt.Errorf("Decode target must be pointer to struct, not %s", v.Type())
 	}
 
 	s := NewScanner(r)
 
 	open := s.Read()
 	if open.Type != LEFT {
-		return fmt.Errorf("must start with LEFT; got %s", open.Type)
+		return "", fmt.Errorf("must start with LEFT; got %s", open.Type)
 	}
 
 	typeTok := s.Read()
 	if typeTok.Type != RAW_STRING {
-		return fmt.Errorf("first element must be RAW_STRING; got %s", typeTok.Type)
+		return "", fmt.Errorf("first element must be RAW_STRING; got %s", typeTok.Type)
 	}
 
-	if typeTok.Data != typeName {
-		return fmt.Errorf("want filetype %q but got %q", typeName, typeTok.Data)
+	typeName := ""
+	for _, name := range typeNames {
+		if typeTok.Data == name {
+			typeName = name
+			break
+		}
+	}
+	if typeName == "" {
+		return "", fmt.Errorf("want filetype %q but got %q", strings.Join(typeNames, `" or "`), typeTok.Data)
 	}
 
 	err := decodeSequenceIntoStruct(s, v, RIGHT)
 	if err != nil {
-		return err
+		return typeName, err
 	}
 	s.Read() // consume closing paren
-	return nil
+	return typeName, nil
+}
+
+// DecodeSequence populates a struct passed in t (which must be a pointer to
+// a struct) from a file that is a sequence of tuples with no top-level
+// tuple around them, as with kicad's custom design rules files. The tuples
+// correspond to the named fields of the struct.
+func DecodeSequence(r io.Reader, t interface{}) error {
+	v := reflect.ValueOf(t)
+
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return &InvalidDecodeError{v.Type()}
+	}
+
+	v = decodeIndirect(v)
+
+	if v.Type().Kind() != reflect.Struct {
+		return fmt.Errorf("DecodeSequence target must be pointer to struct, not %s", v.Type())
+	}
+
+	s := NewScanner(r)
+	return decodeSequenceIntoStruct(s, v, EOF)
 }
 
 // DecodeSimple writes a single value based on a sequence read from the given
@@ -152,7 +189,16 @@ func decodeInt(s *Scanner, v reflect.Value) error {
 	next := s.Peek()
 
 	switch next.Type {
-	case RAW_STRING:
+	case RAW_STRING, QUOTE_STRING:
+		// Some kicad formats, such as netlists, quote their numbers
+		if next.Type == QUOTE_STRING {
+			data, err := unquoteString(next.Data)
+			if err != nil {
+				return err
+			}
+			next.Data = data
+		}
+
 		switch v.Kind() {
 		// TODO: kicad additionally supports exponents
 		case reflect.Int:
@@ -195,7 +241,15 @@ func decodeFloat(s *Scanner, v reflect.Value) error {
 	next := s.Peek()
 
 	switch next.Type {
-	case RAW_STRING:
+	case RAW_STRING, QUOTE_STRING:
+		if next.Type == QUOTE_STRING {
+			data, err := unquoteString(next.Data)
+			if err != nil {
+				return err
+			}
+			next.Data = data
+		}
+
 		val, err := strconv.ParseFloat(next.Data, 64)
 		if err != nil {
 			return err
@@ -219,9 +273,19 @@ func decodeBool(s *Scanner, v reflect.Value) error {
 
 	switch next.Type {
 	case RAW_STRING:
-		val, err := strconv.ParseBool(next.Data)
-		if err != nil {
-			return err
+		// Newer kicad versions use yes/no rather than true/false
+		var val bool
+		switch next.Data {
+		case "yes":
+			val = true
+		case "no":
+			val = false
+		default:
+			var err error
+			val, err = strconv.ParseBool(next.Data)
+			if err != nil {
+				return err
+			}
 		}
 		v.SetBool(val)
 	default:
@@ -374,63 +438,23 @@ func decodeStruct(s *Scanner, v reflect.Value) error {
 }
 
 func decodeSequenceIntoStruct(s *Scanner, v reflect.Value, endType TokenType) error {
-	ty := v.Type()
-	type Field struct {
-		Index int
-		Flat  bool
-		Multi bool
-	}
-
-	var posFields []*Field
-	nameFields := make(map[string]*Field)
-	for i := 0; i < ty.NumField(); i++ {
-		field := ty.Field(i)
-		tag, tagSet := field.Tag.Lookup("kicad")
-		if !tagSet {
-			continue
-		}
-
-		parts := strings.Split(tag, ",")
-		key := parts[0]
-		flags := parts[1:]
-		fieldDef := &Field{
-			Index: i,
-		}
-		for _, flag := range flags {
-			switch flag {
-			case "flat":
-				fieldDef.Flat = true
-			case "multi":
-				fieldDef.Multi = true
-			default:
-				return fmt.Errorf(
-					"line %d: invalid kicad decode flag %q on %s",
-					s.lines, flag, field.Name,
-				)
-			}
-		}
-
-		chkType := field.Type
-		if fieldDef.Multi {
-			if chkType.Kind() != reflect.Slice {
-				return fmt.Errorf("line %d: 'multi' flag used on non-slice field %s", s.lines, field.Name)
-			}
-			chkType = chkType.Elem()
-		}
-
-		if fieldDef.Flat {
-			kind := chkType.Kind()
-			if kind != reflect.Slice && kind != reflect.Struct {
-				return fmt.Errorf("line %d: 'flat' flag cannot be used on non-slice, non-struct field %s", s.lines, field.Name)
-			}
-		}
+	fields, err := structFields(v.Type())
+	if err != nil {
+		return fmt.Errorf("line %d: %w", s.lines, err)
+	}
 
-		if key == "" {
+	var posFields, trailingFields []*field
+	nameFields := make(map[string]*field)
+	for _, fieldDef := range fields {
+		if fieldDef.Trailing {
+			trailingFields = append(trailingFields, fieldDef)
+		} else if fieldDef.Positional() {
 			posFields = append(posFields, fieldDef)
 		} else {
-			nameFields[key] = fieldDef
+			for _, name := range fieldDef.Names {
+				nameFields[name] = fieldDef
+			}
 		}
-
 	}
 
 	for {
@@ -442,13 +466,43 @@ func decodeSequenceIntoStruct(s *Scanner, v reflect.Value, endType TokenType) er
 			return fmt.Errorf("line %d: unexpected EOF decoding struct value", s.lines)
 		}
 
-		var fieldDef *Field
+		// A bare keyword matching the name of a bool field is a flag that
+		// sets that field, as in (pad 1 smd rect locked ...).
+		if next.Type == RAW_STRING {
+			if fieldDef := nameFields[next.Data]; fieldDef != nil {
+				if fv := v.Field(fieldDef.Index); fv.Kind() == reflect.Bool {
+					s.Read() // consume keyword
+					fv.SetBool(true)
+					continue
+				}
+			}
+		}
+
+		// Optional positional fields can only be populated from single-token
+		// values, so once we reach a tuple any remaining ones are left unset.
+		if next.Type == LEFT {
+			for len(posFields) > 0 && posFields[0].Optional {
+				posFields = posFields[1:]
+			}
+		}
+
+		var fieldDef *field
+		var name string
 		needClose := false
 		if len(posFields) > 0 {
 			fieldDef = posFields[0]
 			posFields = posFields[1:]
+		} else if next.Type != LEFT && len(trailingFields) > 0 {
+			fieldDef = trailingFields[0]
+			trailingFields = trailingFields[1:]
 		} else {
 			if next.Type != LEFT {
+				// Bare keywords we don't recognize are skipped, in the same
+				// way as unrecognized named tuples.
+				if next.Type == RAW_STRING {
+					s.Read() // consume keyword
+					continue
+				}
 				return fmt.Errorf(
 					"line %d: named struct field must start with LEFT, but got %s (context: %+v)",
 					s.lines, next.Type, next,
@@ -465,7 +519,8 @@ func decodeSequenceIntoStruct(s *Scanner, v reflect.Value, endType TokenType) er
 			}
 			s.Read() // consume label
 
-			fieldDef = nameFields[label.Data]
+			name = label.Data
+			fieldDef = nameFields[name]
 			needClose = true
 		}
 
@@ -495,30 +550,30 @@ func decodeSequenceIntoStruct(s *Scanner, v reflect.Value, endType TokenType) er
 
 		tv = reflect.New(valType)
 
-		if fieldDef.Flat {
-			// For "Flat" we are expecting the elements of a slice or the
-			// fields of a struct to appear directly after the field name,
-			// without an additional wrapping tuple.
-			switch valType.Kind() {
-			case reflect.Struct:
-				err := decodeSequenceIntoStruct(s, tv.Elem(), RIGHT)
-				if err != nil {
-					return err
-				}
-			case reflect.Slice:
-				err := decodeSequenceIntoSlice(s, tv.Elem(), RIGHT)
-				if err != nil {
-					return err
+		if fieldDef.Choice {
+			// The value belongs in the field of the element that has
+			// the same name as the tuple.
+			elemFields, err := structFields(valType)
+			if err != nil {
+				return fmt.Errorf("line %d: %w", s.lines, err)
+			}
+			var elemDef *field
+			for _, f := range elemFields {
+				if slices.Contains(f.Names, name) {
+					elemDef = f
+					break
 				}
-			default:
-				// Should never happen due to validation above
-				panic("non-slice and non-struct flat target")
 			}
-		} else {
-			err := decodeIntoValue(s, tv)
-			if err != nil {
+			if elemDef == nil {
+				return fmt.Errorf("line %d: %s has no field for %q", s.lines, valType, name)
+			}
+			ev := reflect.New(tv.Elem().Field(elemDef.Index).Type())
+			if err := decodeNamedValue(s, elemDef, ev, needClose); err != nil {
 				return err
 			}
+			tv.Elem().Field(elemDef.Index).Set(ev.Elem())
+		} else if err := decodeNamedValue(s, fieldDef, tv, needClose); err != nil {
+			return err
 		}
 
 		if fieldDef.Multi {
@@ -541,21 +596,69 @@ func decodeSequenceIntoStruct(s *Scanner, v reflect.Value, endType TokenType) er
 		}
 	}
 
-	if len(posFields) > 0 {
-		// If there is one remaining field and it is a "flat" field then
-		// this is acceptable since it is allowed to "consume" the zero
-		// remaining values.
-		if len(posFields) != 1 || !posFields[0].Flat {
-			return fmt.Errorf(
-				"insufficient values for positional fields %#v",
-				posFields,
-			)
+	for i, fieldDef := range posFields {
+		// Remaining optional fields are just left unset. If the final
+		// remaining field is a "flat" field then this is also acceptable
+		// since it is allowed to "consume" the zero remaining values.
+		if fieldDef.Optional || (fieldDef.Flat && i == len(posFields)-1) {
+			continue
 		}
+		return fmt.Errorf(
+			"insufficient values for positional fields %#v",
+			posFields,
+		)
 	}
 
 	return nil
 }
 
+// decodeNamedValue decodes the value of the given field from the elements
+// of a tuple into tv, which must be a pointer to the field's value type.
+// needClose is set if the value is in a named tuple, which the caller
+// consumes the end of.
+func decodeNamedValue(s *Scanner, fieldDef *field, tv reflect.Value, needClose bool) error {
+	if tv.Elem().Type().Kind() == reflect.Bool && needClose && s.Peek().Type == RIGHT {
+		// A named bool field with no value, like (free), is a flag
+		// that is set just by being present.
+		tv.Elem().SetBool(true)
+	} else if needClose && s.Peek().Type == RIGHT && !fieldDef.Flat {
+		// Other named fields with no value, like (company), are left
+		// with their zero value.
+	} else if fieldDef.Flat {
+		// For "Flat" we are expecting the elements of a slice or the
+		// fields of a struct to appear directly after the field name,
+		// without an additional wrapping tuple.
+		switch tv.Elem().Type().Kind() {
+		case reflect.Ptr:
+			// Must be a pointer to a struct due to validation above,
+			// which is useful for optional tuples.
+			err := decodeSequenceIntoStruct(s, decodeIndirect(tv.Elem()), RIGHT)
+			if err != nil {
+				return err
+			}
+		case reflect.Struct:
+			err := decodeSequenceIntoStruct(s, tv.Elem(), RIGHT)
+			if err != nil {
+				return err
+			}
+		case reflect.Slice:
+			err := decodeSequenceIntoSlice(s, tv.Elem(), RIGHT)
+			if err != nil {
+				return err
+			}
+		default:
+			// Should never happen due to validation above
+			panic("non-slice and non-struct flat target")
+		}
+	} else {
+		err := decodeIntoValue(s, tv)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // decodeIndirect deals with pointer values by allocating pointers as
 // needed to reach the final value.
 func decodeIndirect(v reflect.Value) reflect.Value {
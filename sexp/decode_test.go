@@ -34,13 +34,40 @@ func TestDecode_valid(t *testing.T) {
 		Nets        []string `kicad:"add_net,multi"`
 	}
 
+	type PCBDrill struct {
+		Oval   bool      `kicad:"oval"`
+		Width  float64   `kicad:""`
+		Height float64   `kicad:",optional"`
+		Offset []float64 `kicad:"offset,flat"`
+	}
+
+	type PCBPad struct {
+		Number string   `kicad:""`
+		Locked bool     `kicad:"locked"`
+		Drill  PCBDrill `kicad:"drill,flat"`
+	}
+
+	type PCBTitle struct {
+		Title string `kicad:"title"`
+	}
+
+	type PCBField struct {
+		Name  string `kicad:"name"`
+		Value string `kicad:",trailing"`
+	}
+
 	type PCB struct {
 		Version    int           `kicad:"version"`
+		Locked     bool          `kicad:"locked"`
 		General    PCBGeneral    `kicad:"general,flat"`
 		Page       string        `kicad:"page"`
 		Nets       []PCBNet      `kicad:"net,multi,flat"`
 		Layers     []PCBLayer    `kicad:"layers,flat"`
 		NetClasses []PCBNetClass `kicad:"net_class,multi,flat"`
+		Pads       []PCBPad      `kicad:"pad,multi,flat"`
+		Footprints []string      `kicad:"footprint|module,multi"`
+		Title      *PCBTitle     `kicad:"title_block,flat"`
+		Fields     []PCBField    `kicad:"field,multi,flat"`
 	}
 
 	tests := []struct {
@@ -152,6 +179,120 @@ func TestDecode_valid(t *testing.T) {
 				},
 			},
 		},
+		{
+			Input:  `(kicad_pcb (locked yes))`,
+			FileTy: "kicad_pcb",
+			Target: &PCB{},
+			Want: &PCB{
+				Locked: true,
+			},
+		},
+		{
+			Input:  `(kicad_pcb (locked))`,
+			FileTy: "kicad_pcb",
+			Target: &PCB{},
+			Want: &PCB{
+				Locked: true,
+			},
+		},
+		{
+			Input:  `(kicad_pcb locked)`,
+			FileTy: "kicad_pcb",
+			Target: &PCB{},
+			Want: &PCB{
+				Locked: true,
+			},
+		},
+		{
+			Input:  `(kicad_pcb future_flag (page "A4"))`,
+			FileTy: "kicad_pcb",
+			Target: &PCB{},
+			Want: &PCB{
+				Page: "A4",
+			},
+		},
+		{
+			Input:  `(kicad_pcb (title_block))`,
+			FileTy: "kicad_pcb",
+			Target: &PCB{},
+			Want: &PCB{
+				Title: &PCBTitle{},
+			},
+		},
+		{
+			Input:  `(kicad_pcb (title_block (title "Board")))`,
+			FileTy: "kicad_pcb",
+			Target: &PCB{},
+			Want: &PCB{
+				Title: &PCBTitle{Title: "Board"},
+			},
+		},
+		{
+			Input:  `(kicad_pcb (field (name "A") "x") (field (name "B")))`,
+			FileTy: "kicad_pcb",
+			Target: &PCB{},
+			Want: &PCB{
+				Fields: []PCBField{{Name: "A", Value: "x"}, {Name: "B"}},
+			},
+		},
+		{
+			Input:  `(kicad_pcb (page) (version 1))`,
+			FileTy: "kicad_pcb",
+			Target: &PCB{},
+			Want: &PCB{
+				Version: 1,
+			},
+		},
+		{
+			Input:  `(kicad_pcb (version "20231120"))`,
+			FileTy: "kicad_pcb",
+			Target: &PCB{},
+			Want: &PCB{
+				Version: 20231120,
+			},
+		},
+		{
+			Input:  `(kicad_pcb (module A) (footprint "B"))`,
+			FileTy: "kicad_pcb",
+			Target: &PCB{},
+			Want: &PCB{
+				Footprints: []string{"A", "B"},
+			},
+		},
+		{
+			Input:  `(kicad_pcb (pad 1 locked (drill 0.8)))`,
+			FileTy: "kicad_pcb",
+			Target: &PCB{},
+			Want: &PCB{
+				Pads: []PCBPad{
+					{
+						Number: "1",
+						Locked: true,
+						Drill: PCBDrill{
+							Width: 0.8,
+						},
+					},
+				},
+			},
+		},
+		{
+			Input:  `(kicad_pcb (pad 2 (drill oval 1 2 (offset 0 0.5))))`,
+			FileTy: "kicad_pcb",
+			Target: &PCB{},
+			Want: &PCB{
+				Pads: []PCBPad{
+					{
+						Number: "2",
+						Drill: PCBDrill{
+							Oval:   true,
+							Width:  1,
+							Height: 2,
+							Offset: []float64{0, 0.5},
+						},
+					},
+				},
+			},
+		},
 	}
 
 	for _, test := range tests {
@@ -175,6 +316,53 @@ func TestDecode_valid(t *testing.T) {
 	}
 }
 
+func TestDecodeSequence(t *testing.T) {
+	type Constraint struct {
+		Type string   `kicad:""`
+		Args []string `kicad:",flat,optional"`
+		Min  string   `kicad:"min"`
+	}
+	type Rule struct {
+		Name        string       `kicad:""`
+		Constraints []Constraint `kicad:"constraint,multi,flat"`
+		Condition   string       `kicad:"condition"`
+	}
+	type Rules struct {
+		Version int    `kicad:"version"`
+		Rules   []Rule `kicad:"rule,multi,flat"`
+	}
+
+	input := `(version 1)
+# comment
+(rule "HV"
+	(constraint clearance (min 1.5mm))
+	(condition "A.NetClass == 'HV'"))
+(rule keepout (constraint disallow track via))
+`
+	got := &Rules{}
+	err := DecodeSequence(strings.NewReader(input), got)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	want := &Rules{
+		Version: 1,
+		Rules: []Rule{
+			{
+				Name:        "HV",
+				Constraints: []Constraint{{Type: "clearance", Min: "1.5mm"}},
+				Condition:   "A.NetClass == 'HV'",
+			},
+			{
+				Name:        "keepout",
+				Constraints: []Constraint{{Type: "disallow", Args: []string{"track", "via"}}},
+			},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("incorrect result\ngot:  %swant: %s", spew.Sdump(got), spew.Sdump(want))
+	}
+}
+
 func TestDecodeSimple_valid(t *testing.T) {
 	sptr := func(s string) *string {
 		return &s
@@ -266,6 +454,16 @@ func TestDecodeSimple_valid(t *testing.T) {
 			Target: iptr(0),
 			Want:   iptr(-500),
 		},
+		{
+			Input:  `"500"`,
+			Target: iptr(0),
+			Want:   iptr(500),
+		},
+		{
+			Input:  `"1.5"`,
+			Target: fptr(0),
+			Want:   fptr(1.5),
+		},
 		{
 			Input:  `500`,
 			Target: uiptr(0),
@@ -296,6 +494,16 @@ func TestDecodeSimple_valid(t *testing.T) {
 			Target: bptr(true),
 			Want:   bptr(false),
 		},
+		{
+			Input:  `yes`,
+			Target: bptr(false),
+			Want:   bptr(true),
+		},
+		{
+			Input:  `no`,
+			Target: bptr(true),
+			Want:   bptr(false),
+		},
 		{
 			Input:  `1.2`,
 			Target: fptr(0.0),
@@ -0,0 +1,395 @@
+package sexp
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Encode writes the struct given in t (which must be a struct or a pointer
+// to one) as a kicad file whose top-level tuple has the given file type
+// keyword. It is the opposite of Decode, using the same struct tags.
+//
+// Named fields that have the zero value for their type are omitted unless
+// tagged with the "always" flag, since kicad treats most absent values as
+// defaults. Trailing optional positional fields are omitted in the same way.
+// Fields are written in the order they are declared.
+//
+// Strings are written as raw strings only if they look like kicad keywords,
+// and quoted otherwise. Numbers are rounded to six decimal places, which is
+// kicad's own nanometer resolution for values in millimeters.
+func Encode(w io.Writer, typeName string, t interface{}) error {
+	v := reflect.ValueOf(t)
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return fmt.Errorf("can't encode nil %s", v.Type())
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return fmt.Errorf("Encode source must be struct, not %s", v.Type())
+	}
+
+	sw := NewWriter(w)
+	if err := sw.BeginTuple(); err != nil {
+		return err
+	}
+	if err := sw.WriteRawString(typeName); err != nil {
+		return err
+	}
+	if err := encodeStructFields(sw, v); err != nil {
+		return err
+	}
+	if err := sw.EndTuple(); err != nil {
+		return err
+	}
+
+	// kicad always ends its files with a newline
+	_, err := w.Write([]byte{'\n'})
+	return err
+}
+
+// EncodeSequence writes the struct given in t (which must be a struct or a
+// pointer to one) as a sequence of tuples with no top-level tuple around
+// them, one per line. It is the opposite of DecodeSequence.
+//
+// Only the named fields of the struct are written, following the same
+// rules as for Encode.
+func EncodeSequence(w io.Writer, t interface{}) error {
+	v := reflect.ValueOf(t)
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return fmt.Errorf("can't encode nil %s", v.Type())
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return fmt.Errorf("EncodeSequence source must be struct, not %s", v.Type())
+	}
+
+	fields, err := structFields(v.Type())
+	if err != nil {
+		return err
+	}
+
+	// Each tuple is a separate top-level value, so needs its own Writer.
+	encodeOne := func(fieldDef *field, fv reflect.Value) error {
+		if fieldDef.Bare {
+			return fmt.Errorf("can't encode bare field %s at top level", fieldDef.Names[0])
+		}
+		if err := encodeNamedField(NewWriter(w), fieldDef, fv); err != nil {
+			return err
+		}
+		_, err := w.Write([]byte{'\n'})
+		return err
+	}
+
+	for _, fieldDef := range fields {
+		if fieldDef.Positional() {
+			continue
+		}
+		fv := v.Field(fieldDef.Index)
+
+		if fieldDef.Multi {
+			for i := 0; i < fv.Len(); i++ {
+				if err := encodeOne(fieldDef, fv.Index(i)); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if fv.IsZero() && !fieldDef.Always {
+			continue
+		}
+		if err := encodeOne(fieldDef, fv); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func encodeValue(w *Writer, v reflect.Value) error {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return fmt.Errorf("can't encode nil %s", v.Type())
+		}
+		v = v.Elem()
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		return encodeString(w, v.String())
+	case reflect.Int:
+		return w.WriteRawString(strconv.FormatInt(v.Int(), 10))
+	case reflect.Uint:
+		return w.WriteRawString(strconv.FormatUint(v.Uint(), 10))
+	case reflect.Bool:
+		if v.Bool() {
+			return w.WriteRawString("yes")
+		}
+		return w.WriteRawString("no")
+	case reflect.Float64:
+		return w.WriteRawString(formatFloat(v.Float()))
+	case reflect.Slice:
+		if err := w.BeginTuple(); err != nil {
+			return err
+		}
+		if err := encodeSequence(w, v); err != nil {
+			return err
+		}
+		return w.EndTuple()
+	case reflect.Map:
+		return encodeMap(w, v)
+	case reflect.Struct:
+		if err := w.BeginTuple(); err != nil {
+			return err
+		}
+		if err := encodeStructFields(w, v); err != nil {
+			return err
+		}
+		return w.EndTuple()
+	default:
+		return fmt.Errorf("kicad sexp: can't encode %s", v.Type())
+	}
+}
+
+func encodeSequence(w *Writer, v reflect.Value) error {
+	for i := 0; i < v.Len(); i++ {
+		if err := encodeValue(w, v.Index(i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// encodeRawSequence writes the elements of a slice of strings as raw
+// strings.
+func encodeRawSequence(w *Writer, v reflect.Value) error {
+	for i := 0; i < v.Len(); i++ {
+		if err := w.WriteRawString(v.Index(i).String()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func encodeMap(w *Writer, v reflect.Value) error {
+	if err := w.BeginTuple(); err != nil {
+		return err
+	}
+
+	// We sort the keys so that the output is deterministic
+	keys := v.MapKeys()
+	sort.Slice(keys, func(i, j int) bool {
+		return fmt.Sprint(keys[i].Interface()) < fmt.Sprint(keys[j].Interface())
+	})
+
+	for _, key := range keys {
+		if err := w.BeginTuple(); err != nil {
+			return err
+		}
+		if err := encodeValue(w, key); err != nil {
+			return err
+		}
+		if err := encodeValue(w, v.MapIndex(key)); err != nil {
+			return err
+		}
+		if err := w.EndTuple(); err != nil {
+			return err
+		}
+	}
+
+	return w.EndTuple()
+}
+
+func encodeStructFields(w *Writer, v reflect.Value) error {
+	fields, err := structFields(v.Type())
+	if err != nil {
+		return err
+	}
+
+	// Trailing optional positional fields can be omitted if unset
+	omit := make(map[*field]bool)
+	for i := len(fields) - 1; i >= 0; i-- {
+		fieldDef := fields[i]
+		if !fieldDef.Positional() {
+			continue
+		}
+		if !(fieldDef.Optional || fieldDef.Trailing) || !v.Field(fieldDef.Index).IsZero() {
+			break
+		}
+		omit[fieldDef] = true
+	}
+
+	// Fields are written in the order they are declared, which allows
+	// bare keyword flags to appear before positional values, as in
+	// (drill oval 1 2).
+	for _, fieldDef := range fields {
+		if omit[fieldDef] {
+			continue
+		}
+		fv := v.Field(fieldDef.Index)
+
+		if fieldDef.Positional() {
+			var err error
+			switch {
+			case fieldDef.Flat && fv.Kind() == reflect.Struct:
+				err = encodeStructFields(w, fv)
+			case fieldDef.Flat && fieldDef.Raw:
+				err = encodeRawSequence(w, fv)
+			case fieldDef.Flat:
+				err = encodeSequence(w, fv)
+			case fieldDef.Raw:
+				err = w.WriteRawString(fv.String())
+			case fieldDef.TrueFalse:
+				err = w.WriteRawString(strconv.FormatBool(fv.Bool()))
+			default:
+				err = encodeValue(w, fv)
+			}
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		if fieldDef.Choice {
+			for i := 0; i < fv.Len(); i++ {
+				if err := encodeChoice(w, fv.Index(i)); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if fieldDef.Multi {
+			for i := 0; i < fv.Len(); i++ {
+				if err := encodeNamedField(w, fieldDef, fv.Index(i)); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if fv.IsZero() && !fieldDef.Always {
+			continue
+		}
+		if err := encodeNamedField(w, fieldDef, fv); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// encodeChoice writes an element of a field with the "choice" flag, which
+// is a tuple for each of the element's fields that is set.
+func encodeChoice(w *Writer, v reflect.Value) error {
+	fields, err := structFields(v.Type())
+	if err != nil {
+		return err
+	}
+	for _, fieldDef := range fields {
+		fv := v.Field(fieldDef.Index)
+		if fv.IsZero() {
+			continue
+		}
+		if err := encodeNamedField(w, fieldDef, fv); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func encodeNamedField(w *Writer, fieldDef *field, v reflect.Value) error {
+	name := fieldDef.Names[0]
+
+	if fieldDef.Bare {
+		if !v.Bool() {
+			return nil
+		}
+		return w.WriteRawString(name)
+	}
+
+	if fieldDef.Empty {
+		if !v.Bool() {
+			return nil
+		}
+		if err := w.BeginTuple(); err != nil {
+			return err
+		}
+		if err := w.WriteRawString(name); err != nil {
+			return err
+		}
+		return w.EndTuple()
+	}
+
+	if err := w.BeginTuple(); err != nil {
+		return err
+	}
+	if err := w.WriteRawString(name); err != nil {
+		return err
+	}
+
+	if fieldDef.Flat && v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+
+	var err error
+	switch {
+	case fieldDef.Flat && v.Kind() == reflect.Struct:
+		err = encodeStructFields(w, v)
+	case fieldDef.Flat && fieldDef.Raw:
+		err = encodeRawSequence(w, v)
+	case fieldDef.Flat:
+		err = encodeSequence(w, v)
+	case fieldDef.Raw:
+		err = w.WriteRawString(v.String())
+	case fieldDef.TrueFalse:
+		err = w.WriteRawString(strconv.FormatBool(v.Bool()))
+	default:
+		err = encodeValue(w, v)
+	}
+	if err != nil {
+		return err
+	}
+
+	return w.EndTuple()
+}
+
+// encodeString writes the given string as a raw string if it looks like a
+// kicad keyword, or as a quoted string otherwise. Kicad accepts quoted
+// strings for all free-form text, but keywords must be raw.
+func encodeString(w *Writer, str string) error {
+	if isKeyword(str) {
+		return w.WriteRawString(str)
+	}
+	return w.WriteQuoteString(str)
+}
+
+func isKeyword(str string) bool {
+	if str == "" {
+		return false
+	}
+	for i := 0; i < len(str); i++ {
+		ch := str[i]
+		switch {
+		case ch >= 'a' && ch <= 'z', ch == '_':
+		case ch >= '0' && ch <= '9' && i > 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func formatFloat(f float64) string {
+	str := strconv.FormatFloat(f, 'f', 6, 64)
+	str = strings.TrimRight(str, "0")
+	str = strings.TrimSuffix(str, ".")
+	if str == "-0" {
+		str = "0"
+	}
+	return str
+}
@@ -0,0 +1,239 @@
+package sexp
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/davecgh/go-spew/spew"
+)
+
+func TestEncode(t *testing.T) {
+	type Drill struct {
+		Oval   bool      `kicad:"oval,bare"`
+		Width  float64   `kicad:""`
+		Height float64   `kicad:",optional"`
+		Offset []float64 `kicad:"offset,flat"`
+	}
+
+	type Pad struct {
+		Number string   `kicad:""`
+		Type   string   `kicad:""`
+		Locked bool     `kicad:"locked,bare"`
+		Layers []string `kicad:"layers,flat"`
+		Drill  Drill    `kicad:"drill,flat"`
+	}
+
+	type Net struct {
+		Number int    `kicad:""`
+		Name   string `kicad:""`
+	}
+
+	type Property struct {
+		Name  string `kicad:"name"`
+		Value string `kicad:",trailing"`
+	}
+
+	type Footprint struct {
+		Name    string     `kicad:""`
+		Version int        `kicad:"version"`
+		Layer   string     `kicad:"layer"`
+		Descr   string     `kicad:"descr"`
+		Power   bool       `kicad:"power,empty"`
+		InBOM   bool       `kicad:"in_bom,always"`
+		OnBoard bool       `kicad:"on_board,always"`
+		Nets    []Net      `kicad:"net,multi,flat"`
+		Pads    []Pad      `kicad:"pad,multi,flat"`
+		Props   []Property `kicad:"property,multi,flat"`
+		Width   float64    `kicad:"width"`
+		Mirror  bool       `kicad:"mirror,truefalse,always"`
+	}
+
+	fp := &Footprint{
+		Name:    "R_0603",
+		Version: 20221018,
+		Layer:   "F.Cu",
+		Power:   true,
+		OnBoard: true,
+		Nets: []Net{
+			{0, ""},
+			{1, "GND"},
+		},
+		Pads: []Pad{
+			{
+				Number: "1",
+				Type:   "thru_hole",
+				Locked: true,
+				Layers: []string{"*.Cu", "*.Mask"},
+				Drill:  Drill{Width: 0.8},
+			},
+			{
+				Number: "2",
+				Type:   "np_thru_hole",
+				Drill:  Drill{Oval: true, Width: 1, Height: 2.5, Offset: []float64{0, 0.1}},
+			},
+		},
+		Props: []Property{
+			{Name: "A", Value: "x y"},
+			{Name: "B"},
+		},
+		Width:  0.1 + 0.2,
+		Mirror: true,
+	}
+
+	var buf bytes.Buffer
+	err := Encode(&buf, "footprint", fp)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	want := `(footprint "R_0603"
+  (version 20221018)
+  (layer "F.Cu")
+  (power)
+  (in_bom no)
+  (on_board yes)
+  (net 0 "")
+  (net 1 "GND")
+  (pad "1" thru_hole locked
+    (layers "*.Cu" "*.Mask")
+    (drill 0.8))
+  (pad "2" np_thru_hole
+    (drill oval 1 2.5
+      (offset 0 0.1)))
+  (property
+    (name "A") "x y")
+  (property
+    (name "B"))
+  (width 0.3)
+  (mirror true))
+`
+	if got := buf.String(); got != want {
+		t.Errorf("incorrect result\ngot:\n%s\nwant:\n%s", got, want)
+	}
+
+	// The result should decode back into the same value, aside from
+	// the float rounding.
+	fp.Width = 0.3
+	got := &Footprint{}
+	err = Decode(&buf, "footprint", got)
+	if err != nil {
+		t.Fatalf("unexpected error decoding result: %s", err)
+	}
+	if !reflect.DeepEqual(got, fp) {
+		t.Errorf("incorrect round-trip\ngot:  %swant: %s", spew.Sdump(got), spew.Sdump(fp))
+	}
+}
+
+func TestEncode_choice(t *testing.T) {
+	type XY struct {
+		X float64 `kicad:""`
+		Y float64 `kicad:""`
+	}
+	type Arc struct {
+		Start XY `kicad:"start,flat"`
+		End   XY `kicad:"end,flat"`
+	}
+	type Point struct {
+		XY  *XY  `kicad:"xy,flat"`
+		Arc *Arc `kicad:"arc,flat"`
+	}
+	type Poly struct {
+		Points []Point `kicad:"xy|arc,multi,choice"`
+		Width  float64 `kicad:"width"`
+	}
+
+	poly := &Poly{
+		Points: []Point{
+			{XY: &XY{X: 0, Y: 0}},
+			{Arc: &Arc{Start: XY{X: 1, Y: 0}, End: XY{X: 2, Y: 1}}},
+			{XY: &XY{X: 2, Y: 2}},
+		},
+		Width: 0.1,
+	}
+	var buf bytes.Buffer
+	err := Encode(&buf, "poly", poly)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	want := `(poly
+  (xy 0 0)
+  (arc
+    (start 1 0)
+    (end 2 1))
+  (xy 2 2)
+  (width 0.1))
+`
+	if got := buf.String(); got != want {
+		t.Errorf("incorrect result\ngot:\n%s\nwant:\n%s", got, want)
+	}
+
+	// The tuples must decode back in the same order.
+	got := &Poly{}
+	err = Decode(&buf, "poly", got)
+	if err != nil {
+		t.Fatalf("unexpected error decoding result: %s", err)
+	}
+	if !reflect.DeepEqual(got, poly) {
+		t.Errorf("incorrect round-trip\ngot:  %swant: %s", spew.Sdump(got), spew.Sdump(poly))
+	}
+}
+
+func TestEncodeSequence(t *testing.T) {
+	type Constraint struct {
+		Type string   `kicad:""`
+		Args []string `kicad:",flat,optional"`
+		Min  string   `kicad:"min,raw"`
+	}
+	type Rule struct {
+		Name        string       `kicad:""`
+		Constraints []Constraint `kicad:"constraint,multi,flat"`
+		Condition   string       `kicad:"condition"`
+	}
+	type Rules struct {
+		Version int    `kicad:"version"`
+		Rules   []Rule `kicad:"rule,multi,flat"`
+	}
+
+	rules := &Rules{
+		Version: 1,
+		Rules: []Rule{
+			{
+				Name:        "HV",
+				Constraints: []Constraint{{Type: "clearance", Min: "1.5mm"}},
+				Condition:   "A.NetClass == 'HV'",
+			},
+			{
+				Name:        "keepout",
+				Constraints: []Constraint{{Type: "disallow", Args: []string{"track", "via"}}},
+			},
+		},
+	}
+
+	var buf bytes.Buffer
+	err := EncodeSequence(&buf, rules)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	want := `(version 1)
+(rule "HV"
+  (constraint clearance
+    (min 1.5mm))
+  (condition "A.NetClass == 'HV'"))
+(rule keepout
+  (constraint disallow track via))
+`
+	if got := buf.String(); got != want {
+		t.Errorf("incorrect result\ngot:\n%s\nwant:\n%s", got, want)
+	}
+
+	got := &Rules{}
+	err = DecodeSequence(&buf, got)
+	if err != nil {
+		t.Fatalf("unexpected error decoding result: %s", err)
+	}
+	if !reflect.DeepEqual(got, rules) {
+		t.Errorf("incorrect round-trip\ngot:  %swant: %s", spew.Sdump(got), spew.Sdump(rules))
+	}
+}
@@ -0,0 +1,159 @@
+package sexp
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// field describes how a struct field corresponds to the elements of a
+// tuple, as given by its "kicad" tag.
+//
+// The tag gives the name of the tuple that holds the field's value, or is
+// empty for a positional field whose value appears directly, in order,
+// before any named tuples. It may be followed by comma-separated flags:
+//
+//   - flat: the elements of a slice or the fields of a struct appear
+//     directly after the name, without an additional wrapping tuple. A
+//     named flat field may also be a pointer to a struct, which is nil if
+//     the tuple is absent.
+//   - multi: the name may appear many times, appending to a slice.
+//   - optional: a positional field that may be omitted.
+//   - trailing: a positional field whose value appears after the named
+//     tuples rather than before them, as in (field (name "x") "value"). It
+//     may be omitted.
+//   - bare: a bool field that is written as a bare keyword when set,
+//     rather than as a tuple like (name yes).
+//   - empty: a bool field that is written as an empty tuple like (name)
+//     when set.
+//   - always: a named field that is written even when it has its zero
+//     value.
+//   - truefalse: a bool field that is written as true or false rather
+//     than yes or no, for tuples that older kicad versions only accept in
+//     that form.
+//   - raw: a string field, or a flat slice of strings, that is always
+//     written as raw strings rather than quoted, for values like 0.2mm
+//     that kicad expects unquoted.
+//   - choice: a multi field whose elements are structs with one named
+//     field for each of the field's names. Each tuple appends an element
+//     with only the field of the same name set, so that the order of
+//     tuples of different names is kept, as with the xy and arc tuples
+//     of a point list.
+type field struct {
+	Index     int
+	Names     []string
+	Flat      bool
+	Multi     bool
+	Optional  bool
+	Trailing  bool
+	Bare      bool
+	Empty     bool
+	Always    bool
+	TrueFalse bool
+	Raw       bool
+	Choice    bool
+}
+
+// Positional returns true if the field is positional rather than named.
+func (f *field) Positional() bool {
+	return len(f.Names) == 0
+}
+
+// structFields returns the tagged fields of the given struct type in the
+// order they are declared.
+func structFields(ty reflect.Type) ([]*field, error) {
+	var ret []*field
+	for i := 0; i < ty.NumField(); i++ {
+		sf := ty.Field(i)
+		tag, tagSet := sf.Tag.Lookup("kicad")
+		if !tagSet {
+			continue
+		}
+
+		parts := strings.Split(tag, ",")
+		key := parts[0]
+		flags := parts[1:]
+		f := &field{
+			Index: i,
+		}
+		if key != "" {
+			// A field can accept several alternative names separated by
+			// pipes, which is useful when kicad has renamed a tuple
+			// between versions, as with "module" becoming "footprint".
+			// The first name is the one used when encoding.
+			f.Names = strings.Split(key, "|")
+		}
+		for _, flag := range flags {
+			switch flag {
+			case "flat":
+				f.Flat = true
+			case "multi":
+				f.Multi = true
+			case "optional":
+				f.Optional = true
+			case "trailing":
+				f.Trailing = true
+			case "bare":
+				f.Bare = true
+			case "empty":
+				f.Empty = true
+			case "always":
+				f.Always = true
+			case "truefalse":
+				f.TrueFalse = true
+			case "raw":
+				f.Raw = true
+			case "choice":
+				f.Choice = true
+			default:
+				return nil, fmt.Errorf("invalid kicad tag flag %q on %s", flag, sf.Name)
+			}
+		}
+
+		chkType := sf.Type
+		if f.Multi {
+			if chkType.Kind() != reflect.Slice {
+				return nil, fmt.Errorf("'multi' flag used on non-slice field %s", sf.Name)
+			}
+			chkType = chkType.Elem()
+		}
+
+		if f.Choice && (!f.Multi || f.Flat || f.Positional() || chkType.Kind() != reflect.Struct) {
+			return nil, fmt.Errorf("'choice' flag can only be used on named non-flat multi field of structs %s", sf.Name)
+		}
+
+		if f.Flat {
+			kind := chkType.Kind()
+			if kind == reflect.Ptr && !f.Positional() {
+				// Named flat fields may be pointers to structs, which
+				// are left nil when the tuple is absent.
+				kind = chkType.Elem().Kind()
+				if kind != reflect.Struct {
+					return nil, fmt.Errorf("'flat' flag cannot be used on pointer to non-struct field %s", sf.Name)
+				}
+			}
+			if kind != reflect.Slice && kind != reflect.Struct {
+				return nil, fmt.Errorf("'flat' flag cannot be used on non-slice, non-struct field %s", sf.Name)
+			}
+		}
+
+		if f.Trailing && (!f.Positional() || f.Flat) {
+			return nil, fmt.Errorf("'trailing' flag can only be used on non-flat positional field %s", sf.Name)
+		}
+
+		rawType := chkType
+		if f.Flat && rawType.Kind() == reflect.Slice {
+			rawType = rawType.Elem()
+		}
+		if f.Raw && rawType.Kind() != reflect.String {
+			return nil, fmt.Errorf("'raw' flag used on non-string field %s", sf.Name)
+		}
+
+		if (f.Bare || f.Empty || f.TrueFalse) && chkType.Kind() != reflect.Bool {
+			return nil, fmt.Errorf("'bare', 'empty' or 'truefalse' flag used on non-bool field %s", sf.Name)
+		}
+
+		ret = append(ret, f)
+	}
+	return ret, nil
+}
@@ -156,7 +156,7 @@ func (s *Scanner) scanIrrelevant(data []byte, eof bool) (int, []byte, error) {
 	}
 
 	switch data[0] {
-	case 10, 13, 32, 8, 0:
+	case 10, 13, 32, 9, 8, 0:
 		return s.scanWhitespace(data, eof)
 	case '#':
 		return s.scanComment(data, eof)
@@ -181,7 +181,7 @@ Bytes:
 		case 10:
 			s.lines++
 			size++
-		case 13, 32, 8, 0:
+		case 13, 32, 9, 8, 0:
 			size++
 		default:
 			break Bytes
@@ -266,7 +266,7 @@ Bytes:
 		b = b[1:]
 
 		switch next {
-		case 10, 13, 32, 8, 0, '(', ')', '#':
+		case 10, 13, 32, 9, 8, 0, '(', ')', '#':
 			break Bytes
 		}
 
@@ -109,6 +109,19 @@ func TestScanner(t *testing.T) {
 				{EOF, ""},
 			},
 		},
+		{
+			"(foo\n\t(bar\t\"baz\")\n)",
+			[]Token{
+				{LEFT, `(`},
+				{RAW_STRING, `foo`},
+				{LEFT, `(`},
+				{RAW_STRING, `bar`},
+				{QUOTE_STRING, `"baz"`},
+				{RIGHT, `)`},
+				{RIGHT, `)`},
+				{EOF, ""},
+			},
+		},
 	}
 
 	for _, test := range tests {
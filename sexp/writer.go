@@ -149,9 +149,12 @@ func (w *Writer) WriteQuoteString(str string) error {
 // WriteString writes the given string as a raw string if possible or as
 // a quoted string otherwise.
 func (w *Writer) WriteString(str string) error {
+	if str == "" {
+		return w.WriteQuoteString(str)
+	}
 	for i := 0; i < len(str); i++ {
 		switch str[i] {
-		case 10, 13, 32, 8, 0, '(', ')', '#':
+		case 10, 13, 32, 9, 8, 0, '(', ')', '#', '"', '\\':
 			return w.WriteQuoteString(str)
 		}
 	}
@@ -0,0 +1,216 @@
+package kicad
+
+import (
+	"math"
+	"strings"
+)
+
+// RotatePoint rotates the given position around the origin by the given
+// angle in degrees, counterclockwise as seen on screen, which is the
+// direction of the angles of footprints and pads.
+func RotatePoint(p Position, angle float64) Position {
+	if angle == 0 {
+		return p
+	}
+	sin, cos := math.Sincos(angle * math.Pi / 180)
+	return Position{
+		X: p.X*cos + p.Y*sin,
+		Y: -p.X*sin + p.Y*cos,
+	}
+}
+
+// NormalizeDegrees returns the given angle in degrees as an equivalent
+// angle in the range [0, 360).
+func NormalizeDegrees(angle float64) float64 {
+	angle = math.Mod(angle, 360)
+	if angle < 0 {
+		angle += 360
+	}
+	return angle
+}
+
+// TransformPoints returns the result of calling fn with each of the given
+// points, such as Footprint.BoardPosition to convert footprint coordinates
+// into board coordinates.
+func TransformPoints(points []Position, fn func(Position) Position) []Position {
+	ret := make([]Position, len(points))
+	for i, p := range points {
+		ret[i] = fn(p)
+	}
+	return ret
+}
+
+// RectPoints returns the corners of the axis-aligned rectangle with the
+// given opposite corners, in order around its outline.
+func RectPoints(a, b Position) []Position {
+	return []Position{a, {X: b.X, Y: a.Y}, b, {X: a.X, Y: b.Y}}
+}
+
+// ClosePolyline returns the given polyline with its first point repeated
+// at its end, so that drawing it draws a closed outline.
+func ClosePolyline(points []Position) []Position {
+	if len(points) < 2 || points[0] == points[len(points)-1] {
+		return points
+	}
+	return append(points[:len(points):len(points)], points[0])
+}
+
+// ArcSegmentAngle is the largest angle in radians covered by one of the
+// straight segments of the polylines returned by ArcPoints.
+const ArcSegmentAngle = math.Pi / 36
+
+// ArcPoints approximates the arc from start through mid to end with a
+// polyline. If the points are collinear then the result is a straight line
+// from start to end.
+func ArcPoints(start, mid, end Position) []Position {
+	center, ok := ArcCenter(start, mid, end)
+	if !ok {
+		return []Position{start, end}
+	}
+	sweep := ArcSweep(center, start, mid, end)
+	r := Distance(center, start)
+	a0 := math.Atan2(start.Y-center.Y, start.X-center.X)
+	n := int(math.Ceil(math.Abs(sweep) / ArcSegmentAngle))
+	ret := make([]Position, 0, n+1)
+	ret = append(ret, start)
+	for i := 1; i < n; i++ {
+		a := a0 + sweep*float64(i)/float64(n)
+		ret = append(ret, Position{
+			X: center.X + r*math.Cos(a),
+			Y: center.Y + r*math.Sin(a),
+		})
+	}
+	return append(ret, end)
+}
+
+// BezierSegments is the number of straight segments in the polylines
+// returned by BezierPoints.
+const BezierSegments = 16
+
+// BezierPoints approximates the cubic Bézier curve with the given four
+// control points with a polyline. Any other number of points is returned
+// unchanged.
+func BezierPoints(ctrl []Position) []Position {
+	if len(ctrl) != 4 {
+		return ctrl
+	}
+	ret := make([]Position, 0, BezierSegments+1)
+	for i := 0; i <= BezierSegments; i++ {
+		t := float64(i) / BezierSegments
+		u := 1 - t
+		a, b, c, d := u*u*u, 3*u*u*t, 3*u*t*t, t*t*t
+		ret = append(ret, Position{
+			X: a*ctrl[0].X + b*ctrl[1].X + c*ctrl[2].X + d*ctrl[3].X,
+			Y: a*ctrl[0].Y + b*ctrl[1].Y + c*ctrl[2].Y + d*ctrl[3].Y,
+		})
+	}
+	return ret
+}
+
+// LayerMatch returns true if the given layer name matches the given
+// pattern, which may be a layer name or a wildcard such as "*.Cu" or
+// "F&B.Cu" as used in pad layer lists.
+func LayerMatch(pattern, layer string) bool {
+	if side, kind, ok := strings.Cut(pattern, "."); ok {
+		switch side {
+		case "*":
+			return strings.HasSuffix(layer, "."+kind)
+		case "F&B":
+			return layer == "F."+kind || layer == "B."+kind
+		}
+	}
+	return pattern == layer
+}
+
+// OnLayer returns true if any of the given patterns matches the given
+// layer name, as LayerMatch does.
+func OnLayer(patterns []string, layer string) bool {
+	for _, pattern := range patterns {
+		if LayerMatch(pattern, layer) {
+			return true
+		}
+	}
+	return false
+}
+
+// RectCorners returns the corners of a rectangle of the given size
+// centered on the origin, in order around its outline.
+func RectCorners(w, h float64) []Position {
+	return RectPoints(Position{X: -w / 2, Y: -h / 2}, Position{X: w / 2, Y: h / 2})
+}
+
+// TrapezoidCorners returns the corners of a trapezoid pad of the given
+// size centered on the origin, whose opposite sides differ in length by
+// delta, as given by Pad.RectDelta.
+func TrapezoidCorners(w, h float64, delta Size) []Position {
+	dx, dy := delta.Width/2, delta.Height/2
+	return []Position{
+		{X: -w/2 - dy, Y: h/2 + dx},
+		{X: -w/2 + dy, Y: -h/2 - dx},
+		{X: w/2 - dy, Y: -h/2 + dx},
+		{X: w/2 + dy, Y: h/2 - dx},
+	}
+}
+
+// CornerSegments is the number of straight segments that ChamferedRect
+// uses to approximate each rounded corner.
+const CornerSegments = 8
+
+// CirclePoints approximates the circle with the given center and radius
+// with a polygon, using CornerSegments segments for each quarter of the
+// circle.
+func CirclePoints(center Position, r float64) []Position {
+	const n = 4 * CornerSegments
+	ret := make([]Position, n)
+	for i := range ret {
+		a := 2 * math.Pi * float64(i) / n
+		ret[i] = Position{X: center.X + r*math.Cos(a), Y: center.Y + r*math.Sin(a)}
+	}
+	return ret
+}
+
+// ChamferedRect returns the outline of a rectangle of the given size
+// centered on the origin, running clockwise on screen, whose corners are
+// either chamfered by c or rounded with radius r. This is the outline of
+// roundrect and chamfered rectangle pads.
+func ChamferedRect(w, h, r, c float64, chamfer PadChamfer) []Position {
+	type corner struct {
+		at       Position
+		dx, dy   float64 // direction towards the inside of the pad
+		chamfer  bool
+		startDeg float64 // angle of the start of the rounded corner
+	}
+	corners := []corner{
+		{Position{X: -w / 2, Y: -h / 2}, 1, 1, chamfer.TopLeft, 180},
+		{Position{X: w / 2, Y: -h / 2}, -1, 1, chamfer.TopRight, 270},
+		{Position{X: w / 2, Y: h / 2}, -1, -1, chamfer.BottomRight, 0},
+		{Position{X: -w / 2, Y: h / 2}, 1, -1, chamfer.BottomLeft, 90},
+	}
+	var ret []Position
+	for _, k := range corners {
+		switch {
+		case k.chamfer:
+			// The outline runs clockwise on screen, so it enters
+			// the top left and bottom right corners along a
+			// vertical edge and the others along a horizontal one.
+			a := Position{X: k.at.X, Y: k.at.Y + k.dy*c}
+			b := Position{X: k.at.X + k.dx*c, Y: k.at.Y}
+			if k.dx*k.dy < 0 {
+				a, b = b, a
+			}
+			ret = append(ret, a, b)
+		case r > 0:
+			center := Position{X: k.at.X + k.dx*r, Y: k.at.Y + k.dy*r}
+			for i := 0; i <= CornerSegments; i++ {
+				rad := (k.startDeg + 90*float64(i)/CornerSegments) * math.Pi / 180
+				ret = append(ret, Position{
+					X: center.X + r*math.Cos(rad),
+					Y: center.Y + r*math.Sin(rad),
+				})
+			}
+		default:
+			ret = append(ret, k.at)
+		}
+	}
+	return ret
+}
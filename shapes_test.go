@@ -0,0 +1,81 @@
+package kicad
+
+import (
+	"math"
+	"testing"
+)
+
+func TestLayerMatch(t *testing.T) {
+	tests := []struct {
+		pattern, layer string
+		want           bool
+	}{
+		{"F.Cu", "F.Cu", true},
+		{"F.Cu", "B.Cu", false},
+		{"*.Cu", "In1.Cu", true},
+		{"*.Cu", "F.Mask", false},
+		{"F&B.Cu", "B.Cu", true},
+		{"F&B.Cu", "In1.Cu", false},
+	}
+	for _, test := range tests {
+		if got := LayerMatch(test.pattern, test.layer); got != test.want {
+			t.Errorf("LayerMatch(%q, %q) = %t; want %t", test.pattern, test.layer, got, test.want)
+		}
+	}
+}
+
+func TestArcPoints(t *testing.T) {
+	// A half circle of radius 1, passing below the center on screen
+	start, mid, end := Position{X: -1}, Position{Y: 1}, Position{X: 1}
+	got := ArcPoints(start, mid, end)
+	if got, want := len(got), 37; got != want {
+		t.Fatalf("wrong number of points %d; want %d", got, want)
+	}
+	if got[0] != start || got[len(got)-1] != end {
+		t.Errorf("arc runs from %v to %v; want %v to %v", got[0], got[len(got)-1], start, end)
+	}
+	for _, p := range got {
+		if r := Distance(Position{}, p); math.Abs(r-1) > 1e-9 {
+			t.Errorf("point %v is at radius %g; want 1", p, r)
+		}
+		if p.Y < -1e-9 {
+			t.Errorf("point %v is above the center", p)
+		}
+	}
+
+	if got := ArcPoints(start, Position{}, end); len(got) != 2 {
+		t.Errorf("collinear arc gives %d points; want 2", len(got))
+	}
+}
+
+func TestCirclePoints(t *testing.T) {
+	center := Position{X: 10, Y: 5}
+	got := CirclePoints(center, 2)
+	if got, want := len(got), 4*CornerSegments; got != want {
+		t.Fatalf("wrong number of points %d; want %d", got, want)
+	}
+	for _, p := range got {
+		if r := Distance(center, p); math.Abs(r-2) > 1e-9 {
+			t.Errorf("point %v is at radius %g; want 2", p, r)
+		}
+	}
+}
+
+func TestChamferedRect(t *testing.T) {
+	got := ChamferedRect(4, 2, 0, 0.5, PadChamfer{TopLeft: true})
+	want := []Position{
+		{X: -2, Y: -0.5}, {X: -1.5, Y: -1},
+		{X: 2, Y: -1},
+		{X: 2, Y: 1},
+		{X: -2, Y: 1},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("wrong outline %v; want %v", got, want)
+	}
+	for i := range want {
+		if Distance(got[i], want[i]) > 1e-9 {
+			t.Errorf("wrong outline %v; want %v", got, want)
+			break
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package strokefont
+
+import (
+	"strconv"
+	"strings"
+)
+
+// glyph is a character of the font, as strokes in glyph units.
+type glyph struct {
+	strokes [][]point
+	advance float64
+}
+
+// spaceAdvance is the advance width of a space, in glyph units.
+const spaceAdvance = 3.0
+
+// glyphSources describes the strokes of each glyph as polylines separated
+// by semicolons, each a list of x,y points separated by spaces. Capitals
+// are 4 units wide and 6 high, lowercase letters are 4 high, and
+// descenders reach down to -2.
+var glyphSources = map[rune]string{
+	'!':  "0,6 0,2;0,0.5 0,0",
+	'"':  "0,6 0,4.5;1.5,6 1.5,4.5",
+	'#':  "1,0 1,6;3,0 3,6;0,2 4,2;0,4 4,4",
+	'$':  "4,5 3,6 1,6 0,5 0,4 1,3 3,3 4,2 4,1 3,0 1,0 0,1;2,7 2,-1",
+	'%':  "0,0 4,6;0,6 0,5 1,5 1,6 0,6;3,0 3,1 4,1 4,0 3,0",
+	'&':  "4,0 1,4 1,5 2,6 3,5 3,4 0,2 0,1 1,0 2,0 4,2",
+	'\'': "0,6 0,4.5",
+	'(':  "1.5,7 0.5,6 0,4 0,2 0.5,0 1.5,-1",
+	')':  "0,7 1,6 1.5,4 1.5,2 1,0 0,-1",
+	'*':  "2,5 2,1;0.3,4 3.7,2;0.3,2 3.7,4",
+	'+':  "2,5 2,1;0,3 4,3",
+	',':  "0.5,0.5 0.5,0 0,-1",
+	'-':  "0,3 4,3",
+	'.':  "0,0.5 0,0",
+	'/':  "0,0 4,6",
+	'0':  "1,0 3,0 4,1 4,5 3,6 1,6 0,5 0,1 1,0",
+	'1':  "1,5 2,6 2,0;1,0 3,0",
+	'2':  "0,5 1,6 3,6 4,5 4,4 0,0 4,0",
+	'3':  "0,5 1,6 3,6 4,5 4,4 3,3 1,3;3,3 4,2 4,1 3,0 1,0 0,1",
+	'4':  "3,0 3,6 0,2 4,2",
+	'5':  "4,6 0,6 0,3 3,3 4,2 4,1 3,0 1,0 0,1",
+	'6':  "3,6 1,6 0,5 0,1 1,0 3,0 4,1 4,2 3,3 0,3",
+	'7':  "0,6 4,6 1,0",
+	'8':  "1,3 0,4 0,5 1,6 3,6 4,5 4,4 3,3 1,3 0,2 0,1 1,0 3,0 4,1 4,2 3,3",
+	'9':  "4,3 1,3 0,4 0,5 1,6 3,6 4,5 4,1 3,0 1,0",
+	':':  "0,4 0,3.5;0,0.5 0,0",
+	';':  "0.5,4 0.5,3.5;0.5,0.5 0.5,0 0,-1",
+	'<':  "4,5 0,3 4,1",
+	'=':  "0,4 4,4;0,2 4,2",
+	'>':  "0,5 4,3 0,1",
+	'?':  "0,5 1,6 3,6 4,5 4,4 2,3 2,2;2,0.5 2,0",
+	'@':  "3,2 1,2 1,4 3,4 3,1 4,1 4,5 3,6 1,6 0,5 0,1 1,0 3.5,0",
+	'A':  "0,0 2,6 4,0;0.7,2 3.3,2",
+	'B':  "0,0 0,6 3,6 4,5 4,4 3,3 0,3;3,3 4,2 4,1 3,0 0,0",
+	'C':  "4,5 3,6 1,6 0,5 0,1 1,0 3,0 4,1",
+	'D':  "0,0 0,6 2.5,6 4,4.5 4,1.5 2.5,0 0,0",
+	'E':  "4,6 0,6 0,0 4,0;0,3 3,3",
+	'F':  "4,6 0,6 0,0;0,3 3,3",
+	'G':  "4,5 3,6 1,6 0,5 0,1 1,0 3,0 4,1 4,3 2,3",
+	'H':  "0,0 0,6;4,0 4,6;0,3 4,3",
+	'I':  "0,0 2,0;1,0 1,6;0,6 2,6",
+	'J':  "4,6 4,1 3,0 1,0 0,1",
+	'K':  "0,0 0,6;4,6 0,2;1.5,3.5 4,0",
+	'L':  "0,6 0,0 4,0",
+	'M':  "0,0 0,6 2,3 4,6 4,0",
+	'N':  "0,0 0,6 4,0 4,6",
+	'O':  "1,0 3,0 4,1 4,5 3,6 1,6 0,5 0,1 1,0",
+	'P':  "0,0 0,6 3,6 4,5 4,4 3,3 0,3",
+	'Q':  "1,0 3,0 4,1 4,5 3,6 1,6 0,5 0,1 1,0;2.5,1.5 4,0",
+	'R':  "0,0 0,6 3,6 4,5 4,4 3,3 0,3;2,3 4,0",
+	'S':  "4,5 3,6 1,6 0,5 0,4 1,3 3,3 4,2 4,1 3,0 1,0 0,1",
+	'T':  "0,6 4,6;2,6 2,0",
+	'U':  "0,6 0,1 1,0 3,0 4,1 4,6",
+	'V':  "0,6 2,0 4,6",
+	'W':  "0,6 1,0 2,4 3,0 4,6",
+	'X':  "0,0 4,6;0,6 4,0",
+	'Y':  "0,6 2,3 4,6;2,3 2,0",
+	'Z':  "0,6 4,6 0,0 4,0",
+	'[':  "2,7 0,7 0,-1 2,-1",
+	'\\': "0,6 4,0",
+	']':  "0,7 2,7 2,-1 0,-1",
+	'^':  "0,4 2,6 4,4",
+	'_':  "0,-1 4,-1",
+	'`':  "0,6 1,5",
+	'a':  "1,4 3,4 4,3 4,0;4,2 1,2 0,1 1,0 3,0 4,1",
+	'b':  "0,6 0,0;0,3 1,4 3,4 4,3 4,1 3,0 1,0 0,1",
+	'c':  "4,3 3,4 1,4 0,3 0,1 1,0 3,0 4,1",
+	'd':  "4,6 4,0;4,3 3,4 1,4 0,3 0,1 1,0 3,0 4,1",
+	'e':  "0,2 4,2 4,3 3,4 1,4 0,3 0,1 1,0 3.5,0",
+	'f':  "3,6 2,6 1,5 1,0;0,4 3,4",
+	'g':  "4,4 4,-1 3,-2 1,-2;4,3 3,4 1,4 0,3 0,1 1,0 3,0 4,1",
+	'h':  "0,6 0,0;0,3 1,4 3,4 4,3 4,0",
+	'i':  "0,4 0,0;0,5.5 0,6",
+	'j':  "1,4 1,-1 0,-2;1,5.5 1,6",
+	'k':  "0,6 0,0;3.5,4 0,1.5;1.2,2.3 4,0",
+	'l':  "0,6 0,1 1,0",
+	'm':  "0,4 0,0;0,3 1,4 2,3 2,0;2,3 3,4 4,3 4,0",
+	'n':  "0,4 0,0;0,3 1,4 3,4 4,3 4,0",
+	'o':  "1,0 3,0 4,1 4,3 3,4 1,4 0,3 0,1 1,0",
+	'p':  "0,4 0,-2;0,3 1,4 3,4 4,3 4,1 3,0 1,0 0,1",
+	'q':  "4,4 4,-2;4,3 3,4 1,4 0,3 0,1 1,0 3,0 4,1",
+	'r':  "0,4 0,0;0,2.5 1.5,4 3,4",
+	's':  "4,3 3,4 1,4 0,3 1,2 3,2 4,1 3,0 1,0 0,1",
+	't':  "1,6 1,1 2,0 3,0;0,4 3,4",
+	'u':  "0,4 0,1 1,0 3,0 4,1;4,4 4,0",
+	'v':  "0,4 2,0 4,4",
+	'w':  "0,4 1,0 2,3 3,0 4,4",
+	'x':  "0,0 4,4;0,4 4,0",
+	'y':  "0,4 2,0;4,4 1.5,-1 0.5,-2",
+	'z':  "0,4 4,4 0,0 4,0",
+	'{':  "2,7 1,6.5 1,4 0,3 1,2 1,-0.5 2,-1",
+	'|':  "0,7 0,-1",
+	'}':  "0,7 1,6.5 1,4 2,3 1,2 1,-0.5 0,-1",
+	'~':  "0,3 1,4 3,2 4,3",
+	'°':  "0,6 1,6 1,5 0,5 0,6",
+	'±':  "2,5 2,1;0,3 4,3;0,0 4,0",
+	'µ':  "0,4 0,-2;0,1 1,0 3,0 4,1;4,4 4,0",
+	'Ω':  "0,0 1,0 1,1 0,2 0,5 1,6 3,6 4,5 4,2 3,1 3,0 4,0",
+}
+
+var glyphs = parseGlyphs(glyphSources)
+
+// lookupGlyph returns the glyph for the given character, or a question
+// mark for characters that the font doesn't cover.
+func lookupGlyph(r rune) glyph {
+	if r == ' ' {
+		return glyph{advance: spaceAdvance}
+	}
+	if g, ok := glyphs[r]; ok {
+		return g
+	}
+	return glyphs['?']
+}
+
+func parseGlyphs(sources map[rune]string) map[rune]glyph {
+	ret := make(map[rune]glyph, len(sources))
+	for r, src := range sources {
+		var g glyph
+		width := 0.0
+		for _, strokeSrc := range strings.Split(src, ";") {
+			var stroke []point
+			for _, pointSrc := range strings.Fields(strokeSrc) {
+				xs, ys, _ := strings.Cut(pointSrc, ",")
+				x, err := strconv.ParseFloat(xs, 64)
+				if err != nil {
+					panic("invalid glyph for " + string(r))
+				}
+				y, err := strconv.ParseFloat(ys, 64)
+				if err != nil {
+					panic("invalid glyph for " + string(r))
+				}
+				stroke = append(stroke, point{X: x, Y: y})
+				if x > width {
+					width = x
+				}
+			}
+			g.strokes = append(g.strokes, stroke)
+		}
+		g.advance = width + glyphSpacing
+		ret[r] = g
+	}
+	return ret
+}
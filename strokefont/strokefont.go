@@ -0,0 +1,207 @@
+// Package strokefont draws text as strokes of a pen, for plotting the text
+// of KiCad documents in formats like Gerber that have no fonts of their
+// own.
+//
+// The glyphs are a simple single-stroke font covering printable ASCII and
+// a few symbols common in electronics, rather than a copy of KiCad's own
+// stroke font. Text is laid out at the same size and position as KiCad
+// lays it out, so plotted text occupies about the same area as in KiCad,
+// but the shapes of the characters differ.
+package strokefont
+
+import (
+	"math"
+	"strings"
+
+	"github.com/apparentlymart/go-kicad"
+)
+
+const (
+	// capHeight is the height of capital letters in glyph units, which
+	// corresponds to the height of the text's size.
+	capHeight = 6.0
+
+	// glyphSpacing is the space in glyph units between the right edge of
+	// one glyph and the left edge of the next.
+	glyphSpacing = 1.2
+
+	// interlinePitch is the distance between the baselines of lines of
+	// text, relative to the text height.
+	interlinePitch = 1.62
+
+	// italicTilt is the horizontal slant of italic text, relative to the
+	// height above the baseline.
+	italicTilt = 1.0 / 8
+
+	// scriptScale is the size of superscript and subscript text relative
+	// to the surrounding text.
+	scriptScale = 0.8
+)
+
+// Strokes returns the strokes that draw the given text with the given
+// effects, positioned and rotated by at. Each stroke is a polyline in
+// document coordinates, which is drawn with a round pen of the width
+// returned by Thickness. A stroke with a single point is a dot.
+//
+// The text may have several lines separated by newlines, and may use
+// KiCad's markup for overbars (~{text}), superscripts (^{text}) and
+// subscripts (_{text}).
+func Strokes(text string, at kicad.PositionAngle, effects *kicad.TextEffects) [][]kicad.Position {
+	size := effects.Font.Size
+	if size.Height == 0 || size.Width == 0 {
+		return nil
+	}
+	sx := size.Width / capHeight
+	sy := size.Height / capHeight
+
+	lineSpacing := effects.Font.LineSpacing
+	if lineSpacing == 0 {
+		lineSpacing = 1
+	}
+	pitch := size.Height * interlinePitch * lineSpacing
+
+	lines := strings.Split(text, "\n")
+	total := size.Height + float64(len(lines)-1)*pitch
+	var baseline float64
+	switch {
+	case effects.Justify.Top:
+		baseline = size.Height
+	case effects.Justify.Bottom:
+		baseline = size.Height - total
+	default:
+		baseline = size.Height - total/2
+	}
+
+	var ret [][]kicad.Position
+	for _, line := range lines {
+		strokes, width := layoutLine(line)
+		var x0 float64
+		switch {
+		case effects.Justify.Left:
+			x0 = 0
+		case effects.Justify.Right:
+			x0 = -width * sx
+		default:
+			x0 = -width * sx / 2
+		}
+		for _, stroke := range strokes {
+			pts := make([]kicad.Position, len(stroke))
+			for i, p := range stroke {
+				if effects.Font.Italic {
+					p.X += p.Y * italicTilt
+				}
+				// Glyph coordinates increase upwards, while document
+				// coordinates increase downwards.
+				x := x0 + p.X*sx
+				y := baseline - p.Y*sy
+				if effects.Justify.Mirror {
+					x = -x
+				}
+				pts[i] = place(x, y, at)
+			}
+			ret = append(ret, pts)
+		}
+		baseline += pitch
+	}
+	return ret
+}
+
+// Thickness returns the width of the pen that draws text with the given
+// effects.
+func Thickness(effects *kicad.TextEffects) float64 {
+	if effects.Font.Thickness > 0 {
+		return effects.Font.Thickness
+	}
+	if effects.Font.Bold {
+		return effects.Font.Size.Width / 5
+	}
+	return effects.Font.Size.Width * 0.15
+}
+
+// Width returns the width in millimeters of the widest line of the given
+// text when drawn with the given effects, not including the width of the
+// pen.
+func Width(text string, effects *kicad.TextEffects) float64 {
+	sx := effects.Font.Size.Width / capHeight
+	ret := 0.0
+	for _, line := range strings.Split(text, "\n") {
+		_, width := layoutLine(line)
+		ret = math.Max(ret, width*sx)
+	}
+	return ret
+}
+
+// place rotates the given position relative to the text's anchor by the
+// text's angle, counterclockwise as seen on screen, and then offsets it by
+// the anchor position.
+func place(x, y float64, at kicad.PositionAngle) kicad.Position {
+	sin, cos := math.Sincos(at.Angle * math.Pi / 180)
+	return kicad.Position{
+		X: at.X + x*cos + y*sin,
+		Y: at.Y - x*sin + y*cos,
+	}
+}
+
+// point is a position in glyph units, with Y increasing upwards from the
+// baseline.
+type point struct {
+	X, Y float64
+}
+
+// layoutLine lays out a single line of text, returning its strokes in
+// glyph units relative to the left end of its baseline along with its
+// advance width.
+func layoutLine(line string) ([][]point, float64) {
+	var ret [][]point
+	x := 0.0
+	var scale, rise float64 = 1, 0
+	var markup rune // the markup character of the open group, if any
+	overbarStart := 0.0
+
+	runes := []rune(line)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		if (r == '~' || r == '^' || r == '_') && markup == 0 && i+1 < len(runes) && runes[i+1] == '{' {
+			markup = r
+			i++
+			switch r {
+			case '~':
+				overbarStart = x
+			case '^':
+				scale, rise = scriptScale, capHeight*0.5
+			case '_':
+				scale, rise = scriptScale, -capHeight*0.3
+			}
+			continue
+		}
+		if r == '}' && markup != 0 {
+			if markup == '~' {
+				y := capHeight + 1.5
+				ret = append(ret, []point{{overbarStart, y}, {x - glyphSpacing, y}})
+			}
+			markup = 0
+			scale, rise = 1, 0
+			continue
+		}
+
+		g := lookupGlyph(r)
+		for _, stroke := range g.strokes {
+			pts := make([]point, len(stroke))
+			for j, p := range stroke {
+				pts[j] = point{X: x + p.X*scale, Y: rise + p.Y*scale}
+			}
+			ret = append(ret, pts)
+		}
+		x += g.advance * scale
+	}
+	if markup == '~' {
+		y := capHeight + 1.5
+		ret = append(ret, []point{{overbarStart, y}, {x - glyphSpacing, y}})
+	}
+
+	width := x - glyphSpacing
+	if width < 0 {
+		width = 0
+	}
+	return ret, width
+}
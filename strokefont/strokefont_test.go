@@ -0,0 +1,121 @@
+package strokefont
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/apparentlymart/go-kicad"
+	"github.com/davecgh/go-spew/spew"
+)
+
+func TestStrokes(t *testing.T) {
+	effects := &kicad.TextEffects{
+		Font: kicad.Font{Size: kicad.TextSize{Height: 1.2, Width: 1.2}},
+	}
+
+	tests := map[string]struct {
+		at      kicad.PositionAngle
+		justify kicad.TextJustify
+		want    [][]kicad.Position
+	}{
+		"centered": {
+			at: kicad.PositionAngle{X: 10, Y: 20},
+			want: [][]kicad.Position{
+				{{X: 9.8, Y: 20.6}, {X: 10.2, Y: 20.6}},
+				{{X: 10, Y: 20.6}, {X: 10, Y: 19.4}},
+				{{X: 9.8, Y: 19.4}, {X: 10.2, Y: 19.4}},
+			},
+		},
+		"left top": {
+			at:      kicad.PositionAngle{X: 10, Y: 20},
+			justify: kicad.TextJustify{Left: true, Top: true},
+			want: [][]kicad.Position{
+				{{X: 10, Y: 21.2}, {X: 10.4, Y: 21.2}},
+				{{X: 10.2, Y: 21.2}, {X: 10.2, Y: 20}},
+				{{X: 10, Y: 20}, {X: 10.4, Y: 20}},
+			},
+		},
+		"mirrored right": {
+			at:      kicad.PositionAngle{X: 10, Y: 20},
+			justify: kicad.TextJustify{Right: true, Mirror: true},
+			want: [][]kicad.Position{
+				{{X: 10.4, Y: 20.6}, {X: 10, Y: 20.6}},
+				{{X: 10.2, Y: 20.6}, {X: 10.2, Y: 19.4}},
+				{{X: 10.4, Y: 19.4}, {X: 10, Y: 19.4}},
+			},
+		},
+		"rotated": {
+			at: kicad.PositionAngle{X: 10, Y: 20, Angle: 90},
+			want: [][]kicad.Position{
+				{{X: 10.6, Y: 20.2}, {X: 10.6, Y: 19.8}},
+				{{X: 10.6, Y: 20}, {X: 9.4, Y: 20}},
+				{{X: 9.4, Y: 20.2}, {X: 9.4, Y: 19.8}},
+			},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			e := *effects
+			e.Justify = test.justify
+			got := Strokes("I", test.at, &e)
+			for _, stroke := range got {
+				for i := range stroke {
+					stroke[i].X = math.Round(stroke[i].X*1e6) / 1e6
+					stroke[i].Y = math.Round(stroke[i].Y*1e6) / 1e6
+				}
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("incorrect result\ngot:  %swant: %s", spew.Sdump(got), spew.Sdump(test.want))
+			}
+		})
+	}
+}
+
+func TestLayoutLine(t *testing.T) {
+	tests := map[string]struct {
+		strokes int
+		width   float64
+	}{
+		"":          {0, 0},
+		"I":         {3, 2},
+		"II":        {6, 5.2},
+		"I I":       {6, 8.2},
+		"~{II}":     {7, 5.2},
+		"I^{I}":     {6, 4.56},
+		"☃":         {2, 4},
+		"~{unclose": {10, 32.2},
+	}
+	for text, want := range tests {
+		strokes, width := layoutLine(text)
+		if len(strokes) != want.strokes {
+			t.Errorf("wrong number of strokes for %q: got %d, want %d", text, len(strokes), want.strokes)
+		}
+		if math.Abs(width-want.width) > 1e-9 {
+			t.Errorf("wrong width for %q: got %g, want %g", text, width, want.width)
+		}
+	}
+}
+
+func TestWidth(t *testing.T) {
+	effects := &kicad.TextEffects{Font: kicad.Font{Size: kicad.TextSize{Height: 3, Width: 3}}}
+	if got, want := Width("II\nI", effects), 2.6; math.Abs(got-want) > 1e-9 {
+		t.Errorf("wrong width: got %g, want %g", got, want)
+	}
+}
+
+func TestThickness(t *testing.T) {
+	tests := []struct {
+		font kicad.Font
+		want float64
+	}{
+		{kicad.Font{Size: kicad.TextSize{Height: 1, Width: 1}, Thickness: 0.2}, 0.2},
+		{kicad.Font{Size: kicad.TextSize{Height: 1, Width: 1}}, 0.15},
+		{kicad.Font{Size: kicad.TextSize{Height: 1, Width: 1}, Bold: true}, 0.2},
+	}
+	for _, test := range tests {
+		if got := Thickness(&kicad.TextEffects{Font: test.font}); got != test.want {
+			t.Errorf("wrong thickness for %#v: got %g, want %g", test.font, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,210 @@
+package kicad
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Symbol is a schematic symbol, either stored in a symbol library or cached
+// in the lib_symbols section of a schematic.
+//
+// The graphics and pins of a symbol are divided between its Units, each of
+// which applies to a particular unit and body style of the symbol. A symbol
+// that Extends another has no units of its own, and instead inherits the
+// units of its parent; use SymbolLibrary.Resolve to obtain the effective
+// symbol in that case.
+type Symbol struct {
+	Name           string      `kicad:""`
+	Extends        string      `kicad:"extends"`
+	Power          bool        `kicad:"power,empty"`
+	PinNumbers     *PinNumbers `kicad:"pin_numbers,flat"`
+	PinNames       *PinNames   `kicad:"pin_names,flat"`
+	ExcludeFromSim bool        `kicad:"exclude_from_sim"`
+
+	// InBOM and OnBoard are nil if the document doesn't specify them, in
+	// which case they default to true. Use the methods of the same names
+	// to get the effective values.
+	InBOM   *bool `kicad:"in_bom"`
+	OnBoard *bool `kicad:"on_board"`
+
+	Properties    []SchProperty `kicad:"property,multi,flat"`
+	Units         []SymbolUnit  `kicad:"symbol,multi,flat"`
+	EmbeddedFonts bool          `kicad:"embedded_fonts"`
+}
+
+// IncludeInBOM returns true if the symbol should be included in a bill of
+// materials.
+func (s *Symbol) IncludeInBOM() bool {
+	return s.InBOM == nil || *s.InBOM
+}
+
+// IncludeOnBoard returns true if the symbol should be exported to the board
+// when the schematic is updated.
+func (s *Symbol) IncludeOnBoard() bool {
+	return s.OnBoard == nil || *s.OnBoard
+}
+
+// Property returns the value of the property with the given name, or the
+// empty string if there is no such property.
+func (s *Symbol) Property(name string) string {
+	if prop := s.property(name); prop != nil {
+		return prop.Value
+	}
+	return ""
+}
+
+func (s *Symbol) property(name string) *SchProperty {
+	for i := range s.Properties {
+		if s.Properties[i].Name == name {
+			return &s.Properties[i]
+		}
+	}
+	return nil
+}
+
+// UnitCount returns the number of units in the symbol, which is one for
+// symbols that are not split into several units.
+func (s *Symbol) UnitCount() int {
+	count := 1
+	for _, unit := range s.Units {
+		if n, _ := unit.UnitAndStyle(); n > count {
+			count = n
+		}
+	}
+	return count
+}
+
+// UnitItems returns the units of the symbol that should be drawn for the
+// given unit number and body style, counting from one. This includes the
+// units numbered zero, which are common to all units or all body styles.
+func (s *Symbol) UnitItems(unit, bodyStyle int) []SymbolUnit {
+	var ret []SymbolUnit
+	for _, u := range s.Units {
+		n, style := u.UnitAndStyle()
+		if (n == 0 || n == unit) && (style == 0 || style == bodyStyle) {
+			ret = append(ret, u)
+		}
+	}
+	return ret
+}
+
+// Pins returns all of the pins of the symbol, across all of its units.
+func (s *Symbol) Pins() []Pin {
+	var ret []Pin
+	for _, unit := range s.Units {
+		ret = append(ret, unit.Pins...)
+	}
+	return ret
+}
+
+// PinNumbers controls the display of the pin numbers of a symbol.
+type PinNumbers struct {
+	Hide bool `kicad:"hide,bare"`
+}
+
+// PinNames controls the display of the pin names of a symbol.
+//
+// Offset is the distance of the pin names inside the symbol body from the
+// ends of their pins, or nil for the default. An offset of zero places the
+// names outside the body, above and below the pins.
+type PinNames struct {
+	Offset *float64 `kicad:"offset"`
+	Hide   bool     `kicad:"hide,bare"`
+}
+
+// SymbolUnit holds the graphics and pins of one unit and body style of a
+// symbol.
+//
+// Its name is the name of the symbol followed by the unit number and the
+// body style number, as in "R_1_1". Either number may be zero, meaning that
+// the items are common to all units or body styles.
+type SymbolUnit struct {
+	Name     string `kicad:""`
+	UnitName string `kicad:"unit_name"`
+
+	Arcs       []SchArc       `kicad:"arc,multi,flat"`
+	Circles    []SchCircle    `kicad:"circle,multi,flat"`
+	Rectangles []SchRectangle `kicad:"rectangle,multi,flat"`
+	Polylines  []SchPolyline  `kicad:"polyline,multi,flat"`
+	Beziers    []SchBezier    `kicad:"bezier,multi,flat"`
+	Texts      []SchText      `kicad:"text,multi,flat"`
+	TextBoxes  []SchTextBox   `kicad:"text_box,multi,flat"`
+	Pins       []Pin          `kicad:"pin,multi,flat"`
+}
+
+// UnitAndStyle returns the unit number and body style number encoded in
+// the unit's name, or zeros if the name isn't of the expected form.
+func (u *SymbolUnit) UnitAndStyle() (unit, bodyStyle int) {
+	rest, styleStr, ok := cutLast(u.Name, "_")
+	if !ok {
+		return 0, 0
+	}
+	_, unitStr, ok := cutLast(rest, "_")
+	if !ok {
+		return 0, 0
+	}
+	unit, err := strconv.Atoi(unitStr)
+	if err != nil {
+		return 0, 0
+	}
+	bodyStyle, err = strconv.Atoi(styleStr)
+	if err != nil {
+		return 0, 0
+	}
+	return unit, bodyStyle
+}
+
+func cutLast(s, sep string) (before, after string, found bool) {
+	if i := strings.LastIndex(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+	return s, "", false
+}
+
+// Pin is a pin of a symbol.
+//
+// ElectricalType is one of "input", "output", "bidirectional",
+// "tri_state", "passive", "free", "unspecified", "power_in", "power_out",
+// "open_collector", "open_emitter" or "no_connect". GraphicStyle is one of
+// "line", "inverted", "clock", "inverted_clock", "input_low", "clock_low",
+// "output_low", "edge_clock_high" or "non_logic".
+//
+// At is the position of the connection point of the pin, and its angle is
+// the direction from there towards the symbol body.
+type Pin struct {
+	ElectricalType string         `kicad:""`
+	GraphicStyle   string         `kicad:""`
+	At             PositionAngle  `kicad:"at,flat,always"`
+	Length         float64        `kicad:"length,always"`
+	Hide           bool           `kicad:"hide,bare"`
+	Name           PinText        `kicad:"name,flat,always"`
+	Number         PinText        `kicad:"number,flat,always"`
+	Alternates     []PinAlternate `kicad:"alternate,multi,flat"`
+}
+
+// End returns the position of the end of the pin that meets the symbol
+// body. Like all positions within symbols, it is in symbol coordinates,
+// where Y increases upwards.
+func (p *Pin) End() Position {
+	s, c := math.Sincos(p.At.Angle * math.Pi / 180)
+	return Position{
+		X: p.At.X + c*p.Length,
+		Y: p.At.Y + s*p.Length,
+	}
+}
+
+// PinText is the name or number of a pin. A name of "~" means that the pin
+// has no name.
+type PinText struct {
+	Text    string      `kicad:""`
+	Effects TextEffects `kicad:"effects,flat"`
+}
+
+// PinAlternate is an alternate function of a pin, which can be selected
+// for each instance of the symbol in a schematic.
+type PinAlternate struct {
+	Name           string `kicad:""`
+	ElectricalType string `kicad:""`
+	GraphicStyle   string `kicad:""`
+}
@@ -0,0 +1,173 @@
+package kicad
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/apparentlymart/go-kicad/sexp"
+)
+
+// SymbolLibrary is a symbol library, as stored in a .kicad_sym file.
+type SymbolLibrary struct {
+	Version          int      `kicad:"version"`
+	Generator        string   `kicad:"generator"`
+	GeneratorVersion string   `kicad:"generator_version"`
+	Symbols          []Symbol `kicad:"symbol,multi,flat"`
+}
+
+// ReadSymbolLibrary reads a stream containing a symbol library and returns
+// a SymbolLibrary structure describing it.
+func ReadSymbolLibrary(r io.Reader) (*SymbolLibrary, error) {
+	lib := &SymbolLibrary{}
+	err := sexp.Decode(r, "kicad_symbol_lib", lib)
+	return lib, err
+}
+
+// ReadSymbolLibraryFile is a convenience wrapper around ReadSymbolLibrary
+// that takes a filename and opens the given file for reading before calling
+// ReadSymbolLibrary.
+func ReadSymbolLibraryFile(filename string) (*SymbolLibrary, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ReadSymbolLibrary(f)
+}
+
+// WriteSymbolLibrary writes the given symbol library to the given writer.
+//
+// The SymbolLibrary structure is not a comprehensive representation of the
+// file format, so writing a library previously returned by
+// ReadSymbolLibrary will lose any information that the structure doesn't
+// capture.
+func WriteSymbolLibrary(w io.Writer, lib *SymbolLibrary) error {
+	return sexp.Encode(w, "kicad_symbol_lib", lib)
+}
+
+// WriteSymbolLibraryFile is a convenience wrapper around WriteSymbolLibrary
+// that creates or replaces the given file before calling
+// WriteSymbolLibrary.
+func WriteSymbolLibraryFile(filename string, lib *SymbolLibrary) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+
+	err = WriteSymbolLibrary(f, lib)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Symbol returns the symbol with the given name, or nil if there is no such
+// symbol in the library.
+func (l *SymbolLibrary) Symbol(name string) *Symbol {
+	for i := range l.Symbols {
+		if l.Symbols[i].Name == name {
+			return &l.Symbols[i]
+		}
+	}
+	return nil
+}
+
+// Resolve returns the effective form of the symbol with the given name,
+// after applying any chain of symbols that it extends.
+//
+// A derived symbol takes its units from its root symbol, renamed to match
+// the derived symbol, and takes its properties from its parent except where
+// it overrides them or adds its own. Display settings and the in_bom and
+// on_board flags of the derived symbol override those of its parent only
+// where the derived symbol specifies them.
+//
+// The result is a new symbol with an empty Extends. Its Properties and
+// Units slices can be modified freely, but the items within them may share
+// data with the symbols in the library.
+func (l *SymbolLibrary) Resolve(name string) (*Symbol, error) {
+	return l.resolve(name, nil)
+}
+
+func (l *SymbolLibrary) resolve(name string, seen []string) (*Symbol, error) {
+	for _, prev := range seen {
+		if prev == name {
+			return nil, fmt.Errorf("symbol %q extends itself via %s", name, strings.Join(seen, " -> "))
+		}
+	}
+	seen = append(seen, name)
+
+	sym := l.Symbol(name)
+	if sym == nil {
+		if len(seen) > 1 {
+			return nil, fmt.Errorf("symbol %q extends undefined symbol %q", seen[len(seen)-2], name)
+		}
+		return nil, fmt.Errorf("no symbol %q in library", name)
+	}
+
+	ret := *sym
+	ret.Properties = append([]SchProperty(nil), sym.Properties...)
+	ret.Units = append([]SymbolUnit(nil), sym.Units...)
+	if sym.Extends == "" {
+		return &ret, nil
+	}
+
+	parent, err := l.resolve(sym.Extends, seen)
+	if err != nil {
+		return nil, err
+	}
+
+	ret.Extends = ""
+	if len(ret.Units) == 0 {
+		ret.Units = parent.Units
+		oldPrefix := symbolItemName(parent.Name) + "_"
+		newPrefix := symbolItemName(ret.Name) + "_"
+		for i := range ret.Units {
+			ret.Units[i].Name = newPrefix + strings.TrimPrefix(ret.Units[i].Name, oldPrefix)
+		}
+	}
+
+	props := parent.Properties
+	for _, prop := range sym.Properties {
+		replaced := false
+		for i := range props {
+			if props[i].Name == prop.Name {
+				props[i] = prop
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			props = append(props, prop)
+		}
+	}
+	ret.Properties = props
+
+	ret.Power = ret.Power || parent.Power
+	if ret.PinNumbers == nil {
+		ret.PinNumbers = parent.PinNumbers
+	}
+	if ret.PinNames == nil {
+		ret.PinNames = parent.PinNames
+	}
+	if ret.InBOM == nil {
+		ret.InBOM = parent.InBOM
+	}
+	if ret.OnBoard == nil {
+		ret.OnBoard = parent.OnBoard
+	}
+
+	return &ret, nil
+}
+
+// symbolItemName returns the given symbol name without any library
+// nickname, which is how symbol names appear in the names of their units.
+func symbolItemName(name string) string {
+	if i := strings.LastIndex(name, ":"); i >= 0 {
+		return name[i+1:]
+	}
+	return name
+}
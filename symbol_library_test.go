@@ -0,0 +1,183 @@
+package kicad
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/davecgh/go-spew/spew"
+)
+
+const testSymbolLibrary = `(kicad_symbol_lib (version 20231120) (generator "kicad_symbol_editor") (generator_version "8.0")
+	(symbol "R"
+		(pin_numbers hide)
+		(pin_names (offset 0))
+		(exclude_from_sim no)
+		(in_bom yes)
+		(on_board yes)
+		(property "Reference" "R" (at 2.032 0 90) (effects (font (size 1.27 1.27))))
+		(property "Value" "R" (at 0 0 90) (effects (font (size 1.27 1.27))))
+		(property "Footprint" "" (at -1.778 0 90) (effects (font (size 1.27 1.27)) hide))
+		(symbol "R_0_1"
+			(rectangle (start -1.016 -2.54) (end 1.016 2.54)
+				(stroke (width 0.254) (type default))
+				(fill (type none))
+			)
+		)
+		(symbol "R_1_1"
+			(pin passive line (at 0 3.81 270) (length 1.27)
+				(name "~" (effects (font (size 1.27 1.27))))
+				(number "1" (effects (font (size 1.27 1.27))))
+			)
+			(pin passive line (at 0 -3.81 90) (length 1.27)
+				(name "~" (effects (font (size 1.27 1.27))))
+				(number "2" (effects (font (size 1.27 1.27))))
+			)
+		)
+	)
+	(symbol "R_Small" (extends "R")
+		(property "Value" "R_Small" (at 0 0 90) (effects (font (size 1 1))))
+		(property "ki_keywords" "resistor" (at 0 0 0) (effects (font (size 1.27 1.27)) hide))
+	)
+	(symbol "R_Small_Power" (extends "R_Small") (power) (in_bom no)
+		(property "Reference" "#PWR" (at 0 0 0) (effects (font (size 1.27 1.27))))
+	)
+	(symbol "MCU" (pin_names (offset 1.016) hide)
+		(property "Reference" "U" (id 0) (at 0 0 0) (effects (font (size 1.27 1.27))))
+		(symbol "MCU_1_1"
+			(unit_name "Logic")
+			(polyline (pts (xy -5 0) (xy 5 0)) (stroke (width 0) (type default)) (fill (type background)))
+			(pin bidirectional line (at -7.62 0 0) (length 2.54)
+				(name "PA0" (effects (font (size 1.27 1.27))))
+				(number "1" (effects (font (size 1.27 1.27))))
+				(alternate "ADC0" input line)
+				(alternate "TX" output inverted)
+			)
+		)
+		(symbol "MCU_2_1"
+			(pin power_in line (at 0 7.62 270) (length 2.54) hide
+				(name "VCC" (effects (font (size 1.27 1.27))))
+				(number "2" (effects (font (size 1.27 1.27))))
+			)
+		)
+	)
+	(symbol "Loop1" (extends "Loop2"))
+	(symbol "Loop2" (extends "Loop1"))
+)
+`
+
+func TestReadWriteSymbolLibrary(t *testing.T) {
+	lib, err := ReadSymbolLibrary(strings.NewReader(testSymbolLibrary))
+	if err != nil {
+		t.Fatalf("unexpected error reading: %s", err)
+	}
+
+	r := lib.Symbol("R")
+	if r == nil {
+		t.Fatalf("no symbol R")
+	}
+	if got, want := len(r.Pins()), 2; got != want {
+		t.Errorf("wrong number of pins %d; want %d", got, want)
+	}
+	if r.PinNames == nil || r.PinNames.Offset == nil || *r.PinNames.Offset != 0 {
+		t.Errorf("wrong pin name settings %s", spew.Sdump(r.PinNames))
+	}
+	if r.PinNumbers == nil || !r.PinNumbers.Hide {
+		t.Errorf("pin numbers should be hidden")
+	}
+	if got, want := r.Units[1].Pins[0].End(), (Position{X: 0, Y: 2.54}); Distance(got, want) > 1e-9 {
+		t.Errorf("wrong pin end %#v; want %#v", got, want)
+	}
+
+	mcu := lib.Symbol("MCU")
+	if got, want := mcu.UnitCount(), 2; got != want {
+		t.Errorf("wrong unit count %d; want %d", got, want)
+	}
+	if got, want := len(mcu.UnitItems(2, 1)), 1; got != want {
+		t.Errorf("wrong number of units for unit 2 %d; want %d", got, want)
+	}
+	pins := mcu.Pins()
+	if got, want := pins[0].Alternates, []PinAlternate{{"ADC0", "input", "line"}, {"TX", "output", "inverted"}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("wrong alternates %#v; want %#v", got, want)
+	}
+	if !pins[1].Hide {
+		t.Errorf("pin 2 should be hidden")
+	}
+
+	var buf bytes.Buffer
+	err = WriteSymbolLibrary(&buf, lib)
+	if err != nil {
+		t.Fatalf("unexpected error writing: %s", err)
+	}
+	got, err := ReadSymbolLibrary(&buf)
+	if err != nil {
+		t.Fatalf("unexpected error re-reading: %s\n%s", err, buf.String())
+	}
+	if !reflect.DeepEqual(got, lib) {
+		t.Errorf("incorrect round-trip\ngot:  %swant: %s", spew.Sdump(got), spew.Sdump(lib))
+	}
+}
+
+func TestSymbolLibraryResolve(t *testing.T) {
+	lib, err := ReadSymbolLibrary(strings.NewReader(testSymbolLibrary))
+	if err != nil {
+		t.Fatalf("unexpected error reading: %s", err)
+	}
+
+	sym, err := lib.Resolve("R_Small_Power")
+	if err != nil {
+		t.Fatalf("unexpected error resolving: %s", err)
+	}
+	if sym.Extends != "" {
+		t.Errorf("resolved symbol still extends %q", sym.Extends)
+	}
+	if !sym.Power {
+		t.Errorf("resolved symbol should be a power symbol")
+	}
+	if sym.IncludeInBOM() {
+		t.Errorf("resolved symbol should not be in BOM")
+	}
+	if !sym.IncludeOnBoard() {
+		t.Errorf("resolved symbol should be on board")
+	}
+	if sym.PinNumbers == nil || !sym.PinNumbers.Hide {
+		t.Errorf("resolved symbol should inherit hidden pin numbers")
+	}
+
+	var propNames []string
+	for _, prop := range sym.Properties {
+		propNames = append(propNames, prop.Name+"="+prop.Value)
+	}
+	wantProps := []string{"Reference=#PWR", "Value=R_Small", "Footprint=", "ki_keywords=resistor"}
+	if !reflect.DeepEqual(propNames, wantProps) {
+		t.Errorf("wrong properties %#v; want %#v", propNames, wantProps)
+	}
+
+	var unitNames []string
+	for _, unit := range sym.Units {
+		unitNames = append(unitNames, unit.Name)
+	}
+	wantUnits := []string{"R_Small_Power_0_1", "R_Small_Power_1_1"}
+	if !reflect.DeepEqual(unitNames, wantUnits) {
+		t.Errorf("wrong unit names %#v; want %#v", unitNames, wantUnits)
+	}
+	if got, want := len(sym.UnitItems(1, 1)), 2; got != want {
+		t.Errorf("wrong number of units for unit 1 %d; want %d", got, want)
+	}
+
+	// Resolving must not modify the symbols in the library.
+	if got, want := lib.Symbol("R").Units[0].Name, "R_0_1"; got != want {
+		t.Errorf("library symbol unit renamed to %q", got)
+	}
+	if got, want := lib.Symbol("R").Property("Value"), "R"; got != want {
+		t.Errorf("library symbol value changed to %q", got)
+	}
+
+	if _, err := lib.Resolve("Loop1"); err == nil {
+		t.Errorf("resolving circular symbol should fail")
+	}
+	if _, err := lib.Resolve("Missing"); err == nil {
+		t.Errorf("resolving missing symbol should fail")
+	}
+}
@@ -0,0 +1,91 @@
+package kicad
+
+import (
+	"strings"
+)
+
+// TextEffects describes the appearance of a text item.
+type TextEffects struct {
+	Font    Font        `kicad:"font,flat"`
+	Justify TextJustify `kicad:"justify,flat"`
+	Hide    bool        `kicad:"hide,bare"`
+	Href    string      `kicad:"href"`
+}
+
+// Font describes the typeface of a text item. Face is empty for the KiCad
+// built-in stroke font.
+type Font struct {
+	Face        string   `kicad:"face"`
+	Size        TextSize `kicad:"size,flat"`
+	Thickness   float64  `kicad:"thickness"`
+	Bold        bool     `kicad:"bold,bare"`
+	Italic      bool     `kicad:"italic,bare"`
+	LineSpacing float64  `kicad:"line_spacing"`
+	Color       Color    `kicad:"color,flat"`
+}
+
+// TextSize is the size of the characters of a text item. Unlike Size,
+// KiCad writes the height before the width.
+type TextSize struct {
+	Height float64 `kicad:""`
+	Width  float64 `kicad:""`
+}
+
+// TextJustify describes the alignment of a text item relative to its
+// position. Text is centered on any axis where neither flag is set.
+type TextJustify struct {
+	Left   bool `kicad:"left,bare"`
+	Right  bool `kicad:"right,bare"`
+	Top    bool `kicad:"top,bare"`
+	Bottom bool `kicad:"bottom,bare"`
+	Mirror bool `kicad:"mirror,bare"`
+}
+
+// TextLayer is the layer that a board text item is drawn on. Knockout text
+// is drawn as a cutout from a filled box rather than directly.
+type TextLayer struct {
+	Name     string `kicad:""`
+	Knockout bool   `kicad:"knockout,bare"`
+}
+
+// ExpandTextVars replaces references to text variables in the given string,
+// written as ${NAME}, with the values that resolve returns for them. The
+// values are themselves expanded, to a limited depth so that variables that
+// refer to themselves don't expand forever.
+//
+// References for which resolve returns false are left unchanged, as KiCad
+// does.
+func ExpandTextVars(s string, resolve func(name string) (string, bool)) string {
+	return expandTextVars(s, resolve, 0)
+}
+
+// maxTextVarDepth is the number of times that values of text variables are
+// expanded in turn.
+const maxTextVarDepth = 10
+
+func expandTextVars(s string, resolve func(name string) (string, bool), depth int) string {
+	var b strings.Builder
+	for {
+		i := strings.Index(s, "${")
+		if i < 0 {
+			break
+		}
+		end := strings.IndexByte(s[i:], '}')
+		if end < 0 {
+			break
+		}
+		end += i
+		b.WriteString(s[:i])
+		if v, ok := resolve(s[i+2 : end]); ok {
+			if depth < maxTextVarDepth {
+				v = expandTextVars(v, resolve, depth+1)
+			}
+			b.WriteString(v)
+		} else {
+			b.WriteString(s[i : end+1])
+		}
+		s = s[end+1:]
+	}
+	b.WriteString(s)
+	return b.String()
+}
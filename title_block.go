@@ -0,0 +1,41 @@
+package kicad
+
+// TitleBlock is the information shown in the title block of a document's
+// drawing sheet. Date is free-form text, as KiCad doesn't interpret it.
+type TitleBlock struct {
+	Title    string              `kicad:"title"`
+	Date     string              `kicad:"date"`
+	Revision string              `kicad:"rev"`
+	Company  string              `kicad:"company"`
+	Comments []TitleBlockComment `kicad:"comment,multi,flat"`
+}
+
+// TitleBlockComment is one of the numbered comments of a title block, which
+// are numbered from 1 to 9.
+type TitleBlockComment struct {
+	Number int    `kicad:""`
+	Text   string `kicad:""`
+}
+
+// Comment returns the text of the comment with the given number, or the
+// empty string if there is no such comment.
+func (tb *TitleBlock) Comment(n int) string {
+	for _, c := range tb.Comments {
+		if c.Number == n {
+			return c.Text
+		}
+	}
+	return ""
+}
+
+// SetComment sets the text of the comment with the given number, replacing
+// any existing comment with that number.
+func (tb *TitleBlock) SetComment(n int, text string) {
+	for i := range tb.Comments {
+		if tb.Comments[i].Number == n {
+			tb.Comments[i].Text = text
+			return
+		}
+	}
+	tb.Comments = append(tb.Comments, TitleBlockComment{Number: n, Text: text})
+}
@@ -0,0 +1,105 @@
+package kicad
+
+import (
+	"math"
+)
+
+// Segment is a straight track segment on a copper layer. Net is the number
+// of a net declared in PCB.Nets.
+type Segment struct {
+	Start  Position `kicad:"start,flat,always"`
+	End    Position `kicad:"end,flat,always"`
+	Width  float64  `kicad:"width"`
+	Layer  string   `kicad:"layer"`
+	Locked bool     `kicad:"locked,bare"`
+	Net    int      `kicad:"net"`
+	UUID   string   `kicad:"uuid"`
+	TStamp string   `kicad:"tstamp"`
+}
+
+// Length returns the length of the centerline of the segment.
+func (s *Segment) Length() float64 {
+	return Distance(s.Start, s.End)
+}
+
+// Arc is a curved track segment on a copper layer, running from Start
+// through Mid to End. Arc tracks were introduced in KiCad 6.
+type Arc struct {
+	Start  Position `kicad:"start,flat,always"`
+	Mid    Position `kicad:"mid,flat,always"`
+	End    Position `kicad:"end,flat,always"`
+	Width  float64  `kicad:"width"`
+	Layer  string   `kicad:"layer"`
+	Locked bool     `kicad:"locked,bare"`
+	Net    int      `kicad:"net"`
+	UUID   string   `kicad:"uuid"`
+	TStamp string   `kicad:"tstamp"`
+}
+
+// Length returns the length of the centerline of the arc.
+func (a *Arc) Length() float64 {
+	center, ok := ArcCenter(a.Start, a.Mid, a.End)
+	if !ok {
+		// Degenerate arcs with collinear points are straight lines
+		return Distance(a.Start, a.End)
+	}
+	return Distance(center, a.Start) * math.Abs(ArcSweep(center, a.Start, a.Mid, a.End))
+}
+
+// Via is a plated hole connecting copper layers.
+//
+// Type is empty for a through via, or else "blind" or "micro". Layers
+// gives the outermost pair of copper layers that the via connects.
+type Via struct {
+	Type               string   `kicad:",optional"`
+	Locked             bool     `kicad:"locked,bare"`
+	At                 Position `kicad:"at,flat,always"`
+	Size               float64  `kicad:"size"`
+	Drill              float64  `kicad:"drill"`
+	Layers             []string `kicad:"layers,flat"`
+	RemoveUnusedLayers bool     `kicad:"remove_unused_layers,empty"`
+	KeepEndLayers      bool     `kicad:"keep_end_layers,empty"`
+	Free               bool     `kicad:"free,empty"`
+	Net                int      `kicad:"net"`
+	UUID               string   `kicad:"uuid"`
+	TStamp             string   `kicad:"tstamp"`
+}
+
+// NetName returns the name of the net with the given number, or the empty
+// string if no such net is declared.
+func (p *PCB) NetName(number int) string {
+	for _, net := range p.Nets {
+		if net.Number == number {
+			return net.Name
+		}
+	}
+	return ""
+}
+
+// NetNumber returns the number of the net with the given name, or -1 if
+// no such net is declared.
+func (p *PCB) NetNumber(name string) int {
+	for _, net := range p.Nets {
+		if net.Name == name {
+			return net.Number
+		}
+	}
+	return -1
+}
+
+// TrackLength returns the total centerline length of the segments and arcs
+// belonging to the net with the given number.
+func (p *PCB) TrackLength(net int) float64 {
+	total := 0.0
+	for i := range p.Segments {
+		if p.Segments[i].Net == net {
+			total += p.Segments[i].Length()
+		}
+	}
+	for i := range p.Arcs {
+		if p.Arcs[i].Net == net {
+			total += p.Arcs[i].Length()
+		}
+	}
+	return total
+}
@@ -0,0 +1,111 @@
+package kicad
+
+// Zone is a copper zone or a rule area (keepout) on a board or within a
+// footprint.
+//
+// A zone is on either a single layer given in Layer or several layers given
+// in Layers; LayerNames returns whichever is set. Outline gives the polygon
+// the user drew, while Filled gives the copper areas that KiCad calculated
+// when the zone was last filled.
+type Zone struct {
+	Net                  int                 `kicad:"net"`
+	NetName              string              `kicad:"net_name"`
+	Layer                string              `kicad:"layer"`
+	Layers               []string            `kicad:"layers,flat"`
+	UUID                 string              `kicad:"uuid"`
+	TStamp               string              `kicad:"tstamp"`
+	Name                 string              `kicad:"name"`
+	Hatch                ZoneHatch           `kicad:"hatch,flat"`
+	Priority             int                 `kicad:"priority"`
+	ConnectPads          ZoneConnectPads     `kicad:"connect_pads,flat"`
+	MinThickness         float64             `kicad:"min_thickness"`
+	FilledAreasThickness bool                `kicad:"filled_areas_thickness"`
+	Keepout              ZoneKeepout         `kicad:"keepout,flat"`
+	Fill                 ZoneFill            `kicad:"fill,flat"`
+	Locked               bool                `kicad:"locked,bare"`
+	Outline              []ZonePolygon       `kicad:"polygon,multi,flat"`
+	Filled               []ZoneFilledPolygon `kicad:"filled_polygon,multi,flat"`
+}
+
+// LayerNames returns the names of the layers that the zone is on.
+func (z *Zone) LayerNames() []string {
+	if len(z.Layers) > 0 {
+		return z.Layers
+	}
+	if z.Layer != "" {
+		return []string{z.Layer}
+	}
+	return nil
+}
+
+// IsRuleArea returns true if the zone is a rule area, which restricts what
+// can be placed within it rather than being filled with copper.
+func (z *Zone) IsRuleArea() bool {
+	return z.Keepout != ZoneKeepout{}
+}
+
+// ZoneHatch describes how the outline of a zone is drawn in the editor.
+// Style is one of "none", "edge" or "full".
+type ZoneHatch struct {
+	Style string  `kicad:""`
+	Pitch float64 `kicad:""`
+}
+
+// ZoneConnectPads describes how pads connect to a zone. Mode is empty for
+// connection via thermal reliefs, "yes" for solid connection, "no" for no
+// connection, or "thru_hole_only" for thermal reliefs on through-hole pads
+// only.
+type ZoneConnectPads struct {
+	Mode      string  `kicad:",optional"`
+	Clearance float64 `kicad:"clearance"`
+}
+
+// ZoneKeepout gives the restrictions of a rule area. Each field is either
+// "allowed" or "not_allowed", and all are empty for a copper zone.
+type ZoneKeepout struct {
+	Tracks     string `kicad:"tracks"`
+	Vias       string `kicad:"vias"`
+	Pads       string `kicad:"pads"`
+	CopperPour string `kicad:"copperpour"`
+	Footprints string `kicad:"footprints"`
+}
+
+// ZoneFill holds the settings used to fill a zone.
+//
+// Mode is empty for a solid fill or "hatch" for a hatched fill.
+// IslandRemovalMode is 0 to remove all isolated islands, 1 to keep them
+// all, or 2 to remove only those smaller than IslandAreaMin square
+// millimeters.
+type ZoneFill struct {
+	Enabled              bool    `kicad:",optional"`
+	Mode                 string  `kicad:"mode"`
+	ArcSegments          int     `kicad:"arc_segments"`
+	ThermalGap           float64 `kicad:"thermal_gap"`
+	ThermalBridgeWidth   float64 `kicad:"thermal_bridge_width"`
+	Smoothing            string  `kicad:"smoothing"`
+	Radius               float64 `kicad:"radius"`
+	IslandRemovalMode    int     `kicad:"island_removal_mode"`
+	IslandAreaMin        float64 `kicad:"island_area_min"`
+	HatchThickness       float64 `kicad:"hatch_thickness"`
+	HatchGap             float64 `kicad:"hatch_gap"`
+	HatchOrientation     float64 `kicad:"hatch_orientation"`
+	HatchSmoothingLevel  int     `kicad:"hatch_smoothing_level"`
+	HatchSmoothingValue  float64 `kicad:"hatch_smoothing_value"`
+	HatchBorderAlgorithm string  `kicad:"hatch_border_algorithm"`
+	HatchMinHoleArea     float64 `kicad:"hatch_min_hole_area"`
+}
+
+// ZonePolygon is one polygon of a zone outline.
+type ZonePolygon struct {
+	Points Points `kicad:"pts,flat"`
+}
+
+// ZoneFilledPolygon is one polygon of copper calculated when filling a
+// zone. Layer is empty in documents from KiCad 5, where zones are on a
+// single layer. Island is set if the polygon is not connected to any other
+// item on the zone's net.
+type ZoneFilledPolygon struct {
+	Layer  string `kicad:"layer"`
+	Island bool   `kicad:"island,empty"`
+	Points Points `kicad:"pts,flat"`
+}